@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/retconned/kick-monitor/internal/app"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/monitor"
+)
+
+// commandUsage lists the one-off commands run instead of the server
+const commandUsage = `usage: kick-monitor [command]
+
+Without a command the server starts. Commands:
+  backup             back up channels, profiles and reports to the BACKUP_S3_* storage
+  backups            list the stored backups, newest first
+  restore <key>      restore a backup, keeping rows that already exist
+  spam-benchmark [-runs N] [-min-score S] [-builtin=false] [dataset|dir ...]
+                     run the spam detectors over the built-in golden datasets and the given ones, reporting
+                     precision, recall and runtime; no database needed
+  spam-dataset <livestream_id>
+                     print a livestream's chat as a dataset labeled by its moderation reviews
+  --validate-config  check the environment and exit, with status 1 when the server wouldn't start`
+
+// runCommand runs a one-off command against the database and prints its result as JSON
+func runCommand(args []string) error {
+	var run func(ctx context.Context) (any, error)
+	backups := false
+	switch args[0] {
+	case "backup":
+		run, backups = func(ctx context.Context) (any, error) { return monitor.BackupDatabase(ctx) }, true
+	case "backups":
+		run, backups = func(ctx context.Context) (any, error) { return monitor.ListBackups(ctx) }, true
+	case "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("restore takes the key of a backup, see kick-monitor backups\n\n%s", commandUsage)
+		}
+		run, backups = func(ctx context.Context) (any, error) { return monitor.RestoreBackup(ctx, args[1]) }, true
+	case "spam-benchmark":
+		return runSpamBenchmark(args[1:])
+	case "spam-dataset":
+		var livestreamID uint64
+		var err error
+		if len(args) == 2 {
+			livestreamID, err = strconv.ParseUint(args[1], 10, 64)
+		}
+		if len(args) != 2 || err != nil {
+			return fmt.Errorf("spam-dataset takes the ID of a livestream\n\n%s", commandUsage)
+		}
+		run = func(ctx context.Context) (any, error) { return monitor.ExportSpamDataset(ctx, uint(livestreamID)) }
+	case "--validate-config", "validate-config":
+		report := app.ValidateConfig()
+		fmt.Println(report)
+		if len(report.Errors) > 0 {
+			os.Exit(1)
+		}
+		return nil
+	case "help", "-h", "--help":
+		fmt.Println(commandUsage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], commandUsage)
+	}
+	if backups && !monitor.BackupsEnabled() {
+		return monitor.ErrBackupsDisabled
+	}
+
+	database, err := db.Connect()
+	if err != nil {
+		return err
+	}
+	db.DB = database
+
+	result, err := run(context.Background())
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// runSpamBenchmark benchmarks the spam detectors against golden datasets, without a database
+func runSpamBenchmark(args []string) error {
+	flags := flag.NewFlagSet("spam-benchmark", flag.ContinueOnError)
+	runs := flags.Int("runs", 5, "report builds per dataset, the runtime is their median")
+	minScore := flags.Float64("min-score", 0, "suspicious chatters scoring below this aren't counted as flagged")
+	builtin := flags.Bool("builtin", true, "include the built-in datasets")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, commandUsage)
+	}
+
+	datasets, err := monitor.LoadSpamDatasets(flags.Args(), *builtin)
+	if err != nil {
+		return err
+	}
+	return printJSON(monitor.RunSpamBenchmark(datasets, monitor.SpamBenchmarkOptions{Runs: *runs, MinScore: *minScore}))
+}
+
+func printJSON(result any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
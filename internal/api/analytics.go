@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAnalyticsPeriod = 30 * 24 * time.Hour
+	maxAnalyticsPeriod     = 2 * 366 * 24 * time.Hour
+	maxAnalyticsMetrics    = 8
+	maxAnalyticsFilters    = 100
+)
+
+// splitQueryList splits a comma separated query parameter, dropping empty entries
+func splitQueryList(param string) []string {
+	var values []string
+	for _, part := range strings.Split(param, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// AnalyticsQueryHandler handles
+// GET /analytics/query?metric=hours_watched,peak_viewers&group_by=day|week|month|channel|category|simulcast
+// &series_by=channel|category|simulcast&channel_ids=1,2&category=Just Chatting&simulcast=true|false&from=&to=
+// and returns aggregates over livestream reports as chart-ready series (defaults to the last 30 days).
+func AnalyticsQueryHandler(c echo.Context) error {
+	query := monitor.AnalyticsQuery{
+		Metrics:    splitQueryList(c.QueryParam("metric")),
+		GroupBy:    c.QueryParam("group_by"),
+		SeriesBy:   c.QueryParam("series_by"),
+		Categories: splitQueryList(c.QueryParam("category")),
+	}
+	if query.GroupBy == "" {
+		query.GroupBy = "day"
+	}
+	if len(query.Metrics) > maxAnalyticsMetrics {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("metric may list at most %d metrics", maxAnalyticsMetrics))
+	}
+	if len(query.Categories) > maxAnalyticsFilters {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("category may list at most %d categories", maxAnalyticsFilters))
+	}
+
+	for _, part := range splitQueryList(c.QueryParam("channel_ids")) {
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, fmt.Sprintf("Invalid channel ID '%s'", part))
+		}
+		query.ChannelIDs = append(query.ChannelIDs, uint(id))
+	}
+	if len(query.ChannelIDs) > maxAnalyticsFilters {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, fmt.Sprintf("channel_ids may contain at most %d IDs", maxAnalyticsFilters))
+	}
+
+	if raw := c.QueryParam("simulcast"); raw != "" {
+		simulcast, err := strconv.ParseBool(raw)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "simulcast must be true or false")
+		}
+		query.Simulcast = &simulcast
+	}
+
+	var err error
+	query.End = time.Now().UTC()
+	if raw := c.QueryParam("to"); raw != "" {
+		if query.End, err = time.Parse(time.RFC3339, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "to must be an RFC 3339 timestamp")
+		}
+	}
+	query.Start = query.End.Add(-defaultAnalyticsPeriod)
+	if raw := c.QueryParam("from"); raw != "" {
+		if query.Start, err = time.Parse(time.RFC3339, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "from must be an RFC 3339 timestamp")
+		}
+	}
+	if query.End.Sub(query.Start) > maxAnalyticsPeriod {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "the period may be at most 732 days")
+	}
+
+	if err := query.Validate(); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	result, err := monitor.RunAnalyticsQuery(query)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+	return jsonWithETag(c, http.StatusOK, result)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListBackupsHandler handles GET /protected/admin/backups, the backups stored in the S3-compatible bucket
+func ListBackupsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	backups, err := monitor.ListBackups(ctx)
+	if err != nil {
+		if errors.Is(err, monitor.ErrBackupsDisabled) {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, err.Error())
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to list backups: %v", err))
+	}
+	return c.JSON(http.StatusOK, backups)
+}
+
+// StartBackupHandler handles POST /protected/admin/backups, starting a backup now. It runs as a job; follow it in
+// GET /protected/admin/jobs. Restoring is only offered by the restore command, never over the API.
+func StartBackupHandler(c echo.Context) error {
+	lease, err := monitor.StartBackup()
+	if err != nil {
+		switch {
+		case errors.Is(err, monitor.ErrBackupsDisabled):
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, err.Error())
+		case errors.Is(err, monitor.ErrJobLeased):
+			return util.Problem(c, http.StatusConflict, util.ErrConflict, "A backup is already running")
+		case errors.Is(err, monitor.ErrDraining):
+			return util.Problem(c, http.StatusServiceUnavailable, util.ErrDraining, "This instance is draining and takes no new jobs")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to start backup: %v", err))
+	}
+	log.Printf("audit: backup %s started from %s", lease.ID.String(), c.RealIP())
+	return c.JSON(http.StatusAccepted, lease)
+}
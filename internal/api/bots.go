@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetChatterBotScoreHandler handles GET /protected/chatters/:senderID/bot_score, the bot probability of a chatter
+// across the streams they chatted in recently, with the per-stream features behind it
+func GetChatterBotScoreHandler(c echo.Context) error {
+	senderID, err := strconv.Atoi(c.Param("senderID"))
+	if err != nil || senderID <= 0 {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid sender ID")
+	}
+
+	score, err := monitor.GetAccountBotScore(senderID)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch bot score: %v", err))
+	}
+	if score == nil {
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, fmt.Sprintf("No scored streams for sender %d", senderID))
+	}
+	return jsonWithETag(c, http.StatusOK, score)
+}
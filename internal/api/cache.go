@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Public endpoints a CDN may cache, the keys of HTTP_CACHE_TTLS
+const (
+	CacheProfile       = "profile"        // /profile/:username and /mobile/v1/profile/:username
+	CacheEmbed         = "embed"          // /embed/:username and /oembed
+	CacheReports       = "reports"        // /livestream/:livestreamID and /mobile/v1/livestream/:livestreamID
+	CacheLatestReports = "latest_reports" // /channels/:channelID/reports/latest and /reports/latest
+	CacheStatus        = "status"
+	CacheLiveAggregate = "live_aggregate"
+	CacheLiveReport    = "live_report" // /channels/:channelID/reports/live
+)
+
+// cacheTTLs is how long browsers and CDNs may cache each public endpoint, overridden by HTTP_CACHE_TTLS
+// ("profile=2m,reports=1h"). A TTL of 0 makes clients revalidate every time, with the ETag still saving the body.
+var cacheTTLs = cacheTTLsFromEnv()
+
+func cacheTTLsFromEnv() map[string]time.Duration {
+	ttls := map[string]time.Duration{
+		CacheProfile:       time.Minute,
+		CacheEmbed:         EmbedCacheMaxAge,
+		CacheReports:       5 * time.Minute, // Reports change when regenerated or when a VOD is attached
+		CacheLatestReports: time.Minute,
+		CacheStatus:        30 * time.Second,
+		CacheLiveAggregate: 15 * time.Second,
+		CacheLiveReport:    15 * time.Second,
+	}
+
+	raw := strings.TrimSpace(os.Getenv("HTTP_CACHE_TTLS"))
+	if raw == "" {
+		return ttls
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		endpoint, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		endpoint = strings.TrimSpace(endpoint)
+		if !ok {
+			util.InvalidSetting("invalid HTTP_CACHE_TTLS entry %q, expected endpoint=duration", pair)
+			continue
+		}
+		if _, known := ttls[endpoint]; !known {
+			util.InvalidSetting("unknown endpoint %q in HTTP_CACHE_TTLS", endpoint)
+			continue
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			util.InvalidSetting("invalid TTL for %s in HTTP_CACHE_TTLS (%q), using %s", endpoint, value, ttls[endpoint])
+			continue
+		}
+		ttls[endpoint] = ttl
+	}
+	return ttls
+}
+
+// cacheTTL returns the TTL of a public endpoint
+func cacheTTL(endpoint string) time.Duration {
+	return cacheTTLs[endpoint]
+}
+
+// publicCacheControl is the Cache-Control header of a public endpoint. Shared caches may serve a stale copy for
+// twice the TTL while they revalidate it, so a popular profile is fetched from the origin once per TTL.
+func publicCacheControl(endpoint string) string {
+	maxAge := int(cacheTTL(endpoint).Seconds())
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d, s-maxage=%d, stale-while-revalidate=%d", maxAge, maxAge, maxAge*2)
+}
+
+// setPublicCacheControl sets the Cache-Control header of a public endpoint on responses not written through
+// jsonWithCacheControl
+func setPublicCacheControl(c echo.Context, endpoint string) {
+	c.Response().Header().Set(echo.HeaderCacheControl, publicCacheControl(endpoint))
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	calendarHistoryWindow    = 8 * 7 * 24 * time.Hour // How far back streams are analysed for the schedule
+	calendarPredictAhead     = 14 * 24 * time.Hour    // How far ahead streams are predicted
+	calendarMinSlotOccurence = 2                      // Min streams in the same weekday/hour slot to predict it
+	icsDateFormat            = "20060102T150405Z"
+)
+
+// pastStream is a livestream aggregated from livestream_data snapshots
+type pastStream struct {
+	LivestreamID uint
+	Title        string
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+// predictedStream is an expected upcoming stream derived from the channel's schedule
+type predictedStream struct {
+	StartTime   time.Time
+	Duration    time.Duration
+	Occurrences int
+}
+
+// GetChannelCalendarHandler handles GET /channels/:channelID/calendar.ics
+func GetChannelCalendarHandler(c echo.Context) error {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	var channel models.MonitoredChannel
+	if err := db.FromContext(c).First(&channel, channelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, "Channel not found")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch channel: %v", err))
+	}
+
+	var streams []pastStream
+	err = db.FromContext(c).Raw(`
+		SELECT
+			livestream_id,
+			(ARRAY_AGG(session_title ORDER BY created_at DESC))[1] AS title,
+			MIN(start_time) AS start_time,
+			MAX(created_at) AS end_time
+		FROM livestream_data
+		WHERE channel_id = ?
+		GROUP BY livestream_id
+		ORDER BY start_time DESC
+	`, channelID).Scan(&streams).Error
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch streams: %v", err))
+	}
+
+	now := time.Now().UTC()
+	predictions := predictUpcomingStreams(streams, now)
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.ics"`, channel.Username))
+	return c.String(http.StatusOK, buildICS(channel, streams, predictions, now))
+}
+
+// predictUpcomingStreams finds recurring weekday/hour slots in recent streams and projects them forward
+func predictUpcomingStreams(streams []pastStream, now time.Time) []predictedStream {
+	type slot struct {
+		Weekday time.Weekday
+		Hour    int
+	}
+	durations := make(map[slot][]time.Duration)
+
+	for _, s := range streams {
+		if s.StartTime.IsZero() || now.Sub(s.StartTime) > calendarHistoryWindow {
+			continue
+		}
+		start := s.StartTime.UTC()
+		key := slot{Weekday: start.Weekday(), Hour: start.Hour()}
+		durations[key] = append(durations[key], s.EndTime.Sub(s.StartTime))
+	}
+
+	predictions := []predictedStream{}
+	for key, ds := range durations {
+		if len(ds) < calendarMinSlotOccurence {
+			continue
+		}
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		median := ds[len(ds)/2]
+
+		// Next occurrences of the slot within the prediction window
+		day := time.Date(now.Year(), now.Month(), now.Day(), key.Hour, 0, 0, 0, time.UTC)
+		for day.Weekday() != key.Weekday || !day.After(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+		for ; day.Sub(now) <= calendarPredictAhead; day = day.AddDate(0, 0, 7) {
+			predictions = append(predictions, predictedStream{StartTime: day, Duration: median, Occurrences: len(ds)})
+		}
+	}
+
+	sort.Slice(predictions, func(i, j int) bool { return predictions[i].StartTime.Before(predictions[j].StartTime) })
+	return predictions
+}
+
+// buildICS renders the calendar following RFC 5545
+func buildICS(channel models.MonitoredChannel, streams []pastStream, predictions []predictedStream, now time.Time) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(foldICSLine(line))
+		b.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//kick-monitor//stream calendar//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:" + escapeICSText(channel.Username+" streams"))
+
+	for _, s := range streams {
+		if s.StartTime.IsZero() {
+			continue
+		}
+		end := s.EndTime
+		if end.Before(s.StartTime) {
+			end = s.StartTime
+		}
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:livestream-%d@kick-monitor", s.LivestreamID))
+		writeLine("DTSTAMP:" + now.Format(icsDateFormat))
+		writeLine("DTSTART:" + s.StartTime.UTC().Format(icsDateFormat))
+		writeLine("DTEND:" + end.UTC().Format(icsDateFormat))
+		writeLine("SUMMARY:" + escapeICSText(s.Title))
+		writeLine("DESCRIPTION:" + escapeICSText(fmt.Sprintf("%s streamed for %d minutes", channel.Username, int(end.Sub(s.StartTime).Minutes()))))
+		writeLine("URL:https://kick.com/" + channel.Username)
+		writeLine("STATUS:CONFIRMED")
+		writeLine("END:VEVENT")
+	}
+
+	for _, p := range predictions {
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:predicted-%d-%s@kick-monitor", channel.ChannelID, p.StartTime.Format(icsDateFormat)))
+		writeLine("DTSTAMP:" + now.Format(icsDateFormat))
+		writeLine("DTSTART:" + p.StartTime.Format(icsDateFormat))
+		writeLine("DTEND:" + p.StartTime.Add(p.Duration).Format(icsDateFormat))
+		writeLine("SUMMARY:" + escapeICSText(channel.Username+" (predicted stream)"))
+		writeLine("DESCRIPTION:" + escapeICSText(fmt.Sprintf("Predicted from %d past streams in this time slot", p.Occurrences)))
+		writeLine("URL:https://kick.com/" + channel.Username)
+		writeLine("STATUS:TENTATIVE")
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+// escapeICSText escapes text values as required by RFC 5545
+func escapeICSText(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(s)
+}
+
+// foldICSLine folds content lines longer than 75 octets without splitting UTF-8 sequences
+func foldICSLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// UpdateChannelRequest is the body of PATCH /protected/channels/:username
+type UpdateChannelRequest struct {
+	IsActive *bool `json:"is_active"`
+}
+
+// UpdateChannelResponse is the channel after an update
+type UpdateChannelResponse struct {
+	models.MonitoredChannel
+	Changed bool `json:"changed"` // False when the channel already was in the requested state
+}
+
+// monitoredChannelByUsername loads the channel of the :username param, following renames
+func monitoredChannelByUsername(c echo.Context) (*models.MonitoredChannel, error) {
+	var channel models.MonitoredChannel
+	err := db.FromContext(c).Where("username = ?", monitor.ResolveUsername(c.Param("username"))).First(&channel).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, util.NewProblem(http.StatusNotFound, util.ErrChannelNotFound, "Channel is not monitored")
+	case err != nil:
+		log.Printf("Database error looking up channel %s: %v", c.Param("username"), err)
+		return nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, "Database error looking up channel")
+	}
+	return &channel, nil
+}
+
+// requester is the email of the authenticated user, for audit logs
+func requester(c echo.Context) string {
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		return claims.Email
+	}
+	return "anonymous"
+}
+
+// authorizeChannel lets operators, the user who added the channel and users with at least teamRole in a team the
+// channel is shared with act on it. Everyone else gets a 403, audit-logged with what they tried.
+func authorizeChannel(c echo.Context, channel *models.MonitoredChannel, teamRole, action string) error {
+	if auth.IsAdmin(c) {
+		return nil
+	}
+	userID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	if channel.AddedBy != nil && *channel.AddedBy == userID {
+		return nil
+	}
+	roles := make([]string, 0, len(monitor.TeamRoleRank))
+	for role, rank := range monitor.TeamRoleRank {
+		if rank >= monitor.TeamRoleRank[teamRole] {
+			roles = append(roles, role)
+		}
+	}
+	var memberships int64
+	if err := db.FromContext(c).Model(&models.TeamMember{}).
+		Joins("JOIN team_channels ON team_channels.team_id = team_members.team_id").
+		Where("team_channels.channel_id = ? AND team_members.user_id = ? AND team_members.role IN ?", channel.ChannelID, userID, roles).
+		Count(&memberships).Error; err != nil {
+		log.Printf("Error checking team access of %s to channel %s: %v", userID, channel.Username, err)
+		return util.NewProblem(http.StatusInternalServerError, util.ErrInternal, "Failed to check channel access")
+	}
+	if memberships > 0 {
+		return nil
+	}
+	log.Printf("audit: rejected %s of channel %s by %s from %s", action, channel.Username, requester(c), c.RealIP())
+	return util.NewProblem(http.StatusForbidden, util.ErrForbidden, fmt.Sprintf("Only operators, the user who added the channel and %ss of its teams may do this", teamRole))
+}
+
+// UpdateChannelHandler handles PATCH /protected/channels/:username, activating or deactivating a channel.
+// Deactivating stops its fetch and WebSocket routines and keeps everything collected. Only operators, the user who
+// added the channel and admins of its teams may do either.
+func UpdateChannelHandler(c echo.Context) error {
+	req := new(UpdateChannelRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if req.IsActive == nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "is_active is required")
+	}
+	channel, err := monitoredChannelByUsername(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, fmt.Sprintf("setting is_active=%t", *req.IsActive)); err != nil {
+		return err
+	}
+	if *req.IsActive && !monitor.UsernameAllowed(channel.Username) {
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, fmt.Sprintf("Channel %s is not on this instance's allow-list", channel.Username))
+	}
+
+	changed, err := monitor.SetChannelActive(channel, *req.IsActive)
+	if err != nil {
+		log.Printf("Error updating channel %s: %v", channel.Username, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update channel status")
+	}
+	if changed {
+		log.Printf("audit: channel %s (ID: %d) set to is_active=%t by %s from %s", channel.Username, channel.ChannelID, channel.IsActive, requester(c), c.RealIP())
+	}
+	return c.JSON(http.StatusOK, UpdateChannelResponse{MonitoredChannel: *channel, Changed: changed})
+}
+
+// DeleteChannelHandler handles DELETE /protected/channels/:username?purge=true, removing a channel. Its reports
+// and profile stay readable unless purge deletes everything collected about it as well. Operators may remove any
+// channel and are the only ones who may purge; the user who added a channel and admins of its teams may remove it.
+func DeleteChannelHandler(c echo.Context) error {
+	channel, err := monitoredChannelByUsername(c)
+	if err != nil {
+		return err
+	}
+	purge := c.QueryParam("purge") == "true"
+
+	if purge && !auth.IsAdmin(c) {
+		log.Printf("audit: rejected purging channel %s by non-operator %s from %s", channel.Username, requester(c), c.RealIP())
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "Only operators may purge a channel")
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "removal"); err != nil {
+		return err
+	}
+
+	removal, err := monitor.RemoveChannel(c.Request().Context(), channel, purge)
+	if err != nil {
+		log.Printf("Error removing channel %s: %v", channel.Username, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to remove channel")
+	}
+	log.Printf("audit: channel %s (ID: %d) removed (purged: %t) by %s from %s", channel.Username, channel.ChannelID, purge, requester(c), c.RealIP())
+	return c.JSON(http.StatusOK, removal)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ChatterListRequest struct {
+	List     string `json:"list"` // excluded or trusted
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+// GetChatterListsHandler handles GET /protected/channels/:channelID/chatter_lists?list=excluded|trusted
+func GetChatterListsHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	query := db.FromContext(c).Where("channel_id = ?", channel.ChannelID)
+	if list := c.QueryParam("list"); list != "" {
+		if !monitor.IsValidChatterList(list) {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "list must be excluded or trusted")
+		}
+		query = query.Where("list = ?", list)
+	}
+
+	entries := []models.ChatterListEntry{}
+	if err := query.Order("created_at DESC").Find(&entries).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch chatter lists: %v", err))
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// PutChatterListHandler handles PUT /protected/channels/:channelID/chatter_lists/:senderID. Reports generated
+// afterwards leave excluded chatters out of every metric and never flag trusted chatters as suspicious. Only
+// operators, the user who added the channel and admins of its teams may change the lists.
+func PutChatterListHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "changing chatter lists"); err != nil {
+		return err
+	}
+	senderID, err := strconv.Atoi(c.Param("senderID"))
+	if err != nil || senderID <= 0 {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid sender ID format")
+	}
+
+	req := new(ChatterListRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if !monitor.IsValidChatterList(req.List) {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "list must be excluded or trusted")
+	}
+
+	addedBy := ""
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		addedBy = claims.Email
+	}
+
+	entry, err := monitor.SetChatterList(models.ChatterListEntry{
+		ChannelID:      channel.ChannelID,
+		SenderID:       senderID,
+		SenderUsername: req.Username,
+		List:           req.List,
+		Reason:         req.Reason,
+		AddedBy:        addedBy,
+	})
+	if err != nil {
+		log.Printf("Error updating chatter lists of channel %d: %v", channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update chatter list")
+	}
+
+	log.Printf("Chatter %d put on the %s list of channel %s by %s", senderID, entry.List, channel.Username, addedBy)
+	return c.JSON(http.StatusOK, entry)
+}
+
+// DeleteChatterListHandler handles DELETE /protected/channels/:channelID/chatter_lists/:senderID, allowed to the
+// same users as putting a chatter on a list
+func DeleteChatterListHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "changing chatter lists"); err != nil {
+		return err
+	}
+	senderID, err := strconv.Atoi(c.Param("senderID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid sender ID format")
+	}
+
+	removed, err := monitor.RemoveChatterFromLists(channel.ChannelID, senderID)
+	if err != nil {
+		log.Printf("Error updating chatter lists of channel %d: %v", channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update chatter list")
+	}
+	if !removed {
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Chatter is not on a list of this channel")
+	}
+	log.Printf("Chatter %d taken off the lists of channel %s by %s", senderID, channel.Username, requester(c))
+	return c.NoContent(http.StatusNoContent)
+}
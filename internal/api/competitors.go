@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCompetitorSetMembers  = 50
+	defaultCompetitorPeriod  = 30 * 24 * time.Hour
+	maxCompetitorPeriod      = 366 * 24 * time.Hour
+	competitorSetNameMaxSize = 255
+)
+
+type CompetitorSetRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ChannelIDs  []uint `json:"channel_ids"`
+}
+
+// validate normalizes the request and checks every channel is monitored
+func (req *CompetitorSetRequest) validate(tx *gorm.DB) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > competitorSetNameMaxSize {
+		return fmt.Errorf("name is required and must be at most %d characters", competitorSetNameMaxSize)
+	}
+
+	seen := make(map[uint]struct{}, len(req.ChannelIDs))
+	unique := make([]uint, 0, len(req.ChannelIDs))
+	for _, id := range req.ChannelIDs {
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			unique = append(unique, id)
+		}
+	}
+	req.ChannelIDs = unique
+	if len(req.ChannelIDs) < 2 || len(req.ChannelIDs) > maxCompetitorSetMembers {
+		return fmt.Errorf("channel_ids must contain between 2 and %d channels", maxCompetitorSetMembers)
+	}
+
+	var found int64
+	if err := tx.Model(&models.MonitoredChannel{}).Where("channel_id IN ?", req.ChannelIDs).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(req.ChannelIDs) {
+		return errors.New("channel_ids may only contain monitored channels")
+	}
+	return nil
+}
+
+func (req *CompetitorSetRequest) members(setID uuid.UUID) []models.CompetitorSetMember {
+	members := make([]models.CompetitorSetMember, 0, len(req.ChannelIDs))
+	for _, channelID := range req.ChannelIDs {
+		members = append(members, models.CompetitorSetMember{SetID: setID, ChannelID: channelID})
+	}
+	return members
+}
+
+// competitorSetFromParam loads the :setID competitor set of the requesting tenant
+func competitorSetFromParam(c echo.Context) (*models.CompetitorSet, error) {
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return nil, util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	setID, err := uuid.Parse(c.Param("setID"))
+	if err != nil {
+		return nil, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "Invalid competitor set ID format")
+	}
+
+	var set models.CompetitorSet
+	if err := db.FromContext(c).Preload("Members").Where("id = ? AND tenant_id = ?", setID, tenantID).First(&set).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.NewProblem(http.StatusNotFound, util.ErrNotFound, "Competitor set not found")
+		}
+		return nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch competitor set: %v", err))
+	}
+	return &set, nil
+}
+
+// GetCompetitorSetsHandler handles GET /protected/competitor_sets
+func GetCompetitorSetsHandler(c echo.Context) error {
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	sets := []models.CompetitorSet{}
+	if err := db.FromContext(c).Preload("Members").Where("tenant_id = ?", tenantID).Order("name ASC").Find(&sets).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch competitor sets: %v", err))
+	}
+	return c.JSON(http.StatusOK, sets)
+}
+
+// GetCompetitorSetHandler handles GET /protected/competitor_sets/:setID
+func GetCompetitorSetHandler(c echo.Context) error {
+	set, err := competitorSetFromParam(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, set)
+}
+
+// CreateCompetitorSetHandler handles POST /protected/competitor_sets
+func CreateCompetitorSetHandler(c echo.Context) error {
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	req := new(CompetitorSetRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if err := req.validate(db.FromContext(c)); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	set := models.CompetitorSet{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	set.Members = req.members(set.ID)
+	if err := db.FromContext(c).Create(&set).Error; err != nil {
+		log.Printf("Error creating competitor set %s: %v", set.Name, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to create competitor set")
+	}
+
+	return c.JSON(http.StatusCreated, set)
+}
+
+// UpdateCompetitorSetHandler handles PUT /protected/competitor_sets/:setID, replacing the name, description and members
+func UpdateCompetitorSetHandler(c echo.Context) error {
+	set, err := competitorSetFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(CompetitorSetRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if err := req.validate(db.FromContext(c)); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	members := req.members(set.ID)
+	err = db.FromContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(set).Updates(map[string]any{"name": req.Name, "description": req.Description}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("set_id = ?", set.ID).Delete(&models.CompetitorSetMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&members).Error
+	})
+	if err != nil {
+		log.Printf("Error updating competitor set %s: %v", set.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update competitor set")
+	}
+
+	set.Name, set.Description, set.Members = req.Name, req.Description, members
+	return c.JSON(http.StatusOK, set)
+}
+
+// DeleteCompetitorSetHandler handles DELETE /protected/competitor_sets/:setID
+func DeleteCompetitorSetHandler(c echo.Context) error {
+	set, err := competitorSetFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := db.FromContext(c).Delete(set).Error; err != nil {
+		log.Printf("Error deleting competitor set %s: %v", set.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to delete competitor set")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetCompetitorAnalyticsHandler handles GET /protected/competitor_sets/:setID/analytics?from=&to=&interval=day|week
+// and returns market share analytics of the set's members (defaults to the last 30 days, daily trends).
+func GetCompetitorAnalyticsHandler(c echo.Context) error {
+	set, err := competitorSetFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now().UTC()
+	if raw := c.QueryParam("to"); raw != "" {
+		if end, err = time.Parse(time.RFC3339, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "to must be an RFC 3339 timestamp")
+		}
+	}
+	start := end.Add(-defaultCompetitorPeriod)
+	if raw := c.QueryParam("from"); raw != "" {
+		if start, err = time.Parse(time.RFC3339, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "from must be an RFC 3339 timestamp")
+		}
+	}
+	if !start.Before(end) || end.Sub(start) > maxCompetitorPeriod {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "from must be before to and the period at most 366 days")
+	}
+
+	interval := c.QueryParam("interval")
+	switch interval {
+	case "":
+		interval = monitor.TrendIntervalDay
+	case monitor.TrendIntervalDay, monitor.TrendIntervalWeek:
+	default:
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "interval must be day or week")
+	}
+
+	analytics, err := monitor.BuildCompetitorAnalytics(*set, start, end, interval)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build competitor analytics: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, analytics)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetDailyDigestsHandler handles GET /protected/digests/daily?limit=, the stored fleet digests newest first
+func GetDailyDigestsHandler(c echo.Context) error {
+	limit := 30
+	if raw := c.QueryParam("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > 366 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "limit must be between 1 and 366")
+		}
+	}
+	digests, err := monitor.GetDailyDigests(limit)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+	return jsonWithETag(c, http.StatusOK, digests)
+}
+
+// GetDailyDigestHandler handles GET /protected/digests/daily/:day, the fleet digest of a UTC day (YYYY-MM-DD).
+// With preview=true a digest not generated yet is built on the fly, without storing it.
+func GetDailyDigestHandler(c echo.Context) error {
+	day, err := monitor.ParseDigestDay(c.Param("day"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "day must be a date formatted as YYYY-MM-DD")
+	}
+
+	digest, err := monitor.GetDailyDigest(day)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if c.QueryParam("preview") != "true" {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, fmt.Sprintf("No digest was generated for %s", c.Param("day")))
+		}
+		built, err := monitor.BuildFleetDigest(day)
+		if err != nil {
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build digest: %v", err))
+		}
+		return c.JSON(http.StatusOK, built)
+	}
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch digest: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, digest)
+}
+
+// RegenerateDailyDigestHandler handles POST /protected/admin/digests/daily/:day, rebuilding and storing the fleet
+// digest of a past day, e.g. after its reports were regenerated. It is not delivered again.
+func RegenerateDailyDigestHandler(c echo.Context) error {
+	day, err := monitor.ParseDigestDay(c.Param("day"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "day must be a date formatted as YYYY-MM-DD")
+	}
+	if !day.AddDate(0, 0, 1).Before(time.Now()) {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "only the digest of a past day can be generated")
+	}
+
+	if _, err := monitor.GenerateDailyDigest(day); err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to generate digest: %v", err))
+	}
+	log.Printf("audit: daily digest of %s regenerated from %s", c.Param("day"), c.RealIP())
+	digest, err := monitor.GetDailyDigest(day)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch digest: %v", err))
+	}
+	return c.JSON(http.StatusOK, digest)
+}
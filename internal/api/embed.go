@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// EmbedCacheMaxAge is how long browsers and CDNs may cache embed responses, unless HTTP_CACHE_TTLS sets embed
+var EmbedCacheMaxAge = util.GetEnvDuration("EMBED_CACHE_MAX_AGE", 5*time.Minute)
+
+const (
+	embedWidth  = 350
+	embedHeight = 150
+)
+
+// OEmbedResponse is a "rich" oEmbed 1.0 response (https://oembed.com)
+type OEmbedResponse struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	ProviderName string `json:"provider_name"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+var embedCardTemplate = template.Must(template.New("embed").Parse(
+	`<div class="kick-monitor-embed" style="width:{{.Width}}px;height:{{.Height}}px;box-sizing:border-box;overflow:hidden;font-family:sans-serif;border:1px solid #ccc;border-radius:8px;padding:12px">` +
+		`<a href="{{.Summary.ChannelURL}}" target="_blank" rel="noopener"><strong>{{.Summary.Username}}</strong></a>` +
+		`{{if .Summary.IsLive}} <span style="color:#e91916">● LIVE · {{.Summary.Live.Viewers}} viewers</span>{{end}}` +
+		`<div>{{.Summary.Followers}} followers</div>` +
+		`{{with .Summary.LastStream}}<div>Last stream: {{.AverageViewers}} avg / {{.PeakViewers}} peak viewers, {{.UniqueChatters}} chatters</div>{{end}}` +
+		`<div>Spam: {{.Summary.SpamBadge}}{{with .Summary.SpamScore}} ({{.}}/100){{end}}</div>` +
+		`</div>`))
+
+// GetEmbedHandler handles GET /embed/:username?format=json|oembed, the public summary behind embed widgets
+func GetEmbedHandler(c echo.Context) error {
+	return writeEmbed(c, c.Param("username"), c.QueryParam("format"))
+}
+
+// OEmbedHandler handles GET /oembed?url=&maxwidth=&maxheight=, the oEmbed discovery endpoint. url may be a Kick
+// channel URL or an /embed/:username URL of this API.
+func OEmbedHandler(c echo.Context) error {
+	if format := c.QueryParam("format"); format != "" && format != "json" {
+		return util.Problem(c, http.StatusNotImplemented, util.ErrValidationFailed, "Only the json oEmbed format is supported")
+	}
+	resource, err := url.Parse(c.QueryParam("url"))
+	if err != nil || resource.Path == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "url must be a channel URL")
+	}
+	segments := strings.Split(strings.Trim(resource.Path, "/"), "/")
+	return writeEmbed(c, segments[len(segments)-1], "oembed")
+}
+
+func writeEmbed(c echo.Context, username, format string) error {
+	if username == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Username is required in the path")
+	}
+	if format != "" && format != "json" && format != "oembed" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "format must be json or oembed")
+	}
+
+	summary, err := monitor.BuildEmbedSummary(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, fmt.Sprintf("Channel '%s' is not monitored", username))
+		}
+		log.Printf("Error building embed summary for '%s': %v", username, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to build embed summary")
+	}
+
+	if format != "oembed" {
+		return jsonWithCacheControl(c, http.StatusOK, summary, publicCacheControl(CacheEmbed))
+	}
+
+	width := boundedDimension(c.QueryParam("maxwidth"), embedWidth)
+	height := boundedDimension(c.QueryParam("maxheight"), embedHeight)
+	var card bytes.Buffer
+	if err := embedCardTemplate.Execute(&card, map[string]any{"Summary": summary, "Width": width, "Height": height}); err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to render embed: %v", err))
+	}
+
+	return jsonWithCacheControl(c, http.StatusOK, OEmbedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        summary.Username + " on Kick",
+		AuthorName:   summary.Username,
+		AuthorURL:    summary.ChannelURL,
+		ProviderName: "Kick Monitor",
+		CacheAge:     int(cacheTTL(CacheEmbed).Seconds()),
+		HTML:         card.String(),
+		Width:        width,
+		Height:       height,
+	}, publicCacheControl(CacheEmbed))
+}
+
+// boundedDimension returns the default size, shrunk to the consumer's maxwidth/maxheight when one is given
+func boundedDimension(raw string, size int) int {
+	if limit, err := strconv.Atoi(raw); err == nil && limit > 0 && limit < size {
+		return limit
+	}
+	return size
+}
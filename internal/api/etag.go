@@ -0,0 +1,76 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jsonWithETag writes payload as JSON with a content-based ETag, answering 304 Not Modified
+// when the client's If-None-Match already matches so polling dashboards skip the body.
+func jsonWithETag(c echo.Context, status int, payload any) error {
+	return jsonWithCacheControl(c, status, payload, "no-cache")
+}
+
+// jsonWithCacheControl is jsonWithETag with a custom Cache-Control header, e.g. to let CDNs cache public responses
+func jsonWithCacheControl(c echo.Context, status int, payload any, cacheControl string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to encode response: %v", err))
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderCacheControl, cacheControl)
+
+	if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSONBlob(status, body)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag (weak comparison)
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == target {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSince reads the optional ?since= query parameter (RFC 3339 or unix seconds) used for delta responses
+func parseSince(c echo.Context) (*time.Time, error) {
+	raw := c.QueryParam("since")
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	var unix int64
+	if _, err := fmt.Sscanf(raw, "%d", &unix); err == nil {
+		t := time.Unix(unix, 0)
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid since value '%s': expected RFC 3339 timestamp or unix seconds", raw)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/quota"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	exportDefaultLimit = 10000
+	exportMaxLimit     = 100000
+)
+
+// exportEnd is the last line of an export, even when it stopped on an error
+type exportEnd struct {
+	Kind       string `json:"kind"` // Always "end"
+	NextCursor string `json:"next_cursor,omitempty"`
+	More       bool   `json:"more"` // Request again with since=next_cursor; false once caught up
+	Error      string `json:"error,omitempty"`
+}
+
+// ExportEventsHandler handles GET /protected/export/events?since=&limit= streaming the ingested events (chat,
+// reactions, channel snapshots, viewer samples, reports) as NDJSON in insertion order. since is the cursor of the
+// last event received, or an RFC 3339 time to start from; omitted, the export starts with the oldest event.
+func ExportEventsHandler(c echo.Context) error {
+	cursor, err := monitor.ParseExportCursor(c.QueryParam("since"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "since must be an export cursor or an RFC 3339 time")
+	}
+	limit := exportDefaultLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > exportMaxLimit {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("limit must be between 1 and %d", exportMaxLimit))
+		}
+		limit = parsed
+	}
+
+	// Events count against the tenant's export_rows quota, an export stops early with more=true once it runs out.
+	// The page is reserved up front and what isn't written given back.
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return util.Problem(c, http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	usage, err := quota.Usage(db.FromContext(c), tenantID, quota.MetricExportRows)
+	if err != nil {
+		log.Printf("Error checking quota %s for tenant %s: %v", quota.MetricExportRows, tenantID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+	}
+	if usage.Limit > 0 {
+		limit = min(limit, max(usage.Limit-usage.Used, 1))
+	}
+	usage, ok, err := quota.Reserve(db.FromContext(c), tenantID, quota.MetricExportRows, limit)
+	if err != nil {
+		log.Printf("Error checking quota %s for tenant %s: %v", quota.MetricExportRows, tenantID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+	}
+	if !ok {
+		return quota.Exceeded(c, usage)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+	written := 0
+	last, more, err := monitor.StreamExportEvents(c.Request().Context(), cursor, limit, func(event monitor.ExportEvent) error {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+		if written++; written%100 == 0 {
+			res.Flush()
+		}
+		return nil
+	})
+	if err := quota.Release(db.FromContext(c), tenantID, quota.MetricExportRows, limit-written); err != nil {
+		log.Printf("Error releasing quota usage %s for tenant %s: %v", quota.MetricExportRows, tenantID, err)
+	}
+
+	end := exportEnd{Kind: "end", More: more}
+	if !last.Time.IsZero() {
+		end.NextCursor = last.String()
+	}
+	if err != nil {
+		if c.Request().Context().Err() != nil {
+			return nil // Client went away
+		}
+		log.Printf("Error exporting events after %s: %v", end.NextCursor, err)
+		end.Error = "export interrupted, resume from next_cursor"
+	}
+	if err := encoder.Encode(end); err != nil {
+		return nil
+	}
+	res.Flush()
+	return nil
+}
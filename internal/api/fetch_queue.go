@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+const fetcherContextKey = "fetcher"
+
+// WithFetcher returns middleware making fetcher the Fetcher the requests' Kick lookups go through
+func WithFetcher(fetcher monitor.Fetcher) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(fetcherContextKey, fetcher)
+			return next(c)
+		}
+	}
+}
+
+// fetcherFrom returns the Fetcher WithFetcher set for the request, nil when none was
+func fetcherFrom(c echo.Context) monitor.Fetcher {
+	fetcher, _ := c.Get(fetcherContextKey).(monitor.Fetcher)
+	return fetcher
+}
+
+// GetFetchQueueHandler handles GET /protected/admin/fetch_queue, the queued and in-flight proxy requests of this
+// instance and when each channel is fetched next, to tell why a channel's data is stale
+func GetFetchQueueHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, monitor.GetFetchQueueStatus())
+}
+
+// PrioritizeChannelFetchesHandler handles POST /protected/admin/fetch_queue/:channelID/prioritize, fetching the
+// channel now and putting its proxy requests at the front of the queue for FETCH_PRIORITY_DURATION
+func PrioritizeChannelFetchesHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	until, err := monitor.PrioritizeChannelFetches(channel.ChannelID)
+	if errors.Is(err, monitor.ErrChannelNotFetched) {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "The channel is not fetched by this instance, ask the instance monitoring it")
+	}
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to prioritize the channel")
+	}
+
+	log.Printf("audit: fetches of channel %s prioritized until %s from %s", channel.Username, until.Format("15:04:05"), c.RealIP())
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"channel_id":        channel.ChannelID,
+		"username":          channel.Username,
+		"prioritized_until": until,
+	})
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxSparseFields caps the fields a request may select
+const maxSparseFields = 100
+
+// fieldSet is a sparse fieldset from ?fields=, a tree of the selected JSON fields. A field without children is
+// kept whole, dotted paths select inside nested objects: fields=username,livestreams.peak_viewers.
+type fieldSet map[string]fieldSet
+
+// parseFields parses a comma separated list of dotted JSON field paths, nil when raw is empty
+func parseFields(raw string) (fieldSet, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxSparseFields {
+		return nil, fmt.Errorf("fields may list at most %d fields", maxSparseFields)
+	}
+	fields := fieldSet{}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		node := fields
+		segments := strings.Split(part, ".")
+		for i, segment := range segments {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid field '%s'", part)
+			}
+			if i == len(segments)-1 {
+				node[segment] = nil // The whole field, whatever was selected inside it
+				break
+			}
+			child, seen := node[segment]
+			if seen && child == nil {
+				break // Already selected whole
+			}
+			if !seen {
+				child = fieldSet{}
+				node[segment] = child
+			}
+			node = child
+		}
+	}
+	return fields, nil
+}
+
+// apply keeps the selected fields of the JSON value. Arrays are filtered element by element, and values that
+// aren't objects are kept as they are. Fields none of the filtered objects have are reported as unknown.
+func (f fieldSet) apply(raw json.RawMessage) (json.RawMessage, error) {
+	unknown := map[string]struct{}{}
+	filtered, err := f.filter(raw, "", unknown)
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		names := make([]string, 0, len(unknown))
+		for name := range unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown field(s): %s", strings.Join(names, ", "))
+	}
+	return filtered, nil
+}
+
+func (f fieldSet) filter(raw json.RawMessage, prefix string, unknown map[string]struct{}) (json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		// A field is unknown when no element has it
+		missing := map[string]int{}
+		for i, item := range items {
+			itemUnknown := map[string]struct{}{}
+			filtered, err := f.filter(item, prefix, itemUnknown)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = filtered
+			for name := range itemUnknown {
+				missing[name]++
+			}
+		}
+		for name, count := range missing {
+			if count == len(items) {
+				unknown[name] = struct{}{}
+			}
+		}
+		return json.Marshal(items)
+	case strings.HasPrefix(trimmed, "{"):
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+		kept := make(map[string]json.RawMessage, len(f))
+		for name, children := range f {
+			value, ok := object[name]
+			if !ok {
+				unknown[prefix+name] = struct{}{}
+				continue
+			}
+			if children != nil {
+				var err error
+				if value, err = children.filter(value, prefix+name+".", unknown); err != nil {
+					return nil, err
+				}
+			}
+			kept[name] = value
+		}
+		return json.Marshal(kept)
+	default:
+		return raw, nil
+	}
+}
+
+// jsonWithFields is jsonWithCacheControl honouring ?fields=, a sparse fieldset trimming the payload to the fields a
+// dashboard renders
+func jsonWithFields(c echo.Context, status int, payload any, cacheControl string) error {
+	fields, err := parseFields(c.QueryParam("fields"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+	if fields == nil {
+		return jsonWithCacheControl(c, status, payload, cacheControl)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to encode response: %v", err))
+	}
+	filtered, err := fields.apply(body)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+	return jsonWithCacheControl(c, status, filtered, cacheControl)
+}
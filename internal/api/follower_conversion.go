@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetFollowerConversionHandler handles GET /channels/:channelID/follower_conversion?days=&limit=, the channel's
+// streams of the last days ranked by followers gained per hour watched
+func GetFollowerConversionHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	days, limit := 90, 20
+	if raw := c.QueryParam("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 365 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "days must be between 1 and 365")
+		}
+		days = parsed
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "limit must be between 1 and 100")
+		}
+		limit = parsed
+	}
+
+	ranked, err := monitor.RankFollowerConversion(channel.ChannelID, time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to rank streams: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, ranked)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetChannelForecastHandler handles GET /channels/:channelID/forecast, the 7 and 30 day projections of average
+// viewers and followers
+func GetChannelForecastHandler(c echo.Context) error {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	var channel models.MonitoredChannel
+	if err := db.FromContext(c).First(&channel, channelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, "Channel not found")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch channel: %v", err))
+	}
+
+	forecast, err := monitor.BuildForecast(channel, time.Now())
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build forecast: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, forecast)
+}
@@ -1,16 +1,21 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/cluster"
 	"github.com/retconned/kick-monitor/internal/db"
 	"github.com/retconned/kick-monitor/internal/models"
 	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -23,7 +28,9 @@ type AddChannelRequest struct {
 }
 
 type ProcessLivestreamReportRequest struct {
-	LivestreamID uint `json:"livestream_id"`
+	LivestreamID uint       `json:"livestream_id"`
+	StartTime    *time.Time `json:"start_time"` // Optional window, e.g. a sponsored segment, reported next to the full report
+	EndTime      *time.Time `json:"end_time"`
 }
 
 type FullLivestreamReport struct {
@@ -35,6 +42,13 @@ type HealthCheckResponse struct {
 	Status    string `json:"status"`
 	Timestamp string `json:"timestamp"`
 	Message   string `json:"message"`
+
+	Pusher           monitor.PusherHealth          `json:"pusher"`
+	TimestampParsing monitor.TimestampParseStats   `json:"timestamp_parsing"`
+	Capacity         monitor.ConcurrencyBudget     `json:"capacity"`
+	IngestionLag     monitor.IngestionLagHistogram `json:"ingestion_lag"`
+	WritePipelines   []monitor.WritePipelineStats  `json:"write_pipelines"`
+	Drain            *monitor.DrainStatus          `json:"drain,omitempty"` // Only while draining
 }
 
 func HealthCheckHandler(c echo.Context) error {
@@ -42,32 +56,215 @@ func HealthCheckHandler(c echo.Context) error {
 		Status:    "healthy",
 		Timestamp: time.Now().Format(time.RFC3339),
 		Message:   "kick-monitor is alive",
+		Pusher:    monitor.GetPusherHealth(),
+
+		TimestampParsing: monitor.GetTimestampParseStats(),
+		Capacity:         monitor.Capacity,
+		IngestionLag:     monitor.GetIngestionLagHistogram(),
+		WritePipelines:   monitor.GetWritePipelineStats(),
+	}
+	// Chat ingestion is likely broken until the Pusher key is re-detected
+	if response.Pusher.ConsecutiveFailures >= monitor.PusherKeyFailureThreshold {
+		response.Status = "degraded"
+		response.Message = "Pusher subscriptions are failing: " + response.Pusher.LastFailureReason
+	}
+	if monitor.IsDraining() {
+		drain := monitor.GetDrainStatus()
+		response.Status, response.Drain = "draining", &drain
+		response.Message = "kick-monitor is draining ahead of a shutdown"
 	}
 	return c.JSON(http.StatusOK, response)
 }
 
+// PublicStatusHandler handles GET /status, an anonymized snapshot for public status pages
+func PublicStatusHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	status := monitor.GetPublicStatus(ctx)
+	httpStatus := http.StatusOK
+	if status.Status == monitor.StatusDown {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	return jsonWithCacheControl(c, httpStatus, status, publicCacheControl(CacheStatus))
+}
+
+// GetLiveAggregateHandler handles GET /live/aggregate, the total viewers of all live channels right now plus the
+// recent history, for network-wide viewer counters. In a cluster it sums the totals every instance shares, instances
+// tells how many were counted.
+func GetLiveAggregateHandler(c echo.Context) error {
+	return jsonWithCacheControl(c, http.StatusOK, monitor.GetLiveAggregate(), publicCacheControl(CacheLiveAggregate))
+}
+
+// MigrationStatusHandler handles GET /protected/migrations
+func MigrationStatusHandler(c echo.Context) error {
+	statuses, err := db.MigrationStatuses(c.Request().Context())
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch migration status: %v", err))
+	}
+
+	pending := 0
+	var current int64
+	for _, s := range statuses {
+		if s.Applied {
+			current = s.Version
+		} else {
+			pending++
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"current_version": current,
+		"pending":         pending,
+		"migrations":      statuses,
+	})
+}
+
+// MonitorsHandler handles GET /protected/admin/monitors, listing the channels this instance monitors with
+// their fetch health and proxy budget consumption
+func MonitorsHandler(c echo.Context) error {
+	statuses, err := monitor.GetMonitorStatuses()
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"proxy_budget": monitor.GlobalProxyBudget(),
+		"monitors":     statuses,
+	})
+}
+
+// ProxiesHandler handles GET /protected/admin/proxies, the health of the proxies Kick requests rotate over
+func ProxiesHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"proxies": monitor.GetProxyPoolStatus(fetcherFrom(c))})
+}
+
+// JobsHandler handles GET /protected/admin/jobs?stuck=true&include_finished=true, listing background job leases.
+// Stuck jobs lost their heartbeat (crashed instance) or have been running for longer than JOB_STUCK_AFTER.
+func JobsHandler(c echo.Context) error {
+	jobs, err := monitor.GetJobStatuses(c.QueryParam("include_finished") == "true")
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+	if c.QueryParam("stuck") == "true" {
+		stuck := make([]monitor.JobStatus, 0, len(jobs))
+		for _, job := range jobs {
+			if job.Stuck {
+				stuck = append(stuck, job)
+			}
+		}
+		jobs = stuck
+	}
+	return c.JSON(http.StatusOK, jobs)
+}
+
+// RequeueJobHandler handles POST /protected/admin/jobs/:jobID/requeue, restarting an unfinished job on this instance
+func RequeueJobHandler(c echo.Context) error {
+	jobID, err := uuid.Parse(c.Param("jobID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid job ID format")
+	}
+
+	lease, err := monitor.RequeueJob(jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "No unfinished job with this ID")
+		case errors.Is(err, monitor.ErrJobLeased):
+			return util.Problem(c, http.StatusConflict, util.ErrConflict, "The job was claimed by another instance")
+		case errors.Is(err, monitor.ErrDraining):
+			return util.Problem(c, http.StatusServiceUnavailable, util.ErrDraining, "This instance is draining and takes no new jobs")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to requeue job: %v", err))
+	}
+	log.Printf("audit: job %s (%s %s) requeued from %s", lease.ID.String(), lease.Kind, lease.JobKey, c.RealIP())
+	return c.JSON(http.StatusAccepted, lease)
+}
+
+// DrainHandler handles POST /protected/admin/drain, stopping this instance's monitors and new report jobs ahead of a
+// shutdown. The work already in flight carries on; poll GET /protected/admin/drain until safe_to_stop.
+func DrainHandler(c echo.Context) error {
+	alreadyDraining := monitor.IsDraining()
+	status := monitor.Drain()
+	if !alreadyDraining {
+		log.Printf("audit: drain requested from %s", c.RealIP())
+	}
+	return c.JSON(http.StatusAccepted, status)
+}
+
+// DrainStatusHandler handles GET /protected/admin/drain, telling whether the instance can be stopped without losing
+// data
+func DrainStatusHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, monitor.GetDrainStatus())
+}
+
+// ResyncChannelHandler handles POST /protected/admin/channels/:channelID/resync, rebuilding the channel's profile,
+// followers timeline and livestream list from its snapshots and reports. Other channels are left alone.
+func ResyncChannelHandler(c echo.Context) error {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	result, err := monitor.ResyncChannel(uint(channelID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, "Channel is not monitored")
+		case errors.Is(err, monitor.ErrNoChannelSnapshot):
+			return util.Problem(c, http.StatusConflict, util.ErrConflict, "The channel has not been fetched yet, there is nothing to rebuild from")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to resync channel: %v", err))
+	}
+	log.Printf("audit: channel %d resynced from %s", channelID, c.RealIP())
+	return c.JSON(http.StatusOK, result)
+}
+
+// StorageStatsHandler handles GET /protected/admin/storage
+func StorageStatsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	report, err := db.Storage(ctx)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch storage stats: %v", err))
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
 func AddChannelHandler(c echo.Context) error {
 	req := new(AddChannelRequest)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+
+	if !monitor.UsernameAllowed(req.Username) {
+		requester := "anonymous"
+		if claims, err := auth.CurrentUserClaims(c); err == nil {
+			requester = claims.Email
+		}
+		log.Printf("audit: rejected adding channel %q outside the monitor allow-list, requested by %s from %s", req.Username, requester, c.RealIP())
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, fmt.Sprintf("Channel %s is not on this instance's allow-list", req.Username))
 	}
 
 	var existingChannel models.MonitoredChannel
-	result := db.DB.Where("username = ?", req.Username).First(&existingChannel)
+	result := db.FromContext(c).Where("username = ?", req.Username).First(&existingChannel)
 
 	if result.Error == nil {
 		log.Printf("Channel %s already exists in DB (ID: %d).", req.Username, existingChannel.ChannelID)
 
 		if existingChannel.IsActive != req.IsActive {
-			if err := db.DB.Model(&existingChannel).Update("is_active", req.IsActive).Error; err != nil {
+			if err := authorizeChannel(c, &existingChannel, monitor.TeamRoleAdmin, fmt.Sprintf("setting is_active=%t", req.IsActive)); err != nil {
+				return err
+			}
+			changed, err := monitor.SetChannelActive(&existingChannel, req.IsActive)
+			if err != nil {
 				log.Printf("Failed to update is_active status for channel %s: %v", req.Username, err)
-				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update channel status"})
+				return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update channel status")
 			}
-			log.Printf("Updated is_active status for channel %s to %t", req.Username, req.IsActive)
-
-			if req.IsActive {
-				go monitor.StartMonitoringChannel(&existingChannel)
-
+			if !changed {
+				log.Printf("is_active status for channel %s was already changed to %t concurrently", req.Username, req.IsActive)
+			} else {
+				log.Printf("audit: channel %s (ID: %d) set to is_active=%t by %s from %s", req.Username, existingChannel.ChannelID, req.IsActive, requester(c), c.RealIP())
 			}
 		} else {
 			log.Printf("Channel %s already exists and is_active status is the same.", req.Username)
@@ -76,14 +273,14 @@ func AddChannelHandler(c echo.Context) error {
 		return c.JSON(http.StatusOK, existingChannel)
 	} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		log.Printf("Database error checking for existing channel %s: %v", req.Username, result.Error)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Database error checking channel"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Database error checking channel")
 	}
 
 	log.Printf("Channel %s not found in DB. Fetching data from API.", req.Username)
-	kickData, err := monitor.FetchChannelData(req.Username)
+	kickData, err := monitor.FetchChannelData(fetcherFrom(c), req.Username)
 	if err != nil {
 		log.Printf("Error fetching channel data for %s: %v", req.Username, err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch channel data"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to fetch channel data")
 	}
 
 	channel := models.MonitoredChannel{
@@ -92,20 +289,36 @@ func AddChannelHandler(c echo.Context) error {
 		Username:   req.Username,
 		IsActive:   req.IsActive,
 	}
+	if tenantID, err := auth.TenantID(c); err == nil {
+		channel.AddedBy = &tenantID
+	}
 
 	var potentialExistingChannel models.MonitoredChannel
-	if err := db.DB.First(&potentialExistingChannel, channel.ChannelID).Error; err == nil {
+	if err := db.FromContext(c).First(&potentialExistingChannel, channel.ChannelID).Error; err == nil && potentialExistingChannel.Username != req.Username {
+		// Same channel ID under another username: the channel was renamed since it was added
+		previous := potentialExistingChannel.Username
+		if _, err := monitor.RenameChannel(&potentialExistingChannel, req.Username); err != nil {
+			log.Printf("Failed to rename channel %s (ID: %d) to %s: %v", previous, channel.ChannelID, req.Username, err)
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to record channel rename")
+		}
+		log.Printf("Channel %s (ID: %d) was renamed to %s", previous, channel.ChannelID, req.Username)
+		potentialExistingChannel.Username = req.Username
+		if monitor.StopMonitoringChannel(channel.ChannelID) {
+			go monitor.StartMonitoringChannel(&potentialExistingChannel)
+		}
+		return c.JSON(http.StatusOK, potentialExistingChannel)
+	} else if err == nil {
 		log.Printf("Race condition detected: Channel %s (ID: %d) was added by another process.", req.Username, channel.ChannelID)
-		return c.JSON(http.StatusConflict, map[string]string{"message": "Channel was added concurrently"})
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "Channel was added concurrently")
 	} else if err != gorm.ErrRecordNotFound {
 		log.Printf("Database error checking for concurrent channel add for %s: %v", req.Username, err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Database error"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Database error")
 	}
 
-	result = db.DB.Create(&channel)
+	result = db.FromContext(c).Create(&channel)
 	if result.Error != nil {
 		log.Printf("Failed to add new channel %s to database: %v", req.Username, result.Error)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to add channel to database"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to add channel to database")
 	}
 
 	log.Printf("Added new channel %s with ID %d to database", channel.Username, channel.ChannelID)
@@ -121,28 +334,62 @@ func AddChannelHandler(c echo.Context) error {
 func ProcessLivestreamReportHandler(c echo.Context) error {
 	req := new(ProcessLivestreamReportRequest)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
 	}
 
 	if req.LivestreamID == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "livestream_id is required and must be a valid ID"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidLivestreamID, "livestream_id is required and must be a valid ID")
+	}
+
+	if monitor.IsReportInProgress(req.LivestreamID) {
+		return util.Problem(c, http.StatusConflict, util.ErrReportInProgress, fmt.Sprintf("A report for livestream %d is already being generated", req.LivestreamID))
 	}
 
 	log.Printf("Received request to process lr for livestream ID: %d", req.LivestreamID)
 
-	go func(livestreamID uint) {
-		err := monitor.GenerateLivestreamReport(livestreamID)
-		if err != nil {
-			log.Printf("Error generating livestream lr for %d: %v", livestreamID, err)
-		} else {
-			log.Printf("Successfully generated livestream lr for %d", livestreamID)
-		}
-	}(req.LivestreamID)
+	window := monitor.ReportWindow{Start: req.StartTime, End: req.EndTime}
+	if err := window.Validate(); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	jobID, err := monitor.StartLivestreamReport(req.LivestreamID, window)
+	if errors.Is(err, monitor.ErrReportInProgress) {
+		return util.Problem(c, http.StatusConflict, util.ErrReportInProgress, fmt.Sprintf("A report for livestream %d is already being generated", req.LivestreamID))
+	}
+	if errors.Is(err, monitor.ErrDraining) {
+		return util.Problem(c, http.StatusServiceUnavailable, util.ErrDraining, "This instance is draining and takes no new report jobs")
+	}
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to start report generation: %v", err))
+	}
 
-	return c.JSON(http.StatusAccepted, map[string]string{"status": "processing_started", "message": "Livestream lr generation initiated."})
+	response := map[string]string{
+		"status":       "processing_started",
+		"message":      "Livestream lr generation initiated.",
+		"job_id":       jobID.String(),
+		"progress_url": fmt.Sprintf("/api/protected/reports/jobs/%s/progress", jobID),
+	}
+	if !window.IsZero() {
+		response["windows_url"] = fmt.Sprintf("/api/protected/livestream/%d/windows", req.LivestreamID)
+	}
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// GetWindowReportsHandler handles GET /protected/livestream/:livestreamID/windows, the reports generated for parts of
+// the stream
+func GetWindowReportsHandler(c echo.Context) error {
+	livestreamID, err := strconv.ParseUint(c.Param("livestreamID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidLivestreamID, "Invalid livestream ID format")
+	}
+	reports, err := monitor.GetWindowReports(uint(livestreamID))
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch window reports: %v", err))
+	}
+	return c.JSON(http.StatusOK, map[string]any{"livestream_id": livestreamID, "windows": reports})
 }
 
-func getFullReport(query *gorm.DB) ([]monitor.FullLivestreamReportForProfile, error) {
+func getFullReport(reader, query *gorm.DB) ([]monitor.FullLivestreamReportForProfile, error) {
 	var livestreamReports []models.LivestreamReport
 	if err := query.Find(&livestreamReports).Error; err != nil {
 		return nil, fmt.Errorf("failed to find livestream reports: %w", err)
@@ -155,26 +402,76 @@ func getFullReport(query *gorm.DB) ([]monitor.FullLivestreamReportForProfile, er
 	fullReports := make([]monitor.FullLivestreamReportForProfile, len(livestreamReports))
 	for i, lr := range livestreamReports {
 		fullReports[i].LivestreamReportRestructured = monitor.LivestreamReportRestructured{
-			LivestreamID:          int(lr.LivestreamID),
-			Title:                 lr.Title,
-			ReportStartTime:       lr.ReportStartTime,
-			DurationMinutes:       lr.DurationMinutes,
-			AverageViewers:        lr.AverageViewers,
-			PeakViewers:           lr.PeakViewers,
-			LowestViewers:         lr.LowestViewers,
-			Engagement:            lr.Engagement,
-			TotalMessages:         lr.TotalMessages,
-			HoursWatched:          lr.HoursWatched,
-			UniqueChatters:        lr.UniqueChatters,
-			MessagesFromApps:      lr.MessagesFromApps,
-			ViewerCountsTimeline:  lr.ViewerCountsTimeline,
-			MessageCountsTimeline: lr.MessageCountsTimeline,
-			CreatedAt:             lr.CreatedAt,
+			LivestreamID:                  int(lr.LivestreamID),
+			Title:                         lr.Title,
+			Category:                      lr.Category,
+			ReportStartTime:               lr.ReportStartTime,
+			ReportEndTime:                 lr.ReportEndTime,
+			DurationMinutes:               lr.DurationMinutes,
+			AverageViewers:                lr.AverageViewers,
+			PeakViewers:                   lr.PeakViewers,
+			LowestViewers:                 lr.LowestViewers,
+			Engagement:                    lr.Engagement,
+			TotalMessages:                 lr.TotalMessages,
+			HoursWatched:                  lr.HoursWatched,
+			AverageConcurrentViewers:      lr.AverageConcurrentViewers,
+			ViewerTrackedSeconds:          lr.ViewerTrackedSeconds,
+			FollowersGained:               lr.FollowersGained,
+			FollowersPerHourWatched:       lr.FollowersPerHourWatched,
+			EngagementFormula:             lr.EngagementFormula,
+			EngagementChattersPerAverage:  lr.EngagementChattersPerAverage,
+			EngagementMessagesPerViewerHr: lr.EngagementMessagesPerViewerHr,
+			EngagementChattersPerPeak:     lr.EngagementChattersPerPeak,
+			EngagementQualityWeighted:     lr.EngagementQualityWeighted,
+			EngagementBotAdjusted:         lr.EngagementBotAdjusted,
+			LikelyBotChatters:             lr.LikelyBotChatters,
+			UniqueChatters:                lr.UniqueChatters,
+			MessagesFromApps:              lr.MessagesFromApps,
+			TopOnePercentShare:            lr.TopOnePercentShare,
+			TopTenPercentShare:            lr.TopTenPercentShare,
+			ChatGini:                      lr.ChatGini,
+			RawAverageViewers:             lr.RawAverageViewers,
+			RawPeakViewers:                lr.RawPeakViewers,
+			ViewerCountsTimeline:          lr.ViewerCountsTimeline,
+			RawViewerCountsTimeline:       lr.RawViewerCountsTimeline,
+			MessageCountsTimeline:         lr.MessageCountsTimeline,
+			Reactions:                     lr.Reactions,
+			ClipsCreated:                  lr.ClipsCreated,
+			QuestionStats:                 lr.QuestionStats,
+			CustomMetrics:                 lr.CustomMetrics,
+			ViewerBotAnalysis:             lr.ViewerBotAnalysis,
+			ViewerBotSuspected:            lr.ViewerBotSuspected,
+			ChatLanguages:                 lr.ChatLanguages,
+			Quality:                       lr.Quality,
+			Simulcast:                     lr.Simulcast,
+			SimulcastInfo:                 lr.SimulcastInfo,
+			ViewerMilestones:              lr.ViewerMilestones,
+			RaidSpikes:                    lr.RaidSpikes,
+			AudienceComposition:           lr.AudienceComposition,
+			ParentReportID:                lr.ParentReportID,
+			ChunkIndex:                    lr.ChunkIndex,
+			ChunkCount:                    lr.ChunkCount,
+			ChunkReportIDs:                lr.ChunkReportIDs,
+			Stale:                         lr.Stale,
+			StaleMessages:                 lr.StaleMessages,
+			Sampled:                       lr.Sampled,
+			SampleRate:                    lr.SampleRate,
+			PersistedMessages:             lr.PersistedMessages,
+			ExcludedChatters:              lr.ExcludedChatters,
+			ExcludedMessages:              lr.ExcludedMessages,
+			IngestionLagP50:               lr.IngestionLagP50,
+			IngestionLagP95:               lr.IngestionLagP95,
+			IngestionLagged:               lr.IngestionLagged,
+			WindowStart:                   lr.WindowStart,
+			WindowEnd:                     lr.WindowEnd,
+			VodURL:                        lr.VodURL,
+			VodSourceURL:                  lr.VodSourceURL,
+			CreatedAt:                     lr.CreatedAt,
 		}
 		// fmt.Println(i, lr)
 		if lr.SpamReportID != nil {
 			var spamReport models.SpamReport
-			if err := db.DB.Where("id = ?", lr.SpamReportID).First(&spamReport).Error; err != nil {
+			if err := reader.Where("id = ?", lr.SpamReportID).First(&spamReport).Error; err != nil {
 				log.Printf("Warning: Failed to fetch spam report  %s for livestream id %s: %v", lr.SpamReportID.String(), lr.ID.String(), err)
 
 			} else {
@@ -186,6 +483,10 @@ func getFullReport(query *gorm.DB) ([]monitor.FullLivestreamReportForProfile, er
 					ExactDuplicateBursts:       spamReport.ExactDuplicateBursts,
 					SimilarMessageBursts:       spamReport.SimilarMessageBursts,
 					SuspiciousChatters:         spamReport.SuspiciousChatters,
+					CrossUserCopypasta:         spamReport.CrossUserCopypasta,
+					AltClusters:                spamReport.AltClusters,
+					UnicodeAbuse:               spamReport.UnicodeAbuse,
+					EmoteBursts:                spamReport.EmoteBursts,
 				}
 			}
 		}
@@ -211,22 +512,22 @@ func GetLatestLivestreams(c echo.Context) error {
 		WHERE rn = 1
 		ORDER BY livestream_id, created_at DESC;
 	`
-	err := db.DB.Raw(windowSQL).Scan(&latestLivestreams).Error
+	err := db.FromContext(c).Raw(windowSQL).Scan(&latestLivestreams).Error
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to get latest livestreams: %v", err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to get latest livestreams: %v", err))
 	}
 
 	/*
-		subQuery := db.DB.Model(&LivestreamData{}).
+		subQuery := db.FromContext(c).Model(&LivestreamData{}).
 			Select("livestream_id, MAX(created_at) as created_at").
 			Group("livestream_id")
 
-		err = db.DB.Table("livestream_data").
+		err = db.FromContext(c).Table("livestream_data").
 			Joins("INNER JOIN (?) as t2 ON livestream_data.livestream_id = t2.livestream_id AND livestream_data.created_at = t2.created_at", subQuery).
 			Find(&latestLivestreams).Error
 
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to get latest livestreams: %v", err)})
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to get latest livestreams: %v", err))
 		}
 	*/
 
@@ -238,18 +539,18 @@ func GetLatestLivestreamsByUsername(c echo.Context) error {
 	username := c.Param("username") // Get username from URL path
 
 	if username == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "username cannot be empty"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Username cannot be empty")
 	}
 
 	// Step 1: Query MonitoredChannel to get ChannelID from Username
 	var monitoredChannel models.MonitoredChannel
-	result := db.DB.Where("username = ?", username).First(&monitoredChannel)
+	result := db.FromContext(c).Where("username = ?", monitor.ResolveUsername(username)).First(&monitoredChannel)
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("channel with username '%s' not found", username)})
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, fmt.Sprintf("Channel with username '%s' not found", username))
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to query channel by username: %v", result.Error)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to query channel by username: %v", result.Error))
 	}
 
 	channelID := monitoredChannel.ChannelID
@@ -272,36 +573,49 @@ func GetLatestLivestreamsByUsername(c echo.Context) error {
 		WHERE rn = 1
 		ORDER BY livestream_id, created_at DESC;
 	`
-	err := db.DB.Raw(windowSQL, channelID).Scan(&latestLivestreams).Error
+	err := db.FromContext(c).Raw(windowSQL, channelID).Scan(&latestLivestreams).Error
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to get latest livestreams for channel %d: %v", channelID, err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to get latest livestreams for channel %d: %v", channelID, err))
 	}
 
 	if len(latestLivestreams) == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"message": fmt.Sprintf("no livestream data found for channel with username '%s'", username)})
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, fmt.Sprintf("no livestream data found for channel with username '%s'", username))
 	}
 
 	return c.JSON(http.StatusOK, latestLivestreams)
 }
 
+// writeReports responds with reports as JSON, or as a Markdown summary for ?format=markdown
+func writeReports(c echo.Context, reports []monitor.FullLivestreamReportForProfile, payload any) error {
+	switch c.QueryParam("format") {
+	case "", "json":
+		return jsonWithFields(c, http.StatusOK, payload, publicCacheControl(CacheReports))
+	case "markdown", "md":
+		setPublicCacheControl(c, CacheReports)
+		return c.Blob(http.StatusOK, "text/markdown; charset=utf-8", []byte(monitor.RenderReportsMarkdown(reports)))
+	default:
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "format must be json or markdown")
+	}
+}
+
 // GetReportByUUIDHandler now takes echo.Context
 func GetReportByUUIDHandler(c echo.Context) error {
 	reportUUIDStr := c.Param("reportUUID") // Use c.Param for path variables
 	reportUUID, err := uuid.Parse(reportUUIDStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid lr UUID format"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidReportID, "Invalid lr UUID format")
 	}
 
-	fullReports, err := getFullReport(db.DB.Where("id = ?", reportUUID))
+	fullReports, err := getFullReport(db.ReaderFromContext(c), db.ReaderFromContext(c).Where("id = ?", reportUUID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to fetch lr: %v", err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch lr: %v", err))
 	}
 
 	if len(fullReports) == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"message": "Report not found"})
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "Report not found")
 	}
 
-	return c.JSON(http.StatusOK, fullReports[0])
+	return writeReports(c, fullReports, fullReports[0])
 }
 
 // GetReportsByChannelIDHandler handles GET /channels/{channel_id}/reports
@@ -309,15 +623,26 @@ func GetReportsByChannelIDHandler(c echo.Context) error {
 	channelIDStr := c.Param("channelID") // Use c.Param for path variables
 	channelID, err := strconv.ParseUint(channelIDStr, 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid channel ID format"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	since, err := parseSince(c)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	// Chunks of long streams are reached through their parent report
+	query := db.ReaderFromContext(c).Where("channel_id = ? AND parent_report_id IS NULL", channelID).Order("report_start_time DESC")
+	if since != nil {
+		query = query.Where("created_at > ?", *since)
 	}
 
-	fullReports, err := getFullReport(db.DB.Where("channel_id = ?", channelID).Order("report_start_time DESC"))
+	fullReports, err := getFullReport(db.ReaderFromContext(c), query)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to fetch reports: %v", err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch reports: %v", err))
 	}
 
-	return c.JSON(http.StatusOK, fullReports)
+	return writeReports(c, fullReports, fullReports)
 }
 
 // GetReportsByLivestreamIDHandler handles GET /livestream/id
@@ -325,21 +650,31 @@ func GetReportsByLivestreamIDHandler(c echo.Context) error {
 	livestreamIDStr := c.Param("livestreamID") // Use c.Param for path variables
 	livestreamID, err := strconv.ParseUint(livestreamIDStr, 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid livestream ID format"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidLivestreamID, "Invalid livestream ID format")
+	}
+
+	since, err := parseSince(c)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	query := db.ReaderFromContext(c).Where("livestream_id = ?", livestreamID).Order("report_start_time DESC")
+	if since != nil {
+		query = query.Where("created_at > ?", *since)
 	}
 
-	fullReports, err := getFullReport(db.DB.Where("livestream_id = ?", livestreamID).Order("report_start_time DESC"))
+	fullReports, err := getFullReport(db.ReaderFromContext(c), query)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to fetch reports: %v", err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch reports: %v", err))
 	}
 
-	return c.JSON(http.StatusOK, fullReports)
+	return writeReports(c, fullReports, fullReports)
 }
 
 func GetMonitoredChannelsHandler(c echo.Context) error {
 	var channels []models.MonitoredChannel
-	if err := db.DB.Order("username ASC").Find(&channels).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to fetch channels: %v", err)})
+	if err := db.FromContext(c).Order("username ASC").Find(&channels).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch channels: %v", err))
 	}
 
 	return c.JSON(http.StatusOK, channels)
@@ -349,38 +684,201 @@ func GetChannelInfoHandler(c echo.Context) error {
 	channelIDStr := c.Param("channelID") // Use c.Param for path variables
 	channelID, err := strconv.ParseUint(channelIDStr, 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid channel ID format"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
 	}
 
 	var latestChannelData models.ChannelData
-	if err := db.DB.Where("channel_id = ?", channelID).
+	if err := db.FromContext(c).Where("channel_id = ?", channelID).
 		Order("created_at DESC").
 		First(&latestChannelData).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.JSON(http.StatusNotFound, map[string]string{"message": "Channel info not found"})
+			return util.Problem(c, http.StatusNotFound, util.ErrChannelNotFound, "Channel info not found")
 		} else {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to fetch channel info: %v", err)})
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch channel info: %v", err))
 		}
 	}
 
 	return c.JSON(http.StatusOK, latestChannelData)
 }
 
+// GetStreamerProfileHandler handles GET /profile/:username?since=&as_of=. With as_of the profile is reconstructed
+// as it was at that time (a bare date means the end of that day, UTC).
 func GetStreamerProfileHandler(c echo.Context) error {
 	username := c.Param("username")
 
 	if username == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Username is required in the path"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Username is required in the path")
+	}
+
+	since, err := parseSince(c)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	asOf, err := parseAsOf(c.QueryParam("as_of"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
 	}
 
-	apiProfile, err := monitor.GetStreamerProfile(username)
+	var apiProfile monitor.StreamerProfileAPI
+	if asOf != nil {
+		apiProfile, err = monitor.GetStreamerProfileAsOf(username, *asOf)
+	} else {
+		apiProfile, err = monitor.GetStreamerProfile(username)
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.JSON(http.StatusNotFound, map[string]string{"message": fmt.Sprintf("Streamer profile not found for username '%s'", username)})
+			return util.Problem(c, http.StatusNotFound, util.ErrProfileNotFound, fmt.Sprintf("Streamer profile not found for username '%s'", username))
+		}
+		if errors.Is(err, monitor.ErrNoProfileHistory) {
+			return util.Problem(c, http.StatusNotFound, util.ErrProfileNotFound, fmt.Sprintf("No history of '%s' as of %s", username, asOf.Format(time.RFC3339)))
 		}
 		log.Printf("Error fetcheing streamer profile for username '%s': %v", username, err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("Failed to build streamer profile: %v", err)})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build streamer profile: %v", err))
+	}
+
+	// Delta response: only reports created after ?since=
+	if since != nil {
+		recent := make([]monitor.FullLivestreamReportForProfile, 0, len(apiProfile.Livestreams))
+		for _, report := range apiProfile.Livestreams {
+			if report.CreatedAt.After(*since) {
+				recent = append(recent, report)
+			}
+		}
+		apiProfile.Livestreams = recent
+	}
+
+	return jsonWithFields(c, http.StatusOK, apiProfile, publicCacheControl(CacheProfile))
+}
+
+// parseAsOf reads an as_of value, either an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseAsOf(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	day, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid as_of value '%s': expected YYYY-MM-DD or an RFC 3339 timestamp", raw)
+	}
+	endOfDay := day.Add(24*time.Hour - time.Nanosecond)
+	return &endOfDay, nil
+}
+
+// ReportSummary is a report without timelines, used for "last stream stats" views
+type ReportSummary struct {
+	ID                       uuid.UUID `json:"id"`
+	ChannelID                uint      `json:"channel_id"`
+	Username                 string    `json:"username"`
+	LivestreamID             uint      `json:"livestream_id"`
+	Title                    string    `json:"title"`
+	ReportStartTime          time.Time `json:"report_start_time"`
+	ReportEndTime            time.Time `json:"report_end_time"`
+	DurationMinutes          int       `json:"duration_minutes"`
+	AverageViewers           int       `json:"average_viewers"`
+	PeakViewers              int       `json:"peak_viewers"`
+	LowestViewers            int       `json:"lowest_viewers"`
+	Engagement               float64   `json:"engagement"`
+	HoursWatched             float64   `json:"hours_watched"`
+	AverageConcurrentViewers float64   `json:"average_concurrent_viewers"`
+	TotalMessages            int       `json:"total_messages"`
+	UniqueChatters           int       `json:"unique_chatters"`
+	CreatedAt                time.Time `json:"created_at"`
+}
+
+// latestReportSummaries returns the newest report summary for each of the given channels
+func latestReportSummaries(reader *gorm.DB, channelIDs []uint64) ([]ReportSummary, error) {
+	summaries := []ReportSummary{}
+	err := reader.Raw(`
+		SELECT DISTINCT ON (channel_id)
+			id, channel_id, username, livestream_id, title, report_start_time, report_end_time,
+			duration_minutes, average_viewers, peak_viewers, lowest_viewers, engagement,
+			hours_watched, average_concurrent_viewers, total_messages, unique_chatters, created_at
+		FROM livestream_reports
+		WHERE channel_id IN ? AND parent_report_id IS NULL
+		ORDER BY channel_id, report_start_time DESC, created_at DESC
+	`, channelIDs).Scan(&summaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest reports: %w", err)
+	}
+	return summaries, nil
+}
+
+// GetLatestReportByChannelIDHandler handles GET /channels/:channelID/reports/latest
+func GetLatestReportByChannelIDHandler(c echo.Context) error {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	summaries, err := latestReportSummaries(db.ReaderFromContext(c), []uint64{channelID})
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+
+	if len(summaries) == 0 {
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "No reports found for channel")
+	}
+
+	return jsonWithFields(c, http.StatusOK, summaries[0], publicCacheControl(CacheLatestReports))
+}
+
+// GetLiveReportHandler handles GET /channels/:channelID/reports/live, the report so far of the stream the channel
+// is on, kept up to date as chat and viewer counts come in. In a cluster the instances not monitoring the channel
+// serve the snapshot its monitor persisted last, up to LIVE_REPORT_SNAPSHOT_INTERVAL behind.
+func GetLiveReportHandler(c echo.Context) error {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	report, ok := monitor.GetLiveReport(uint(channelID))
+	if !ok && cluster.Enabled() {
+		if report, ok, err = monitor.GetLiveReportSnapshot(uint(channelID)); err != nil {
+			log.Printf("Error fetching live report snapshot of channel %d: %v", channelID, err)
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to fetch live report")
+		}
+	}
+	if !ok {
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "No live report for channel")
+	}
+
+	return jsonWithFields(c, http.StatusOK, report, publicCacheControl(CacheLiveReport))
+}
+
+// GetLatestReportsHandler handles GET /reports/latest?channel_ids=1,2,3
+func GetLatestReportsHandler(c echo.Context) error {
+	param := c.QueryParam("channel_ids")
+	if param == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "channel_ids query parameter is required")
+	}
+
+	var channelIDs []uint64
+	for _, part := range strings.Split(param, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, fmt.Sprintf("Invalid channel ID '%s'", part))
+		}
+		channelIDs = append(channelIDs, id)
+	}
+
+	if len(channelIDs) == 0 {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "channel_ids must contain at least one ID")
+	}
+	if len(channelIDs) > 100 {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "channel_ids may contain at most 100 IDs")
+	}
+
+	summaries, err := latestReportSummaries(db.ReaderFromContext(c), channelIDs)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, apiProfile)
+	return jsonWithFields(c, http.StatusOK, summaries, publicCacheControl(CacheLatestReports))
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetMobileProfileHandler handles GET /mobile/v1/profile/:username, the trimmed profile for mobile clients
+func GetMobileProfileHandler(c echo.Context) error {
+	username := c.Param("username")
+	if username == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Username is required in the path")
+	}
+
+	profile, err := monitor.BuildMobileProfile(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrProfileNotFound, fmt.Sprintf("Profile for '%s' not found", username))
+		}
+		log.Printf("Error building mobile profile for '%s': %v", username, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to build profile")
+	}
+	return jsonWithFields(c, http.StatusOK, profile, publicCacheControl(CacheProfile))
+}
+
+// GetMobileReportHandler handles GET /mobile/v1/livestream/:livestreamID, the trimmed latest report of a livestream
+func GetMobileReportHandler(c echo.Context) error {
+	livestreamID, err := strconv.ParseUint(c.Param("livestreamID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidLivestreamID, "Invalid livestream ID format")
+	}
+
+	report, err := monitor.BuildMobileReport(uint(livestreamID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, fmt.Sprintf("No report for livestream %d", livestreamID))
+		}
+		log.Printf("Error building mobile report for livestream %d: %v", livestreamID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to build report")
+	}
+	return jsonWithFields(c, http.StatusOK, report, publicCacheControl(CacheReports))
+}
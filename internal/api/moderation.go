@@ -0,0 +1,272 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+type FlagUserRequest struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+// FlagUserHandler handles POST /protected/channels/:channelID/flag_user
+func FlagUserHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(FlagUserRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if req.Username == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "username is required")
+	}
+
+	flaggedBy := ""
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		flaggedBy = claims.Email
+	}
+
+	flagged, err := monitor.FlagChatter(channel.ChannelID, req.Username, req.Reason, flaggedBy)
+	if err != nil {
+		log.Printf("Error flagging chatter %s on channel %d: %v", req.Username, channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to flag user")
+	}
+
+	log.Printf("Chatter %s flagged on channel %s by %s", flagged.SenderUsername, channel.Username, flaggedBy)
+	return c.JSON(http.StatusCreated, flagged)
+}
+
+// UnflagUserHandler handles DELETE /protected/channels/:channelID/flag_user/:username
+func UnflagUserHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := monitor.UnflagChatter(channel.ChannelID, c.Param("username")); err != nil {
+		log.Printf("Error unflagging chatter %s on channel %d: %v", c.Param("username"), channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to unflag user")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetFlaggedUsersHandler handles GET /protected/channels/:channelID/flagged_users
+func GetFlaggedUsersHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	flagged := []models.FlaggedChatter{}
+	if err := db.FromContext(c).Where("channel_id = ?", channel.ChannelID).Order("created_at DESC").Find(&flagged).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch flagged users: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, flagged)
+}
+
+// GetFlaggedMessagesHandler handles GET /protected/channels/:channelID/flagged_messages?username=&since=&limit=
+func GetFlaggedMessagesHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	since, err := parseSince(c)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	limit := 200
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "limit must be between 1 and 1000")
+		}
+		limit = parsed
+	}
+
+	query := db.FromContext(c).Where("chatroom_id = ? AND flagged = ?", channel.ChatroomID, true)
+	if username := c.QueryParam("username"); username != "" {
+		query = query.Where("LOWER(sender_username) = LOWER(?)", username)
+	}
+	if since != nil {
+		query = query.Where("message_send_time > ?", *since)
+	}
+
+	messages := []models.ChatMessage{}
+	if err := query.Order("message_send_time DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch flagged messages: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
+
+// StreamChannelEventsHandler handles GET /protected/channels/:channelID/events as a Server-Sent Events stream
+func StreamChannelEventsHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := monitor.SubscribeEvents(channel.ChannelID)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error marshalling live event for channel %d: %v", channel.ChannelID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// channelFromParam loads the monitored channel referenced by the :channelID path parameter
+func channelFromParam(c echo.Context) (*models.MonitoredChannel, error) {
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return nil, util.NewProblem(http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	var channel models.MonitoredChannel
+	if err := db.FromContext(c).First(&channel, channelID).Error; err != nil {
+		return nil, util.NewProblem(http.StatusNotFound, util.ErrChannelNotFound, "Channel not found")
+	}
+	return &channel, nil
+}
+
+// GetSuspiciousChattersHandler handles GET /protected/channels/:channelID/suspicious_chatters?livestream_id=&min_score=
+// returning the ranked suspicious chatters of the latest (or given) livestream report, with score explanations
+func GetSuspiciousChattersHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	query := db.FromContext(c).Where("channel_id = ? AND parent_report_id IS NULL AND spam_report_id IS NOT NULL", channel.ChannelID)
+	if raw := c.QueryParam("livestream_id"); raw != "" {
+		livestreamID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrInvalidLivestreamID, "Invalid livestream_id")
+		}
+		query = query.Where("livestream_id = ?", livestreamID)
+	}
+
+	minScore := 0.0
+	if raw := c.QueryParam("min_score"); raw != "" {
+		minScore, err = strconv.ParseFloat(raw, 64)
+		if err != nil || minScore < 0 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "min_score must be a non-negative number")
+		}
+	}
+
+	var report models.LivestreamReport
+	if err := query.Order("report_start_time DESC").First(&report).Error; err != nil {
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "No report found for this channel")
+	}
+
+	var spamReport models.SpamReport
+	if err := db.FromContext(c).Where("id = ?", report.SpamReportID).First(&spamReport).Error; err != nil {
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "Spam report not found")
+	}
+
+	var chatters []monitor.SuspiciousChatterReport
+	if err := json.Unmarshal(spamReport.SuspiciousChatters, &chatters); err != nil {
+		log.Printf("Error unmarshalling suspicious chatters of spam report %s: %v", spamReport.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to read suspicious chatters")
+	}
+
+	ranked := make([]monitor.SuspiciousChatterReport, 0, len(chatters))
+	for _, chatter := range chatters {
+		if chatter.Score >= minScore {
+			ranked = append(ranked, chatter)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"report_id":           report.ID,
+		"livestream_id":       report.LivestreamID,
+		"weights":             monitor.SuspicionWeights,
+		"suspicious_chatters": ranked,
+	})
+}
+
+// GetChatterEvidenceHandler handles GET /protected/channels/:channelID/chatters/:username/evidence?from=&to=&format=
+// returning the evidence bundle about a chatter as JSON, or as Markdown for format=markdown
+func GetChatterEvidenceHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	username := strings.TrimSpace(c.Param("username"))
+	if username == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "username is required")
+	}
+	from, to, err := timeRangeParams(c)
+	if err != nil {
+		return err
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "markdown" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "format must be json or markdown")
+	}
+
+	evidence, err := monitor.BuildModerationEvidence(*channel, username, from, to)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to assemble evidence: %v", err))
+	}
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		log.Printf("audit: %s exported moderation evidence about %s in channel %d", claims.Email, evidence.Username, channel.ChannelID)
+	}
+
+	filename := fmt.Sprintf("evidence-%s-%s-%s", channel.Username, evidence.Username, evidence.GeneratedAt.Format("20060102"))
+	if format == "markdown" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.md"`, filename))
+		return c.Blob(http.StatusOK, "text/markdown; charset=utf-8", []byte(monitor.RenderEvidenceMarkdown(evidence)))
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+	return c.JSON(http.StatusOK, evidence)
+}
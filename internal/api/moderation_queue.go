@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ReviewModerationItemRequest is the optional body of the confirm and dismiss endpoints
+type ReviewModerationItemRequest struct {
+	Note string `json:"note"`
+}
+
+// GetModerationQueueHandler handles GET /protected/moderation/queue?status=&channel_id=&types=&before=&limit=, the
+// spam findings awaiting review (status=pending by default), newest first
+func GetModerationQueueHandler(c echo.Context) error {
+	q := monitor.ModerationQueueQuery{Status: monitor.ModerationPending, Limit: 50}
+	switch raw := c.QueryParam("status"); {
+	case raw == "all":
+		q.Status = ""
+	case raw != "" && !slices.Contains(monitor.ModerationStatuses, raw):
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed,
+			fmt.Sprintf("unknown status '%s', expected all or one of %s", raw, strings.Join(monitor.ModerationStatuses, ", ")))
+	case raw != "":
+		q.Status = raw
+	}
+	incidents, err := spamIncidentQuery(c, 50, 500)
+	if err != nil {
+		return err
+	}
+	q.ChannelID, q.Types, q.Limit = incidents.ChannelID, incidents.Types, incidents.Limit
+	if raw := c.QueryParam("before"); raw != "" {
+		if q.Before, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("invalid before value '%s': expected RFC 3339 timestamp", raw))
+		}
+	}
+
+	page, err := monitor.ListModerationQueue(q)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to list moderation queue: %v", err))
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// ConfirmModerationItemHandler handles POST /protected/moderation/items/:itemID/confirm. Confirmed chatters are
+// flagged in the reports of every channel, so reviews are up to operators, the user who added the item's channel
+// and admins of its teams.
+func ConfirmModerationItemHandler(c echo.Context) error {
+	return reviewModerationItem(c, monitor.ModerationConfirmed)
+}
+
+// DismissModerationItemHandler handles POST /protected/moderation/items/:itemID/dismiss, allowed to the same users as
+// confirming, as dismissals count against the detectors' precision
+func DismissModerationItemHandler(c echo.Context) error {
+	return reviewModerationItem(c, monitor.ModerationDismissed)
+}
+
+func reviewModerationItem(c echo.Context, status string) error {
+	itemID, err := uuid.Parse(c.Param("itemID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid item ID")
+	}
+	var row models.ModerationItem
+	if err := db.FromContext(c).Select("id", "channel_id").First(&row, "id = ?", itemID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Moderation item not found")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to fetch moderation item")
+	}
+	channel := models.MonitoredChannel{ChannelID: row.ChannelID} // Still reviewable by operators once the channel is removed
+	if err := db.FromContext(c).First(&channel, row.ChannelID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to fetch channel of moderation item")
+	}
+	if err := authorizeChannel(c, &channel, monitor.TeamRoleAdmin, "reviewing moderation item "+itemID.String()); err != nil {
+		return err
+	}
+
+	req := new(ReviewModerationItemRequest)
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(req); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+		}
+	}
+
+	reviewer := ""
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		reviewer = claims.Email
+	}
+
+	item, err := monitor.ReviewModerationItem(itemID, status, reviewer, req.Note)
+	switch {
+	case errors.Is(err, monitor.ErrModerationItemNotFound):
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Moderation item not found")
+	case errors.Is(err, monitor.ErrModerationReviewed):
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "Moderation item was already reviewed")
+	case err != nil:
+		log.Printf("Error reviewing moderation item %s: %v", itemID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to review moderation item")
+	}
+
+	log.Printf("audit: moderation item %s (%s %s) %s by %s from %s", item.ID, item.Type, item.Username, status, reviewer, c.RealIP())
+	return c.JSON(http.StatusOK, item)
+}
+
+// GetModerationPrecisionHandler handles GET /protected/moderation/precision?channel_id=&from=&to=, the share of each
+// detector's reviewed findings analysts confirmed
+func GetModerationPrecisionHandler(c echo.Context) error {
+	var q monitor.ModerationPrecisionQuery
+	if raw := c.QueryParam("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel_id")
+		}
+		q.ChannelID = uint(channelID)
+	}
+	var err error
+	if q.From, q.To, err = timeRangeParams(c); err != nil {
+		return err
+	}
+
+	report, err := monitor.BuildModerationPrecision(q)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to compute moderation precision: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, report)
+}
+
+// GetConfirmedOffendersHandler handles GET /protected/moderation/confirmed_offenders?channel_id=&limit=, the chatters
+// analysts confirmed as spammers. Reports of every channel flag them as confirmed_offender.
+func GetConfirmedOffendersHandler(c echo.Context) error {
+	q, err := spamIncidentQuery(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+	offenders, err := monitor.ListConfirmedOffenders(q.ChannelID, q.Limit)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to list confirmed offenders: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, offenders)
+}
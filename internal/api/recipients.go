@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+type AddReportRecipientRequest struct {
+	Email        string `json:"email"`
+	Reports      *bool  `json:"reports"`       // defaults to true
+	WeeklyDigest *bool  `json:"weekly_digest"` // defaults to true
+}
+
+// GetReportRecipientsHandler handles GET /protected/channels/:channelID/recipients. The addresses are personal
+// data, only operators, the user who added the channel and members of its teams see them.
+func GetReportRecipientsHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleMember, "listing report recipients"); err != nil {
+		return err
+	}
+
+	recipients := []models.ReportRecipient{}
+	if err := db.FromContext(c).Where("channel_id = ?", channel.ChannelID).Order("created_at ASC").Find(&recipients).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch recipients: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, recipients)
+}
+
+// AddReportRecipientHandler handles POST /protected/channels/:channelID/recipients.
+// Adding an existing address updates its subscriptions. Operators, the user who added the channel and admins of its
+// teams may add recipients, so the instance's mail account doesn't write to whoever anyone names.
+func AddReportRecipientHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "adding a report recipient"); err != nil {
+		return err
+	}
+
+	req := new(AddReportRecipientRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+
+	address, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "email must be a valid email address")
+	}
+
+	recipient := models.ReportRecipient{
+		ChannelID:    channel.ChannelID,
+		Email:        strings.ToLower(address.Address),
+		Reports:      req.Reports == nil || *req.Reports,
+		WeeklyDigest: req.WeeklyDigest == nil || *req.WeeklyDigest,
+	}
+	if !recipient.Reports && !recipient.WeeklyDigest {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "at least one of reports or weekly_digest must be enabled")
+	}
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		recipient.CreatedBy = claims.Email
+	}
+
+	err = db.FromContext(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reports", "weekly_digest"}),
+	}).Create(&recipient).Error
+	if err != nil {
+		log.Printf("Error saving report recipient %s for channel %d: %v", recipient.Email, channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to save recipient")
+	}
+
+	if err := db.FromContext(c).Where("channel_id = ? AND email = ?", recipient.ChannelID, recipient.Email).First(&recipient).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to load recipient")
+	}
+
+	return c.JSON(http.StatusCreated, recipient)
+}
+
+// DeleteReportRecipientHandler handles DELETE /protected/channels/:channelID/recipients/:recipientID, allowed to
+// the same users as adding one
+func DeleteReportRecipientHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "removing a report recipient"); err != nil {
+		return err
+	}
+
+	recipientID, err := strconv.ParseUint(c.Param("recipientID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid recipient ID format")
+	}
+
+	result := db.FromContext(c).Where("id = ? AND channel_id = ?", recipientID, channel.ChannelID).Delete(&models.ReportRecipient{})
+	if result.Error != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to delete recipient")
+	}
+	if result.RowsAffected == 0 {
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Recipient not found")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PreviewDigestHandler handles GET /protected/channels/:channelID/digest and returns the data of the
+// weekly digest ending now, without sending it.
+func PreviewDigestHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now().UTC()
+	digest, err := monitor.BuildDigest(*channel, end.Add(-7*24*time.Hour), end)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build digest: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, digest)
+}
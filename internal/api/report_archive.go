@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/quota"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ExportReportArchiveHandler handles GET /protected/reports/:reportID/archive, the report with its chunks, spam
+// reports and spam incidents as a portable archive another instance imports. Its rows count against the tenant's
+// export_rows quota.
+func ExportReportArchiveHandler(c echo.Context) error {
+	reportID, err := uuid.Parse(c.Param("reportID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidReportID, "Invalid report ID")
+	}
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return util.Problem(c, http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	archive, err := monitor.ExportReportArchive(c.Request().Context(), reportID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return util.Problem(c, http.StatusNotFound, util.ErrReportNotFound, "Report not found")
+	case errors.Is(err, monitor.ErrReportArchiveChunk):
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Report is a chunk, export its parent report")
+	case err != nil:
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to export report: %v", err))
+	}
+
+	rows := len(archive.Reports) + len(archive.SpamReports) + len(archive.SpamIncidents)
+	usage, ok, err := quota.Reserve(db.FromContext(c), tenantID, quota.MetricExportRows, rows)
+	if err != nil {
+		log.Printf("Error checking quota %s for tenant %s: %v", quota.MetricExportRows, tenantID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+	}
+	if !ok {
+		return quota.Exceeded(c, usage)
+	}
+
+	c.Response().Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s-%d-report.json"`, archive.Username, archive.LivestreamID))
+	return c.JSON(http.StatusOK, archive)
+}
+
+// ImportReportArchiveHandler handles POST /protected/reports/import?replace=true, storing an exported archive as the
+// report of its livestream. Without replace a livestream that already has a report is a conflict. Imported reports
+// are served publicly like generated ones, so only operators may import.
+func ImportReportArchiveHandler(c echo.Context) error {
+	var archive monitor.ReportArchive
+	if err := c.Bind(&archive); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	replace := c.QueryParam("replace") == "true"
+
+	result, err := monitor.ImportReportArchive(c.Request().Context(), archive, replace)
+	switch {
+	case errors.Is(err, monitor.ErrInvalidReportArchive):
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	case errors.Is(err, monitor.ErrReportArchiveExists):
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "The livestream already has a report, import with replace=true to overwrite it")
+	case err != nil:
+		log.Printf("Error importing report %s: %v", archive.ReportID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to import report")
+	}
+
+	importer := ""
+	if claims, err := auth.CurrentUserClaims(c); err == nil {
+		importer = claims.Email
+	}
+	log.Printf("audit: report %s of %s livestream %d imported (replaced: %t) by %s from %s",
+		result.ReportID, archive.Username, result.LivestreamID, result.Replaced, importer, c.RealIP())
+	return c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// reportProgressPollInterval is how often the lease of a report job running on another instance is checked
+const reportProgressPollInterval = 2 * time.Second
+
+// StreamReportProgressHandler handles GET /protected/reports/jobs/:jobID/progress as a Server-Sent Events stream of
+// progress events, ending with the done or failed event. Jobs running on another instance only report their
+// lease status.
+func StreamReportProgressHandler(c echo.Context) error {
+	jobID, err := uuid.Parse(c.Param("jobID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid job ID format")
+	}
+
+	current, updates, unsubscribe, local := monitor.SubscribeReportProgress(jobID)
+	defer unsubscribe()
+
+	var poll <-chan time.Time
+	if !local {
+		current, err = monitor.GetReportJobProgress(jobID)
+		if errors.Is(err, monitor.ErrReportJobNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "No report job with this ID")
+		}
+		if err != nil {
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch report job: %v", err))
+		}
+		ticker := time.NewTicker(reportProgressPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	res.WriteHeader(http.StatusOK)
+
+	last := current
+	send := func(progress monitor.ReportProgress) bool {
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", progress.Phase, data); err != nil {
+			return false
+		}
+		res.Flush()
+		last = progress
+		return true
+	}
+	if !send(current) || current.Finished() {
+		return nil
+	}
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case progress, ok := <-updates:
+			if !ok {
+				// The job finished, the final update may have been dropped for a slow client
+				if final, _, _, _ := monitor.SubscribeReportProgress(jobID); final.Finished() && final.Phase != last.Phase {
+					send(final)
+				}
+				return nil
+			}
+			if !send(progress) {
+				return nil
+			}
+		case <-poll:
+			progress, err := monitor.GetReportJobProgress(jobID)
+			if err != nil {
+				continue
+			}
+			if progress.Phase != last.Phase || !progress.UpdatedAt.Equal(last.UpdatedAt) {
+				if !send(progress) {
+					return nil
+				}
+			}
+			if progress.Finished() {
+				return nil
+			}
+		}
+	}
+}
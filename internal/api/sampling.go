@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UpdateChannelSamplingRequest struct {
+	SampleRate int `json:"sample_rate"` // persist 1 in sample_rate messages, 1 persists all of them
+}
+
+type ChannelSamplingResponse struct {
+	ChannelID         uint `json:"channel_id"`
+	SampleRate        int  `json:"sample_rate"`           // configured for the channel
+	EffectiveRate     int  `json:"effective_sample_rate"` // in use right now, including automatic sampling
+	ViewerThreshold   int  `json:"auto_viewer_threshold,omitempty"`
+	AutomaticRate     int  `json:"auto_sample_rate,omitempty"`
+	AutomaticSampling bool `json:"auto_sampling"`
+}
+
+func channelSamplingResponse(channelID uint, sampleRate int) ChannelSamplingResponse {
+	response := ChannelSamplingResponse{
+		ChannelID:         channelID,
+		SampleRate:        sampleRate,
+		EffectiveRate:     monitor.EffectiveSampleRate(channelID),
+		AutomaticSampling: monitor.SamplingViewerThreshold > 0,
+	}
+	if response.AutomaticSampling {
+		response.ViewerThreshold = monitor.SamplingViewerThreshold
+		response.AutomaticRate = monitor.SamplingAutoRate
+	}
+	return response
+}
+
+// GetChannelSamplingHandler handles GET /protected/channels/:channelID/sampling
+func GetChannelSamplingHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, channelSamplingResponse(channel.ChannelID, max(channel.SampleRate, 1)))
+}
+
+// UpdateChannelSamplingHandler handles PUT /protected/channels/:channelID/sampling. Sampling drops chat for every
+// user of the instance, so only operators, the user who added the channel and admins of its teams may set it.
+func UpdateChannelSamplingHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "setting the sample rate"); err != nil {
+		return err
+	}
+
+	req := new(UpdateChannelSamplingRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if req.SampleRate < 1 || req.SampleRate > monitor.MaxSampleRate {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("sample_rate must be between 1 and %d", monitor.MaxSampleRate))
+	}
+
+	if err := monitor.SetChannelSampleRate(channel.ChannelID, req.SampleRate); err != nil {
+		log.Printf("Error updating sample rate of channel %d: %v", channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update sample rate")
+	}
+	log.Printf("audit: sample rate of channel %s set to %d by %s from %s", channel.Username, req.SampleRate, requester(c), c.RealIP())
+	return c.JSON(http.StatusOK, channelSamplingResponse(channel.ChannelID, req.SampleRate))
+}
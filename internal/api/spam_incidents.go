@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetSpamIncidentsHandler handles GET /protected/spam_incidents?username=&sender_id=&channel_id=&types=&from=&to=
+// &before=&limit=, the archived spam findings of every stream newest first. Pass next_before of a page as before to
+// get the next one.
+func GetSpamIncidentsHandler(c echo.Context) error {
+	q, err := spamIncidentQuery(c, 50, 500)
+	if err != nil {
+		return err
+	}
+	if raw := c.QueryParam("before"); raw != "" {
+		if q.Before, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("invalid before value '%s': expected RFC 3339 timestamp", raw))
+		}
+	}
+
+	page, err := monitor.SearchSpamIncidents(q)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to search spam incidents: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, page)
+}
+
+// GetRepeatOffendersHandler handles GET /protected/spam_incidents/offenders?channel_id=&types=&from=&to=&min_streams=
+// &limit=, the chatters whose spam incidents span several streams
+func GetRepeatOffendersHandler(c echo.Context) error {
+	q, err := spamIncidentQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	minStreams := 2
+	if raw := c.QueryParam("min_streams"); raw != "" {
+		minStreams, err = strconv.Atoi(raw)
+		if err != nil || minStreams < 1 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "min_streams must be a positive integer")
+		}
+	}
+
+	offenders, err := monitor.FindRepeatOffenders(q, minStreams)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to find repeat offenders: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, offenders)
+}
+
+// spamIncidentQuery parses the filters shared by the spam incident endpoints
+func spamIncidentQuery(c echo.Context, defaultLimit, maxLimit int) (monitor.SpamIncidentQuery, error) {
+	q := monitor.SpamIncidentQuery{Username: strings.TrimSpace(c.QueryParam("username")), Limit: defaultLimit}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			return q, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+		}
+		q.Limit = limit
+	}
+	if raw := c.QueryParam("sender_id"); raw != "" {
+		senderID, err := strconv.Atoi(raw)
+		if err != nil {
+			return q, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "sender_id must be an integer")
+		}
+		q.SenderID = &senderID
+	}
+	if raw := c.QueryParam("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return q, util.NewProblem(http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel_id")
+		}
+		q.ChannelID = uint(channelID)
+	}
+	if raw := c.QueryParam("types"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(monitor.SpamIncidentTypes, t) {
+				return q, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed,
+					fmt.Sprintf("unknown incident type '%s', expected one of %s", t, strings.Join(monitor.SpamIncidentTypes, ", ")))
+			}
+			q.Types = append(q.Types, t)
+		}
+	}
+	var err error
+	q.From, q.To, err = timeRangeParams(c)
+	return q, err
+}
+
+// timeRangeParams parses the optional from and to RFC 3339 query parameters, zero when absent
+func timeRangeParams(c echo.Context) (from, to time.Time, err error) {
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.QueryParam(bound.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, bound.name+" must be an RFC 3339 timestamp")
+		}
+		*bound.target = parsed
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "to must be after from")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,694 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/mailer"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	TeamInviteTTL = util.GetEnvDuration("TEAM_INVITE_TTL", 7*24*time.Hour)
+	TeamInviteURL = os.Getenv("TEAM_INVITE_URL") // Accept link of invite emails, {invite_id} is replaced
+)
+
+const (
+	teamNameMaxSize       = 255
+	defaultTeamDashboard  = 7  // Days
+	maxTeamDashboard      = 90 // Days
+	defaultTeamReportPage = 50
+	maxTeamReportPage     = 200
+)
+
+type TeamRequest struct {
+	Name string `json:"name"`
+}
+
+type TeamInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type TeamMemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// TeamSummary is a team of the requesting user
+type TeamSummary struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"` // Of the requesting user
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamDetail is a team with its members and shared channels
+type TeamDetail struct {
+	TeamSummary
+	Members  []TeamMemberInfo  `json:"members"`
+	Channels []TeamChannelInfo `json:"channels"`
+}
+
+type TeamMemberInfo struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type TeamChannelInfo struct {
+	ChannelID uint      `json:"channel_id"`
+	Username  string    `json:"username"`
+	AddedBy   string    `json:"added_by,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// TeamInviteInfo is a pending invite, TeamName is set on the invites of the requesting user
+type TeamInviteInfo struct {
+	ID        uuid.UUID `json:"id"`
+	TeamID    uuid.UUID `json:"team_id"`
+	TeamName  string    `json:"team_name,omitempty"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamInviteEmail is the data rendered into the team invite email
+type TeamInviteEmail struct {
+	TeamName  string
+	InvitedBy string
+	Role      string
+	AcceptURL string
+	ExpiresAt time.Time
+}
+
+// requireVerifiedEmail rejects users who haven't confirmed owning their email, who could have registered someone
+// else's address to claim their invites
+func requireVerifiedEmail(c echo.Context) error {
+	verified, err := auth.EmailVerified(c)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return util.NewProblem(http.StatusForbidden, util.ErrForbidden, "Verify your email address first, see POST /api/protected/account/verify_email")
+	}
+	return nil
+}
+
+// teamFromParam loads the :teamID team and the requesting user's membership, which needs at least minRole.
+// Non-members get a 404 so team IDs can't be probed.
+func teamFromParam(c echo.Context, minRole string) (*models.Team, *models.TeamMember, error) {
+	userID, err := auth.TenantID(c)
+	if err != nil {
+		return nil, nil, util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	teamID, err := uuid.Parse(c.Param("teamID"))
+	if err != nil {
+		return nil, nil, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "Invalid team ID format")
+	}
+
+	var member models.TeamMember
+	if err := db.FromContext(c).Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, util.NewProblem(http.StatusNotFound, util.ErrNotFound, "Team not found")
+		}
+		return nil, nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team membership: %v", err))
+	}
+	var team models.Team
+	if err := db.FromContext(c).First(&team, "id = ?", teamID).Error; err != nil {
+		return nil, nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team: %v", err))
+	}
+	if monitor.TeamRoleRank[member.Role] < monitor.TeamRoleRank[minRole] {
+		return nil, nil, util.NewProblem(http.StatusForbidden, util.ErrForbidden, fmt.Sprintf("This requires the %s role in the team", minRole))
+	}
+	return &team, &member, nil
+}
+
+// validTeamRole checks a role exists and that the acting member may grant it; only owners make owners
+func validTeamRole(role string, actor *models.TeamMember) error {
+	if _, ok := monitor.TeamRoleRank[role]; !ok {
+		return fmt.Errorf("role must be one of %s, %s or %s", monitor.TeamRoleOwner, monitor.TeamRoleAdmin, monitor.TeamRoleMember)
+	}
+	if role == monitor.TeamRoleOwner && actor.Role != monitor.TeamRoleOwner {
+		return errors.New("only owners can grant the owner role")
+	}
+	return nil
+}
+
+// teamChannelIDs returns the IDs of the channels shared with a team
+func teamChannelIDs(tx *gorm.DB, teamID uuid.UUID) ([]uint, error) {
+	var channelIDs []uint
+	if err := tx.Model(&models.TeamChannel{}).Where("team_id = ?", teamID).Pluck("channel_id", &channelIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch team channels: %w", err)
+	}
+	return channelIDs, nil
+}
+
+// GetTeamsHandler handles GET /protected/teams, the teams of the requesting user
+func GetTeamsHandler(c echo.Context) error {
+	userID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	teams := []TeamSummary{}
+	if err := db.FromContext(c).Table("teams").
+		Select("teams.id, teams.name, team_members.role, teams.created_at").
+		Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Where("team_members.user_id = ?", userID).
+		Order("teams.name ASC").Scan(&teams).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch teams: %v", err))
+	}
+	return c.JSON(http.StatusOK, teams)
+}
+
+// CreateTeamHandler handles POST /protected/teams, the creator becomes its owner
+func CreateTeamHandler(c echo.Context) error {
+	userID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	req := new(TeamRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > teamNameMaxSize {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("name is required and must be at most %d characters", teamNameMaxSize))
+	}
+
+	team := models.Team{ID: uuid.New(), Name: req.Name, CreatedBy: userID}
+	err = db.FromContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TeamMember{TeamID: team.ID, UserID: userID, Role: monitor.TeamRoleOwner}).Error
+	})
+	if err != nil {
+		log.Printf("Error creating team %s: %v", team.Name, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to create team")
+	}
+
+	return c.JSON(http.StatusCreated, TeamSummary{ID: team.ID, Name: team.Name, Role: monitor.TeamRoleOwner, CreatedAt: team.CreatedAt})
+}
+
+// GetTeamHandler handles GET /protected/teams/:teamID with the team's members and shared channels
+func GetTeamHandler(c echo.Context) error {
+	team, member, err := teamFromParam(c, monitor.TeamRoleMember)
+	if err != nil {
+		return err
+	}
+
+	detail := TeamDetail{
+		TeamSummary: TeamSummary{ID: team.ID, Name: team.Name, Role: member.Role, CreatedAt: team.CreatedAt},
+		Members:     []TeamMemberInfo{},
+		Channels:    []TeamChannelInfo{},
+	}
+	if err := db.FromContext(c).Table("team_members").
+		Select("team_members.user_id, users.email, team_members.role, team_members.created_at AS joined_at").
+		Joins("JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ?", team.ID).
+		Order("team_members.created_at ASC").Scan(&detail.Members).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team members: %v", err))
+	}
+	if err := db.FromContext(c).Table("team_channels").
+		Select("team_channels.channel_id, monitored_channels.username, team_channels.added_by, team_channels.created_at AS added_at").
+		Joins("JOIN monitored_channels ON monitored_channels.channel_id = team_channels.channel_id").
+		Where("team_channels.team_id = ?", team.ID).
+		Order("monitored_channels.username ASC").Scan(&detail.Channels).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team channels: %v", err))
+	}
+	return c.JSON(http.StatusOK, detail)
+}
+
+// UpdateTeamHandler handles PUT /protected/teams/:teamID, renaming the team
+func UpdateTeamHandler(c echo.Context) error {
+	team, member, err := teamFromParam(c, monitor.TeamRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	req := new(TeamRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > teamNameMaxSize {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("name is required and must be at most %d characters", teamNameMaxSize))
+	}
+
+	if err := db.FromContext(c).Model(team).Update("name", req.Name).Error; err != nil {
+		log.Printf("Error renaming team %s: %v", team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update team")
+	}
+	return c.JSON(http.StatusOK, TeamSummary{ID: team.ID, Name: req.Name, Role: member.Role, CreatedAt: team.CreatedAt})
+}
+
+// DeleteTeamHandler handles DELETE /protected/teams/:teamID. Shared channels stay monitored.
+func DeleteTeamHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if err := db.FromContext(c).Delete(team).Error; err != nil {
+		log.Printf("Error deleting team %s: %v", team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to delete team")
+	}
+	log.Printf("audit: team %s (%s) deleted", team.ID.String(), team.Name)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// InviteTeamMemberHandler handles POST /protected/teams/:teamID/invites and emails the invite.
+// Inviting an address again renews its invite with the new role.
+func InviteTeamMemberHandler(c echo.Context) error {
+	team, member, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+	invitedBy := requester(c)
+
+	req := new(TeamInviteRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "email must be a valid email address")
+	}
+	if req.Role == "" {
+		req.Role = monitor.TeamRoleMember
+	}
+	if err := validTeamRole(req.Role, member); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+
+	email := strings.ToLower(address.Address)
+	var existing int64
+	if err := db.FromContext(c).Table("team_members").
+		Joins("JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ? AND LOWER(users.email) = ?", team.ID, email).
+		Count(&existing).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to check team members: %v", err))
+	}
+	if existing > 0 {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "This user is already a member of the team")
+	}
+
+	invite := models.TeamInvite{
+		ID:        uuid.New(),
+		TeamID:    team.ID,
+		Email:     email,
+		Role:      req.Role,
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(TeamInviteTTL),
+	}
+	err = db.FromContext(c).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "team_id"}, {Name: "email"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"id": invite.ID, "role": invite.Role, "invited_by": invite.InvitedBy, "expires_at": invite.ExpiresAt,
+			"accepted_at": nil, "created_at": time.Now(),
+		}),
+	}).Create(&invite).Error
+	if err != nil {
+		log.Printf("Error saving team invite for %s to team %s: %v", email, team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to save invite")
+	}
+
+	go sendTeamInvite(*team, invite)
+	log.Printf("audit: %s invited %s to team %s as %s", invitedBy, email, team.ID.String(), invite.Role)
+	return c.JSON(http.StatusCreated, newTeamInviteInfo(invite, ""))
+}
+
+func sendTeamInvite(team models.Team, invite models.TeamInvite) {
+	data := TeamInviteEmail{TeamName: team.Name, InvitedBy: invite.InvitedBy, Role: invite.Role, ExpiresAt: invite.ExpiresAt}
+	if TeamInviteURL != "" {
+		data.AcceptURL = strings.ReplaceAll(TeamInviteURL, "{invite_id}", invite.ID.String())
+	}
+	subject := fmt.Sprintf("You're invited to join %s", team.Name)
+	if err := mailer.Send([]string{invite.Email}, subject, mailer.TemplateInvite, data); err != nil {
+		log.Printf("Error emailing team invite %s: %v", invite.ID.String(), err)
+	}
+}
+
+func newTeamInviteInfo(invite models.TeamInvite, teamName string) TeamInviteInfo {
+	return TeamInviteInfo{
+		ID:        invite.ID,
+		TeamID:    invite.TeamID,
+		TeamName:  teamName,
+		Email:     invite.Email,
+		Role:      invite.Role,
+		InvitedBy: invite.InvitedBy,
+		ExpiresAt: invite.ExpiresAt,
+		CreatedAt: invite.CreatedAt,
+	}
+}
+
+// GetTeamInvitesHandler handles GET /protected/teams/:teamID/invites, the team's pending invites
+func GetTeamInvitesHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var invites []models.TeamInvite
+	if err := db.FromContext(c).Where("team_id = ? AND accepted_at IS NULL AND expires_at > ?", team.ID, time.Now()).
+		Order("created_at DESC").Find(&invites).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch invites: %v", err))
+	}
+	infos := make([]TeamInviteInfo, 0, len(invites))
+	for _, invite := range invites {
+		infos = append(infos, newTeamInviteInfo(invite, ""))
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+// RevokeTeamInviteHandler handles DELETE /protected/teams/:teamID/invites/:inviteID
+func RevokeTeamInviteHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+	inviteID, err := uuid.Parse(c.Param("inviteID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid invite ID format")
+	}
+
+	result := db.FromContext(c).Where("id = ? AND team_id = ? AND accepted_at IS NULL", inviteID, team.ID).Delete(&models.TeamInvite{})
+	if result.Error != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to revoke invite")
+	}
+	if result.RowsAffected == 0 {
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Invite not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetMyTeamInvitesHandler handles GET /protected/team_invites, the pending invites of the requesting user's email.
+// The email must be verified, invites go to whoever owns the address.
+func GetMyTeamInvitesHandler(c echo.Context) error {
+	claims, err := auth.CurrentUserClaims(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	if err := requireVerifiedEmail(c); err != nil {
+		return err
+	}
+
+	var invites []struct {
+		models.TeamInvite
+		TeamName string
+	}
+	if err := db.FromContext(c).Table("team_invites").
+		Select("team_invites.*, teams.name AS team_name").
+		Joins("JOIN teams ON teams.id = team_invites.team_id").
+		Where("team_invites.email = ? AND team_invites.accepted_at IS NULL AND team_invites.expires_at > ?", strings.ToLower(claims.Email), time.Now()).
+		Order("team_invites.created_at DESC").Scan(&invites).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch invites: %v", err))
+	}
+	infos := make([]TeamInviteInfo, 0, len(invites))
+	for _, invite := range invites {
+		infos = append(infos, newTeamInviteInfo(invite.TeamInvite, invite.TeamName))
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+// AcceptTeamInviteHandler handles POST /protected/team_invites/:inviteID/accept. Only the user registered with
+// the invited email, once verified, can accept it.
+func AcceptTeamInviteHandler(c echo.Context) error {
+	userID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	claims, _ := auth.CurrentUserClaims(c) // Present, TenantID read them
+	if err := requireVerifiedEmail(c); err != nil {
+		return err
+	}
+	inviteID, err := uuid.Parse(c.Param("inviteID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid invite ID format")
+	}
+
+	var invite models.TeamInvite
+	if err := db.FromContext(c).Where("id = ? AND email = ?", inviteID, strings.ToLower(claims.Email)).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Invite not found")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch invite: %v", err))
+	}
+	if invite.AcceptedAt != nil {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "Invite was already accepted")
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return util.Problem(c, http.StatusGone, util.ErrNotFound, "Invite has expired, ask for a new one")
+	}
+
+	var team models.Team
+	err = db.FromContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&team, "id = ?", invite.TeamID).Error; err != nil {
+			return err
+		}
+		member := models.TeamMember{TeamID: invite.TeamID, UserID: userID, Role: invite.Role}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&invite).Update("accepted_at", time.Now()).Error
+	})
+	if err != nil {
+		log.Printf("Error accepting team invite %s: %v", invite.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to accept invite")
+	}
+
+	log.Printf("audit: %s joined team %s as %s", claims.Email, team.ID.String(), invite.Role)
+	return c.JSON(http.StatusOK, TeamSummary{ID: team.ID, Name: team.Name, Role: invite.Role, CreatedAt: team.CreatedAt})
+}
+
+// teamMemberFromParam loads the :userID member of a team
+func teamMemberFromParam(c echo.Context, teamID uuid.UUID) (*models.TeamMember, error) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		return nil, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "Invalid user ID format")
+	}
+	var member models.TeamMember
+	if err := db.FromContext(c).Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.NewProblem(http.StatusNotFound, util.ErrNotFound, "Team member not found")
+		}
+		return nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team member: %v", err))
+	}
+	return &member, nil
+}
+
+// isLastTeamOwner reports whether member is the only owner of its team
+func isLastTeamOwner(tx *gorm.DB, member *models.TeamMember) (bool, error) {
+	if member.Role != monitor.TeamRoleOwner {
+		return false, nil
+	}
+	var owners int64
+	if err := tx.Model(&models.TeamMember{}).Where("team_id = ? AND role = ?", member.TeamID, monitor.TeamRoleOwner).Count(&owners).Error; err != nil {
+		return false, err
+	}
+	return owners <= 1, nil
+}
+
+// UpdateTeamMemberHandler handles PUT /protected/teams/:teamID/members/:userID, changing the member's role.
+// Only owners change the role of owners, and the last owner can't be demoted.
+func UpdateTeamMemberHandler(c echo.Context) error {
+	team, actor, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+	member, err := teamMemberFromParam(c, team.ID)
+	if err != nil {
+		return err
+	}
+
+	req := new(TeamMemberRoleRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if err := validTeamRole(req.Role, actor); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+	if member.Role == monitor.TeamRoleOwner && actor.Role != monitor.TeamRoleOwner {
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "Only owners can change the role of owners")
+	}
+	if req.Role != monitor.TeamRoleOwner {
+		last, err := isLastTeamOwner(db.FromContext(c), member)
+		if err != nil {
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to count team owners: %v", err))
+		}
+		if last {
+			return util.Problem(c, http.StatusConflict, util.ErrConflict, "The team needs at least one owner")
+		}
+	}
+
+	if err := db.FromContext(c).Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", member.TeamID, member.UserID).Update("role", req.Role).Error; err != nil {
+		log.Printf("Error updating role of %s in team %s: %v", member.UserID.String(), team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to update team member")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveTeamMemberHandler handles DELETE /protected/teams/:teamID/members/:userID. Admins remove members, owners
+// remove anyone, and every member can leave. The last owner has to delete the team instead.
+func RemoveTeamMemberHandler(c echo.Context) error {
+	team, actor, err := teamFromParam(c, monitor.TeamRoleMember)
+	if err != nil {
+		return err
+	}
+	member, err := teamMemberFromParam(c, team.ID)
+	if err != nil {
+		return err
+	}
+
+	if member.UserID != actor.UserID {
+		if monitor.TeamRoleRank[actor.Role] < monitor.TeamRoleRank[monitor.TeamRoleAdmin] {
+			return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "This requires the admin role in the team")
+		}
+		if member.Role == monitor.TeamRoleOwner && actor.Role != monitor.TeamRoleOwner {
+			return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "Only owners can remove owners")
+		}
+	}
+	last, err := isLastTeamOwner(db.FromContext(c), member)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to count team owners: %v", err))
+	}
+	if last {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "The team needs at least one owner, delete the team instead")
+	}
+
+	if err := db.FromContext(c).Where("team_id = ? AND user_id = ?", member.TeamID, member.UserID).Delete(&models.TeamMember{}).Error; err != nil {
+		log.Printf("Error removing %s from team %s: %v", member.UserID.String(), team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to remove team member")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ShareTeamChannelHandler handles PUT /protected/teams/:teamID/channels/:channelID, sharing a monitored channel
+// and its reports with the team. Sharing lets the team's admins manage the channel, so only those who may already
+// manage it can share it.
+func ShareTeamChannelHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "sharing with team "+team.ID.String()); err != nil {
+		return err
+	}
+	shared := models.TeamChannel{TeamID: team.ID, ChannelID: channel.ChannelID, AddedBy: requester(c)}
+	if err := db.FromContext(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&shared).Error; err != nil {
+		log.Printf("Error sharing channel %d with team %s: %v", channel.ChannelID, team.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to share channel")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnshareTeamChannelHandler handles DELETE /protected/teams/:teamID/channels/:channelID
+func UnshareTeamChannelHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleAdmin)
+	if err != nil {
+		return err
+	}
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidChannelID, "Invalid channel ID format")
+	}
+
+	result := db.FromContext(c).Where("team_id = ? AND channel_id = ?", team.ID, channelID).Delete(&models.TeamChannel{})
+	if result.Error != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to unshare channel")
+	}
+	if result.RowsAffected == 0 {
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Channel isn't shared with the team")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetTeamDashboardHandler handles GET /protected/teams/:teamID/dashboard?days=, totals and per-channel stats of
+// the team's shared channels over the last days (default 7)
+func GetTeamDashboardHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleMember)
+	if err != nil {
+		return err
+	}
+
+	days := defaultTeamDashboard
+	if raw := c.QueryParam("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxTeamDashboard {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("days must be between 1 and %d", maxTeamDashboard))
+		}
+		days = parsed
+	}
+
+	channelIDs, err := teamChannelIDs(db.FromContext(c), team.ID)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, err.Error())
+	}
+	end := time.Now().UTC()
+	dashboard, err := monitor.BuildTeamDashboard(*team, channelIDs, end.AddDate(0, 0, -days), end)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build team dashboard: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, dashboard)
+}
+
+// GetTeamReportsHandler handles GET /protected/teams/:teamID/reports?before=&limit=, the report summaries of the
+// team's shared channels, newest first. Pass the report_start_time of the last summary as before for the next page.
+func GetTeamReportsHandler(c echo.Context) error {
+	team, _, err := teamFromParam(c, monitor.TeamRoleMember)
+	if err != nil {
+		return err
+	}
+
+	limit := defaultTeamReportPage
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxTeamReportPage {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("limit must be between 1 and %d", maxTeamReportPage))
+		}
+		limit = parsed
+	}
+	query := db.FromContext(c).Model(&models.LivestreamReport{}).
+		Select("id, channel_id, username, livestream_id, title, report_start_time, report_end_time, duration_minutes, "+
+			"average_viewers, peak_viewers, lowest_viewers, engagement, hours_watched, total_messages, unique_chatters, created_at").
+		Where("channel_id IN (?) AND parent_report_id IS NULL",
+			db.FromContext(c).Model(&models.TeamChannel{}).Select("channel_id").Where("team_id = ?", team.ID))
+	if raw := c.QueryParam("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("invalid before value '%s': expected RFC 3339 timestamp", raw))
+		}
+		query = query.Where("report_start_time < ?", before)
+	}
+
+	summaries := []ReportSummary{}
+	if err := query.Order("report_start_time DESC").Limit(limit).Scan(&summaries).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch team reports: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, summaries)
+}
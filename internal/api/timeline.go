@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetChannelEventsHandler handles GET /channels/:channelID/events?types=&before=&since=&limit=, the channel's
+// timeline of structured events newest first. Pass next_before of a page as before to get the next one.
+func GetChannelEventsHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	q := monitor.TimelineQuery{Limit: 50}
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "limit must be between 1 and 200")
+		}
+		q.Limit = parsed
+	}
+	if raw := c.QueryParam("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("invalid before value '%s': expected RFC 3339 timestamp", raw))
+		}
+		q.Before = before
+	}
+	if q.Since, err = parseSince(c); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, err.Error())
+	}
+	if raw := c.QueryParam("types"); raw != "" {
+		q.Types = make(map[string]struct{})
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(monitor.TimelineEventTypes, t) {
+				return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed,
+					fmt.Sprintf("unknown event type '%s', expected one of %s", t, strings.Join(monitor.TimelineEventTypes, ", ")))
+			}
+			q.Types[t] = struct{}{}
+		}
+	}
+
+	timeline, err := monitor.GetChannelTimeline(*channel, q)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to build channel timeline: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, timeline)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetTitleAnalyticsHandler handles GET /channels/:channelID/title_analytics?days=, the channel's streams of the
+// last days grouped by title keywords, styles and recurring templates, with how fast each drew viewers in
+// compared to the channel's average
+func GetTitleAnalyticsHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	days := 90
+	if raw := c.QueryParam("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 365 {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "days must be between 1 and 365")
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	analytics, err := monitor.BuildTitleAnalytics(channel.ChannelID, channel.Username, now.AddDate(0, 0, -days), now)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to analyse titles: %v", err))
+	}
+	return jsonWithETag(c, http.StatusOK, analytics)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/notify"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type AddChannelWebhookRequest struct {
+	URL string `json:"url"`
+}
+
+// ChannelWebhookSecretResponse is a webhook with its signing secret, only returned when the secret is created
+type ChannelWebhookSecretResponse struct {
+	models.ChannelWebhook
+	Secret string `json:"secret"`
+}
+
+// GetChannelWebhooksHandler handles GET /protected/channels/:channelID/webhooks. Secrets aren't listed. Like every
+// webhook route it's open to operators, the user who added the channel and admins of its teams only.
+func GetChannelWebhooksHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "listing webhooks"); err != nil {
+		return err
+	}
+
+	webhooks := []models.ChannelWebhook{}
+	if err := db.FromContext(c).Where("channel_id = ?", channel.ChannelID).Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch webhooks: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// AddChannelWebhookHandler handles POST /protected/channels/:channelID/webhooks, registering an endpoint for the
+// channel's alerts with a new signing secret. The secret is in this response only.
+func AddChannelWebhookHandler(c echo.Context) error {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, "adding a webhook"); err != nil {
+		return err
+	}
+
+	req := new(AddChannelWebhookRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	endpoint, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "url must be an absolute http or https URL")
+	}
+	if err := notify.CheckWebhookTarget(c.Request().Context(), endpoint); err != nil {
+		log.Printf("audit: rejected webhook %s for channel %s by %s from %s: %v", endpoint.Redacted(), channel.Username, requester(c), c.RealIP(), err)
+		if errors.Is(err, notify.ErrPrivateTarget) {
+			return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "url must point to a public address")
+		}
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "url host can't be resolved")
+	}
+
+	secret, err := notify.NewSecret()
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to generate secret")
+	}
+	webhook := models.ChannelWebhook{
+		ID:        uuid.New(),
+		ChannelID: channel.ChannelID,
+		URL:       endpoint.String(),
+		Secret:    secret,
+		CreatedBy: requester(c),
+	}
+	if err := db.FromContext(c).Create(&webhook).Error; err != nil {
+		log.Printf("Error saving webhook for channel %d: %v", channel.ChannelID, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to save webhook")
+	}
+
+	log.Printf("audit: webhook %s added to channel %s by %s from %s", webhook.ID, channel.Username, webhook.CreatedBy, c.RealIP())
+	return c.JSON(http.StatusCreated, ChannelWebhookSecretResponse{ChannelWebhook: webhook, Secret: secret})
+}
+
+// DeleteChannelWebhookHandler handles DELETE /protected/channels/:channelID/webhooks/:webhookID
+func DeleteChannelWebhookHandler(c echo.Context) error {
+	channel, webhook, err := channelWebhookFromParams(c, "deleting a webhook")
+	if err != nil {
+		return err
+	}
+
+	if err := db.FromContext(c).Delete(webhook).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to delete webhook")
+	}
+
+	log.Printf("audit: webhook %s removed from channel %s by %s from %s", webhook.ID, channel.Username, requester(c), c.RealIP())
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RotateChannelWebhookSecretHandler handles POST /protected/channels/:channelID/webhooks/:webhookID/rotate_secret.
+// Deliveries are signed with both the new and the old secret for notify.SecretRotationGrace, so the consumer can
+// switch over without rejecting alerts.
+func RotateChannelWebhookSecretHandler(c echo.Context) error {
+	channel, webhook, err := channelWebhookFromParams(c, "rotating a webhook secret")
+	if err != nil {
+		return err
+	}
+
+	secret, err := notify.NewSecret()
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to generate secret")
+	}
+	expiresAt := time.Now().UTC().Add(notify.SecretRotationGrace)
+	// Conditional on the old secret so of two concurrent rotations one fails instead of dropping the other's secret
+	result := db.FromContext(c).Model(webhook).Where("secret = ?", webhook.Secret).Updates(map[string]any{
+		"secret":                     secret,
+		"previous_secret":            webhook.Secret,
+		"previous_secret_expires_at": expiresAt,
+	})
+	if result.Error != nil {
+		log.Printf("Error rotating secret of webhook %s: %v", webhook.ID, result.Error)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to rotate secret")
+	}
+	if result.RowsAffected == 0 {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "The secret was rotated concurrently, retry")
+	}
+	webhook.PreviousSecretExpiresAt = &expiresAt
+
+	log.Printf("audit: secret of webhook %s of channel %s rotated by %s from %s", webhook.ID, channel.Username, requester(c), c.RealIP())
+	return c.JSON(http.StatusOK, ChannelWebhookSecretResponse{ChannelWebhook: *webhook, Secret: secret})
+}
+
+// channelWebhookFromParams loads the webhook of :webhookID, which must belong to the channel of :channelID, once the
+// requesting user is allowed the action on the channel's webhooks
+func channelWebhookFromParams(c echo.Context, action string) (*models.MonitoredChannel, *models.ChannelWebhook, error) {
+	channel, err := channelFromParam(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := authorizeChannel(c, channel, monitor.TeamRoleAdmin, action); err != nil {
+		return nil, nil, err
+	}
+	webhookID, err := uuid.Parse(c.Param("webhookID"))
+	if err != nil {
+		return nil, nil, util.NewProblem(http.StatusBadRequest, util.ErrValidationFailed, "Invalid webhook ID format")
+	}
+
+	var webhook models.ChannelWebhook
+	if err := db.FromContext(c).Where("id = ? AND channel_id = ?", webhookID, channel.ChannelID).First(&webhook).Error; err != nil {
+		return nil, nil, util.NewProblem(http.StatusNotFound, util.ErrNotFound, "Webhook not found")
+	}
+	return channel, &webhook, nil
+}
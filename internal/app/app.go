@@ -0,0 +1,124 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/cluster"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm"
+)
+
+// Config is what the service needs from its environment beyond the settings each package reads for itself
+type Config struct {
+	Port      string
+	ProxyURLs []string // Challenge-solving proxies Kick requests rotate over, unused in FakeMode
+	FakeMode  bool     // Synthetic channels, viewers and chat instead of Kick
+}
+
+// ConfigFromEnv reads the Config from PORT, PROXY_URLS (or PROXY_URL for a single proxy) and FAKE_MODE
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Port:      util.GetEnvString("PORT", "8080"),
+		ProxyURLs: proxyURLsFromEnv(),
+		FakeMode:  monitor.FakeMode,
+	}
+	if !cfg.FakeMode && len(cfg.ProxyURLs) == 0 {
+		return cfg, errors.New("PROXY_URL or PROXY_URLS environment variable is not set. Please set it in your environment or docker-compose.yml")
+	}
+	return cfg, nil
+}
+
+// proxyURLsFromEnv returns the comma separated PROXY_URLS, falling back to PROXY_URL
+func proxyURLsFromEnv() []string {
+	raw := os.Getenv("PROXY_URLS")
+	if strings.TrimSpace(raw) == "" {
+		raw = os.Getenv("PROXY_URL")
+	}
+	var urls []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			urls = append(urls, part)
+		}
+	}
+	return urls
+}
+
+// App holds the dependencies of the service. NewServer hands DB and Fetcher to the handlers with every request; the
+// monitors and background loops still read them from the package state (db.DB, monitor's fetcher) New fills in, so
+// a process runs one App at a time.
+type App struct {
+	DB      *gorm.DB
+	Fetcher monitor.Fetcher // Nil in FakeMode
+	Config  Config
+}
+
+// New wires database and fetcher into the service
+func New(cfg Config, database *gorm.DB, fetcher monitor.Fetcher) *App {
+	db.DB = database
+	monitor.SetFetcher(fetcher)
+	return &App{DB: database, Fetcher: fetcher, Config: cfg}
+}
+
+// Start starts monitoring the active channels, or lets the cluster claim them, and the background loops. They
+// run until stop is closed.
+func (a *App) Start(stop <-chan struct{}) error {
+	if a.Config.FakeMode {
+		// Synthetic data generator, no proxy or Kick access needed
+		log.Printf("FAKE_MODE enabled: generating synthetic channels, viewers and chat")
+		if err := monitor.SeedFakeChannels(); err != nil {
+			return fmt.Errorf("failed to seed synthetic channels: %w", err)
+		}
+	}
+
+	var activeChannels []models.MonitoredChannel
+	if err := a.DB.Where("is_active = ?", true).Find(&activeChannels).Error; err != nil {
+		return fmt.Errorf("failed to load active channels: %w", err)
+	}
+	if len(activeChannels) == 0 {
+		log.Printf("No active channels found in the database on startup.")
+	}
+
+	activeUsernames := make([]string, len(activeChannels))
+	for i, channel := range activeChannels {
+		activeUsernames[i] = channel.Username
+	}
+	if err := monitor.LoadMonitorAllowlist(activeUsernames); err != nil {
+		return fmt.Errorf("invalid monitor allow-list: %w", err)
+	}
+
+	if err := monitor.LoadFlaggedChatters(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	cluster.Init()
+	if cluster.Enabled() {
+		// Channels are claimed by the cluster rebalance loop instead of all at once
+		go cluster.Run(stop)
+		go monitor.RunLiveReportSnapshots(stop)
+		go monitor.RunClusterEvents(stop)
+	} else {
+		for _, channel := range activeChannels {
+			go monitor.StartMonitoringChannel(&channel)
+		}
+	}
+
+	go monitor.RunWeeklyDigests(stop)
+	go monitor.RunMessageReassociation(stop)
+	go monitor.RunChatCounterFlusher(stop)
+	go monitor.RunJobRecovery(stop)
+	go monitor.RunLiveAggregateSampler(stop)
+	go monitor.RunWritePipelines(stop)
+	go monitor.RunFollowersBackfill(stop)
+	go monitor.RunDailyDigests(stop)
+	go monitor.RunChatArchiver(stop)
+	go monitor.RunBackups(stop)
+	return nil
+}
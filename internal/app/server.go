@@ -0,0 +1,278 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/retconned/kick-monitor/internal/api"
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/quota"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
+)
+
+// reportImportPath takes report archives, larger than API_BODY_LIMIT allows
+const reportImportPath = "/api/protected/reports/import"
+
+// NewServer builds the HTTP server of a: middleware and every route. It doesn't listen, main starts it. Handlers
+// query a.DB and look channels up through a.Fetcher, the monitors they start use what New set.
+func NewServer(a *App) (*echo.Echo, error) {
+	e := echo.New()
+
+	// Resolve client IPs behind reverse proxies (rate limiting and logs key on c.RealIP())
+	ipExtractor, err := util.NewIPExtractor()
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy configuration: %w", err)
+	}
+	e.IPExtractor = ipExtractor
+
+	e.Logger.SetLevel(log.INFO) // (INFO, DEBUG, WARN, ERROR, OFF)
+
+	// --- Custom Error Handler ---
+	e.HTTPErrorHandler = util.CustomHTTPErrorHandler
+
+	// Logger middleware (using Echo's default for requests)
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: `{"time":"${time_rfc3339}","id":"${id}","remote_ip":"${remote_ip}","host":"${host}",` +
+			`"method":"${method}","uri":"${uri}","user_agent":"${user_agent}",` +
+			`"status":${status},"error":"${error}","latency":"${latency_human}"` +
+			`,"bytes_in":${bytes_in},"bytes_out":${bytes_out}}` + "\n",
+		CustomTimeFormat: "2006-01-02 15:04:05.000",
+		Output:           os.Stdout,
+	}))
+
+	e.Use(middleware.Recover())   // Recovers from panics and serves a 500 error
+	e.Use(middleware.RequestID()) // Assigns a unique ID to each request (useful for tracing logs)
+	e.Use(middleware.Secure())
+	e.Use(db.Middleware(a.DB), api.WithFetcher(a.Fetcher)) // What handlers read with db.FromContext and fetcherFrom
+
+	// gzip for JSON, NDJSON, CSV and calendar responses; size cap on request bodies of write endpoints
+	compress, err := util.Compress()
+	if err != nil {
+		return nil, fmt.Errorf("invalid compression configuration: %w", err)
+	}
+	e.Use(compress)
+	bodyLimit, err := util.BodyLimit(util.APIBodyLimit, reportImportPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_BODY_LIMIT: %w", err)
+	}
+	e.Use(bodyLimit)
+	reportImportLimit, err := util.BodyLimit(util.ReportImportBodyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REPORT_IMPORT_BODY_LIMIT: %w", err)
+	}
+
+	// CORS middleware (configure carefully for production)
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"}, //TODO: switch it to  "https://yourfrontend.com" when its production
+
+		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXCSRFToken},
+		AllowCredentials: true,
+		MaxAge:           300, // Max age for preflight requests in seconds
+	}))
+
+	// CSRF middleware (optional, for form submissions)
+	// Needs careful implementation with frontend to send CSRF token with requests
+	// e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+	// 	TokenLookup: "header:X-CSRF-Token", // or "form:_csrf" etc.
+	// 	CookiePath:  "/",
+	// 	CookieHTTPOnly: true,
+	// 	CookieSameSite: http.SameSiteLaxMode, // Adjust for cross-domain if needed
+	// 	CookieSecure: true, // Set to true in HTTPS production
+	// }))
+
+	// Rate Limiter middleware, per client IP with per-route budgets
+	rateLimitRules, err := util.ParseRateLimitRules(util.RateLimitRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ROUTES: %w", err)
+	}
+	rateLimiter, err := util.RateLimiter(rateLimitRules)
+	if err != nil {
+		return nil, err
+	}
+	e.Use(rateLimiter)
+
+	apiGroup := e.Group("/api")
+	// health endpoint
+	apiGroup.GET("/health", api.HealthCheckHandler)
+	apiGroup.GET("/status", api.PublicStatusHandler) // anonymized snapshot for public status pages
+
+	// public routes start here
+	apiGroup.POST("/register", auth.RegisterHandler)
+	apiGroup.POST("/login", auth.LoginHandler)
+	apiGroup.POST("/account/verify_email", auth.VerifyEmailHandler) // {"token": ""} mailed by POST /protected/account/email
+
+	// Reports API
+	// Group these routes with common prefixes
+	// e.GET("/reports/:reportUUID", api.GetReportByUUIDHandler)
+	// e.GET("/channels/:channelID/reports", api.GetReportsByChannelIDHandler)
+
+	// route to get livestream report
+	apiGroup.GET("/livestream/:livestreamID", api.GetReportsByLivestreamIDHandler) // /livestream/id, ?fields=peak_viewers,spam_report.duplicate_messages_count
+
+	// latest report summary (no timelines) per channel
+	apiGroup.GET("/channels/:channelID/reports/latest", api.GetLatestReportByChannelIDHandler) // ?fields=
+	apiGroup.GET("/channels/:channelID/reports/live", api.GetLiveReportHandler)                // ?fields=, running aggregate of the stream in progress
+	apiGroup.GET("/reports/latest", api.GetLatestReportsHandler)                               // ?channel_ids=1,2,3&fields=
+
+	// iCalendar feed of past and predicted streams
+	apiGroup.GET("/channels/:channelID/calendar.ics", api.GetChannelCalendarHandler)
+
+	// 7 and 30 day projections of average viewers and followers
+	apiGroup.GET("/channels/:channelID/forecast", api.GetChannelForecastHandler)
+
+	// streams, hours, viewers, followers, engagement and spam score of the last 30 days vs the 30 days before
+	apiGroup.GET("/channels/:channelID/growth", api.GetChannelGrowthHandler)
+
+	// streams ranked by followers gained per hour watched
+	apiGroup.GET("/channels/:channelID/follower_conversion", api.GetFollowerConversionHandler) // ?days=&limit=
+
+	// title keywords, styles and recurring templates by viewer ramp, compared to the channel's average
+	apiGroup.GET("/channels/:channelID/title_analytics", api.GetTitleAnalyticsHandler) // ?days=
+
+	// activity feed: went live/offline, title and chat mode changes, bans, milestones, reports
+	apiGroup.GET("/channels/:channelID/events", api.GetChannelEventsHandler) // ?types=&before=&since=&limit=
+
+	// ad-hoc aggregates over reports, grouped for charts
+	apiGroup.GET("/analytics/query", api.AnalyticsQueryHandler) // ?metric=&group_by=day|week|month|channel|category|simulcast&simulcast=&from=&to=
+
+	// TODO: /livestreams , might need a new name. we'll get protected
+	apiGroup.GET("/livestreams", api.GetLatestLivestreams)
+	apiGroup.GET("/livestreams/:username", api.GetLatestLivestreamsByUsername)
+	// Channels Info API
+	apiGroup.GET("/profile/:username", api.GetStreamerProfileHandler) // /channels/id/profile (aggregated profile), ?fields=username,livestreams.peak_viewers
+
+	// compact, cacheable summaries for widgets on third-party sites
+	apiGroup.GET("/embed/:username", api.GetEmbedHandler) // ?format=json|oembed
+	apiGroup.GET("/oembed", api.OEmbedHandler)            // ?url=https://kick.com/username
+
+	// network-wide concurrent viewers of the live channels monitored by this instance
+	apiGroup.GET("/live/aggregate", api.GetLiveAggregateHandler)
+
+	// trimmed payloads for mobile clients: no timelines, 20-point sparklines, rounded numbers
+	mobile := apiGroup.Group("/mobile/v1")
+	mobile.GET("/profile/:username", api.GetMobileProfileHandler)
+	mobile.GET("/livestream/:livestreamID", api.GetMobileReportHandler)
+
+	// proeteced routes start here
+	r := apiGroup.Group("/protected")
+	r.Use(auth.AuthMiddleware())
+	r.POST("/add_channel", api.AddChannelHandler, quota.Enforce(quota.MetricChannels))
+	r.PATCH("/channels/:username", api.UpdateChannelHandler)                                                     // {"is_active": false} stops monitoring and keeps the data
+	r.DELETE("/channels/:username", api.DeleteChannelHandler)                                                    // ?purge=true also deletes everything collected about it
+	r.POST("/process_livestream_report", api.ProcessLivestreamReportHandler, quota.Enforce(quota.MetricReports)) // start_time/end_time report a window next to the full report
+	r.GET("/livestream/:livestreamID/windows", api.GetWindowReportsHandler)
+	r.GET("/usage", quota.UsageHandler)
+	r.GET("/sessions", auth.ListSessionsHandler)
+	r.GET("/account", auth.GetAccountHandler)
+	r.PUT("/account/password", auth.ChangePasswordHandler) // {"current_password": "", "new_password": ""} revokes the other sessions
+	r.POST("/account/email", auth.ChangeEmailHandler)      // {"email": "", "current_password": ""} mails a confirmation token to the new address
+	r.DELETE("/account/email", auth.CancelEmailChangeHandler)
+	r.POST("/account/verify_email", auth.SendEmailVerificationHandler) // mails a token confirming the address, needed to accept team invites
+	r.DELETE("/sessions/:sessionID", auth.RevokeSessionHandler)
+	r.GET("/migrations", api.MigrationStatusHandler)
+	r.GET("/reports/jobs/:jobID/progress", api.StreamReportProgressHandler) // SSE, job_id from process_livestream_report
+
+	// operators only (ADMIN_EMAILS), what they do affects every user of the instance
+	admin := r.Group("/admin", auth.RequireAdmin())
+	admin.GET("/storage", api.StorageStatsHandler) // table sizes, row counts and growth for retention planning
+	admin.GET("/monitors", api.MonitorsHandler)    // fetch health and proxy budget consumption per channel
+	admin.GET("/proxies", api.ProxiesHandler)      // health of each proxy of the pool, failing ones rest before rotating back in
+	admin.GET("/jobs", api.JobsHandler)            // ?stuck=true&include_finished=true
+	admin.POST("/jobs/:jobID/requeue", api.RequeueJobHandler)
+	admin.POST("/drain", api.DrainHandler) // stop new fetches and report jobs before a shutdown
+	admin.GET("/drain", api.DrainStatusHandler)
+	admin.POST("/channels/:channelID/resync", api.ResyncChannelHandler) // rebuild profile, followers timeline and livestream list from raw data
+	admin.POST("/digests/daily/:day", api.RegenerateDailyDigestHandler)
+	admin.GET("/fetch_queue", api.GetFetchQueueHandler)                                   // proxy requests queued and in flight, next fetch of each channel
+	admin.POST("/fetch_queue/:channelID/prioritize", api.PrioritizeChannelFetchesHandler) // fetch now and jump the queue for FETCH_PRIORITY_DURATION
+	admin.GET("/backups", api.ListBackupsHandler)
+	admin.POST("/backups", api.StartBackupHandler) // runs as a job, restore with the restore command
+
+	// portable archives of complete reports, to move them between instances
+	r.GET("/reports/:reportID/archive", api.ExportReportArchiveHandler)
+	r.POST("/reports/import", api.ImportReportArchiveHandler, reportImportLimit, auth.RequireAdmin()) // operators only, ?replace=true overwrites the livestream's report
+
+	// resumable NDJSON feed of every ingested event for data pipelines
+	r.GET("/export/events", api.ExportEventsHandler) // ?since=cursor&limit=
+
+	// moderation: flagged chatters and live events
+	r.POST("/channels/:channelID/flag_user", api.FlagUserHandler)
+	r.DELETE("/channels/:channelID/flag_user/:username", api.UnflagUserHandler)
+	r.GET("/channels/:channelID/flagged_users", api.GetFlaggedUsersHandler)
+	r.GET("/channels/:channelID/flagged_messages", api.GetFlaggedMessagesHandler)
+	r.GET("/channels/:channelID/suspicious_chatters", api.GetSuspiciousChattersHandler)
+	r.GET("/channels/:channelID/events", api.StreamChannelEventsHandler)                     // SSE
+	r.GET("/channels/:channelID/chatters/:username/evidence", api.GetChatterEvidenceHandler) // ?from=&to=&format=json|markdown, bundle for Kick ban appeals
+
+	// archive of spam findings across streams: bursts, suspicious chatters, copypasta
+	r.GET("/spam_incidents", api.GetSpamIncidentsHandler)             // ?username=&sender_id=&channel_id=&types=&from=&to=&before=&limit=
+	r.GET("/spam_incidents/offenders", api.GetRepeatOffendersHandler) // ?channel_id=&types=&from=&to=&min_streams=&limit=
+
+	// review queue of spam findings, confirmations tune the detectors and flag offenders in every report
+	r.GET("/moderation/queue", api.GetModerationQueueHandler)                     // ?status=pending|confirmed|dismissed|all&channel_id=&types=&before=&limit=
+	r.POST("/moderation/items/:itemID/confirm", api.ConfirmModerationItemHandler) // {"note": ""}
+	r.POST("/moderation/items/:itemID/dismiss", api.DismissModerationItemHandler) // {"note": ""}
+	r.GET("/moderation/precision", api.GetModerationPrecisionHandler)             // ?channel_id=&from=&to=
+	r.GET("/moderation/confirmed_offenders", api.GetConfirmedOffendersHandler)    // ?channel_id=&limit=
+
+	// fleet-wide summary of each UTC day, also sent through the notification webhook
+	r.GET("/digests/daily", api.GetDailyDigestsHandler)     // ?limit=
+	r.GET("/digests/daily/:day", api.GetDailyDigestHandler) // YYYY-MM-DD, ?preview=true builds one not generated yet
+
+	// bot probability of a chatter from their behaviour across recent streams
+	r.GET("/chatters/:senderID/bot_score", api.GetChatterBotScoreHandler)
+
+	// chatters excluded from analytics (alts, test bots) or never flagged as suspicious
+	r.GET("/channels/:channelID/chatter_lists", api.GetChatterListsHandler) // ?list=excluded|trusted
+	r.PUT("/channels/:channelID/chatter_lists/:senderID", api.PutChatterListHandler)
+	r.DELETE("/channels/:channelID/chatter_lists/:senderID", api.DeleteChatterListHandler)
+
+	// chat sampling for giant streams
+	r.GET("/channels/:channelID/sampling", api.GetChannelSamplingHandler)
+	r.PUT("/channels/:channelID/sampling", api.UpdateChannelSamplingHandler)
+
+	// email delivery of reports and weekly digests
+	r.GET("/channels/:channelID/recipients", api.GetReportRecipientsHandler)
+	r.POST("/channels/:channelID/recipients", api.AddReportRecipientHandler)
+	r.DELETE("/channels/:channelID/recipients/:recipientID", api.DeleteReportRecipientHandler)
+	r.GET("/channels/:channelID/webhooks", api.GetChannelWebhooksHandler)
+	r.POST("/channels/:channelID/webhooks", api.AddChannelWebhookHandler)
+	r.DELETE("/channels/:channelID/webhooks/:webhookID", api.DeleteChannelWebhookHandler)
+	r.POST("/channels/:channelID/webhooks/:webhookID/rotate_secret", api.RotateChannelWebhookSecretHandler)
+	r.GET("/channels/:channelID/digest", api.PreviewDigestHandler) // JSON preview of the weekly digest
+
+	// competitor sets and market share analytics
+	r.GET("/competitor_sets", api.GetCompetitorSetsHandler)
+	r.POST("/competitor_sets", api.CreateCompetitorSetHandler)
+	r.GET("/competitor_sets/:setID", api.GetCompetitorSetHandler)
+	r.PUT("/competitor_sets/:setID", api.UpdateCompetitorSetHandler)
+	r.DELETE("/competitor_sets/:setID", api.DeleteCompetitorSetHandler)
+	r.GET("/competitor_sets/:setID/analytics", api.GetCompetitorAnalyticsHandler)
+
+	// team workspaces sharing channels, their reports and a dashboard
+	r.GET("/teams", api.GetTeamsHandler)
+	r.POST("/teams", api.CreateTeamHandler)
+	r.GET("/teams/:teamID", api.GetTeamHandler)
+	r.PUT("/teams/:teamID", api.UpdateTeamHandler)
+	r.DELETE("/teams/:teamID", api.DeleteTeamHandler)
+	r.GET("/teams/:teamID/dashboard", api.GetTeamDashboardHandler) // ?days=
+	r.GET("/teams/:teamID/reports", api.GetTeamReportsHandler)     // ?before=&limit=
+	r.PUT("/teams/:teamID/channels/:channelID", api.ShareTeamChannelHandler)
+	r.DELETE("/teams/:teamID/channels/:channelID", api.UnshareTeamChannelHandler)
+	r.PUT("/teams/:teamID/members/:userID", api.UpdateTeamMemberHandler)
+	r.DELETE("/teams/:teamID/members/:userID", api.RemoveTeamMemberHandler)
+	r.GET("/teams/:teamID/invites", api.GetTeamInvitesHandler)
+	r.POST("/teams/:teamID/invites", api.InviteTeamMemberHandler)
+	r.DELETE("/teams/:teamID/invites/:inviteID", api.RevokeTeamInviteHandler)
+	r.GET("/team_invites", api.GetMyTeamInvitesHandler)
+	r.POST("/team_invites/:inviteID/accept", api.AcceptTeamInviteHandler)
+
+	return e, nil
+}
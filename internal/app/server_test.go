@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errNoDatabase = errors.New("no database in tests")
+
+// recordingPool is a gorm.ConnPool failing every statement, recording what it was sent
+type recordingPool struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (p *recordingPool) record(query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queries = append(p.queries, query)
+}
+
+// sent reports whether a recorded statement contains fragment
+func (p *recordingPool) sent(fragment string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, query := range p.queries {
+		if strings.Contains(query, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *recordingPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	p.record(query)
+	return nil, errNoDatabase
+}
+
+func (p *recordingPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	p.record(query)
+	return nil, errNoDatabase
+}
+
+func (p *recordingPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	p.record(query)
+	return nil, errNoDatabase
+}
+
+func (p *recordingPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	p.record(query)
+	return nil
+}
+
+// newTestServer builds the App around a database recording its statements and serves NewServer over httptest
+func newTestServer(t *testing.T) (*httptest.Server, *recordingPool) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	pool := &recordingPool{}
+	conn, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the test database: %v", err)
+	}
+
+	previous := db.DB
+	t.Cleanup(func() { db.DB = previous })
+	a := New(Config{FakeMode: true}, conn, nil)
+	auth.InitAuth(a.DB)
+
+	e, err := NewServer(a)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return server, pool
+}
+
+func TestHealth(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode the health response: %v", err)
+	}
+	if body.Status == "" {
+		t.Error("health response has no status")
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/protected/usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestHandlersQueryAppDatabase(t *testing.T) {
+	server, pool := newTestServer(t)
+	db.DB = nil // Handlers must use the App's database, not the package one
+
+	for _, path := range []string{"/api/channels/1/calendar.ics", "/api/reports/latest?channel_ids=1"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("GET %s: status = %d, want %d from the failing database", path, resp.StatusCode, http.StatusInternalServerError)
+		}
+	}
+	for _, table := range []string{"monitored_channels", "livestream_reports"} {
+		if !pool.sent(table) {
+			t.Errorf("no query of %s reached the App's database", table)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// ConfigReport is what ValidateConfig found wrong with the environment. Errors keep the service from starting,
+// warnings are settings that were ignored or fell back to their default.
+type ConfigReport struct {
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateConfig checks every setting the service needs before anything starts, so a broken environment is
+// reported at once instead of by whichever package happens to read it first
+func ValidateConfig() ConfigReport {
+	var errs []error
+	if _, err := ConfigFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, raw := range proxyURLsFromEnv() {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("proxy URLs must be http(s) URLs, got %q", raw))
+		}
+	}
+	if raw := os.Getenv("PORT"); raw != "" {
+		if port, err := strconv.Atoi(raw); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("PORT must be a port number, got %q", raw))
+		}
+	}
+	errs = append(errs, db.ValidateConfig()...)
+	errs = append(errs, auth.ValidateConfig()...)
+
+	// Optional integrations only have to be complete once one of their settings is given
+	if raw := os.Getenv("NOTIFY_WEBHOOK_URL"); raw != "" {
+		if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("NOTIFY_WEBHOOK_URL must be an absolute URL, got %q", raw))
+		}
+	}
+	if os.Getenv("SMTP_HOST") != "" {
+		if os.Getenv("SMTP_FROM") == "" && os.Getenv("SMTP_USERNAME") == "" {
+			errs = append(errs, errors.New("SMTP_HOST is set but neither SMTP_FROM nor SMTP_USERNAME, emails have no sender"))
+		}
+		util.GetEnvInt("SMTP_PORT", 587) // Read by mailer.Init only after validation
+	}
+	if (monitor.BackupS3Endpoint == "") != (monitor.BackupS3Bucket == "") {
+		errs = append(errs, errors.New("BACKUP_S3_ENDPOINT and BACKUP_S3_BUCKET must be set together"))
+	}
+	if strings.Contains(monitor.BackupS3Endpoint, "://") {
+		errs = append(errs, fmt.Errorf("BACKUP_S3_ENDPOINT is host[:port] without a scheme, got %q", monitor.BackupS3Endpoint))
+	}
+
+	report := ConfigReport{Errors: []string{}, Warnings: util.InvalidSettings()}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+	return report
+}
+
+// Err returns the errors of the report as one, nil when there are none
+func (r ConfigReport) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration, %d problem(s):\n  - %s", len(r.Errors), strings.Join(r.Errors, "\n  - "))
+}
+
+// String lists the errors and warnings of the report for a person to read
+func (r ConfigReport) String() string {
+	if len(r.Errors) == 0 && len(r.Warnings) == 0 {
+		return "Configuration OK"
+	}
+	var b strings.Builder
+	for _, section := range []struct {
+		title string
+		items []string
+	}{{"Errors", r.Errors}, {"Warnings", r.Warnings}} {
+		if len(section.items) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", section.title, len(section.items))
+		for _, item := range section.items {
+			fmt.Fprintf(&b, "  - %s\n", item)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/mailer"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	EmailChangeTTL = util.GetEnvDuration("EMAIL_CHANGE_TTL", 24*time.Hour) // How long the token confirming a new email stays valid
+	EmailVerifyURL = os.Getenv("EMAIL_VERIFY_URL")                         // Confirm link of email change emails, {token} is replaced
+)
+
+const minPasswordLength = 8
+
+// AccountResponse is the account of the authenticated user
+type AccountResponse struct {
+	ID                    uuid.UUID  `json:"id"`
+	Email                 string     `json:"email"`
+	EmailVerified         bool       `json:"email_verified"`
+	Admin                 bool       `json:"admin"`                   // Operator of the instance (ADMIN_EMAILS)
+	PendingEmail          string     `json:"pending_email,omitempty"` // Waiting on confirmation
+	PendingEmailExpiresAt *time.Time `json:"pending_email_expires_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	PasswordChangedAt     *time.Time `json:"password_changed_at,omitempty"`
+	LastLoginAt           *time.Time `json:"last_login_at,omitempty"`
+	ActiveSessions        int64      `json:"active_sessions"`
+	Teams                 int64      `json:"teams"`
+	GatewayManaged        bool       `json:"gateway_managed"` // Signed in through the SSO gateway, which owns the email and password
+}
+
+// ChangePasswordRequest is the body of PUT /protected/account/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangeEmailRequest is the body of POST /protected/account/email
+type ChangeEmailRequest struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// VerifyEmailRequest is the body of POST /account/verify_email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// EmailChangeEmail is the data rendered into the email change confirmation
+type EmailChangeEmail struct {
+	CurrentEmail string
+	NewEmail     string
+	Token        string
+	VerifyURL    string
+	ExpiresAt    time.Time
+}
+
+// EmailVerificationEmail is the data rendered into the email confirming the address of an account
+type EmailVerificationEmail struct {
+	Email     string
+	Token     string
+	VerifyURL string
+	ExpiresAt time.Time
+}
+
+// GetAccountHandler handles GET /protected/account
+func GetAccountHandler(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	account := AccountResponse{
+		ID:                user.ID,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerifiedAt != nil || gatewayManaged(c),
+		Admin:             user.IsAdmin,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+		PasswordChangedAt: user.PasswordChangedAt,
+		GatewayManaged:    gatewayManaged(c),
+	}
+	if user.PendingEmail != "" && user.PendingEmailExpiresAt != nil && user.PendingEmailExpiresAt.After(time.Now()) {
+		account.PendingEmail, account.PendingEmailExpiresAt = user.PendingEmail, user.PendingEmailExpiresAt
+	}
+
+	var lastLogin struct{ At *time.Time }
+	if err := db.FromContext(c).Model(&models.UserSession{}).Select("MAX(created_at) AS at").Where("user_id = ?", user.ID).
+		Scan(&lastLogin).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch sessions: %v", err))
+	}
+	account.LastLoginAt = lastLogin.At
+	if err := db.FromContext(c).Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Count(&account.ActiveSessions).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch sessions: %v", err))
+	}
+	if err := db.FromContext(c).Table("team_members").Where("user_id = ?", user.ID).Count(&account.Teams).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch teams: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
+
+// ChangePasswordHandler handles PUT /protected/account/password. Every other session of the user is revoked, the
+// one making the request stays signed in.
+func ChangePasswordHandler(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if gatewayManaged(c) {
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "The password of this account is managed by the SSO gateway")
+	}
+
+	req := new(ChangePasswordRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if !CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		log.Printf("audit: password change rejected for %s from %s: wrong current password", user.Email, c.RealIP())
+		return util.Problem(c, http.StatusUnauthorized, util.ErrInvalidCredentials, "Current password is incorrect")
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, fmt.Sprintf("new_password must be at least %d characters", minPasswordLength))
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "new_password must differ from the current password")
+	}
+
+	hash, err := HashPassword(req.NewPassword)
+	if err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to hash password")
+	}
+	if err := db.FromContext(c).Model(user).Updates(map[string]any{"password_hash": hash, "password_changed_at": time.Now()}).Error; err != nil {
+		log.Printf("Error changing password of user %s: %v", user.Email, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to change password")
+	}
+	currentID, _ := currentSessionID(c)
+	revoked, err := revokeUserSessions(db.FromContext(c), user.ID, currentID)
+	if err != nil {
+		log.Printf("Error revoking sessions of user %s: %v", user.Email, err)
+	}
+
+	log.Printf("audit: user %s changed their password from %s, %d other session(s) revoked", user.Email, c.RealIP(), revoked)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ChangeEmailHandler handles POST /protected/account/email, mailing a confirmation token to the new address. The
+// email only changes once the token is confirmed through POST /account/verify_email; asking again replaces the
+// pending change.
+func ChangeEmailHandler(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if gatewayManaged(c) {
+		return util.Problem(c, http.StatusForbidden, util.ErrForbidden, "The email of this account is managed by the SSO gateway")
+	}
+	if !mailer.Enabled() {
+		return util.Problem(c, http.StatusServiceUnavailable, "", "Email delivery is not configured, the new address can't be verified")
+	}
+
+	req := new(ChangeEmailRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	if !CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		log.Printf("audit: email change rejected for %s from %s: wrong current password", user.Email, c.RealIP())
+		return util.Problem(c, http.StatusUnauthorized, util.ErrInvalidCredentials, "Current password is incorrect")
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "email must be a valid email address")
+	}
+	email := address.Address
+	if strings.EqualFold(email, user.Email) {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "email is already the address of this account")
+	}
+	if taken, err := emailTaken(db.FromContext(c), email, user.ID); err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to check email: %v", err))
+	} else if taken {
+		return util.Problem(c, http.StatusConflict, util.ErrUserExists, "User with this email already exists")
+	}
+
+	token, expiresAt, err := issueEmailToken(db.FromContext(c), user, email)
+	if err != nil {
+		log.Printf("Error saving email change of user %s: %v", user.Email, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to save email change")
+	}
+
+	data := EmailChangeEmail{CurrentEmail: user.Email, NewEmail: email, Token: token, VerifyURL: emailVerifyURL(token), ExpiresAt: expiresAt}
+	if err := mailer.Send([]string{email}, "Confirm your new email address", mailer.TemplateEmail, data); err != nil {
+		log.Printf("Error emailing email change confirmation of user %s: %v", user.Email, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to send confirmation email")
+	}
+
+	log.Printf("audit: user %s requested an email change to %s from %s", user.Email, email, c.RealIP())
+	return c.JSON(http.StatusAccepted, map[string]any{"pending_email": email, "pending_email_expires_at": expiresAt})
+}
+
+// CancelEmailChangeHandler handles DELETE /protected/account/email, dropping a pending email change
+func CancelEmailChangeHandler(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if err := db.FromContext(c).Model(user).Updates(map[string]any{
+		"pending_email": "", "email_verification_hash": "", "pending_email_expires_at": nil,
+	}).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to cancel email change")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SendEmailVerificationHandler handles POST /protected/account/verify_email, mailing a token confirming the address
+// of the account, e.g. for accounts registered before verification or whose token expired. It replaces a pending
+// email change.
+func SendEmailVerificationHandler(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil || gatewayManaged(c) {
+		return util.Problem(c, http.StatusConflict, util.ErrConflict, "The email of this account is already verified")
+	}
+	if !mailer.Enabled() {
+		return util.Problem(c, http.StatusServiceUnavailable, "", "Email delivery is not configured, the address can't be verified")
+	}
+	expiresAt, err := sendEmailVerification(db.FromContext(c), user)
+	if err != nil {
+		log.Printf("Error emailing address verification of user %s: %v", user.Email, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to send verification email")
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"email": user.Email, "expires_at": expiresAt})
+}
+
+// sendEmailVerification mails the user a token confirming their current address
+func sendEmailVerification(tx *gorm.DB, user *models.User) (time.Time, error) {
+	token, expiresAt, err := issueEmailToken(tx, user, user.Email)
+	if err != nil {
+		return time.Time{}, err
+	}
+	data := EmailVerificationEmail{Email: user.Email, Token: token, VerifyURL: emailVerifyURL(token), ExpiresAt: expiresAt}
+	return expiresAt, mailer.Send([]string{user.Email}, "Confirm your email address", mailer.TemplateVerify, data)
+}
+
+// VerifyEmailHandler handles POST /account/verify_email, confirming the address of the account or an email change
+// with the mailed token. It's public, as the link may be opened on another device. On an email change the user's
+// sessions are revoked, so tokens carrying the old address stop working and the user logs in again with the new one.
+func VerifyEmailHandler(c echo.Context) error {
+	req := new(VerifyEmailRequest)
+	if err := c.Bind(req); err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
+	}
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "token is required")
+	}
+
+	var user models.User
+	var oldEmail string
+	err := db.FromContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("email_verification_hash = ? AND pending_email_expires_at > ?", hashEmailToken(token), time.Now()).
+			First(&user).Error; err != nil {
+			return err
+		}
+		oldEmail = user.Email
+		if user.PendingEmail == user.Email {
+			if err := tx.Model(&user).Updates(map[string]any{
+				"email_verified_at": time.Now(), "pending_email": "", "email_verification_hash": "", "pending_email_expires_at": nil,
+			}).Error; err != nil {
+				return err
+			}
+			return syncAdmin(tx, user.ID)
+		}
+		if taken, err := emailTaken(tx, user.PendingEmail, user.ID); err != nil {
+			return err
+		} else if taken {
+			return gorm.ErrDuplicatedKey
+		}
+		if err := tx.Model(&user).Updates(map[string]any{
+			"email": user.PendingEmail, "email_verified_at": time.Now(),
+			"pending_email": "", "email_verification_hash": "", "pending_email_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return syncAdmin(tx, user.ID)
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Invalid or expired token")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return util.Problem(c, http.StatusConflict, util.ErrUserExists, "User with this email already exists")
+	case err != nil:
+		log.Printf("Error confirming email change: %v", err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to confirm email change")
+	}
+	if user.Email == oldEmail {
+		log.Printf("audit: user %s verified their email from %s", user.Email, c.RealIP())
+		return c.JSON(http.StatusOK, map[string]string{"message": "Email verified", "email": user.Email})
+	}
+
+	revoked, err := revokeUserSessions(db.FromContext(c), user.ID, uuid.Nil)
+	if err != nil {
+		log.Printf("Error revoking sessions of user %s: %v", user.Email, err)
+	}
+	log.Printf("audit: user %s changed their email to %s from %s, %d session(s) revoked", oldEmail, user.Email, c.RealIP(), revoked)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Email changed, log in with the new address", "email": user.Email})
+}
+
+// currentUser loads the authenticated user
+func currentUser(c echo.Context) (*models.User, error) {
+	userID, err := TenantID(c)
+	if err != nil {
+		return nil, util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	var user models.User
+	if err := db.FromContext(c).First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Account no longer exists")
+		}
+		return nil, util.NewProblem(http.StatusInternalServerError, util.ErrInternal, "Failed to load account")
+	}
+	return &user, nil
+}
+
+// gatewayManaged reports whether the request was authenticated by the SSO gateway rather than a token
+func gatewayManaged(c echo.Context) bool {
+	return AuthMode != AuthModeJWT && headerIdentity(c) != ""
+}
+
+// emailTaken reports whether another user has the email, ignoring case as logins through the gateway do
+func emailTaken(tx *gorm.DB, email string, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", strings.ToLower(email), userID).Count(&count).Error
+	return count > 0, err
+}
+
+// issueEmailToken stores a new token confirming email for the user, replacing any pending one
+func issueEmailToken(tx *gorm.DB, user *models.User, email string) (string, time.Time, error) {
+	token, hash, err := newEmailToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(EmailChangeTTL)
+	if err := tx.Model(user).Updates(map[string]any{
+		"pending_email": email, "email_verification_hash": hash, "pending_email_expires_at": expiresAt,
+	}).Error; err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save email token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// emailVerifyURL returns the confirm link of a token, "" when EMAIL_VERIFY_URL isn't set
+func emailVerifyURL(token string) string {
+	if EmailVerifyURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(EmailVerifyURL, "{token}", token)
+}
+
+// EmailVerified reports whether the authenticated user confirmed owning their email. The SSO gateway vouches for
+// the addresses of the users it signs in.
+func EmailVerified(c echo.Context) (bool, error) {
+	if gatewayManaged(c) {
+		return true, nil
+	}
+	user, err := currentUser(c)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+// newEmailToken returns a confirmation token and the hash stored in its place
+func newEmailToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+	return token, hashEmailToken(token), nil
+}
+
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
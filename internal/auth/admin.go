@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Operators run the instance: the /admin routes, channel removal and report imports. They are the users whose
+// verified email is on ADMIN_EMAILS; an unverified account doesn't qualify, as anyone can register any address.
+var AdminEmails = parseAdminEmails(os.Getenv("ADMIN_EMAILS"))
+
+func parseAdminEmails(raw string) []string {
+	var emails []string
+	for _, email := range strings.Split(raw, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// SyncAdmins sets is_admin of every user from ADMIN_EMAILS, granting and revoking the operator role
+func SyncAdmins(conn *gorm.DB) error {
+	result := syncAdmins(conn, "TRUE")
+	if result.Error != nil {
+		return fmt.Errorf("failed to sync operator accounts: %w", result.Error)
+	}
+	var admins int64
+	if err := conn.Model(&models.User{}).Where("is_admin").Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to count operator accounts: %w", err)
+	}
+	if len(AdminEmails) > 0 && admins < int64(len(AdminEmails)) {
+		log.Printf("%d of %d ADMIN_EMAILS have a verified account, the others become operators once they verify their email", admins, len(AdminEmails))
+	} else if len(AdminEmails) == 0 {
+		log.Printf("Warning: ADMIN_EMAILS is not set, nobody can use the /admin routes")
+	}
+	return nil
+}
+
+// syncAdmin updates is_admin of one user, after their email or its verification changed
+func syncAdmin(tx *gorm.DB, userID uuid.UUID) error {
+	return syncAdmins(tx, "id = ?", userID).Error
+}
+
+func syncAdmins(tx *gorm.DB, where string, args ...any) *gorm.DB {
+	if len(AdminEmails) == 0 {
+		return tx.Exec("UPDATE users SET is_admin = false WHERE is_admin AND "+where, args...)
+	}
+	return tx.Exec("UPDATE users SET is_admin = (LOWER(email) IN ? AND email_verified_at IS NOT NULL) WHERE "+where,
+		append([]any{AdminEmails}, args...)...)
+}
+
+// markGatewayVerified records that the SSO gateway vouched for the email of a user, which may make them an operator
+func markGatewayVerified(tx *gorm.DB, user models.User) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("email_verified_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return syncAdmin(tx, user.ID)
+	})
+}
+
+// RequireAdmin returns middleware rejecting users who aren't operators with 403. It goes after AuthMiddleware.
+func RequireAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := currentUser(c)
+			if err != nil {
+				return err
+			}
+			if !user.IsAdmin {
+				log.Printf("audit: rejected %s %s by non-operator %s from %s", c.Request().Method, c.Path(), user.Email, c.RealIP())
+				return util.NewProblem(http.StatusForbidden, util.ErrForbidden, "This route is reserved for the operators of the instance")
+			}
+			return next(c)
+		}
+	}
+}
+
+// IsAdmin reports whether the authenticated user is an operator
+func IsAdmin(c echo.Context) bool {
+	user, err := currentUser(c)
+	return err == nil && user.IsAdmin
+}
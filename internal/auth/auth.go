@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/mailer"
 	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
 	"log"
 	"net/http"
 	"os"
@@ -27,13 +29,34 @@ type JwtCustomClaims struct {
 
 var jwtSecret []byte // Stores the JWT secret key as a byte slice
 
-// InitAuth initializes the authentication system by loading the JWT secret.
-func InitAuth() {
+// InitAuth initializes the authentication system by loading the JWT secret and the trusted-header settings, and
+// syncs the operator accounts of conn.
+func InitAuth(conn *gorm.DB) {
+	if err := initHeaderAuth(); err != nil {
+		log.Fatalf("Invalid authentication settings: %v", err)
+	}
+
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		log.Fatal("JWT_SECRET environment variable not set. Please configure it.")
 	}
 	jwtSecret = []byte(secret) // Convert string secret to byte slice
+
+	if err := SyncAdmins(conn); err != nil {
+		log.Printf("Error: %v", err)
+	}
+}
+
+// ValidateConfig checks the settings InitAuth reads without applying them
+func ValidateConfig() []error {
+	var errs []error
+	if os.Getenv("JWT_SECRET") == "" {
+		errs = append(errs, errors.New("JWT_SECRET is not set"))
+	}
+	if err := checkHeaderAuth(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
 }
 
 // HashPassword hashes a plain-text password using bcrypt.
@@ -51,15 +74,19 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil // Returns true if passwords match, false otherwise
 }
 
-// GenerateToken generates a JWT token for a given user.
-func GenerateToken(user *models.User) (string, error) {
+// TokenTTL is how long issued tokens, and the sessions behind them, stay valid
+const TokenTTL = 72 * time.Hour
+
+// GenerateToken generates a JWT token for a given user session.
+func GenerateToken(user *models.User, session *models.UserSession) (string, error) {
 	claims := &JwtCustomClaims{
 		ID:    user.ID.String(),
 		Email: user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 72)), // Token valid for 72 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()), // Token is valid immediately
+			ID:        session.ID.String(), // jti, checked against revoked sessions
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(session.CreatedAt),
+			NotBefore: jwt.NewNumericDate(session.CreatedAt), // Token is valid immediately
 		},
 	}
 
@@ -86,18 +113,18 @@ type RegisterRequest struct {
 func RegisterHandler(c echo.Context) error {
 	req := new(RegisterRequest)
 	if err := c.Bind(req); err != nil { // Bind request body to struct
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
 	}
 
 	// Basic input validation
 	if req.Email == "" || req.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Email and password are required"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Email and password are required")
 	}
 
 	// Hash the user's password
 	hashedPassword, err := HashPassword(req.Password)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to hash password"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to hash password")
 	}
 
 	// Create a new user model
@@ -108,17 +135,32 @@ func RegisterHandler(c echo.Context) error {
 	}
 
 	// Save the user to the database
-	if err := db.DB.Create(&user).Error; err != nil {
+	if err := db.FromContext(c).Create(&user).Error; err != nil {
 		// Check for unique constraint violation (email must be unique)
 		if errors.Is(err, gorm.ErrDuplicatedKey) { // This correctly checks for unique constraint violation
-			return c.JSON(http.StatusConflict, map[string]string{"message": "User with this email already exists"})
+			log.Printf("audit: registration rejected for %s from %s: email already registered", req.Email, c.RealIP())
+			return util.Problem(c, http.StatusConflict, util.ErrUserExists, "User with this email already exists")
 		}
 		log.Printf("Database error during user registration: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to register user"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to register user")
+	}
+
+	log.Printf("audit: user %s registered from %s", user.Email, c.RealIP())
+
+	// Team invites go by email, so they wait until the address is confirmed
+	verificationSent := false
+	if mailer.Enabled() {
+		if _, err := sendEmailVerification(db.FromContext(c), &user); err != nil {
+			log.Printf("Error emailing address verification of user %s: %v", user.Email, err)
+		} else {
+			verificationSent = true
+		}
 	}
 
 	// Return success response
-	return c.JSON(http.StatusCreated, map[string]string{"message": "User registered successfully", "id": user.ID.String()})
+	return c.JSON(http.StatusCreated, map[string]any{
+		"message": "User registered successfully", "id": user.ID.String(), "email_verification_sent": verificationSent,
+	})
 }
 
 // LoginRequest represents the request body for user login.
@@ -131,49 +173,109 @@ type LoginRequest struct {
 func LoginHandler(c echo.Context) error {
 	req := new(LoginRequest)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrInvalidRequestBody, "Invalid request body")
 	}
 
 	if req.Email == "" || req.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Email and password are required"})
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Email and password are required")
 	}
 
 	// Find the user by email
 	var user models.User
-	if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := db.FromContext(c).Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid credentials"}) // User not found
+			log.Printf("audit: failed login for unknown user %s from %s", req.Email, c.RealIP())
+			return util.Problem(c, http.StatusUnauthorized, util.ErrInvalidCredentials, "Invalid credentials") // User not found
 		}
 		log.Printf("Database error during user login: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Database error"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Database error")
 	}
 
 	// Check if the provided password matches the stored hash
 	if !CheckPasswordHash(req.Password, user.PasswordHash) {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid credentials"}) // Password mismatch
+		log.Printf("audit: failed login for %s from %s: wrong password", user.Email, c.RealIP())
+		return util.Problem(c, http.StatusUnauthorized, util.ErrInvalidCredentials, "Invalid credentials") // Password mismatch
+	}
+
+	session, err := createSession(&user, c)
+	if err != nil {
+		log.Printf("Error creating session for user %s: %v", user.Email, err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to create session")
 	}
 
 	// Generate a JWT token
-	token, err := GenerateToken(&user)
+	token, err := GenerateToken(&user, session)
 	if err != nil {
 		log.Printf("Error generating token for user %s: %v", user.Email, err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate token"})
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to generate token")
 	}
 
+	log.Printf("audit: user %s logged in from %s", user.Email, c.RealIP())
+
 	// Return success response with the token
 	return c.JSON(http.StatusOK, map[string]string{"message": "Login successful", "token": token})
 }
 
-// AuthMiddleware provides JWT authentication middleware for Echo.
+// AuthMiddleware authenticates requests according to AUTH_MODE: JWTs of sessions that weren't revoked, identity
+// headers of a trusted SSO gateway, or either.
 func AuthMiddleware() echo.MiddlewareFunc {
-	return echojwt.WithConfig(echojwt.Config{
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
 		SigningKey:  jwtSecret,
 		TokenLookup: "header:Authorization:Bearer ",
 		ErrorHandler: func(c echo.Context, err error) error {
-			log.Printf("JWT authentication error: %v", err)
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token. Please log in again.")
+			log.Printf("JWT authentication error from %s: %v", c.RealIP(), err)
+			return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Invalid or expired token. Please log in again.")
 		},
 		ContextKey: "user",
-		Skipper:    nil,
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(JwtCustomClaims)
+		},
+		Skipper: nil,
 	})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtMiddleware(requireActiveSession(next))
+		if AuthMode == AuthModeJWT {
+			return withJWT
+		}
+		return func(c echo.Context) error {
+			email := headerIdentity(c)
+			if email == "" {
+				if AuthMode == AuthModeBoth {
+					return withJWT(c)
+				}
+				return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Sign in through the SSO gateway")
+			}
+			if err := authenticateHeader(c, email); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// CurrentUserClaims returns the JWT claims of the authenticated user for the request.
+func CurrentUserClaims(c echo.Context) (*JwtCustomClaims, error) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil {
+		return nil, errors.New("no authenticated user in context")
+	}
+	claims, ok := token.Claims.(*JwtCustomClaims)
+	if !ok {
+		return nil, errors.New("unexpected JWT claims type")
+	}
+	return claims, nil
+}
+
+// TenantID returns the identifier that usage and quotas are scoped to for the request.
+// Until organizations exist every user is their own tenant.
+func TenantID(c echo.Context) (uuid.UUID, error) {
+	claims, err := CurrentUserClaims(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user id in token: %w", err)
+	}
+	return id, nil
 }
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Authentication modes (AUTH_MODE)
+const (
+	AuthModeJWT    = "jwt"    // Bearer tokens issued by /login
+	AuthModeHeader = "header" // Identity headers set by a trusted SSO gateway such as oauth2-proxy or Authentik
+	AuthModeBoth   = "both"   // Identity headers when a trusted proxy sent them, bearer tokens otherwise
+)
+
+// Trusted-header authentication trusts the identity headers of requests coming straight from one of the
+// AUTH_TRUSTED_PROXIES; the headers of any other peer are ignored, as clients could set them themselves.
+var (
+	AuthMode            = strings.ToLower(util.GetEnvString("AUTH_MODE", AuthModeJWT))
+	AuthHeaderEmail     = util.GetEnvString("AUTH_HEADER_EMAIL", "X-Auth-Request-Email")
+	AuthHeaderGroups    = util.GetEnvString("AUTH_HEADER_GROUPS", "X-Auth-Request-Groups") // Comma separated
+	AuthHeaderProvision = util.GetEnvBool("AUTH_HEADER_AUTO_PROVISION", true)              // Create local users for unknown emails
+)
+
+var (
+	trustedProxies []*net.IPNet
+	groupTeamRoles map[string][]groupTeamRole // SSO group -> team roles granted to its members
+)
+
+// groupTeamRole is a team role granted to the members of an SSO group (AUTH_HEADER_TEAM_ROLES)
+type groupTeamRole struct {
+	TeamID uuid.UUID
+	Role   string
+}
+
+// headerUserCacheTTL is how long a header identity's local user is trusted before it is looked up (and its team
+// roles synced) again
+const headerUserCacheTTL = 5 * time.Minute
+
+type headerUser struct {
+	UserID   uuid.UUID
+	Groups   string
+	LoadedAt time.Time
+}
+
+var headerUsers sync.Map // map[string]headerUser, keyed by lowercased email
+
+// initHeaderAuth validates and applies the trusted-header settings
+func initHeaderAuth() error {
+	if AuthMode == AuthModeJWT {
+		return nil
+	}
+	var err error
+	if trustedProxies, groupTeamRoles, err = headerAuthSettings(); err != nil {
+		return err
+	}
+	log.Printf("Trusted-header authentication enabled (mode %s): %s from %d trusted proxy network(s)", AuthMode, AuthHeaderEmail, len(trustedProxies))
+	return nil
+}
+
+// checkHeaderAuth validates the trusted-header settings without applying them
+func checkHeaderAuth() error {
+	if AuthMode == AuthModeJWT {
+		return nil
+	}
+	_, _, err := headerAuthSettings()
+	return err
+}
+
+func headerAuthSettings() ([]*net.IPNet, map[string][]groupTeamRole, error) {
+	switch AuthMode {
+	case AuthModeHeader, AuthModeBoth:
+	default:
+		return nil, nil, fmt.Errorf("AUTH_MODE must be %s, %s or %s, got %q", AuthModeJWT, AuthModeHeader, AuthModeBoth, AuthMode)
+	}
+	proxies, err := parseTrustedProxies(util.GetEnvString("AUTH_TRUSTED_PROXIES", "127.0.0.1/32,::1/128"))
+	if err != nil {
+		return nil, nil, err
+	}
+	roles, err := parseGroupTeamRoles(os.Getenv("AUTH_HEADER_TEAM_ROLES"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return proxies, roles, nil
+}
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDRs
+func parseTrustedProxies(raw string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid AUTH_TRUSTED_PROXIES entry %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_TRUSTED_PROXIES entry %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	if len(networks) == 0 {
+		return nil, errors.New("AUTH_TRUSTED_PROXIES must list at least one proxy address")
+	}
+	return networks, nil
+}
+
+// parseGroupTeamRoles parses AUTH_HEADER_TEAM_ROLES ("group=team-id:role,group=team-id:role")
+func parseGroupTeamRoles(raw string) (map[string][]groupTeamRole, error) {
+	roles := make(map[string][]groupTeamRole)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, grant, ok := strings.Cut(pair, "=")
+		teamRaw, role, ok2 := strings.Cut(grant, ":")
+		if !ok || !ok2 || strings.TrimSpace(group) == "" {
+			return nil, fmt.Errorf("invalid AUTH_HEADER_TEAM_ROLES entry %q, expected group=team-id:role", pair)
+		}
+		teamID, err := uuid.Parse(strings.TrimSpace(teamRaw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid team ID in AUTH_HEADER_TEAM_ROLES entry %q: %w", pair, err)
+		}
+		role = strings.TrimSpace(role)
+		if _, known := monitor.TeamRoleRank[role]; !known {
+			return nil, fmt.Errorf("unknown role %q in AUTH_HEADER_TEAM_ROLES entry %q", role, pair)
+		}
+		group = strings.TrimSpace(group)
+		roles[group] = append(roles[group], groupTeamRole{TeamID: teamID, Role: role})
+	}
+	return roles, nil
+}
+
+// fromTrustedProxy reports whether the request's peer, not the address it claims to forward for, is a trusted proxy
+func fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerIdentity returns the email the SSO gateway authenticated the request as, empty when it carries none or
+// didn't come through a trusted proxy
+func headerIdentity(c echo.Context) string {
+	email := strings.TrimSpace(c.Request().Header.Get(AuthHeaderEmail))
+	if email == "" {
+		return ""
+	}
+	if !fromTrustedProxy(c.Request()) {
+		log.Printf("audit: ignored %s header from untrusted peer %s", AuthHeaderEmail, c.Request().RemoteAddr)
+		return ""
+	}
+	return email
+}
+
+// authenticateHeader maps the gateway identity to its local user and stores claims like the JWT middleware does, so
+// handlers read the user with CurrentUserClaims whatever the mode
+func authenticateHeader(c echo.Context, email string) error {
+	groups := c.Request().Header.Get(AuthHeaderGroups)
+	userID, err := headerUserID(db.FromContext(c), email, groups)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("audit: rejected SSO identity %s from %s: no local user", email, c.RealIP())
+		return util.NewProblem(http.StatusForbidden, util.ErrForbidden, "No local account for this identity")
+	}
+	if err != nil {
+		log.Printf("Error resolving SSO identity %s: %v", email, err)
+		return util.NewProblem(http.StatusInternalServerError, util.ErrInternal, "Failed to resolve identity")
+	}
+
+	claims := &JwtCustomClaims{ID: userID.String(), Email: email}
+	c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+	return nil
+}
+
+// headerUserID returns the local user of an email, provisioning it and syncing its team roles when it isn't cached
+func headerUserID(tx *gorm.DB, email, groups string) (uuid.UUID, error) {
+	key := strings.ToLower(email)
+	if cached, ok := headerUsers.Load(key); ok {
+		user := cached.(headerUser)
+		if user.Groups == groups && time.Since(user.LoadedAt) < headerUserCacheTTL {
+			return user.UserID, nil
+		}
+	}
+
+	var user models.User
+	err := tx.Where("LOWER(email) = ?", key).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && AuthHeaderProvision {
+		user, err = provisionHeaderUser(tx, email)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		if err := markGatewayVerified(tx, user); err != nil {
+			log.Printf("Error marking the email of %s verified: %v", email, err)
+		}
+	}
+	if err := grantGroupTeamRoles(tx, user.ID, groups); err != nil {
+		log.Printf("Error syncing team roles of %s: %v", email, err)
+	}
+
+	headerUsers.Store(key, headerUser{UserID: user.ID, Groups: groups, LoadedAt: time.Now()})
+	return user.ID, nil
+}
+
+// provisionHeaderUser creates the local user of an SSO identity. Its password is random and never revealed, so
+// the account can only be used through the gateway.
+func provisionHeaderUser(tx *gorm.DB, email string) (models.User, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.User{}, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hash, err := HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{ID: uuid.New(), Email: email, PasswordHash: hash}
+	if err := tx.Create(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// Provisioned concurrently by another request
+			return user, tx.Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error
+		}
+		return models.User{}, fmt.Errorf("failed to provision user %s: %w", email, err)
+	}
+	log.Printf("audit: provisioned user %s for SSO identity", email)
+	return user, nil
+}
+
+// grantGroupTeamRoles gives the user the team roles mapped to its SSO groups. Roles are only ever raised: a
+// membership the user already holds with a higher role is kept, and leaving a group doesn't revoke anything.
+func grantGroupTeamRoles(tx *gorm.DB, userID uuid.UUID, groups string) error {
+	if len(groupTeamRoles) == 0 {
+		return nil
+	}
+
+	granted := make(map[uuid.UUID]string)
+	for _, group := range strings.Split(groups, ",") {
+		for _, grant := range groupTeamRoles[strings.TrimSpace(group)] {
+			if monitor.TeamRoleRank[grant.Role] > monitor.TeamRoleRank[granted[grant.TeamID]] {
+				granted[grant.TeamID] = grant.Role
+			}
+		}
+	}
+
+	for teamID, role := range granted {
+		var existing models.TeamMember
+		err := tx.Where("team_id = ? AND user_id = ?", teamID, userID).First(&existing).Error
+		if err == nil && monitor.TeamRoleRank[existing.Role] >= monitor.TeamRoleRank[role] {
+			continue
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).Create(&models.TeamMember{TeamID: teamID, UserID: userID, Role: role}).Error; err != nil {
+			return fmt.Errorf("failed to grant %s role in team %s: %w", role, teamID.String(), err)
+		}
+		log.Printf("audit: granted user %s the %s role in team %s from SSO groups", userID.String(), role, teamID.String())
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// sessionCheckInterval is how long a session is trusted before it is checked again, so a session revoked
+// through another instance stops working within this long. Checking also bumps the session's last_seen_at.
+const sessionCheckInterval = 30 * time.Second
+
+// sessionPruneInterval is how often the cache drops states it no longer needs: active ones due for a check, which
+// the database would be asked about again anyway, and revoked ones older than TokenTTL, whose tokens have expired
+const sessionPruneInterval = 10 * time.Minute
+
+const maxUserAgentLength = 512
+
+type sessionState struct {
+	Active    bool
+	CheckedAt time.Time
+}
+
+var (
+	sessionStates   sync.Map     // map[uuid.UUID]sessionState
+	sessionsPruneAt atomic.Int64 // Unix nanoseconds of the next prune
+)
+
+// SessionResponse is a session as listed to its user
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // The session the request was made with
+}
+
+// createSession records a new login of user from the requesting client
+func createSession(user *models.User, c echo.Context) (*models.UserSession, error) {
+	now := time.Now()
+	userAgent := c.Request().UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session := models.UserSession{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		IP:         c.RealIP(),
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(TokenTTL),
+	}
+	if err := db.FromContext(c).Create(&session).Error; err != nil {
+		return nil, err
+	}
+	sessionStates.Store(session.ID, sessionState{Active: true, CheckedAt: now})
+	return &session, nil
+}
+
+// requireActiveSession rejects tokens whose session was revoked. Tokens issued before sessions existed carry no
+// session ID and stay valid until they expire.
+func requireActiveSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sessionID, ok := currentSessionID(c)
+		if !ok {
+			return next(c)
+		}
+
+		active, err := isSessionActive(db.FromContext(c), sessionID)
+		if err != nil {
+			log.Printf("Error checking session %s: %v", sessionID.String(), err)
+			return util.NewProblem(http.StatusInternalServerError, util.ErrInternal, "Failed to check session")
+		}
+		if !active {
+			log.Printf("audit: rejected token of revoked session %s from %s", sessionID.String(), c.RealIP())
+			return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Session has been revoked. Please log in again.")
+		}
+		return next(c)
+	}
+}
+
+// currentSessionID returns the session ID (jti) of the request's token, if it has one
+func currentSessionID(c echo.Context) (uuid.UUID, bool) {
+	claims, err := CurrentUserClaims(c)
+	if err != nil || claims.RegisteredClaims.ID == "" {
+		return uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(claims.RegisteredClaims.ID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+func isSessionActive(tx *gorm.DB, sessionID uuid.UUID) (bool, error) {
+	pruneSessionStates()
+	if cached, ok := sessionStates.Load(sessionID); ok {
+		state := cached.(sessionState)
+		if !state.Active || time.Since(state.CheckedAt) < sessionCheckInterval {
+			return state.Active, nil
+		}
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, now).
+		Update("last_seen_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	active := result.RowsAffected > 0
+	sessionStates.Store(sessionID, sessionState{Active: active, CheckedAt: now})
+	return active, nil
+}
+
+// pruneSessionStates drops the cached states that can't save a database check anymore, at most once per
+// sessionPruneInterval, so the cache doesn't grow with every session ever seen
+func pruneSessionStates() {
+	now := time.Now()
+	next := sessionsPruneAt.Load()
+	if now.UnixNano() < next || !sessionsPruneAt.CompareAndSwap(next, now.Add(sessionPruneInterval).UnixNano()) {
+		return
+	}
+	sessionStates.Range(func(key, value any) bool {
+		state := value.(sessionState)
+		if (state.Active && now.Sub(state.CheckedAt) >= sessionCheckInterval) || now.Sub(state.CheckedAt) >= TokenTTL {
+			sessionStates.Delete(key)
+		}
+		return true
+	})
+}
+
+// revokeUserSessions revokes every active session of a user but except, and returns how many it revoked
+func revokeUserSessions(tx *gorm.DB, userID, except uuid.UUID) (int, error) {
+	var sessionIDs []uuid.UUID
+	if err := tx.Model(&models.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, except, time.Now()).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(sessionIDs) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	if err := tx.Model(&models.UserSession{}).Where("id IN ?", sessionIDs).Update("revoked_at", now).Error; err != nil {
+		return 0, err
+	}
+	for _, sessionID := range sessionIDs {
+		sessionStates.Store(sessionID, sessionState{Active: false, CheckedAt: now})
+	}
+	return len(sessionIDs), nil
+}
+
+// ListSessionsHandler handles GET /protected/sessions, listing the active sessions of the authenticated user
+func ListSessionsHandler(c echo.Context) error {
+	userID, err := TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	currentID, _ := currentSessionID(c)
+
+	var sessions []models.UserSession
+	if err := db.FromContext(c).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch sessions: %v", err))
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokeSessionHandler handles DELETE /protected/sessions/:sessionID. Revoking the current session logs out.
+func RevokeSessionHandler(c echo.Context) error {
+	userID, err := TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		return util.Problem(c, http.StatusBadRequest, util.ErrValidationFailed, "Invalid session ID format")
+	}
+
+	var session models.UserSession
+	if err := db.FromContext(c).Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.Problem(c, http.StatusNotFound, util.ErrNotFound, "Session not found")
+		}
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch session: %v", err))
+	}
+
+	if err := db.FromContext(c).Model(&session).Update("revoked_at", time.Now()).Error; err != nil {
+		log.Printf("Error revoking session %s: %v", session.ID.String(), err)
+		return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to revoke session")
+	}
+	sessionStates.Store(session.ID, sessionState{Active: false, CheckedAt: time.Now()})
+
+	log.Printf("audit: session %s of user %s revoked from %s", session.ID.String(), userID.String(), c.RealIP())
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,242 @@
+package cluster
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/monitor"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	HeartbeatInterval = util.GetEnvDuration("CLUSTER_HEARTBEAT_INTERVAL", 15*time.Second)
+	InstanceTTL       = util.GetEnvDuration("CLUSTER_INSTANCE_TTL", 45*time.Second) // Instances silent for longer are considered gone
+)
+
+// rebalanceLockKey serialises rebalancing across instances via a Postgres advisory lock
+const rebalanceLockKey = 0x6b69636b // "kick"
+
+var (
+	instanceID string
+	enabled    bool
+
+	membersMu sync.RWMutex
+	members   []string // Sorted IDs of live instances
+)
+
+// Enabled reports whether channel sharding is active (CLUSTER_MODE=true).
+func Enabled() bool {
+	return enabled
+}
+
+// InstanceID returns the identifier of this instance in the cluster.
+func InstanceID() string {
+	return instanceID
+}
+
+// Init enables sharding when CLUSTER_MODE is set, registers this instance and installs the ownership filter.
+func Init() {
+	enabled = util.GetEnvBool("CLUSTER_MODE", false)
+	if !enabled {
+		return
+	}
+
+	instanceID = os.Getenv("INSTANCE_ID")
+	if instanceID == "" {
+		instanceID = uuid.New().String()
+	}
+
+	if err := heartbeat(); err != nil {
+		log.Fatalf("Failed to register cluster instance %s: %v", instanceID, err)
+	}
+	if err := refreshMembers(); err != nil {
+		log.Fatalf("Failed to load cluster members: %v", err)
+	}
+
+	monitor.OwnershipFilter = Owns
+	monitor.JobOwner = instanceID
+	monitor.Clustered = true
+	log.Printf("Cluster mode enabled. Instance %s joined with %d live instance(s).", instanceID, len(Members()))
+}
+
+// Members returns the sorted IDs of live instances.
+func Members() []string {
+	membersMu.RLock()
+	defer membersMu.RUnlock()
+	return append([]string(nil), members...)
+}
+
+// Owner returns the instance responsible for a channel using rendezvous (highest random weight) hashing,
+// which only moves the channels of an instance that joins or leaves.
+func Owner(channelID uint, instances []string) string {
+	var owner string
+	var best uint64
+	for _, id := range instances {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s:%d", id, channelID)
+		if weight := h.Sum64(); owner == "" || weight > best {
+			owner, best = id, weight
+		}
+	}
+	return owner
+}
+
+// Owns reports whether this instance is responsible for the channel.
+func Owns(channelID uint) bool {
+	if !enabled {
+		return true
+	}
+	return Owner(channelID, Members()) == instanceID
+}
+
+// Run keeps the heartbeat alive and re-balances channels as instances join or leave. It blocks until stop is closed.
+func Run(stop <-chan struct{}) {
+	if !enabled {
+		return
+	}
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	rebalance()
+	left := false
+	for {
+		select {
+		case <-stop:
+			if !left {
+				leave()
+			}
+			return
+		case <-ticker.C:
+			// A draining instance leaves the cluster so its peers take over its channels right away
+			if monitor.IsDraining() {
+				if !left {
+					leave()
+					left = true
+					log.Printf("Instance %s is draining, left the cluster", instanceID)
+				}
+				continue
+			}
+			if err := heartbeat(); err != nil {
+				log.Printf("Cluster heartbeat failed for instance %s: %v", instanceID, err)
+				continue
+			}
+			if err := refreshMembers(); err != nil {
+				log.Printf("Failed to refresh cluster members: %v", err)
+				continue
+			}
+			rebalance()
+		}
+	}
+}
+
+func heartbeat() error {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	instance := models.MonitorInstance{
+		ID:            instanceID,
+		Hostname:      hostname,
+		StartedAt:     now,
+		LastHeartbeat: now,
+	}
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_heartbeat", "hostname"}),
+	}).Create(&instance).Error
+}
+
+func refreshMembers() error {
+	var ids []string
+	if err := db.DB.Model(&models.MonitorInstance{}).
+		Where("last_heartbeat >= ?", time.Now().Add(-InstanceTTL)).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	sort.Strings(ids)
+
+	membersMu.Lock()
+	changed := !equalStrings(members, ids)
+	members = ids
+	membersMu.Unlock()
+
+	if changed {
+		log.Printf("Cluster membership changed: %d live instance(s)", len(ids))
+	}
+	return nil
+}
+
+// rebalance starts channels newly owned by this instance and stops the ones it no longer owns.
+func rebalance() {
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		log.Printf("Failed to begin rebalance transaction: %v", tx.Error)
+		return
+	}
+	defer tx.Rollback()
+
+	// Only one instance rebalances at a time; the lock is released with the transaction
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rebalanceLockKey).Error; err != nil {
+		log.Printf("Failed to acquire rebalance lock: %v", err)
+		return
+	}
+
+	var activeChannels []models.MonitoredChannel
+	if err := tx.Where("is_active = ?", true).Find(&activeChannels).Error; err != nil {
+		log.Printf("Failed to load active channels for rebalance: %v", err)
+		return
+	}
+
+	instances := Members()
+	owned := make(map[uint]struct{})
+	started, stopped := 0, 0
+	for _, channel := range activeChannels {
+		if Owner(channel.ChannelID, instances) != instanceID {
+			continue
+		}
+		owned[channel.ChannelID] = struct{}{}
+		if !monitor.IsMonitoring(channel.ChannelID) {
+			go monitor.StartMonitoringChannel(&channel)
+			started++
+		}
+	}
+
+	for _, id := range monitor.MonitoredChannelIDs() {
+		if _, ok := owned[id]; !ok {
+			monitor.StopMonitoringChannel(id)
+			stopped++
+		}
+	}
+
+	if started > 0 || stopped > 0 {
+		log.Printf("Rebalanced instance %s: started %d, stopped %d, owning %d channel(s)", instanceID, started, stopped, len(owned))
+	}
+}
+
+// leave removes this instance so peers pick up its channels on their next rebalance.
+func leave() {
+	if err := db.DB.Delete(&models.MonitorInstance{}, "id = ?", instanceID).Error; err != nil {
+		log.Printf("Failed to deregister cluster instance %s: %v", instanceID, err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
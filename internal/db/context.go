@@ -0,0 +1,26 @@
+package db
+
+import (
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const contextKey = "db"
+
+// Middleware makes conn the database of the requests it serves, which handlers read with FromContext
+func Middleware(conn *gorm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(contextKey, conn)
+			return next(c)
+		}
+	}
+}
+
+// FromContext returns the database of the request, DB when no Middleware set one
+func FromContext(c echo.Context) *gorm.DB {
+	if conn, ok := c.Get(contextKey).(*gorm.DB); ok && conn != nil {
+		return conn
+	}
+	return DB
+}
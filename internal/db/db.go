@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
-	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -14,7 +16,26 @@ import (
 
 var DB *gorm.DB
 
-func Init() {
+// ValidateConfig checks the DB_* settings Connect reads, so a missing one is reported by name instead of as a DSN
+// error after the connection retries
+func ValidateConfig() []error {
+	var errs []error
+	for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_NAME"} {
+		if os.Getenv(key) == "" {
+			errs = append(errs, fmt.Errorf("%s is not set", key))
+		}
+	}
+	if raw := os.Getenv("DB_PORT"); raw != "" {
+		if port, err := strconv.Atoi(raw); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("DB_PORT must be a port number, got %q", raw))
+		}
+	}
+	return errs
+}
+
+// Connect opens the database from the DB_* environment variables, applies pending migrations unless
+// MIGRATE_ON_START=false and verifies the schema. It doesn't set DB.
+func Connect() (*gorm.DB, error) {
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
 	dbUser := os.Getenv("DB_USER")
@@ -29,24 +50,37 @@ func Init() {
 		dbPort,
 	)
 
+	var conn *gorm.DB
 	var err error
 	for i := 0; i < 5; i++ { // Try up to 5 times
-		DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		conn, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 		if err == nil {
 			break // Connection successful
 		}
 		log.Printf("Attempt %d: Failed to connect to database: %v. Retrying in 5 seconds...", i+1, err)
 		time.Sleep(5 * time.Second)
 	}
-
 	if err != nil {
-		log.Fatalf("Exhausted retries: Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("exhausted retries: failed to connect to database: %w", err)
 	}
 
-	err = DB.AutoMigrate(&models.MonitoredChannel{}, &models.ChannelData{}, &models.LivestreamData{}, &models.ChatMessage{}, &models.LivestreamReport{}, &models.SpamReport{}, &models.StreamerProfile{}, &models.User{})
-	if err != nil {
-		log.Fatalf("Failed to auto-migrate database schema: %v", err)
+	// Versioned migrations replace AutoMigrate; MIGRATE_ON_START=false leaves applying them to the operator
+	ctx := context.Background()
+	if util.GetEnvBool("MIGRATE_ON_START", true) {
+		if err := Migrate(ctx, conn); err != nil {
+			return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+		}
+	}
+
+	// Refuse to run against a schema that doesn't match this build
+	if err := CheckSchema(ctx, conn); err != nil {
+		return nil, fmt.Errorf("database schema check failed: %w", err)
+	}
+
+	if err := useReadReplicas(conn); err != nil {
+		return nil, err
 	}
 
-	log.Println("Database connected and schema migrated.")
+	log.Println("Database connected and schema verified.")
+	return conn, nil
 }
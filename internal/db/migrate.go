@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// MigrationStatus describes a single versioned migration and whether it has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// SchemaModels lists every model whose table must match the migrations.
+var SchemaModels = []any{
+	&models.MonitoredChannel{}, &models.ChannelData{}, &models.LivestreamData{}, &models.ChatMessage{},
+	&models.LivestreamReport{}, &models.SpamReport{}, &models.StreamerProfile{}, &models.User{},
+	&models.QuotaUsage{}, &models.MonitorInstance{}, &models.FlaggedChatter{}, &models.ReportRecipient{},
+	&models.ReactionEvent{}, &models.CompetitorSet{}, &models.CompetitorSetMember{},
+	&models.ChatMessageCount{}, &models.UserSession{}, &models.KickClip{},
+	&models.JobLease{}, &models.ChatterListEntry{}, &models.Team{}, &models.TeamMember{},
+	&models.TeamInvite{}, &models.TeamChannel{}, &models.SpamIncident{},
+	&models.ChannelAlias{}, &models.ChatConnection{}, &models.ChatterBotScore{}, &models.LivestreamSimulcast{},
+	&models.DailyDigest{}, &models.ChatMessageArchive{}, &models.ModerationItem{}, &models.ChannelWebhook{},
+	&models.LivestreamWindowReport{}, &models.LiveReportSnapshot{},
+	&models.LiveAggregateSample{},
+}
+
+func newMigrationProvider(conn *gorm.DB) (*goose.Provider, error) {
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sql.DB from gorm: %w", err)
+	}
+	migrations, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	return goose.NewProvider(goose.DialectPostgres, sqlDB, migrations)
+}
+
+// Migrate applies all pending versioned migrations.
+func Migrate(ctx context.Context, conn *gorm.DB) error {
+	provider, err := newMigrationProvider(conn)
+	if err != nil {
+		return err
+	}
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	for _, r := range results {
+		log.Printf("Applied migration %d (%s) in %s", r.Source.Version, r.Source.Path, r.Duration)
+	}
+	return nil
+}
+
+// MigrationStatuses reports every known migration and whether it has been applied.
+func MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	provider, err := newMigrationProvider(DB)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	result := make([]MigrationStatus, 0, len(statuses))
+	for _, s := range statuses {
+		status := MigrationStatus{
+			Version: s.Source.Version,
+			Name:    s.Source.Path,
+			Applied: s.State == goose.StateApplied,
+		}
+		if status.Applied {
+			appliedAt := s.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database
+func SchemaVersion(ctx context.Context) (int64, error) {
+	provider, err := newMigrationProvider(DB)
+	if err != nil {
+		return 0, err
+	}
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read database schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema refuses to continue when the database is not exactly at the embedded migration version,
+// or when a model column is missing from its table (schema drift from manual changes).
+func CheckSchema(ctx context.Context, conn *gorm.DB) error {
+	provider, err := newMigrationProvider(conn)
+	if err != nil {
+		return err
+	}
+
+	dbVersion, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read database schema version: %w", err)
+	}
+
+	sources := provider.ListSources()
+	var latest int64
+	if len(sources) > 0 {
+		latest = sources[len(sources)-1].Version
+	}
+
+	if dbVersion > latest {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", dbVersion, latest)
+	}
+	if pending, err := provider.HasPending(ctx); err != nil {
+		return fmt.Errorf("failed to check pending migrations: %w", err)
+	} else if pending {
+		return fmt.Errorf("database schema version %d has pending migrations (latest %d); run migrations first", dbVersion, latest)
+	}
+
+	var missing []string
+	cache := &sync.Map{}
+	for _, model := range SchemaModels {
+		s, err := schema.Parse(model, cache, conn.NamingStrategy)
+		if err != nil {
+			return fmt.Errorf("failed to parse model schema: %w", err)
+		}
+		if !conn.Migrator().HasTable(s.Table) {
+			missing = append(missing, s.Table)
+			continue
+		}
+		for _, field := range s.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !conn.Migrator().HasColumn(model, field.DBName) {
+				missing = append(missing, s.Table+"."+field.DBName)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("schema drift detected, missing tables/columns: %v", missing)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+-- +goose Up
+-- Baseline schema previously created by GORM AutoMigrate. IF NOT EXISTS lets existing
+-- deployments adopt versioned migrations without recreating their tables.
+CREATE TABLE IF NOT EXISTS monitored_channels (
+    channel_id  BIGSERIAL PRIMARY KEY,
+    chatroom_id BIGINT UNIQUE,
+    username    TEXT NOT NULL UNIQUE,
+    is_active   BOOLEAN DEFAULT true,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS channel_data (
+    id         UUID PRIMARY KEY,
+    channel_id BIGINT NOT NULL,
+    data       JSONB,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS livestream_data (
+    channel_id            BIGINT NOT NULL,
+    livestream_id         BIGINT NOT NULL,
+    slug                  VARCHAR(255),
+    start_time            TIMESTAMPTZ,
+    session_title         VARCHAR(255),
+    viewer_count          BIGINT,
+    livestream_created_at TIMESTAMPTZ,
+    tags                  JSONB,
+    is_live               BOOLEAN,
+    duration              BIGINT,
+    lang_iso              VARCHAR(10),
+    created_at            TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (channel_id, livestream_id, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id                UUID PRIMARY KEY,
+    chatroom_id       BIGINT NOT NULL,
+    livestream_id     BIGINT,
+    sender_id         BIGINT NOT NULL,
+    sender_username   VARCHAR(255) NOT NULL,
+    event             VARCHAR(255) NOT NULL,
+    message           TEXT NOT NULL,
+    metadata          JSONB,
+    message_send_time TIMESTAMPTZ NOT NULL,
+    created_at        TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS livestream_reports (
+    id                      UUID PRIMARY KEY,
+    livestream_id           BIGINT NOT NULL,
+    title                   TEXT,
+    channel_id              BIGINT NOT NULL,
+    username                VARCHAR(255) NOT NULL,
+    report_start_time       TIMESTAMPTZ NOT NULL,
+    report_end_time         TIMESTAMPTZ NOT NULL,
+    duration_minutes        BIGINT NOT NULL,
+    average_viewers         BIGINT NOT NULL DEFAULT 0,
+    peak_viewers            BIGINT NOT NULL DEFAULT 0,
+    lowest_viewers          BIGINT NOT NULL DEFAULT 0,
+    engagement              DECIMAL NOT NULL DEFAULT 0.0,
+    hours_watched           DECIMAL NOT NULL DEFAULT 0.0,
+    total_messages          BIGINT NOT NULL DEFAULT 0,
+    unique_chatters         BIGINT NOT NULL DEFAULT 0,
+    messages_from_apps      BIGINT NOT NULL DEFAULT 0,
+    spam_report_id          UUID,
+    viewer_counts_timeline  JSONB,
+    message_counts_timeline JSONB,
+    created_at              TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS spam_reports (
+    id                            UUID PRIMARY KEY,
+    livestream_report_id          UUID NOT NULL,
+    channel_id                    BIGINT NOT NULL,
+    livestream_id                 BIGINT NOT NULL,
+    messages_with_emotes          BIGINT NOT NULL DEFAULT 0,
+    messages_multiple_emotes_only BIGINT NOT NULL DEFAULT 0,
+    duplicate_messages_count      BIGINT NOT NULL DEFAULT 0,
+    repetitive_phrases_count      BIGINT NOT NULL DEFAULT 0,
+    exact_duplicate_bursts        JSONB,
+    similar_message_bursts        JSONB,
+    suspicious_chatters           JSONB,
+    created_at                    TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS streamer_profiles (
+    channel_id           BIGINT PRIMARY KEY,
+    username             VARCHAR(255) NOT NULL,
+    verified             BOOLEAN NOT NULL DEFAULT false,
+    is_banned            BOOLEAN NOT NULL DEFAULT false,
+    vod_enabled          BOOLEAN NOT NULL DEFAULT false,
+    is_affiliate         BOOLEAN NOT NULL DEFAULT false,
+    subscription_enabled BOOLEAN NOT NULL DEFAULT false,
+    followers_count      JSONB,
+    livestreams          JSONB,
+    bio                  TEXT,
+    city                 VARCHAR(255),
+    state                VARCHAR(255),
+    tik_tok              VARCHAR(255),
+    country              VARCHAR(255),
+    discord              VARCHAR(255),
+    twitter              VARCHAR(255),
+    you_tube             VARCHAR(255),
+    facebook             VARCHAR(255),
+    instagram            VARCHAR(255),
+    profile_pic          TEXT,
+    created_at           TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id            UUID PRIMARY KEY,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS streamer_profiles;
+DROP TABLE IF EXISTS spam_reports;
+DROP TABLE IF EXISTS livestream_reports;
+DROP TABLE IF EXISTS chat_messages;
+DROP TABLE IF EXISTS livestream_data;
+DROP TABLE IF EXISTS channel_data;
+DROP TABLE IF EXISTS monitored_channels;
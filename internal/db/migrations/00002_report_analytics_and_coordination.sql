@@ -0,0 +1,67 @@
+-- +goose Up
+ALTER TABLE livestream_reports
+    ADD COLUMN IF NOT EXISTS raw_average_viewers        BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS raw_peak_viewers           BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS top_one_percent_share      DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS top_ten_percent_share      DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS chat_gini                  DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS vod_url                    TEXT,
+    ADD COLUMN IF NOT EXISTS vod_source_url             TEXT,
+    ADD COLUMN IF NOT EXISTS raw_viewer_counts_timeline JSONB;
+
+ALTER TABLE spam_reports
+    ADD COLUMN IF NOT EXISTS cross_user_copypasta JSONB;
+
+ALTER TABLE livestream_data
+    ADD COLUMN IF NOT EXISTS source VARCHAR(16) NOT NULL DEFAULT 'http';
+
+ALTER TABLE chat_messages
+    ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS quota_usages (
+    tenant_id    UUID NOT NULL,
+    metric       VARCHAR(64) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    count        BIGINT NOT NULL DEFAULT 0,
+    updated_at   TIMESTAMPTZ,
+    PRIMARY KEY (tenant_id, metric, period_start)
+);
+
+CREATE TABLE IF NOT EXISTS monitor_instances (
+    id             VARCHAR(64) PRIMARY KEY,
+    hostname       VARCHAR(255),
+    started_at     TIMESTAMPTZ NOT NULL,
+    last_heartbeat TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_monitor_instances_last_heartbeat ON monitor_instances (last_heartbeat);
+
+CREATE TABLE IF NOT EXISTS flagged_chatters (
+    channel_id      BIGINT NOT NULL,
+    sender_username VARCHAR(255) NOT NULL,
+    reason          TEXT,
+    flagged_by      VARCHAR(255),
+    created_at      TIMESTAMPTZ,
+    PRIMARY KEY (channel_id, sender_username)
+);
+
+-- Speeds up the flagged message query endpoint
+CREATE INDEX IF NOT EXISTS idx_chat_messages_flagged ON chat_messages (chatroom_id, message_send_time) WHERE flagged;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_chat_messages_flagged;
+DROP TABLE IF EXISTS flagged_chatters;
+DROP TABLE IF EXISTS monitor_instances;
+DROP TABLE IF EXISTS quota_usages;
+
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS flagged;
+ALTER TABLE livestream_data DROP COLUMN IF EXISTS source;
+ALTER TABLE spam_reports DROP COLUMN IF EXISTS cross_user_copypasta;
+ALTER TABLE livestream_reports
+    DROP COLUMN IF EXISTS raw_viewer_counts_timeline,
+    DROP COLUMN IF EXISTS vod_source_url,
+    DROP COLUMN IF EXISTS vod_url,
+    DROP COLUMN IF EXISTS chat_gini,
+    DROP COLUMN IF EXISTS top_ten_percent_share,
+    DROP COLUMN IF EXISTS top_one_percent_share,
+    DROP COLUMN IF EXISTS raw_peak_viewers,
+    DROP COLUMN IF EXISTS raw_average_viewers;
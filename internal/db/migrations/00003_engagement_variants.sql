@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE livestream_reports
+    ADD COLUMN IF NOT EXISTS engagement_formula                VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS engagement_chatters_per_average   DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS engagement_messages_per_viewer_hr DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS engagement_chatters_per_peak      DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS engagement_quality_weighted       DECIMAL NOT NULL DEFAULT 0.0;
+
+-- Existing reports were computed with the original definition
+UPDATE livestream_reports
+SET engagement_formula = 'chatters_per_average_viewers',
+    engagement_chatters_per_average = engagement
+WHERE engagement_formula IS NULL;
+
+-- +goose Down
+ALTER TABLE livestream_reports
+    DROP COLUMN IF EXISTS engagement_quality_weighted,
+    DROP COLUMN IF EXISTS engagement_chatters_per_peak,
+    DROP COLUMN IF EXISTS engagement_messages_per_viewer_hr,
+    DROP COLUMN IF EXISTS engagement_chatters_per_average,
+    DROP COLUMN IF EXISTS engagement_formula;
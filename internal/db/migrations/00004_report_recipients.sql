@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS report_recipients (
+    id             BIGSERIAL PRIMARY KEY,
+    channel_id     BIGINT NOT NULL,
+    email          VARCHAR(255) NOT NULL,
+    reports        BOOLEAN NOT NULL DEFAULT TRUE,
+    weekly_digest  BOOLEAN NOT NULL DEFAULT TRUE,
+    last_digest_at TIMESTAMPTZ,
+    created_by     VARCHAR(255),
+    created_at     TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_report_recipients_channel_email ON report_recipients (channel_id, email);
+
+-- +goose Down
+DROP TABLE IF EXISTS report_recipients;
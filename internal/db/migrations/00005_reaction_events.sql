@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS reaction_events (
+    id            UUID PRIMARY KEY,
+    channel_id    BIGINT NOT NULL,
+    livestream_id BIGINT,
+    event         VARCHAR(255) NOT NULL,
+    kind          VARCHAR(64) NOT NULL,
+    username      VARCHAR(255),
+    amount        BIGINT NOT NULL DEFAULT 1,
+    data          JSONB,
+    created_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reaction_events_channel_id ON reaction_events (channel_id);
+CREATE INDEX IF NOT EXISTS idx_reaction_events_livestream_id ON reaction_events (livestream_id);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS reactions JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS reactions;
+DROP TABLE IF EXISTS reaction_events;
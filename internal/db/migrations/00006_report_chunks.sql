@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE livestream_reports
+    ADD COLUMN IF NOT EXISTS parent_report_id UUID,
+    ADD COLUMN IF NOT EXISTS chunk_index      BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS chunk_count      BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS chunk_report_ids JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_livestream_reports_parent_report_id ON livestream_reports (parent_report_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_livestream_reports_parent_report_id;
+ALTER TABLE livestream_reports
+    DROP COLUMN IF EXISTS chunk_report_ids,
+    DROP COLUMN IF EXISTS chunk_count,
+    DROP COLUMN IF EXISTS chunk_index,
+    DROP COLUMN IF EXISTS parent_report_id;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS badges JSONB;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS audience_composition JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS audience_composition;
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS badges;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS stale BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS stale_messages BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS stale_messages;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS stale;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS competitor_sets (
+    id          UUID PRIMARY KEY,
+    tenant_id   UUID NOT NULL,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_competitor_sets_tenant_id ON competitor_sets (tenant_id);
+
+CREATE TABLE IF NOT EXISTS competitor_set_members (
+    set_id     UUID NOT NULL REFERENCES competitor_sets (id) ON DELETE CASCADE,
+    channel_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (set_id, channel_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS competitor_set_members;
+DROP TABLE IF EXISTS competitor_sets;
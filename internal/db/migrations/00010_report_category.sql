@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS category VARCHAR(255);
+
+-- Backfill from the last channel snapshot taken during each report's window
+UPDATE livestream_reports r SET category = (
+    SELECT cd.data->'livestream'->'categories'->0->>'name'
+    FROM channel_data cd
+    WHERE cd.channel_id = r.channel_id
+      AND cd.created_at BETWEEN r.report_start_time AND r.report_end_time
+      AND cd.data->'livestream'->'categories'->0->>'name' IS NOT NULL
+    ORDER BY cd.created_at DESC
+    LIMIT 1
+)
+WHERE r.category IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_livestream_reports_category ON livestream_reports (category);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_livestream_reports_category;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS category;
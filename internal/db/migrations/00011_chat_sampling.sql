@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE monitored_channels ADD COLUMN IF NOT EXISTS sample_rate INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS sampled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS sample_rate NUMERIC NOT NULL DEFAULT 1;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS persisted_messages BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS chat_message_counts (
+    livestream_id      BIGINT NOT NULL,
+    sender_id          BIGINT NOT NULL,
+    sender_username    VARCHAR(255),
+    messages           BIGINT NOT NULL DEFAULT 0,
+    persisted_messages BIGINT NOT NULL DEFAULT 0,
+    updated_at         TIMESTAMPTZ,
+    PRIMARY KEY (livestream_id, sender_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chat_message_counts;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS persisted_messages;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS sample_rate;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS sampled;
+ALTER TABLE monitored_channels DROP COLUMN IF EXISTS sample_rate;
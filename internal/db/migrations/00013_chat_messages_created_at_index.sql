@@ -0,0 +1,6 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_chat_messages_created_at ON chat_messages (created_at);
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS idx_chat_messages_created_at;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS kick_clips (
+    id               VARCHAR(64) PRIMARY KEY,
+    channel_id       BIGINT NOT NULL,
+    livestream_id    BIGINT,
+    shared_by        VARCHAR(255),
+    shared_at        TIMESTAMPTZ NOT NULL,
+    creator_username VARCHAR(255),
+    title            TEXT,
+    duration_seconds BIGINT NOT NULL DEFAULT 0,
+    thumbnail_url    TEXT,
+    video_url        TEXT,
+    clipped_at       TIMESTAMPTZ,
+    resolved_at      TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_kick_clips_channel_id ON kick_clips (channel_id);
+CREATE INDEX IF NOT EXISTS idx_kick_clips_livestream_id ON kick_clips (livestream_id);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS clips_created JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS clips_created;
+DROP TABLE IF EXISTS kick_clips;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS job_leases (
+    id           UUID PRIMARY KEY,
+    kind         VARCHAR(64) NOT NULL,
+    job_key      VARCHAR(255) NOT NULL,
+    owner        VARCHAR(255) NOT NULL,
+    status       VARCHAR(32) NOT NULL,
+    attempts     BIGINT NOT NULL DEFAULT 1,
+    started_at   TIMESTAMPTZ NOT NULL,
+    heartbeat_at TIMESTAMPTZ NOT NULL,
+    finished_at  TIMESTAMPTZ,
+    error        TEXT,
+    created_at   TIMESTAMPTZ
+);
+
+-- At most one unfinished lease per job
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_leases_active ON job_leases (kind, job_key) WHERE finished_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_job_leases_heartbeat_at ON job_leases (heartbeat_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_leases;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chatter_list_entries (
+    channel_id      BIGINT NOT NULL,
+    sender_id       BIGINT NOT NULL,
+    sender_username VARCHAR(255),
+    list            VARCHAR(16) NOT NULL,
+    reason          TEXT,
+    added_by        VARCHAR(255),
+    created_at      TIMESTAMPTZ,
+    PRIMARY KEY (channel_id, sender_id)
+);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS excluded_chatters BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS excluded_messages BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS excluded_messages;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS excluded_chatters;
+DROP TABLE IF EXISTS chatter_list_entries;
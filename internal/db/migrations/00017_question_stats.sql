@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS question_stats JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS question_stats;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS ingestion_lag_p50 NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS ingestion_lag_p95 NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS ingestion_lagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS ingestion_lagged;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS ingestion_lag_p95;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS ingestion_lag_p50;
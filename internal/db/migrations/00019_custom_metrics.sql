@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS custom_metrics JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS custom_metrics;
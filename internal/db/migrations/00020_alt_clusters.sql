@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE spam_reports ADD COLUMN IF NOT EXISTS alt_clusters JSONB;
+
+-- +goose Down
+ALTER TABLE spam_reports DROP COLUMN IF EXISTS alt_clusters;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS window_start TIMESTAMPTZ;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS window_end TIMESTAMPTZ;
+ALTER TABLE job_leases ADD COLUMN IF NOT EXISTS params JSONB;
+
+-- +goose Down
+ALTER TABLE job_leases DROP COLUMN IF EXISTS params;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS window_end;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS window_start;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS followers_gained INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS followers_per_hour_watched NUMERIC NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS followers_per_hour_watched;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS followers_gained;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE spam_reports ADD COLUMN IF NOT EXISTS unicode_abuse JSONB;
+
+-- +goose Down
+ALTER TABLE spam_reports DROP COLUMN IF EXISTS unicode_abuse;
@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS teams (
+    id         UUID PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id    UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL,
+    role       VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_user_id ON team_members (user_id);
+
+CREATE TABLE IF NOT EXISTS team_invites (
+    id          UUID PRIMARY KEY,
+    team_id     UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    email       VARCHAR(255) NOT NULL,
+    role        VARCHAR(16) NOT NULL,
+    invited_by  VARCHAR(255),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_invites_team_email ON team_invites (team_id, email);
+CREATE INDEX IF NOT EXISTS idx_team_invites_email ON team_invites (email);
+
+CREATE TABLE IF NOT EXISTS team_channels (
+    team_id    UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    channel_id BIGINT NOT NULL,
+    added_by   VARCHAR(255),
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (team_id, channel_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS team_channels;
+DROP TABLE IF EXISTS team_invites;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
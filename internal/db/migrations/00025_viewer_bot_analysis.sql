@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS viewer_bot_analysis JSONB;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS viewer_bot_suspected BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS viewer_bot_suspected;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS viewer_bot_analysis;
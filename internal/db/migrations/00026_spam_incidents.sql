@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS spam_incidents (
+    id                   UUID PRIMARY KEY,
+    spam_report_id       UUID NOT NULL,
+    livestream_report_id UUID NOT NULL,
+    channel_id           BIGINT NOT NULL,
+    livestream_id        BIGINT NOT NULL,
+    type                 VARCHAR(32) NOT NULL,
+    sender_id            BIGINT,
+    username             VARCHAR(255) NOT NULL,
+    content              TEXT NOT NULL,
+    message_count        INTEGER NOT NULL DEFAULT 0,
+    score                NUMERIC NOT NULL DEFAULT 0,
+    details              JSONB,
+    first_seen           TIMESTAMPTZ NOT NULL,
+    last_seen            TIMESTAMPTZ NOT NULL,
+    created_at           TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_spam_report_id ON spam_incidents (spam_report_id);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_channel_id ON spam_incidents (channel_id);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_livestream_id ON spam_incidents (livestream_id);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_type ON spam_incidents (type);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_sender_id ON spam_incidents (sender_id);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_username ON spam_incidents (username);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_first_seen ON spam_incidents (first_seen);
+CREATE INDEX IF NOT EXISTS idx_spam_incidents_details_usernames ON spam_incidents USING GIN ((details -> 'usernames'));
+
+-- +goose Down
+DROP TABLE IF EXISTS spam_incidents;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS channel_aliases (
+    id         BIGSERIAL PRIMARY KEY,
+    channel_id BIGINT NOT NULL,
+    username   VARCHAR(255) NOT NULL,
+    renamed_to VARCHAR(255) NOT NULL,
+    renamed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_aliases_channel_id ON channel_aliases (channel_id);
+CREATE INDEX IF NOT EXISTS idx_channel_aliases_username ON channel_aliases (username);
+
+-- +goose Down
+DROP TABLE IF EXISTS channel_aliases;
@@ -0,0 +1,12 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_channel_data_created_at ON channel_data (created_at);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_livestream_data_created_at ON livestream_data (created_at);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_reaction_events_created_at ON reaction_events (created_at);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_livestream_reports_created_at ON livestream_reports (created_at);
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS idx_livestream_reports_created_at;
+DROP INDEX CONCURRENTLY IF EXISTS idx_reaction_events_created_at;
+DROP INDEX CONCURRENTLY IF EXISTS idx_livestream_data_created_at;
+DROP INDEX CONCURRENTLY IF EXISTS idx_channel_data_created_at;
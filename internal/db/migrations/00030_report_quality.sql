@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_connections (
+    id              UUID PRIMARY KEY,
+    channel_id      BIGINT NOT NULL,
+    connected_at    TIMESTAMPTZ NOT NULL,
+    last_seen_at    TIMESTAMPTZ NOT NULL,
+    disconnected_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_connections_channel_connected ON chat_connections (channel_id, connected_at);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS quality JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS quality;
+DROP TABLE IF EXISTS chat_connections;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chatter_bot_scores (
+    sender_id       BIGINT NOT NULL,
+    livestream_id   BIGINT NOT NULL,
+    channel_id      BIGINT NOT NULL,
+    sender_username VARCHAR(255),
+    probability     NUMERIC NOT NULL DEFAULT 0,
+    messages        BIGINT NOT NULL DEFAULT 0,
+    features        JSONB,
+    created_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (sender_id, livestream_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chatter_bot_scores_created_at ON chatter_bot_scores (created_at);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS engagement_bot_adjusted NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS likely_bot_chatters INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS likely_bot_chatters;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS engagement_bot_adjusted;
+DROP TABLE IF EXISTS chatter_bot_scores;
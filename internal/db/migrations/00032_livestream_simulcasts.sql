@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS livestream_simulcasts (
+    livestream_id  BIGINT PRIMARY KEY,
+    channel_id     BIGINT NOT NULL,
+    platforms      VARCHAR(255),
+    twitch_channel VARCHAR(255),
+    indicators     JSONB,
+    first_seen_at  TIMESTAMPTZ NOT NULL,
+    last_seen_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_livestream_simulcasts_channel_id ON livestream_simulcasts (channel_id);
+
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS simulcast BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE livestream_reports ADD COLUMN IF NOT EXISTS simulcast_info JSONB;
+
+-- +goose Down
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS simulcast_info;
+ALTER TABLE livestream_reports DROP COLUMN IF EXISTS simulcast;
+DROP TABLE IF EXISTS livestream_simulcasts;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE streamer_profiles ADD COLUMN IF NOT EXISTS followers_backfilled_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE streamer_profiles DROP COLUMN IF EXISTS followers_backfilled_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS daily_digests (
+    day                DATE PRIMARY KEY,
+    streams            INTEGER NOT NULL DEFAULT 0,
+    active_channels    INTEGER NOT NULL DEFAULT 0,
+    monitored_channels INTEGER NOT NULL DEFAULT 0,
+    hours_streamed     NUMERIC NOT NULL DEFAULT 0,
+    hours_watched      NUMERIC NOT NULL DEFAULT 0,
+    summary            JSONB,
+    delivered_at       TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS daily_digests;
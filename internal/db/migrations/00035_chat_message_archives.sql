@@ -0,0 +1,23 @@
+-- +goose Up
+-- Monthly partitions (chat_message_archives_YYYY_MM) are created by the archiver as it needs them
+CREATE TABLE IF NOT EXISTS chat_message_archives (
+    id            UUID NOT NULL,
+    day           DATE NOT NULL,
+    chatroom_id   BIGINT NOT NULL,
+    livestream_id BIGINT,
+    messages      INTEGER NOT NULL,
+    first_sent_at TIMESTAMPTZ NOT NULL,
+    last_sent_at  TIMESTAMPTZ NOT NULL,
+    raw_bytes     BIGINT NOT NULL,
+    data          BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ,
+    PRIMARY KEY (id, day)
+) PARTITION BY RANGE (day);
+
+CREATE INDEX IF NOT EXISTS idx_chat_message_archives_livestream_id ON chat_message_archives (livestream_id);
+
+-- Compressed already, keep Postgres from trying again
+ALTER TABLE chat_message_archives ALTER COLUMN data SET STORAGE EXTERNAL;
+
+-- +goose Down
+DROP TABLE IF EXISTS chat_message_archives;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE spam_reports ADD COLUMN IF NOT EXISTS emote_bursts JSONB;
+
+-- +goose Down
+ALTER TABLE spam_reports DROP COLUMN IF EXISTS emote_bursts;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chat_connections ADD COLUMN IF NOT EXISTS source VARCHAR(16) NOT NULL DEFAULT 'websocket';
+
+-- +goose Down
+ALTER TABLE chat_connections DROP COLUMN IF EXISTS source;
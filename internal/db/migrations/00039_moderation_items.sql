@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS moderation_items (
+    id            UUID PRIMARY KEY,
+    channel_id    BIGINT NOT NULL,
+    livestream_id BIGINT NOT NULL,
+    finding_key   VARCHAR(64) NOT NULL,
+    type          VARCHAR(32) NOT NULL,
+    sender_id     BIGINT,
+    username      VARCHAR(255) NOT NULL,
+    content       TEXT NOT NULL,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    score         NUMERIC NOT NULL DEFAULT 0,
+    details       JSONB,
+    first_seen    TIMESTAMPTZ NOT NULL,
+    status        VARCHAR(16) NOT NULL DEFAULT 'pending',
+    reviewed_by   VARCHAR(255),
+    reviewed_at   TIMESTAMPTZ,
+    note          TEXT,
+    created_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_moderation_items_finding ON moderation_items (livestream_id, finding_key);
+CREATE INDEX IF NOT EXISTS idx_moderation_items_channel_id ON moderation_items (channel_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_items_username ON moderation_items (username);
+CREATE INDEX IF NOT EXISTS idx_moderation_items_status_first_seen ON moderation_items (status, first_seen);
+
+-- +goose Down
+DROP TABLE IF EXISTS moderation_items;
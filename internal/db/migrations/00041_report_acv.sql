@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE livestream_reports
+    ADD COLUMN IF NOT EXISTS average_concurrent_viewers DECIMAL NOT NULL DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS viewer_tracked_seconds     BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE livestream_reports
+    DROP COLUMN IF EXISTS viewer_tracked_seconds,
+    DROP COLUMN IF EXISTS average_concurrent_viewers;
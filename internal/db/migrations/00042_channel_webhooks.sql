@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS channel_webhooks (
+    id                         UUID PRIMARY KEY,
+    channel_id                 BIGINT NOT NULL,
+    url                        TEXT NOT NULL,
+    secret                     VARCHAR(128) NOT NULL,
+    previous_secret            VARCHAR(128),
+    previous_secret_expires_at TIMESTAMPTZ,
+    created_by                 VARCHAR(255),
+    created_at                 TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_webhooks_channel_id ON channel_webhooks (channel_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS channel_webhooks;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS pending_email            VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS email_verification_hash  VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS pending_email_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS password_changed_at      TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_changed_at,
+    DROP COLUMN IF EXISTS pending_email_expires_at,
+    DROP COLUMN IF EXISTS email_verification_hash,
+    DROP COLUMN IF EXISTS pending_email;
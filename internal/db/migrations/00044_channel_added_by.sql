@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE monitored_channels ADD COLUMN IF NOT EXISTS added_by UUID;
+CREATE INDEX IF NOT EXISTS idx_monitored_channels_added_by ON monitored_channels (added_by);
+-- The channel quota is counted from monitored_channels now, the lifetime counter never went down on removal
+DELETE FROM quota_usages WHERE metric = 'channels_monitored';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_monitored_channels_added_by;
+ALTER TABLE monitored_channels DROP COLUMN IF EXISTS added_by;
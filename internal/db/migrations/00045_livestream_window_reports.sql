@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS livestream_window_reports (
+    id            UUID PRIMARY KEY,
+    channel_id    BIGINT      NOT NULL,
+    livestream_id BIGINT      NOT NULL,
+    window_start  TIMESTAMPTZ,
+    window_end    TIMESTAMPTZ,
+    report        JSONB       NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- One report per livestream and window, open bounds compared as equal
+CREATE UNIQUE INDEX IF NOT EXISTS idx_livestream_window_reports_window ON livestream_window_reports
+    (livestream_id, COALESCE(window_start, '-infinity'::timestamptz), COALESCE(window_end, 'infinity'::timestamptz));
+CREATE INDEX IF NOT EXISTS idx_livestream_window_reports_channel_id ON livestream_window_reports (channel_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS livestream_window_reports;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Accounts registered before verification existed confirm their address through POST /protected/account/verify_email
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
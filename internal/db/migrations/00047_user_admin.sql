@@ -0,0 +1,6 @@
+-- +goose Up
+-- Synced from ADMIN_EMAILS on startup, for verified addresses only
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS live_report_snapshots (
+    channel_id    BIGINT PRIMARY KEY,
+    livestream_id BIGINT      NOT NULL,
+    report        JSONB       NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS live_report_snapshots;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS live_aggregate_samples (
+    instance_id   VARCHAR(64) NOT NULL,
+    sampled_at    TIMESTAMPTZ NOT NULL,
+    viewers       INTEGER     NOT NULL,
+    live_channels INTEGER     NOT NULL,
+    PRIMARY KEY (instance_id, sampled_at)
+);
+CREATE INDEX IF NOT EXISTS idx_live_aggregate_samples_sampled_at ON live_aggregate_samples (sampled_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS live_aggregate_samples;
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// MaxNotifyPayload is the largest payload Postgres accepts in a notification, minus some headroom
+const MaxNotifyPayload = 7900
+
+// Notify sends a Postgres notification on the channel to every instance listening on it
+func Notify(channel, payload string) error {
+	if len(payload) > MaxNotifyPayload {
+		return fmt.Errorf("notification on %s is %d bytes, over the %d allowed", channel, len(payload), MaxNotifyPayload)
+	}
+	return DB.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// Listen holds a connection of the pool listening on the channels and calls handle with every notification until
+// ctx is done or the connection fails. ready is called once listening, before any notification is handled, so
+// callers can catch up on what they missed while they weren't. Callers reconnect by calling Listen again.
+func Listen(ctx context.Context, channels []string, ready func(), handle func(channel, payload string)) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB from gorm: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection to listen on: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		// The connection goes back to the pool, which must not hand it out still listening
+		defer pgConn.Exec(context.Background(), "UNLISTEN *")
+		for _, channel := range channels {
+			if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return fmt.Errorf("failed to listen on %s: %w", channel, err)
+			}
+		}
+		ready()
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			handle(notification.Channel, notification.Payload)
+		}
+	})
+}
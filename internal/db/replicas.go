@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReadReplicaDSNs lists the DSNs of read replicas, comma separated. Heavy reads opting in through Reader go to
+// one of them at random; everything else, ingestion writes included, stays on the primary.
+var ReadReplicaDSNs = util.GetEnvString("DB_READ_REPLICAS", "")
+
+// replicaResolver names the resolver Reader selects. No global resolver is registered, so queries that don't
+// ask for the replicas never leave the primary.
+const replicaResolver = "read_replicas"
+
+var replicasEnabled bool
+
+// useReadReplicas registers the replicas of DB_READ_REPLICAS on conn, if any
+func useReadReplicas(conn *gorm.DB) error {
+	var replicas []gorm.Dialector
+	for _, dsn := range strings.Split(ReadReplicaDSNs, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicas = append(replicas, postgres.Open(dsn))
+		}
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+	if err := conn.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}, replicaResolver)); err != nil {
+		return fmt.Errorf("failed to register read replicas: %w", err)
+	}
+	replicasEnabled = true
+	log.Printf("Routing report, profile and search reads to %d read replica(s)", len(replicas))
+	return nil
+}
+
+// Reader returns DB for a read that may be served by a read replica, slightly behind the primary. Reads that must
+// see a write just made, and anything inside a transaction, use DB directly. Without replicas it is DB.
+func Reader() *gorm.DB {
+	return readerOf(DB)
+}
+
+// ReaderFromContext is Reader for the database of the request
+func ReaderFromContext(c echo.Context) *gorm.DB {
+	return readerOf(FromContext(c))
+}
+
+func readerOf(conn *gorm.DB) *gorm.DB {
+	if !replicasEnabled {
+		return conn
+	}
+	return conn.Clauses(dbresolver.Use(replicaResolver), dbresolver.Read)
+}
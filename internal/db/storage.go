@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// storageGrowthWindow is how far back rows are counted to estimate a table's growth rate
+const storageGrowthWindow = 7 * 24 * time.Hour
+
+// TableStorage describes the size, vacuum state and growth of one table
+type TableStorage struct {
+	Table           string     `json:"table"`
+	Rows            int64      `json:"rows"`      // Live tuples as estimated by Postgres statistics
+	DeadRows        int64      `json:"dead_rows"` // Dead tuples waiting for vacuum
+	TotalBytes      int64      `json:"total_bytes"`
+	TableBytes      int64      `json:"table_bytes"`
+	IndexBytes      int64      `json:"index_bytes"`
+	ToastBytes      int64      `json:"toast_bytes"`
+	OldestRow       *time.Time `json:"oldest_row,omitempty"` // Only for tables with a created_at column
+	NewestRow       *time.Time `json:"newest_row,omitempty"`
+	RowsPerDay      float64    `json:"rows_per_day"`  // Rows created per day over the last storageGrowthWindow
+	BytesPerDay     float64    `json:"bytes_per_day"` // RowsPerDay * average row size
+	LastVacuum      *time.Time `json:"last_vacuum,omitempty"`
+	LastAutoVacuum  *time.Time `json:"last_autovacuum,omitempty"`
+	LastAnalyze     *time.Time `json:"last_analyze,omitempty"`
+	LastAutoAnalyze *time.Time `json:"last_autoanalyze,omitempty"`
+}
+
+// StorageReport summarizes the on-disk usage of the database
+type StorageReport struct {
+	DatabaseBytes int64          `json:"database_bytes"`
+	Tables        []TableStorage `json:"tables"` // Largest first
+	GeneratedAt   time.Time      `json:"generated_at"`
+}
+
+// Storage reports row counts, sizes, oldest rows and growth estimates for every table in SchemaModels.
+func Storage(ctx context.Context) (StorageReport, error) {
+	report := StorageReport{GeneratedAt: time.Now().UTC()}
+	conn := DB.WithContext(ctx)
+
+	if err := conn.Raw("SELECT pg_database_size(current_database())").Scan(&report.DatabaseBytes).Error; err != nil {
+		return report, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	cache := &sync.Map{}
+	since := report.GeneratedAt.Add(-storageGrowthWindow)
+	for _, model := range SchemaModels {
+		s, err := schema.Parse(model, cache, DB.NamingStrategy)
+		if err != nil {
+			return report, fmt.Errorf("failed to parse model schema: %w", err)
+		}
+
+		stats := TableStorage{Table: s.Table}
+		row := conn.Raw(`SELECT COALESCE(s.n_live_tup, 0), COALESCE(s.n_dead_tup, 0),
+				pg_total_relation_size(c.oid), pg_relation_size(c.oid), pg_indexes_size(c.oid),
+				COALESCE(pg_total_relation_size(c.reltoastrelid), 0),
+				s.last_vacuum, s.last_autovacuum, s.last_analyze, s.last_autoanalyze
+			FROM pg_class c
+			LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+			WHERE c.oid = to_regclass(?)`, s.Table).Row()
+		if err := row.Scan(&stats.Rows, &stats.DeadRows, &stats.TotalBytes, &stats.TableBytes, &stats.IndexBytes,
+			&stats.ToastBytes, &stats.LastVacuum, &stats.LastAutoVacuum, &stats.LastAnalyze, &stats.LastAutoAnalyze); err != nil {
+			return report, fmt.Errorf("failed to read storage stats for %s: %w", s.Table, err)
+		}
+
+		if s.LookUpField("created_at") != nil {
+			var bounds struct {
+				Oldest *time.Time
+				Newest *time.Time
+				Recent int64
+			}
+			if err := conn.Table(s.Table).
+				Select("MIN(created_at) AS oldest, MAX(created_at) AS newest, COUNT(*) FILTER (WHERE created_at >= ?) AS recent", since).
+				Scan(&bounds).Error; err != nil {
+				return report, fmt.Errorf("failed to read row ages for %s: %w", s.Table, err)
+			}
+			stats.OldestRow, stats.NewestRow = bounds.Oldest, bounds.Newest
+			stats.RowsPerDay = float64(bounds.Recent) / storageGrowthWindow.Hours() * 24
+			if stats.Rows > 0 {
+				stats.BytesPerDay = stats.RowsPerDay * float64(stats.TotalBytes) / float64(stats.Rows)
+			}
+		}
+
+		report.Tables = append(report.Tables, stats)
+	}
+
+	// Largest tables first, they are the retention candidates
+	sort.Slice(report.Tables, func(i, j int) bool { return report.Tables[i].TotalBytes > report.Tables[j].TotalBytes })
+	return report, nil
+}
@@ -0,0 +1,104 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Email templates
+const (
+	TemplateReport = "report.html"
+	TemplateDigest = "digest.html"
+	TemplateInvite = "team_invite.html"
+	TemplateEmail  = "email_change.html"
+	TemplateVerify = "verify_email.html"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"date":     func(t time.Time) string { return t.UTC().Format("Mon, 02 Jan 2006 15:04 MST") },
+	"decimals": func(f float64) string { return fmt.Sprintf("%.2f", f) },
+}).ParseFS(templatesFS, "templates/*.html"))
+
+var (
+	smtpHost     string
+	smtpPort     int
+	smtpUsername string
+	smtpPassword string
+	smtpFrom     string
+)
+
+// Init loads the SMTP configuration from the environment.
+// When SMTP_HOST is unset email delivery is disabled.
+func Init() {
+	smtpHost = os.Getenv("SMTP_HOST")
+	smtpPort = util.GetEnvInt("SMTP_PORT", 587)
+	smtpUsername = os.Getenv("SMTP_USERNAME")
+	smtpPassword = os.Getenv("SMTP_PASSWORD")
+	smtpFrom = os.Getenv("SMTP_FROM")
+	if smtpFrom == "" {
+		smtpFrom = smtpUsername
+	}
+
+	if smtpHost == "" {
+		log.Println("SMTP_HOST not set. Report emails are disabled.")
+	}
+}
+
+// Enabled reports whether SMTP delivery is configured.
+func Enabled() bool {
+	return smtpHost != "" && smtpFrom != ""
+}
+
+// Send renders the named template with data and emails it as HTML to the recipients.
+func Send(to []string, subject, templateName string, data any) error {
+	if !Enabled() {
+		return nil
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, templateName, data); err != nil {
+		return fmt.Errorf("failed to render email template %s: %w", templateName, err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", stripNewlines(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if smtpUsername != "" {
+		auth = smtp.PlainAuth("", smtpUsername, smtpPassword, smtpHost)
+	}
+
+	addr := fmt.Sprintf("%s:%d", smtpHost, smtpPort)
+	if err := smtp.SendMail(addr, auth, smtpFrom, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// stripNewlines prevents header injection through user-controlled subjects (e.g. stream titles).
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
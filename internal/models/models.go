@@ -8,10 +8,12 @@ import (
 )
 
 type MonitoredChannel struct {
-	ChannelID  uint   `gorm:"primaryKey"`
-	ChatroomID uint   `gorm:"unique;notnull"`
-	Username   string `gorm:"unique;not null"`
-	IsActive   bool   `gorm:"default:true"`
+	ChannelID  uint       `gorm:"primaryKey"`
+	ChatroomID uint       `gorm:"unique;notnull"`
+	Username   string     `gorm:"unique;not null"`
+	IsActive   bool       `gorm:"default:true"`
+	SampleRate int        `gorm:"not null;default:1"` // Persist 1 in SampleRate chat messages, 1 persists all of them
+	AddedBy    *uuid.UUID `gorm:"type:uuid;index"`    // Tenant whose channel quota the channel counts against, nil for channels added before quotas
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
@@ -36,26 +38,30 @@ type LivestreamData struct {
 	IsLive              bool
 	Duration            int
 	LangISO             string    `gorm:"size:10"`
+	Source              string    `gorm:"size:16;not null;default:'http'"` // "http" for periodic fetches, "pusher" for WebSocket viewer updates
 	CreatedAt           time.Time `gorm:"primaryKey;autoCreateTime"`
 }
 
 type ChatMessage struct {
-	ID              uuid.UUID `gorm:"type:uuid;primaryKey"` // Message UUID from data payload
-	ChatroomID      uint      `gorm:"not null"`             // Link to MonitoredChannel.ChatRoomID
-	LivestreamID    *uint     `gorm:"column:livestream_id"` // Nullable foreign key, pointer to uint
-	SenderID        int       `gorm:"not null"`             // Sender user ID
-	SenderUsername  string    `gorm:"size:255;not null"`    // Sender username (slug)
-	Event           string    `gorm:"size:255;not null"`    // WebSocket event type
-	Message         string    `gorm:"type:text;not null"`   // Message content
-	Metadata        []byte    `gorm:"type:jsonb"`           // Metadata as JSONB (nullable if not always present)
-	MessageSendTime time.Time `gorm:"not null"`             // Original message send time from data
-	CreatedAt       time.Time `gorm:"autoCreateTime"`       // Timestamp of when message was processed/saved Extracted Chat Message Fields
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`   // Message UUID from data payload
+	ChatroomID      uint      `gorm:"not null"`               // Link to MonitoredChannel.ChatRoomID
+	LivestreamID    *uint     `gorm:"column:livestream_id"`   // Nullable foreign key, pointer to uint
+	SenderID        int       `gorm:"not null"`               // Sender user ID
+	SenderUsername  string    `gorm:"size:255;not null"`      // Sender username (slug)
+	Event           string    `gorm:"size:255;not null"`      // WebSocket event type
+	Message         string    `gorm:"type:text;not null"`     // Message content
+	Metadata        []byte    `gorm:"type:jsonb"`             // Metadata as JSONB (nullable if not always present)
+	Flagged         bool      `gorm:"not null;default:false"` // Sender was flagged by a moderator at ingestion time
+	Badges          []byte    `gorm:"type:jsonb"`             // Sender identity badges (subscriber, moderator...), NULL for older messages
+	MessageSendTime time.Time `gorm:"not null"`               // Original message send time from data
+	CreatedAt       time.Time `gorm:"autoCreateTime"`         // Timestamp of when message was processed/saved Extracted Chat Message Fields
 }
 
 type LivestreamReport struct {
 	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
 	LivestreamID uint      `gorm:"not null"`
 	Title        string
+	Category     string `gorm:"size:255;index"` // Kick category (game) the stream was in when it ended
 
 	ChannelID       uint      `gorm:"not null"`
 	Username        string    `gorm:"size:255;not null"`
@@ -64,26 +70,122 @@ type LivestreamReport struct {
 	DurationMinutes int       `gorm:"not null"`
 
 	// Viewer Analytics
-	AverageViewers int     `gorm:"not null;default:0"`
+	AverageViewers int     `gorm:"not null;default:0"` // Mean of the viewer samples, however unevenly they're spaced
 	PeakViewers    int     `gorm:"not null;default:0"`
 	LowestViewers  int     `gorm:"not null;default:0"`
 	Engagement     float64 `gorm:"not null;default:0.0" `
-	HoursWatched   float64 `gorm:"not null;default:0.0" `
+	HoursWatched   float64 `gorm:"not null;default:0.0" ` // AverageConcurrentViewers over the report's duration
+
+	// Average concurrent viewers (ACV), each viewer sample weighted by how long it held. 0 for reports generated
+	// before it was tracked.
+	AverageConcurrentViewers float64 `gorm:"not null;default:0"`
+	ViewerTrackedSeconds     int     `gorm:"not null;default:0"` // Time the viewer samples covered, what the ACV averages over
+
+	// Every engagement definition is stored; Engagement holds the one selected by EngagementFormula
+	EngagementFormula             string  `gorm:"size:64"`
+	EngagementChattersPerAverage  float64 `gorm:"not null;default:0.0"`
+	EngagementMessagesPerViewerHr float64 `gorm:"not null;default:0.0"`
+	EngagementChattersPerPeak     float64 `gorm:"not null;default:0.0"`
+	EngagementQualityWeighted     float64 `gorm:"not null;default:0.0"`
+	EngagementBotAdjusted         float64 `gorm:"not null;default:0.0"`
+	LikelyBotChatters             int     `gorm:"not null;default:0"` // Chatters with a bot probability of at least BOT_PROBABILITY_THRESHOLD
+
+	// Raw (unsmoothed) viewer analytics, kept alongside the outlier-rejected values above
+	RawAverageViewers int `gorm:"not null;default:0"`
+	RawPeakViewers    int `gorm:"not null;default:0"`
 
 	// Chat Metrics (spam/emote related moved to SpamReport)
 	TotalMessages    int `gorm:"not null;default:0"`
 	UniqueChatters   int `gorm:"not null;default:0"`
 	MessagesFromApps int `gorm:"not null;default:0"`
 
+	// Chat Concentration
+	TopOnePercentShare float64 `gorm:"not null;default:0.0"`
+	TopTenPercentShare float64 `gorm:"not null;default:0.0"`
+	ChatGini           float64 `gorm:"not null;default:0.0"`
+
+	// Follower count growth from the stream start to FollowerAttributionWindow after its end
+	FollowersGained         int     `gorm:"not null;default:0"`
+	FollowersPerHourWatched float64 `gorm:"not null;default:0"`
+
 	SpamReportID *uuid.UUID `gorm:"type:uuid"`
 
+	// VOD
+	VodURL       string `gorm:"type:text"` // Kick VOD page
+	VodSourceURL string `gorm:"type:text"` // HLS playlist of the VOD
+
 	// Timelines
-	ViewerCountsTimeline  []byte `gorm:"type:jsonb"`
-	MessageCountsTimeline []byte `gorm:"type:jsonb"`
+	ViewerCountsTimeline    []byte `gorm:"type:jsonb"`
+	RawViewerCountsTimeline []byte `gorm:"type:jsonb"`
+	MessageCountsTimeline   []byte `gorm:"type:jsonb"`
+
+	Reactions           []byte `gorm:"type:jsonb"`             // Reactions section: totals and bursts correlated with chat/viewers
+	AudienceComposition []byte `gorm:"type:jsonb"`             // Chat share of moderators, subscribers and non-subscribers
+	ClipsCreated        []byte `gorm:"type:jsonb"`             // Clips linked in chat during the stream, with the chat rate around them
+	QuestionStats       []byte `gorm:"type:jsonb"`             // Questions asked in chat and how many the streamer or moderators answered
+	CustomMetrics       []byte `gorm:"type:jsonb"`             // Metrics returned by the operator's custom metric callouts, keyed by callout name
+	ViewerBotAnalysis   []byte `gorm:"type:jsonb"`             // Correlation between the viewer count and the chat rate
+	ViewerBotSuspected  bool   `gorm:"not null;default:false"` // Viewers barely move with chat, typical of view-botting
+	ChatLanguages       []byte `gorm:"type:jsonb"`             // Estimated audience languages, from chat or the declared stream language
+	Quality             []byte `gorm:"type:jsonb"`             // Data quality score and confidence ranges of the viewer metrics
+	Simulcast           bool   `gorm:"not null;default:false"` // The stream was probably also broadcast elsewhere, splitting its chat
+	SimulcastInfo       []byte `gorm:"type:jsonb"`             // Platforms and indicators of the simulcast, null when not one
+	ViewerMilestones    []byte `gorm:"type:jsonb"`             // Moments the viewers crossed notable thresholds, markers for the viewer chart
+	RaidSpikes          []byte `gorm:"type:jsonb"`             // Viewer rises annotated with the channel that probably raided or hosted
+
+	// Long streams are split into chunk reports that point at a parent rollup report
+	ParentReportID *uuid.UUID `gorm:"type:uuid;index"`    // Set on chunk reports
+	ChunkIndex     int        `gorm:"not null;default:0"` // 1-based position of a chunk, 0 for regular and parent reports
+	ChunkCount     int        `gorm:"not null;default:0"` // Number of chunks the stream was split into, 0 when not split
+	ChunkReportIDs []byte     `gorm:"type:jsonb"`         // On parent reports, the IDs of the chunk reports in order
+
+	// Set when chat messages were reassociated with the livestream after the report was generated
+	Stale         bool `gorm:"not null;default:false"`
+	StaleMessages int  `gorm:"not null;default:0"` // Messages missing from the report
+
+	// Set when only a sample of the chat messages was persisted; TotalMessages and UniqueChatters still count all of them
+	Sampled           bool    `gorm:"not null;default:false"`
+	SampleRate        float64 `gorm:"not null;default:1"` // Messages received per message persisted
+	PersistedMessages int     `gorm:"not null;default:0"`
+
+	// Chatters on the channel's exclusion list (alts, test bots) are left out of every metric
+	ExcludedChatters int `gorm:"not null;default:0"`
+	ExcludedMessages int `gorm:"not null;default:0"`
+
+	// Lag between Kick's send time and our persist time of the chat messages, in seconds. IngestionLagged is set
+	// when it was high enough (or negative enough, clock skew) that time-based metrics may be off.
+	IngestionLagP50 float64 `gorm:"not null;default:0"`
+	IngestionLagP95 float64 `gorm:"not null;default:0"`
+	IngestionLagged bool    `gorm:"not null;default:false"`
+
+	// Set when the report was restricted to part of the stream; ReportStartTime and ReportEndTime then span it
+	WindowStart *time.Time
+	WindowEnd   *time.Time
 
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
 
+// LivestreamWindowReport is a report restricted to part of a stream, kept next to the stream's full report. Report
+// holds it with its spam report in the shape the API serves reports.
+type LivestreamWindowReport struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	ChannelID    uint            `gorm:"not null;index"`
+	LivestreamID uint            `gorm:"not null"`
+	WindowStart  *time.Time      // Nil for a window open at the start of the stream
+	WindowEnd    *time.Time      // Nil for a window open at the end of the stream
+	Report       json.RawMessage `gorm:"type:jsonb;not null"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime"`
+}
+
+// LiveReportSnapshot is the live report of a channel's stream as last persisted by the instance monitoring it, so
+// every instance of a cluster can serve it
+type LiveReportSnapshot struct {
+	ChannelID    uint            `gorm:"primaryKey;autoIncrement:false"`
+	LivestreamID uint            `gorm:"not null"`
+	Report       json.RawMessage `gorm:"type:jsonb;not null"`
+	UpdatedAt    time.Time       `gorm:"not null;autoUpdateTime:false"` // Of the live report, not of the row
+}
+
 type SpamReport struct {
 	ID                 uuid.UUID `gorm:"type:uuid;primaryKey"`
 	LivestreamReportID uuid.UUID `gorm:"type:uuid;not null"`
@@ -100,6 +202,10 @@ type SpamReport struct {
 	ExactDuplicateBursts   []byte `gorm:"type:jsonb"`
 	SimilarMessageBursts   []byte `gorm:"type:jsonb"`
 	SuspiciousChatters     []byte `gorm:"type:jsonb"`
+	CrossUserCopypasta     []byte `gorm:"type:jsonb"`
+	AltClusters            []byte `gorm:"type:jsonb"` // Likely alt accounts / botting rings and the signals linking them
+	UnicodeAbuse           []byte `gorm:"type:jsonb"` // Zalgo, combining mark, bidi override and emoji flood messages by category
+	EmoteBursts            []byte `gorm:"type:jsonb"` // Bursts of emote-only messages classified as hype or spam
 
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
@@ -117,9 +223,11 @@ type StreamerProfile struct {
 	VodEnabled          bool            `gorm:"not null;default:false"`
 	IsAffiliate         bool            `gorm:"not null;default:false"`
 	SubscriptionEnabled bool            `gorm:"not null;default:false"`
-	FollowersCount      json.RawMessage `gorm:"type:jsonb"`
+	FollowersCount      json.RawMessage `gorm:"type:jsonb"` // Appended to on every fetch, never rewritten by it
 	Livestreams         []byte          `gorm:"type:jsonb"`
 
+	FollowersBackfilledAt *time.Time // Set once FollowersCount was rebuilt from channel_data, or for profiles created with an incremental timeline
+
 	Bio        string `gorm:"type:text"`
 	City       string `gorm:"size:255"`
 	State      string `gorm:"size:255"`
@@ -143,4 +251,320 @@ type User struct {
 	PasswordHash string    `gorm:"type:text;not null;column:password_hash"`
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+
+	// An email change waits on PendingEmail until the token mailed there is confirmed
+	PendingEmail          string `gorm:"size:255"`
+	EmailVerificationHash string `gorm:"size:64"` // SHA-256 of the token, hex
+	PendingEmailExpiresAt *time.Time
+	PasswordChangedAt     *time.Time
+	EmailVerifiedAt       *time.Time // Set once the user confirmed owning Email, team invites need it
+	IsAdmin               bool       `gorm:"not null;default:false"` // Operator of the instance, synced from ADMIN_EMAILS
+}
+
+// UserSession is a login of a user; its ID is the jti of the JWT issued for it, so revoking it rejects the token
+type UserSession struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	IP         string    `gorm:"size:64"`
+	UserAgent  string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	LastSeenAt time.Time
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
+
+// QuotaUsage counts metered usage for a tenant within a quota period
+type QuotaUsage struct {
+	TenantID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Metric      string    `gorm:"size:64;primaryKey"`
+	PeriodStart time.Time `gorm:"primaryKey"` // Start of the day/month the usage belongs to, zero for lifetime metrics
+	Count       int       `gorm:"not null;default:0"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// MonitorInstance is a running kick-monitor instance taking part in channel sharding
+type MonitorInstance struct {
+	ID            string    `gorm:"size:64;primaryKey"`
+	Hostname      string    `gorm:"size:255"`
+	StartedAt     time.Time `gorm:"not null"`
+	LastHeartbeat time.Time `gorm:"not null;index"`
+}
+
+// LiveAggregateSample is the viewer total of the channels an instance of a cluster monitors, summed across
+// instances for the network-wide live aggregate
+type LiveAggregateSample struct {
+	InstanceID   string    `gorm:"size:64;primaryKey"`
+	SampledAt    time.Time `gorm:"primaryKey;index"` // Truncated to LiveAggregateInterval so instances' samples line up
+	Viewers      int       `gorm:"not null"`
+	LiveChannels int       `gorm:"not null"`
+}
+
+// FlaggedChatter is a chatter moderators are watching on a channel
+type FlaggedChatter struct {
+	ChannelID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	SenderUsername string    `gorm:"size:255;primaryKey"` // Lowercased sender slug
+	Reason         string    `gorm:"type:text"`
+	FlaggedBy      string    `gorm:"size:255"` // Email of the user who flagged the chatter
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// ChatterListEntry puts a chatter of a channel on its exclusion or trusted list
+type ChatterListEntry struct {
+	ChannelID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	SenderID       int       `gorm:"primaryKey;autoIncrement:false"`
+	SenderUsername string    `gorm:"size:255"`         // For display, the list is keyed on the ID
+	List           string    `gorm:"size:16;not null"` // excluded or trusted
+	Reason         string    `gorm:"type:text"`
+	AddedBy        string    `gorm:"size:255"` // Email of the user who added the chatter
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// ChannelWebhook is an endpoint receiving the alerts about a channel, signed with its own secret
+type ChannelWebhook struct {
+	ID                      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ChannelID               uint      `gorm:"not null;index"`
+	URL                     string    `gorm:"type:text;not null"`
+	Secret                  string    `gorm:"size:128;not null" json:"-"`
+	PreviousSecret          string    `gorm:"size:128" json:"-"` // Still signs deliveries until PreviousSecretExpiresAt, so consumers can roll over
+	PreviousSecretExpiresAt *time.Time
+	CreatedBy               string    `gorm:"size:255"` // Email of the user who added the webhook
+	CreatedAt               time.Time `gorm:"autoCreateTime"`
+}
+
+// ReportRecipient receives report summaries and/or weekly digests for a channel by email
+type ReportRecipient struct {
+	ID           uint       `gorm:"primaryKey"`
+	ChannelID    uint       `gorm:"not null;uniqueIndex:idx_report_recipients_channel_email"`
+	Email        string     `gorm:"size:255;not null;uniqueIndex:idx_report_recipients_channel_email"`
+	Reports      bool       `gorm:"not null;default:true"` // Email every generated livestream report
+	WeeklyDigest bool       `gorm:"not null;default:true"` // Email the weekly digest
+	LastDigestAt *time.Time // When the last weekly digest went out
+	CreatedBy    string     `gorm:"size:255"` // Email of the user who added the recipient
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+}
+
+// ReactionEvent is a reaction/celebration event (subs, gifts, hosts, rewards...) received over Pusher
+type ReactionEvent struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ChannelID    uint      `gorm:"not null;index"`
+	LivestreamID *uint     `gorm:"column:livestream_id;index"`
+	Event        string    `gorm:"size:255;not null"` // Pusher event name
+	Kind         string    `gorm:"size:64;not null"`
+	Username     string    `gorm:"size:255"`           // Who triggered it (gifter, host, subscriber...)
+	Amount       int       `gorm:"not null;default:1"` // Subs gifted, viewers brought by a host, otherwise 1
+	Data         []byte    `gorm:"type:jsonb"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// KickClip is a Kick clip whose link was posted in chat, with its metadata once resolved from the Kick API
+type KickClip struct {
+	ID              string     `gorm:"size:64;primaryKey"` // Kick clip ID, e.g. clip_01H...
+	ChannelID       uint       `gorm:"not null;index"`
+	LivestreamID    *uint      `gorm:"column:livestream_id;index"`
+	SharedBy        string     `gorm:"size:255"` // First chatter who posted the link
+	SharedAt        time.Time  `gorm:"not null"`
+	CreatorUsername string     `gorm:"size:255"` // Who made the clip, when resolved
+	Title           string     `gorm:"type:text"`
+	DurationSeconds int        `gorm:"not null;default:0"`
+	ThumbnailURL    string     `gorm:"type:text"`
+	VideoURL        string     `gorm:"type:text"`
+	ClippedAt       *time.Time // When the clip was created on Kick
+	ResolvedAt      *time.Time // Nil until the metadata was fetched
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+}
+
+// JobLease records a background job while an instance runs it. The owner keeps HeartbeatAt fresh, so an unfinished
+// lease with a stale heartbeat belongs to a crashed instance and the job can be requeued.
+type JobLease struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	Kind        string          `gorm:"size:64;not null"`
+	JobKey      string          `gorm:"size:255;not null"` // What the job works on, e.g. the livestream ID of a report
+	Owner       string          `gorm:"size:255;not null"` // Instance running the job
+	Status      string          `gorm:"size:32;not null"`  // running, done, failed or abandoned
+	Attempts    int             `gorm:"not null;default:1"`
+	StartedAt   time.Time       `gorm:"not null"`
+	HeartbeatAt time.Time       `gorm:"not null;index"`
+	FinishedAt  *time.Time      // Nil while the job runs
+	Error       string          `gorm:"type:text"`
+	Params      json.RawMessage `gorm:"type:jsonb"` // What the runner needs beyond the key, e.g. the window of a report
+	CreatedAt   time.Time       `gorm:"autoCreateTime"`
+}
+
+// CompetitorSet is a named group of channels in the same niche, compared with market share analytics
+type CompetitorSet struct {
+	ID          uuid.UUID             `gorm:"type:uuid;primaryKey"`
+	TenantID    uuid.UUID             `gorm:"type:uuid;not null;index"`
+	Name        string                `gorm:"size:255;not null"`
+	Description string                `gorm:"type:text"`
+	Members     []CompetitorSetMember `gorm:"foreignKey:SetID"`
+	CreatedAt   time.Time             `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time             `gorm:"autoUpdateTime"`
+}
+
+// CompetitorSetMember is a channel belonging to a competitor set
+type CompetitorSetMember struct {
+	SetID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ChannelID uint      `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Team is a workspace whose members share a set of monitored channels and their reports
+type Team struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name      string    `gorm:"size:255;not null"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TeamMember gives a user a role in a team
+type TeamMember struct {
+	TeamID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	Role      string    `gorm:"size:16;not null"` // owner, admin or member
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TeamInvite invites an email address to join a team with a role, accepted by the user registered with it
+type TeamInvite struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TeamID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_team_invites_team_email"`
+	Email      string    `gorm:"size:255;not null;uniqueIndex:idx_team_invites_team_email;index"` // Lowercased
+	Role       string    `gorm:"size:16;not null"`
+	InvitedBy  string    `gorm:"size:255"` // Email of the user who sent the invite
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcceptedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// TeamChannel shares a monitored channel, and its reports, with a team
+type TeamChannel struct {
+	TeamID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ChannelID uint      `gorm:"primaryKey;autoIncrement:false"`
+	AddedBy   string    `gorm:"size:255"` // Email of the user who shared the channel
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ChatMessageCount is the exact number of messages a chatter sent during a livestream, including the messages
+// sampling didn't persist
+type ChatMessageCount struct {
+	LivestreamID      uint   `gorm:"primaryKey;autoIncrement:false"`
+	SenderID          int    `gorm:"primaryKey;autoIncrement:false"`
+	SenderUsername    string `gorm:"size:255"`
+	Messages          int    `gorm:"not null;default:0"`
+	PersistedMessages int    `gorm:"not null;default:0"`
+	UpdatedAt         time.Time
+}
+
+// SpamIncident is one finding of a spam report (a burst, a suspicious chatter, a copypasta), stored as its own row
+// so offenders can be searched across streams and channels
+type SpamIncident struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primaryKey"`
+	SpamReportID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	LivestreamReportID uuid.UUID `gorm:"type:uuid;not null"`
+	ChannelID          uint      `gorm:"not null;index"`
+	LivestreamID       uint      `gorm:"not null;index"`
+	Type               string    `gorm:"size:32;not null;index"`
+	SenderID           *int      `gorm:"index"`                   // Unknown for bursts, which only record the username
+	Username           string    `gorm:"size:255;not null;index"` // Empty for copypasta, whose participants are in Details
+	Content            string    `gorm:"type:text;not null"`      // Burst content, copypasta text or an example message
+	MessageCount       int       `gorm:"not null;default:0"`
+	Score              float64   `gorm:"not null;default:0"` // Suspicion score of suspicious chatters
+	Details            []byte    `gorm:"type:jsonb"`         // Type specific: issues of a chatter, participants of a copypasta
+	FirstSeen          time.Time `gorm:"not null;index"`
+	LastSeen           time.Time `gorm:"not null"`
+	CreatedAt          time.Time `gorm:"autoCreateTime"`
+}
+
+// ModerationItem is a spam finding queued for an analyst's review. Items outlive the incidents they come from: a
+// regenerated report finds its items again by FindingKey and the decisions stand.
+type ModerationItem struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ChannelID    uint      `gorm:"not null;index"`
+	LivestreamID uint      `gorm:"not null;uniqueIndex:idx_moderation_items_finding,priority:1"`
+	FindingKey   string    `gorm:"size:64;not null;uniqueIndex:idx_moderation_items_finding,priority:2"` // Hash of the type, username and content
+	Type         string    `gorm:"size:32;not null"`                                                     // Spam incident type
+	SenderID     *int      // Unknown for bursts
+	Username     string    `gorm:"size:255;not null;index"` // Empty for copypasta, whose participants are in Details
+	Content      string    `gorm:"type:text;not null"`
+	MessageCount int       `gorm:"not null;default:0"`
+	Score        float64   `gorm:"not null;default:0"`
+	Details      []byte    `gorm:"type:jsonb"`
+	FirstSeen    time.Time `gorm:"not null;index:idx_moderation_items_status_first_seen,priority:2"`
+	Status       string    `gorm:"size:16;not null;default:pending;index:idx_moderation_items_status_first_seen,priority:1"` // pending, confirmed or dismissed
+	ReviewedBy   string    `gorm:"size:255"`                                                                                 // Email of the analyst
+	ReviewedAt   *time.Time
+	Note         string    `gorm:"type:text"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// ChannelAlias is a username a channel went by before a rename, so lookups by the old username keep working
+type ChannelAlias struct {
+	ID        uint      `gorm:"primaryKey"`
+	ChannelID uint      `gorm:"not null;index"`
+	Username  string    `gorm:"size:255;not null;index"` // The username before the rename
+	RenamedTo string    `gorm:"size:255;not null"`
+	RenamedAt time.Time `gorm:"not null"`
+}
+
+// ChatConnection is a period the chat WebSocket of a channel was connected, to tell how much of a stream's chat
+// was captured. LastSeenAt is bumped while connected, so a connection cut by a crash ends there.
+type ChatConnection struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	ChannelID      uint       `gorm:"not null;index:idx_chat_connections_channel_connected,priority:1"`
+	ConnectedAt    time.Time  `gorm:"not null;index:idx_chat_connections_channel_connected,priority:2"`
+	LastSeenAt     time.Time  `gorm:"not null"`
+	DisconnectedAt *time.Time // Nil while connected, or when the instance died before recording the disconnect
+	Source         string     `gorm:"size:16;not null;default:websocket"` // websocket, or polling while the WebSocket was blocked
+}
+
+// LivestreamSimulcast records that a livestream was probably also broadcast on another platform, splitting its chat
+type LivestreamSimulcast struct {
+	LivestreamID  uint      `gorm:"primaryKey;autoIncrement:false"`
+	ChannelID     uint      `gorm:"not null;index"`
+	Platforms     string    `gorm:"size:255"`   // Comma separated, e.g. "twitch,youtube"; empty when only a title keyword hinted at it
+	TwitchChannel string    `gorm:"size:255"`   // From the twitch_channel field of Kick's livestream payload
+	Indicators    []byte    `gorm:"type:jsonb"` // Every indicator seen during the stream
+	FirstSeenAt   time.Time `gorm:"not null"`
+	LastSeenAt    time.Time `gorm:"not null"`
+}
+
+// DailyDigest is the fleet-wide summary of a UTC day, across every monitored channel
+type DailyDigest struct {
+	Day               time.Time  `gorm:"type:date;primaryKey"`
+	Streams           int        `gorm:"not null;default:0"`
+	ActiveChannels    int        `gorm:"not null;default:0"`
+	MonitoredChannels int        `gorm:"not null;default:0"`
+	HoursStreamed     float64    `gorm:"not null;default:0"`
+	HoursWatched      float64    `gorm:"not null;default:0"`
+	Summary           []byte     `gorm:"type:jsonb"` // The full digest: streams, gainers and losers, spam incidents
+	DeliveredAt       *time.Time // Nil until sent through the notification webhook
+	CreatedAt         time.Time  `gorm:"not null"`
+}
+
+// ChatMessageArchive is a gzip compressed JSON array of the chat messages of one chatroom, livestream and UTC day,
+// moved out of chat_messages once they are old. The table is partitioned by month on Day.
+type ChatMessageArchive struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Day          time.Time `gorm:"type:date;primaryKey"`
+	ChatroomID   uint      `gorm:"not null"`
+	LivestreamID *uint     `gorm:"index"` // Nil for messages never associated with a livestream
+	Messages     int       `gorm:"not null"`
+	FirstSentAt  time.Time `gorm:"not null"`
+	LastSentAt   time.Time `gorm:"not null"`
+	RawBytes     int64     `gorm:"not null"` // Size of the JSON before compression
+	Data         []byte    `gorm:"type:bytea;not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// ChatterBotScore is the bot probability of a chatter in one stream, from their behaviour in its chat
+type ChatterBotScore struct {
+	SenderID       int       `gorm:"primaryKey;autoIncrement:false"`
+	LivestreamID   uint      `gorm:"primaryKey;autoIncrement:false"`
+	ChannelID      uint      `gorm:"not null"`
+	SenderUsername string    `gorm:"size:255"`
+	Probability    float64   `gorm:"not null;default:0"`
+	Messages       int       `gorm:"not null;default:0"`
+	Features       []byte    `gorm:"type:jsonb"` // Behavioural features the probability was computed from
+	CreatedAt      time.Time `gorm:"not null;index"`
 }
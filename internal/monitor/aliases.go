@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sameSlug compares usernames the way Kick builds slugs from them: case-insensitive, underscores as dashes
+func sameSlug(a, b string) bool {
+	normalize := func(s string) string { return strings.ReplaceAll(strings.ToLower(s), "_", "-") }
+	return normalize(a) == normalize(b)
+}
+
+// renamedSlug returns the new username of a channel whose fetched data, matched on the channel ID, carries another
+// slug; empty when it wasn't renamed
+func renamedSlug(channel *models.MonitoredChannel, kickData KickChannelResponse) string {
+	if kickData.Slug == "" || uint(kickData.ID) != channel.ChannelID || sameSlug(kickData.Slug, channel.Username) {
+		return ""
+	}
+	return kickData.Slug
+}
+
+// RenameChannel moves a channel and its profile to a new username, keeping the old one as an alias. It reports
+// false when the channel no longer goes by channel.Username, i.e. the rename was already recorded.
+func RenameChannel(channel *models.MonitoredChannel, newUsername string) (bool, error) {
+	renamed := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MonitoredChannel{}).
+			Where("channel_id = ? AND username = ?", channel.ChannelID, channel.Username).
+			Update("username", newUsername)
+		if result.Error != nil {
+			return fmt.Errorf("failed to rename channel %d to %s: %w", channel.ChannelID, newUsername, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.StreamerProfile{}).Where("channel_id = ?", channel.ChannelID).
+			Update("username", newUsername).Error; err != nil {
+			return fmt.Errorf("failed to rename streamer profile of channel %d: %w", channel.ChannelID, err)
+		}
+		alias := models.ChannelAlias{
+			ChannelID: channel.ChannelID,
+			Username:  channel.Username,
+			RenamedTo: newUsername,
+			RenamedAt: time.Now().UTC(),
+		}
+		if err := tx.Create(&alias).Error; err != nil {
+			return fmt.Errorf("failed to save alias %s of channel %d: %w", channel.Username, channel.ChannelID, err)
+		}
+		renamed = true
+		return nil
+	})
+	return renamed, err
+}
+
+// handleRename records a rename detected while fetching and restarts the channel's monitor under the new username.
+// Chat ingestion pauses for the few seconds the WebSocket takes to reconnect.
+func handleRename(channel *models.MonitoredChannel, newUsername string) {
+	renamed, err := RenameChannel(channel, newUsername)
+	if err != nil {
+		log.Printf("Error recording rename of channel %s (ID: %d) to %s: %v", channel.Username, channel.ChannelID, newUsername, err)
+		return
+	}
+	if !renamed {
+		return
+	}
+	log.Printf("Channel %s (ID: %d) was renamed to %s, restarting its monitor", channel.Username, channel.ChannelID, newUsername)
+
+	restarted := *channel
+	restarted.Username = newUsername
+	if StopMonitoringChannel(channel.ChannelID) {
+		go StartMonitoringChannel(&restarted)
+	}
+}
+
+// ResolveUsername returns the current username of a channel looked up by a username it went by before a rename,
+// or the username itself. Monitored channels win over aliases, as Kick lets another streamer take a freed username.
+func ResolveUsername(username string) string {
+	var monitored int64
+	if err := db.DB.Model(&models.MonitoredChannel{}).Where("username = ?", username).Count(&monitored).Error; err != nil || monitored > 0 {
+		return username
+	}
+
+	var current string
+	if err := db.DB.Raw(`
+		SELECT mc.username FROM channel_aliases ca
+		JOIN monitored_channels mc ON mc.channel_id = ca.channel_id
+		WHERE ca.username = ?
+		ORDER BY ca.renamed_at DESC
+		LIMIT 1`, username).Scan(&current).Error; err != nil {
+		log.Printf("Error resolving alias %s: %v", username, err)
+		return username
+	}
+	if current == "" {
+		return username
+	}
+	return current
+}
+
+// previousUsernames lists the usernames a channel went by, most recent first
+func previousUsernames(channelID uint) ([]string, error) {
+	var usernames []string
+	if err := db.DB.Model(&models.ChannelAlias{}).Where("channel_id = ?", channelID).
+		Order("renamed_at DESC").Pluck("username", &usernames).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch aliases of channel %d: %w", channelID, err)
+	}
+	return usernames, nil
+}
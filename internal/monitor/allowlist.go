@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// A locked-down deployment lists the only usernames that may be added through the API, in MONITOR_ALLOWLIST
+// (comma separated) and/or MONITOR_ALLOWLIST_FILE (one per line, # starts a comment). With neither set any
+// username can be added.
+var (
+	MonitorAllowlist     = util.GetEnvString("MONITOR_ALLOWLIST", "")
+	MonitorAllowlistFile = util.GetEnvString("MONITOR_ALLOWLIST_FILE", "")
+)
+
+// monitorAllowlist holds the lowercased allowed usernames, nil when every username is allowed
+var monitorAllowlist map[string]struct{}
+
+// LoadMonitorAllowlist reads the allow-list from the environment and its file, and warns about active channels
+// outside it: they keep being monitored, but can't be added back once removed.
+func LoadMonitorAllowlist(activeUsernames []string) error {
+	if MonitorAllowlist == "" && MonitorAllowlistFile == "" {
+		return nil
+	}
+	allowed := make(map[string]struct{})
+	for _, username := range strings.Split(MonitorAllowlist, ",") {
+		if username = strings.ToLower(strings.TrimSpace(username)); username != "" {
+			allowed[username] = struct{}{}
+		}
+	}
+	if MonitorAllowlistFile != "" {
+		f, err := os.Open(MonitorAllowlistFile)
+		if err != nil {
+			return fmt.Errorf("failed to open MONITOR_ALLOWLIST_FILE: %w", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			if username := strings.ToLower(strings.TrimSpace(line)); username != "" {
+				allowed[username] = struct{}{}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read MONITOR_ALLOWLIST_FILE: %w", err)
+		}
+	}
+	monitorAllowlist = allowed
+	log.Printf("Monitor allow-list enabled: %d username(s) may be added", len(allowed))
+
+	for _, username := range activeUsernames {
+		if !UsernameAllowed(username) {
+			log.Printf("Warning: active channel %s is not on the monitor allow-list", username)
+		}
+	}
+	return nil
+}
+
+// UsernameAllowed reports whether a channel may be added under username
+func UsernameAllowed(username string) bool {
+	if monitorAllowlist == nil {
+		return true
+	}
+	_, ok := monitorAllowlist[strings.ToLower(strings.TrimSpace(username))]
+	return ok
+}
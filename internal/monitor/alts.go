@@ -0,0 +1,293 @@
+package monitor
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+const (
+	AltUsernameMaxDistance  = 2   // Max edit distance between the usernames of likely alts
+	AltUsernameMinLength    = 5   // Shorter usernames are too alike by chance
+	AltSerialMinAccounts    = 3   // Accounts needed before a username stem or an ID run counts as serial
+	AltSerialMaxIDGap       = 20  // Max gap between consecutive Kick user IDs of serially created accounts
+	AltMessageMinDistinct   = 3   // Distinct messages each account needs before message patterns are compared
+	AltMessageMinSimilarity = 0.8 // Jaccard similarity of the normalized messages of two accounts
+	altCommonMessageUsers   = 50  // Messages sent by more users are chat-wide reactions, not a shared pattern
+	altMaxClusters          = 25
+)
+
+// Alt detection signals
+const (
+	AltSignalSimilarUsernames   = "similar_usernames"   // Small edit distance and never active at the same time
+	AltSignalSerialUsernames    = "serial_usernames"    // Same stem with a numeric suffix (bob_01, bob_02...)
+	AltSignalSerialAccountIDs   = "serial_account_ids"  // Kick user IDs created in a run
+	AltSignalIdenticalMessaging = "identical_messaging" // Near identical sets of messages
+)
+
+// AltCluster is a group of chatters that are likely the same person or one botting ring
+type AltCluster struct {
+	Accounts []AltAccount `json:"accounts"`
+	Signals  []string     `json:"signals"`
+}
+
+// AltAccount is a chatter of an AltCluster
+type AltAccount struct {
+	SenderID  int       `json:"sender_id"`
+	Username  string    `json:"username"`
+	Messages  int       `json:"messages"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type altChatter struct {
+	AltAccount
+	name     string              // Normalized username
+	messages map[string]struct{} // Normalized message contents
+}
+
+// altLinks joins chatters into clusters (union-find) and remembers which signals linked each cluster
+type altLinks struct {
+	parent  map[int]int
+	signals map[int]map[string]struct{}
+}
+
+func (l *altLinks) find(id int) int {
+	for l.parent[id] != id {
+		l.parent[id] = l.parent[l.parent[id]]
+		id = l.parent[id]
+	}
+	return id
+}
+
+func (l *altLinks) link(a, b int, signal string) {
+	rootA, rootB := l.find(a), l.find(b)
+	if rootA != rootB {
+		l.parent[rootB] = rootA
+		for s := range l.signals[rootB] {
+			l.signals[rootA][s] = struct{}{}
+		}
+		delete(l.signals, rootB)
+	}
+	l.signals[rootA][signal] = struct{}{}
+}
+
+// detectAltClusters looks for likely alt accounts among the chatters of a report window. Trusted chatters and app
+// accounts are never reported. messages must be sorted by MessageSendTime ascending.
+func detectAltClusters(messages []models.ChatMessage, trusted map[int]struct{}) []AltCluster {
+	chatters := make(map[int]*altChatter)
+	for _, msg := range messages {
+		if _, ok := trusted[msg.SenderID]; ok {
+			continue
+		}
+		if _, isApp := AppSenders[msg.SenderUsername]; isApp {
+			continue
+		}
+		chatter, ok := chatters[msg.SenderID]
+		if !ok {
+			chatter = &altChatter{
+				AltAccount: AltAccount{SenderID: msg.SenderID, Username: msg.SenderUsername, FirstSeen: msg.MessageSendTime},
+				name:       normalizeChatName(msg.SenderUsername),
+				messages:   make(map[string]struct{}),
+			}
+			chatters[msg.SenderID] = chatter
+		}
+		chatter.Messages++
+		chatter.LastSeen = msg.MessageSendTime
+		if _, normalized := copypastaKey(msg.Message); normalized != "" {
+			chatter.messages[normalized] = struct{}{}
+		}
+	}
+
+	links := &altLinks{parent: make(map[int]int), signals: make(map[int]map[string]struct{})}
+	for id := range chatters {
+		links.parent[id] = id
+		links.signals[id] = make(map[string]struct{})
+	}
+	linkSimilarUsernames(chatters, links)
+	linkSerialUsernames(chatters, links)
+	linkSerialAccountIDs(chatters, links)
+	linkIdenticalMessaging(chatters, links)
+
+	members := make(map[int][]AltAccount)
+	for id, chatter := range chatters {
+		root := links.find(id)
+		members[root] = append(members[root], chatter.AltAccount)
+	}
+
+	clusters := []AltCluster{}
+	for root, accounts := range members {
+		if len(accounts) < 2 {
+			continue
+		}
+		sort.Slice(accounts, func(i, j int) bool { return accounts[i].SenderID < accounts[j].SenderID })
+		signals := make([]string, 0, len(links.signals[root]))
+		for signal := range links.signals[root] {
+			signals = append(signals, signal)
+		}
+		sort.Strings(signals)
+		clusters = append(clusters, AltCluster{Accounts: accounts, Signals: signals})
+	}
+
+	// Clusters backed by more independent signals first, then bigger rings
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Signals) != len(clusters[j].Signals) {
+			return len(clusters[i].Signals) > len(clusters[j].Signals)
+		}
+		if len(clusters[i].Accounts) != len(clusters[j].Accounts) {
+			return len(clusters[i].Accounts) > len(clusters[j].Accounts)
+		}
+		return clusters[i].Accounts[0].SenderID < clusters[j].Accounts[0].SenderID
+	})
+	if len(clusters) > altMaxClusters {
+		clusters = clusters[:altMaxClusters]
+	}
+	return clusters
+}
+
+// linkSimilarUsernames links chatters whose usernames are within AltUsernameMaxDistance edits and who were never
+// active at the same time. Candidates share their name or a single-deletion variant of it, which finds names one
+// edit apart on each side (insertions, deletions, substitutions) without comparing all pairs.
+func linkSimilarUsernames(chatters map[int]*altChatter, links *altLinks) {
+	byVariant := make(map[string][]int)
+	for id, chatter := range chatters {
+		if len([]rune(chatter.name)) < AltUsernameMinLength {
+			continue
+		}
+		for variant := range deletionVariants(chatter.name) {
+			byVariant[variant] = append(byVariant[variant], id)
+		}
+	}
+
+	compared := make(map[[2]int]struct{})
+	for _, ids := range byVariant {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				pair := [2]int{min(ids[i], ids[j]), max(ids[i], ids[j])}
+				if _, done := compared[pair]; done {
+					continue
+				}
+				compared[pair] = struct{}{}
+
+				a, b := chatters[pair[0]], chatters[pair[1]]
+				if a.name == b.name || levenshtein(a.name, b.name) > AltUsernameMaxDistance {
+					continue
+				}
+				if a.FirstSeen.After(b.LastSeen) || b.FirstSeen.After(a.LastSeen) {
+					links.link(pair[0], pair[1], AltSignalSimilarUsernames)
+				}
+			}
+		}
+	}
+}
+
+// deletionVariants returns a name and every variant of it with one character removed
+func deletionVariants(name string) map[string]struct{} {
+	runes := []rune(name)
+	variants := map[string]struct{}{name: {}}
+	for i := range runes {
+		variants[string(runes[:i])+string(runes[i+1:])] = struct{}{}
+	}
+	return variants
+}
+
+// levenshtein is the edit distance between two strings, in runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// linkSerialUsernames links chatters sharing a username stem followed by digits, when at least
+// AltSerialMinAccounts of them chat
+func linkSerialUsernames(chatters map[int]*altChatter, links *altLinks) {
+	byStem := make(map[string][]int)
+	for id, chatter := range chatters {
+		stem := strings.TrimRightFunc(chatter.name, unicode.IsDigit)
+		if stem == chatter.name || len([]rune(strings.Trim(stem, "-_"))) < 3 {
+			continue
+		}
+		byStem[stem] = append(byStem[stem], id)
+	}
+	for _, ids := range byStem {
+		if len(ids) < AltSerialMinAccounts {
+			continue
+		}
+		for _, id := range ids[1:] {
+			links.link(ids[0], id, AltSignalSerialUsernames)
+		}
+	}
+}
+
+// linkSerialAccountIDs links runs of at least AltSerialMinAccounts chatters whose Kick user IDs are at most
+// AltSerialMaxIDGap apart, accounts registered back to back
+func linkSerialAccountIDs(chatters map[int]*altChatter, links *altLinks) {
+	ids := make([]int, 0, len(chatters))
+	for id := range chatters {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	runStart := 0
+	for i := 1; i <= len(ids); i++ {
+		if i < len(ids) && ids[i]-ids[i-1] <= AltSerialMaxIDGap {
+			continue
+		}
+		if i-runStart >= AltSerialMinAccounts {
+			for _, id := range ids[runStart+1 : i] {
+				links.link(ids[runStart], id, AltSignalSerialAccountIDs)
+			}
+		}
+		runStart = i
+	}
+}
+
+// linkIdenticalMessaging links chatters whose sets of distinct messages are nearly the same. Only pairs sharing an
+// uncommon message are compared.
+func linkIdenticalMessaging(chatters map[int]*altChatter, links *altLinks) {
+	byMessage := make(map[string][]int)
+	for id, chatter := range chatters {
+		if len(chatter.messages) < AltMessageMinDistinct {
+			continue
+		}
+		for message := range chatter.messages {
+			byMessage[message] = append(byMessage[message], id)
+		}
+	}
+
+	shared := make(map[[2]int]int)
+	for _, ids := range byMessage {
+		if len(ids) < 2 || len(ids) > altCommonMessageUsers {
+			continue
+		}
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				shared[[2]int{min(ids[i], ids[j]), max(ids[i], ids[j])}]++
+			}
+		}
+	}
+	for pair, common := range shared {
+		union := len(chatters[pair[0]].messages) + len(chatters[pair[1]].messages) - common
+		if float64(common)/float64(union) >= AltMessageMinSimilarity {
+			links.link(pair[0], pair[1], AltSignalIdenticalMessaging)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+)
+
+// analyticsQueryMaxRows caps the grouped rows an analytics query may return
+const analyticsQueryMaxRows = 10000
+
+// AnalyticsMetrics maps the metrics of GET /analytics/query to their aggregate over livestream_reports.
+// Only these expressions ever reach the SQL, user input never does.
+var AnalyticsMetrics = map[string]string{
+	"streams":                    "COUNT(*)",
+	"stream_minutes":             "SUM(duration_minutes)",
+	"hours_watched":              "SUM(hours_watched)",
+	"followers_gained":           "SUM(followers_gained)",
+	"followers_per_hour_watched": "SUM(followers_gained)::float / NULLIF(SUM(hours_watched), 0)",
+	"average_viewers":            "SUM(average_viewers * duration_minutes)::float / NULLIF(SUM(duration_minutes), 0)", // Weighted by stream length
+	"peak_viewers":               "MAX(peak_viewers)",
+	"messages":                   "SUM(total_messages)",
+	"unique_chatters":            "AVG(unique_chatters)",
+	"engagement":                 "AVG(engagement)",
+	"engagement_bot_adjusted":    "AVG(engagement_bot_adjusted)",
+}
+
+// AnalyticsDimensions maps the group_by/series_by values to the expression producing their label
+var AnalyticsDimensions = map[string]string{
+	"day":       "to_char(date_trunc('day', report_start_time AT TIME ZONE 'UTC'), 'YYYY-MM-DD')",
+	"week":      "to_char(date_trunc('week', report_start_time AT TIME ZONE 'UTC'), 'YYYY-MM-DD')",
+	"month":     "to_char(date_trunc('month', report_start_time AT TIME ZONE 'UTC'), 'YYYY-MM')",
+	"channel":   "username",
+	"category":  "COALESCE(NULLIF(category, ''), 'unknown')",
+	"simulcast": "CASE WHEN simulcast THEN 'simulcast' ELSE 'kick_only' END", // Simulcast chats are split, compare them apart
+}
+
+// AnalyticsQuery is an aggregate over the (non-chunk) livestream reports in [Start, End)
+type AnalyticsQuery struct {
+	Metrics    []string
+	GroupBy    string // X axis
+	SeriesBy   string // Optional, splits each metric into one series per channel or category
+	Start      time.Time
+	End        time.Time
+	ChannelIDs []uint
+	Categories []string
+	Simulcast  *bool // Only simulcast streams when true, only Kick-only streams when false
+}
+
+// AnalyticsResult is chart-ready: one series per metric (and SeriesBy value), each with points along GroupBy
+type AnalyticsResult struct {
+	Metrics  []string          `json:"metrics"`
+	GroupBy  string            `json:"group_by"`
+	SeriesBy string            `json:"series_by,omitempty"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Labels   []string          `json:"labels"` // Every X value present in any series, sorted
+	Series   []AnalyticsSeries `json:"series"`
+}
+
+type AnalyticsSeries struct {
+	Name   string           `json:"name"`
+	Metric string           `json:"metric"`
+	Group  string           `json:"group,omitempty"` // SeriesBy value
+	Points []AnalyticsPoint `json:"points"`
+}
+
+type AnalyticsPoint struct {
+	X string  `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Validate checks the query only uses known metrics and dimensions
+func (q AnalyticsQuery) Validate() error {
+	if len(q.Metrics) == 0 {
+		return fmt.Errorf("at least one metric is required")
+	}
+	for _, metric := range q.Metrics {
+		if _, ok := AnalyticsMetrics[metric]; !ok {
+			return fmt.Errorf("unknown metric %q", metric)
+		}
+	}
+	if _, ok := AnalyticsDimensions[q.GroupBy]; !ok {
+		return fmt.Errorf("unknown group_by %q", q.GroupBy)
+	}
+	if q.SeriesBy != "" {
+		if q.SeriesBy != "channel" && q.SeriesBy != "category" && q.SeriesBy != "simulcast" {
+			return fmt.Errorf("series_by must be channel, category or simulcast")
+		}
+		if q.SeriesBy == q.GroupBy {
+			return fmt.Errorf("series_by must differ from group_by")
+		}
+	}
+	if !q.Start.Before(q.End) {
+		return fmt.Errorf("from must be before to")
+	}
+	return nil
+}
+
+// RunAnalyticsQuery aggregates livestream reports as described by q
+func RunAnalyticsQuery(q AnalyticsQuery) (AnalyticsResult, error) {
+	result := AnalyticsResult{
+		Metrics:  q.Metrics,
+		GroupBy:  q.GroupBy,
+		SeriesBy: q.SeriesBy,
+		From:     q.Start,
+		To:       q.End,
+		Labels:   []string{},
+		Series:   []AnalyticsSeries{},
+	}
+	if err := q.Validate(); err != nil {
+		return result, err
+	}
+
+	seriesExpr := "''"
+	if q.SeriesBy != "" {
+		seriesExpr = AnalyticsDimensions[q.SeriesBy]
+	}
+	selects := []string{AnalyticsDimensions[q.GroupBy] + " AS x", seriesExpr + " AS series"}
+	for i, metric := range q.Metrics {
+		selects = append(selects, fmt.Sprintf("(%s)::float AS m%d", AnalyticsMetrics[metric], i))
+	}
+
+	query := db.DB.Table("livestream_reports").
+		Select(strings.Join(selects, ", ")).
+		Where("parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?", q.Start, q.End)
+	if len(q.ChannelIDs) > 0 {
+		query = query.Where("channel_id IN ?", q.ChannelIDs)
+	}
+	if len(q.Categories) > 0 {
+		query = query.Where("category IN ?", q.Categories)
+	}
+	if q.Simulcast != nil {
+		query = query.Where("simulcast = ?", *q.Simulcast)
+	}
+
+	rows, err := query.Group("x, series").Order("x, series").Limit(analyticsQueryMaxRows).Rows()
+	if err != nil {
+		return result, fmt.Errorf("failed to run analytics query: %w", err)
+	}
+	defer rows.Close()
+
+	type seriesKey struct{ metric, group string }
+	seriesByKey := make(map[seriesKey]*AnalyticsSeries)
+	var order []seriesKey
+	labels := make(map[string]struct{})
+
+	values := make([]sql.NullFloat64, len(q.Metrics))
+	dest := make([]any, 0, len(q.Metrics)+2)
+	var x, group string
+	dest = append(dest, &x, &group)
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return result, fmt.Errorf("failed to read analytics row: %w", err)
+		}
+		labels[x] = struct{}{}
+		for i, metric := range q.Metrics {
+			key := seriesKey{metric, group}
+			series, ok := seriesByKey[key]
+			if !ok {
+				name := metric
+				if group != "" {
+					name = group + " " + metric
+				}
+				series = &AnalyticsSeries{Name: name, Metric: metric, Group: group, Points: []AnalyticsPoint{}}
+				seriesByKey[key] = series
+				order = append(order, key)
+			}
+			series.Points = append(series.Points, AnalyticsPoint{X: x, Y: values[i].Float64})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read analytics rows: %w", err)
+	}
+
+	for label := range labels {
+		result.Labels = append(result.Labels, label)
+	}
+	sort.Strings(result.Labels)
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].metric != order[j].metric {
+			return indexOf(q.Metrics, order[i].metric) < indexOf(q.Metrics, order[j].metric)
+		}
+		return order[i].group < order[j].group
+	})
+	for _, key := range order {
+		result.Series = append(result.Series, *seriesByKey[key])
+	}
+	return result, nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Chat messages and events are associated with the livestream whose window (first start_time to the last
+// live sample in livestream_data, plus LivestreamEndGrace) contains their send time, so a failed fetch
+// or a late replayed message doesn't leave them with a NULL livestream_id.
+var (
+	LivestreamFreshnessLeeway = util.GetEnvDuration("LIVESTREAM_FRESHNESS_LEEWAY", 20*time.Second) // Slack added to FetchInterval when judging sample freshness
+	LivestreamEndGrace        = util.GetEnvDuration("LIVESTREAM_END_GRACE", 10*time.Minute)        // How long after the last live sample messages still belong to the stream
+	LivestreamPreShowWindow   = util.GetEnvDuration("LIVESTREAM_PRESHOW_WINDOW", 10*time.Minute)   // Orphaned messages this long before the start are pre-show chat of the stream
+)
+
+// livestreamWindowRefresh is the minimum time between DB lookups of a channel's livestream window
+const livestreamWindowRefresh = 30 * time.Second
+
+// livestreamWindow is the known time span of a channel's most recent livestream
+type livestreamWindow struct {
+	LivestreamID uint
+	Start        time.Time // Zero when the stream start time is unknown
+	LastSeen     time.Time // Last time the stream was seen live
+	RefreshedAt  time.Time
+}
+
+func (w livestreamWindow) contains(t time.Time) bool {
+	if !w.Start.IsZero() && t.Before(w.Start.Add(-LivestreamFreshnessLeeway)) {
+		return false
+	}
+	return !t.After(w.LastSeen.Add(LivestreamEndGrace))
+}
+
+// recordLivestreamSample extends the channel's livestream window with a live sample seen at seenAt
+func recordLivestreamSample(channelID, livestreamID uint, start, seenAt time.Time) {
+	updateLivestreamWindow(channelID, func(prev *livestreamWindow) livestreamWindow {
+		window := livestreamWindow{LivestreamID: livestreamID, Start: start, LastSeen: seenAt, RefreshedAt: time.Now()}
+		if prev != nil && prev.LivestreamID == livestreamID {
+			if window.Start.IsZero() || (!prev.Start.IsZero() && prev.Start.Before(window.Start)) {
+				window.Start = prev.Start
+			}
+			if prev.LastSeen.After(window.LastSeen) {
+				window.LastSeen = prev.LastSeen
+			}
+		}
+		return window
+	})
+}
+
+// livestreamForMessage returns the livestream a message sent at sentAt belongs to, or nil when the channel
+// wasn't live then
+func livestreamForMessage(channelID uint, sentAt time.Time) *uint {
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+
+	// Fast path: the HTTP fetcher saw the channel live within the last interval
+	if livestreamInfo, ok := latestLivestreamInfo(channelID); ok {
+		if livestreamInfo.IsLive && time.Since(livestreamInfo.FetchTime) <= FetchInterval+LivestreamFreshnessLeeway {
+			livestreamID := livestreamInfo.LivestreamID
+			return &livestreamID
+		}
+	}
+
+	window, ok := livestreamWindowFor(channelID, sentAt)
+	if !ok || !window.contains(sentAt) {
+		return nil
+	}
+	livestreamID := window.LivestreamID
+	return &livestreamID
+}
+
+// livestreamWindowFor returns the channel's latest livestream window, reloading it from livestream_data when
+// it is unknown or doesn't cover sentAt and hasn't been refreshed recently
+func livestreamWindowFor(channelID uint, sentAt time.Time) (livestreamWindow, bool) {
+	if window, ok := cachedLivestreamWindow(channelID); ok {
+		if window.contains(sentAt) || time.Since(window.RefreshedAt) < livestreamWindowRefresh {
+			return window, window.LivestreamID != 0
+		}
+	}
+
+	var row struct {
+		LivestreamID uint
+		Start        *time.Time
+		LastSeen     *time.Time
+	}
+	window := livestreamWindow{RefreshedAt: time.Now()}
+	err := db.DB.Model(&models.LivestreamData{}).
+		Select("livestream_id, MIN(start_time) AS start, MAX(created_at) AS last_seen").
+		Where("channel_id = ? AND is_live = ?", channelID, true).
+		Group("livestream_id").
+		Order("last_seen DESC").
+		Limit(1).
+		Scan(&row).Error
+	if err != nil {
+		log.Printf("Error loading livestream window for channel %d: %v", channelID, err)
+	} else if row.LastSeen != nil {
+		window.LivestreamID = row.LivestreamID
+		window.LastSeen = *row.LastSeen
+		if row.Start != nil {
+			window.Start = *row.Start
+		}
+	}
+
+	updateLivestreamWindow(channelID, func(*livestreamWindow) livestreamWindow { return window })
+	return window, window.LivestreamID != 0
+}
@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"encoding/json"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Kick badge types relevant to audience composition
+const (
+	BadgeBroadcaster = "broadcaster"
+	BadgeModerator   = "moderator"
+	BadgeSubscriber  = "subscriber"
+	BadgeFounder     = "founder"
+)
+
+// KickBadge is an entry of sender.identity.badges in chat messages
+type KickBadge struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Count  int    `json:"count,omitempty"` // Subscription months for subscriber badges
+	Active *bool  `json:"active,omitempty"`
+}
+
+// Audience groups a chatter can belong to, from most to least privileged
+const (
+	audienceUnknown = iota // Message ingested before badges were stored
+	audienceNonSubscriber
+	audienceSubscriber
+	audienceModerator
+)
+
+// AudienceComposition splits chat between moderators, subscribers and non-subscribers.
+// The broadcaster counts as a moderator and founders as subscribers.
+type AudienceComposition struct {
+	ModeratorMessages         int     `json:"moderator_messages"`
+	SubscriberMessages        int     `json:"subscriber_messages"`
+	NonSubscriberMessages     int     `json:"non_subscriber_messages"`
+	UnknownMessages           int     `json:"unknown_messages"` // No badge data (older messages)
+	ModeratorMessageShare     float64 `json:"moderator_message_share"`
+	SubscriberMessageShare    float64 `json:"subscriber_message_share"`
+	NonSubscriberMessageShare float64 `json:"non_subscriber_message_share"`
+
+	ModeratorChatters         int     `json:"moderator_chatters"`
+	SubscriberChatters        int     `json:"subscriber_chatters"`
+	NonSubscriberChatters     int     `json:"non_subscriber_chatters"`
+	ModeratorChatterShare     float64 `json:"moderator_chatter_share"`
+	SubscriberChatterShare    float64 `json:"subscriber_chatter_share"`
+	NonSubscriberChatterShare float64 `json:"non_subscriber_chatter_share"`
+
+	AverageSubscriberMonths float64 `json:"average_subscriber_months"`
+}
+
+// audienceGroup classifies a message by the badges stored with it
+func audienceGroup(rawBadges []byte) (group int, subscriberMonths int) {
+	if len(rawBadges) == 0 {
+		return audienceUnknown, 0
+	}
+
+	var badges []KickBadge
+	if err := json.Unmarshal(rawBadges, &badges); err != nil {
+		return audienceUnknown, 0
+	}
+
+	group = audienceNonSubscriber
+	for _, badge := range badges {
+		switch badge.Type {
+		case BadgeBroadcaster, BadgeModerator:
+			group = audienceModerator
+		case BadgeSubscriber, BadgeFounder:
+			if group < audienceSubscriber {
+				group = audienceSubscriber
+			}
+			subscriberMonths = max(subscriberMonths, badge.Count)
+		}
+	}
+	return group, subscriberMonths
+}
+
+// calculateAudienceComposition computes message and chatter shares per audience group.
+// Shares are computed over messages/chatters with badge data; a chatter counts in their most privileged group.
+func calculateAudienceComposition(messages []models.ChatMessage) AudienceComposition {
+	var composition AudienceComposition
+
+	chatterGroups := make(map[int]int)
+	chatterMonths := make(map[int]int)
+	for _, msg := range messages {
+		group, months := audienceGroup(msg.Badges)
+		switch group {
+		case audienceModerator:
+			composition.ModeratorMessages++
+		case audienceSubscriber:
+			composition.SubscriberMessages++
+		case audienceNonSubscriber:
+			composition.NonSubscriberMessages++
+		default:
+			composition.UnknownMessages++
+			continue
+		}
+		chatterGroups[msg.SenderID] = max(chatterGroups[msg.SenderID], group)
+		chatterMonths[msg.SenderID] = max(chatterMonths[msg.SenderID], months)
+	}
+
+	totalMonths := 0
+	for senderID, group := range chatterGroups {
+		switch group {
+		case audienceModerator:
+			composition.ModeratorChatters++
+		case audienceSubscriber:
+			composition.SubscriberChatters++
+			totalMonths += chatterMonths[senderID]
+		case audienceNonSubscriber:
+			composition.NonSubscriberChatters++
+		}
+	}
+
+	knownMessages := composition.ModeratorMessages + composition.SubscriberMessages + composition.NonSubscriberMessages
+	if knownMessages > 0 {
+		composition.ModeratorMessageShare = float64(composition.ModeratorMessages) / float64(knownMessages)
+		composition.SubscriberMessageShare = float64(composition.SubscriberMessages) / float64(knownMessages)
+		composition.NonSubscriberMessageShare = float64(composition.NonSubscriberMessages) / float64(knownMessages)
+	}
+	if len(chatterGroups) > 0 {
+		composition.ModeratorChatterShare = float64(composition.ModeratorChatters) / float64(len(chatterGroups))
+		composition.SubscriberChatterShare = float64(composition.SubscriberChatters) / float64(len(chatterGroups))
+		composition.NonSubscriberChatterShare = float64(composition.NonSubscriberChatters) / float64(len(chatterGroups))
+	}
+	if composition.SubscriberChatters > 0 {
+		composition.AverageSubscriberMonths = float64(totalMonths) / float64(composition.SubscriberChatters)
+	}
+
+	return composition
+}
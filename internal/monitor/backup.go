@@ -0,0 +1,402 @@
+package monitor
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"gorm.io/gorm"
+)
+
+// Backups go to any S3-compatible storage (AWS S3, MinIO, R2, B2...). An empty BACKUP_S3_ENDPOINT disables them.
+var (
+	BackupS3Endpoint  = util.GetEnvString("BACKUP_S3_ENDPOINT", "") // host[:port], without scheme
+	BackupS3Bucket    = util.GetEnvString("BACKUP_S3_BUCKET", "")
+	BackupS3Region    = util.GetEnvString("BACKUP_S3_REGION", "")
+	BackupS3AccessKey = util.GetEnvString("BACKUP_S3_ACCESS_KEY", "")
+	BackupS3SecretKey = util.GetEnvString("BACKUP_S3_SECRET_KEY", "")
+	BackupS3UseSSL    = util.GetEnvBool("BACKUP_S3_USE_SSL", true)
+	BackupS3PathStyle = util.GetEnvBool("BACKUP_S3_PATH_STYLE", false) // Path-style URLs, needed by most self-hosted stores
+	BackupS3Prefix    = util.GetEnvString("BACKUP_S3_PREFIX", "kick-monitor/backups/")
+	BackupInterval    = util.GetEnvDuration("BACKUP_INTERVAL", 24*time.Hour) // 0 disables scheduled backups, the commands still work
+	BackupKeep        = util.GetEnvInt("BACKUP_KEEP", 14)                    // Newest backups kept in the bucket, 0 keeps all
+)
+
+// BackupTables are the tables a backup covers, in the order they are restored so references resolve
+var BackupTables = []string{"monitored_channels", "streamer_profiles", "spam_reports", "livestream_reports"}
+
+const (
+	backupFormatVersion = 1
+	backupJobKey        = "scheduled"
+	backupKeyLayout     = "20060102T150405Z"
+	backupRestoreBatch  = 500
+)
+
+// ErrBackupsDisabled is returned when no S3-compatible storage is configured
+var ErrBackupsDisabled = errors.New("backups are disabled, set BACKUP_S3_ENDPOINT and BACKUP_S3_BUCKET")
+
+// A backup is a gzip compressed stream of JSON lines: a manifest, then one line per row, table after table in
+// BackupTables order. Rows are the to_json of the table row, so any column type round-trips.
+type backupLine struct {
+	Manifest *BackupManifest `json:"manifest,omitempty"`
+	Table    string          `json:"table,omitempty"`
+	Row      json.RawMessage `json:"row,omitempty"`
+}
+
+// BackupManifest opens a backup
+type BackupManifest struct {
+	Format        int       `json:"format"`
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int64     `json:"schema_version"` // Migration the database was at, a newer schema can't be restored
+	Tables        []string  `json:"tables"`
+}
+
+// BackupObject is a backup stored in the bucket
+type BackupObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// BackupResult is the outcome of a backup or a restore, in rows per table
+type BackupResult struct {
+	Key   string         `json:"key"`
+	Rows  map[string]int `json:"rows"`
+	Bytes int64          `json:"bytes,omitempty"` // Compressed size, for backups
+}
+
+// BackupsEnabled reports whether an S3-compatible storage is configured
+func BackupsEnabled() bool {
+	return BackupS3Endpoint != "" && BackupS3Bucket != ""
+}
+
+func newBackupClient() (*minio.Client, error) {
+	if !BackupsEnabled() {
+		return nil, ErrBackupsDisabled
+	}
+	lookup := minio.BucketLookupAuto
+	if BackupS3PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(BackupS3Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(BackupS3AccessKey, BackupS3SecretKey, ""),
+		Secure:       BackupS3UseSSL,
+		Region:       BackupS3Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup storage configuration: %w", err)
+	}
+	return client, nil
+}
+
+// RunBackups backs up the database every BackupInterval. Across instances the job lease lets one of them run it.
+// It blocks until stop is closed.
+func RunBackups(stop <-chan struct{}) {
+	if !BackupsEnabled() || BackupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		runDueBackup()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueBackup runs a backup when the last one stored is older than BackupInterval
+func runDueBackup() {
+	backups, err := ListBackups(context.Background())
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+		return
+	}
+	if len(backups) > 0 && time.Since(backups[0].LastModified) < BackupInterval {
+		return
+	}
+
+	lease, err := acquireJobLease(JobKindBackup, backupJobKey, nil)
+	if err != nil {
+		if !errors.Is(err, ErrJobLeased) && !errors.Is(err, ErrDraining) {
+			log.Printf("Error leasing the backup: %v", err)
+		}
+		return
+	}
+	if err := runLeasedJob(lease, runBackup); err != nil {
+		log.Printf("Error backing up the database: %v", err)
+	}
+}
+
+// StartBackup runs a backup in the background now, whatever the schedule, and returns its lease
+func StartBackup() (*models.JobLease, error) {
+	if !BackupsEnabled() {
+		return nil, ErrBackupsDisabled
+	}
+	lease, err := acquireJobLease(JobKindBackup, backupJobKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := runLeasedJob(lease, runBackup); err != nil {
+			log.Printf("Error backing up the database: %v", err)
+		}
+	}()
+	return lease, nil
+}
+
+func runBackupJob(*models.JobLease) error {
+	return runBackup()
+}
+
+func runBackup() error {
+	result, err := BackupDatabase(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Printf("Backed up %s (%d bytes)", result.Key, result.Bytes)
+	if err := pruneBackups(context.Background()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// BackupDatabase dumps BackupTables into a new backup in the bucket
+func BackupDatabase(ctx context.Context) (BackupResult, error) {
+	result := BackupResult{Rows: make(map[string]int, len(BackupTables))}
+	client, err := newBackupClient()
+	if err != nil {
+		return result, err
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now().UTC()
+	result.Key = BackupS3Prefix + "backup-" + now.Format(backupKeyLayout) + ".jsonl.gz"
+	manifest := BackupManifest{Format: backupFormatVersion, CreatedAt: now, SchemaVersion: version, Tables: BackupTables}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeBackup(ctx, writer, manifest, result.Rows))
+	}()
+	info, err := client.PutObject(ctx, BackupS3Bucket, result.Key, reader, -1, minio.PutObjectOptions{ContentType: "application/gzip"})
+	reader.CloseWithError(err) // Stops the dump if the upload failed
+	if err != nil {
+		return result, fmt.Errorf("failed to upload backup %s: %w", result.Key, err)
+	}
+	result.Bytes = info.Size
+	return result, nil
+}
+
+// writeBackup writes the manifest and the rows of every table to w, counting them in rows
+func writeBackup(ctx context.Context, w io.Writer, manifest BackupManifest, rows map[string]int) error {
+	// One snapshot for every table, so reports and the spam reports they point to match
+	tx := db.DB.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to start the backup transaction: %w", tx.Error)
+	}
+	defer tx.Rollback()
+
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	if err := encoder.Encode(backupLine{Manifest: &manifest}); err != nil {
+		return err
+	}
+	for _, table := range manifest.Tables {
+		// Table names only ever come from BackupTables
+		result, err := tx.Raw(fmt.Sprintf("SELECT to_json(t)::text FROM %s t", table)).Rows()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		for result.Next() {
+			var row string
+			if err := result.Scan(&row); err != nil {
+				result.Close()
+				return fmt.Errorf("failed to read a row of %s: %w", table, err)
+			}
+			if err := encoder.Encode(backupLine{Table: table, Row: json.RawMessage(row)}); err != nil {
+				result.Close()
+				return err
+			}
+			rows[table]++
+		}
+		err = result.Err()
+		result.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+	}
+	return gz.Close()
+}
+
+// ListBackups lists the backups in the bucket, newest first
+func ListBackups(ctx context.Context) ([]BackupObject, error) {
+	client, err := newBackupClient()
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupObject{}
+	for object := range client.ListObjects(ctx, BackupS3Bucket, minio.ListObjectsOptions{Prefix: BackupS3Prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", object.Err)
+		}
+		if !strings.HasSuffix(object.Key, ".jsonl.gz") {
+			continue
+		}
+		backups = append(backups, BackupObject{Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Key > backups[j].Key }) // Keys embed the UTC time
+	return backups, nil
+}
+
+// pruneBackups deletes the backups beyond the newest BackupKeep
+func pruneBackups(ctx context.Context) error {
+	if BackupKeep <= 0 {
+		return nil
+	}
+	backups, err := ListBackups(ctx)
+	if err != nil || len(backups) <= BackupKeep {
+		return err
+	}
+	client, err := newBackupClient()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[BackupKeep:] {
+		if err := client.RemoveObject(ctx, BackupS3Bucket, backup.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete old backup %s: %w", backup.Key, err)
+		}
+		log.Printf("Deleted old backup %s", backup.Key)
+	}
+	return nil
+}
+
+// RestoreBackup loads a backup into the database. Rows whose primary key already exists are left as they are,
+// so restoring into a live database only fills in what is missing. Columns added since the backup was taken get
+// their defaults.
+func RestoreBackup(ctx context.Context, key string) (BackupResult, error) {
+	result := BackupResult{Key: key, Rows: make(map[string]int, len(BackupTables))}
+	client, err := newBackupClient()
+	if err != nil {
+		return result, err
+	}
+	object, err := client.GetObject(ctx, BackupS3Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return result, fmt.Errorf("failed to download backup %s: %w", key, err)
+	}
+	defer object.Close()
+	gz, err := gzip.NewReader(object)
+	if err != nil {
+		return result, fmt.Errorf("failed to read backup %s: %w", key, err)
+	}
+	defer gz.Close()
+	decoder := json.NewDecoder(gz)
+
+	var first backupLine
+	if err := decoder.Decode(&first); err != nil || first.Manifest == nil {
+		return result, fmt.Errorf("backup %s has no manifest", key)
+	}
+	if first.Manifest.Format != backupFormatVersion {
+		return result, fmt.Errorf("backup %s has unsupported format %d", key, first.Manifest.Format)
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return result, err
+	}
+	if first.Manifest.SchemaVersion > version {
+		return result, fmt.Errorf("backup %s was taken at schema version %d, newer than this database (%d)", key, first.Manifest.SchemaVersion, version)
+	}
+
+	allowed := make(map[string]struct{}, len(BackupTables))
+	for _, table := range BackupTables {
+		allowed[table] = struct{}{}
+	}
+	table := ""
+	var batch []json.RawMessage
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inserted, err := restoreRows(db.DB.WithContext(ctx), table, batch)
+		result.Rows[table] += inserted
+		batch = batch[:0]
+		return err
+	}
+	for {
+		var line backupLine
+		if err := decoder.Decode(&line); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return result, fmt.Errorf("failed to read backup %s: %w", key, err)
+		}
+		if _, ok := allowed[line.Table]; !ok {
+			return result, fmt.Errorf("backup %s holds rows of unknown table %q", key, line.Table)
+		}
+		if line.Table != table || len(batch) >= backupRestoreBatch {
+			if err := flush(); err != nil {
+				return result, err
+			}
+			table = line.Table
+		}
+		batch = append(batch, line.Row)
+	}
+	return result, flush()
+}
+
+// restoreRows inserts the rows missing from table and returns how many were inserted. Only the columns both the
+// rows and the table have are written.
+func restoreRows(tx *gorm.DB, table string, rows []json.RawMessage) (int, error) {
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(rows[0], &present); err != nil {
+		return 0, fmt.Errorf("invalid row of %s: %w", table, err)
+	}
+	var existing []string
+	if err := tx.Raw(`SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ?`, table).Scan(&existing).Error; err != nil {
+		return 0, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+	}
+	columns := make([]string, 0, len(existing))
+	for _, column := range existing {
+		if _, ok := present[column]; ok {
+			columns = append(columns, `"`+strings.ReplaceAll(column, `"`, `""`)+`"`)
+		}
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("rows of %s share no column with the table", table)
+	}
+
+	list := strings.Join(columns, ", ")
+	payload, err := json.Marshal(rows)
+	if err != nil {
+		return 0, err
+	}
+	insert := tx.Exec(fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, ?::json) ON CONFLICT DO NOTHING",
+		table, list, list, table), string(payload))
+	if insert.Error != nil {
+		return 0, fmt.Errorf("failed to restore rows of %s: %w", table, insert.Error)
+	}
+	return int(insert.RowsAffected), nil
+}
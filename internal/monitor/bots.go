@@ -0,0 +1,281 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm/clause"
+)
+
+var (
+	BotProbabilityThreshold = util.GetEnvFloat("BOT_PROBABILITY_THRESHOLD", 0.5)         // Probability from which a chatter counts as a likely bot
+	BotChannelWindow        = util.GetEnvDuration("BOT_CHANNEL_WINDOW", 30*24*time.Hour) // How far back chatting in other channels counts
+	BotAccountWindow        = util.GetEnvDuration("BOT_ACCOUNT_WINDOW", 90*24*time.Hour) // Streams an account's probability is averaged over
+)
+
+const (
+	botMinIntervals  = 3   // Gaps between messages needed before their regularity counts
+	botRegularCV     = 0.5 // Below this coefficient of variation the gaps look scripted; people chat far more irregularly
+	botManyChannels  = 5.0 // Other channels chatted in for the full cross-channel weight
+	botHistoryLength = 20  // Streams listed with an account's probability
+)
+
+// botWeights are the coefficients of the logistic classifier. There is no labelled data to fit them on: they are
+// set so that a chatter needs about two strong signals to cross a probability of 0.5.
+var botWeights = struct {
+	Bias, Regularity, Duplicates, EmoteOnly, Channels, Username float64
+}{Bias: -3, Regularity: 2.5, Duplicates: 3, EmoteOnly: 1, Channels: 1.5, Username: 0.8}
+
+// BotFeatures are the behavioural features a chatter's bot probability is computed from, for one stream
+type BotFeatures struct {
+	Messages           int      `json:"messages"`
+	IntervalCV         *float64 `json:"interval_cv,omitempty"` // Coefficient of variation of the gaps between messages, low for scripted senders
+	DuplicateRatio     float64  `json:"duplicate_ratio"`       // Messages repeating one the chatter already sent
+	EmoteOnlyShare     float64  `json:"emote_only_share"`
+	Channels           int      `json:"channels"` // Channels chatted in over BotChannelWindow, this one included
+	SuspiciousUsername bool     `json:"suspicious_username"`
+	KnownApp           bool     `json:"known_app,omitempty"` // A known chat bot or chat app, always a bot
+	Trusted            bool     `json:"trusted,omitempty"`   // On the channel's trusted list, never a bot
+}
+
+// Probability is the chance the chatter is a bot, from the logistic classifier
+func (f BotFeatures) Probability() float64 {
+	switch {
+	case f.Trusted:
+		return 0
+	case f.KnownApp:
+		return 1
+	}
+	z := botWeights.Bias + botWeights.Duplicates*f.DuplicateRatio + botWeights.EmoteOnly*f.EmoteOnlyShare
+	if f.IntervalCV != nil {
+		z += botWeights.Regularity * math.Max(0, 1-*f.IntervalCV/botRegularCV)
+	}
+	z += botWeights.Channels * math.Min(1, float64(max(0, f.Channels-1))/botManyChannels)
+	if f.SuspiciousUsername {
+		z += botWeights.Username
+	}
+	return 1 / (1 + math.Exp(-z))
+}
+
+// chatterBotScore is the classification of one chatter of a stream
+type chatterBotScore struct {
+	Username    string
+	Features    BotFeatures
+	Probability float64
+}
+
+// classifyChatters computes the bot probability of every chatter of a stream. channels holds how many channels
+// each sender chatted in over BotChannelWindow, this one included; senders missing from it count one.
+func classifyChatters(messages []models.ChatMessage, channels map[int]int, trusted map[int]struct{}) map[int]chatterBotScore {
+	bySender := make(map[int][]models.ChatMessage)
+	for _, msg := range messages {
+		bySender[msg.SenderID] = append(bySender[msg.SenderID], msg)
+	}
+
+	scores := make(map[int]chatterBotScore, len(bySender))
+	for senderID, sent := range bySender {
+		sort.Slice(sent, func(i, j int) bool { return sent[i].MessageSendTime.Before(sent[j].MessageSendTime) })
+		username := sent[0].SenderUsername
+		_, isTrusted := trusted[senderID]
+		_, isApp := AppSenders[strings.ToLower(username)]
+		features := BotFeatures{
+			Messages:           len(sent),
+			Channels:           max(1, channels[senderID]),
+			SuspiciousUsername: suspiciousUsernameChecker.MatchString(username),
+			KnownApp:           isApp,
+			Trusted:            isTrusted,
+		}
+
+		seen := make(map[string]struct{}, len(sent))
+		duplicates, emoteOnly := 0, 0
+		for _, msg := range sent {
+			normalized := util.NormalizeChatMessage(msg.Message)
+			if _, repeated := seen[normalized]; repeated {
+				duplicates++
+			}
+			seen[normalized] = struct{}{}
+			if onlyEmotesRegex.MatchString(strings.TrimSpace(msg.Message)) {
+				emoteOnly++
+			}
+		}
+		features.DuplicateRatio = roundTo(float64(duplicates)/float64(len(sent)), 3)
+		features.EmoteOnlyShare = roundTo(float64(emoteOnly)/float64(len(sent)), 3)
+		features.IntervalCV = intervalVariation(sent)
+
+		scores[senderID] = chatterBotScore{Username: username, Features: features, Probability: roundTo(features.Probability(), 3)}
+	}
+	return scores
+}
+
+// intervalVariation is the coefficient of variation of the gaps between messages sorted by send time, nil with
+// fewer than botMinIntervals gaps
+func intervalVariation(sent []models.ChatMessage) *float64 {
+	if len(sent) <= botMinIntervals {
+		return nil
+	}
+	gaps := make([]float64, 0, len(sent)-1)
+	mean := 0.0
+	for i := 1; i < len(sent); i++ {
+		gap := sent[i].MessageSendTime.Sub(sent[i-1].MessageSendTime).Seconds()
+		gaps = append(gaps, gap)
+		mean += gap
+	}
+	mean /= float64(len(gaps))
+	if mean <= 0 {
+		return nil
+	}
+	variance := 0.0
+	for _, gap := range gaps {
+		variance += (gap - mean) * (gap - mean)
+	}
+	cv := roundTo(math.Sqrt(variance/float64(len(gaps)))/mean, 3)
+	return &cv
+}
+
+// botAdjustedShare returns the expected share of human chatters among the senders of messages, and the number of
+// likely bots. Senders without a score count as human.
+func botAdjustedShare(messages []models.ChatMessage, scores map[int]chatterBotScore) (humanShare float64, likelyBots int) {
+	senders := make(map[int]struct{})
+	for _, msg := range messages {
+		senders[msg.SenderID] = struct{}{}
+	}
+	if len(senders) == 0 {
+		return 1, 0
+	}
+	humans := 0.0
+	for senderID := range senders {
+		probability := scores[senderID].Probability
+		humans += 1 - probability
+		if probability >= BotProbabilityThreshold {
+			likelyBots++
+		}
+	}
+	return humans / float64(len(senders)), likelyBots
+}
+
+// chatterChannelCounts returns, per sender, the channels they chatted in over BotChannelWindow according to the
+// bot scores of earlier streams, plus this one
+func chatterChannelCounts(senderIDs []int, channelID uint) (map[int]int, error) {
+	counts := make(map[int]int, len(senderIDs))
+	const batchSize = 5000
+	for start := 0; start < len(senderIDs); start += batchSize {
+		batch := senderIDs[start:min(start+batchSize, len(senderIDs))]
+		var rows []struct {
+			SenderID int
+			Channels int
+		}
+		if err := db.DB.Model(&models.ChatterBotScore{}).
+			Select("sender_id, COUNT(DISTINCT channel_id) AS channels").
+			Where("sender_id IN ? AND channel_id <> ? AND created_at >= ?", batch, channelID, time.Now().Add(-BotChannelWindow)).
+			Group("sender_id").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to count channels of chatters: %w", err)
+		}
+		for _, row := range rows {
+			counts[row.SenderID] = row.Channels
+		}
+	}
+	for _, senderID := range senderIDs {
+		counts[senderID]++
+	}
+	return counts, nil
+}
+
+// saveChatterBotScores stores the bot probabilities of a stream's chatters, replacing those of an earlier run
+func saveChatterBotScores(channelID, livestreamID uint, scores map[int]chatterBotScore) error {
+	rows := make([]models.ChatterBotScore, 0, len(scores))
+	now := time.Now().UTC()
+	for senderID, score := range scores {
+		features, err := json.Marshal(score.Features)
+		if err != nil {
+			return fmt.Errorf("failed to marshal bot features of chatter %d: %w", senderID, err)
+		}
+		rows = append(rows, models.ChatterBotScore{
+			SenderID:       senderID,
+			LivestreamID:   livestreamID,
+			ChannelID:      channelID,
+			SenderUsername: score.Username,
+			Probability:    score.Probability,
+			Messages:       score.Features.Messages,
+			Features:       features,
+			CreatedAt:      now,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sender_id"}, {Name: "livestream_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sender_username", "probability", "messages", "features", "created_at"}),
+	}).CreateInBatches(rows, 1000).Error; err != nil {
+		return fmt.Errorf("failed to save bot scores of livestream %d: %w", livestreamID, err)
+	}
+	return nil
+}
+
+// AccountBotScore is the bot probability of an account: the average of its per-stream probabilities over
+// BotAccountWindow, weighted by the messages it sent in each
+type AccountBotScore struct {
+	SenderID    int              `json:"sender_id"`
+	Username    string           `json:"username"`
+	Probability float64          `json:"probability"`
+	LikelyBot   bool             `json:"likely_bot"`
+	Streams     int              `json:"streams"`
+	Channels    int              `json:"channels"`
+	Messages    int              `json:"messages"`
+	History     []StreamBotScore `json:"history"` // Latest first, up to botHistoryLength streams
+}
+
+type StreamBotScore struct {
+	LivestreamID uint            `json:"livestream_id"`
+	ChannelID    uint            `json:"channel_id"`
+	Probability  float64         `json:"probability"`
+	Messages     int             `json:"messages"`
+	Features     json.RawMessage `json:"features"`
+	Time         time.Time       `json:"time"`
+}
+
+// GetAccountBotScore returns the bot probability of a chatter, nil when none of their recent streams was scored
+func GetAccountBotScore(senderID int) (*AccountBotScore, error) {
+	var rows []models.ChatterBotScore
+	if err := db.DB.Where("sender_id = ? AND created_at >= ?", senderID, time.Now().Add(-BotAccountWindow)).
+		Order("created_at DESC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch bot scores of chatter %d: %w", senderID, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	score := AccountBotScore{SenderID: senderID, Username: rows[0].SenderUsername, Streams: len(rows), History: []StreamBotScore{}}
+	channels := make(map[uint]struct{})
+	weighted := 0.0
+	for _, row := range rows {
+		channels[row.ChannelID] = struct{}{}
+		score.Messages += row.Messages
+		weighted += row.Probability * float64(row.Messages)
+		if len(score.History) < botHistoryLength {
+			score.History = append(score.History, StreamBotScore{
+				LivestreamID: row.LivestreamID,
+				ChannelID:    row.ChannelID,
+				Probability:  row.Probability,
+				Messages:     row.Messages,
+				Features:     row.Features,
+				Time:         row.CreatedAt,
+			})
+		}
+	}
+	score.Channels = len(channels)
+	if score.Messages > 0 {
+		score.Probability = roundTo(weighted/float64(score.Messages), 3)
+	}
+	score.LikelyBot = score.Probability >= BotProbabilityThreshold
+	return &score, nil
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"log"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+const (
+	reportMemoryBudget = 512 << 20 // Memory set aside per concurrent report, chat messages dominate it
+	maxReportWorkers   = 32
+	maxFetchSlots      = 64
+)
+
+// ConcurrencyBudget sizes the concurrent work of this instance from the CPUs and memory it may use. Each value
+// can be pinned with its environment variable, 0 derives it from the host.
+type ConcurrencyBudget struct {
+	CPUs              int    `json:"cpus"`
+	MemoryBytes       int64  `json:"memory_bytes"`
+	MemorySource      string `json:"memory_source"`      // GOMEMLIMIT, cgroup, host or unknown
+	ReportWorkers     int    `json:"report_workers"`     // Goroutines analysing the messages of a report (REPORT_WORKERS)
+	ConcurrentReports int    `json:"concurrent_reports"` // Reports generated at once (MAX_CONCURRENT_REPORTS)
+	FetchConcurrency  int    `json:"fetch_concurrency"`  // Proxy requests in flight (FETCH_CONCURRENCY)
+	DBBatchSize       int    `json:"db_batch_size"`      // Rows per batched insert (DB_BATCH_SIZE)
+}
+
+// Capacity is the concurrency budget of this instance, computed once at startup
+var Capacity = newConcurrencyBudget()
+
+var reportSlots = make(chan struct{}, Capacity.ConcurrentReports)
+
+func newConcurrencyBudget() ConcurrencyBudget {
+	budget := ConcurrencyBudget{CPUs: util.CPULimit()}
+	budget.MemoryBytes, budget.MemorySource = util.MemoryLimit()
+
+	budget.ReportWorkers = min(budget.CPUs, maxReportWorkers)
+	budget.ConcurrentReports = budget.CPUs
+	if budget.MemoryBytes > 0 {
+		budget.ConcurrentReports = min(budget.ConcurrentReports, int(budget.MemoryBytes/reportMemoryBudget))
+	}
+	budget.ConcurrentReports = max(budget.ConcurrentReports, 1)
+	budget.FetchConcurrency = min(max(budget.CPUs*4, 4), maxFetchSlots) // Fetches wait on the proxy, not the CPU
+	budget.DBBatchSize = 500
+	if budget.MemoryBytes > 0 && budget.MemoryBytes < 1<<30 {
+		budget.DBBatchSize = 200
+	}
+
+	budget.ReportWorkers = envOverride("REPORT_WORKERS", budget.ReportWorkers)
+	budget.ConcurrentReports = envOverride("MAX_CONCURRENT_REPORTS", budget.ConcurrentReports)
+	budget.FetchConcurrency = envOverride("FETCH_CONCURRENCY", budget.FetchConcurrency)
+	budget.DBBatchSize = envOverride("DB_BATCH_SIZE", budget.DBBatchSize)
+	return budget
+}
+
+// LogCapacity logs the concurrency budget at startup
+func LogCapacity() {
+	log.Printf("Concurrency budget: %d CPU(s), %d MiB memory (%s) -> %d report worker(s), %d concurrent report(s), %d fetch slot(s), batches of %d",
+		Capacity.CPUs, Capacity.MemoryBytes>>20, Capacity.MemorySource, Capacity.ReportWorkers, Capacity.ConcurrentReports, Capacity.FetchConcurrency, Capacity.DBBatchSize)
+}
+
+// envOverride returns the positive value of an environment variable, or the derived value
+func envOverride(key string, derived int) int {
+	if value := util.GetEnvInt(key, 0); value > 0 {
+		return value
+	}
+	return derived
+}
+
+// acquire blocks until one of the slots is free and returns the function releasing it
+func acquire(slots chan struct{}) func() {
+	slots <- struct{}{}
+	return func() { <-slots }
+}
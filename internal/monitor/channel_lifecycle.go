@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// A deactivated channel keeps its row and everything collected about it, and monitoring resumes once it's
+// reactivated. A removed channel is gone from the monitored channels along with its configuration; its reports and
+// profile stay readable unless it is purged, which deletes every row of the channel.
+
+// channelConfigTables hold what was set up for a channel, removed with it as nothing reads them without it
+var channelConfigTables = []string{"report_recipients", "chatter_list_entries", "team_channels", "competitor_set_members", "channel_webhooks"}
+
+// ChannelRemoval is what removing a channel deleted
+type ChannelRemoval struct {
+	ChannelID   uint             `json:"channel_id"`
+	Username    string           `json:"username"`
+	Purged      bool             `json:"purged"`
+	DeletedRows map[string]int64 `json:"deleted_rows"` // Per table, tables without rows of the channel left out
+}
+
+// SetChannelActive turns the monitoring of a channel on or off and reports whether it changed. Deactivating stops
+// the channel's fetch and WebSocket routines on this instance; other instances of a cluster stop theirs at their
+// next rebalance, which only monitors active channels.
+func SetChannelActive(channel *models.MonitoredChannel, active bool) (bool, error) {
+	// Conditional on the old status so of two concurrent requests only the one flipping it starts monitoring
+	update := db.DB.Model(channel).Where("is_active = ?", !active).Update("is_active", active)
+	if update.Error != nil {
+		return false, fmt.Errorf("failed to set is_active of channel %s to %t: %w", channel.Username, active, update.Error)
+	}
+	channel.IsActive = active
+	if !active {
+		// Also when it was already inactive, in case routines outlived an earlier deactivation
+		StopMonitoringChannel(channel.ChannelID)
+	}
+	if update.RowsAffected == 0 {
+		return false, nil
+	}
+	if active {
+		go StartMonitoringChannel(channel)
+	}
+	return true, nil
+}
+
+// RemoveChannel stops monitoring a channel and deletes it with its configuration. With purge, every row of the
+// channel goes too: chat, viewer samples, reports, spam findings and the profile.
+func RemoveChannel(ctx context.Context, channel *models.MonitoredChannel, purge bool) (ChannelRemoval, error) {
+	StopMonitoringChannel(channel.ChannelID)
+	removal := ChannelRemoval{ChannelID: channel.ChannelID, Username: channel.Username, Purged: purge, DeletedRows: map[string]int64{}}
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		del := func(table, where string, args ...any) error {
+			result := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", table, where), args...)
+			if result.Error != nil {
+				return fmt.Errorf("failed to delete %s of channel %d: %w", table, channel.ChannelID, result.Error)
+			}
+			if result.RowsAffected > 0 {
+				removal.DeletedRows[table] = result.RowsAffected
+			}
+			return nil
+		}
+
+		if !purge {
+			for _, table := range append([]string{"monitored_channels"}, channelConfigTables...) {
+				if err := del(table, "channel_id = ?", channel.ChannelID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		columns, err := tablesByColumn(tx, "channel_id", "chatroom_id", "livestream_id")
+		if err != nil {
+			return err
+		}
+		// Tables keyed by livestream alone go first, while the viewer samples still tell the channel's livestreams
+		var livestreamIDs []uint
+		if err := tx.Raw(`SELECT livestream_id FROM livestream_data WHERE channel_id = ?
+			UNION SELECT livestream_id FROM livestream_reports WHERE channel_id = ?`, channel.ChannelID, channel.ChannelID).
+			Scan(&livestreamIDs).Error; err != nil {
+			return fmt.Errorf("failed to list livestreams of channel %d: %w", channel.ChannelID, err)
+		}
+		for _, table := range columns["livestream_id"] {
+			if slices.Contains(columns["channel_id"], table) || slices.Contains(columns["chatroom_id"], table) || len(livestreamIDs) == 0 {
+				continue
+			}
+			if err := del(table, "livestream_id IN ?", livestreamIDs); err != nil {
+				return err
+			}
+		}
+		for _, table := range columns["chatroom_id"] {
+			if err := del(table, "chatroom_id = ?", channel.ChatroomID); err != nil {
+				return err
+			}
+		}
+		for _, table := range columns["channel_id"] {
+			if slices.Contains(columns["chatroom_id"], table) {
+				continue // monitored_channels, deleted by chatroom already
+			}
+			if err := del(table, "channel_id = ?", channel.ChannelID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ChannelRemoval{}, err
+	}
+	log.Printf("Removed channel %s (ID: %d), purged: %t, deleted rows: %v", channel.Username, channel.ChannelID, purge, removal.DeletedRows)
+	return removal, nil
+}
+
+// tablesByColumn lists, for each of the columns, the tables of the schema that have it
+func tablesByColumn(tx *gorm.DB, columns ...string) (map[string][]string, error) {
+	var rows []struct {
+		TableName  string
+		ColumnName string
+	}
+	if err := tx.Raw(`SELECT c.table_name, c.column_name FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE' AND c.column_name IN ?
+		ORDER BY c.table_name`, columns).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list the tables of the schema: %w", err)
+	}
+	tables := make(map[string][]string, len(columns))
+	for _, row := range rows {
+		tables[row.ColumnName] = append(tables[row.ColumnName], row.TableName)
+	}
+	return tables, nil
+}
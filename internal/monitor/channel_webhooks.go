@@ -0,0 +1,28 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/notify"
+)
+
+// ChannelWebhookEndpoints returns the webhooks of the channel with the username, for notify.ChannelEndpoints. Alerts
+// about subsystems match no channel and have none.
+func ChannelWebhookEndpoints(username string) ([]notify.Endpoint, error) {
+	var webhooks []models.ChannelWebhook
+	if err := db.DB.Where("channel_id IN (?)", db.DB.Model(&models.MonitoredChannel{}).Select("channel_id").Where("username = ?", username)).
+		Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks of channel %s: %w", username, err)
+	}
+	endpoints := make([]notify.Endpoint, len(webhooks))
+	for i, webhook := range webhooks {
+		endpoints[i] = notify.Endpoint{URL: webhook.URL, Secret: webhook.Secret, Untrusted: true}
+		if webhook.PreviousSecretExpiresAt != nil && webhook.PreviousSecretExpiresAt.After(time.Now()) {
+			endpoints[i].PreviousSecret = webhook.PreviousSecret
+		}
+	}
+	return endpoints, nil
+}
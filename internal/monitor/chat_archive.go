@@ -0,0 +1,284 @@
+package monitor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Chat messages older than ChatArchiveAfter move out of chat_messages into gzip compressed bundles in
+// chat_message_archives, one per chatroom, livestream and UTC day, in monthly partitions. Reports, counters and
+// spam incidents stay where they are; a report regenerated later reads the archived messages back.
+var (
+	ChatArchiveAfter    = util.GetEnvDuration("CHAT_ARCHIVE_AFTER", 0) // 0 keeps every message hot
+	ChatArchiveInterval = util.GetEnvDuration("CHAT_ARCHIVE_INTERVAL", 6*time.Hour)
+	ChatArchiveBatch    = util.GetEnvInt("CHAT_ARCHIVE_BATCH", 500) // Bundles archived per run at most
+)
+
+// chatArchiveJobKey is the key of the archiver's lease, one run at a time across the cluster
+const chatArchiveJobKey = "chat_messages"
+
+// chatArchiveDeleteBatch is how many archived messages are deleted per statement
+const chatArchiveDeleteBatch = 5000
+
+// archiveGroupsSQL lists the oldest bundles to archive. Messages of a livestream wait for its report, and orphans
+// until the reassociation gave up on them.
+const archiveGroupsSQL = `
+SELECT m.chatroom_id, m.livestream_id, (m.message_send_time AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS messages
+FROM chat_messages m
+WHERE m.message_send_time < @cutoff
+  AND (m.livestream_id IS NULL OR EXISTS (SELECT 1 FROM livestream_reports r WHERE r.livestream_id = m.livestream_id))
+GROUP BY 1, 2, 3
+ORDER BY 3, 1
+LIMIT @limit`
+
+type chatArchiveGroup struct {
+	ChatroomID   uint
+	LivestreamID *uint
+	Day          time.Time
+	Messages     int
+}
+
+// ChatArchiveRun is the outcome of one archiver run
+type ChatArchiveRun struct {
+	Bundles         int   `json:"bundles"`
+	Messages        int   `json:"messages"`
+	RawBytes        int64 `json:"raw_bytes"`
+	CompressedBytes int64 `json:"compressed_bytes"`
+}
+
+// RunChatArchiver archives old chat messages every ChatArchiveInterval. It blocks until stop is closed.
+func RunChatArchiver(stop <-chan struct{}) {
+	if ChatArchiveAfter <= 0 {
+		return
+	}
+	if ChatArchiveAfter < ReassociateLookback {
+		log.Printf("Warning: CHAT_ARCHIVE_AFTER %s is below REASSOCIATE_LOOKBACK, archiving messages after %s instead",
+			ChatArchiveAfter, ReassociateLookback)
+	}
+
+	ticker := time.NewTicker(ChatArchiveInterval)
+	defer ticker.Stop()
+
+	for {
+		lease, err := acquireJobLease(JobKindChatArchive, chatArchiveJobKey, nil)
+		switch {
+		case err == nil:
+			if err := runLeasedJob(lease, runChatArchive); err != nil {
+				log.Printf("Error archiving chat messages: %v", err)
+			}
+		case !errors.Is(err, ErrJobLeased) && !errors.Is(err, ErrDraining):
+			log.Printf("Error leasing the chat archiver: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runChatArchiveJob(*models.JobLease) error {
+	return runChatArchive()
+}
+
+func runChatArchive() error {
+	run, err := ArchiveChatMessages(time.Now().Add(-max(ChatArchiveAfter, ReassociateLookback)), ChatArchiveBatch)
+	if run.Bundles > 0 {
+		log.Printf("Archived %d chat messages in %d bundles (%d bytes, %d compressed)", run.Messages, run.Bundles,
+			run.RawBytes, run.CompressedBytes)
+	}
+	return err
+}
+
+// ArchiveChatMessages moves the chat messages sent before cutoff into the archive, up to limit bundles
+func ArchiveChatMessages(cutoff time.Time, limit int) (ChatArchiveRun, error) {
+	var run ChatArchiveRun
+	var groups []chatArchiveGroup
+	if err := db.DB.Raw(archiveGroupsSQL, map[string]any{"cutoff": cutoff, "limit": limit}).Scan(&groups).Error; err != nil {
+		return run, fmt.Errorf("failed to list chat messages to archive: %w", err)
+	}
+
+	for _, group := range groups {
+		if IsDraining() {
+			return run, ErrDraining
+		}
+		archive, err := archiveChatGroup(group)
+		if err != nil {
+			return run, err
+		}
+		run.Bundles++
+		run.Messages += archive.Messages
+		run.RawBytes += archive.RawBytes
+		run.CompressedBytes += int64(len(archive.Data))
+	}
+	return run, nil
+}
+
+// archiveChatGroup moves the messages of one bundle into a compressed archive row, in one transaction
+func archiveChatGroup(group chatArchiveGroup) (*models.ChatMessageArchive, error) {
+	day := time.Date(group.Day.Year(), group.Day.Month(), group.Day.Day(), 0, 0, 0, 0, time.UTC)
+	var archive *models.ChatMessageArchive
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("chatroom_id = ? AND message_send_time >= ? AND message_send_time < ?", group.ChatroomID, day, day.AddDate(0, 0, 1))
+		if group.LivestreamID != nil {
+			query = query.Where("livestream_id = ?", *group.LivestreamID)
+		} else {
+			query = query.Where("livestream_id IS NULL")
+		}
+		var messages []models.ChatMessage
+		if err := query.Order("message_send_time ASC").Find(&messages).Error; err != nil {
+			return fmt.Errorf("failed to load chat messages to archive: %w", err)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		raw, err := json.Marshal(messages)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chat messages to archive: %w", err)
+		}
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(raw); err != nil {
+			return fmt.Errorf("failed to compress chat messages: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress chat messages: %w", err)
+		}
+
+		if err := ensureChatArchivePartition(tx, day); err != nil {
+			return err
+		}
+		archive = &models.ChatMessageArchive{
+			ID:           uuid.New(),
+			Day:          day,
+			ChatroomID:   group.ChatroomID,
+			LivestreamID: group.LivestreamID,
+			Messages:     len(messages),
+			FirstSentAt:  messages[0].MessageSendTime,
+			LastSentAt:   messages[len(messages)-1].MessageSendTime,
+			RawBytes:     int64(len(raw)),
+			Data:         compressed.Bytes(),
+		}
+		if err := tx.Create(archive).Error; err != nil {
+			return fmt.Errorf("failed to save chat archive: %w", err)
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i, msg := range messages {
+			ids[i] = msg.ID
+		}
+		for start := 0; start < len(ids); start += chatArchiveDeleteBatch {
+			batch := ids[start:min(start+chatArchiveDeleteBatch, len(ids))]
+			if err := tx.Where("id IN ?", batch).Delete(&models.ChatMessage{}).Error; err != nil {
+				return fmt.Errorf("failed to delete archived chat messages: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive chat of chatroom %d on %s: %w", group.ChatroomID, day.Format(time.DateOnly), err)
+	}
+	if archive == nil {
+		return &models.ChatMessageArchive{}, nil
+	}
+	return archive, nil
+}
+
+// ensureChatArchivePartition creates the monthly partition of chat_message_archives holding day
+func ensureChatArchivePartition(tx *gorm.DB, day time.Time) error {
+	month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	name := fmt.Sprintf("chat_message_archives_%04d_%02d", month.Year(), month.Month())
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF chat_message_archives FOR VALUES FROM ('%s') TO ('%s')",
+		name, month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly))
+	if err := tx.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to create chat archive partition %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadArchivedMessages returns the archived chat messages of a livestream within the window, sorted by send time
+func loadArchivedMessages(livestreamID uint, window ReportWindow) ([]models.ChatMessage, error) {
+	messages, err := readChatArchives(db.DB.Where("livestream_id = ?", livestreamID), window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat archives of livestream %d: %w", livestreamID, err)
+	}
+	return messages, nil
+}
+
+// loadChatroomArchive returns the archived chat messages of a chatroom between from and to, either of which may be
+// zero
+func loadChatroomArchive(chatroomID uint, from, to time.Time) ([]models.ChatMessage, error) {
+	var window ReportWindow
+	if !from.IsZero() {
+		window.Start = &from
+	}
+	if !to.IsZero() {
+		window.End = &to
+	}
+	messages, err := readChatArchives(db.DB.Where("chatroom_id = ?", chatroomID), window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat archives of chatroom %d: %w", chatroomID, err)
+	}
+	return messages, nil
+}
+
+// readChatArchives decodes the archives matching query that overlap the window, and returns their messages within it
+func readChatArchives(query *gorm.DB, window ReportWindow) ([]models.ChatMessage, error) {
+	if window.Start != nil {
+		query = query.Where("last_sent_at >= ?", *window.Start)
+	}
+	if window.End != nil {
+		query = query.Where("first_sent_at < ?", *window.End)
+	}
+	var archives []models.ChatMessageArchive
+	if err := query.Order("first_sent_at ASC").Find(&archives).Error; err != nil {
+		return nil, err
+	}
+
+	var messages []models.ChatMessage
+	for _, archive := range archives {
+		bundle, err := decodeChatArchive(archive.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chat archive %s: %w", archive.ID.String(), err)
+		}
+		for _, msg := range bundle {
+			if (window.Start == nil || !msg.MessageSendTime.Before(*window.Start)) &&
+				(window.End == nil || msg.MessageSendTime.Before(*window.End)) {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages, nil
+}
+
+func decodeChatArchive(data []byte) ([]models.ChatMessage, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	var messages []models.ChatMessage
+	if err := json.Unmarshal(raw, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
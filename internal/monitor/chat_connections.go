@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// chatConnectionHeartbeat is how often a connected chat bumps its LastSeenAt
+const chatConnectionHeartbeat = time.Minute
+
+// How a chat connection receives messages
+const (
+	ChatSourceWebSocket = "websocket"
+	ChatSourcePolling   = "polling" // The messages API polled through the proxy, recent messages only
+)
+
+// chatConnection records the period a channel's chat is connected, through the WebSocket or polling
+type chatConnection struct {
+	id   uuid.UUID
+	done chan struct{}
+}
+
+// openChatConnection records that the channel's chat is connected until close is called
+func openChatConnection(channelID uint, source string) *chatConnection {
+	now := time.Now().UTC()
+	row := models.ChatConnection{ID: uuid.New(), ChannelID: channelID, ConnectedAt: now, LastSeenAt: now, Source: source}
+	if err := db.DB.Create(&row).Error; err != nil {
+		log.Printf("Error recording chat connection of channel %d: %v", channelID, err)
+	}
+
+	conn := &chatConnection{id: row.ID, done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(chatConnectionHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-conn.done:
+				return
+			case <-ticker.C:
+				if err := db.DB.Model(&models.ChatConnection{}).Where("id = ?", conn.id).
+					Update("last_seen_at", time.Now().UTC()).Error; err != nil {
+					log.Printf("Error updating chat connection of channel %d: %v", channelID, err)
+				}
+			}
+		}
+	}()
+	return conn
+}
+
+func (c *chatConnection) close() {
+	close(c.done)
+	now := time.Now().UTC()
+	if err := db.DB.Model(&models.ChatConnection{}).Where("id = ?", c.id).
+		Updates(map[string]any{"last_seen_at": now, "disconnected_at": now}).Error; err != nil {
+		log.Printf("Error recording chat disconnect %s: %v", c.id.String(), err)
+	}
+}
+
+// timeRange is a half-open [Start, End) period
+type timeRange struct {
+	Start, End time.Time
+}
+
+// loadChatConnections returns the periods the channel's chat was connected between start and end, merged and
+// clipped to the range, and among them those it was only polled. tracked is false when no connection was
+// recorded for the channel before end, i.e. the stream predates connection tracking and its chat coverage is
+// unknown.
+func loadChatConnections(channelID uint, start, end time.Time) (connected, polled []timeRange, tracked bool, err error) {
+	var rows []models.ChatConnection
+	if err := db.DB.Where("channel_id = ? AND connected_at < ? AND last_seen_at >= ?", channelID, end, start.Add(-chatConnectionHeartbeat)).
+		Order("connected_at ASC").Find(&rows).Error; err != nil {
+		return nil, nil, false, err
+	}
+	if len(rows) == 0 {
+		var earlier int64
+		if err := db.DB.Model(&models.ChatConnection{}).Where("channel_id = ? AND connected_at < ?", channelID, end).
+			Limit(1).Count(&earlier).Error; err != nil {
+			return nil, nil, false, err
+		}
+		return nil, nil, earlier > 0, nil
+	}
+
+	var streamed, polling []timeRange
+	for _, row := range rows {
+		until := row.LastSeenAt
+		if row.DisconnectedAt != nil {
+			until = *row.DisconnectedAt
+		} else if time.Since(row.LastSeenAt) < 2*chatConnectionHeartbeat {
+			until = time.Now() // Still connected
+		}
+		if row.Source == ChatSourcePolling {
+			polling = append(polling, timeRange{Start: row.ConnectedAt, End: until})
+		} else {
+			streamed = append(streamed, timeRange{Start: row.ConnectedAt, End: until})
+		}
+	}
+	streamed = mergeTimeRanges(streamed, start, end)
+	polled = subtractTimeRanges(mergeTimeRanges(polling, start, end), streamed)
+	connected = mergeTimeRanges(append(append([]timeRange(nil), streamed...), polled...), start, end)
+	return connected, polled, true, nil
+}
+
+// mergeTimeRanges clips ranges to [start, end) and merges the overlapping ones, sorted by start
+func mergeTimeRanges(ranges []timeRange, start, end time.Time) []timeRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+	var merged []timeRange
+	for _, r := range ranges {
+		if r.Start.Before(start) {
+			r.Start = start
+		}
+		if r.End.After(end) {
+			r.End = end
+		}
+		if !r.End.After(r.Start) {
+			continue
+		}
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subtractTimeRanges returns the parts of ranges not covered by cut, both merged and sorted by start
+func subtractTimeRanges(ranges, cut []timeRange) []timeRange {
+	var result []timeRange
+	for _, r := range ranges {
+		for _, c := range cut {
+			if !c.End.After(r.Start) {
+				continue
+			}
+			if !c.Start.Before(r.End) {
+				break
+			}
+			if c.Start.After(r.Start) {
+				result = append(result, timeRange{Start: r.Start, End: c.Start})
+			}
+			r.Start = c.End
+		}
+		if r.End.After(r.Start) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
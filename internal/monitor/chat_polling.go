@@ -0,0 +1,218 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// Polling fallback for hosts that block outbound WebSockets. Once the WebSocket keeps failing to connect, the chat
+// is polled from Kick's messages API through the proxy until it connects again. Polling only sees the messages
+// still in the API's recent list, so busy chats lose some; reports flag the polled share of a stream.
+var (
+	ChatPollFallback      = util.GetEnvBool("CHAT_POLL_FALLBACK", true)
+	ChatPollAfterFailures = util.GetEnvInt("CHAT_POLL_AFTER_FAILURES", 3)             // Consecutive failed WebSocket connections before polling
+	ChatPollInterval      = util.GetEnvDuration("CHAT_POLL_INTERVAL", 10*time.Second) // Backs off like the channel fetches once a proxy budget is exhausted
+)
+
+// chatSeenIDs is how many chat message IDs are remembered per channel, so messages both the WebSocket and a poll
+// returned are stored once
+const chatSeenIDs = 5000
+
+// kickChatEvent is the event polled messages are stored under, the one the WebSocket delivers them with
+const kickChatEvent = "App\\Events\\ChatMessageEvent"
+
+// KickChatMessagesResponse is the payload of Kick's recent chat messages API
+type KickChatMessagesResponse struct {
+	Data struct {
+		Messages []json.RawMessage `json:"messages"`
+	} `json:"data"`
+}
+
+// kickPolledMessage is a message of the messages API, shaped like a WebSocket one but for its chatroom ID
+type kickPolledMessage struct {
+	ChatMessageEventData
+	ChatID int `json:"chat_id"`
+}
+
+// polledChatMessage is a message returned by a poll, with its raw payload for reaction events
+type polledChatMessage struct {
+	Data ChatMessageEventData
+	Raw  []byte
+}
+
+// chatPoller polls a channel's chat while its WebSocket is down
+type chatPoller struct {
+	channel *models.MonitoredChannel
+	stopped chan struct{}
+	done    chan struct{}
+	catchUp bool // Poll once more before stopping, for the messages sent until the WebSocket took over
+}
+
+var chatPollers sync.Map // channelID -> *chatPoller
+
+// startChatPoller starts polling the channel's chat until stop is called
+func startChatPoller(channel *models.MonitoredChannel) *chatPoller {
+	poller := &chatPoller{channel: channel, stopped: make(chan struct{}), done: make(chan struct{})}
+	chatPollers.Store(channel.ChannelID, poller)
+	log.Printf("WebSocket unreachable for channel %s (ID: %d), polling its chat every %s instead", channel.Username, channel.ChannelID, chatPollInterval(channel.Username))
+	go poller.run()
+	return poller
+}
+
+// stop stops polling and waits for the poller to finish. With catchUp it polls a last time first, once the
+// WebSocket is back; messages both return are stored once.
+func (p *chatPoller) stop(catchUp bool) {
+	p.catchUp = catchUp
+	close(p.stopped)
+	<-p.done
+	chatPollers.CompareAndDelete(p.channel.ChannelID, p)
+}
+
+func (p *chatPoller) run() {
+	defer close(p.done)
+	connection := openChatConnection(p.channel.ChannelID, ChatSourcePolling)
+	defer connection.close()
+
+	for {
+		p.poll()
+		if sleepOrStop(chatPollInterval(p.channel.Username), p.stopped) {
+			if p.catchUp {
+				p.poll()
+				log.Printf("WebSocket back for channel %s (ID: %d), stopped polling its chat", p.channel.Username, p.channel.ChannelID)
+			}
+			return
+		}
+	}
+}
+
+func (p *chatPoller) poll() {
+	messages, err := fetchRecentChatMessages(p.channel)
+	if err != nil {
+		log.Printf("Error polling chat of channel %s (ID: %d): %v", p.channel.Username, p.channel.ChannelID, err)
+		return
+	}
+	trackIngestion(func() { ingestPolledMessages(p.channel, messages) })
+}
+
+// fetchRecentChatMessages returns the recent chat messages Kick lists for a channel, oldest first
+func fetchRecentChatMessages(channel *models.MonitoredChannel) ([]polledChatMessage, error) {
+	jsonString, err := fetchViaProxy(fmt.Sprintf("https://kick.com/api/v2/channels/%d/messages", channel.ChannelID), channel.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var response KickChatMessagesResponse
+	if err := json.Unmarshal([]byte(jsonString), &response); err != nil {
+		return nil, fmt.Errorf("error unmarshalling chat messages for %s: %w", channel.Username, err)
+	}
+	messages := make([]polledChatMessage, 0, len(response.Data.Messages))
+	for _, raw := range response.Data.Messages {
+		var msg kickPolledMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			log.Printf("Error unmarshalling polled chat message for %s: %v, raw message: %s", channel.Username, err, raw)
+			continue
+		}
+		if msg.ChatroomID == 0 {
+			msg.ChatroomID = msg.ChatID
+		}
+		if msg.ChatroomID == 0 {
+			msg.ChatroomID = int(channel.ChatroomID)
+		}
+		messages = append(messages, polledChatMessage{Data: msg.ChatMessageEventData, Raw: raw})
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].Data.CreatedAt < messages[j].Data.CreatedAt })
+	return messages, nil
+}
+
+// ingestPolledMessages stores the polled messages neither the WebSocket nor an earlier poll stored. Messages
+// older than what this instance remembers, e.g. after a restart, are looked up in the database.
+func ingestPolledMessages(channel *models.MonitoredChannel, messages []polledChatMessage) {
+	var unseen []uuid.UUID
+	for _, msg := range messages {
+		if id, err := uuid.Parse(msg.Data.ID); err == nil && !chatMessageSeen(channel.ChannelID, id) {
+			unseen = append(unseen, id)
+		}
+	}
+	if len(unseen) == 0 {
+		return
+	}
+	var stored []uuid.UUID
+	if err := db.DB.Model(&models.ChatMessage{}).Where("id IN ?", unseen).Pluck("id", &stored).Error; err != nil {
+		log.Printf("Error checking polled chat messages of %s: %v", channel.Username, err)
+		return
+	}
+	for _, id := range stored {
+		markChatMessageSeen(channel.ChannelID, id)
+	}
+
+	currentLivestreamID := livestreamForMessage(channel.ChannelID, time.Now())
+	for _, msg := range messages {
+		ingestChatMessage(channel, kickChatEvent, msg.Data, msg.Raw, currentLivestreamID)
+	}
+}
+
+// chatPollInterval returns how often the channel's chat is polled, backing off with its fetches
+func chatPollInterval(username string) time.Duration {
+	if pollInterval(username) > FetchInterval {
+		return ChatPollInterval * time.Duration(max(ProxyBudgetBackoff, 1))
+	}
+	return ChatPollInterval
+}
+
+// IsChatPolling reports whether the channel's chat is polled because its WebSocket is down
+func IsChatPolling(channelID uint) bool {
+	_, ok := chatPollers.Load(channelID)
+	return ok
+}
+
+// chatPollingChannels returns how many channels have their chat polled
+func chatPollingChannels() int {
+	count := 0
+	chatPollers.Range(func(any, any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// recentIDs remembers the last n IDs added
+type recentIDs struct {
+	ids  map[uuid.UUID]struct{}
+	ring []uuid.UUID
+	next int
+}
+
+func newRecentIDs(n int) *recentIDs {
+	return &recentIDs{ids: make(map[uuid.UUID]struct{}, n), ring: make([]uuid.UUID, 0, n)}
+}
+
+// add remembers id, forgetting the oldest once full, and reports whether it was new
+func (r *recentIDs) add(id uuid.UUID) bool {
+	if _, ok := r.ids[id]; ok {
+		return false
+	}
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, id)
+	} else {
+		delete(r.ids, r.ring[r.next])
+		r.ring[r.next] = id
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.ids[id] = struct{}{}
+	return true
+}
+
+func (r *recentIDs) contains(id uuid.UUID) bool {
+	_, ok := r.ids[id]
+	return ok
+}
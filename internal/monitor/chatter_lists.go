@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Chatter lists
+const (
+	ChatterListExcluded = "excluded" // Left out of unique chatters, engagement and spam analysis
+	ChatterListTrusted  = "trusted"  // Never reported as a suspicious chatter
+)
+
+// chatterLists are the excluded and trusted sender IDs of a channel
+type chatterLists struct {
+	Excluded map[int]struct{}
+	Trusted  map[int]struct{}
+}
+
+// IsValidChatterList reports whether list names a chatter list
+func IsValidChatterList(list string) bool {
+	return list == ChatterListExcluded || list == ChatterListTrusted
+}
+
+// SetChatterList puts a chatter on a list of the channel, moving it from the other list if needed
+func SetChatterList(entry models.ChatterListEntry) (models.ChatterListEntry, error) {
+	if !IsValidChatterList(entry.List) {
+		return models.ChatterListEntry{}, fmt.Errorf("unknown chatter list %q", entry.List)
+	}
+	if err := db.DB.Save(&entry).Error; err != nil {
+		return models.ChatterListEntry{}, fmt.Errorf("failed to add chatter %d to the %s list of channel %d: %w", entry.SenderID, entry.List, entry.ChannelID, err)
+	}
+	return entry, nil
+}
+
+// RemoveChatterFromLists takes a chatter off the lists of a channel. It reports false when it wasn't on one.
+func RemoveChatterFromLists(channelID uint, senderID int) (bool, error) {
+	result := db.DB.Where("channel_id = ? AND sender_id = ?", channelID, senderID).Delete(&models.ChatterListEntry{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to remove chatter %d from the lists of channel %d: %w", senderID, channelID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func loadChatterLists(channelID uint) (chatterLists, error) {
+	lists := chatterLists{Excluded: make(map[int]struct{}), Trusted: make(map[int]struct{})}
+
+	var entries []models.ChatterListEntry
+	if err := db.DB.Where("channel_id = ?", channelID).Find(&entries).Error; err != nil {
+		return lists, fmt.Errorf("failed to load chatter lists of channel %d: %w", channelID, err)
+	}
+	for _, entry := range entries {
+		switch entry.List {
+		case ChatterListExcluded:
+			lists.Excluded[entry.SenderID] = struct{}{}
+		case ChatterListTrusted:
+			lists.Trusted[entry.SenderID] = struct{}{}
+		}
+	}
+	return lists, nil
+}
+
+// chatterExclusion is what a report left out because of the channel's exclusion list
+type chatterExclusion struct {
+	Chatters int
+	Messages int
+}
+
+// excludeChatters drops the messages of excluded chatters
+func excludeChatters(messages []models.ChatMessage, excluded map[int]struct{}) ([]models.ChatMessage, chatterExclusion) {
+	var exclusion chatterExclusion
+	if len(excluded) == 0 {
+		return messages, exclusion
+	}
+
+	seen := make(map[int]struct{})
+	kept := messages[:0]
+	for _, msg := range messages {
+		if _, ok := excluded[msg.SenderID]; ok {
+			exclusion.Messages++
+			seen[msg.SenderID] = struct{}{}
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	exclusion.Chatters = len(seen)
+	return kept, exclusion
+}
+
+// dropTrustedChatters removes the suspicion signals of trusted chatters before they are scored
+func dropTrustedChatters(metrics *ReportMetrics, trusted map[int]struct{}) {
+	if len(trusted) == 0 {
+		return
+	}
+	for senderID := range trusted {
+		delete(metrics.SuspicionSignals, senderID)
+	}
+	kept := metrics.SuspiciousChattersList[:0]
+	for _, chatter := range metrics.SuspiciousChattersList {
+		if _, ok := trusted[chatter.UserID]; !ok {
+			kept = append(kept, chatter)
+		}
+	}
+	metrics.SuspiciousChattersList = kept
+}
@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Streams longer than ReportChunkThreshold are split into ReportChunkDuration chunks, each with its own report,
+// plus a parent rollup report covering the whole stream. A zero threshold disables splitting.
+var (
+	ReportChunkThreshold = util.GetEnvDuration("REPORT_CHUNK_THRESHOLD", 0)
+	ReportChunkDuration  = util.GetEnvDuration("REPORT_CHUNK_DURATION", 24*time.Hour)
+)
+
+// generateChunkedReports builds and persists the chunk reports of a long stream and their parent rollup.
+// The parent carries whole-stream analytics but leaves the timelines to its chunks.
+func generateChunkedReports(in reportInput) error {
+	chunkDuration := ReportChunkDuration
+	if chunkDuration <= 0 {
+		chunkDuration = 24 * time.Hour
+	}
+
+	parent, parentSpam := buildLivestreamReport(in)
+	parent.ViewerCountsTimeline = []byte("[]")
+	parent.RawViewerCountsTimeline = []byte("[]")
+	parent.MessageCountsTimeline = []byte("[]")
+
+	var chunks []models.LivestreamReport
+	var chunkSpams []models.SpamReport
+	for start := in.StartTime; start.Before(in.EndTime); start = start.Add(chunkDuration) {
+		end := start.Add(chunkDuration)
+		if end.After(in.EndTime) {
+			end = in.EndTime
+		}
+
+		chunkInput := in
+		chunkInput.StartTime, chunkInput.EndTime = start, end
+		chunkInput.ChatMessages = messagesBetween(in.ChatMessages, start, end)
+		chunkInput.ViewerCounts = samplesBetween(in.ViewerCounts, start.Add(-ReportTimeBlock), end.Add(ReportTimeBlock))
+		chunkInput.Reactions = reactionsBetween(in.Reactions, start, end)
+		chunkInput.Clips = clipsBetween(in.Clips, start, end)
+		chunkInput.ChatConnected = mergeTimeRanges(append([]timeRange(nil), in.ChatConnected...), start, end)
+		chunkInput.ChatPolled = mergeTimeRanges(append([]timeRange(nil), in.ChatPolled...), start, end)
+		if end.Before(in.EndTime) {
+			chunkInput.FollowersUntil = end // Only the last chunk gets the attribution window
+		}
+		if in.Sampling != nil {
+			chunkInput.Sampling = &messageSampling{Messages: in.Sampling.Messages, Persisted: in.Sampling.Persisted}
+		}
+		if len(chunkInput.ChatMessages) == 0 {
+			continue
+		}
+
+		chunk, chunkSpam := buildLivestreamReport(chunkInput)
+		chunk.ParentReportID = &parent.ID
+		chunk.ChunkIndex = len(chunks) + 1
+		chunks = append(chunks, chunk)
+		chunkSpams = append(chunkSpams, chunkSpam)
+	}
+
+	chunkIDs := make([]uuid.UUID, len(chunks))
+	for i := range chunks {
+		chunks[i].ChunkCount = len(chunks)
+		chunkIDs[i] = chunks[i].ID
+	}
+	parent.ChunkCount = len(chunks)
+	chunkIDsJSON, err := json.Marshal(chunkIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk report IDs for livestream %d: %w", in.LivestreamID, err)
+	}
+	parent.ChunkReportIDs = chunkIDsJSON
+
+	reports := append([]models.LivestreamReport{parent}, chunks...)
+	spamReports := append([]models.SpamReport{parentSpam}, chunkSpams...)
+	in.Progress.setPhase(ReportPhasePersisting)
+	if err := persistLivestreamReports(in.ChannelID, in.LivestreamID, reports, spamReports); err != nil {
+		return err
+	}
+
+	go resolveReportVOD(parent.ID, in.ChannelUsername, in.LivestreamID)
+	go sendReportEmails(parent)
+	log.Printf("Successfully generated livestream report for livestream ID %d split into %d chunk(s) (Report ID: %s)",
+		in.LivestreamID, len(chunks), parent.ID.String())
+	return nil
+}
+
+// messagesBetween returns the messages sent in [start, end)
+func messagesBetween(messages []models.ChatMessage, start, end time.Time) []models.ChatMessage {
+	var out []models.ChatMessage
+	for _, msg := range messages {
+		if !msg.MessageSendTime.Before(start) && msg.MessageSendTime.Before(end) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// samplesBetween returns the viewer samples recorded in [start, end]
+func samplesBetween(samples []models.LivestreamData, start, end time.Time) []models.LivestreamData {
+	var out []models.LivestreamData
+	for _, sample := range samples {
+		if !sample.CreatedAt.Before(start) && !sample.CreatedAt.After(end) {
+			out = append(out, sample)
+		}
+	}
+	return out
+}
+
+// reactionsBetween returns the reactions received in [start, end)
+func reactionsBetween(reactions []models.ReactionEvent, start, end time.Time) []models.ReactionEvent {
+	var out []models.ReactionEvent
+	for _, reaction := range reactions {
+		if !reaction.CreatedAt.Before(start) && reaction.CreatedAt.Before(end) {
+			out = append(out, reaction)
+		}
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ClipChatWindow is how much chat before a clip is compared with the stream's average chat rate, roughly what
+// the clip captured
+const ClipChatWindow = 2 * time.Minute
+
+// clipLinkPattern matches kick.com/<channel>/clips/<id> and kick.com/<channel>?clip=<id> links
+var clipLinkPattern = regexp.MustCompile(`(?i)kick\.com/[a-z0-9_-]+(?:/clips/|/?\?clip=)(clip_[a-z0-9]+)`)
+
+// KickClipResponse is the part of https://kick.com/api/v2/clips/{id} we use
+type KickClipResponse struct {
+	Clip struct {
+		ID           string `json:"id"`
+		Title        string `json:"title"`
+		Duration     int    `json:"duration"` // Seconds
+		ThumbnailURL string `json:"thumbnail_url"`
+		VideoURL     string `json:"video_url"`
+		CreatedAt    string `json:"created_at"`
+		Creator      struct {
+			Username string `json:"username"`
+		} `json:"creator"`
+	} `json:"clip"`
+}
+
+// ClipCreated is an entry of the ClipsCreated section of a livestream report
+type ClipCreated struct {
+	ClipID          string     `json:"clip_id"`
+	URL             string     `json:"url"`
+	Title           string     `json:"title,omitempty"`
+	Creator         string     `json:"creator,omitempty"` // Empty until the clip metadata was resolved
+	SharedBy        string     `json:"shared_by"`
+	SharedAt        time.Time  `json:"shared_at"`
+	ClippedAt       *time.Time `json:"clipped_at,omitempty"`
+	DurationSeconds int        `json:"duration_seconds,omitempty"`
+	ThumbnailURL    string     `json:"thumbnail_url,omitempty"`
+	ChatRate        float64    `json:"chat_rate"`         // Messages per minute in ClipChatWindow before the clip
+	ChatRateVsAvg   float64    `json:"chat_rate_vs_avg"`  // ChatRate relative to the stream's average, 2 = twice as busy
+	Viewers         int        `json:"viewers,omitempty"` // Last viewer count before the clip
+}
+
+// detectClipLinks records the Kick clips linked in a chat message and resolves new ones in the background.
+// Sampled out messages are checked too, clips are rare and worth keeping.
+func detectClipLinks(channel *models.MonitoredChannel, msg *models.ChatMessage) {
+	if !strings.Contains(strings.ToLower(msg.Message), "clip") {
+		return
+	}
+
+	sharedAt := msg.MessageSendTime
+	if sharedAt.IsZero() {
+		sharedAt = msg.CreatedAt
+	}
+	for _, match := range clipLinkPattern.FindAllStringSubmatch(msg.Message, -1) {
+		clip := models.KickClip{
+			ID:           match[1],
+			ChannelID:    channel.ChannelID,
+			LivestreamID: msg.LivestreamID,
+			SharedBy:     msg.SenderUsername,
+			SharedAt:     sharedAt,
+		}
+		// The first link wins, reposts of the same clip are ignored
+		result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&clip)
+		if result.Error != nil {
+			log.Printf("Error saving clip %s for %s: %v", clip.ID, channel.Username, result.Error)
+			recordDBWriteError("kick_clips", result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		log.Printf("🎬 Clip %s linked in %s's chat by %s", clip.ID, channel.Username, clip.SharedBy)
+		if !FakeMode {
+			go resolveClip(clip.ID, channel.Username)
+		}
+	}
+}
+
+// resolveClip fetches the metadata of a clip from Kick. The request counts towards the channel's proxy budget.
+func resolveClip(clipID, username string) {
+	jsonString, err := fetchViaProxy(fmt.Sprintf("https://kick.com/api/v2/clips/%s", clipID), username)
+	if err != nil {
+		log.Printf("Error resolving clip %s of %s: %v", clipID, username, err)
+		return
+	}
+
+	var resp KickClipResponse
+	if err := json.Unmarshal([]byte(jsonString), &resp); err != nil {
+		log.Printf("Error unmarshalling clip %s of %s: %v", clipID, username, err)
+		return
+	}
+
+	now := receiveTime()
+	updates := map[string]any{
+		"creator_username": resp.Clip.Creator.Username,
+		"title":            resp.Clip.Title,
+		"duration_seconds": resp.Clip.Duration,
+		"thumbnail_url":    resp.Clip.ThumbnailURL,
+		"video_url":        resp.Clip.VideoURL,
+		"resolved_at":      now,
+	}
+	if clippedAt := normalizeKickTimestamp("clip.created_at", username, resp.Clip.CreatedAt, now); !clippedAt.IsZero() {
+		updates["clipped_at"] = clippedAt
+	}
+	if err := db.DB.Model(&models.KickClip{}).Where("id = ?", clipID).Updates(updates).Error; err != nil {
+		log.Printf("Error saving metadata of clip %s: %v", clipID, err)
+	}
+}
+
+// buildClipsCreated lists the clips of a livestream with the chat activity around them.
+// messages and viewerCounts must be sorted by time ascending.
+func buildClipsCreated(clips []models.KickClip, channelUsername string, messages []models.ChatMessage, viewerCounts []models.LivestreamData, start, end time.Time) []ClipCreated {
+	result := make([]ClipCreated, 0, len(clips))
+	averageRate := messagesPerMinute(messages, start, end)
+
+	for _, clip := range clips {
+		entry := ClipCreated{
+			ClipID:          clip.ID,
+			URL:             fmt.Sprintf("https://kick.com/%s/clips/%s", channelUsername, clip.ID),
+			Title:           clip.Title,
+			Creator:         clip.CreatorUsername,
+			SharedBy:        clip.SharedBy,
+			SharedAt:        clip.SharedAt,
+			ClippedAt:       clip.ClippedAt,
+			DurationSeconds: clip.DurationSeconds,
+			ThumbnailURL:    clip.ThumbnailURL,
+		}
+
+		moment := clip.SharedAt
+		if clip.ClippedAt != nil {
+			moment = *clip.ClippedAt
+		}
+		entry.ChatRate = messagesPerMinute(messages, moment.Add(-ClipChatWindow), moment)
+		if averageRate > 0 {
+			entry.ChatRateVsAvg = entry.ChatRate / averageRate
+		}
+		for _, sample := range viewerCounts {
+			if sample.CreatedAt.After(moment) {
+				break
+			}
+			entry.Viewers = sample.ViewerCount
+		}
+		result = append(result, entry)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].SharedAt.Before(result[j].SharedAt) })
+	return result
+}
+
+// clipsBetween returns the clips shared in [from, to)
+func clipsBetween(clips []models.KickClip, from, to time.Time) []models.KickClip {
+	var result []models.KickClip
+	for _, clip := range clips {
+		if !clip.SharedAt.Before(from) && clip.SharedAt.Before(to) {
+			result = append(result, clip)
+		}
+	}
+	return result
+}
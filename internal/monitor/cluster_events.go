@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+)
+
+// In a cluster chat is ingested by the instance monitoring the channel, while chatters are flagged and SSE clients
+// subscribe through any instance. Flag changes and live events are therefore sent to every instance through Postgres
+// notifications as well: each instance applies the flags and delivers the events it didn't publish itself.
+
+const (
+	flaggedChattersNotifyChannel = "kick_monitor_flagged_chatters"
+	liveEventsNotifyChannel      = "kick_monitor_live_events"
+	clusterEventsRetryDelay      = 5 * time.Second
+)
+
+// flaggedChatterChange is the notification of a chatter flagged or unflagged on a channel
+type flaggedChatterChange struct {
+	ChannelID uint   `json:"channel_id"`
+	Username  string `json:"username"`
+	Flagged   bool   `json:"flagged"`
+}
+
+// sharedLiveEvent is the notification of a live event, Origin being the instance that published it
+type sharedLiveEvent struct {
+	Origin string    `json:"origin"`
+	Event  LiveEvent `json:"event"`
+}
+
+// sharedEvents queues the live events to notify, so ingestion never waits on the database. Events are dropped
+// when it's full, like slow subscribers drop them.
+var sharedEvents = make(chan LiveEvent, 256)
+
+// shareEvent queues a live event published on this instance for the other instances of the cluster
+func shareEvent(event LiveEvent) {
+	if !Clustered {
+		return
+	}
+	select {
+	case sharedEvents <- event:
+	default:
+	}
+}
+
+// shareFlaggedChatter notifies the other instances of the cluster of a flag change made on this one
+func shareFlaggedChatter(change flaggedChatterChange) {
+	if !Clustered {
+		return
+	}
+	payload, err := json.Marshal(change)
+	if err == nil {
+		err = db.Notify(flaggedChattersNotifyChannel, string(payload))
+	}
+	if err != nil {
+		log.Printf("Error sharing flag change of chatter %s on channel %d: %v", change.Username, change.ChannelID, err)
+	}
+}
+
+// RunClusterEvents listens for the flag changes and live events of the other instances of the cluster and sends
+// this instance's until stop is closed. The flagged chatters are reloaded whenever listening starts, so changes
+// made while the connection was down aren't missed.
+func RunClusterEvents(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	go notifySharedEvents(ctx)
+
+	for {
+		err := db.Listen(ctx, []string{flaggedChattersNotifyChannel, liveEventsNotifyChannel}, func() {
+			if err := LoadFlaggedChatters(); err != nil {
+				log.Printf("Error reloading flagged chatters: %v", err)
+			}
+		}, handleClusterNotification)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Listening for cluster events failed, retrying in %s: %v", clusterEventsRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(clusterEventsRetryDelay):
+		}
+	}
+}
+
+// notifySharedEvents sends the queued live events to the other instances until ctx is done
+func notifySharedEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-sharedEvents:
+			payload, err := json.Marshal(sharedLiveEvent{Origin: JobOwner, Event: event})
+			if err == nil {
+				err = db.Notify(liveEventsNotifyChannel, string(payload))
+			}
+			if err != nil {
+				log.Printf("Error sharing %s event of channel %d: %v", event.Type, event.ChannelID, err)
+			}
+		}
+	}
+}
+
+func handleClusterNotification(channel, payload string) {
+	switch channel {
+	case flaggedChattersNotifyChannel:
+		var change flaggedChatterChange
+		if err := json.Unmarshal([]byte(payload), &change); err != nil {
+			log.Printf("Error decoding flag change notification: %v", err)
+			return
+		}
+		if change.Flagged {
+			cacheFlaggedChatter(change.ChannelID, change.Username)
+		} else {
+			uncacheFlaggedChatter(change.ChannelID, change.Username)
+		}
+	case liveEventsNotifyChannel:
+		var shared sharedLiveEvent
+		if err := json.Unmarshal([]byte(payload), &shared); err != nil {
+			log.Printf("Error decoding live event notification: %v", err)
+			return
+		}
+		if shared.Origin != JobOwner {
+			deliverEvent(shared.Event)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Trend intervals of competitor analytics
+const (
+	TrendIntervalDay  = "day"
+	TrendIntervalWeek = "week"
+)
+
+// CompetitorAnalytics compares the members of a competitor set over a period, market-share style
+type CompetitorAnalytics struct {
+	SetID             uuid.UUID               `json:"set_id"`
+	Name              string                  `json:"name"`
+	PeriodStart       time.Time               `json:"period_start"`
+	PeriodEnd         time.Time               `json:"period_end"`
+	Interval          string                  `json:"interval"`
+	TotalHoursWatched float64                 `json:"total_hours_watched"`
+	ConcurrentMinutes float64                 `json:"concurrent_minutes"` // Time at least two members were live at once
+	Members           []CompetitorMemberStats `json:"members"`            // Largest share of hours watched first
+}
+
+// CompetitorMemberStats is one channel's standing within its competitor set
+type CompetitorMemberStats struct {
+	ChannelID             uint                   `json:"channel_id"`
+	Username              string                 `json:"username"`
+	Streams               int                    `json:"streams"`
+	StreamedMinutes       int                    `json:"streamed_minutes"`
+	HoursWatched          float64                `json:"hours_watched"`
+	AverageViewers        int                    `json:"average_viewers"`
+	PeakViewers           int                    `json:"peak_viewers"`
+	HoursWatchedShare     float64                `json:"hours_watched_share"`     // Fraction of the set's hours watched
+	ConcurrentViewerShare float64                `json:"concurrent_viewer_share"` // Fraction of the set's viewers while live alongside another member
+	ConcurrentMinutes     float64                `json:"concurrent_minutes"`      // Time live alongside at least one other member
+	Trend                 []CompetitorTrendPoint `json:"trend"`
+}
+
+// CompetitorTrendPoint is a member's performance within one trend interval
+type CompetitorTrendPoint struct {
+	PeriodStart       time.Time `json:"period_start"`
+	Streams           int       `json:"streams"`
+	HoursWatched      float64   `json:"hours_watched"`
+	HoursWatchedShare float64   `json:"hours_watched_share"`
+	AverageViewers    int       `json:"average_viewers"`
+
+	streamedMinutes int
+	viewerMinutes   int
+}
+
+// truncateToInterval returns the start of the day or ISO week (Monday) t falls in, in UTC
+func truncateToInterval(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if interval != TrendIntervalWeek {
+		return day
+	}
+	offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// BuildCompetitorAnalytics computes share of hours watched, share of concurrent viewers and per-interval trends
+// for the members of a competitor set over [start, end).
+func BuildCompetitorAnalytics(set models.CompetitorSet, start, end time.Time, interval string) (CompetitorAnalytics, error) {
+	analytics := CompetitorAnalytics{
+		SetID:       set.ID,
+		Name:        set.Name,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Interval:    interval,
+		Members:     []CompetitorMemberStats{},
+	}
+	if len(set.Members) == 0 {
+		return analytics, nil
+	}
+
+	channelIDs := make([]uint, 0, len(set.Members))
+	for _, member := range set.Members {
+		channelIDs = append(channelIDs, member.ChannelID)
+	}
+
+	var channels []models.MonitoredChannel
+	if err := db.DB.Where("channel_id IN ?", channelIDs).Find(&channels).Error; err != nil {
+		return analytics, fmt.Errorf("failed to load competitor channels: %w", err)
+	}
+	usernames := make(map[uint]string, len(channels))
+	for _, channel := range channels {
+		usernames[channel.ChannelID] = channel.Username
+	}
+
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("channel_id", "report_start_time", "duration_minutes", "hours_watched", "average_viewers", "peak_viewers").
+		Where("channel_id IN ? AND parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?", channelIDs, start, end).
+		Order("report_start_time ASC").Find(&reports).Error; err != nil {
+		return analytics, fmt.Errorf("failed to load competitor reports: %w", err)
+	}
+
+	members := make(map[uint]*CompetitorMemberStats, len(channelIDs))
+	trends := make(map[uint]map[time.Time]*CompetitorTrendPoint, len(channelIDs))
+	periodHours := make(map[time.Time]float64)
+	viewerMinutes := make(map[uint]int)
+	for _, channelID := range channelIDs {
+		members[channelID] = &CompetitorMemberStats{ChannelID: channelID, Username: usernames[channelID], Trend: []CompetitorTrendPoint{}}
+		trends[channelID] = make(map[time.Time]*CompetitorTrendPoint)
+	}
+
+	for _, report := range reports {
+		member := members[report.ChannelID]
+		member.Streams++
+		member.StreamedMinutes += report.DurationMinutes
+		member.HoursWatched += report.HoursWatched
+		member.PeakViewers = max(member.PeakViewers, report.PeakViewers)
+		viewerMinutes[report.ChannelID] += report.AverageViewers * report.DurationMinutes
+		analytics.TotalHoursWatched += report.HoursWatched
+
+		period := truncateToInterval(report.ReportStartTime, interval)
+		point, ok := trends[report.ChannelID][period]
+		if !ok {
+			point = &CompetitorTrendPoint{PeriodStart: period}
+			trends[report.ChannelID][period] = point
+		}
+		point.Streams++
+		point.HoursWatched += report.HoursWatched
+		point.streamedMinutes += report.DurationMinutes
+		point.viewerMinutes += report.AverageViewers * report.DurationMinutes
+		periodHours[period] += report.HoursWatched
+	}
+
+	if err := addConcurrentViewerShares(&analytics, members, channelIDs, start, end); err != nil {
+		return analytics, err
+	}
+
+	for _, channelID := range channelIDs {
+		member := members[channelID]
+		if member.StreamedMinutes > 0 {
+			member.AverageViewers = viewerMinutes[channelID] / member.StreamedMinutes
+		}
+		if analytics.TotalHoursWatched > 0 {
+			member.HoursWatchedShare = member.HoursWatched / analytics.TotalHoursWatched
+		}
+
+		for period, point := range trends[channelID] {
+			if periodHours[period] > 0 {
+				point.HoursWatchedShare = point.HoursWatched / periodHours[period]
+			}
+			if point.streamedMinutes > 0 {
+				point.AverageViewers = point.viewerMinutes / point.streamedMinutes // Weighted by stream length
+			}
+			member.Trend = append(member.Trend, *point)
+		}
+		sort.Slice(member.Trend, func(i, j int) bool { return member.Trend[i].PeriodStart.Before(member.Trend[j].PeriodStart) })
+
+		analytics.Members = append(analytics.Members, *member)
+	}
+
+	sort.SliceStable(analytics.Members, func(i, j int) bool {
+		return analytics.Members[i].HoursWatched > analytics.Members[j].HoursWatched
+	})
+	return analytics, nil
+}
+
+// addConcurrentViewerShares buckets viewer samples by ReportTimeBlock and, for every bucket in which at least two
+// members were live, credits each member with its share of the combined viewers.
+func addConcurrentViewerShares(analytics *CompetitorAnalytics, members map[uint]*CompetitorMemberStats, channelIDs []uint, start, end time.Time) error {
+	var samples []struct {
+		ChannelID uint
+		Bucket    time.Time
+		Viewers   float64
+	}
+	blockSeconds := ReportTimeBlock.Seconds()
+	if err := db.DB.Model(&models.LivestreamData{}).
+		Select("channel_id, to_timestamp(floor(extract(epoch from created_at) / ?) * ?) AS bucket, AVG(viewer_count) AS viewers", blockSeconds, blockSeconds).
+		Where("channel_id IN ? AND is_live = ? AND created_at >= ? AND created_at < ?", channelIDs, true, start, end).
+		Group("channel_id, bucket").
+		Scan(&samples).Error; err != nil {
+		return fmt.Errorf("failed to load competitor viewer samples: %w", err)
+	}
+
+	buckets := make(map[time.Time]map[uint]float64)
+	for _, sample := range samples {
+		if buckets[sample.Bucket] == nil {
+			buckets[sample.Bucket] = make(map[uint]float64)
+		}
+		buckets[sample.Bucket][sample.ChannelID] = sample.Viewers
+	}
+
+	concurrentViewers := make(map[uint]float64)
+	totalConcurrentViewers := 0.0
+	for _, live := range buckets {
+		if len(live) < 2 {
+			continue
+		}
+		analytics.ConcurrentMinutes += ReportTimeBlock.Minutes()
+		for channelID, viewers := range live {
+			concurrentViewers[channelID] += viewers
+			totalConcurrentViewers += viewers
+			members[channelID].ConcurrentMinutes += ReportTimeBlock.Minutes()
+		}
+	}
+
+	if totalConcurrentViewers > 0 {
+		for channelID, viewers := range concurrentViewers {
+			members[channelID].ConcurrentViewerShare = viewers / totalConcurrentViewers
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"sort"
+)
+
+// ChatConcentration summarises how evenly chat activity is spread across chatters.
+type ChatConcentration struct {
+	TopOnePercentShare float64 // Share of messages sent by the top 1% of chatters (0-100)
+	TopTenPercentShare float64 // Share of messages sent by the top 10% of chatters (0-100)
+	Gini               float64 // Gini coefficient of messages per chatter (0 = equal, 1 = one chatter)
+}
+
+// calculateChatConcentration computes concentration metrics from per-chatter message counts.
+func calculateChatConcentration(messagesPerChatter map[int]int) ChatConcentration {
+	if len(messagesPerChatter) == 0 {
+		return ChatConcentration{}
+	}
+
+	counts := make([]int, 0, len(messagesPerChatter))
+	total := 0
+	for _, count := range messagesPerChatter {
+		counts = append(counts, count)
+		total += count
+	}
+	if total == 0 {
+		return ChatConcentration{}
+	}
+
+	// Sort descending so the most active chatters come first
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+	return ChatConcentration{
+		TopOnePercentShare: topShare(counts, total, 0.01),
+		TopTenPercentShare: topShare(counts, total, 0.10),
+		Gini:               giniCoefficient(counts, total),
+	}
+}
+
+// topShare returns the percentage of messages sent by the top fraction of chatters.
+// counts must be sorted in descending order. At least one chatter is always included.
+func topShare(counts []int, total int, fraction float64) float64 {
+	n := int(float64(len(counts)) * fraction)
+	if n < 1 {
+		n = 1
+	}
+
+	sum := 0
+	for _, count := range counts[:n] {
+		sum += count
+	}
+
+	return float64(sum) / float64(total) * 100.0
+}
+
+// giniCoefficient computes the Gini coefficient of the given counts.
+// counts must be sorted in descending order.
+func giniCoefficient(counts []int, total int) float64 {
+	n := len(counts)
+	if n < 2 {
+		return 0
+	}
+
+	// Standard formula on ascending values: G = (2 * sum(i * x_i)) / (n * sum(x)) - (n + 1) / n
+	var weighted float64
+	for i, count := range counts {
+		rank := n - i // ascending rank, 1-based
+		weighted += float64(rank) * float64(count)
+	}
+
+	return (2*weighted)/(float64(n)*float64(total)) - float64(n+1)/float64(n)
+}
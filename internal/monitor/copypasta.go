@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+const (
+	CrossUserCopypastaWindow   = 60 * time.Second // Time window in which the same content must be posted
+	CrossUserCopypastaMinUsers = 5                // Min distinct users posting the content in the window
+	CrossUserCopypastaMinChars = 10               // Ignore short messages ("lol", "W") that are naturally repeated
+	copypastaExampleUsernames  = 10               // Max usernames listed per copypasta
+)
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^\p{L}\p{N}\s]+`)
+
+// CrossUserCopypastaReport for spam_reports table
+type CrossUserCopypastaReport struct {
+	ContentHash     string    `json:"content_hash"`
+	Content         string    `json:"content"` // Example of the original message
+	UserCount       int       `json:"user_count"`
+	MessageCount    int       `json:"message_count"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Usernames       []string  `json:"usernames"`
+}
+
+// copypastaKey normalises a message aggressively so near-identical variants
+// (punctuation, emotes, stretched letters) hash to the same value.
+func copypastaKey(message string) (string, string) {
+	normalized := emoteRegex.ReplaceAllString(message, " ")
+	normalized = util.NormalizeChatMessage(normalized)
+	normalized = nonAlphanumericRegex.ReplaceAllString(normalized, "")
+	normalized = collapseRepeatedRunes(normalized)
+	normalized = strings.Join(strings.Fields(normalized), " ")
+
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:]), normalized
+}
+
+// collapseRepeatedRunes shortens runs of three or more identical characters to two ("loooool" -> "lool").
+// RE2 has no backreferences, so this can't be a regexp.
+func collapseRepeatedRunes(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	var prev rune
+	run := 0
+	for _, r := range s {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run <= 2 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// detectCrossUserCopypasta finds content posted by many different users within CrossUserCopypastaWindow.
+// messages must be sorted by MessageSendTime ascending.
+func detectCrossUserCopypasta(messages []models.ChatMessage) []CrossUserCopypastaReport {
+	byHash := make(map[string][]models.ChatMessage)
+	for _, msg := range messages {
+		hash, normalized := copypastaKey(msg.Message)
+		if len([]rune(normalized)) < CrossUserCopypastaMinChars {
+			continue
+		}
+		byHash[hash] = append(byHash[hash], msg)
+	}
+
+	reports := []CrossUserCopypastaReport{}
+	for hash, group := range byHash {
+		if len(group) < CrossUserCopypastaMinUsers {
+			continue
+		}
+
+		// Slide a window over the group to find the densest run of distinct users
+		bestStart, bestUsers := 0, 0
+		start := 0
+		userCounts := make(map[int]int)
+		for _, msg := range group {
+			userCounts[msg.SenderID]++
+			for msg.MessageSendTime.Sub(group[start].MessageSendTime) > CrossUserCopypastaWindow {
+				userCounts[group[start].SenderID]--
+				if userCounts[group[start].SenderID] == 0 {
+					delete(userCounts, group[start].SenderID)
+				}
+				start++
+			}
+			if len(userCounts) > bestUsers {
+				bestStart, bestUsers = start, len(userCounts)
+			}
+		}
+
+		if bestUsers < CrossUserCopypastaMinUsers {
+			continue
+		}
+
+		// Report the whole spread of the copypasta, not only the densest window
+		users := make(map[string]struct{})
+		usernames := []string{}
+		for _, msg := range group {
+			if _, seen := users[msg.SenderUsername]; !seen {
+				users[msg.SenderUsername] = struct{}{}
+				if len(usernames) < copypastaExampleUsernames {
+					usernames = append(usernames, msg.SenderUsername)
+				}
+			}
+		}
+
+		first := group[0].MessageSendTime
+		last := group[len(group)-1].MessageSendTime
+		reports = append(reports, CrossUserCopypastaReport{
+			ContentHash:     hash,
+			Content:         group[bestStart].Message,
+			UserCount:       len(users),
+			MessageCount:    len(group),
+			FirstSeen:       first,
+			LastSeen:        last,
+			DurationSeconds: last.Sub(first).Seconds(),
+			Usernames:       usernames,
+		})
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].UserCount > reports[j].UserCount
+	})
+	return reports
+}
@@ -0,0 +1,200 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Custom metric callouts: during report generation the report window's chat (up to CustomMetricsMaxMessages,
+// sampled evenly) and viewer counts are POSTed to each callout, and the JSON object it answers is stored under
+// its name in the report's CustomMetrics. A failing callout is logged and left out, it never fails the report.
+var (
+	CustomMetricsCallouts    = parseCustomMetricsCallouts(os.Getenv("CUSTOM_METRICS_CALLOUTS")) // name=url,name=url
+	CustomMetricsToken       = os.Getenv("CUSTOM_METRICS_TOKEN")                                // Sent as a bearer token when set
+	CustomMetricsTimeout     = util.GetEnvDuration("CUSTOM_METRICS_TIMEOUT", 30*time.Second)
+	CustomMetricsMaxMessages = util.GetEnvInt("CUSTOM_METRICS_MAX_MESSAGES", 20000)
+)
+
+const customMetricsMaxResponse = 1 << 20
+
+var customMetricsClient = &http.Client{}
+
+// customMetricsCallout is a named external metric computation
+type customMetricsCallout struct {
+	Name string
+	URL  string
+}
+
+// CustomMetricsRequest is the body POSTed to the callouts
+type CustomMetricsRequest struct {
+	ReportID        uuid.UUID              `json:"report_id"`
+	ChannelID       uint                   `json:"channel_id"`
+	ChannelUsername string                 `json:"channel_username"`
+	LivestreamID    uint                   `json:"livestream_id"`
+	Title           string                 `json:"title"`
+	Category        string                 `json:"category"`
+	StartTime       time.Time              `json:"start_time"`
+	EndTime         time.Time              `json:"end_time"`
+	TotalMessages   int                    `json:"total_messages"`
+	Sampled         bool                   `json:"sampled"` // Messages is a sample of the window's messages
+	Messages        []CustomMetricsMessage `json:"messages"`
+	ViewerCounts    []CustomMetricsViewers `json:"viewer_counts"`
+}
+
+// CustomMetricsMessage is a chat message sent to the callouts
+type CustomMetricsMessage struct {
+	SenderID       int             `json:"sender_id"`
+	SenderUsername string          `json:"sender_username"`
+	Message        string          `json:"message"`
+	Badges         json.RawMessage `json:"badges,omitempty"`
+	SentAt         time.Time       `json:"sent_at"`
+}
+
+// CustomMetricsViewers is a viewer count sample sent to the callouts
+type CustomMetricsViewers struct {
+	Time    time.Time `json:"time"`
+	Viewers int       `json:"viewers"`
+}
+
+func parseCustomMetricsCallouts(raw string) []customMetricsCallout {
+	var callouts []customMetricsCallout
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" || !strings.HasPrefix(url, "http") {
+			log.Printf("Ignoring invalid CUSTOM_METRICS_CALLOUTS entry '%s', expected name=url", entry)
+			continue
+		}
+		callouts = append(callouts, customMetricsCallout{Name: strings.TrimSpace(name), URL: strings.TrimSpace(url)})
+	}
+	return callouts
+}
+
+// buildCustomMetrics runs every configured callout on the report window concurrently and returns their metrics
+// keyed by callout name, nil when no callout is configured
+func buildCustomMetrics(reportID uuid.UUID, in reportInput) []byte {
+	if len(CustomMetricsCallouts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(newCustomMetricsRequest(reportID, in))
+	if err != nil {
+		log.Printf("Error marshalling custom metrics request for livestream %d: %v", in.LivestreamID, err)
+		return nil
+	}
+
+	metrics := make(map[string]json.RawMessage, len(CustomMetricsCallouts))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, callout := range CustomMetricsCallouts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := callCustomMetrics(callout, body)
+			if err != nil {
+				log.Printf("Custom metrics callout %s failed for livestream %d: %v", callout.Name, in.LivestreamID, err)
+				return
+			}
+			mu.Lock()
+			metrics[callout.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	raw, err := json.Marshal(metrics)
+	if err != nil {
+		log.Printf("Error marshalling custom metrics for livestream %d: %v", in.LivestreamID, err)
+		return nil
+	}
+	return raw
+}
+
+func newCustomMetricsRequest(reportID uuid.UUID, in reportInput) CustomMetricsRequest {
+	req := CustomMetricsRequest{
+		ReportID:        reportID,
+		ChannelID:       in.ChannelID,
+		ChannelUsername: in.ChannelUsername,
+		LivestreamID:    in.LivestreamID,
+		Title:           in.Title,
+		Category:        in.Category,
+		StartTime:       in.StartTime,
+		EndTime:         in.EndTime,
+		TotalMessages:   len(in.ChatMessages),
+		Messages:        make([]CustomMetricsMessage, 0, min(len(in.ChatMessages), CustomMetricsMaxMessages)),
+		ViewerCounts:    make([]CustomMetricsViewers, 0, len(in.ViewerCounts)),
+	}
+
+	// Take every step-th message so a sample still spans the whole window
+	step := 1.0
+	if CustomMetricsMaxMessages > 0 && len(in.ChatMessages) > CustomMetricsMaxMessages {
+		step = float64(len(in.ChatMessages)) / float64(CustomMetricsMaxMessages)
+		req.Sampled = true
+	}
+	for i := 0.0; int(i) < len(in.ChatMessages); i += step {
+		msg := in.ChatMessages[int(i)]
+		req.Messages = append(req.Messages, CustomMetricsMessage{
+			SenderID:       msg.SenderID,
+			SenderUsername: msg.SenderUsername,
+			Message:        msg.Message,
+			Badges:         msg.Badges,
+			SentAt:         msg.MessageSendTime,
+		})
+	}
+	for _, point := range in.ViewerCounts {
+		req.ViewerCounts = append(req.ViewerCounts, CustomMetricsViewers{Time: point.CreatedAt, Viewers: point.ViewerCount})
+	}
+	return req
+}
+
+// callCustomMetrics POSTs the request body to a callout and returns the JSON object it answered
+func callCustomMetrics(callout customMetricsCallout, body []byte) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CustomMetricsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callout.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if CustomMetricsToken != "" {
+		req.Header.Set("Authorization", "Bearer "+CustomMetricsToken)
+	}
+
+	resp, err := customMetricsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("callout returned non-success status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, customMetricsMaxResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(raw) > customMetricsMaxResponse {
+		return nil, fmt.Errorf("response is larger than %d bytes", customMetricsMaxResponse)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object: %w", err)
+	}
+	return raw, nil
+}
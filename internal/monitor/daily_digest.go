@@ -0,0 +1,379 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/notify"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	DailyDigestEnabled = util.GetEnvBool("DAILY_DIGEST_ENABLED", true)
+	DailyDigestHour    = util.GetEnvInt("DAILY_DIGEST_HOUR", 6) // UTC hour after which the digest of the previous day is generated
+	DailyDigestTop     = util.GetEnvInt("DAILY_DIGEST_TOP", 5)  // Gainers, losers and spam incidents listed
+)
+
+// dailyDigestDayLayout is the layout of the day a daily digest covers, its job key and API parameter
+const dailyDigestDayLayout = "2006-01-02"
+
+// FleetDigest summarizes one UTC day across every monitored channel
+type FleetDigest struct {
+	Day               string                `json:"day"` // YYYY-MM-DD, UTC
+	PeriodStart       time.Time             `json:"period_start"`
+	PeriodEnd         time.Time             `json:"period_end"`
+	MonitoredChannels int                   `json:"monitored_channels"`
+	ActiveChannels    int                   `json:"active_channels"` // Channels that streamed
+	Streams           []ReportEmail         `json:"streams"`         // Reports of the streams that started that day, longest watched first
+	HoursStreamed     float64               `json:"hours_streamed"`
+	HoursWatched      float64               `json:"hours_watched"`
+	TotalMessages     int                   `json:"total_messages"`
+	TopGainers        []FleetDigestMover    `json:"top_gainers"` // By followers gained over the day
+	TopLosers         []FleetDigestMover    `json:"top_losers"`
+	SpamIncidents     []FleetDigestIncident `json:"spam_incidents"` // The largest spam incidents of the day
+}
+
+// FleetDigestMover is the follower change of a channel over the day, from its first and last snapshot
+type FleetDigestMover struct {
+	ChannelID       uint    `json:"channel_id"`
+	Username        string  `json:"username"`
+	FollowersStart  int     `json:"followers_start"`
+	FollowersEnd    int     `json:"followers_end"`
+	FollowersChange int     `json:"followers_change"`
+	ChangePercent   float64 `json:"change_percent"`
+}
+
+type FleetDigestIncident struct {
+	ID           uuid.UUID `json:"id"`
+	ChannelID    uint      `json:"channel_id"`
+	Channel      string    `json:"channel"`
+	LivestreamID uint      `json:"livestream_id"`
+	Type         string    `json:"type"`
+	Username     string    `json:"username,omitempty"`
+	Content      string    `json:"content"`
+	MessageCount int       `json:"message_count"`
+	FirstSeen    time.Time `json:"first_seen"`
+}
+
+// followersChangeSQL reads the first and last follower count of every channel snapshotted in [start, end)
+const followersChangeSQL = `
+SELECT channel_id,
+       (ARRAY_AGG((data->>'followers_count')::bigint ORDER BY created_at ASC))[1]  AS followers_start,
+       (ARRAY_AGG((data->>'followers_count')::bigint ORDER BY created_at DESC))[1] AS followers_end
+FROM channel_data
+WHERE created_at >= ? AND created_at < ? AND jsonb_typeof(data->'followers_count') = 'number'
+GROUP BY channel_id`
+
+// BuildFleetDigest summarizes the UTC day starting at day across every monitored channel
+func BuildFleetDigest(day time.Time) (FleetDigest, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+	digest := FleetDigest{
+		Day:           start.Format(dailyDigestDayLayout),
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		Streams:       []ReportEmail{},
+		TopGainers:    []FleetDigestMover{},
+		TopLosers:     []FleetDigestMover{},
+		SpamIncidents: []FleetDigestIncident{},
+	}
+
+	var channels []models.MonitoredChannel
+	if err := db.DB.Find(&channels).Error; err != nil {
+		return digest, fmt.Errorf("failed to load channels: %w", err)
+	}
+	usernames := make(map[uint]string, len(channels))
+	for _, channel := range channels {
+		usernames[channel.ChannelID] = channel.Username
+		if channel.IsActive {
+			digest.MonitoredChannels++
+		}
+	}
+
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("id", "channel_id", "username", "title", "report_start_time", "duration_minutes", "average_viewers",
+		"peak_viewers", "hours_watched", "total_messages", "unique_chatters", "engagement").
+		Where("parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?", start, end).
+		Order("hours_watched DESC").Find(&reports).Error; err != nil {
+		return digest, fmt.Errorf("failed to load reports: %w", err)
+	}
+	active := make(map[uint]struct{})
+	for _, report := range reports {
+		active[report.ChannelID] = struct{}{}
+		digest.Streams = append(digest.Streams, newReportEmail(report))
+		digest.HoursStreamed += float64(report.DurationMinutes) / 60
+		digest.HoursWatched += report.HoursWatched
+		digest.TotalMessages += report.TotalMessages
+	}
+	digest.ActiveChannels = len(active)
+	digest.HoursStreamed = roundTo(digest.HoursStreamed, 2)
+	digest.HoursWatched = roundTo(digest.HoursWatched, 2)
+
+	var changes []struct {
+		ChannelID      uint
+		FollowersStart int
+		FollowersEnd   int
+	}
+	if err := db.DB.Raw(followersChangeSQL, start, end).Scan(&changes).Error; err != nil {
+		return digest, fmt.Errorf("failed to load follower changes: %w", err)
+	}
+	movers := make([]FleetDigestMover, 0, len(changes))
+	for _, change := range changes {
+		mover := FleetDigestMover{
+			ChannelID:       change.ChannelID,
+			Username:        usernames[change.ChannelID],
+			FollowersStart:  change.FollowersStart,
+			FollowersEnd:    change.FollowersEnd,
+			FollowersChange: change.FollowersEnd - change.FollowersStart,
+		}
+		if change.FollowersStart > 0 {
+			mover.ChangePercent = roundTo(100*float64(mover.FollowersChange)/float64(change.FollowersStart), 2)
+		}
+		movers = append(movers, mover)
+	}
+	sort.Slice(movers, func(i, j int) bool { return movers[i].FollowersChange > movers[j].FollowersChange })
+	for _, mover := range movers {
+		if len(digest.TopGainers) == DailyDigestTop || mover.FollowersChange <= 0 {
+			break
+		}
+		digest.TopGainers = append(digest.TopGainers, mover)
+	}
+	for i := len(movers) - 1; i >= 0; i-- {
+		if len(digest.TopLosers) == DailyDigestTop || movers[i].FollowersChange >= 0 {
+			break
+		}
+		digest.TopLosers = append(digest.TopLosers, movers[i])
+	}
+
+	var incidents []models.SpamIncident
+	if err := db.DB.Where("first_seen >= ? AND first_seen < ?", start, end).
+		Order("message_count DESC, first_seen ASC").Limit(max(0, DailyDigestTop)).Find(&incidents).Error; err != nil {
+		return digest, fmt.Errorf("failed to load spam incidents: %w", err)
+	}
+	for _, incident := range incidents {
+		digest.SpamIncidents = append(digest.SpamIncidents, FleetDigestIncident{
+			ID:           incident.ID,
+			ChannelID:    incident.ChannelID,
+			Channel:      usernames[incident.ChannelID],
+			LivestreamID: incident.LivestreamID,
+			Type:         incident.Type,
+			Username:     incident.Username,
+			Content:      incident.Content,
+			MessageCount: incident.MessageCount,
+			FirstSeen:    incident.FirstSeen,
+		})
+	}
+	return digest, nil
+}
+
+// Summary is the one paragraph version of the digest sent as the notification message
+func (d FleetDigest) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d streams on %d of %d channels, %.1fh streamed, %.0fh watched, %d chat messages.",
+		d.Day, len(d.Streams), d.ActiveChannels, d.MonitoredChannels, d.HoursStreamed, d.HoursWatched, d.TotalMessages)
+	if len(d.TopGainers) > 0 {
+		fmt.Fprintf(&b, " Top gainer: %s (%+d followers).", d.TopGainers[0].Username, d.TopGainers[0].FollowersChange)
+	}
+	if len(d.TopLosers) > 0 {
+		fmt.Fprintf(&b, " Top loser: %s (%+d followers).", d.TopLosers[0].Username, d.TopLosers[0].FollowersChange)
+	}
+	if len(d.SpamIncidents) > 0 {
+		fmt.Fprintf(&b, " Largest spam incident: %s in %s (%d messages).", d.SpamIncidents[0].Type, d.SpamIncidents[0].Channel,
+			d.SpamIncidents[0].MessageCount)
+	}
+	return b.String()
+}
+
+// GenerateDailyDigest builds and stores the fleet digest of a UTC day, replacing an earlier one of the same day
+func GenerateDailyDigest(day time.Time) (*models.DailyDigest, error) {
+	digest, err := BuildFleetDigest(day)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := json.Marshal(digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal daily digest: %w", err)
+	}
+	row := models.DailyDigest{
+		Day:               digest.PeriodStart,
+		Streams:           len(digest.Streams),
+		ActiveChannels:    digest.ActiveChannels,
+		MonitoredChannels: digest.MonitoredChannels,
+		HoursStreamed:     digest.HoursStreamed,
+		HoursWatched:      digest.HoursWatched,
+		Summary:           summary,
+		CreatedAt:         time.Now().UTC(),
+	}
+	if err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"streams", "active_channels", "monitored_channels", "hours_streamed",
+			"hours_watched", "summary", "created_at"}),
+	}).Create(&row).Error; err != nil {
+		return nil, fmt.Errorf("failed to save daily digest of %s: %w", digest.Day, err)
+	}
+	return &row, nil
+}
+
+// deliverDailyDigest sends a stored digest through the notification webhook and records the delivery
+func deliverDailyDigest(row *models.DailyDigest) error {
+	var digest FleetDigest
+	if err := json.Unmarshal(row.Summary, &digest); err != nil {
+		return fmt.Errorf("failed to decode daily digest: %w", err)
+	}
+	if err := notify.Send(notify.Alert{
+		ID:       "daily_digest:" + digest.Day, // Redeliveries of the day's digest dedupe
+		Kind:     "daily_digest",
+		Severity: notify.SeverityInfo,
+		Subject:  "fleet",
+		Message:  digest.Summary(),
+		Data:     digest,
+	}); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	row.DeliveredAt = &now
+	return db.DB.Model(&models.DailyDigest{}).Where("day = ?", row.Day).Update("delivered_at", now).Error
+}
+
+// RunDailyDigests generates and delivers the fleet digest of the previous UTC day once DailyDigestHour has passed.
+// The digest is a leased job keyed by its day, so one instance of a cluster sends it.
+func RunDailyDigests(stop <-chan struct{}) {
+	if !DailyDigestEnabled {
+		return
+	}
+
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		runDueDailyDigest(time.Now().UTC())
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runDueDailyDigest(now time.Time) {
+	if now.Hour() < DailyDigestHour {
+		return
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+
+	var existing models.DailyDigest
+	err := db.DB.Where("day = ?", day).Take(&existing).Error
+	if err == nil && existing.DeliveredAt != nil {
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error checking the daily digest of %s: %v", day.Format(dailyDigestDayLayout), err)
+		return
+	}
+
+	lease, err := acquireJobLease(JobKindDailyDigest, day.Format(dailyDigestDayLayout), nil)
+	if err != nil {
+		if !errors.Is(err, ErrJobLeased) && !errors.Is(err, ErrDraining) {
+			log.Printf("Error leasing the daily digest of %s: %v", day.Format(dailyDigestDayLayout), err)
+		}
+		return
+	}
+	if err := runLeasedJob(lease, func() error { return runDailyDigest(day) }); err != nil {
+		log.Printf("Error sending the daily digest of %s: %v", day.Format(dailyDigestDayLayout), err)
+	}
+}
+
+func runDailyDigestJob(lease *models.JobLease) error {
+	day, err := time.Parse(dailyDigestDayLayout, lease.JobKey)
+	if err != nil {
+		return fmt.Errorf("invalid digest day %q: %w", lease.JobKey, err)
+	}
+	return runDailyDigest(day)
+}
+
+// runDailyDigest generates the digest of a day unless it is stored already, and delivers it unless it was
+func runDailyDigest(day time.Time) error {
+	var row models.DailyDigest
+	err := db.DB.Where("day = ?", day).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		generated, err := GenerateDailyDigest(day)
+		if err != nil {
+			return err
+		}
+		row = *generated
+	} else if err != nil {
+		return fmt.Errorf("failed to load daily digest: %w", err)
+	}
+	if row.DeliveredAt != nil {
+		return nil
+	}
+	if err := deliverDailyDigest(&row); err != nil {
+		return fmt.Errorf("failed to deliver daily digest: %w", err)
+	}
+	log.Printf("Sent the daily digest of %s", day.Format(dailyDigestDayLayout))
+	return nil
+}
+
+// DailyDigestEntry lists a stored daily digest
+type DailyDigestEntry struct {
+	Day               string     `json:"day"`
+	Streams           int        `json:"streams"`
+	ActiveChannels    int        `json:"active_channels"`
+	MonitoredChannels int        `json:"monitored_channels"`
+	HoursStreamed     float64    `json:"hours_streamed"`
+	HoursWatched      float64    `json:"hours_watched"`
+	DeliveredAt       *time.Time `json:"delivered_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// GetDailyDigests lists the stored daily digests, newest first
+func GetDailyDigests(limit int) ([]DailyDigestEntry, error) {
+	var rows []models.DailyDigest
+	if err := db.DB.Omit("summary").Order("day DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list daily digests: %w", err)
+	}
+	entries := make([]DailyDigestEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, DailyDigestEntry{
+			Day:               row.Day.Format(dailyDigestDayLayout),
+			Streams:           row.Streams,
+			ActiveChannels:    row.ActiveChannels,
+			MonitoredChannels: row.MonitoredChannels,
+			HoursStreamed:     row.HoursStreamed,
+			HoursWatched:      row.HoursWatched,
+			DeliveredAt:       row.DeliveredAt,
+			CreatedAt:         row.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+// GetDailyDigest returns the stored digest of a day; gorm.ErrRecordNotFound when none was generated
+func GetDailyDigest(day time.Time) (*FleetDigest, error) {
+	var row models.DailyDigest
+	if err := db.DB.Where("day = ?", day).Take(&row).Error; err != nil {
+		return nil, err
+	}
+	var digest FleetDigest
+	if err := json.Unmarshal(row.Summary, &digest); err != nil {
+		return nil, fmt.Errorf("failed to decode daily digest of %s: %w", day.Format(dailyDigestDayLayout), err)
+	}
+	return &digest, nil
+}
+
+// ParseDigestDay parses the YYYY-MM-DD day of a daily digest
+func ParseDigestDay(raw string) (time.Time, error) {
+	return time.Parse(dailyDigestDayLayout, raw)
+}
@@ -0,0 +1,131 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDraining is returned when new work is refused because the instance is draining
+var ErrDraining = errors.New("instance is draining")
+
+// drainPollInterval is how often a drain checks whether the in-flight work finished
+const drainPollInterval = time.Second
+
+var drainState struct {
+	sync.Mutex
+	draining  atomic.Bool
+	startedAt time.Time
+}
+
+var (
+	inFlightIngestion atomic.Int64 // Channel fetches and chat messages being processed
+	runningJobs       atomic.Int64 // Leased jobs running on this instance
+)
+
+// DrainStatus tells whether a draining instance finished its work and can be stopped without losing data
+type DrainStatus struct {
+	Draining          bool       `json:"draining"`
+	SafeToStop        bool       `json:"safe_to_stop"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	ActiveMonitors    int        `json:"active_monitors"`
+	InFlightIngestion int64      `json:"in_flight_ingestion"` // Fetches and chat messages being processed
+	RunningJobs       int64      `json:"running_jobs"`
+	PendingWrites     int64      `json:"pending_writes"`        // Rows queued or being inserted by the write pipelines
+	PendingCounters   int        `json:"pending_chat_counters"` // Chat message counts not flushed yet
+	Waiting           []string   `json:"waiting_for,omitempty"` // What still keeps the instance from being safe to stop
+}
+
+// IsDraining reports whether Drain was called; a draining instance starts no monitors and no jobs
+func IsDraining() bool {
+	return drainState.draining.Load()
+}
+
+// Drain prepares the instance to be stopped: it stops every monitor, refuses new fetches and report jobs, and
+// keeps flushing the chat counters in the background until the running jobs and queued writes are done. Poll
+// GetDrainStatus until SafeToStop. Draining can't be undone, the instance is meant to be restarted.
+func Drain() DrainStatus {
+	drainState.Lock()
+	if drainState.draining.Load() {
+		drainState.Unlock()
+		return GetDrainStatus()
+	}
+	drainState.startedAt = time.Now().UTC()
+	drainState.draining.Store(true)
+	drainState.Unlock()
+
+	ids := MonitoredChannelIDs()
+	for _, id := range ids {
+		StopMonitoringChannel(id)
+	}
+	log.Printf("Draining: stopped %d monitor(s), waiting for in-flight work", len(ids))
+
+	go finishDrain()
+	return GetDrainStatus()
+}
+
+// finishDrain flushes the chat counters until nothing is in flight anymore, as the last fetches and messages may
+// still count chat messages after the monitors were stopped
+func finishDrain() {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		flushChatCounters()
+		if status := GetDrainStatus(); status.SafeToStop {
+			log.Printf("Drained: safe to stop after %s", time.Since(*status.StartedAt).Round(time.Second))
+			return
+		}
+	}
+}
+
+// GetDrainStatus returns the work still outstanding on this instance
+func GetDrainStatus() DrainStatus {
+	status := DrainStatus{
+		Draining:          IsDraining(),
+		ActiveMonitors:    len(MonitoredChannelIDs()),
+		InFlightIngestion: inFlightIngestion.Load(),
+		RunningJobs:       runningJobs.Load(),
+	}
+	for _, pipeline := range GetWritePipelineStats() {
+		status.PendingWrites += pipeline.Pending()
+	}
+	chatCounters.Lock()
+	status.PendingCounters = len(chatCounters.counts)
+	chatCounters.Unlock()
+
+	if !status.Draining {
+		return status
+	}
+	drainState.Lock()
+	startedAt := drainState.startedAt
+	drainState.Unlock()
+	status.StartedAt = &startedAt
+
+	for _, outstanding := range []struct {
+		count int64
+		what  string
+	}{
+		{int64(status.ActiveMonitors), "monitors"},
+		{status.InFlightIngestion, "in-flight fetches and chat messages"},
+		{status.RunningJobs, "running jobs"},
+		{status.PendingWrites, "queued writes"},
+		{int64(status.PendingCounters), "unflushed chat counters"},
+	} {
+		if outstanding.count > 0 {
+			status.Waiting = append(status.Waiting, fmt.Sprintf("%d %s", outstanding.count, outstanding.what))
+		}
+	}
+	status.SafeToStop = len(status.Waiting) == 0
+	return status
+}
+
+// trackIngestion counts fn as in-flight work for the drain status
+func trackIngestion(fn func()) {
+	inFlightIngestion.Add(1)
+	defer inFlightIngestion.Add(-1)
+	fn()
+}
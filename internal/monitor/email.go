@@ -0,0 +1,229 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/mailer"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+var (
+	DigestWeekday = digestWeekdayFromEnv()
+	DigestHour    = util.GetEnvInt("DIGEST_HOUR", 9) // UTC hour after which the weekly digest goes out
+)
+
+const (
+	digestCheckInterval = 15 * time.Minute
+	digestPeriod        = 7 * 24 * time.Hour
+)
+
+func digestWeekdayFromEnv() time.Weekday {
+	day := strings.ToLower(os.Getenv("DIGEST_WEEKDAY"))
+	if day == "" {
+		return time.Monday
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.ToLower(d.String()) == day {
+			return d
+		}
+	}
+	util.InvalidSetting("unknown DIGEST_WEEKDAY %q, falling back to %s", day, time.Monday)
+	return time.Monday
+}
+
+// ReportEmail is the data rendered into the report summary email.
+type ReportEmail struct {
+	ReportID        uuid.UUID `json:"report_id"`
+	ChannelUsername string    `json:"channel_username"`
+	Title           string    `json:"title"`
+	StartTime       time.Time `json:"start_time"`
+	DurationMinutes int       `json:"duration_minutes"`
+	AverageViewers  int       `json:"average_viewers"`
+	PeakViewers     int       `json:"peak_viewers"`
+	HoursWatched    float64   `json:"hours_watched"`
+	TotalMessages   int       `json:"total_messages"`
+	UniqueChatters  int       `json:"unique_chatters"`
+	Engagement      float64   `json:"engagement"`
+}
+
+// DigestEmail is the data rendered into the weekly digest email.
+type DigestEmail struct {
+	ChannelUsername string           `json:"channel_username"`
+	PeriodStart     time.Time        `json:"period_start"`
+	PeriodEnd       time.Time        `json:"period_end"`
+	Streams         []ReportEmail    `json:"streams"`
+	TotalMinutes    int              `json:"total_minutes"`
+	AverageViewers  int              `json:"average_viewers"`
+	PeakViewers     int              `json:"peak_viewers"`
+	HoursWatched    float64          `json:"hours_watched"`
+	TotalMessages   int              `json:"total_messages"`
+	Forecast        *ChannelForecast `json:"forecast,omitempty"` // Nil when it couldn't be built
+}
+
+func newReportEmail(report models.LivestreamReport) ReportEmail {
+	return ReportEmail{
+		ReportID:        report.ID,
+		ChannelUsername: report.Username,
+		Title:           report.Title,
+		StartTime:       report.ReportStartTime,
+		DurationMinutes: report.DurationMinutes,
+		AverageViewers:  report.AverageViewers,
+		PeakViewers:     report.PeakViewers,
+		HoursWatched:    report.HoursWatched,
+		TotalMessages:   report.TotalMessages,
+		UniqueChatters:  report.UniqueChatters,
+		Engagement:      report.Engagement,
+	}
+}
+
+// recipientEmails returns the addresses subscribed to a channel, filtered by the given boolean column.
+func recipientEmails(channelID uint, column string) ([]string, error) {
+	var emails []string
+	err := db.DB.Model(&models.ReportRecipient{}).
+		Where("channel_id = ? AND "+column+" = ?", channelID, true).
+		Pluck("email", &emails).Error
+	return emails, err
+}
+
+// sendReportEmails emails a freshly generated report to the channel's report recipients.
+func sendReportEmails(report models.LivestreamReport) {
+	if !mailer.Enabled() {
+		return
+	}
+
+	to, err := recipientEmails(report.ChannelID, "reports")
+	if err != nil {
+		log.Printf("Error loading report recipients for channel %d: %v", report.ChannelID, err)
+		return
+	}
+	if len(to) == 0 {
+		return
+	}
+
+	subject := fmt.Sprintf("%s stream report: %s", report.Username, report.Title)
+	if err := mailer.Send(to, subject, mailer.TemplateReport, newReportEmail(report)); err != nil {
+		log.Printf("Error emailing report %s: %v", report.ID.String(), err)
+		return
+	}
+	log.Printf("Emailed report %s to %d recipient(s)", report.ID.String(), len(to))
+}
+
+// BuildDigest summarizes the reports of a channel generated in [start, end).
+func BuildDigest(channel models.MonitoredChannel, start, end time.Time) (DigestEmail, error) {
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("id", "username", "title", "report_start_time", "duration_minutes", "average_viewers",
+		"peak_viewers", "hours_watched", "total_messages", "unique_chatters", "engagement").
+		Where("channel_id = ? AND parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?", channel.ChannelID, start, end).
+		Order("report_start_time ASC").Find(&reports).Error; err != nil {
+		return DigestEmail{}, err
+	}
+
+	digest := DigestEmail{
+		ChannelUsername: channel.Username,
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		Streams:         make([]ReportEmail, 0, len(reports)),
+	}
+
+	weightedViewers := 0
+	for _, report := range reports {
+		digest.Streams = append(digest.Streams, newReportEmail(report))
+		digest.TotalMinutes += report.DurationMinutes
+		digest.HoursWatched += report.HoursWatched
+		digest.TotalMessages += report.TotalMessages
+		digest.PeakViewers = max(digest.PeakViewers, report.PeakViewers)
+		weightedViewers += report.AverageViewers * report.DurationMinutes
+	}
+	if digest.TotalMinutes > 0 {
+		digest.AverageViewers = weightedViewers / digest.TotalMinutes
+	}
+
+	if forecast, err := BuildForecast(channel, end); err != nil {
+		log.Printf("Error building forecast for the digest of %s: %v", channel.Username, err)
+	} else {
+		digest.Forecast = &forecast
+	}
+	return digest, nil
+}
+
+// RunWeeklyDigests sends the weekly digest to subscribed recipients on DigestWeekday after DigestHour (UTC).
+func RunWeeklyDigests(stop <-chan struct{}) {
+	if !mailer.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		sendDueDigests(time.Now().UTC())
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sendDueDigests(now time.Time) {
+	if now.Weekday() != DigestWeekday || now.Hour() < DigestHour {
+		return
+	}
+
+	// Anything sent within the last few days belongs to this week's run
+	var due []models.ReportRecipient
+	if err := db.DB.Where("weekly_digest = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", true, now.Add(-digestPeriod/2)).
+		Find(&due).Error; err != nil {
+		log.Printf("Error loading digest recipients: %v", err)
+		return
+	}
+
+	byChannel := make(map[uint][]models.ReportRecipient)
+	for _, recipient := range due {
+		byChannel[recipient.ChannelID] = append(byChannel[recipient.ChannelID], recipient)
+	}
+
+	for channelID, recipients := range byChannel {
+		// In cluster mode only the instance owning the channel sends its digest
+		if OwnershipFilter != nil && !OwnershipFilter(channelID) {
+			continue
+		}
+
+		var channel models.MonitoredChannel
+		if err := db.DB.First(&channel, channelID).Error; err != nil {
+			log.Printf("Error loading channel %d for weekly digest: %v", channelID, err)
+			continue
+		}
+
+		digest, err := BuildDigest(channel, now.Add(-digestPeriod), now)
+		if err != nil {
+			log.Printf("Error building weekly digest for %s: %v", channel.Username, err)
+			continue
+		}
+
+		to := make([]string, 0, len(recipients))
+		ids := make([]uint, 0, len(recipients))
+		for _, recipient := range recipients {
+			to = append(to, recipient.Email)
+			ids = append(ids, recipient.ID)
+		}
+
+		subject := fmt.Sprintf("%s weekly digest", channel.Username)
+		if err := mailer.Send(to, subject, mailer.TemplateDigest, digest); err != nil {
+			log.Printf("Error emailing weekly digest for %s: %v", channel.Username, err)
+			continue
+		}
+		if err := db.DB.Model(&models.ReportRecipient{}).Where("id IN ?", ids).Update("last_digest_at", now).Error; err != nil {
+			log.Printf("Error recording weekly digest delivery for %s: %v", channel.Username, err)
+		}
+		log.Printf("Emailed weekly digest for %s to %d recipient(s)", channel.Username, len(to))
+	}
+}
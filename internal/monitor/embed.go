@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Spam badges of the embed widget, from the spam score of the last stream
+const (
+	SpamBadgeLow      = "low"
+	SpamBadgeModerate = "moderate"
+	SpamBadgeHigh     = "high"
+	SpamBadgeUnknown  = "unknown"
+)
+
+// EmbedSummary is the compact, public view of a channel shown by third-party embed widgets
+type EmbedSummary struct {
+	Username    string           `json:"username"`
+	ChannelURL  string           `json:"channel_url"`
+	ProfilePic  string           `json:"profile_pic,omitempty"`
+	Verified    bool             `json:"verified"`
+	IsLive      bool             `json:"is_live"`
+	Live        *EmbedLiveStatus `json:"live,omitempty"`
+	Followers   int              `json:"followers"`
+	LastStream  *EmbedLastStream `json:"last_stream,omitempty"`
+	SpamScore   *int             `json:"spam_score,omitempty"` // 0-100, nil until the channel has a report
+	SpamBadge   string           `json:"spam_badge"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
+
+type EmbedLiveStatus struct {
+	Title     string    `json:"title"`
+	Viewers   int       `json:"viewers"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type EmbedLastStream struct {
+	Title           string    `json:"title"`
+	Category        string    `json:"category,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	AverageViewers  int       `json:"average_viewers"`
+	PeakViewers     int       `json:"peak_viewers"`
+	HoursWatched    float64   `json:"hours_watched"`
+	TotalMessages   int       `json:"total_messages"`
+	UniqueChatters  int       `json:"unique_chatters"`
+}
+
+// BuildEmbedSummary returns the embed summary of a monitored channel; gorm.ErrRecordNotFound when it isn't monitored
+func BuildEmbedSummary(username string) (EmbedSummary, error) {
+	var channel models.MonitoredChannel
+	if err := db.DB.Where("username = ?", ResolveUsername(username)).First(&channel).Error; err != nil {
+		return EmbedSummary{}, err
+	}
+
+	summary := EmbedSummary{
+		Username:    channel.Username,
+		ChannelURL:  "https://kick.com/" + channel.Username,
+		SpamBadge:   SpamBadgeUnknown,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	var profile models.StreamerProfile
+	err := db.DB.Select("verified", "profile_pic", "followers_count").Where("channel_id = ?", channel.ChannelID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return summary, fmt.Errorf("failed to load streamer profile of %s: %w", username, err)
+	}
+	summary.Verified = profile.Verified
+	summary.ProfilePic = profile.ProfilePic
+	var followers []models.FollowersCountPoint
+	if len(profile.FollowersCount) > 0 && json.Unmarshal(profile.FollowersCount, &followers) == nil && len(followers) > 0 {
+		summary.Followers = followers[len(followers)-1].Count
+	}
+
+	// Live when the latest sample is live and recent enough that the stream can't have ended unnoticed
+	var latest models.LivestreamData
+	err = db.DB.Where("channel_id = ?", channel.ChannelID).Order("created_at DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return summary, fmt.Errorf("failed to load livestream status of %s: %w", username, err)
+	}
+	if err == nil && latest.IsLive && time.Since(latest.CreatedAt) <= FetchInterval+LivestreamFreshnessLeeway {
+		summary.IsLive = true
+		summary.Live = &EmbedLiveStatus{Title: latest.SessionTitle, Viewers: latest.ViewerCount, StartedAt: latest.StartTime}
+	}
+
+	var report models.LivestreamReport
+	err = db.DB.Where("channel_id = ? AND parent_report_id IS NULL", channel.ChannelID).Order("report_start_time DESC").First(&report).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return summary, nil
+	}
+	if err != nil {
+		return summary, fmt.Errorf("failed to load the last report of %s: %w", username, err)
+	}
+	summary.LastStream = &EmbedLastStream{
+		Title:           report.Title,
+		Category:        report.Category,
+		StartedAt:       report.ReportStartTime,
+		DurationMinutes: report.DurationMinutes,
+		AverageViewers:  report.AverageViewers,
+		PeakViewers:     report.PeakViewers,
+		HoursWatched:    report.HoursWatched,
+		TotalMessages:   report.TotalMessages,
+		UniqueChatters:  report.UniqueChatters,
+	}
+
+	if report.SpamReportID != nil {
+		var spamReport models.SpamReport
+		if err := db.DB.Where("id = ?", *report.SpamReportID).First(&spamReport).Error; err == nil {
+			score := spamScore(report, spamReport)
+			summary.SpamScore = &score
+			summary.SpamBadge = spamBadge(score)
+		}
+	}
+	return summary, nil
+}
+
+// spamScore rates the chat of a stream from 0 (clean) to 100, averaging the share of duplicate messages and the
+// share of chatters flagged as suspicious
+func spamScore(report models.LivestreamReport, spamReport models.SpamReport) int {
+	duplicateShare, suspiciousShare := 0.0, 0.0
+	if report.PersistedMessages > 0 {
+		duplicateShare = float64(spamReport.DuplicateMessagesCount) / float64(report.PersistedMessages)
+	} else if report.TotalMessages > 0 {
+		duplicateShare = float64(spamReport.DuplicateMessagesCount) / float64(report.TotalMessages)
+	}
+
+	var suspicious []json.RawMessage
+	if report.UniqueChatters > 0 && json.Unmarshal(spamReport.SuspiciousChatters, &suspicious) == nil {
+		suspiciousShare = float64(len(suspicious)) / float64(report.UniqueChatters)
+	}
+
+	score := 100 * (min(duplicateShare, 1) + min(suspiciousShare, 1)) / 2
+	return int(math.Round(score))
+}
+
+func spamBadge(score int) string {
+	switch {
+	case score < 10:
+		return SpamBadgeLow
+	case score < 30:
+		return SpamBadgeModerate
+	default:
+		return SpamBadgeHigh
+	}
+}
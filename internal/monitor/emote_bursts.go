@@ -0,0 +1,196 @@
+package monitor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+const (
+	EmoteBurstGap           = 15 * time.Second // Max gap between emote-only messages of the same burst
+	EmoteBurstMinMessages   = 10               // Emote-only messages a burst needs
+	EmoteBurstContextWindow = 2 * time.Minute  // How long before and after a burst an event or viewer spike explains it
+	EmoteBurstViewerRise    = 0.1              // Viewer rise over the minutes before a burst that counts as a spike
+	EmoteSpamMaxChatters    = 3                // A burst from this few chatters can be a flood
+	EmoteSpamTopShare       = 0.5              // Or one where a single chatter sent at least this share
+	emoteBurstMaxListed     = 100              // Bursts listed in the report, the largest kept
+)
+
+// Emote burst classifications
+const (
+	EmoteBurstHype = "hype" // Chat reacting to something: subs, gifts, a host, a clip, a viewer spike or many chatters at once
+	EmoteBurstSpam = "spam" // A flood from a few chatters with nothing happening around it
+)
+
+// Reasons an emote burst was classified the way it was
+const (
+	EmoteReasonReaction     = "reaction"      // Subs, gifted subs, hosts, rewards or kicks around the burst
+	EmoteReasonClip         = "clip"          // A clip of the moment was shared
+	EmoteReasonViewerSpike  = "viewer_spike"  // Viewers rose by EmoteBurstViewerRise around the burst
+	EmoteReasonManyChatters = "many_chatters" // More than EmoteSpamMaxChatters chatters and none dominating
+	EmoteReasonFewChatters  = "few_chatters"
+	EmoteReasonOneDominates = "one_chatter_dominates"
+)
+
+var emoteNameRegex = regexp.MustCompile(`\[emote:\d+:(\w+)\]`)
+
+// EmoteBurstReport splits the emote-only messages of a report window into hype and spam. Messages outside any
+// burst are counted apart, they are neither.
+type EmoteBurstReport struct {
+	EmoteOnlyMessages int          `json:"emote_only_messages"`
+	HypeMessages      int          `json:"hype_messages"`
+	SpamMessages      int          `json:"spam_messages"`
+	ScatteredMessages int          `json:"scattered_messages"` // Emote-only messages outside any burst
+	HypeBursts        int          `json:"hype_bursts"`
+	SpamBursts        int          `json:"spam_bursts"`
+	Bursts            []EmoteBurst `json:"bursts"` // In time order
+}
+
+// EmoteBurst is a run of emote-only messages with no gap over EmoteBurstGap
+type EmoteBurst struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Messages       int       `json:"messages"`
+	Chatters       int       `json:"chatters"`
+	TopChatter     string    `json:"top_chatter"`
+	TopChatterMsgs int       `json:"top_chatter_messages"`
+	TopEmote       string    `json:"top_emote,omitempty"`
+	Classification string    `json:"classification"`
+	Reasons        []string  `json:"reasons"`
+}
+
+// classifyEmoteBursts finds the bursts of emote-only messages and tells hype from spam by what surrounds them.
+// Messages must be sorted by send time; app accounts are left out, their emote walls are neither.
+func classifyEmoteBursts(messages []models.ChatMessage, viewerCounts []models.LivestreamData, reactions []models.ReactionEvent, clips []models.KickClip) EmoteBurstReport {
+	report := EmoteBurstReport{Bursts: []EmoteBurst{}}
+
+	var run []models.ChatMessage
+	flush := func() {
+		if len(run) >= EmoteBurstMinMessages {
+			burst := newEmoteBurst(run, viewerCounts, reactions, clips)
+			if burst.Classification == EmoteBurstHype {
+				report.HypeMessages += burst.Messages
+				report.HypeBursts++
+			} else {
+				report.SpamMessages += burst.Messages
+				report.SpamBursts++
+			}
+			report.Bursts = append(report.Bursts, burst)
+		} else {
+			report.ScatteredMessages += len(run)
+		}
+		run = nil
+	}
+	for _, msg := range messages {
+		if _, isApp := AppSenders[msg.SenderUsername]; isApp {
+			continue
+		}
+		if !onlyEmotesRegex.MatchString(strings.TrimSpace(msg.Message)) {
+			continue
+		}
+		report.EmoteOnlyMessages++
+		if len(run) > 0 && msg.MessageSendTime.Sub(run[len(run)-1].MessageSendTime) > EmoteBurstGap {
+			flush()
+		}
+		run = append(run, msg)
+	}
+	flush()
+
+	if len(report.Bursts) > emoteBurstMaxListed {
+		sort.SliceStable(report.Bursts, func(i, j int) bool { return report.Bursts[i].Messages > report.Bursts[j].Messages })
+		report.Bursts = report.Bursts[:emoteBurstMaxListed]
+		sort.Slice(report.Bursts, func(i, j int) bool { return report.Bursts[i].Start.Before(report.Bursts[j].Start) })
+	}
+	return report
+}
+
+func newEmoteBurst(run []models.ChatMessage, viewerCounts []models.LivestreamData, reactions []models.ReactionEvent, clips []models.KickClip) EmoteBurst {
+	burst := EmoteBurst{Start: run[0].MessageSendTime, End: run[len(run)-1].MessageSendTime, Messages: len(run), Reasons: []string{}}
+
+	perChatter := make(map[string]int)
+	perEmote := make(map[string]int)
+	for _, msg := range run {
+		perChatter[msg.SenderUsername]++
+		for _, match := range emoteNameRegex.FindAllStringSubmatch(msg.Message, -1) {
+			perEmote[match[1]]++
+		}
+	}
+	burst.Chatters = len(perChatter)
+	burst.TopChatter, burst.TopChatterMsgs = topCount(perChatter)
+	burst.TopEmote, _ = topCount(perEmote)
+
+	from, to := burst.Start.Add(-EmoteBurstContextWindow), burst.End.Add(EmoteBurstContextWindow)
+	for _, reaction := range reactions {
+		if !reaction.CreatedAt.Before(from) && !reaction.CreatedAt.After(to) {
+			burst.Reasons = append(burst.Reasons, EmoteReasonReaction)
+			break
+		}
+	}
+	for _, clip := range clips {
+		moment := clip.SharedAt
+		if clip.ClippedAt != nil {
+			moment = *clip.ClippedAt
+		}
+		if !moment.Before(from) && !moment.After(to) {
+			burst.Reasons = append(burst.Reasons, EmoteReasonClip)
+			break
+		}
+	}
+	if viewerSpikeAround(viewerCounts, from, to) {
+		burst.Reasons = append(burst.Reasons, EmoteReasonViewerSpike)
+	}
+	explained := len(burst.Reasons) > 0
+
+	fewChatters := burst.Chatters <= EmoteSpamMaxChatters
+	dominated := float64(burst.TopChatterMsgs) >= EmoteSpamTopShare*float64(burst.Messages)
+	switch {
+	case fewChatters:
+		burst.Reasons = append(burst.Reasons, EmoteReasonFewChatters)
+	case dominated:
+		burst.Reasons = append(burst.Reasons, EmoteReasonOneDominates)
+	default:
+		burst.Reasons = append(burst.Reasons, EmoteReasonManyChatters)
+	}
+
+	// A crowd chanting is hype on its own, a few chatters flooding only when something explains it
+	burst.Classification = EmoteBurstHype
+	if (fewChatters || dominated) && !explained {
+		burst.Classification = EmoteBurstSpam
+	}
+	return burst
+}
+
+// viewerSpikeAround reports whether the peak viewers within [from, to] exceed those of the minutes before from by
+// EmoteBurstViewerRise
+func viewerSpikeAround(samples []models.LivestreamData, from, to time.Time) bool {
+	baseline, baselineSamples, peak := 0.0, 0, 0
+	for _, sample := range samples {
+		switch {
+		case sample.CreatedAt.Before(from.Add(-ReactionCompareWindow)):
+		case sample.CreatedAt.Before(from):
+			baseline += float64(sample.ViewerCount)
+			baselineSamples++
+		case !sample.CreatedAt.After(to):
+			peak = max(peak, sample.ViewerCount)
+		}
+	}
+	if baselineSamples == 0 || baseline == 0 {
+		return false
+	}
+	baseline /= float64(baselineSamples)
+	return float64(peak) >= baseline*(1+EmoteBurstViewerRise)
+}
+
+// topCount returns the key with the highest count, the alphabetically first on ties
+func topCount(counts map[string]int) (string, int) {
+	top, best := "", 0
+	for key, count := range counts {
+		if count > best || (count == best && key < top) {
+			top, best = key, count
+		}
+	}
+	return top, best
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"os"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Engagement formulas selectable through ENGAGEMENT_FORMULA
+const (
+	EngagementChattersPerAverage  = "chatters_per_average_viewers" // unique chatters / average viewers * 100 (original definition)
+	EngagementMessagesPerViewerHr = "messages_per_viewer_hour"     // total messages / hours watched
+	EngagementChattersPerPeak     = "chatters_per_peak_viewers"    // unique chatters / peak viewers * 100
+	EngagementQualityWeighted     = "quality_weighted"             // chatters weighted by message quality / average viewers * 100
+	EngagementBotAdjusted         = "bot_adjusted"                 // chatters weighted by their chance of being human / average viewers * 100
+)
+
+// EngagementFormula is the definition used for the headline Engagement value of new reports
+var EngagementFormula = engagementFormulaFromEnv()
+
+func engagementFormulaFromEnv() string {
+	formula := strings.ToLower(os.Getenv("ENGAGEMENT_FORMULA"))
+	switch formula {
+	case "":
+		return EngagementChattersPerAverage
+	case EngagementChattersPerAverage, EngagementMessagesPerViewerHr, EngagementChattersPerPeak, EngagementQualityWeighted, EngagementBotAdjusted:
+		return formula
+	default:
+		util.InvalidSetting("unknown ENGAGEMENT_FORMULA %q, falling back to %s", formula, EngagementChattersPerAverage)
+		return EngagementChattersPerAverage
+	}
+}
+
+// EngagementScores holds every engagement definition so historical reports stay comparable
+type EngagementScores struct {
+	ChattersPerAverage  float64
+	MessagesPerViewerHr float64
+	ChattersPerPeak     float64
+	QualityWeighted     float64
+	BotAdjusted         float64
+}
+
+// Selected returns the score for the configured EngagementFormula
+func (s EngagementScores) Selected() float64 {
+	switch EngagementFormula {
+	case EngagementMessagesPerViewerHr:
+		return s.MessagesPerViewerHr
+	case EngagementChattersPerPeak:
+		return s.ChattersPerPeak
+	case EngagementQualityWeighted:
+		return s.QualityWeighted
+	case EngagementBotAdjusted:
+		return s.BotAdjusted
+	default:
+		return s.ChattersPerAverage
+	}
+}
+
+// calculateEngagement computes all engagement definitions for a livestream, the per average viewer ones
+// against the average concurrent viewers
+func calculateEngagement(messages []models.ChatMessage, uniqueChatters int, acv float64, peakViewers int, hoursWatched float64) EngagementScores {
+	var scores EngagementScores
+
+	if acv > 0 {
+		scores.ChattersPerAverage = float64(uniqueChatters) / acv * 100.0
+		scores.QualityWeighted = qualityWeightedChatters(messages) / acv * 100.0
+	}
+	if peakViewers > 0 {
+		scores.ChattersPerPeak = float64(uniqueChatters) / float64(peakViewers) * 100.0
+	}
+	if hoursWatched > 0 {
+		scores.MessagesPerViewerHr = float64(len(messages)) / hoursWatched
+	}
+
+	return scores
+}
+
+// messageQuality scores a single message between 0 (noise) and 1 (regular conversation)
+func messageQuality(msg models.ChatMessage, seenBySender map[string]struct{}) float64 {
+	if _, isApp := AppSenders[msg.SenderUsername]; isApp {
+		return 0
+	}
+
+	normalized := util.NormalizeChatMessage(msg.Message)
+	key := msg.SenderUsername + "\x00" + normalized
+	if _, repeated := seenBySender[key]; repeated {
+		return 0.25
+	}
+	seenBySender[key] = struct{}{}
+
+	if onlyEmotesRegex.MatchString(strings.TrimSpace(msg.Message)) {
+		return 0.5
+	}
+	return 1
+}
+
+// qualityWeightedChatters sums, per chatter, the average quality of their messages
+func qualityWeightedChatters(messages []models.ChatMessage) float64 {
+	type tally struct {
+		quality float64
+		count   int
+	}
+	perChatter := make(map[string]*tally)
+	seenBySender := make(map[string]struct{})
+
+	for _, msg := range messages {
+		t, ok := perChatter[msg.SenderUsername]
+		if !ok {
+			t = &tally{}
+			perChatter[msg.SenderUsername] = t
+		}
+		t.quality += messageQuality(msg, seenBySender)
+		t.count++
+	}
+
+	var weighted float64
+	for _, t := range perChatter {
+		weighted += t.quality / float64(t.count)
+	}
+	return weighted
+}
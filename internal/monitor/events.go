@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// Live event types published to subscribers (e.g. SSE clients)
+const (
+	EventFlaggedMessage = "flagged_message"
+)
+
+// LiveEvent is a real-time event about a monitored channel
+type LiveEvent struct {
+	Type      string    `json:"type"`
+	ChannelID uint      `json:"channel_id"`
+	Data      any       `json:"data"`
+	Time      time.Time `json:"time"`
+}
+
+// eventBroker fans out live events to per-channel subscribers
+var eventBroker = struct {
+	sync.RWMutex
+	subscribers map[uint]map[chan LiveEvent]struct{}
+}{subscribers: make(map[uint]map[chan LiveEvent]struct{})}
+
+// SubscribeEvents registers a subscriber for a channel's live events.
+// The returned function must be called to unsubscribe.
+func SubscribeEvents(channelID uint) (<-chan LiveEvent, func()) {
+	ch := make(chan LiveEvent, 64)
+
+	eventBroker.Lock()
+	if eventBroker.subscribers[channelID] == nil {
+		eventBroker.subscribers[channelID] = make(map[chan LiveEvent]struct{})
+	}
+	eventBroker.subscribers[channelID][ch] = struct{}{}
+	eventBroker.Unlock()
+
+	unsubscribe := func() {
+		eventBroker.Lock()
+		delete(eventBroker.subscribers[channelID], ch)
+		if len(eventBroker.subscribers[channelID]) == 0 {
+			delete(eventBroker.subscribers, channelID)
+		}
+		eventBroker.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+// publishEvent delivers an event to all subscribers of its channel, on every instance of a cluster. Slow subscribers
+// drop events rather than blocking ingestion.
+func publishEvent(event LiveEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	deliverEvent(event)
+	shareEvent(event)
+}
+
+// deliverEvent delivers an event to the subscribers of its channel connected to this instance
+func deliverEvent(event LiveEvent) {
+	eventBroker.RLock()
+	defer eventBroker.RUnlock()
+
+	for ch := range eventBroker.subscribers[event.ChannelID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package monitor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExportSettleDelay holds back the rows inserted in the last moments: queued writes and other instances may still
+// insert rows stamped slightly earlier, which a cursor already past them would skip.
+var ExportSettleDelay = util.GetEnvDuration("EXPORT_SETTLE_DELAY", 30*time.Second)
+
+const exportBatchSize = 500
+
+// Export event kinds, in the order events inserted at the same instant are exported
+const (
+	ExportChannelSnapshot = "channel_snapshot" // A periodic fetch of the channel, including its live state
+	ExportChat            = "chat"
+	ExportReaction        = "reaction" // Subs, gifts, hosts and other Pusher events
+	ExportReport          = "report"   // A livestream report was generated
+	ExportViewerSample    = "viewer_sample"
+)
+
+// ExportCursor is the position of an event in the export: its insertion time, kind and key. The zero cursor is
+// before every event.
+type ExportCursor struct {
+	Time time.Time
+	Kind string
+	Key  string
+}
+
+// String encodes the cursor for the since parameter
+func (c ExportCursor) String() string {
+	raw := fmt.Sprintf("%d|%s|%s", c.Time.UnixNano(), c.Kind, c.Key)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseExportCursor decodes a cursor returned by the export, or starts at an RFC 3339 time
+func ParseExportCursor(raw string) (ExportCursor, error) {
+	if raw == "" {
+		return ExportCursor{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return ExportCursor{Time: t}, nil // No kind sorts before every kind, so events at t itself are included
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return ExportCursor{}, errors.New("invalid cursor")
+	}
+	parts := strings.SplitN(string(decoded), "|", 3)
+	if len(parts) != 3 {
+		return ExportCursor{}, errors.New("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ExportCursor{}, errors.New("invalid cursor")
+	}
+	cursor := ExportCursor{Time: time.Unix(0, nanos).UTC(), Kind: parts[1], Key: parts[2]}
+	if cursor.Kind == "" && cursor.Key == "" {
+		return cursor, nil // A start time nothing was exported after yet
+	}
+	source, ok := exportSourceByKind(cursor.Kind)
+	if !ok {
+		return ExportCursor{}, errors.New("invalid cursor")
+	}
+	if _, err := source.parseKey(cursor.Key); err != nil {
+		return ExportCursor{}, errors.New("invalid cursor")
+	}
+	return cursor, nil
+}
+
+// ExportEvent is a line of the NDJSON export
+type ExportEvent struct {
+	Kind   string    `json:"kind"`
+	Cursor string    `json:"cursor"` // Pass as since to resume after this event
+	Time   time.Time `json:"time"`   // Insertion time
+	Data   any       `json:"data"`
+
+	position ExportCursor
+}
+
+// exportSource loads the events of one table after a cursor, in export order
+type exportSource struct {
+	kind      string
+	keyColumn string
+	parseKey  func(key string) (any, error)
+	load      func(query *gorm.DB) ([]ExportEvent, error)
+}
+
+func parseUUIDKey(key string) (any, error) { return uuid.Parse(key) }
+
+// viewer samples are keyed by channel ID, zero-padded so keys sort like the numbers
+func parseChannelKey(key string) (any, error) { return strconv.ParseUint(key, 10, 64) }
+
+func channelKey(channelID uint) string { return fmt.Sprintf("%020d", channelID) }
+
+var exportSources = []exportSource{
+	{ExportChannelSnapshot, "id", parseUUIDKey, loadExportChannelSnapshots},
+	{ExportChat, "id", parseUUIDKey, loadExportChat},
+	{ExportReaction, "id", parseUUIDKey, loadExportReactions},
+	{ExportReport, "id", parseUUIDKey, loadExportReports},
+	{ExportViewerSample, "channel_id", parseChannelKey, loadExportViewerSamples},
+}
+
+func exportSourceByKind(kind string) (exportSource, bool) {
+	for _, source := range exportSources {
+		if source.kind == kind {
+			return source, true
+		}
+	}
+	return exportSource{}, false
+}
+
+// after restricts a query to the source's rows that come after the cursor and were inserted before until
+func (s exportSource) after(query *gorm.DB, cursor ExportCursor, until time.Time) *gorm.DB {
+	query = query.Where("created_at < ?", until)
+	switch {
+	case cursor.Time.IsZero():
+		return query
+	case s.kind > cursor.Kind:
+		return query.Where("created_at >= ?", cursor.Time)
+	case s.kind < cursor.Kind:
+		return query.Where("created_at > ?", cursor.Time)
+	}
+	key, _ := s.parseKey(cursor.Key) // Validated by ParseExportCursor
+	return query.Where("(created_at > ? OR (created_at = ? AND "+s.keyColumn+" > ?))", cursor.Time, cursor.Time, key)
+}
+
+// StreamExportEvents passes up to limit events after the cursor to emit, in insertion order, and returns the
+// cursor of the last one. more is false once the export caught up with the settled rows.
+func StreamExportEvents(ctx context.Context, cursor ExportCursor, limit int, emit func(ExportEvent) error) (last ExportCursor, more bool, err error) {
+	until := time.Now().Add(-ExportSettleDelay)
+	last = cursor
+	for remaining := limit; remaining > 0; {
+		if err := ctx.Err(); err != nil {
+			return last, true, err
+		}
+
+		batchSize := min(exportBatchSize, remaining)
+		var batch []ExportEvent
+		for _, source := range exportSources {
+			query := source.after(db.DB.WithContext(ctx), last, until).Order("created_at, " + source.keyColumn).Limit(batchSize)
+			events, err := source.load(query)
+			if err != nil {
+				return last, true, fmt.Errorf("failed to export %s events: %w", source.kind, err)
+			}
+			batch = append(batch, events...)
+		}
+		if len(batch) == 0 {
+			return last, false, nil
+		}
+
+		// Every source returned its first batchSize events, so the first batchSize of the union come next
+		sort.Slice(batch, func(i, j int) bool { return batch[i].position.before(batch[j].position) })
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		for _, event := range batch {
+			event.Cursor = event.position.String()
+			if err := emit(event); err != nil {
+				return last, true, err
+			}
+			last = event.position
+		}
+		remaining -= len(batch)
+	}
+	return last, true, nil
+}
+
+func (c ExportCursor) before(other ExportCursor) bool {
+	if !c.Time.Equal(other.Time) {
+		return c.Time.Before(other.Time)
+	}
+	if c.Kind != other.Kind {
+		return c.Kind < other.Kind
+	}
+	return c.Key < other.Key
+}
+
+func exportEvent(kind string, createdAt time.Time, key string, data any) ExportEvent {
+	createdAt = createdAt.UTC()
+	return ExportEvent{Kind: kind, Time: createdAt, Data: data, position: ExportCursor{Time: createdAt, Kind: kind, Key: key}}
+}
+
+func loadExportChannelSnapshots(query *gorm.DB) ([]ExportEvent, error) {
+	var rows []models.ChannelData
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]ExportEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, exportEvent(ExportChannelSnapshot, row.CreatedAt, row.ID.String(), map[string]any{
+			"id":         row.ID,
+			"channel_id": row.ChannelID,
+			"data":       json.RawMessage(row.Data),
+		}))
+	}
+	return events, nil
+}
+
+func loadExportChat(query *gorm.DB) ([]ExportEvent, error) {
+	var rows []models.ChatMessage
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]ExportEvent, 0, len(rows))
+	for _, row := range rows {
+		var badges json.RawMessage
+		if len(row.Badges) > 0 {
+			badges = row.Badges
+		}
+		events = append(events, exportEvent(ExportChat, row.CreatedAt, row.ID.String(), map[string]any{
+			"id":                row.ID,
+			"chatroom_id":       row.ChatroomID,
+			"livestream_id":     row.LivestreamID,
+			"sender_id":         row.SenderID,
+			"sender_username":   row.SenderUsername,
+			"message":           row.Message,
+			"badges":            badges,
+			"flagged":           row.Flagged,
+			"message_send_time": row.MessageSendTime.UTC(),
+		}))
+	}
+	return events, nil
+}
+
+func loadExportReactions(query *gorm.DB) ([]ExportEvent, error) {
+	var rows []models.ReactionEvent
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]ExportEvent, 0, len(rows))
+	for _, row := range rows {
+		var data json.RawMessage
+		if len(row.Data) > 0 {
+			data = row.Data
+		}
+		events = append(events, exportEvent(ExportReaction, row.CreatedAt, row.ID.String(), map[string]any{
+			"id":            row.ID,
+			"channel_id":    row.ChannelID,
+			"livestream_id": row.LivestreamID,
+			"event":         row.Event,
+			"kind":          row.Kind,
+			"username":      row.Username,
+			"amount":        row.Amount,
+			"data":          data,
+		}))
+	}
+	return events, nil
+}
+
+func loadExportReports(query *gorm.DB) ([]ExportEvent, error) {
+	var rows []models.LivestreamReport
+	if err := query.Select("id", "channel_id", "livestream_id", "parent_report_id", "report_start_time", "report_end_time", "created_at").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]ExportEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, exportEvent(ExportReport, row.CreatedAt, row.ID.String(), map[string]any{
+			"id":                row.ID,
+			"channel_id":        row.ChannelID,
+			"livestream_id":     row.LivestreamID,
+			"parent_report_id":  row.ParentReportID,
+			"report_start_time": row.ReportStartTime.UTC(),
+			"report_end_time":   row.ReportEndTime.UTC(),
+		}))
+	}
+	return events, nil
+}
+
+func loadExportViewerSamples(query *gorm.DB) ([]ExportEvent, error) {
+	var rows []models.LivestreamData
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]ExportEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, exportEvent(ExportViewerSample, row.CreatedAt, channelKey(row.ChannelID), map[string]any{
+			"channel_id":    row.ChannelID,
+			"livestream_id": row.LivestreamID,
+			"viewer_count":  row.ViewerCount,
+			"is_live":       row.IsLive,
+			"session_title": row.SessionTitle,
+			"lang_iso":      row.LangISO,
+			"start_time":    row.StartTime.UTC(),
+			"source":        row.Source,
+		}))
+	}
+	return events, nil
+}
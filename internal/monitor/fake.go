@@ -0,0 +1,346 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+	"gorm.io/gorm"
+)
+
+// FakeMode replaces Kick (proxy fetches, Pusher chat and VOD lookups) with an internal synthetic data generator,
+// so ingestion, report generation and the API can be exercised without a proxy or live streams.
+var (
+	FakeMode            = util.GetEnvBool("FAKE_MODE", false)
+	FakeChannels        = util.GetEnvInt("FAKE_CHANNELS", 3)                           // Synthetic channels seeded on startup
+	FakeStreamDuration  = util.GetEnvDuration("FAKE_STREAM_DURATION", 1*time.Hour)     // Length of each synthetic livestream
+	FakeOfflineDuration = util.GetEnvDuration("FAKE_OFFLINE_DURATION", 15*time.Minute) // Gap between synthetic livestreams
+)
+
+const (
+	fakeIDBase             = 900_000_000 // Synthetic channel and chatroom IDs live above this to avoid clashing with real ones
+	fakeChatTick           = 1 * time.Second
+	fakeViewerUpdateTicks  = 30  // Emit a Pusher-style viewer update every N chat ticks
+	fakeChatterPool        = 400 // Distinct synthetic chatters per channel
+	fakeViewerSpikeChance  = 0.03
+	fakeCopypastaChance    = 0.01
+	fakeRapidSpammerChance = 0.005
+)
+
+var fakePhrases = []string{
+	"hello chat",
+	"lets gooo",
+	"W stream",
+	"what game is this",
+	"gg",
+	"LUL that was close",
+	"first time here, love the vibes",
+	"can you play the next map",
+	"[emote:37226:KEKW]",
+	"[emote:37230:POLICE] [emote:37230:POLICE]",
+	"that's crazy [emote:37226:KEKW]",
+	"who else is watching from work",
+	"nice play",
+	"L take",
+	"how long have you been streaming today",
+}
+
+var fakeCopypastas = []string{
+	"THIS IS THE BEST STREAM ON KICK NO CAP",
+	"🔥🔥🔥 HYPE HYPE HYPE 🔥🔥🔥",
+	"Subscribe to the channel for more content like this!!!",
+}
+
+// fakeHash derives a stable seed from a string so every instance generates the same synthetic world.
+func fakeHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// fakeChannelID maps a username to a stable synthetic channel ID.
+func fakeChannelID(username string) uint {
+	return fakeIDBase + uint(fakeHash(username)%1_000_000)
+}
+
+// fakeStreamState describes where a synthetic channel is in its live/offline cycle at a point in time.
+type fakeStreamState struct {
+	IsLive       bool
+	LivestreamID uint
+	StartTime    time.Time
+	Progress     float64 // 0..1 through the current livestream
+}
+
+func fakeStreamAt(username string, now time.Time) fakeStreamState {
+	period := FakeStreamDuration + FakeOfflineDuration
+	if period <= 0 {
+		return fakeStreamState{}
+	}
+
+	// Offset each channel so they don't all go live at once
+	offset := time.Duration(fakeHash(username)) % period
+	shifted := now.Add(offset)
+	cycle := shifted.UnixNano() / int64(period)
+	position := time.Duration(shifted.UnixNano() % int64(period))
+
+	if position >= FakeStreamDuration {
+		return fakeStreamState{}
+	}
+	return fakeStreamState{
+		IsLive:       true,
+		LivestreamID: fakeIDBase + uint(fakeHash(fmt.Sprintf("%s:%d", username, cycle))%100_000_000),
+		StartTime:    now.Add(-position).Truncate(time.Second),
+		Progress:     float64(position) / float64(FakeStreamDuration),
+	}
+}
+
+// fakeViewerCount follows a ramp-up / plateau / tail-off curve with noise and occasional spikes,
+// so the outlier rejection has something to reject.
+func fakeViewerCount(username string, state fakeStreamState) int {
+	if !state.IsLive {
+		return 0
+	}
+	peak := 150 + float64(fakeHash(username)%5000)
+
+	curve := math.Min(1, state.Progress*4)
+	if state.Progress > 0.8 {
+		curve *= 1 - 0.4*(state.Progress-0.8)/0.2
+	}
+	viewers := peak * curve * (0.95 + rand.Float64()*0.1)
+	if rand.Float64() < fakeViewerSpikeChance {
+		viewers *= 3 + rand.Float64()*3
+	}
+	return max(1, int(viewers))
+}
+
+// fakeChannelJSON renders a synthetic /api/v2/channels/{slug} response.
+func fakeChannelJSON(username string) (string, error) {
+	channelID := fakeChannelID(username)
+	state := fakeStreamAt(username, time.Now())
+
+	kickData := KickChannelResponse{
+		ID:             int(channelID),
+		UserID:         int(channelID),
+		Slug:           username,
+		VodEnabled:     true,
+		FollowersCount: 1000 + int(fakeHash(username)%100_000),
+		Verified:       fakeHash(username)%4 == 0,
+		User: &User{
+			ID:       int(channelID),
+			Username: username,
+			Bio:      "Synthetic channel generated in FAKE_MODE",
+		},
+		Chatroom: &KickChatroom{
+			ID:        int(channelID),
+			ChannelID: int(channelID),
+			ChatMode:  "public",
+		},
+	}
+
+	if state.IsLive {
+		kickData.Livestream = &KickLivestream{
+			ID:           int(state.LivestreamID),
+			Slug:         fmt.Sprintf("%s-stream-%d", username, state.LivestreamID),
+			ChannelID:    int(channelID),
+			CreatedAt:    state.StartTime.UTC().Format("2006-01-02 15:04:05"),
+			SessionTitle: fmt.Sprintf("%s synthetic stream #%d", username, state.LivestreamID%1000),
+			IsLive:       true,
+			StartTime:    state.StartTime.UTC().Format("2006-01-02 15:04:05"),
+			Language:     "English",
+			LangISO:      "en",
+			ViewerCount:  fakeViewerCount(username, state),
+			Tags:         json.RawMessage(`["synthetic","fake_mode"]`),
+		}
+	}
+
+	payload, err := json.Marshal(kickData)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling synthetic channel data for %s: %w", username, err)
+	}
+	return string(payload), nil
+}
+
+// fakeChannelVideos lists VODs for the synthetic livestreams that ended recently.
+func fakeChannelVideos(username string) []KickVideo {
+	period := FakeStreamDuration + FakeOfflineDuration
+	now := time.Now()
+
+	var videos []KickVideo
+	for i := 1; i <= 5; i++ {
+		// Step back whole cycles; when currently offline, also step back over the offline gap
+		state := fakeStreamAt(username, now.Add(-time.Duration(i)*period))
+		if !state.IsLive {
+			state = fakeStreamAt(username, now.Add(-time.Duration(i)*period-FakeOfflineDuration))
+		}
+		if !state.IsLive {
+			continue
+		}
+
+		video := KickVideo{
+			ID:           int(state.LivestreamID),
+			Slug:         fmt.Sprintf("%s-stream-%d", username, state.LivestreamID),
+			SessionTitle: fmt.Sprintf("%s synthetic stream #%d", username, state.LivestreamID%1000),
+			StartTime:    state.StartTime.UTC().Format("2006-01-02 15:04:05"),
+			Source:       fmt.Sprintf("https://fake.invalid/%s/%d/master.m3u8", username, state.LivestreamID),
+		}
+		video.Video.ID = int(state.LivestreamID)
+		video.Video.UUID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(video.Source)).String()
+		videos = append(videos, video)
+	}
+	return videos
+}
+
+// SeedFakeChannels makes sure the configured number of synthetic channels exist and are active.
+func SeedFakeChannels() error {
+	for i := 1; i <= FakeChannels; i++ {
+		username := fmt.Sprintf("fake_streamer_%d", i)
+		channelID := fakeChannelID(username)
+
+		var existing models.MonitoredChannel
+		err := db.DB.Where("username = ?", username).First(&existing).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error checking synthetic channel %s: %w", username, err)
+		}
+
+		channel := models.MonitoredChannel{
+			ChannelID:  channelID,
+			ChatroomID: channelID,
+			Username:   username,
+			IsActive:   true,
+		}
+		if err := db.DB.Create(&channel).Error; err != nil {
+			return fmt.Errorf("error seeding synthetic channel %s: %w", username, err)
+		}
+		log.Printf("Seeded synthetic channel %s (ID: %d)", username, channelID)
+	}
+	return nil
+}
+
+// fakeChatter is a synthetic sender; a few are chat apps, moderators, subscribers or have suspicious names.
+type fakeChatter struct {
+	ID     int
+	Slug   string
+	Badges []KickBadge
+}
+
+func fakeChatters(channel *models.MonitoredChannel) []fakeChatter {
+	chatters := make([]fakeChatter, 0, fakeChatterPool)
+	for i := 0; i < fakeChatterPool; i++ {
+		slug := fmt.Sprintf("viewer_%d_%d", channel.ChannelID%1000, i)
+		switch {
+		case i == 0:
+			slug = "botrix"
+		case i%50 == 0:
+			slug = fmt.Sprintf("user%08d", int(fakeHash(slug))%100_000_000)
+		}
+		badges := []KickBadge{}
+		switch {
+		case i > 0 && i%40 == 1:
+			badges = append(badges, KickBadge{Type: BadgeModerator, Text: "Moderator"})
+		case i%4 == 1:
+			badges = append(badges, KickBadge{Type: BadgeSubscriber, Text: "Subscriber", Count: 1 + i%24})
+		}
+		chatters = append(chatters, fakeChatter{ID: int(fakeIDBase) + int(channel.ChannelID%1000)*10_000 + i, Slug: slug, Badges: badges})
+	}
+	return chatters
+}
+
+// runFakeChat stands in for the Pusher connection: it feeds synthetic chat and viewer events
+// through handleWebSocketMessage so they take the same ingestion path as real ones.
+func runFakeChat(channel *models.MonitoredChannel, stop <-chan struct{}) {
+	log.Printf("FAKE_MODE: generating synthetic chat for channel %s (ID: %d)", channel.Username, channel.ChannelID)
+
+	chatters := fakeChatters(channel)
+	ticker := time.NewTicker(fakeChatTick)
+	defer ticker.Stop()
+
+	ticks := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		ticks++
+
+		state := fakeStreamAt(channel.Username, time.Now())
+		if !state.IsLive {
+			continue
+		}
+		viewers := fakeViewerCount(channel.Username, state)
+
+		if ticks%fakeViewerUpdateTicks == 0 {
+			emitFakeEvent(channel, "App\\Events\\ViewerCountUpdated", fmt.Sprintf("channel.%d", channel.ChannelID),
+				map[string]any{"id": state.LivestreamID, "viewers": viewers})
+		}
+
+		// Roughly one message per 100 viewers per second
+		for n := rand.IntN(max(1, viewers/50) + 1); n > 0; n-- {
+			chatter := chatters[rand.IntN(len(chatters))]
+			emitFakeChatMessage(channel, chatter, fakePhrases[rand.IntN(len(fakePhrases))])
+		}
+
+		if rand.Float64() < fakeCopypastaChance {
+			// Several users pasting the same text with small variations
+			text := fakeCopypastas[rand.IntN(len(fakeCopypastas))]
+			for i := 0; i < 4+rand.IntN(6); i++ {
+				variant := text
+				if i%2 == 1 {
+					variant = strings.ToLower(text) + "!!"
+				}
+				emitFakeChatMessage(channel, chatters[rand.IntN(len(chatters))], variant)
+			}
+		}
+
+		if rand.Float64() < fakeRapidSpammerChance {
+			spammer := chatters[rand.IntN(len(chatters))]
+			for i := 0; i < RapidMessageBurstMinCount+2; i++ {
+				emitFakeChatMessage(channel, spammer, "FREE GIFT CARDS check my bio")
+			}
+		}
+	}
+}
+
+func emitFakeChatMessage(channel *models.MonitoredChannel, chatter fakeChatter, content string) {
+	var data ChatMessageEventData
+	data.ID = uuid.New().String()
+	data.ChatroomID = int(channel.ChatroomID)
+	data.Content = content
+	data.Type = "message"
+	data.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	data.Sender.ID = chatter.ID
+	data.Sender.Username = chatter.Slug
+	data.Sender.Slug = chatter.Slug
+	data.Sender.Identity.Color = "#75FD46"
+	data.Sender.Identity.Badges = chatter.Badges
+	data.Metadata = json.RawMessage(`{}`)
+
+	emitFakeEvent(channel, "App\\Events\\ChatMessageEvent", fmt.Sprintf("chatrooms.%d.v2", channel.ChatroomID), data)
+}
+
+func emitFakeEvent(channel *models.MonitoredChannel, event, pusherChannel string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error marshalling synthetic %s for %s: %v", event, channel.Username, err)
+		return
+	}
+	raw, err := json.Marshal(IncomingMessage{Event: event, Channel: pusherChannel, Data: string(payload)})
+	if err != nil {
+		log.Printf("Error marshalling synthetic %s for %s: %v", event, channel.Username, err)
+		return
+	}
+	handleWebSocketMessage(channel, raw)
+}
@@ -0,0 +1,262 @@
+package monitor
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Proxy requests queue for one of the Capacity.FetchConcurrency slots. A freed slot goes to a prioritized channel
+// first, then to the oldest request of a channel under its soft share, so one channel's VOD and profile fetches
+// can't starve the others; when every waiting channel is over its share the oldest request still runs.
+var (
+	FetchChannelConcurrency = util.GetEnvInt("FETCH_CHANNEL_CONCURRENCY", 2)                 // Soft share of the slots per channel, 0 disables it
+	FetchPriorityDuration   = util.GetEnvDuration("FETCH_PRIORITY_DURATION", 10*time.Minute) // How long a prioritized channel jumps the queue
+)
+
+// ErrChannelNotFetched is returned when prioritizing a channel this instance doesn't fetch
+var ErrChannelNotFetched = errors.New("channel is not fetched by this instance")
+
+// fetchRequest is a proxy request waiting for or holding a slot
+type fetchRequest struct {
+	seq       uint64
+	key       string // Proxy budget key, the channel's username for channel fetches
+	url       string
+	queuedAt  time.Time
+	startedAt time.Time
+	ready     chan struct{}
+}
+
+// fetchSchedule is when a channel's fetch loop last ran and next runs
+type fetchSchedule struct {
+	channelID uint
+	username  string
+	lastFetch time.Time
+	nextFetch time.Time
+	wake      chan struct{}
+}
+
+var fetchQueue = struct {
+	sync.Mutex
+	seq         uint64
+	inFlight    map[uint64]*fetchRequest
+	waiting     []*fetchRequest
+	schedules   map[uint]*fetchSchedule
+	prioritized map[string]time.Time // Budget key -> until
+}{
+	inFlight:    make(map[uint64]*fetchRequest),
+	schedules:   make(map[uint]*fetchSchedule),
+	prioritized: make(map[string]time.Time),
+}
+
+// acquireFetchSlot blocks until a proxy slot is free for the request and returns the function releasing it
+func acquireFetchSlot(key, url string) func() {
+	fetchQueue.Lock()
+	fetchQueue.seq++
+	req := &fetchRequest{seq: fetchQueue.seq, key: key, url: url, queuedAt: time.Now(), ready: make(chan struct{})}
+	if len(fetchQueue.inFlight) < Capacity.FetchConcurrency && len(fetchQueue.waiting) == 0 {
+		startFetchLocked(req)
+	} else {
+		fetchQueue.waiting = append(fetchQueue.waiting, req)
+	}
+	fetchQueue.Unlock()
+
+	<-req.ready
+	return func() {
+		fetchQueue.Lock()
+		defer fetchQueue.Unlock()
+		delete(fetchQueue.inFlight, req.seq)
+		if next := nextFetch(fetchQueue.waiting, inFlightPerKeyLocked(), time.Now()); next >= 0 {
+			waiter := fetchQueue.waiting[next]
+			fetchQueue.waiting = append(fetchQueue.waiting[:next], fetchQueue.waiting[next+1:]...)
+			startFetchLocked(waiter)
+		}
+	}
+}
+
+func startFetchLocked(req *fetchRequest) {
+	req.startedAt = time.Now()
+	fetchQueue.inFlight[req.seq] = req
+	close(req.ready)
+}
+
+// inFlightPerKeyLocked counts the requests in flight of each budget key
+func inFlightPerKeyLocked() map[string]int {
+	perKey := make(map[string]int)
+	for _, req := range fetchQueue.inFlight {
+		perKey[req.key]++
+	}
+	return perKey
+}
+
+// nextFetch returns the index of the waiting request the next free slot goes to, -1 when none waits. Called with
+// fetchQueue locked.
+func nextFetch(waiting []*fetchRequest, inFlight map[string]int, now time.Time) int {
+	best := -1
+	bestRank := [2]bool{}
+	for i, req := range waiting {
+		rank := [2]bool{
+			fetchQueue.prioritized[req.key].After(now),
+			FetchChannelConcurrency <= 0 || inFlight[req.key] < FetchChannelConcurrency,
+		}
+		// Waiting is in arrival order, so the first of the best rank is the oldest
+		if best < 0 || (rank[0] && !bestRank[0]) || (rank[0] == bestRank[0] && rank[1] && !bestRank[1]) {
+			best, bestRank = i, rank
+		}
+	}
+	return best
+}
+
+// scheduleFetches registers the fetch loop of a channel and returns the channel that wakes it for an immediate
+// fetch, and the function unregistering it
+func scheduleFetches(channelID uint, username string) (<-chan struct{}, func()) {
+	schedule := &fetchSchedule{channelID: channelID, username: username, wake: make(chan struct{}, 1)}
+	fetchQueue.Lock()
+	fetchQueue.schedules[channelID] = schedule
+	fetchQueue.Unlock()
+	return schedule.wake, func() {
+		fetchQueue.Lock()
+		if fetchQueue.schedules[channelID] == schedule {
+			delete(fetchQueue.schedules, channelID)
+		}
+		fetchQueue.Unlock()
+	}
+}
+
+// recordFetchRun notes that a channel's fetch loop ran and when it runs next
+func recordFetchRun(channelID uint, at, next time.Time) {
+	fetchQueue.Lock()
+	defer fetchQueue.Unlock()
+	if schedule, ok := fetchQueue.schedules[channelID]; ok {
+		schedule.lastFetch, schedule.nextFetch = at, next
+	}
+}
+
+// PrioritizeChannelFetches fetches a channel now and lets its proxy requests jump the queue for
+// FetchPriorityDuration. It returns when the priority ends.
+func PrioritizeChannelFetches(channelID uint) (time.Time, error) {
+	fetchQueue.Lock()
+	defer fetchQueue.Unlock()
+	schedule, ok := fetchQueue.schedules[channelID]
+	if !ok {
+		return time.Time{}, ErrChannelNotFetched
+	}
+	until := time.Now().Add(FetchPriorityDuration)
+	fetchQueue.prioritized[schedule.username] = until
+	for key, expires := range fetchQueue.prioritized {
+		if expires.Before(time.Now()) {
+			delete(fetchQueue.prioritized, key)
+		}
+	}
+	select {
+	case schedule.wake <- struct{}{}:
+	default: // Already woken
+	}
+	return until, nil
+}
+
+// FetchQueueStatus is the state of the proxy request scheduler of this instance
+type FetchQueueStatus struct {
+	Concurrency        int                  `json:"concurrency"`         // Proxy slots (FETCH_CONCURRENCY)
+	ChannelConcurrency int                  `json:"channel_concurrency"` // Soft share of the slots per channel
+	InFlight           []FetchRequestStatus `json:"in_flight"`           // Oldest first
+	Queued             []FetchRequestStatus `json:"queued"`              // In the order they get a slot
+	Channels           []ChannelFetchStatus `json:"channels"`            // Most overdue first
+	OldestQueuedMs     int64                `json:"oldest_queued_ms"`    // How long the oldest queued request has waited
+}
+
+// FetchRequestStatus is a proxy request in the scheduler
+type FetchRequestStatus struct {
+	Key         string     `json:"key"` // Channel username, or the shared key of requests not made for a channel
+	URL         string     `json:"url"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	WaitedMs    int64      `json:"waited_ms"` // Time in the queue, so far for queued requests
+	Prioritized bool       `json:"prioritized"`
+}
+
+// ChannelFetchStatus is the fetch schedule of a channel
+type ChannelFetchStatus struct {
+	ChannelID        uint       `json:"channel_id"`
+	Username         string     `json:"username"`
+	LastFetch        *time.Time `json:"last_fetch,omitempty"`
+	NextFetch        *time.Time `json:"next_fetch,omitempty"`
+	PollInterval     string     `json:"poll_interval"` // Longer while the channel is over its proxy budget
+	Overdue          bool       `json:"overdue"`       // Its next fetch is late, usually stuck behind queued requests
+	InFlight         int        `json:"in_flight"`
+	Queued           int        `json:"queued"`
+	PrioritizedUntil *time.Time `json:"prioritized_until,omitempty"`
+}
+
+// GetFetchQueueStatus returns the queued and in-flight proxy requests and the schedule of every fetched channel
+func GetFetchQueueStatus() FetchQueueStatus {
+	fetchQueue.Lock()
+	defer fetchQueue.Unlock()
+
+	now := time.Now()
+	status := FetchQueueStatus{
+		Concurrency:        Capacity.FetchConcurrency,
+		ChannelConcurrency: FetchChannelConcurrency,
+		InFlight:           []FetchRequestStatus{},
+		Queued:             []FetchRequestStatus{},
+		Channels:           []ChannelFetchStatus{},
+	}
+	inFlight, queued := inFlightPerKeyLocked(), make(map[string]int)
+	for _, req := range fetchQueue.inFlight {
+		startedAt := req.startedAt
+		status.InFlight = append(status.InFlight, FetchRequestStatus{
+			Key: req.key, URL: req.url, QueuedAt: req.queuedAt, StartedAt: &startedAt,
+			WaitedMs: req.startedAt.Sub(req.queuedAt).Milliseconds(), Prioritized: fetchQueue.prioritized[req.key].After(now),
+		})
+	}
+	sort.Slice(status.InFlight, func(i, j int) bool { return status.InFlight[i].StartedAt.Before(*status.InFlight[j].StartedAt) })
+
+	// Replay the slot hand-out on a copy of the queue to list it in the order it drains
+	waiting := append([]*fetchRequest(nil), fetchQueue.waiting...)
+	holding := inFlightPerKeyLocked()
+	for len(waiting) > 0 {
+		next := nextFetch(waiting, holding, now)
+		req := waiting[next]
+		waiting = append(waiting[:next], waiting[next+1:]...)
+		holding[req.key]++ // Holds its slot until the end of the replay, as the channel share counts it
+		status.Queued = append(status.Queued, FetchRequestStatus{
+			Key: req.key, URL: req.url, QueuedAt: req.queuedAt,
+			WaitedMs: now.Sub(req.queuedAt).Milliseconds(), Prioritized: fetchQueue.prioritized[req.key].After(now),
+		})
+		queued[req.key]++
+		status.OldestQueuedMs = max(status.OldestQueuedMs, now.Sub(req.queuedAt).Milliseconds())
+	}
+
+	for _, schedule := range fetchQueue.schedules {
+		channel := ChannelFetchStatus{
+			ChannelID:    schedule.channelID,
+			Username:     schedule.username,
+			PollInterval: pollInterval(schedule.username).String(),
+			InFlight:     inFlight[schedule.username],
+			Queued:       queued[schedule.username],
+		}
+		if !schedule.lastFetch.IsZero() {
+			lastFetch, nextFetch := schedule.lastFetch, schedule.nextFetch
+			channel.LastFetch, channel.NextFetch = &lastFetch, &nextFetch
+			channel.Overdue = now.Sub(nextFetch) > FetchInterval
+		}
+		if until := fetchQueue.prioritized[schedule.username]; until.After(now) {
+			channel.PrioritizedUntil = &until
+		}
+		status.Channels = append(status.Channels, channel)
+	}
+	sort.Slice(status.Channels, func(i, j int) bool {
+		a, b := status.Channels[i], status.Channels[j]
+		if a.NextFetch == nil || b.NextFetch == nil {
+			return a.NextFetch == nil && b.NextFetch != nil
+		}
+		if !a.NextFetch.Equal(*b.NextFetch) {
+			return a.NextFetch.Before(*b.NextFetch)
+		}
+		return a.Username < b.Username
+	})
+	return status
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Fetcher fetches Kick pages and API URLs, which sit behind a bot challenge, and returns what they rendered
+type Fetcher interface {
+	FetchPage(url string) (string, error)
+}
+
+// FormPoster is implemented by the Fetchers that can also post forms, with the cookies of a signed-in session
+type FormPoster interface {
+	PostForm(url string, form url.Values, cookies []*http.Cookie) (string, error)
+}
+
+// ProxyFetcher fetches through the challenge-solving proxy at URL (FlareSolverr's request.get API)
+type ProxyFetcher struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewProxyFetcher returns a Fetcher going through the proxy at url
+func NewProxyFetcher(url string) (*ProxyFetcher, error) {
+	if url == "" {
+		return nil, errors.New("proxy URL cannot be empty")
+	}
+	return &ProxyFetcher{URL: url, Client: http.DefaultClient}, nil
+}
+
+func (f *ProxyFetcher) FetchPage(apiURL string) (string, error) {
+	return f.do(ProxyRequestPayload{
+		Cmd:        "request.get",
+		URL:        apiURL,
+		MaxTimeout: 60000,
+	})
+}
+
+func (f *ProxyFetcher) PostForm(apiURL string, form url.Values, cookies []*http.Cookie) (string, error) {
+	payload := ProxyRequestPayload{
+		Cmd:        "request.post",
+		URL:        apiURL,
+		MaxTimeout: 60000,
+		PostData:   form.Encode(),
+	}
+	for _, cookie := range cookies {
+		payload.Cookies = append(payload.Cookies, ProxyCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return f.do(payload)
+}
+
+func (f *ProxyFetcher) do(payload ProxyRequestPayload) (string, error) {
+	apiURL := payload.URL
+	proxyReqBody, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling proxy request payload: %w", err)
+	}
+
+	resp, err := f.Client.Post(f.URL, "application/json", bytes.NewBuffer(proxyReqBody))
+	if err != nil {
+		return "", fmt.Errorf("error sending request to proxy for %s: %w", apiURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading proxy response body for %s: %w", apiURL, err)
+	}
+
+	var proxyResp ProxyResponse
+	if err := json.Unmarshal(body, &proxyResp); err != nil {
+		return "", fmt.Errorf("error unmarshalling proxy response for %s: %w", apiURL, err)
+	}
+	if proxyResp.Status != "ok" {
+		return "", fmt.Errorf("proxy returned non-ok status for %s: %s", apiURL, proxyResp.Message)
+	}
+	return proxyResp.Solution.Response, nil
+}
+
+// pageFetcher is the Fetcher every Kick request goes through, nil until one is set
+var pageFetcher Fetcher
+
+// SetFetcher sets the Fetcher Kick requests go through. Tests pass one serving canned pages.
+func SetFetcher(f Fetcher) {
+	pageFetcher = f
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// flaggedChatters caches flagged usernames per channel for lookups at ingestion time
+var flaggedChatters = struct {
+	sync.RWMutex
+	byChannel map[uint]map[string]struct{}
+}{byChannel: make(map[uint]map[string]struct{})}
+
+// LoadFlaggedChatters replaces the in-memory flagged chatter cache with the flags in the database.
+func LoadFlaggedChatters() error {
+	var flagged []models.FlaggedChatter
+	if err := db.DB.Find(&flagged).Error; err != nil {
+		return fmt.Errorf("failed to load flagged chatters: %w", err)
+	}
+
+	byChannel := make(map[uint]map[string]struct{})
+	for _, f := range flagged {
+		if byChannel[f.ChannelID] == nil {
+			byChannel[f.ChannelID] = make(map[string]struct{})
+		}
+		byChannel[f.ChannelID][f.SenderUsername] = struct{}{}
+	}
+	flaggedChatters.Lock()
+	flaggedChatters.byChannel = byChannel
+	flaggedChatters.Unlock()
+	log.Printf("Loaded %d flagged chatters", len(flagged))
+	return nil
+}
+
+func cacheFlaggedChatter(channelID uint, username string) {
+	flaggedChatters.Lock()
+	defer flaggedChatters.Unlock()
+	if flaggedChatters.byChannel[channelID] == nil {
+		flaggedChatters.byChannel[channelID] = make(map[string]struct{})
+	}
+	flaggedChatters.byChannel[channelID][username] = struct{}{}
+}
+
+func uncacheFlaggedChatter(channelID uint, username string) {
+	flaggedChatters.Lock()
+	defer flaggedChatters.Unlock()
+	delete(flaggedChatters.byChannel[channelID], username)
+}
+
+// FlagChatter marks a chatter as watched on a channel.
+func FlagChatter(channelID uint, username, reason, flaggedBy string) (models.FlaggedChatter, error) {
+	flagged := models.FlaggedChatter{
+		ChannelID:      channelID,
+		SenderUsername: strings.ToLower(username),
+		Reason:         reason,
+		FlaggedBy:      flaggedBy,
+	}
+	if err := db.DB.Save(&flagged).Error; err != nil {
+		return models.FlaggedChatter{}, fmt.Errorf("failed to flag chatter %s on channel %d: %w", username, channelID, err)
+	}
+
+	cacheFlaggedChatter(channelID, flagged.SenderUsername)
+	shareFlaggedChatter(flaggedChatterChange{ChannelID: channelID, Username: flagged.SenderUsername, Flagged: true})
+	return flagged, nil
+}
+
+// UnflagChatter removes a chatter from the watch list of a channel.
+func UnflagChatter(channelID uint, username string) error {
+	username = strings.ToLower(username)
+	if err := db.DB.Where("channel_id = ? AND sender_username = ?", channelID, username).Delete(&models.FlaggedChatter{}).Error; err != nil {
+		return fmt.Errorf("failed to unflag chatter %s on channel %d: %w", username, channelID, err)
+	}
+
+	uncacheFlaggedChatter(channelID, username)
+	shareFlaggedChatter(flaggedChatterChange{ChannelID: channelID, Username: username})
+	return nil
+}
+
+// isFlaggedChatter reports whether a chatter is flagged on a channel.
+func isFlaggedChatter(channelID uint, username string) bool {
+	flaggedChatters.RLock()
+	defer flaggedChatters.RUnlock()
+
+	_, ok := flaggedChatters.byChannel[channelID][strings.ToLower(username)]
+	return ok
+}
@@ -0,0 +1,114 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// FollowerAttributionWindow is how long after a stream ends new followers are still credited to it, people
+// following from the VOD or a clip shortly after. It never reaches into the channel's next stream.
+var FollowerAttributionWindow = util.GetEnvDuration("FOLLOWER_ATTRIBUTION_WINDOW", 30*time.Minute)
+
+// followerSample is a follower count from a channel snapshot
+type followerSample struct {
+	Time  time.Time
+	Count int
+}
+
+// loadFollowerSamples returns the channel's follower counts recorded in (start, end], preceded by the last one
+// recorded at or before start
+func loadFollowerSamples(channelID uint, start, end time.Time) ([]followerSample, error) {
+	var samples []followerSample
+	if err := db.DB.Raw(`
+		(SELECT created_at AS "time", (data->>'followers_count')::int AS count
+		FROM channel_data
+		WHERE channel_id = ? AND created_at <= ? AND data->>'followers_count' IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT 1)
+		UNION ALL
+		(SELECT created_at, (data->>'followers_count')::int
+		FROM channel_data
+		WHERE channel_id = ? AND created_at > ? AND created_at <= ? AND data->>'followers_count' IS NOT NULL)
+		ORDER BY "time"`, channelID, start, channelID, start, end).Scan(&samples).Error; err != nil {
+		return nil, fmt.Errorf("failed to load follower counts of channel %d: %w", channelID, err)
+	}
+	return samples, nil
+}
+
+// followerAttributionEnd is when follows stop being credited to a livestream that ended at end: after
+// FollowerAttributionWindow, or when the channel's next stream starts
+func followerAttributionEnd(channelID, livestreamID uint, end time.Time) time.Time {
+	until := end.Add(max(FollowerAttributionWindow, 0))
+	var next *time.Time
+	if err := db.DB.Raw(`
+		SELECT MIN(start_time) FROM livestream_data
+		WHERE channel_id = ? AND livestream_id <> ? AND start_time > ?`, channelID, livestreamID, end).Scan(&next).Error; err != nil {
+		return until
+	}
+	if next != nil && next.Before(until) {
+		return *next
+	}
+	return until
+}
+
+// followersGained diffs the follower count at start against the one at end, each the last sample at or before
+// that time. A channel first sampled mid-stream is diffed from that sample. ok is false without two samples.
+func followersGained(samples []followerSample, start, end time.Time) (gained int, ok bool) {
+	var first, last *followerSample
+	for i := range samples {
+		if samples[i].Time.After(end) {
+			break
+		}
+		if first == nil || !samples[i].Time.After(start) {
+			first = &samples[i]
+		}
+		last = &samples[i]
+	}
+	if first == nil || first == last {
+		return 0, false
+	}
+	return last.Count - first.Count, true
+}
+
+// FollowerConversion is a stream ranked by the followers it gained per hour watched
+type FollowerConversion struct {
+	Rank                    int       `json:"rank"`
+	ReportID                uuid.UUID `json:"report_id"`
+	LivestreamID            uint      `json:"livestream_id"`
+	Title                   string    `json:"title"`
+	Category                string    `json:"category,omitempty"`
+	StartTime               time.Time `json:"start_time"`
+	DurationMinutes         int       `json:"duration_minutes"`
+	AverageViewers          int       `json:"average_viewers"`
+	HoursWatched            float64   `json:"hours_watched"`
+	FollowersGained         int       `json:"followers_gained"`
+	FollowersPerHourWatched float64   `json:"followers_per_hour_watched"`
+}
+
+// RankFollowerConversion returns the channel's streams since the given time ordered by followers gained per hour
+// watched, best first. Windowed and chunk reports are left out, their follower counts cover part of a stream.
+func RankFollowerConversion(channelID uint, since time.Time, limit int) ([]FollowerConversion, error) {
+	var ranked []FollowerConversion
+	if err := db.DB.Raw(`
+		SELECT id AS report_id, livestream_id, title, category, report_start_time AS start_time, duration_minutes,
+			average_viewers, hours_watched, followers_gained, followers_per_hour_watched
+		FROM livestream_reports
+		WHERE channel_id = ? AND parent_report_id IS NULL AND window_start IS NULL AND window_end IS NULL
+			AND report_start_time >= ? AND hours_watched > 0
+		ORDER BY followers_per_hour_watched DESC, report_start_time DESC
+		LIMIT ?`, channelID, since, limit).Scan(&ranked).Error; err != nil {
+		return nil, fmt.Errorf("failed to rank streams of channel %d by follower conversion: %w", channelID, err)
+	}
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	if ranked == nil {
+		ranked = []FollowerConversion{}
+	}
+	return ranked, nil
+}
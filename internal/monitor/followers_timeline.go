@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The followers timeline of a profile grows by one point per fetch, appended in the database so a fetch neither
+// reads the timeline nor the channel's snapshots. Profiles from before the incremental timeline are rebuilt once
+// from channel_data by the followers backfill job.
+
+// appendFollowersSQL appends a point unless the timeline already reaches its time, so a point the backfill took
+// from channel_data is not added twice
+const appendFollowersSQL = `
+UPDATE streamer_profiles
+SET followers_count = COALESCE(followers_count, '[]'::jsonb) || ?::jsonb
+WHERE channel_id = ?
+  AND COALESCE((followers_count->-1->>'time')::timestamptz, '-infinity') < ?`
+
+// appendFollowersPoint appends the follower count of a fetch to the profile's timeline
+func appendFollowersPoint(channelID uint, point models.FollowersCountPoint) error {
+	encoded, err := json.Marshal([]models.FollowersCountPoint{point})
+	if err != nil {
+		return fmt.Errorf("failed to marshal followers point: %w", err)
+	}
+	if err := db.DB.Exec(appendFollowersSQL, string(encoded), channelID, point.Time).Error; err != nil {
+		return fmt.Errorf("failed to append followers point for channel %d: %w", channelID, err)
+	}
+	return nil
+}
+
+// RunFollowersBackfill rebuilds, once, the followers timeline of the profiles created before it was built
+// incrementally. Each channel is a leased job, so instances share the work and a crashed run is picked up by
+// RunJobRecovery.
+func RunFollowersBackfill(stop <-chan struct{}) {
+	var channelIDs []uint
+	if err := db.DB.Model(&models.StreamerProfile{}).Where("followers_backfilled_at IS NULL").
+		Order("channel_id").Pluck("channel_id", &channelIDs).Error; err != nil {
+		log.Printf("Error listing profiles to backfill followers for: %v", err)
+		return
+	}
+	if len(channelIDs) > 0 {
+		log.Printf("Backfilling the followers timeline of %d profiles", len(channelIDs))
+	}
+
+	for _, channelID := range channelIDs {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		lease, err := acquireJobLease(JobKindFollowersBackfill, strconv.FormatUint(uint64(channelID), 10), nil)
+		if errors.Is(err, ErrJobLeased) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error leasing followers backfill of channel %d: %v", channelID, err)
+			return // Draining, or the database is unavailable
+		}
+		if err := runLeasedJob(lease, func() error { return backfillFollowersTimeline(channelID) }); err != nil {
+			log.Printf("Error backfilling followers of channel %d: %v", channelID, err)
+		}
+	}
+}
+
+func runFollowersBackfillJob(lease *models.JobLease) error {
+	channelID, err := strconv.ParseUint(lease.JobKey, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid channel ID %q: %w", lease.JobKey, err)
+	}
+	return backfillFollowersTimeline(uint(channelID))
+}
+
+// backfillFollowersTimeline rebuilds a profile's followers timeline from its channel_data snapshots. Points of the
+// current timeline outside the snapshots' range are kept, in case snapshots were deleted since they were appended.
+func backfillFollowersTimeline(channelID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		// Fetches block on the lock and append their point once the rebuilt timeline is in place
+		var profile models.StreamerProfile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("channel_id = ?", channelID).Take(&profile).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock profile of channel %d: %w", channelID, err)
+		}
+		if profile.FollowersBackfilledAt != nil {
+			return nil
+		}
+
+		snapshots, err := loadFollowersSnapshots(tx, channelID)
+		if err != nil {
+			return err
+		}
+
+		var current []models.FollowersCountPoint
+		if len(profile.FollowersCount) > 0 {
+			if err := json.Unmarshal(profile.FollowersCount, &current); err != nil {
+				log.Printf("Warning: discarding unreadable followers timeline of channel %d: %v", channelID, err)
+				current = nil
+			}
+		}
+		timeline := mergeFollowersTimeline(current, snapshots)
+
+		encoded, err := json.Marshal(timeline)
+		if err != nil {
+			return fmt.Errorf("failed to marshal followers timeline: %w", err)
+		}
+		if err := tx.Model(&models.StreamerProfile{}).Where("channel_id = ?", channelID).
+			Updates(map[string]any{"followers_count": json.RawMessage(encoded), "followers_backfilled_at": time.Now().UTC()}).Error; err != nil {
+			return fmt.Errorf("failed to save followers timeline of channel %d: %w", channelID, err)
+		}
+		log.Printf("Backfilled %d followers points for channel %d", len(timeline), channelID)
+		return nil
+	})
+}
+
+// loadFollowersSnapshots returns the follower count of every channel_data snapshot of a channel, oldest first.
+// Only followers_count is read from the snapshots, streamed row by row.
+func loadFollowersSnapshots(tx *gorm.DB, channelID uint) ([]models.FollowersCountPoint, error) {
+	rows, err := tx.Model(&models.ChannelData{}).
+		Select("created_at, (data->>'followers_count')::bigint").
+		Where("channel_id = ? AND jsonb_typeof(data->'followers_count') = 'number'", channelID).
+		Order("created_at ASC").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channel data of channel %d: %w", channelID, err)
+	}
+	defer rows.Close()
+	snapshots := []models.FollowersCountPoint{}
+	for rows.Next() {
+		var point models.FollowersCountPoint
+		if err := rows.Scan(&point.Time, &point.Count); err != nil {
+			return nil, fmt.Errorf("failed to read channel data of channel %d: %w", channelID, err)
+		}
+		snapshots = append(snapshots, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read channel data of channel %d: %w", channelID, err)
+	}
+	return snapshots, nil
+}
+
+// mergeFollowersTimeline returns the snapshots, sorted by time, with the points of current before the first and
+// after the last of them
+func mergeFollowersTimeline(current, snapshots []models.FollowersCountPoint) []models.FollowersCountPoint {
+	if len(snapshots) == 0 {
+		if current == nil {
+			return []models.FollowersCountPoint{}
+		}
+		return current
+	}
+	first, last := snapshots[0].Time, snapshots[len(snapshots)-1].Time
+	timeline := make([]models.FollowersCountPoint, 0, len(snapshots)+len(current))
+	for _, point := range current {
+		if point.Time.Before(first) || point.Time.After(last) {
+			timeline = append(timeline, point)
+		}
+	}
+	timeline = append(timeline, snapshots...)
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+	return timeline
+}
@@ -0,0 +1,266 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// ForecastHistoryDays is how many days of history forecasts are fitted on
+var ForecastHistoryDays = util.GetEnvInt("FORECAST_HISTORY_DAYS", 90)
+
+const (
+	forecastHorizonDays = 30
+	forecastSeason      = 7 // Weekly seasonality, streams follow the weekly schedule
+	forecastMinPoints   = 3 // Fewer observed days give no forecast at all
+)
+
+// Forecast methods
+const (
+	ForecastHoltWinters      = "holt_winters" // Additive Holt-Winters with weekly seasonality, needs two full weeks
+	ForecastLinearTrend      = "linear_trend"
+	ForecastInsufficientData = "insufficient_data"
+)
+
+var (
+	holtWintersAlphas = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+	holtWintersBetas  = []float64{0.01, 0.05, 0.1, 0.2}
+	holtWintersGammas = []float64{0.05, 0.1, 0.3, 0.5}
+)
+
+// ChannelForecast projects the average viewers and followers of a channel
+type ChannelForecast struct {
+	ChannelID   uint           `json:"channel_id"`
+	Username    string         `json:"username"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Viewers     MetricForecast `json:"viewers"`   // Duration-weighted average viewers of the streams of a day
+	Followers   MetricForecast `json:"followers"` // Follower count at the end of a day
+}
+
+// MetricForecast is the daily projection of one metric
+type MetricForecast struct {
+	Method       string          `json:"method"`
+	HistoryDays  int             `json:"history_days"` // Days between the first and last observation
+	LastObserved *time.Time      `json:"last_observed,omitempty"`
+	LastValue    float64         `json:"last_value"`
+	In7Days      float64         `json:"in_7_days"`
+	In30Days     float64         `json:"in_30_days"`
+	Points       []ForecastPoint `json:"points"` // One per day for the next 30 days
+}
+
+// ForecastPoint is a projected value with a rough 95% interval
+type ForecastPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+type dailyValue struct {
+	Day   time.Time
+	Value float64
+}
+
+// BuildForecast projects a channel's average viewers and followers for the next 7 and 30 days
+func BuildForecast(channel models.MonitoredChannel, now time.Time) (ChannelForecast, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -ForecastHistoryDays)
+
+	var viewers []dailyValue
+	if err := db.DB.Raw(`
+		SELECT date_trunc('day', report_start_time AT TIME ZONE 'UTC') AS day,
+			SUM(average_viewers * duration_minutes)::float / NULLIF(SUM(duration_minutes), 0) AS value
+		FROM livestream_reports
+		WHERE channel_id = ? AND parent_report_id IS NULL AND report_start_time >= ? AND duration_minutes > 0
+		GROUP BY 1
+		ORDER BY 1`, channel.ChannelID, since).Scan(&viewers).Error; err != nil {
+		return ChannelForecast{}, fmt.Errorf("failed to load daily viewers of channel %d: %w", channel.ChannelID, err)
+	}
+
+	var followers []dailyValue
+	if err := db.DB.Raw(`
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, MAX((data->>'followers_count')::float) AS value
+		FROM channel_data
+		WHERE channel_id = ? AND created_at >= ? AND data->>'followers_count' IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1`, channel.ChannelID, since).Scan(&followers).Error; err != nil {
+		return ChannelForecast{}, fmt.Errorf("failed to load daily followers of channel %d: %w", channel.ChannelID, err)
+	}
+
+	return ChannelForecast{
+		ChannelID:   channel.ChannelID,
+		Username:    channel.Username,
+		GeneratedAt: now,
+		Viewers:     forecastDaily(viewers, today),
+		Followers:   forecastDaily(followers, today),
+	}, nil
+}
+
+// forecastDaily fits the observed days (gaps interpolated) and projects them from today
+func forecastDaily(observed []dailyValue, today time.Time) MetricForecast {
+	forecast := MetricForecast{Method: ForecastInsufficientData, Points: []ForecastPoint{}}
+	if len(observed) == 0 {
+		return forecast
+	}
+	last := observed[len(observed)-1]
+	lastDay := last.Day.UTC().Truncate(24 * time.Hour)
+	forecast.LastObserved = &lastDay
+	forecast.LastValue = roundForecast(last.Value)
+	if len(observed) < forecastMinPoints {
+		return forecast
+	}
+
+	series := interpolateDaily(observed)
+	forecast.HistoryDays = len(series)
+
+	// The series ends at the last observation, which may be days before today
+	offset := max(int(today.Sub(lastDay).Hours()/24), 0)
+	horizon := offset + forecastHorizonDays
+
+	var predict func(h int) float64
+	var residuals []float64
+	if len(series) >= 2*forecastSeason {
+		forecast.Method = ForecastHoltWinters
+		predict, residuals = fitHoltWinters(series, forecastSeason)
+	} else {
+		forecast.Method = ForecastLinearTrend
+		predict, residuals = fitLinearTrend(series)
+	}
+	spread := 1.96 * stddev(residuals)
+
+	for h := offset + 1; h <= horizon; h++ {
+		value := math.Max(predict(h), 0)
+		margin := spread * math.Sqrt(float64(h))
+		forecast.Points = append(forecast.Points, ForecastPoint{
+			Date:  lastDay.AddDate(0, 0, h),
+			Value: roundForecast(value),
+			Lower: roundForecast(math.Max(value-margin, 0)),
+			Upper: roundForecast(value + margin),
+		})
+	}
+	forecast.In7Days = forecast.Points[6].Value
+	forecast.In30Days = forecast.Points[forecastHorizonDays-1].Value
+	return forecast
+}
+
+// interpolateDaily turns observations into one value per day, linearly filling the days without one
+func interpolateDaily(observed []dailyValue) []float64 {
+	series := []float64{observed[0].Value}
+	for i := 1; i < len(observed); i++ {
+		prev, cur := observed[i-1], observed[i]
+		gap := int(cur.Day.Sub(prev.Day).Hours() / 24)
+		for d := 1; d < gap; d++ {
+			series = append(series, prev.Value+(cur.Value-prev.Value)*float64(d)/float64(gap))
+		}
+		series = append(series, cur.Value)
+	}
+	return series
+}
+
+// fitHoltWinters fits additive Holt-Winters, picking the smoothing parameters with the lowest one-step-ahead
+// error, and returns the h-step-ahead predictor and the in-sample residuals
+func fitHoltWinters(series []float64, season int) (func(h int) float64, []float64) {
+	var bestPredict func(h int) float64
+	var bestResiduals []float64
+	bestSSE := math.Inf(1)
+
+	for _, alpha := range holtWintersAlphas {
+		for _, beta := range holtWintersBetas {
+			for _, gamma := range holtWintersGammas {
+				predict, residuals := holtWinters(series, season, alpha, beta, gamma)
+				sse := 0.0
+				for _, r := range residuals {
+					sse += r * r
+				}
+				if sse < bestSSE {
+					bestSSE, bestPredict, bestResiduals = sse, predict, residuals
+				}
+			}
+		}
+	}
+	return bestPredict, bestResiduals
+}
+
+func holtWinters(series []float64, season int, alpha, beta, gamma float64) (func(h int) float64, []float64) {
+	first, second := mean(series[:season]), mean(series[season:2*season])
+	level := first
+	trend := (second - first) / float64(season)
+	seasonal := make([]float64, season)
+	for i := 0; i < season; i++ {
+		seasonal[i] = series[i] - first
+	}
+
+	residuals := make([]float64, 0, len(series)-season)
+	for t := season; t < len(series); t++ {
+		s := seasonal[t%season]
+		residuals = append(residuals, series[t]-(level+trend+s))
+
+		newLevel := alpha*(series[t]-s) + (1-alpha)*(level+trend)
+		trend = beta*(newLevel-level) + (1-beta)*trend
+		level = newLevel
+		seasonal[t%season] = gamma*(series[t]-level) + (1-gamma)*s
+	}
+
+	n := len(series)
+	return func(h int) float64 {
+		return level + float64(h)*trend + seasonal[(n-1+h)%season]
+	}, residuals
+}
+
+// fitLinearTrend fits a least squares line and returns the h-step-ahead predictor and the residuals
+func fitLinearTrend(series []float64) (func(h int) float64, []float64) {
+	n := float64(len(series))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range series {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := 0.0
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	residuals := make([]float64, len(series))
+	for i, y := range series {
+		residuals[i] = y - (intercept + slope*float64(i))
+	}
+	last := n - 1
+	return func(h int) float64 {
+		return intercept + slope*(last+float64(h))
+	}, residuals
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stddev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+func roundForecast(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// growthWindow is the length of the two periods a growth report compares
+const growthWindow = 30 * 24 * time.Hour
+
+// GrowthReport compares a channel's last 30 days with the 30 days before, from its stream reports
+type GrowthReport struct {
+	ChannelID   uint          `json:"channel_id"`
+	Username    string        `json:"username"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Current     GrowthPeriod  `json:"current"`
+	Previous    GrowthPeriod  `json:"previous"`
+	Change      GrowthChanges `json:"change"` // Percentage change from the previous period to the current one
+}
+
+// GrowthPeriod sums up the streams that started within [From, To)
+type GrowthPeriod struct {
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Streams         int       `json:"streams"`
+	HoursStreamed   float64   `json:"hours_streamed"`
+	AverageViewers  float64   `json:"average_viewers"` // Weighted by stream length
+	FollowersGained int       `json:"followers_gained"`
+	Engagement      float64   `json:"engagement"`           // Average of the streams' engagement
+	SpamScore       *float64  `json:"spam_score,omitempty"` // Average 0-100 spam score, nil when no stream has a spam report
+}
+
+// GrowthChanges are percentage deltas, nil when the previous value is zero or missing and a change can't be told
+type GrowthChanges struct {
+	Streams         *float64 `json:"streams"`
+	HoursStreamed   *float64 `json:"hours_streamed"`
+	AverageViewers  *float64 `json:"average_viewers"`
+	FollowersGained *float64 `json:"followers_gained"`
+	Engagement      *float64 `json:"engagement"`
+	SpamScore       *float64 `json:"spam_score"`
+}
+
+// BuildGrowthReport compares the 30 days up to now with the 30 days before them. Chunk reports are left out, the
+// parent report of a long stream covers it whole.
+func BuildGrowthReport(channel models.MonitoredChannel, now time.Time) (GrowthReport, error) {
+	now = now.UTC()
+	report := GrowthReport{
+		ChannelID:   channel.ChannelID,
+		Username:    channel.Username,
+		GeneratedAt: now,
+		Current:     GrowthPeriod{From: now.Add(-growthWindow), To: now},
+		Previous:    GrowthPeriod{From: now.Add(-2 * growthWindow), To: now.Add(-growthWindow)},
+	}
+
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("report_start_time, duration_minutes, average_viewers, followers_gained, engagement, "+
+		"total_messages, persisted_messages, unique_chatters, spam_report_id").
+		Where("channel_id = ? AND parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?",
+			channel.ChannelID, report.Previous.From, report.Current.To).
+		Find(&reports).Error; err != nil {
+		return report, fmt.Errorf("failed to fetch reports of channel %d: %w", channel.ChannelID, err)
+	}
+
+	spamReportIDs := make([]uuid.UUID, 0, len(reports))
+	for _, r := range reports {
+		if r.SpamReportID != nil {
+			spamReportIDs = append(spamReportIDs, *r.SpamReportID)
+		}
+	}
+	spamReports := make(map[uuid.UUID]models.SpamReport, len(spamReportIDs))
+	if len(spamReportIDs) > 0 {
+		var rows []models.SpamReport
+		if err := db.DB.Where("id IN ?", spamReportIDs).Find(&rows).Error; err != nil {
+			return report, fmt.Errorf("failed to fetch spam reports of channel %d: %w", channel.ChannelID, err)
+		}
+		for _, row := range rows {
+			spamReports[row.ID] = row
+		}
+	}
+
+	report.Current = summarizeGrowthPeriod(report.Current, reports, spamReports)
+	report.Previous = summarizeGrowthPeriod(report.Previous, reports, spamReports)
+	report.Change = growthChanges(report.Previous, report.Current)
+	return report, nil
+}
+
+// summarizeGrowthPeriod fills period from the reports of the streams that started within it
+func summarizeGrowthPeriod(period GrowthPeriod, reports []models.LivestreamReport, spamReports map[uuid.UUID]models.SpamReport) GrowthPeriod {
+	minutes, viewerMinutes, engagement := 0, 0.0, 0.0
+	spamTotal, spamStreams := 0, 0
+	for _, r := range reports {
+		if r.ReportStartTime.Before(period.From) || !r.ReportStartTime.Before(period.To) {
+			continue
+		}
+		period.Streams++
+		minutes += r.DurationMinutes
+		viewerMinutes += float64(r.AverageViewers) * float64(r.DurationMinutes)
+		period.FollowersGained += r.FollowersGained
+		engagement += r.Engagement
+		if r.SpamReportID == nil {
+			continue
+		}
+		if spamReport, ok := spamReports[*r.SpamReportID]; ok {
+			spamTotal += spamScore(r, spamReport)
+			spamStreams++
+		}
+	}
+
+	period.HoursStreamed = roundTo(float64(minutes)/60, 2)
+	if minutes > 0 {
+		period.AverageViewers = roundTo(viewerMinutes/float64(minutes), 1)
+	}
+	if period.Streams > 0 {
+		period.Engagement = roundTo(engagement/float64(period.Streams), 4)
+	}
+	if spamStreams > 0 {
+		score := roundTo(float64(spamTotal)/float64(spamStreams), 1)
+		period.SpamScore = &score
+	}
+	return period
+}
+
+func growthChanges(previous, current GrowthPeriod) GrowthChanges {
+	changes := GrowthChanges{
+		Streams:         percentChange(float64(previous.Streams), float64(current.Streams)),
+		HoursStreamed:   percentChange(previous.HoursStreamed, current.HoursStreamed),
+		AverageViewers:  percentChange(previous.AverageViewers, current.AverageViewers),
+		FollowersGained: percentChange(float64(previous.FollowersGained), float64(current.FollowersGained)),
+		Engagement:      percentChange(previous.Engagement, current.Engagement),
+	}
+	if previous.SpamScore != nil && current.SpamScore != nil {
+		changes.SpamScore = percentChange(*previous.SpamScore, *current.SpamScore)
+	}
+	return changes
+}
+
+// percentChange is the change from previous to current in percent of previous, nil when previous isn't positive
+func percentChange(previous, current float64) *float64 {
+	if previous <= 0 {
+		return nil
+	}
+	change := roundTo(100*(current-previous)/previous, 2)
+	return &change
+}
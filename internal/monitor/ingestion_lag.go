@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// A report is flagged when the 95th percentile of its messages' ingestion lag exceeds IngestionLagThreshold, or
+// when messages look sent after we stored them by more than ClockSkewThreshold at the median (clock skew). Send
+// times are now clamped to the receive time by normalizeKickTimestamp, the skew check catches messages stored before.
+var (
+	IngestionLagThreshold = util.GetEnvDuration("INGESTION_LAG_THRESHOLD", 30*time.Second)
+	ClockSkewThreshold    = util.GetEnvDuration("CLOCK_SKEW_THRESHOLD", 5*time.Second)
+)
+
+// ingestionLagBuckets are the upper bounds of the histogram buckets, in seconds
+var ingestionLagBuckets = []float64{0.25, 0.5, 1, 2, 5, 10, 30, 60, 300}
+
+// IngestionLagHistogram is the distribution of the lag between Kick's send time of a chat message and the time
+// we persisted it, since startup
+type IngestionLagHistogram struct {
+	Count      int                  `json:"count"`
+	SumSeconds float64              `json:"sum_seconds"`
+	MaxSeconds float64              `json:"max_seconds"`
+	Negative   int                  `json:"negative"` // Persisted before Kick's send time, the clocks disagree
+	Buckets    []IngestionLagBucket `json:"buckets"`  // Cumulative, like Prometheus histograms
+}
+
+// IngestionLagBucket counts the messages persisted within LE seconds
+type IngestionLagBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+var ingestionLag = struct {
+	sync.Mutex
+	counts   []int // Per bucket, plus +Inf
+	count    int
+	sum      float64
+	max      float64
+	negative int
+}{counts: make([]int, len(ingestionLagBuckets)+1)}
+
+// recordIngestionLag adds the lag of a persisted message to the histogram
+func recordIngestionLag(sentAt, persistedAt time.Time) {
+	if sentAt.IsZero() {
+		return
+	}
+	seconds := persistedAt.Sub(sentAt).Seconds()
+	bucket := sort.SearchFloat64s(ingestionLagBuckets, seconds)
+
+	ingestionLag.Lock()
+	defer ingestionLag.Unlock()
+	ingestionLag.count++
+	if seconds < 0 {
+		ingestionLag.negative++
+		bucket = 0
+	} else {
+		ingestionLag.sum += seconds
+		ingestionLag.max = math.Max(ingestionLag.max, seconds)
+	}
+	ingestionLag.counts[bucket]++
+}
+
+// GetIngestionLagHistogram returns a snapshot of the ingestion lag histogram
+func GetIngestionLagHistogram() IngestionLagHistogram {
+	ingestionLag.Lock()
+	defer ingestionLag.Unlock()
+
+	histogram := IngestionLagHistogram{
+		Count:      ingestionLag.count,
+		SumSeconds: ingestionLag.sum,
+		MaxSeconds: ingestionLag.max,
+		Negative:   ingestionLag.negative,
+		Buckets:    make([]IngestionLagBucket, 0, len(ingestionLag.counts)),
+	}
+	cumulative := 0
+	for i, count := range ingestionLag.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(ingestionLagBuckets) {
+			le = strconv.FormatFloat(ingestionLagBuckets[i], 'f', -1, 64)
+		}
+		histogram.Buckets = append(histogram.Buckets, IngestionLagBucket{LE: le, Count: cumulative})
+	}
+	return histogram
+}
+
+// reportIngestionLag is the ingestion lag of the messages of a report, from their stored send and persist times
+type reportIngestionLag struct {
+	P50     float64 // Seconds, negative when messages were stored before their send time
+	P95     float64
+	Flagged bool
+}
+
+func measureIngestionLag(messages []models.ChatMessage) reportIngestionLag {
+	lags := make([]float64, 0, len(messages))
+	for _, msg := range messages {
+		if msg.MessageSendTime.IsZero() || msg.CreatedAt.IsZero() {
+			continue
+		}
+		lags = append(lags, msg.CreatedAt.Sub(msg.MessageSendTime).Seconds())
+	}
+	if len(lags) == 0 {
+		return reportIngestionLag{}
+	}
+	sort.Float64s(lags)
+
+	lag := reportIngestionLag{
+		P50: math.Round(lags[len(lags)/2]*100) / 100,
+		P95: math.Round(lags[min(len(lags)*95/100, len(lags)-1)]*100) / 100,
+	}
+	lag.Flagged = lag.P95 > IngestionLagThreshold.Seconds() || -lag.P50 > ClockSkewThreshold.Seconds()
+	return lag
+}
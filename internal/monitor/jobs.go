@@ -0,0 +1,341 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Jobs hold a lease in job_leases while they run. A lease whose heartbeat is older than JobLeaseTTL was left
+// behind by a crashed instance and is requeued by RunJobRecovery, up to JobMaxAttempts runs in total.
+var (
+	JobHeartbeatInterval = util.GetEnvDuration("JOB_HEARTBEAT_INTERVAL", 15*time.Second)
+	JobLeaseTTL          = util.GetEnvDuration("JOB_LEASE_TTL", time.Minute)
+	JobMaxAttempts       = util.GetEnvInt("JOB_MAX_ATTEMPTS", 3)
+	JobStuckAfter        = util.GetEnvDuration("JOB_STUCK_AFTER", time.Hour)          // Jobs running longer are reported as stuck even with a live heartbeat
+	JobLeaseRetention    = util.GetEnvDuration("JOB_LEASE_RETENTION", 7*24*time.Hour) // How long finished leases are kept
+)
+
+// JobOwner identifies this instance on the leases it holds. Cluster mode replaces it with the instance ID.
+var JobOwner = defaultJobOwner()
+
+// Job kinds
+const (
+	JobKindReport            = "livestream_report"
+	JobKindFollowersBackfill = "followers_backfill" // Keyed by channel ID
+	JobKindDailyDigest       = "daily_digest"       // Keyed by day, YYYY-MM-DD
+	JobKindChatArchive       = "chat_archive"
+	JobKindBackup            = "backup"
+)
+
+// Lease statuses
+const (
+	JobStatusRunning   = "running"
+	JobStatusDone      = "done"
+	JobStatusFailed    = "failed"
+	JobStatusAbandoned = "abandoned" // Crashed JobMaxAttempts times
+)
+
+// ErrJobLeased is returned when another live lease already covers the job
+var ErrJobLeased = errors.New("job is already running")
+
+// jobRunners runs a job of each kind from its lease, used to requeue abandoned jobs
+var jobRunners = map[string]func(lease *models.JobLease) error{
+	JobKindReport:            runReportJob,
+	JobKindFollowersBackfill: runFollowersBackfillJob,
+	JobKindDailyDigest:       runDailyDigestJob,
+	JobKindChatArchive:       runChatArchiveJob,
+	JobKindBackup:            runBackupJob,
+}
+
+// JobStatus is a lease as listed in the admin API
+type JobStatus struct {
+	models.JobLease
+	Stuck       bool   `json:"stuck"`
+	StuckReason string `json:"stuck_reason,omitempty"`
+}
+
+func defaultJobOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s:%s", hostname, uuid.New().String()[:8])
+}
+
+// acquireJobLease takes the lease for (kind, key), taking over a stale lease left by a crashed instance. The job
+// then runs with runLeasedJob, heartbeating while it runs. params, when not nil, is stored on the lease for the
+// job runner.
+func acquireJobLease(kind, key string, params any) (*models.JobLease, error) {
+	if IsDraining() {
+		return nil, ErrDraining
+	}
+	var rawParams json.RawMessage
+	if params != nil {
+		var err error
+		if rawParams, err = json.Marshal(params); err != nil {
+			return nil, fmt.Errorf("failed to marshal params of %s job %s: %w", kind, key, err)
+		}
+	}
+
+	now := time.Now()
+	lease := models.JobLease{
+		ID:          uuid.New(),
+		Kind:        kind,
+		JobKey:      key,
+		Owner:       JobOwner,
+		Status:      JobStatusRunning,
+		Attempts:    1,
+		StartedAt:   now,
+		HeartbeatAt: now,
+		Params:      rawParams,
+	}
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create lease for %s job %s: %w", kind, key, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return &lease, nil
+	}
+
+	var existing models.JobLease
+	if err := db.DB.Where("kind = ? AND job_key = ? AND finished_at IS NULL", kind, key).First(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load lease of %s job %s: %w", kind, key, err)
+	}
+	claimed, err := claimStaleLease(&existing)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrJobLeased
+	}
+	// The crashed run is replaced by this request, with its params
+	if err := db.DB.Model(&models.JobLease{}).Where("id = ?", existing.ID).Update("params", rawParams).Error; err != nil {
+		return nil, fmt.Errorf("failed to update params of lease %s: %w", existing.ID.String(), err)
+	}
+	existing.Params = rawParams
+	return &existing, nil
+}
+
+// claimStaleLease takes over a lease whose heartbeat expired. It reports false when the owner is still alive or
+// another instance claimed it first.
+func claimStaleLease(lease *models.JobLease) (bool, error) {
+	now := time.Now()
+	result := db.DB.Model(&models.JobLease{}).
+		Where("id = ? AND finished_at IS NULL AND heartbeat_at < ?", lease.ID, now.Add(-JobLeaseTTL)).
+		Updates(map[string]any{
+			"owner":        JobOwner,
+			"status":       JobStatusRunning,
+			"attempts":     lease.Attempts + 1,
+			"started_at":   now,
+			"heartbeat_at": now,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim lease %s: %w", lease.ID.String(), result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	log.Printf("Claimed abandoned %s job %s from %s (attempt %d)", lease.Kind, lease.JobKey, lease.Owner, lease.Attempts+1)
+	lease.Owner, lease.Status, lease.Attempts = JobOwner, JobStatusRunning, lease.Attempts+1
+	lease.StartedAt, lease.HeartbeatAt = now, now
+	return true, nil
+}
+
+func runLeasedJob(lease *models.JobLease, fn func() error) error {
+	runningJobs.Add(1)
+	defer runningJobs.Add(-1)
+
+	done := make(chan struct{})
+	go heartbeatJobLease(lease, done)
+
+	err := fn()
+	close(done)
+
+	status, message := JobStatusDone, ""
+	if err != nil {
+		status, message = JobStatusFailed, err.Error()
+	}
+	if dbErr := db.DB.Model(&models.JobLease{}).Where("id = ? AND owner = ?", lease.ID, JobOwner).
+		Updates(map[string]any{"status": status, "finished_at": time.Now(), "error": message}).Error; dbErr != nil {
+		log.Printf("Error finishing lease of %s job %s: %v", lease.Kind, lease.JobKey, dbErr)
+	}
+	return err
+}
+
+func heartbeatJobLease(lease *models.JobLease, done <-chan struct{}) {
+	ticker := time.NewTicker(JobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			result := db.DB.Model(&models.JobLease{}).
+				Where("id = ? AND owner = ? AND finished_at IS NULL", lease.ID, JobOwner).
+				Update("heartbeat_at", time.Now())
+			if result.Error != nil {
+				log.Printf("Error heartbeating %s job %s: %v", lease.Kind, lease.JobKey, result.Error)
+			} else if result.RowsAffected == 0 {
+				log.Printf("Warning: lost the lease of %s job %s, another instance took it over", lease.Kind, lease.JobKey)
+				return
+			}
+		}
+	}
+}
+
+// isJobLeased reports whether an unfinished lease with a live heartbeat covers the job, on any instance
+func isJobLeased(kind, key string) bool {
+	var count int64
+	if err := db.DB.Model(&models.JobLease{}).
+		Where("kind = ? AND job_key = ? AND finished_at IS NULL AND heartbeat_at >= ?", kind, key, time.Now().Add(-JobLeaseTTL)).
+		Count(&count).Error; err != nil {
+		log.Printf("Error checking lease of %s job %s: %v", kind, key, err)
+		return false
+	}
+	return count > 0
+}
+
+// RunJobRecovery requeues the jobs of crashed instances, right away and then every JobLeaseTTL. It blocks until
+// stop is closed.
+func RunJobRecovery(stop <-chan struct{}) {
+	ticker := time.NewTicker(JobLeaseTTL)
+	defer ticker.Stop()
+
+	for {
+		recoverAbandonedJobs()
+		pruneJobLeases()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func recoverAbandonedJobs() {
+	if IsDraining() {
+		return
+	}
+	var stale []models.JobLease
+	if err := db.DB.Where("finished_at IS NULL AND heartbeat_at < ?", time.Now().Add(-JobLeaseTTL)).
+		Order("started_at ASC").Find(&stale).Error; err != nil {
+		log.Printf("Error loading abandoned jobs: %v", err)
+		return
+	}
+
+	for i := range stale {
+		lease := &stale[i]
+		if lease.Attempts >= JobMaxAttempts {
+			giveUpJob(lease)
+			continue
+		}
+		if err := requeueJob(lease); err != nil {
+			log.Printf("Error requeueing %s job %s: %v", lease.Kind, lease.JobKey, err)
+		}
+	}
+}
+
+// requeueJob claims a stale lease and runs its job in the background. A draining instance leaves it to the others.
+func requeueJob(lease *models.JobLease) error {
+	if IsDraining() {
+		return ErrDraining
+	}
+	runner, ok := jobRunners[lease.Kind]
+	if !ok {
+		return fmt.Errorf("unknown job kind %q", lease.Kind)
+	}
+	claimed, err := claimStaleLease(lease)
+	if err != nil || !claimed {
+		return err
+	}
+	go func() {
+		if err := runLeasedJob(lease, func() error { return runner(lease) }); err != nil {
+			log.Printf("Requeued %s job %s failed: %v", lease.Kind, lease.JobKey, err)
+		}
+	}()
+	return nil
+}
+
+func giveUpJob(lease *models.JobLease) {
+	result := db.DB.Model(&models.JobLease{}).
+		Where("id = ? AND finished_at IS NULL AND heartbeat_at < ?", lease.ID, time.Now().Add(-JobLeaseTTL)).
+		Updates(map[string]any{
+			"status":      JobStatusAbandoned,
+			"finished_at": time.Now(),
+			"error":       fmt.Sprintf("instance %s stopped heartbeating, giving up after %d attempts", lease.Owner, lease.Attempts),
+		})
+	if result.Error != nil {
+		log.Printf("Error abandoning %s job %s: %v", lease.Kind, lease.JobKey, result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Warning: gave up on %s job %s after %d attempts", lease.Kind, lease.JobKey, lease.Attempts)
+	}
+}
+
+func pruneJobLeases() {
+	if JobLeaseRetention <= 0 {
+		return
+	}
+	if err := db.DB.Where("finished_at < ?", time.Now().Add(-JobLeaseRetention)).Delete(&models.JobLease{}).Error; err != nil {
+		log.Printf("Error pruning finished job leases: %v", err)
+	}
+}
+
+// GetJobStatuses lists running jobs, and recently finished ones too when includeFinished is set, flagging the
+// stuck ones
+func GetJobStatuses(includeFinished bool) ([]JobStatus, error) {
+	query := db.DB.Order("started_at DESC")
+	if !includeFinished {
+		query = query.Where("finished_at IS NULL")
+	}
+	var leases []models.JobLease
+	if err := query.Limit(500).Find(&leases).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch job leases: %w", err)
+	}
+
+	now := time.Now()
+	statuses := make([]JobStatus, 0, len(leases))
+	for _, lease := range leases {
+		status := JobStatus{JobLease: lease}
+		if lease.FinishedAt == nil {
+			switch {
+			case now.Sub(lease.HeartbeatAt) > JobLeaseTTL:
+				status.Stuck, status.StuckReason = true, fmt.Sprintf("no heartbeat from %s since %s", lease.Owner, lease.HeartbeatAt.UTC().Format(time.RFC3339))
+			case JobStuckAfter > 0 && now.Sub(lease.StartedAt) > JobStuckAfter:
+				status.Stuck, status.StuckReason = true, fmt.Sprintf("running for %s", now.Sub(lease.StartedAt).Round(time.Second))
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// RequeueJob restarts an unfinished job on this instance, even if its owner still heartbeats (e.g. it hangs).
+// The previous owner notices it lost the lease on its next heartbeat.
+func RequeueJob(leaseID uuid.UUID) (*models.JobLease, error) {
+	var lease models.JobLease
+	if err := db.DB.Where("id = ? AND finished_at IS NULL", leaseID).First(&lease).Error; err != nil {
+		return nil, err
+	}
+	// Expire the heartbeat so the lease can be claimed like the lease of a crashed instance
+	if err := db.DB.Model(&models.JobLease{}).Where("id = ?", lease.ID).
+		Update("heartbeat_at", time.Now().Add(-2*JobLeaseTTL)).Error; err != nil {
+		return nil, fmt.Errorf("failed to expire lease %s: %w", lease.ID.String(), err)
+	}
+	if err := requeueJob(&lease); err != nil {
+		return nil, err
+	}
+	if lease.Owner != JobOwner {
+		return nil, ErrJobLeased // Another instance claimed it first
+	}
+	return &lease, nil
+}
@@ -0,0 +1,268 @@
+package monitor
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+const (
+	LanguageMinChatters    = 10 // Chatters with a detected language a stream needs before chat outweighs the declared language
+	AudienceLanguageTop    = 8  // Languages listed per stream and in the profile rollup, the rest is summed up as "other"
+	languageMinScriptRunes = 2  // Letters of a non-Latin script a message needs to be attributed to it
+)
+
+// Source of a stream's language estimate
+const (
+	LanguageSourceChat     = "chat"     // Dominant language of each chatter, from their messages
+	LanguageSourceDeclared = "declared" // Too little detectable chat, the language the streamer set on the stream
+)
+
+// latinStopwords are frequent short words of the Latin-script languages detected. Words shared by several languages
+// count for each of them, a message is only attributed when one language scores highest.
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "you", "is", "are", "this", "that", "what", "with", "for", "why", "how", "have", "was", "just", "like", "good", "game", "they", "my", "your", "im", "dont", "can", "bro", "lol"},
+	"es": {"que", "el", "los", "las", "y", "por", "para", "con", "es", "pero", "como", "muy", "bien", "hola", "jaja", "gracias", "esta", "eso", "yo", "una", "del", "mas", "hermano", "buenas"},
+	"pt": {"que", "não", "nao", "voce", "você", "muito", "mas", "com", "pra", "para", "uma", "isso", "tá", "ta", "kkk", "kkkk", "mano", "obrigado", "é", "do", "da", "os", "eu"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "ein", "eine", "was", "wie", "mit", "auf", "sehr", "gut", "ja", "auch", "hallo", "danke"},
+	"fr": {"le", "la", "les", "et", "est", "pas", "je", "tu", "vous", "une", "des", "du", "que", "cest", "mais", "bonjour", "merci", "oui", "trop", "mdr"},
+	"it": {"il", "che", "non", "di", "è", "sono", "per", "una", "ciao", "grazie", "bene", "molto", "questo", "come", "anche"},
+	"tr": {"ve", "bir", "bu", "ne", "çok", "cok", "da", "de", "mi", "için", "icin", "ben", "sen", "var", "yok", "abi", "merhaba", "evet", "tamam"},
+	"pl": {"nie", "jest", "to", "się", "sie", "na", "że", "co", "jak", "tak", "ale", "czy", "dzięki", "siema", "witam"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "dat", "wat", "van", "op", "maar", "hoi", "goed"},
+}
+
+// latinLetters are letters that only a few of the detected languages use
+var latinLetters = map[rune]string{
+	'ñ': "es", '¿': "es", '¡': "es",
+	'ã': "pt", 'õ': "pt",
+	'ß': "de",
+	'ğ': "tr", 'ş': "tr", 'ı': "tr",
+	'ł': "pl", 'ś': "pl", 'ż': "pl", 'ź': "pl", 'ć': "pl", 'ń': "pl", 'ą': "pl", 'ę': "pl",
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range latinStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// detectLanguage guesses the ISO 639-1 language of a chat message, empty when it can't tell (emotes, links,
+// single words shared by several languages). Non-Latin scripts decide on their own.
+func detectLanguage(content string) string {
+	content = strings.ToLower(emoteRegex.ReplaceAllString(content, " "))
+
+	scripts := make(map[string]int)
+	scores := make(map[string]int)
+	for _, r := range content {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			if strings.ContainsRune("іїєґ", r) {
+				scripts["uk"] += 3
+			}
+			scripts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		default:
+			if lang, ok := latinLetters[r]; ok {
+				scores[lang] += 2
+			}
+		}
+	}
+	// Japanese mixes kana with kanji, Ukrainian is Cyrillic with a few letters of its own
+	if scripts["ja"] > 0 {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+	if scripts["uk"] > 0 {
+		scripts["uk"] += scripts["ru"]
+		delete(scripts, "ru")
+	}
+	if lang, count := bestLanguage(scripts); lang != "" && count >= languageMinScriptRunes {
+		return lang
+	}
+
+	for _, word := range strings.FieldsFunc(content, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) {
+		for _, lang := range stopwordLanguages[strings.ReplaceAll(word, "'", "")] {
+			scores[lang]++
+		}
+	}
+	lang, _ := bestLanguage(scores)
+	return lang
+}
+
+// bestLanguage returns the highest scoring language, empty on a tie or without any score
+func bestLanguage(scores map[string]int) (string, int) {
+	best, bestScore, tied := "", 0, false
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = lang, score, false
+		case score == bestScore && score > 0:
+			tied = true
+		}
+	}
+	if tied {
+		return "", 0
+	}
+	return best, bestScore
+}
+
+// ChatLanguages is the language section of a livestream report, an estimate of the audience's languages since Kick
+// shares no audience geography. Each chatter counts once, for the language most of their messages were in.
+type ChatLanguages struct {
+	Source               string          `json:"source"`                      // chat or declared
+	DeclaredLanguage     string          `json:"declared_language,omitempty"` // Language the streamer set on the stream
+	DetectedChatters     int             `json:"detected_chatters"`
+	UndeterminedChatters int             `json:"undetermined_chatters"` // Only emotes, links or ambiguous messages
+	Languages            []LanguageShare `json:"languages"`             // Largest first
+}
+
+type LanguageShare struct {
+	Language string  `json:"language"` // ISO 639-1, "other" for the languages past the top ones
+	Chatters int     `json:"chatters"`
+	Share    float64 `json:"share"`
+}
+
+// analyzeChatLanguages estimates the audience languages of a stream from its chat, falling back to the declared
+// language when fewer than LanguageMinChatters chatters wrote something detectable
+func analyzeChatLanguages(messages []models.ChatMessage, viewerCounts []models.LivestreamData) ChatLanguages {
+	analysis := ChatLanguages{Source: LanguageSourceChat, Languages: []LanguageShare{}}
+	for i := len(viewerCounts) - 1; i >= 0; i-- {
+		if viewerCounts[i].LangISO != "" {
+			analysis.DeclaredLanguage = strings.ToLower(viewerCounts[i].LangISO)
+			break
+		}
+	}
+
+	perChatter := make(map[int]map[string]int)
+	for _, msg := range messages {
+		if perChatter[msg.SenderID] == nil {
+			perChatter[msg.SenderID] = make(map[string]int)
+		}
+		if lang := detectLanguage(msg.Message); lang != "" {
+			perChatter[msg.SenderID][lang]++
+		}
+	}
+
+	chatters := make(map[string]int)
+	for _, langs := range perChatter {
+		lang, _ := bestLanguage(langs)
+		if lang == "" {
+			analysis.UndeterminedChatters++
+			continue
+		}
+		chatters[lang]++
+		analysis.DetectedChatters++
+	}
+
+	if analysis.DetectedChatters < LanguageMinChatters {
+		analysis.Source = LanguageSourceDeclared
+		if analysis.DeclaredLanguage != "" {
+			analysis.Languages = []LanguageShare{{Language: analysis.DeclaredLanguage, Share: 1}}
+		}
+		return analysis
+	}
+	analysis.Languages = languageShares(chatters)
+	return analysis
+}
+
+// languageShares turns chatter counts per language into shares, largest first, folding the tail into "other"
+func languageShares(chatters map[string]int) []LanguageShare {
+	total := 0
+	shares := make([]LanguageShare, 0, len(chatters))
+	for lang, count := range chatters {
+		shares = append(shares, LanguageShare{Language: lang, Chatters: count})
+		total += count
+	}
+	if total == 0 {
+		return []LanguageShare{}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Chatters != shares[j].Chatters {
+			return shares[i].Chatters > shares[j].Chatters
+		}
+		return shares[i].Language < shares[j].Language
+	})
+	if len(shares) > AudienceLanguageTop {
+		other := LanguageShare{Language: "other"}
+		for _, share := range shares[AudienceLanguageTop:] {
+			other.Chatters += share.Chatters
+		}
+		shares = append(shares[:AudienceLanguageTop], other)
+	}
+	for i := range shares {
+		shares[i].Share = roundTo(float64(shares[i].Chatters)/float64(total), 3)
+	}
+	return shares
+}
+
+// AudienceLanguages is the profile's audience-language estimate: the chatters of every stream with their
+// detected language, and the composition of each stream over time
+type AudienceLanguages struct {
+	Languages []LanguageShare           `json:"languages"` // Chatters summed over the streams whose estimate comes from chat
+	Streams   []AudienceLanguagesStream `json:"streams"`   // Oldest first
+}
+
+type AudienceLanguagesStream struct {
+	LivestreamID uint            `json:"livestream_id"`
+	Time         time.Time       `json:"time"`
+	Source       string          `json:"source"`
+	Languages    []LanguageShare `json:"languages"`
+}
+
+// rollupAudienceLanguages sums the language sections of a profile's reports. Chunk reports are left out, their
+// chatters are part of the parent's. Nil when no report has a language section.
+func rollupAudienceLanguages(reports []FullLivestreamReportForProfile) *AudienceLanguages {
+	rollup := AudienceLanguages{Languages: []LanguageShare{}, Streams: []AudienceLanguagesStream{}}
+	chatters := make(map[string]int)
+	for _, report := range reports {
+		if report.ParentReportID != nil || len(report.ChatLanguages) == 0 {
+			continue
+		}
+		var languages ChatLanguages
+		if err := json.Unmarshal(report.ChatLanguages, &languages); err != nil || languages.Source == "" {
+			continue
+		}
+		rollup.Streams = append(rollup.Streams, AudienceLanguagesStream{
+			LivestreamID: uint(report.LivestreamID),
+			Time:         report.ReportStartTime,
+			Source:       languages.Source,
+			Languages:    languages.Languages,
+		})
+		if languages.Source == LanguageSourceChat {
+			for _, share := range languages.Languages {
+				chatters[share.Language] += share.Chatters
+			}
+		}
+	}
+	if len(rollup.Streams) == 0 {
+		return nil
+	}
+
+	sort.Slice(rollup.Streams, func(i, j int) bool { return rollup.Streams[i].Time.Before(rollup.Streams[j].Time) })
+	rollup.Languages = languageShares(chatters)
+	return &rollup
+}
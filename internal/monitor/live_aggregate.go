@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm/clause"
+)
+
+var (
+	LiveAggregateInterval = util.GetEnvDuration("LIVE_AGGREGATE_INTERVAL", time.Minute) // How often the fleet total is added to the history
+	LiveAggregateHistory  = util.GetEnvInt("LIVE_AGGREGATE_HISTORY", 60)                // History points kept in memory
+)
+
+// liveViewerCount is the latest viewer count of a live channel, from an HTTP fetch or a Pusher update
+type liveViewerCount struct {
+	LivestreamID uint
+	Viewers      int
+	UpdatedAt    time.Time
+}
+
+var liveAggregateHistory struct {
+	sync.Mutex
+	points []LiveAggregatePoint
+}
+
+// LiveAggregate is the network-wide viewer count of the live channels monitored. In a cluster every instance shares
+// its total each LiveAggregateInterval, which the others add to their own.
+type LiveAggregate struct {
+	Viewers      int                  `json:"viewers"`
+	LiveChannels int                  `json:"live_channels"`
+	Instances    int                  `json:"instances"` // Whose channels are counted
+	History      []LiveAggregatePoint `json:"history"`   // Oldest first, one point per LiveAggregateInterval
+	GeneratedAt  time.Time            `json:"generated_at"`
+}
+
+type LiveAggregatePoint struct {
+	Time         time.Time `json:"time"`
+	Viewers      int       `json:"viewers"`
+	LiveChannels int       `json:"live_channels"`
+}
+
+// recordLiveViewers stores a live channel's viewer count received at at, and counts it in the stream's live report;
+// setLatestLivestream clears it once the channel is offline
+func recordLiveViewers(channelID, livestreamID uint, viewers int, at time.Time) {
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.viewers = &liveViewerCount{LivestreamID: livestreamID, Viewers: viewers, UpdatedAt: time.Now()}
+	recordLiveViewerSampleLocked(channelID, state, livestreamID, viewers, at)
+}
+
+// currentLiveAggregate sums the viewers of every live channel. Counts not refreshed within a fetch interval plus
+// leeway are left out, the stream may have ended without the fetcher noticing yet.
+func currentLiveAggregate(now time.Time) LiveAggregatePoint {
+	point := LiveAggregatePoint{Time: now}
+	rangeChannelStates(func(_ uint, state *channelState) bool {
+		state.mu.RLock()
+		count := state.viewers
+		state.mu.RUnlock()
+		if count != nil && now.Sub(count.UpdatedAt) <= FetchInterval+LivestreamFreshnessLeeway {
+			point.Viewers += count.Viewers
+			point.LiveChannels++
+		}
+		return true
+	})
+	return point
+}
+
+// GetLiveAggregate returns the current fleet viewer count with its recent history. In a cluster the other instances'
+// totals are as of their last sample; when they can't be read the aggregate covers this instance only.
+func GetLiveAggregate() LiveAggregate {
+	now := time.Now().UTC()
+	current := currentLiveAggregate(now)
+	aggregate := LiveAggregate{Viewers: current.Viewers, LiveChannels: current.LiveChannels, Instances: 1, GeneratedAt: now}
+
+	if Clustered {
+		err := addClusterLiveAggregate(&aggregate, now)
+		if err == nil {
+			return aggregate
+		}
+		log.Printf("Error reading the live aggregate of the cluster, serving this instance's: %v", err)
+	}
+
+	liveAggregateHistory.Lock()
+	aggregate.History = make([]LiveAggregatePoint, len(liveAggregateHistory.points))
+	copy(aggregate.History, liveAggregateHistory.points)
+	liveAggregateHistory.Unlock()
+	return aggregate
+}
+
+// liveAggregateInterval is LiveAggregateInterval, defaulted when unset
+func liveAggregateInterval() time.Duration {
+	if LiveAggregateInterval <= 0 {
+		return time.Minute
+	}
+	return LiveAggregateInterval
+}
+
+// addClusterLiveAggregate adds the latest totals the other instances shared to the aggregate and replaces its
+// history with the cluster's
+func addClusterLiveAggregate(aggregate *LiveAggregate, now time.Time) error {
+	interval := liveAggregateInterval()
+	var others []models.LiveAggregateSample
+	if err := db.DB.Raw(`SELECT DISTINCT ON (instance_id) * FROM live_aggregate_samples
+		WHERE instance_id <> ? AND sampled_at >= ? ORDER BY instance_id, sampled_at DESC`,
+		JobOwner, now.Add(-2*interval)).Scan(&others).Error; err != nil {
+		return fmt.Errorf("failed to fetch the live totals of other instances: %w", err)
+	}
+	history := []LiveAggregatePoint{}
+	if err := db.DB.Model(&models.LiveAggregateSample{}).
+		Select("sampled_at AS time, SUM(viewers) AS viewers, SUM(live_channels) AS live_channels").
+		Where("sampled_at > ?", now.Add(-time.Duration(max(LiveAggregateHistory, 1))*interval)).
+		Group("sampled_at").Order("sampled_at ASC").Scan(&history).Error; err != nil {
+		return fmt.Errorf("failed to fetch the live aggregate history of the cluster: %w", err)
+	}
+	for _, sample := range others {
+		aggregate.Viewers += sample.Viewers
+		aggregate.LiveChannels += sample.LiveChannels
+	}
+	aggregate.Instances += len(others)
+	aggregate.History = history
+	return nil
+}
+
+// shareLiveAggregate stores this instance's total for the other instances of the cluster and drops samples older
+// than the history kept
+func shareLiveAggregate(point LiveAggregatePoint) {
+	interval := liveAggregateInterval()
+	sample := models.LiveAggregateSample{InstanceID: JobOwner, SampledAt: point.Time.Truncate(interval), Viewers: point.Viewers, LiveChannels: point.LiveChannels}
+	if err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}, {Name: "sampled_at"}},
+		DoUpdates: clause.AssignmentColumns([]string{"viewers", "live_channels"}),
+	}).Create(&sample).Error; err != nil {
+		log.Printf("Error sharing the live aggregate of instance %s: %v", JobOwner, err)
+		return
+	}
+	cutoff := sample.SampledAt.Add(-time.Duration(max(LiveAggregateHistory, 1)) * interval)
+	if err := db.DB.Where("sampled_at <= ?", cutoff).Delete(&models.LiveAggregateSample{}).Error; err != nil {
+		log.Printf("Error pruning live aggregate samples: %v", err)
+	}
+}
+
+// RunLiveAggregateSampler adds the fleet viewer count to the history every LiveAggregateInterval until stop is
+// closed, keeping the last LiveAggregateHistory points. In a cluster each point is shared with the other instances.
+func RunLiveAggregateSampler(stop <-chan struct{}) {
+	ticker := time.NewTicker(liveAggregateInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			point := currentLiveAggregate(now.UTC())
+			if Clustered {
+				shareLiveAggregate(point)
+			}
+			liveAggregateHistory.Lock()
+			liveAggregateHistory.points = append(liveAggregateHistory.points, point)
+			if extra := len(liveAggregateHistory.points) - max(LiveAggregateHistory, 1); extra > 0 {
+				liveAggregateHistory.points = liveAggregateHistory.points[extra:]
+			}
+			liveAggregateHistory.Unlock()
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A live report is the running aggregate of the stream a channel is on, updated with every chat message and viewer
+// sample in constant time, so the stream's report so far is available at any moment without reading its chat back.
+// An event is counted live when received from the aggregate's creation on; what was received before, e.g. when the
+// instance restarted mid-stream, is loaded once from the database. Messages sampled out before then are missing.
+// The aggregate lives until the channel's next stream; the generated report supersedes it once the stream ends.
+// In a cluster only the instance monitoring the channel holds it, so it persists a snapshot of the report every
+// LIVE_REPORT_SNAPSHOT_INTERVAL for the other instances to serve.
+
+var LiveReportSnapshotInterval = util.GetEnvDuration("LIVE_REPORT_SNAPSHOT_INTERVAL", 30*time.Second) // How far behind other instances of a cluster serve the live report
+
+const (
+	liveReportChatterPrecision = 14               // 16KiB of HyperLogLog registers per stream, within about 1%
+	liveReportSeedDelay        = 10 * time.Second // Lets queued writes of events received before the aggregate land first
+)
+
+// liveReport is the aggregate of a stream, read and written under its channel state's lock
+type liveReport struct {
+	livestreamID    uint
+	since           time.Time // Events received from then on are counted live, earlier ones by the seed
+	seeded          bool
+	updatedAt       time.Time
+	messages        int
+	flaggedMessages int
+	chatters        *util.HyperLogLog
+	messageBlocks   map[time.Time]int // Per MessageTimelineBlock of send time, in UTC
+	firstMessageAt  time.Time
+	lastMessageAt   time.Time
+
+	samples       int
+	viewerSum     int64
+	peakViewers   int
+	lowestViewers int
+	viewerBlocks  map[time.Time]int // Last sample per ReportTimeBlock, in UTC
+	firstSample   *viewerSample
+	lastSample    *viewerSample
+	viewerSeconds float64       // Of the samples before lastSample, see calculateACV
+	tracked       time.Duration // Time those samples held
+}
+
+type viewerSample struct {
+	Viewers int
+	At      time.Time
+}
+
+func newLiveReport(livestreamID uint, since time.Time) *liveReport {
+	return &liveReport{
+		livestreamID:  livestreamID,
+		since:         since,
+		chatters:      util.NewHyperLogLog(liveReportChatterPrecision),
+		messageBlocks: make(map[time.Time]int),
+		viewerBlocks:  make(map[time.Time]int),
+	}
+}
+
+func (r *liveReport) addMessage(senderUsername string, sentAt time.Time, flagged bool) {
+	r.messages++
+	if flagged {
+		r.flaggedMessages++
+	}
+	r.chatters.Add(senderUsername)
+	r.messageBlocks[sentAt.UTC().Truncate(MessageTimelineBlock)]++
+	if r.firstMessageAt.IsZero() || sentAt.Before(r.firstMessageAt) {
+		r.firstMessageAt = sentAt
+	}
+	if sentAt.After(r.lastMessageAt) {
+		r.lastMessageAt = sentAt
+	}
+}
+
+// addViewers counts a viewer sample, the previous one holding until it like in calculateACV
+func (r *liveReport) addViewers(viewers int, at time.Time) {
+	if r.lastSample != nil {
+		held := min(max(at.Sub(r.lastSample.At), 0), MaxSampleGap)
+		r.viewerSeconds += float64(r.lastSample.Viewers) * held.Seconds()
+		r.tracked += held
+	}
+	sample := &viewerSample{Viewers: viewers, At: at}
+	if r.firstSample == nil {
+		r.firstSample = sample
+	}
+	r.lastSample = sample
+	r.samples++
+	r.viewerSum += int64(viewers)
+	r.peakViewers = max(r.peakViewers, viewers)
+	if r.samples == 1 || viewers < r.lowestViewers {
+		r.lowestViewers = viewers
+	}
+	r.viewerBlocks[at.UTC().Truncate(ReportTimeBlock)] = viewers
+}
+
+// merge adds the seed, which holds the events received before r.since
+func (r *liveReport) merge(seed *liveReport) {
+	r.messages += seed.messages
+	r.flaggedMessages += seed.flaggedMessages
+	r.chatters.Merge(seed.chatters)
+	for block, count := range seed.messageBlocks {
+		r.messageBlocks[block] += count
+	}
+	if !seed.firstMessageAt.IsZero() && (r.firstMessageAt.IsZero() || seed.firstMessageAt.Before(r.firstMessageAt)) {
+		r.firstMessageAt = seed.firstMessageAt
+	}
+	if seed.lastMessageAt.After(r.lastMessageAt) {
+		r.lastMessageAt = seed.lastMessageAt
+	}
+
+	if seed.samples == 0 {
+		return
+	}
+	if r.firstSample == nil {
+		r.firstSample, r.lastSample = seed.firstSample, seed.lastSample
+	} else {
+		// The seed's last sample holds until the first live one
+		held := min(max(r.firstSample.At.Sub(seed.lastSample.At), 0), MaxSampleGap)
+		r.viewerSeconds += float64(seed.lastSample.Viewers) * held.Seconds()
+		r.tracked += held
+		r.firstSample = seed.firstSample
+	}
+	r.viewerSeconds += seed.viewerSeconds
+	r.tracked += seed.tracked
+	if r.samples == 0 || seed.lowestViewers < r.lowestViewers {
+		r.lowestViewers = seed.lowestViewers
+	}
+	r.samples += seed.samples
+	r.viewerSum += seed.viewerSum
+	r.peakViewers = max(r.peakViewers, seed.peakViewers)
+	for block, viewers := range seed.viewerBlocks {
+		if _, ok := r.viewerBlocks[block]; !ok { // A live sample of the block is later
+			r.viewerBlocks[block] = viewers
+		}
+	}
+}
+
+// liveReportForLocked returns the aggregate of the channel's stream, starting one, and its seed, when the channel
+// switched streams. Called with state.mu held.
+func liveReportForLocked(channelID uint, state *channelState, livestreamID uint) *liveReport {
+	if state.live == nil || state.live.livestreamID != livestreamID {
+		state.live = newLiveReport(livestreamID, time.Now())
+		go seedLiveReport(channelID, livestreamID, state.live.since)
+	}
+	return state.live
+}
+
+// recordLiveMessage counts a chat message of a stream in its live report, whether persisted or sampled out
+func recordLiveMessage(channelID uint, msg *models.ChatMessage) {
+	if msg.LivestreamID == nil {
+		return
+	}
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	report := liveReportForLocked(channelID, state, *msg.LivestreamID)
+	if msg.CreatedAt.Before(report.since) {
+		return // Received before the aggregate, the seed has it
+	}
+	sentAt := msg.MessageSendTime
+	if sentAt.IsZero() {
+		sentAt = msg.CreatedAt
+	}
+	report.addMessage(msg.SenderUsername, sentAt, msg.Flagged)
+	report.updatedAt = time.Now()
+}
+
+// recordLiveViewerSampleLocked counts a viewer sample received at at. Called with state.mu held.
+func recordLiveViewerSampleLocked(channelID uint, state *channelState, livestreamID uint, viewers int, at time.Time) {
+	report := liveReportForLocked(channelID, state, livestreamID)
+	if at.Before(report.since) {
+		return
+	}
+	report.addViewers(viewers, at)
+	report.updatedAt = time.Now()
+}
+
+// seedLiveReport loads the chat and viewer samples of a stream received before since into its live report
+func seedLiveReport(channelID, livestreamID uint, since time.Time) {
+	time.Sleep(liveReportSeedDelay)
+	seed := newLiveReport(livestreamID, since)
+
+	var blocks []struct {
+		Block   time.Time
+		Count   int
+		Flagged int
+	}
+	blockSeconds := MessageTimelineBlock.Seconds()
+	if err := db.DB.Raw(`SELECT to_timestamp(floor(extract(epoch FROM message_send_time) / ?) * ?) AS block,
+			COUNT(*) AS count, COUNT(*) FILTER (WHERE flagged) AS flagged
+		FROM chat_messages WHERE livestream_id = ? AND created_at < ? GROUP BY block`,
+		blockSeconds, blockSeconds, livestreamID, since).Scan(&blocks).Error; err != nil {
+		log.Printf("Error seeding live report of livestream %d: %v", livestreamID, err)
+		return
+	}
+	var chatters []string
+	if err := db.DB.Model(&models.ChatMessage{}).Distinct("sender_username").
+		Where("livestream_id = ? AND created_at < ?", livestreamID, since).Pluck("sender_username", &chatters).Error; err != nil {
+		log.Printf("Error seeding live report chatters of livestream %d: %v", livestreamID, err)
+		return
+	}
+	var samples []models.LivestreamData
+	if err := db.DB.Select("viewer_count", "created_at").Where("livestream_id = ? AND created_at < ?", livestreamID, since).
+		Order("created_at ASC").Find(&samples).Error; err != nil {
+		log.Printf("Error seeding live report viewers of livestream %d: %v", livestreamID, err)
+		return
+	}
+
+	for _, block := range blocks {
+		seed.messages += block.Count
+		seed.flaggedMessages += block.Flagged
+		seed.messageBlocks[block.Block.UTC()] += block.Count
+		if seed.firstMessageAt.IsZero() || block.Block.Before(seed.firstMessageAt) {
+			seed.firstMessageAt = block.Block
+		}
+		if blockEnd := block.Block.Add(MessageTimelineBlock); blockEnd.After(seed.lastMessageAt) {
+			seed.lastMessageAt = blockEnd
+		}
+	}
+	for _, chatter := range chatters {
+		seed.chatters.Add(chatter)
+	}
+	for _, sample := range samples {
+		seed.addViewers(sample.ViewerCount, sample.CreatedAt)
+	}
+
+	state := loadChannelState(channelID)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.live == nil || state.live.livestreamID != livestreamID || state.live.seeded {
+		return // The channel moved on to another stream
+	}
+	state.live.merge(seed)
+	state.live.seeded = true
+	state.live.updatedAt = time.Now()
+}
+
+// LiveReport is the report so far of the stream a channel is on, from its live aggregate
+type LiveReport struct {
+	ChannelID                uint                `json:"channel_id"`
+	LivestreamID             uint                `json:"livestream_id"`
+	Title                    string              `json:"title"`
+	Live                     bool                `json:"live"` // The last fetch saw the stream live; false once it ended
+	StartedAt                *time.Time          `json:"started_at,omitempty"`
+	DurationSeconds          int64               `json:"duration_seconds"`
+	UpdatedAt                time.Time           `json:"updated_at"`
+	CountedSince             time.Time           `json:"counted_since"` // Live from then on, earlier events loaded from the database
+	Seeded                   bool                `json:"seeded"`        // The earlier events were loaded
+	TotalMessages            int                 `json:"total_messages"`
+	FlaggedMessages          int                 `json:"flagged_messages"`
+	UniqueChatters           int                 `json:"unique_chatters"` // Estimated, within about 1%
+	MessagesPerMinute        float64             `json:"messages_per_minute"`
+	AverageViewers           int                 `json:"average_viewers"`
+	AverageConcurrentViewers float64             `json:"average_concurrent_viewers"`
+	PeakViewers              int                 `json:"peak_viewers"`
+	LowestViewers            int                 `json:"lowest_viewers"`
+	HoursWatched             float64             `json:"hours_watched"`
+	ChattersPerAverage       float64             `json:"chatters_per_average_viewers"` // Engagement formulas that need no message content
+	ChattersPerPeak          float64             `json:"chatters_per_peak_viewers"`
+	MessagesPerViewerHour    float64             `json:"messages_per_viewer_hour"`
+	MessageCountsTimeline    []MessageCountPoint `json:"message_counts_timeline"`
+	ViewerCountsTimeline     []ViewerCountPoint  `json:"viewer_counts_timeline"`
+}
+
+// GetLiveReport returns the live report of the channel's current or last stream seen by this instance
+func GetLiveReport(channelID uint) (LiveReport, bool) {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return LiveReport{}, false
+	}
+	now := time.Now()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	r := state.live
+	if r == nil {
+		return LiveReport{}, false
+	}
+
+	report := LiveReport{
+		ChannelID:       channelID,
+		LivestreamID:    r.livestreamID,
+		UpdatedAt:       r.updatedAt,
+		CountedSince:    r.since,
+		Seeded:          r.seeded,
+		TotalMessages:   r.messages,
+		FlaggedMessages: r.flaggedMessages,
+		UniqueChatters:  int(r.chatters.Count()),
+		PeakViewers:     r.peakViewers,
+		LowestViewers:   r.lowestViewers,
+	}
+	if state.snapshot != nil && state.snapshot.LivestreamID == r.livestreamID {
+		report.Title, report.Live = state.snapshot.SessionTitle, true
+	}
+
+	// The stream runs from its start, as the fetcher saw it, until now or its last sign of life
+	start, end := r.firstMessageAt, r.lastMessageAt
+	if r.firstSample != nil && (start.IsZero() || r.firstSample.At.Before(start)) {
+		start = r.firstSample.At
+	}
+	if r.lastSample != nil && r.lastSample.At.After(end) {
+		end = r.lastSample.At
+	}
+	if window := state.window; window != nil && window.LivestreamID == r.livestreamID {
+		if !window.Start.IsZero() {
+			start = window.Start
+		}
+		if window.LastSeen.After(end) {
+			end = window.LastSeen
+		}
+	}
+	if report.Live {
+		end = now
+	}
+	if !start.IsZero() {
+		report.StartedAt = &start
+		report.DurationSeconds = int64(max(end.Sub(start), 0).Seconds())
+	}
+	duration := time.Duration(report.DurationSeconds) * time.Second
+
+	if r.samples > 0 {
+		report.AverageViewers = int(r.viewerSum / int64(r.samples))
+		viewerSeconds, tracked := r.viewerSeconds, r.tracked
+		held := min(max(end.Sub(r.lastSample.At), 0), MaxSampleGap)
+		viewerSeconds += float64(r.lastSample.Viewers) * held.Seconds()
+		tracked += held
+		if tracked > 0 {
+			report.AverageConcurrentViewers = viewerSeconds / tracked.Seconds()
+		} else {
+			report.AverageConcurrentViewers = float64(report.AverageViewers)
+		}
+	}
+	report.HoursWatched = report.AverageConcurrentViewers * duration.Hours()
+	if duration > 0 {
+		report.MessagesPerMinute = float64(r.messages) / duration.Minutes()
+	}
+	if report.AverageConcurrentViewers > 0 {
+		report.ChattersPerAverage = float64(report.UniqueChatters) / report.AverageConcurrentViewers * 100.0
+	}
+	if report.PeakViewers > 0 {
+		report.ChattersPerPeak = float64(report.UniqueChatters) / float64(report.PeakViewers) * 100.0
+	}
+	if report.HoursWatched > 0 {
+		report.MessagesPerViewerHour = float64(r.messages) / report.HoursWatched
+	}
+
+	report.MessageCountsTimeline = []MessageCountPoint{}
+	report.ViewerCountsTimeline = []ViewerCountPoint{}
+	if start.IsZero() {
+		return report, true
+	}
+	for block := start.UTC().Truncate(MessageTimelineBlock); block.Before(end); block = block.Add(MessageTimelineBlock) {
+		report.MessageCountsTimeline = append(report.MessageCountsTimeline, MessageCountPoint{Time: block, Count: r.messageBlocks[block]})
+	}
+	if r.samples > 0 {
+		last := 0 // Blocks without a sample carry the previous count, like buildViewerCountTimeline
+		for block := start.UTC().Truncate(ReportTimeBlock); block.Before(end); block = block.Add(ReportTimeBlock) {
+			if viewers, ok := r.viewerBlocks[block]; ok {
+				last = viewers
+			}
+			report.ViewerCountsTimeline = append(report.ViewerCountsTimeline, ViewerCountPoint{Time: block, Count: last})
+		}
+	}
+	return report, true
+}
+
+// RunLiveReportSnapshots persists the live reports updated since their last snapshot every
+// LiveReportSnapshotInterval until stop is closed. Only cluster instances run it.
+func RunLiveReportSnapshots(stop <-chan struct{}) {
+	interval := LiveReportSnapshotInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	persisted := make(map[uint]time.Time) // UpdatedAt of the last snapshot per channel
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			var channelIDs []uint
+			rangeChannelStates(func(channelID uint, _ *channelState) bool {
+				channelIDs = append(channelIDs, channelID)
+				return true
+			})
+			current := make(map[uint]time.Time, len(channelIDs))
+			for _, channelID := range channelIDs {
+				report, ok := GetLiveReport(channelID)
+				if !ok {
+					continue
+				}
+				if last, ok := persisted[channelID]; ok && last.Equal(report.UpdatedAt) && !report.Live {
+					current[channelID] = last
+					continue // Ended and unchanged since
+				}
+				if err := persistLiveReportSnapshot(report); err != nil {
+					log.Printf("Error persisting live report of channel %d: %v", channelID, err)
+					continue
+				}
+				current[channelID] = report.UpdatedAt
+			}
+			persisted = current
+		}
+	}
+}
+
+func persistLiveReportSnapshot(report LiveReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode live report: %w", err)
+	}
+	snapshot := models.LiveReportSnapshot{ChannelID: report.ChannelID, LivestreamID: report.LivestreamID, Report: body, UpdatedAt: report.UpdatedAt}
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"livestream_id", "report", "updated_at"}),
+	}).Create(&snapshot).Error
+}
+
+// GetLiveReportSnapshot returns the live report of the channel as last persisted by the instance monitoring it, for
+// instances that don't hold its aggregate
+func GetLiveReportSnapshot(channelID uint) (LiveReport, bool, error) {
+	var snapshot models.LiveReportSnapshot
+	if err := db.DB.Where("channel_id = ?", channelID).First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return LiveReport{}, false, nil
+		}
+		return LiveReport{}, false, fmt.Errorf("failed to fetch live report of channel %d: %w", channelID, err)
+	}
+	var report LiveReport
+	if err := json.Unmarshal(snapshot.Report, &report); err != nil {
+		return LiveReport{}, false, fmt.Errorf("failed to decode live report of channel %d: %w", channelID, err)
+	}
+	return report, true, nil
+}
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	markdownTopSpammers  = 5
+	markdownHypeMoments  = 5
+	markdownExampleChars = 80
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`, "<", `\<`, ">", `\>`, "\n", " ", "\r", " ",
+)
+
+// RenderReportsMarkdown renders reports as Markdown for pasting into Discord, Notion or GitHub
+func RenderReportsMarkdown(reports []FullLivestreamReportForProfile) string {
+	if len(reports) == 0 {
+		return "_No reports found._\n"
+	}
+	sections := make([]string, 0, len(reports))
+	for _, report := range reports {
+		sections = append(sections, RenderReportMarkdown(report))
+	}
+	return strings.Join(sections, "\n---\n\n")
+}
+
+// RenderReportMarkdown renders a report as a Markdown summary: headline metrics, top spammers and hype moments
+func RenderReportMarkdown(report FullLivestreamReportForProfile) string {
+	var b strings.Builder
+
+	title := report.Title
+	if title == "" {
+		title = fmt.Sprintf("Livestream %d", report.LivestreamID)
+	}
+	fmt.Fprintf(&b, "## %s\n\n", escapeMarkdown(title))
+	fmt.Fprintf(&b, "%s – %s UTC", report.ReportStartTime.UTC().Format("2006-01-02 15:04"), report.ReportEndTime.UTC().Format("15:04"))
+	if report.Category != "" {
+		fmt.Fprintf(&b, " · %s", escapeMarkdown(report.Category))
+	}
+	if report.ChunkCount > 0 {
+		fmt.Fprintf(&b, " · part %d of %d", report.ChunkIndex+1, report.ChunkCount)
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("| Metric | Value |\n|---|---:|\n")
+	rows := [][2]string{
+		{"Duration", formatMinutes(report.DurationMinutes)},
+		{"Average viewers", formatInt(report.AverageViewers)},
+		{"Peak viewers", formatInt(report.PeakViewers)},
+		{"Hours watched", formatInt(int(report.HoursWatched + 0.5))},
+		{"Followers gained", fmt.Sprintf("%+d", report.FollowersGained)},
+		{"Messages", formatInt(report.TotalMessages)},
+		{"Unique chatters", formatInt(report.UniqueChatters)},
+		{"Engagement", fmt.Sprintf("%.2f", report.Engagement)},
+		{"Duplicate messages", formatInt(report.SpamReport.DuplicateMessagesCount)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], row[1])
+	}
+
+	var notes []string
+	if report.Sampled {
+		notes = append(notes, fmt.Sprintf("chat was sampled at 1/%g; message counts are estimated", report.SampleRate))
+	}
+	if report.Stale {
+		notes = append(notes, fmt.Sprintf("%d messages arrived after the report was built", report.StaleMessages))
+	}
+	if report.ExcludedChatters > 0 {
+		notes = append(notes, fmt.Sprintf("%d messages of %d excluded chatter(s) are left out", report.ExcludedMessages, report.ExcludedChatters))
+	}
+	if report.WindowStart != nil || report.WindowEnd != nil {
+		notes = append(notes, fmt.Sprintf("only %s to %s of the stream is analysed",
+			report.ReportStartTime.UTC().Format("2006-01-02 15:04"), report.ReportEndTime.UTC().Format("2006-01-02 15:04 UTC")))
+	}
+	if report.IngestionLagged {
+		notes = append(notes, fmt.Sprintf("chat was ingested with lag or clock skew (p50 %.1fs, p95 %.1fs); timelines may be shifted", report.IngestionLagP50, report.IngestionLagP95))
+	}
+	if report.ViewerBotSuspected {
+		var viewerBots ViewerBotAnalysis
+		if json.Unmarshal(report.ViewerBotAnalysis, &viewerBots) == nil && viewerBots.ViewerChatCorrelation != nil {
+			notes = append(notes, fmt.Sprintf("viewers barely follow the chat rate (correlation %.2f), a sign of view-botting", *viewerBots.ViewerChatCorrelation))
+		}
+	}
+	for _, note := range notes {
+		fmt.Fprintf(&b, "\n> Note: %s\n", note)
+	}
+
+	if spammers := topSpammers(report.SpamReport.SuspiciousChatters); len(spammers) > 0 {
+		b.WriteString("\n### Top spammers\n\n| # | Chatter | Score | Issues | Example |\n|---:|---|---:|---|---|\n")
+		for _, chatter := range spammers {
+			example := ""
+			if len(chatter.ExampleMessages) > 0 {
+				example = truncateRunes(chatter.ExampleMessages[0], markdownExampleChars)
+			}
+			fmt.Fprintf(&b, "| %d | %s | %.1f | %s | %s |\n", chatter.Rank, escapeMarkdown(chatter.Username), chatter.Score,
+				escapeMarkdown(strings.Join(chatter.PotentialIssues, ", ")), escapeMarkdown(example))
+		}
+	}
+
+	if moments, blocks := hypeMoments(report.MessageCountsTimeline); len(moments) > 0 {
+		b.WriteString("\n### Hype moments\n\n| Time (UTC) | Messages | vs. average |\n|---|---:|---:|\n")
+		average := float64(report.TotalMessages) / float64(blocks)
+		for _, moment := range moments {
+			ratio := "–"
+			if average > 0 {
+				ratio = fmt.Sprintf("%.1fx", float64(moment.Count)/average)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", moment.Time.UTC().Format("15:04"), formatInt(moment.Count), ratio)
+		}
+	}
+
+	if report.VodURL != "" {
+		fmt.Fprintf(&b, "\nVOD: <%s>\n", report.VodURL)
+	}
+	return b.String()
+}
+
+// topSpammers returns the most suspicious chatters of a report, by rank
+func topSpammers(raw json.RawMessage) []SuspiciousChatterReport {
+	var chatters []SuspiciousChatterReport
+	if len(raw) == 0 || json.Unmarshal(raw, &chatters) != nil {
+		return nil
+	}
+	sort.SliceStable(chatters, func(i, j int) bool { return chatters[i].Score > chatters[j].Score })
+	if len(chatters) > markdownTopSpammers {
+		chatters = chatters[:markdownTopSpammers]
+	}
+	for i := range chatters {
+		if chatters[i].Rank == 0 {
+			chatters[i].Rank = i + 1
+		}
+	}
+	return chatters
+}
+
+// hypeMoments returns the busiest message timeline blocks, in time order, and the number of blocks
+func hypeMoments(raw json.RawMessage) ([]MessageCountPoint, int) {
+	var timeline []MessageCountPoint
+	if len(raw) == 0 || json.Unmarshal(raw, &timeline) != nil {
+		return nil, 0
+	}
+	busiest := make([]MessageCountPoint, 0, len(timeline))
+	for _, point := range timeline {
+		if point.Count > 0 {
+			busiest = append(busiest, point)
+		}
+	}
+	sort.SliceStable(busiest, func(i, j int) bool { return busiest[i].Count > busiest[j].Count })
+	if len(busiest) > markdownHypeMoments {
+		busiest = busiest[:markdownHypeMoments]
+	}
+	sort.Slice(busiest, func(i, j int) bool { return busiest[i].Time.Before(busiest[j].Time) })
+	return busiest, len(timeline)
+}
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func formatMinutes(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// formatInt formats n with thousands separators
+func formatInt(n int) string {
+	s := fmt.Sprintf("%d", n)
+	if n < 0 {
+		return "-" + formatInt(-n)
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MobileSparklinePoints = 20 // Points of every sparkline, timelines are binned down to this many
+	MobileRecentStreams   = 10 // Streams listed on a mobile profile
+)
+
+// MobileProfile is the trimmed profile served to mobile clients: no timelines, sparklines binned server-side
+// and numbers rounded to what a phone screen shows
+type MobileProfile struct {
+	ChannelID          uint                  `json:"channel_id"`
+	Username           string                `json:"username"`
+	ProfilePic         string                `json:"profile_pic,omitempty"`
+	Verified           bool                  `json:"verified"`
+	IsLive             bool                  `json:"is_live"`
+	LiveViewers        int                   `json:"live_viewers,omitempty"`
+	Followers          int                   `json:"followers"`
+	FollowersSparkline []int                 `json:"followers_sparkline"`
+	ViewersSparkline   []int                 `json:"viewers_sparkline"` // Average viewers of the recent streams, oldest first
+	RecentStreams      []MobileStreamSummary `json:"recent_streams"`    // Newest first
+	GeneratedAt        time.Time             `json:"generated_at"`
+}
+
+// MobileStreamSummary is one stream in a mobile profile's list
+type MobileStreamSummary struct {
+	ReportID        uuid.UUID `json:"report_id"`
+	LivestreamID    uint      `json:"livestream_id"`
+	Title           string    `json:"title"`
+	Category        string    `json:"category,omitempty"`
+	StartTime       time.Time `json:"start_time"`
+	DurationMinutes int       `json:"duration_minutes"`
+	AverageViewers  int       `json:"average_viewers"`
+	PeakViewers     int       `json:"peak_viewers"`
+	HoursWatched    float64   `json:"hours_watched"`
+}
+
+// MobileReport is the trimmed livestream report served to mobile clients
+type MobileReport struct {
+	ReportID          uuid.UUID `json:"report_id"`
+	LivestreamID      uint      `json:"livestream_id"`
+	ChannelID         uint      `json:"channel_id"`
+	Title             string    `json:"title"`
+	Category          string    `json:"category,omitempty"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	DurationMinutes   int       `json:"duration_minutes"`
+	AverageViewers    int       `json:"average_viewers"`
+	PeakViewers       int       `json:"peak_viewers"`
+	HoursWatched      float64   `json:"hours_watched"`
+	Engagement        float64   `json:"engagement"`
+	TotalMessages     int       `json:"total_messages"`
+	UniqueChatters    int       `json:"unique_chatters"`
+	FollowersGained   int       `json:"followers_gained"`
+	ViewersSparkline  []int     `json:"viewers_sparkline"`
+	MessagesSparkline []int     `json:"messages_sparkline"`
+	SpamScore         *int      `json:"spam_score,omitempty"` // 0-100, nil without a spam report
+	SpamBadge         string    `json:"spam_badge"`
+	GeneratedAt       time.Time `json:"generated_at"`
+}
+
+// BuildMobileProfile returns the mobile profile of a streamer; gorm.ErrRecordNotFound when there is no profile
+func BuildMobileProfile(username string) (MobileProfile, error) {
+	var profile models.StreamerProfile
+	if err := db.DB.Select("channel_id", "username", "verified", "profile_pic", "followers_count").
+		Where("username = ?", ResolveUsername(username)).First(&profile).Error; err != nil {
+		return MobileProfile{}, err
+	}
+
+	mobile := MobileProfile{
+		ChannelID:     profile.ChannelID,
+		Username:      profile.Username,
+		ProfilePic:    profile.ProfilePic,
+		Verified:      profile.Verified,
+		RecentStreams: []MobileStreamSummary{},
+		GeneratedAt:   time.Now().UTC(),
+	}
+
+	var followers []models.FollowersCountPoint
+	if len(profile.FollowersCount) > 0 && json.Unmarshal(profile.FollowersCount, &followers) == nil && len(followers) > 0 {
+		mobile.Followers = followers[len(followers)-1].Count
+	}
+	counts := make([]float64, len(followers))
+	for i, point := range followers {
+		counts[i] = float64(point.Count)
+	}
+	mobile.FollowersSparkline = sparkline(counts)
+
+	var latest models.LivestreamData
+	err := db.DB.Where("channel_id = ?", profile.ChannelID).Order("created_at DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return mobile, fmt.Errorf("failed to load livestream status of %s: %w", username, err)
+	}
+	if err == nil && latest.IsLive && time.Since(latest.CreatedAt) <= FetchInterval+LivestreamFreshnessLeeway {
+		mobile.IsLive = true
+		mobile.LiveViewers = latest.ViewerCount
+	}
+
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("id", "livestream_id", "title", "category", "report_start_time", "duration_minutes", "average_viewers", "peak_viewers", "hours_watched").
+		Where("channel_id = ? AND parent_report_id IS NULL", profile.ChannelID).
+		Order("report_start_time DESC").Limit(MobileSparklinePoints).Find(&reports).Error; err != nil {
+		return mobile, fmt.Errorf("failed to load reports of %s: %w", username, err)
+	}
+	viewers := make([]float64, len(reports))
+	for i, report := range reports {
+		viewers[len(reports)-1-i] = float64(report.AverageViewers)
+		if i < MobileRecentStreams {
+			mobile.RecentStreams = append(mobile.RecentStreams, MobileStreamSummary{
+				ReportID:        report.ID,
+				LivestreamID:    report.LivestreamID,
+				Title:           report.Title,
+				Category:        report.Category,
+				StartTime:       report.ReportStartTime,
+				DurationMinutes: report.DurationMinutes,
+				AverageViewers:  report.AverageViewers,
+				PeakViewers:     report.PeakViewers,
+				HoursWatched:    roundTo(report.HoursWatched, 1),
+			})
+		}
+	}
+	mobile.ViewersSparkline = sparkline(viewers)
+	return mobile, nil
+}
+
+// BuildMobileReport returns the mobile view of a livestream's latest report; gorm.ErrRecordNotFound when it has none
+func BuildMobileReport(livestreamID uint) (MobileReport, error) {
+	var report models.LivestreamReport
+	if err := db.DB.Where("livestream_id = ? AND parent_report_id IS NULL", livestreamID).
+		Order("report_start_time DESC, created_at DESC").First(&report).Error; err != nil {
+		return MobileReport{}, err
+	}
+
+	mobile := MobileReport{
+		ReportID:        report.ID,
+		LivestreamID:    report.LivestreamID,
+		ChannelID:       report.ChannelID,
+		Title:           report.Title,
+		Category:        report.Category,
+		StartTime:       report.ReportStartTime,
+		EndTime:         report.ReportEndTime,
+		DurationMinutes: report.DurationMinutes,
+		AverageViewers:  report.AverageViewers,
+		PeakViewers:     report.PeakViewers,
+		HoursWatched:    roundTo(report.HoursWatched, 1),
+		Engagement:      roundTo(report.Engagement, 2),
+		TotalMessages:   report.TotalMessages,
+		UniqueChatters:  report.UniqueChatters,
+		FollowersGained: report.FollowersGained,
+		SpamBadge:       SpamBadgeUnknown,
+		GeneratedAt:     time.Now().UTC(),
+	}
+
+	// Chunked reports keep their timelines on the chunks
+	timelines := []models.LivestreamReport{report}
+	if report.ChunkCount > 0 {
+		timelines = nil
+		if err := db.DB.Select("viewer_counts_timeline", "message_counts_timeline").
+			Where("parent_report_id = ?", report.ID).Order("chunk_index ASC").Find(&timelines).Error; err != nil {
+			return mobile, fmt.Errorf("failed to load chunks of report %s: %w", report.ID, err)
+		}
+	}
+	var viewers, messages []float64
+	for _, part := range timelines {
+		var viewerPoints []ViewerCountPoint
+		if json.Unmarshal(part.ViewerCountsTimeline, &viewerPoints) == nil {
+			for _, point := range viewerPoints {
+				viewers = append(viewers, float64(point.Count))
+			}
+		}
+		var messagePoints []MessageCountPoint
+		if json.Unmarshal(part.MessageCountsTimeline, &messagePoints) == nil {
+			for _, point := range messagePoints {
+				messages = append(messages, float64(point.Count))
+			}
+		}
+	}
+	mobile.ViewersSparkline = sparkline(viewers)
+	mobile.MessagesSparkline = sparkline(messages)
+
+	if report.SpamReportID != nil {
+		var spamReport models.SpamReport
+		if err := db.DB.Select("duplicate_messages_count", "suspicious_chatters").Where("id = ?", *report.SpamReportID).First(&spamReport).Error; err == nil {
+			score := spamScore(report, spamReport)
+			mobile.SpamScore = &score
+			mobile.SpamBadge = spamBadge(score)
+		}
+	}
+	return mobile, nil
+}
+
+// sparkline bins a series into MobileSparklinePoints consecutive buckets and rounds each bucket's mean. Shorter
+// series are only rounded.
+func sparkline(values []float64) []int {
+	points := min(len(values), MobileSparklinePoints)
+	line := make([]int, points)
+	for i := range line {
+		from, to := i*len(values)/points, (i+1)*len(values)/points
+		sum := 0.0
+		for _, v := range values[from:to] {
+			sum += v
+		}
+		line[i] = int(math.Round(sum / float64(to-from)))
+	}
+	return line
+}
+
+func roundTo(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
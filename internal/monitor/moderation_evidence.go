@@ -0,0 +1,308 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	evidenceMaxMessages   = 5000             // Messages of the chatter listed in a bundle, the latest ones
+	evidenceWindowPadding = 30 * time.Second // Chat shown before and after an incident
+	evidenceWindowRows    = 60               // Rows of a chat window, around the chatter's messages
+)
+
+// ModerationEvidence is everything recorded about a chatter in a channel, assembled for a moderation appeal or
+// report to Kick: their messages, the spam findings about them and the chat around each finding
+type ModerationEvidence struct {
+	Channel     string           `json:"channel"`
+	ChannelID   uint             `json:"channel_id"`
+	Username    string           `json:"username"`
+	SenderID    *int             `json:"sender_id,omitempty"`
+	From        *time.Time       `json:"from,omitempty"`
+	To          *time.Time       `json:"to,omitempty"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Summary     EvidenceSummary  `json:"summary"`
+	Flag        *EvidenceFlag    `json:"flag,omitempty"`      // Set when a moderator flagged the chatter
+	BotScore    *AccountBotScore `json:"bot_score,omitempty"` // Bot probability from their behaviour across streams
+	Incidents   []SpamIncident   `json:"incidents"`
+	Windows     []EvidenceWindow `json:"windows"` // Chat around each incident, one per incident
+	Messages    []EvidenceLine   `json:"messages"`
+}
+
+type EvidenceSummary struct {
+	Messages      int        `json:"messages"`
+	Listed        int        `json:"listed"` // Messages in the bundle, up to evidenceMaxMessages
+	Streams       int        `json:"streams"`
+	Incidents     int        `json:"incidents"`
+	FlaggedAtSend int        `json:"flagged_at_send"` // Messages sent while the chatter was flagged
+	FirstMessage  *time.Time `json:"first_message,omitempty"`
+	LastMessage   *time.Time `json:"last_message,omitempty"`
+}
+
+type EvidenceFlag struct {
+	Reason    string    `json:"reason"`
+	FlaggedBy string    `json:"flagged_by"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// EvidenceLine is a chat message as it appears in the bundle
+type EvidenceLine struct {
+	MessageID    string    `json:"message_id"`
+	Time         time.Time `json:"time"`
+	LivestreamID *uint     `json:"livestream_id,omitempty"`
+	Username     string    `json:"username"`
+	Message      string    `json:"message"`
+	Subject      bool      `json:"subject,omitempty"` // Sent by the chatter the evidence is about
+}
+
+// EvidenceWindow is the chat of a channel around one incident, the textual stand-in for a screenshot
+type EvidenceWindow struct {
+	IncidentID string         `json:"incident_id"`
+	Type       string         `json:"type"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Truncated  bool           `json:"truncated"` // More chat than evidenceWindowRows in the window
+	Lines      []EvidenceLine `json:"lines"`
+}
+
+// BuildModerationEvidence assembles the evidence about username in channel between from and to, either of which
+// may be zero. Archived chat is included.
+func BuildModerationEvidence(channel models.MonitoredChannel, username string, from, to time.Time) (*ModerationEvidence, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	evidence := &ModerationEvidence{
+		Channel:     channel.Username,
+		ChannelID:   channel.ChannelID,
+		Username:    username,
+		GeneratedAt: time.Now().UTC(),
+		Incidents:   []SpamIncident{},
+		Windows:     []EvidenceWindow{},
+		Messages:    []EvidenceLine{},
+	}
+	if !from.IsZero() {
+		evidence.From = &from
+	}
+	if !to.IsZero() {
+		evidence.To = &to
+	}
+
+	messages, err := chatterMessages(channel.ChatroomID, username, from, to)
+	if err != nil {
+		return nil, err
+	}
+	streams := make(map[uint]struct{})
+	for _, msg := range messages {
+		if msg.LivestreamID != nil {
+			streams[*msg.LivestreamID] = struct{}{}
+		}
+		if msg.Flagged {
+			evidence.Summary.FlaggedAtSend++
+		}
+	}
+	evidence.Summary.Messages = len(messages)
+	evidence.Summary.Streams = len(streams)
+	if len(messages) > 0 {
+		first, last := messages[0].MessageSendTime, messages[len(messages)-1].MessageSendTime
+		evidence.Summary.FirstMessage, evidence.Summary.LastMessage = &first, &last
+		senderID := messages[len(messages)-1].SenderID
+		evidence.SenderID = &senderID
+	}
+	if len(messages) > evidenceMaxMessages {
+		messages = messages[len(messages)-evidenceMaxMessages:]
+	}
+	for _, msg := range messages {
+		evidence.Messages = append(evidence.Messages, evidenceLine(msg, username))
+	}
+	evidence.Summary.Listed = len(evidence.Messages)
+
+	page, err := SearchSpamIncidents(SpamIncidentQuery{Username: username, ChannelID: channel.ChannelID, From: from, To: to, Limit: 500})
+	if err != nil {
+		return nil, err
+	}
+	evidence.Incidents = page.Incidents
+	evidence.Summary.Incidents = len(page.Incidents)
+	sort.Slice(evidence.Incidents, func(i, j int) bool { return evidence.Incidents[i].FirstSeen.Before(evidence.Incidents[j].FirstSeen) })
+	for _, incident := range evidence.Incidents {
+		window, err := incidentWindow(channel.ChatroomID, username, incident)
+		if err != nil {
+			return nil, err
+		}
+		evidence.Windows = append(evidence.Windows, window)
+	}
+
+	var flag models.FlaggedChatter
+	if err := db.DB.Where("channel_id = ? AND sender_username = ?", channel.ChannelID, username).First(&flag).Error; err == nil {
+		evidence.Flag = &EvidenceFlag{Reason: flag.Reason, FlaggedBy: flag.FlaggedBy, FlaggedAt: flag.CreatedAt}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch flag of %s: %w", username, err)
+	}
+
+	if evidence.SenderID != nil {
+		if evidence.BotScore, err = GetAccountBotScore(*evidence.SenderID); err != nil {
+			return nil, err
+		}
+	}
+	return evidence, nil
+}
+
+// chatterMessages returns the messages of a chatter in a chatroom, hot and archived, sorted by send time
+func chatterMessages(chatroomID uint, username string, from, to time.Time) ([]models.ChatMessage, error) {
+	query := db.DB.Where("chatroom_id = ? AND LOWER(sender_username) = ?", chatroomID, username)
+	if !from.IsZero() {
+		query = query.Where("message_send_time >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("message_send_time < ?", to)
+	}
+	var messages []models.ChatMessage
+	if err := query.Order("message_send_time ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch messages of %s: %w", username, err)
+	}
+
+	archived, err := loadChatroomArchive(chatroomID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range archived {
+		if strings.EqualFold(msg.SenderUsername, username) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].MessageSendTime.Before(messages[j].MessageSendTime) })
+	return messages, nil
+}
+
+// incidentWindow returns the chat of the chatroom around an incident. Long windows keep the rows nearest to the
+// chatter's own messages.
+func incidentWindow(chatroomID uint, username string, incident SpamIncident) (EvidenceWindow, error) {
+	window := EvidenceWindow{
+		IncidentID: incident.ID.String(),
+		Type:       incident.Type,
+		Start:      incident.FirstSeen.Add(-evidenceWindowPadding),
+		End:        incident.LastSeen.Add(evidenceWindowPadding),
+		Lines:      []EvidenceLine{},
+	}
+	var messages []models.ChatMessage
+	if err := db.DB.Where("chatroom_id = ? AND message_send_time >= ? AND message_send_time < ?", chatroomID, window.Start, window.End).
+		Order("message_send_time ASC").Find(&messages).Error; err != nil {
+		return window, fmt.Errorf("failed to fetch chat around incident %s: %w", window.IncidentID, err)
+	}
+	archived, err := loadChatroomArchive(chatroomID, window.Start, window.End)
+	if err != nil {
+		return window, err
+	}
+	if len(archived) > 0 {
+		messages = append(messages, archived...)
+		sort.SliceStable(messages, func(i, j int) bool { return messages[i].MessageSendTime.Before(messages[j].MessageSendTime) })
+	}
+
+	if len(messages) > evidenceWindowRows {
+		window.Truncated = true
+		first := 0
+		for i, msg := range messages {
+			if strings.EqualFold(msg.SenderUsername, username) {
+				first = i
+				break
+			}
+		}
+		start := max(0, min(first-evidenceWindowRows/4, len(messages)-evidenceWindowRows))
+		messages = messages[start : start+evidenceWindowRows]
+	}
+	for _, msg := range messages {
+		window.Lines = append(window.Lines, evidenceLine(msg, username))
+	}
+	return window, nil
+}
+
+func evidenceLine(msg models.ChatMessage, username string) EvidenceLine {
+	return EvidenceLine{
+		MessageID:    msg.ID.String(),
+		Time:         msg.MessageSendTime,
+		LivestreamID: msg.LivestreamID,
+		Username:     msg.SenderUsername,
+		Message:      msg.Message,
+		Subject:      strings.EqualFold(msg.SenderUsername, username),
+	}
+}
+
+// RenderEvidenceMarkdown renders a bundle as Markdown for pasting into an appeal or a support ticket
+func RenderEvidenceMarkdown(evidence *ModerationEvidence) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Moderation evidence: %s in %s\n\n", escapeMarkdown(evidence.Username), escapeMarkdown(evidence.Channel))
+	period := "all recorded chat"
+	if evidence.From != nil || evidence.To != nil {
+		bound := func(t *time.Time, fallback string) string {
+			if t == nil {
+				return fallback
+			}
+			return t.UTC().Format("2006-01-02 15:04 UTC")
+		}
+		period = bound(evidence.From, "the first record") + " to " + bound(evidence.To, "now")
+	}
+	fmt.Fprintf(&b, "Generated %s, covering %s.\n\n", evidence.GeneratedAt.Format("2006-01-02 15:04 UTC"), period)
+
+	b.WriteString("| | |\n|---|---|\n")
+	if evidence.SenderID != nil {
+		fmt.Fprintf(&b, "| Kick user ID | %d |\n", *evidence.SenderID)
+	}
+	fmt.Fprintf(&b, "| Messages | %s in %d stream(s) |\n", formatInt(evidence.Summary.Messages), evidence.Summary.Streams)
+	if evidence.Summary.FirstMessage != nil {
+		fmt.Fprintf(&b, "| First / last message | %s / %s |\n", evidence.Summary.FirstMessage.UTC().Format(time.DateTime),
+			evidence.Summary.LastMessage.UTC().Format(time.DateTime))
+	}
+	fmt.Fprintf(&b, "| Spam findings | %d |\n", evidence.Summary.Incidents)
+	if evidence.Flag != nil {
+		fmt.Fprintf(&b, "| Flagged | %s by %s: %s |\n", evidence.Flag.FlaggedAt.UTC().Format(time.DateOnly),
+			escapeMarkdown(evidence.Flag.FlaggedBy), escapeMarkdown(evidence.Flag.Reason))
+	}
+	if evidence.BotScore != nil {
+		fmt.Fprintf(&b, "| Bot probability | %.0f%% over %d stream(s) |\n", evidence.BotScore.Probability*100, evidence.BotScore.Streams)
+	}
+
+	if len(evidence.Incidents) > 0 {
+		b.WriteString("\n## Spam findings\n\n| First seen (UTC) | Type | Messages | Content |\n|---|---|---:|---|\n")
+		for _, incident := range evidence.Incidents {
+			fmt.Fprintf(&b, "| %s | %s | %d | %s |\n", incident.FirstSeen.UTC().Format(time.DateTime), incident.Type,
+				incident.MessageCount, escapeMarkdown(truncateRunes(incident.Content, markdownExampleChars)))
+		}
+	}
+
+	for i, window := range evidence.Windows {
+		fmt.Fprintf(&b, "\n### Chat around finding %d (%s), %s – %s UTC\n\n", i+1, window.Type,
+			window.Start.UTC().Format(time.DateTime), window.End.UTC().Format(time.TimeOnly))
+		if window.Truncated {
+			fmt.Fprintf(&b, "_Showing %d messages of a busier window._\n\n", len(window.Lines))
+		}
+		writeEvidenceTable(&b, window.Lines)
+	}
+
+	b.WriteString("\n## Messages\n\n")
+	if evidence.Summary.Listed < evidence.Summary.Messages {
+		fmt.Fprintf(&b, "_The latest %d of %d messages._\n\n", evidence.Summary.Listed, evidence.Summary.Messages)
+	}
+	if len(evidence.Messages) == 0 {
+		b.WriteString("_No messages recorded._\n")
+	} else {
+		writeEvidenceTable(&b, evidence.Messages)
+	}
+	return b.String()
+}
+
+// writeEvidenceTable writes chat lines as a table, the chatter's own lines in bold
+func writeEvidenceTable(b *strings.Builder, lines []EvidenceLine) {
+	b.WriteString("| Time (UTC) | User | Message |\n|---|---|---|\n")
+	for _, line := range lines {
+		user, message := escapeMarkdown(line.Username), escapeMarkdown(line.Message)
+		if line.Subject {
+			user, message = "**"+user+"**", "**"+message+"**"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s |\n", line.Time.UTC().Format(time.DateTime), user, message)
+	}
+}
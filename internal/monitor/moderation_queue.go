@@ -0,0 +1,317 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Moderation item statuses
+const (
+	ModerationPending   = "pending"
+	ModerationConfirmed = "confirmed"
+	ModerationDismissed = "dismissed"
+)
+
+// ModerationStatuses lists the statuses accepted by the queue endpoint
+var ModerationStatuses = []string{ModerationPending, ModerationConfirmed, ModerationDismissed}
+
+var (
+	ErrModerationItemNotFound = errors.New("moderation item not found")
+	ErrModerationReviewed     = errors.New("moderation item was already reviewed")
+)
+
+// moderationFindingKey identifies a finding across regenerations of a stream's report. A suspicious chatter is one
+// finding per stream whatever its example message, bursts and copypasta are one per content.
+func moderationFindingKey(incident models.SpamIncident) string {
+	key := incident.Type + "\x00" + incident.Username
+	if incident.Type != SpamIncidentSuspicious {
+		key += "\x00" + incident.Content
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// queueModerationItems adds the incidents not queued yet to the moderation queue. Findings a regenerated report
+// finds again keep their item and its review.
+func queueModerationItems(tx *gorm.DB, incidents []models.SpamIncident) error {
+	items := make([]models.ModerationItem, 0, len(incidents))
+	for _, incident := range incidents {
+		if incident.Type == SpamIncidentSuspicious && onlyConfirmedOffender(incident) {
+			continue // Nothing new to review, an analyst already confirmed the chatter
+		}
+		items = append(items, models.ModerationItem{
+			ID:           uuid.New(),
+			ChannelID:    incident.ChannelID,
+			LivestreamID: incident.LivestreamID,
+			FindingKey:   moderationFindingKey(incident),
+			Type:         incident.Type,
+			SenderID:     incident.SenderID,
+			Username:     incident.Username,
+			Content:      incident.Content,
+			MessageCount: incident.MessageCount,
+			Score:        incident.Score,
+			Details:      incident.Details,
+			FirstSeen:    incident.FirstSeen,
+			Status:       ModerationPending,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&items, max(Capacity.DBBatchSize, 1)).Error; err != nil {
+		return fmt.Errorf("failed to queue moderation items: %w", err)
+	}
+	return nil
+}
+
+// onlyConfirmedOffender reports whether a suspicious chatter was reported only for an earlier confirmation
+func onlyConfirmedOffender(incident models.SpamIncident) bool {
+	var details struct {
+		Issues []string `json:"issues"`
+	}
+	if json.Unmarshal(incident.Details, &details) != nil {
+		return false
+	}
+	return len(details.Issues) == 1 && details.Issues[0] == IssueConfirmedOffender
+}
+
+// loadConfirmedOffenders returns the lowercased usernames analysts confirmed as spammers, on any channel.
+// Copypasta items have no username, taking part in a confirmed copypasta doesn't make a chatter an offender.
+func loadConfirmedOffenders() (map[string]struct{}, error) {
+	var usernames []string
+	if err := db.DB.Model(&models.ModerationItem{}).
+		Where("status = ? AND username <> ''", ModerationConfirmed).
+		Distinct("username").Pluck("username", &usernames).Error; err != nil {
+		return nil, fmt.Errorf("failed to load confirmed offenders: %w", err)
+	}
+	offenders := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		offenders[username] = struct{}{}
+	}
+	return offenders, nil
+}
+
+// ModerationQueueQuery filters the moderation queue. Zero values don't filter.
+type ModerationQueueQuery struct {
+	Status    string
+	ChannelID uint
+	Types     []string
+	Before    time.Time // Cursor, only items first seen before it
+	Limit     int
+}
+
+// ModerationQueuePage is a page of moderation items, newest first
+type ModerationQueuePage struct {
+	Items      []ModerationItem `json:"items"`
+	NextBefore *time.Time       `json:"next_before,omitempty"` // Pass as before to get the next page
+}
+
+type ModerationItem struct {
+	ID           uuid.UUID       `json:"id"`
+	Type         string          `json:"type"`
+	Status       string          `json:"status"`
+	ChannelID    uint            `json:"channel_id"`
+	LivestreamID uint            `json:"livestream_id"`
+	SenderID     *int            `json:"sender_id,omitempty"`
+	Username     string          `json:"username,omitempty"`
+	Content      string          `json:"content"`
+	MessageCount int             `json:"message_count"`
+	Score        float64         `json:"score,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	FirstSeen    time.Time       `json:"first_seen"`
+	ReviewedBy   string          `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time      `json:"reviewed_at,omitempty"`
+	Note         string          `json:"note,omitempty"`
+}
+
+func moderationItemFromModel(row models.ModerationItem) ModerationItem {
+	return ModerationItem{
+		ID:           row.ID,
+		Type:         row.Type,
+		Status:       row.Status,
+		ChannelID:    row.ChannelID,
+		LivestreamID: row.LivestreamID,
+		SenderID:     row.SenderID,
+		Username:     row.Username,
+		Content:      row.Content,
+		MessageCount: row.MessageCount,
+		Score:        row.Score,
+		Details:      row.Details,
+		FirstSeen:    row.FirstSeen,
+		ReviewedBy:   row.ReviewedBy,
+		ReviewedAt:   row.ReviewedAt,
+		Note:         row.Note,
+	}
+}
+
+// ListModerationQueue returns a page of the moderation items matching the query, newest first
+func ListModerationQueue(q ModerationQueueQuery) (ModerationQueuePage, error) {
+	query := db.DB.Model(&models.ModerationItem{})
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.ChannelID != 0 {
+		query = query.Where("channel_id = ?", q.ChannelID)
+	}
+	if len(q.Types) > 0 {
+		query = query.Where("type IN ?", q.Types)
+	}
+	if !q.Before.IsZero() {
+		query = query.Where("first_seen < ?", q.Before)
+	}
+
+	var rows []models.ModerationItem
+	if err := query.Order("first_seen DESC, id").Limit(q.Limit + 1).Find(&rows).Error; err != nil {
+		return ModerationQueuePage{}, fmt.Errorf("failed to list moderation queue: %w", err)
+	}
+
+	page := ModerationQueuePage{Items: make([]ModerationItem, 0, len(rows))}
+	if len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		next := rows[len(rows)-1].FirstSeen
+		page.NextBefore = &next
+	}
+	for _, row := range rows {
+		page.Items = append(page.Items, moderationItemFromModel(row))
+	}
+	return page, nil
+}
+
+// ReviewModerationItem confirms or dismisses a pending item. Reviews are final, so two analysts racing on the same
+// item can't both count towards precision.
+func ReviewModerationItem(id uuid.UUID, status, reviewer, note string) (ModerationItem, error) {
+	if status != ModerationConfirmed && status != ModerationDismissed {
+		return ModerationItem{}, fmt.Errorf("invalid review status %q", status)
+	}
+	var item models.ModerationItem
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrModerationItemNotFound
+			}
+			return fmt.Errorf("failed to load moderation item %s: %w", id, err)
+		}
+		if item.Status != ModerationPending {
+			return ErrModerationReviewed
+		}
+		now := time.Now()
+		item.Status, item.ReviewedBy, item.ReviewedAt, item.Note = status, reviewer, &now, strings.TrimSpace(note)
+		if err := tx.Model(&item).Select("status", "reviewed_by", "reviewed_at", "note").Updates(&item).Error; err != nil {
+			return fmt.Errorf("failed to review moderation item %s: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return ModerationItem{}, err
+	}
+	return moderationItemFromModel(item), nil
+}
+
+// ModerationPrecision is how often analysts confirmed what a detector found
+type ModerationPrecision struct {
+	Detector  string   `json:"detector"` // Incident type, or suspicious_chatter:<issue> for the signals of suspicious chatters
+	Confirmed int      `json:"confirmed"`
+	Dismissed int      `json:"dismissed"`
+	Pending   int      `json:"pending"`
+	Precision *float64 `json:"precision"` // Confirmed share of the reviewed items, nil until one is reviewed
+}
+
+// ModerationPrecisionQuery filters the items precision is computed over. Zero values don't filter.
+type ModerationPrecisionQuery struct {
+	ChannelID uint
+	From, To  time.Time // On FirstSeen, To exclusive
+}
+
+// ModerationPrecisionReport breaks precision down by incident type and by suspicious chatter issue, to tell which
+// detectors and SUSPICION_WEIGHTS need tuning
+type ModerationPrecisionReport struct {
+	Types  []ModerationPrecision `json:"types"`
+	Issues []ModerationPrecision `json:"issues"`
+}
+
+// BuildModerationPrecision counts the reviews of the items matching the query
+func BuildModerationPrecision(q ModerationPrecisionQuery) (ModerationPrecisionReport, error) {
+	scope := func(query *gorm.DB) *gorm.DB {
+		if q.ChannelID != 0 {
+			query = query.Where("channel_id = ?", q.ChannelID)
+		}
+		if !q.From.IsZero() {
+			query = query.Where("first_seen >= ?", q.From)
+		}
+		if !q.To.IsZero() {
+			query = query.Where("first_seen < ?", q.To)
+		}
+		return query
+	}
+	counts := `SUM(CASE WHEN status = 'confirmed' THEN 1 ELSE 0 END) AS confirmed,
+		SUM(CASE WHEN status = 'dismissed' THEN 1 ELSE 0 END) AS dismissed,
+		SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END) AS pending`
+
+	var types []ModerationPrecision
+	if err := scope(db.Reader().Model(&models.ModerationItem{})).
+		Select("type AS detector, " + counts).Group("type").Order("type").Scan(&types).Error; err != nil {
+		return ModerationPrecisionReport{}, fmt.Errorf("failed to count moderation reviews: %w", err)
+	}
+	var issues []ModerationPrecision
+	if err := scope(db.Reader().Table("moderation_items, jsonb_array_elements_text(moderation_items.details -> 'issues') AS issue")).
+		Where("type = ?", SpamIncidentSuspicious).
+		Select(fmt.Sprintf("'%s:' || issue AS detector, %s", SpamIncidentSuspicious, counts)).Group("issue").Order("issue").Scan(&issues).Error; err != nil {
+		return ModerationPrecisionReport{}, fmt.Errorf("failed to count moderation reviews by issue: %w", err)
+	}
+
+	report := ModerationPrecisionReport{Types: types, Issues: issues}
+	for _, rows := range [][]ModerationPrecision{report.Types, report.Issues} {
+		for i := range rows {
+			if reviewed := rows[i].Confirmed + rows[i].Dismissed; reviewed > 0 {
+				precision := float64(rows[i].Confirmed) / float64(reviewed)
+				rows[i].Precision = &precision
+			}
+		}
+	}
+	if report.Types == nil {
+		report.Types = []ModerationPrecision{}
+	}
+	if report.Issues == nil {
+		report.Issues = []ModerationPrecision{}
+	}
+	return report, nil
+}
+
+// ConfirmedOffender is a chatter analysts confirmed as a spammer at least once
+type ConfirmedOffender struct {
+	Username      string    `json:"username"`
+	SenderID      *int      `json:"sender_id,omitempty"`
+	Confirmations int       `json:"confirmations"`
+	Streams       int       `json:"streams"`
+	Channels      int       `json:"channels"`
+	LastConfirmed time.Time `json:"last_confirmed"`
+}
+
+// ListConfirmedOffenders returns the confirmed offenders, most recently confirmed first. Reports of every channel
+// flag them with the confirmed_offender issue.
+func ListConfirmedOffenders(channelID uint, limit int) ([]ConfirmedOffender, error) {
+	query := db.Reader().Model(&models.ModerationItem{}).Where("status = ? AND username <> ''", ModerationConfirmed)
+	if channelID != 0 {
+		query = query.Where("channel_id = ?", channelID)
+	}
+	offenders := []ConfirmedOffender{}
+	if err := query.Select(`username, MAX(sender_id) AS sender_id, COUNT(*) AS confirmations,
+			COUNT(DISTINCT livestream_id) AS streams, COUNT(DISTINCT channel_id) AS channels,
+			MAX(reviewed_at) AS last_confirmed`).
+		Group("username").Order("last_confirmed DESC, username").Limit(limit).Scan(&offenders).Error; err != nil {
+		return nil, fmt.Errorf("failed to list confirmed offenders: %w", err)
+	}
+	return offenders, nil
+}
@@ -1,14 +1,12 @@
 package monitor
 
 import (
-	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
-	"net/http"
 	"net/url"
 	"regexp"
 	"slices"
@@ -25,15 +23,14 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
 	FetchInterval = 2 * time.Minute
-	WebSocketURL  = "wss://ws-us2.pusher.com/app/32cbd69e4b950bf97679" // Base WebSocket URL
+	WebSocketURL  = "wss://ws-us2.pusher.com/app/32cbd69e4b950bf97679" // Default WebSocket URL, see currentWebSocketURL
 
-	// Leeway for considering livestream data current
-	LivestreamFreshnessLeeway = 20 * time.Second // 2 minutes + 20 seconds
-	ReportTimeBlock           = 2 * time.Minute  // Viewer count timeline interval
+	ReportTimeBlock = 2 * time.Minute // Viewer count timeline interval
 
 	MessageTimelineBlock = 10 * time.Minute // Message count timeline interval
 
@@ -49,8 +46,6 @@ const (
 	RapidMessageBurstMinCount = 5               // Min messages by same user in window for rapid burst
 )
 
-var ProxyURL string
-
 // Structs for proxy response and Kick API data
 type ProxyResponse struct {
 	Status   string `json:"status"`
@@ -152,9 +147,16 @@ type KickChatroom struct {
 }
 
 type ProxyRequestPayload struct {
-	Cmd        string `json:"cmd"`
-	URL        string `json:"url"`
-	MaxTimeout int    `json:"maxTimeout"`
+	Cmd        string        `json:"cmd"`
+	URL        string        `json:"url"`
+	MaxTimeout int           `json:"maxTimeout"`
+	PostData   string        `json:"postData,omitempty"` // URL-encoded form of request.post
+	Cookies    []ProxyCookie `json:"cookies,omitempty"`
+}
+
+type ProxyCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 // Struct to represent the generic WebSocket message structure
@@ -181,8 +183,8 @@ type ChatMessageEventData struct {
 		Username string `json:"username"`
 		Slug     string `json:"slug"`
 		Identity struct {
-			Color  string `json:"color"`
-			Badges []any  `json:"badges"`
+			Color  string      `json:"color"`
+			Badges []KickBadge `json:"badges"`
 		} `json:"identity"`
 	} `json:"sender"`
 	Metadata json.RawMessage `json:"metadata"` // Use json.RawMessage for metadata
@@ -195,7 +197,19 @@ var AppSenders = map[string]struct{}{
 	"fossabot":  {},
 	"kicbot":    {},
 }
-var latestLivestream sync.Map // map[uint]LatestLivestreamInfo
+
+// activeMonitors holds the stop signal of every channel monitored by this instance
+var activeMonitors = struct {
+	sync.Mutex
+	stops map[uint]chan struct{}
+}{stops: make(map[uint]chan struct{})}
+
+// OwnershipFilter, when set, decides whether this instance may monitor a channel (used for sharding).
+var OwnershipFilter func(channelID uint) bool
+
+// Clustered is set when instances shard channels between them. What one instance observes but every instance serves
+// is then shared through the database.
+var Clustered bool
 
 var emoteRegex = regexp.MustCompile(`\[emote:\d+:\w+\]`)
 var onlyEmotesRegex = regexp.MustCompile(`^(\s*\[emote:\d+:\w+\]\s*)+$`)
@@ -221,6 +235,7 @@ type ReportMetrics struct {
 	RepetitivePhraseCounts map[string]int              // Phrase -> count (placeholder)
 	SuspiciousChattersMap  map[int]struct{}            // map[SenderID]struct{} to track unique suspicious users by ID
 	SuspiciousChattersList []SuspiciousChatterReport   // List of detailed reports for suspicious chatters (slice)
+	SuspicionSignals       map[int]map[string]int      // SenderID -> issue -> occurrences, for scoring
 
 	ViewerCountsTimeline  []ViewerCountPoint
 	MessageCountsTimeline []MessageCountPoint
@@ -261,6 +276,10 @@ type SuspiciousChatterReport struct {
 	PotentialIssues   []string    `json:"potential_issues"`
 	MessageTimestamps []time.Time `json:"message_timestamps"`
 	ExampleMessages   []string    `json:"example_messages"`
+
+	Score       float64           `json:"score"` // Weighted sum of the issues, see SuspicionWeights
+	Rank        int               `json:"rank"`  // 1 is the most suspicious chatter of the stream
+	Explanation []SuspicionSignal `json:"explanation"`
 }
 
 // NewReportMetrics initializes a new ReportMetrics instance
@@ -271,6 +290,7 @@ func NewReportMetrics() *ReportMetrics {
 		RepetitivePhraseCounts: make(map[string]int),
 		SuspiciousChattersMap:  make(map[int]struct{}),
 		SuspiciousChattersList: []SuspiciousChatterReport{},
+		SuspicionSignals:       make(map[int]map[string]int),
 		ViewerCountsTimeline:   []ViewerCountPoint{},
 		MessageCountsTimeline:  []MessageCountPoint{},
 	}
@@ -279,6 +299,7 @@ func NewReportMetrics() *ReportMetrics {
 type LivestreamReportRestructured struct {
 	LivestreamID    int       `json:"livestream_id"`
 	Title           string    `json:"title"`
+	Category        string    `json:"category,omitempty"`
 	ReportStartTime time.Time `json:"report_start_time"`
 	ReportEndTime   time.Time `json:"report_end_time"`
 	DurationMinutes int       `json:"duration_minutes"`
@@ -288,12 +309,64 @@ type LivestreamReportRestructured struct {
 	Engagement      float64   `json:"engagement"`
 	HoursWatched    float64   `json:"hours_watched"`
 
-	TotalMessages         int             `json:"total_messages"`
-	UniqueChatters        int             `json:"unique_chatters"`
-	MessagesFromApps      int             `json:"messages_from_apps"`
-	ViewerCountsTimeline  json.RawMessage `json:"viewer_counts_timeline"`
-	MessageCountsTimeline json.RawMessage `json:"message_counts_timeline"`
-	CreatedAt             time.Time       `json:"created_at"`
+	AverageConcurrentViewers float64 `json:"average_concurrent_viewers"` // Time-weighted, 0 for older reports
+	ViewerTrackedSeconds     int     `json:"viewer_tracked_seconds"`
+
+	FollowersGained         int     `json:"followers_gained"`
+	FollowersPerHourWatched float64 `json:"followers_per_hour_watched"`
+
+	EngagementFormula             string  `json:"engagement_formula"`
+	EngagementChattersPerAverage  float64 `json:"engagement_chatters_per_average_viewers"`
+	EngagementMessagesPerViewerHr float64 `json:"engagement_messages_per_viewer_hour"`
+	EngagementChattersPerPeak     float64 `json:"engagement_chatters_per_peak_viewers"`
+	EngagementQualityWeighted     float64 `json:"engagement_quality_weighted"`
+	EngagementBotAdjusted         float64 `json:"engagement_bot_adjusted"`
+	LikelyBotChatters             int     `json:"likely_bot_chatters"`
+
+	TotalMessages           int             `json:"total_messages"`
+	UniqueChatters          int             `json:"unique_chatters"`
+	MessagesFromApps        int             `json:"messages_from_apps"`
+	TopOnePercentShare      float64         `json:"top_one_percent_share"`
+	TopTenPercentShare      float64         `json:"top_ten_percent_share"`
+	ChatGini                float64         `json:"chat_gini"`
+	RawAverageViewers       int             `json:"raw_average_viewers"`
+	RawPeakViewers          int             `json:"raw_peak_viewers"`
+	ViewerCountsTimeline    json.RawMessage `json:"viewer_counts_timeline"`
+	RawViewerCountsTimeline json.RawMessage `json:"raw_viewer_counts_timeline"`
+	MessageCountsTimeline   json.RawMessage `json:"message_counts_timeline"`
+	Reactions               json.RawMessage `json:"reactions"`
+	AudienceComposition     json.RawMessage `json:"audience_composition"`
+	ClipsCreated            json.RawMessage `json:"clips_created"`
+	QuestionStats           json.RawMessage `json:"question_stats"`
+	CustomMetrics           json.RawMessage `json:"custom_metrics,omitempty"`
+	ViewerBotAnalysis       json.RawMessage `json:"viewer_bot_analysis"`
+	ViewerBotSuspected      bool            `json:"viewer_bot_suspected"`
+	ChatLanguages           json.RawMessage `json:"chat_languages"`
+	Quality                 json.RawMessage `json:"quality"`                  // Data quality score, confidence ranges of average_viewers and peak_viewers
+	Simulcast               bool            `json:"simulcast"`                // Also broadcast elsewhere: chat, and engagement with it, is split
+	SimulcastInfo           json.RawMessage `json:"simulcast_info,omitempty"` // Platforms and indicators of the simulcast
+	ViewerMilestones        json.RawMessage `json:"viewer_milestones"`        // Peak, multiples of the starting viewers and round numbers reached, as chart markers
+	RaidSpikes              json.RawMessage `json:"raid_spikes"`              // Viewer rises a host or another monitored channel's stream ending explains, as chart markers
+
+	ParentReportID    *uuid.UUID      `json:"parent_report_id,omitempty"`
+	ChunkIndex        int             `json:"chunk_index,omitempty"`
+	ChunkCount        int             `json:"chunk_count,omitempty"`
+	ChunkReportIDs    json.RawMessage `json:"chunk_report_ids,omitempty"`
+	Stale             bool            `json:"stale"`
+	StaleMessages     int             `json:"stale_messages,omitempty"`
+	Sampled           bool            `json:"sampled"`
+	SampleRate        float64         `json:"sample_rate,omitempty"`
+	PersistedMessages int             `json:"persisted_messages,omitempty"`
+	ExcludedChatters  int             `json:"excluded_chatters,omitempty"`
+	ExcludedMessages  int             `json:"excluded_messages,omitempty"`
+	IngestionLagP50   float64         `json:"ingestion_lag_p50_seconds"`
+	IngestionLagP95   float64         `json:"ingestion_lag_p95_seconds"`
+	IngestionLagged   bool            `json:"ingestion_lagged"`
+	WindowStart       *time.Time      `json:"window_start,omitempty"`
+	WindowEnd         *time.Time      `json:"window_end,omitempty"`
+	VodURL            string          `json:"vod_url,omitempty"`
+	VodSourceURL      string          `json:"vod_source_url,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
 }
 
 type FullLivestreamReportForProfile struct {
@@ -311,6 +384,9 @@ type StreamerProfileAPI struct {
 	SubscriptionEnabled bool                             `json:"subscription_enabled"`
 	FollowersCount      []models.FollowersCountPoint     `json:"followers_count"`
 	Livestreams         []FullLivestreamReportForProfile `json:"livestreams"`
+	AsOf                *time.Time                       `json:"as_of,omitempty"`              // Set when the profile was reconstructed as of a past date
+	PreviousUsernames   []string                         `json:"previous_usernames,omitempty"` // Most recent first
+	AudienceLanguages   *AudienceLanguages               `json:"audience_languages,omitempty"` // Estimated from chat, as Kick shares no audience geography
 
 	Bio        string `json:"bio,omitempty"`
 	City       string `json:"city,omitempty"`
@@ -333,69 +409,94 @@ type SpamReportRestructured struct {
 	ExactDuplicateBursts       json.RawMessage `json:"exact_duplicate_bursts"`
 	SimilarMessageBursts       json.RawMessage `json:"similar_message_bursts"`
 	SuspiciousChatters         json.RawMessage `json:"suspicious_chatters"`
+	CrossUserCopypasta         json.RawMessage `json:"cross_user_copypasta"`
+	AltClusters                json.RawMessage `json:"alt_clusters"`
+	UnicodeAbuse               json.RawMessage `json:"unicode_abuse"`
+	EmoteBursts                json.RawMessage `json:"emote_bursts"` // Emote-only bursts split into hype and spam
 }
 
-func SetProxyURL(url string) error {
-	if url == "" {
-		return fmt.Errorf("provided ProxyURL cannot be empty")
+// StartMonitoringChannel initiates the data fetching and WebSocket routines for a channel. It is idempotent: a
+// channel already monitored keeps its routines and the call returns false, so concurrent starts can't run two sets.
+func StartMonitoringChannel(channel *models.MonitoredChannel) bool {
+	if OwnershipFilter != nil && !OwnershipFilter(channel.ChannelID) {
+		log.Printf("Skipping monitoring for channel %s (ID: %d): owned by another instance", channel.Username, channel.ChannelID)
+		return false
 	}
-	ProxyURL = url
-	return nil
-}
 
-// StartMonitoringChannel initiates the data fetching and WebSocket routines for a channel.
-func StartMonitoringChannel(channel *models.MonitoredChannel) {
+	stop := make(chan struct{})
+	activeMonitors.Lock()
+	// Checked under the lock so Drain, which sets the flag before listing the monitors, can't miss this one
+	if IsDraining() {
+		activeMonitors.Unlock()
+		log.Printf("Skipping monitoring for channel %s (ID: %d): instance is draining", channel.Username, channel.ChannelID)
+		return false
+	}
+	// Registering under the same lock makes the check and the start one step, a second start finds the first
+	if _, running := activeMonitors.stops[channel.ChannelID]; running {
+		activeMonitors.Unlock()
+		log.Printf("Channel %s (ID: %d) is already monitored, not starting it again", channel.Username, channel.ChannelID)
+		return false
+	}
+	activeMonitors.stops[channel.ChannelID] = stop
+	activeMonitors.Unlock()
+
 	log.Printf("Starting monitoring for channel: %s (ID: %d)", channel.Username, channel.ChannelID)
-	latestLivestream.Store(channel.ChannelID, LatestLivestreamInfo{}) // Start with a zero value
+	setLatestLivestream(channel.ChannelID, LatestLivestreamInfo{}, nil) // Start with a zero value
+
 	// Start data fetching Go routine (uses proxy)
-	go fetchDataAndPersist(channel)
+	go fetchDataAndPersist(channel, stop)
 
 	// Start WebSocket monitoring Go routine (does NOT use proxy)
-	go startWebSocketMonitor(channel)
+	go startWebSocketMonitor(channel, stop)
+	return true
 }
 
-func FetchChannelData(username string) (*KickChannelResponse, error) {
-	log.Printf("Fetching data for channel: %s via proxy", username)
-	apiURL := fmt.Sprintf("https://kick.com/api/v2/channels/%s", username)
-
-	if ProxyURL == "" {
-		return nil, fmt.Errorf("ProxyURL not configured.")
-	}
-	proxyReqPayload := ProxyRequestPayload{
-		Cmd:        "request.get",
-		URL:        apiURL,
-		MaxTimeout: 60000, // 60 seconds
+// StopMonitoringChannel stops the fetch and WebSocket routines of a channel, if running.
+func StopMonitoringChannel(channelID uint) bool {
+	activeMonitors.Lock()
+	stop, ok := activeMonitors.stops[channelID]
+	if ok {
+		delete(activeMonitors.stops, channelID)
 	}
+	activeMonitors.Unlock()
 
-	proxyReqBody, err := json.Marshal(proxyReqPayload)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling proxy request payload: %w", err)
+	if !ok {
+		return false
 	}
 
-	resp, err := http.Post(ProxyURL, "application/json", bytes.NewBuffer(proxyReqBody))
-	if err != nil {
-		return nil, fmt.Errorf("error sending request to proxy for %s: %w", username, err)
-	}
-	defer resp.Body.Close()
+	close(stop)
+	dropChannelState(channelID)
+	log.Printf("Stopped monitoring for channel ID: %d", channelID)
+	return true
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading proxy response body for %s: %w", username, err)
-	}
+// MonitoredChannelIDs returns the IDs of channels monitored by this instance.
+func MonitoredChannelIDs() []uint {
+	activeMonitors.Lock()
+	defer activeMonitors.Unlock()
 
-	var proxyResp ProxyResponse
-	if err := json.Unmarshal(body, &proxyResp); err != nil {
-		return nil, fmt.Errorf("error unmarshalling proxy response for %s: %w", username, err)
+	ids := make([]uint, 0, len(activeMonitors.stops))
+	for id := range activeMonitors.stops {
+		ids = append(ids, id)
 	}
+	return ids
+}
 
-	if proxyResp.Status != "ok" {
-		return nil, fmt.Errorf("proxy returned non-ok status for %s: %s", username, proxyResp.Message)
-	}
+// IsMonitoring reports whether this instance is currently monitoring the channel.
+func IsMonitoring(channelID uint) bool {
+	activeMonitors.Lock()
+	defer activeMonitors.Unlock()
+
+	_, ok := activeMonitors.stops[channelID]
+	return ok
+}
 
-	// Extract JSON from HTML response within the proxy's solution.response
-	jsonString, err := util.ExtractJSONFromHTML(proxyResp.Solution.Response)
+// FetchChannelData fetches the Kick channel of username through fetcher
+func FetchChannelData(fetcher Fetcher, username string) (*KickChannelResponse, error) {
+	log.Printf("Fetching data for channel: %s via proxy", username)
+	jsonString, err := fetchChannelJSON(fetcher, fmt.Sprintf("https://kick.com/api/v2/channels/%s", username), username)
 	if err != nil {
-		return nil, fmt.Errorf("error extracting JSON from HTML for %s: %w", username, err)
+		return nil, err
 	}
 
 	var kickData KickChannelResponse
@@ -407,15 +508,35 @@ func FetchChannelData(username string) (*KickChannelResponse, error) {
 }
 
 // fetchDataAndPersist periodically fetches and persists channel and livestream data.
-func fetchDataAndPersist(channel *models.MonitoredChannel) {
+func fetchDataAndPersist(channel *models.MonitoredChannel, stop <-chan struct{}) {
 	ticker := time.NewTicker(FetchInterval)
 	defer ticker.Stop()
+	wake, unschedule := scheduleFetches(channel.ChannelID, channel.Username)
+	defer unschedule()
+
+	var lastFetch time.Time
+	fetch := func() {
+		lastFetch = time.Now()
+		recordFetchRun(channel.ChannelID, lastFetch, lastFetch.Add(pollInterval(channel.Username)))
+		trackIngestion(func() { processChannelData(channel) })
+	}
 
 	// Initial fetch when the routine starts
-	processChannelData(channel)
+	fetch()
 
-	for range ticker.C {
-		processChannelData(channel)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-wake: // Prioritized by an operator
+			fetch()
+		case <-ticker.C:
+			// Channels over their proxy budget are polled less often, see pollInterval
+			if time.Since(lastFetch)+FetchInterval/2 < pollInterval(channel.Username) {
+				continue
+			}
+			fetch()
+		}
 	}
 }
 
@@ -424,44 +545,19 @@ func processChannelData(channel *models.MonitoredChannel) { // Takes MonitoredCh
 	// log.Printf("Processing data for channel: %s (ID: %d, ChatroomID : %d)", channel.Username, channel.ChannelID, channel.ChatroomID)
 	apiURL := fmt.Sprintf("https://kick.com/api/v2/channels/%s", channel.Username)
 
-	proxyReqPayload := ProxyRequestPayload{
-		Cmd:        "request.get",
-		URL:        apiURL,
-		MaxTimeout: 60000,
-	}
-	proxyReqBody, err := json.Marshal(proxyReqPayload)
-	if err != nil {
-		log.Printf("Error marshalling proxy request payload for channel %s: %v", channel.Username, err)
-		return
-	}
-
-	resp, err := http.Post(ProxyURL, "application/json", bytes.NewBuffer(proxyReqBody))
-	if err != nil {
-		log.Printf("Error sending request to proxy for %s: %v", channel.Username, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading proxy response body for %s: %v", channel.Username, err)
-		return
-	}
-
-	var proxyResp ProxyResponse
-	if err := json.Unmarshal(body, &proxyResp); err != nil {
-		log.Printf("Error unmarshalling proxy response for %s: %v", channel.Username, err)
-		return
-	}
-
-	if proxyResp.Status != "ok" {
-		log.Printf("Proxy returned non-ok status for %s: %s", channel.Username, proxyResp.Message)
-		return
-	}
+	// Track fetch outcome for self-monitoring alerts
+	fetched := false
+	defer func() {
+		if fetched {
+			recordFetchSuccess(channel.Username)
+		} else {
+			recordFetchFailure(channel.Username)
+		}
+	}()
 
-	jsonString, err := util.ExtractJSONFromHTML(proxyResp.Solution.Response)
+	jsonString, err := fetchChannelJSON(pageFetcher, apiURL, channel.Username)
 	if err != nil {
-		log.Printf("Error extracting JSON from HTML for %s: %v", channel.Username, err)
+		log.Printf("Error fetching channel data for %s: %v", channel.Username, err)
 		return
 	}
 
@@ -470,6 +566,13 @@ func processChannelData(channel *models.MonitoredChannel) { // Takes MonitoredCh
 		log.Printf("Error unmarshalling Kick channel data for %s: %v", channel.Username, err)
 		return
 	}
+	fetched = true
+
+	// Fetched by the old slug but carrying a new one: record the rename, the restarted monitor takes it from here
+	if newUsername := renamedSlug(channel, kickData); newUsername != "" {
+		handleRename(channel, newUsername)
+		return
+	}
 
 	log.Printf("Fetched Channel Data for %s (ID: %d, ChatroomID : %d):\n", channel.Username, channel.ChannelID, channel.ChatroomID) // Log raw JSON
 
@@ -480,6 +583,7 @@ func processChannelData(channel *models.MonitoredChannel) { // Takes MonitoredCh
 	}
 	if err := db.DB.Create(&channelData).Error; err != nil {
 		log.Printf("Error saving channel data for %s: %v", channel.Username, err)
+		recordDBWriteError("channel_data", err)
 	} else {
 		log.Printf("Saved channel data for %s (Channel ID: %d, UUID: %s)", channel.Username, channel.ChannelID, channelData.ID.String())
 	}
@@ -487,15 +591,9 @@ func processChannelData(channel *models.MonitoredChannel) { // Takes MonitoredCh
 	// Persist livestream data if available and update in-memory latest livestream info
 	if kickData.Livestream != nil && kickData.Livestream.IsLive {
 		// Parse timestamps from the livestream data string fields
-		livestreamCreatedAt, err := time.Parse("2006-01-02 15:04:05", kickData.Livestream.CreatedAt)
-		if err != nil {
-			log.Printf("Error parsing livestream created_at timestamp for %s: %v", channel.Username, err)
-			// Handle error or set a zero time if parsing fails
-		}
-		startTime, err := time.Parse("2006-01-02 15:04:05", kickData.Livestream.StartTime)
-		if err != nil {
-			log.Printf("Error parsing livestream start_time timestamp for %s: %v", channel.Username, err)
-		}
+		fetchedAt := receiveTime()
+		livestreamCreatedAt := normalizeKickTimestamp("livestream.created_at", channel.Username, kickData.Livestream.CreatedAt, fetchedAt)
+		startTime := normalizeKickTimestamp("livestream.start_time", channel.Username, kickData.Livestream.StartTime, fetchedAt)
 
 		tagsData := []byte{}
 		if kickData.Livestream.Tags != nil {
@@ -517,45 +615,70 @@ func processChannelData(channel *models.MonitoredChannel) { // Takes MonitoredCh
 			StartTime:           startTime,
 			ViewerCount:         kickData.Livestream.ViewerCount,
 			SessionTitle:        kickData.Livestream.SessionTitle,
+			Source:              LivestreamSourceHTTP,
 		}
 		if err := db.DB.Create(&livestreamData).Error; err != nil {
 			log.Printf("Error saving livestream data for %s (Livestream ID: %d): %v", channel.Username, livestreamData.LivestreamID, err)
+			recordDBWriteError("livestream_data", err)
 		} else {
 			log.Printf("Saved livestream data for %s (Channel ID: %d, Livestream ID: %d)", channel.Username, channel.ChannelID, livestreamData.LivestreamID)
 
 			// Update in-memory latest livestream info
-			latestLivestream.Store(channel.ChannelID, LatestLivestreamInfo{
+			setLatestLivestream(channel.ChannelID, LatestLivestreamInfo{
 				LivestreamID: livestreamID,
 				FetchTime:    time.Now(), // Use the current time when data was successfully fetched
 				IsLive:       kickData.Livestream.IsLive,
-			})
+			}, &livestreamData)
+			recordLiveViewers(channel.ChannelID, livestreamID, livestreamData.ViewerCount, livestreamData.CreatedAt)
+			recordLivestreamSample(channel.ChannelID, livestreamID, startTime, livestreamData.CreatedAt)
+			if err := recordSimulcast(channel.ChannelID, livestreamID, kickData.Livestream); err != nil {
+				log.Printf("Error recording simulcast indicators for %s: %v", channel.Username, err)
+			}
 			log.Printf("Updated in-memory latest livestream for channel %s (ID: %d) to LivestreamID: %d", channel.Username, channel.ChannelID, livestreamID)
 		}
 	} else {
 		log.Printf("No active livestream data for channel: %s (ID: %d). Clearing in-memory latest livestream info.", channel.Username, channel.ChannelID)
-		latestLivestream.Store(channel.ChannelID, LatestLivestreamInfo{})
+		setLatestLivestream(channel.ChannelID, LatestLivestreamInfo{}, nil)
 	}
 
-	err = streamerProfileBuilder(channel, kickData)
+	snapshotTime := channelData.CreatedAt
+	if snapshotTime.IsZero() {
+		snapshotTime = time.Now() // The snapshot failed to save
+	}
+	err = streamerProfileBuilder(channel, kickData, snapshotTime)
 	if err != nil {
 		log.Printf("Error updating streamer profile for channel %s (ID: %d): %v", channel.Username, channel.ChannelID, err)
 	}
 }
 
-func createWebSocket(chatroomId uint) (*websocket.Conn, error) {
+func createWebSocket(channel *models.MonitoredChannel) (*websocket.Conn, error) {
+	chatroomId, channelID := channel.ChatroomID, channel.ChannelID
 	params := url.Values{}
 	params.Add("protocol", "7")
 	params.Add("client", "js")
 	params.Add("version", "7.4.0")
 	params.Add("flash", "false")
 
-	fullURL := WebSocketURL + "?" + params.Encode()
+	fullURL := currentWebSocketURL() + "?" + params.Encode()
 
 	conn, _, err := websocket.DefaultDialer.Dial(fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to websocket: %w", err)
 	}
 
+	// Private channels are authorized for the socket ID of the connection
+	if PusherPrivateChannels != "" {
+		socketID, err := readSocketID(conn, channel.Username)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := subscribePrivateChannels(conn, channel, socketID); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	subscribe := map[string]any{
 		"event": "pusher:subscribe",
 		"data": map[string]string{
@@ -569,30 +692,122 @@ func createWebSocket(chatroomId uint) (*websocket.Conn, error) {
 		return nil, fmt.Errorf("failed to subscribe to channel chatrooms.%d.v2: %w", chatroomId, err)
 	}
 
+	// Channel-level events carry livestream updates such as viewer counts
+	subscribeChannel := map[string]any{
+		"event": "pusher:subscribe",
+		"data": map[string]string{
+			"auth":    "",
+			"channel": fmt.Sprintf("channel.%d", channelID),
+		},
+	}
+
+	if err := conn.WriteJSON(subscribeChannel); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to channel channel.%d: %w", channelID, err)
+	}
+
 	return conn, nil
 }
 
-func startWebSocketMonitor(channel *models.MonitoredChannel) {
+func startWebSocketMonitor(channel *models.MonitoredChannel, stop <-chan struct{}) {
+	if FakeMode {
+		connection := openChatConnection(channel.ChannelID, ChatSourceWebSocket)
+		defer connection.close()
+		runFakeChat(channel, stop)
+		return
+	}
+
+	// Connections failing in a row, counting those dropped before their first message. Past ChatPollAfterFailures
+	// the chat is polled until the WebSocket works again.
+	failures := 0
+	var poller *chatPoller
+	defer func() {
+		if poller != nil {
+			poller.stop(false)
+		}
+	}()
+	connectionFailed := func() {
+		failures++
+		if ChatPollFallback && poller == nil && failures >= ChatPollAfterFailures {
+			poller = startChatPoller(channel)
+		}
+	}
+
 	for {
-		conn, err := createWebSocket(channel.ChatroomID)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		conn, err := createWebSocket(channel)
 		if err != nil {
 			log.Printf("WebSocket connection error for channel %s (ID: %d): %v. Retrying in 5 seconds...", channel.Username, channel.ChatroomID, err)
-			time.Sleep(5 * time.Second)
+			recordReconnect(channel.Username)
+			connectionFailed()
+			if sleepOrStop(5*time.Second, stop) {
+				return
+			}
 			continue
 		}
 		log.Printf("WebSocket connected and subscribed for channel: %s (ID: %d)", channel.Username, channel.ChatroomID)
+		connection := openChatConnection(channel.ChannelID, ChatSourceWebSocket)
+
+		// Close the connection when asked to stop so the blocking read below returns
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-stop:
+				conn.Close()
+			case <-done:
+			}
+		}()
 
 		// Read messages
+		received := false
 		for {
 			_, message, err := conn.ReadMessage()
 			if err != nil {
-				log.Printf("WebSocket read error for channel %s (ID: %d): %v. Attempting to reconnect...", channel.Username, channel.ChatroomID, err)
 				conn.Close() // Close connection
+				connection.close()
+				select {
+				case <-stop:
+					close(done)
+					log.Printf("WebSocket closed for channel %s (ID: %d): monitoring stopped", channel.Username, channel.ChatroomID)
+					return
+				default:
+				}
+				log.Printf("WebSocket read error for channel %s (ID: %d): %v. Attempting to reconnect...", channel.Username, channel.ChatroomID, err)
+				recordReconnect(channel.Username)
+				if !received {
+					connectionFailed()
+				}
 				break
 			}
-			handleWebSocketMessage(channel, message)
+			if !received {
+				received = true
+				failures = 0
+				if poller != nil {
+					poller.stop(true)
+					poller = nil
+				}
+			}
+			trackIngestion(func() { handleWebSocketMessage(channel, message) })
+		}
+		close(done)
+		if sleepOrStop(1*time.Second, stop) {
+			return
 		}
-		time.Sleep(1 * time.Second)
+	}
+}
+
+// sleepOrStop waits for d and reports whether stop was signalled in the meantime.
+func sleepOrStop(d time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	case <-time.After(d):
+		return false
 	}
 }
 
@@ -603,18 +818,16 @@ func handleWebSocketMessage(channel *models.MonitoredChannel, rawMessage []byte)
 		return
 	}
 
-	var currentLivestreamID *uint = nil // Default to null
-	if info, ok := latestLivestream.Load(channel.ChannelID); ok {
-		livestreamInfo := info.(LatestLivestreamInfo)
-		// Check if the latest livestream data is recent and indicates a live stream
-		if livestreamInfo.IsLive && time.Since(livestreamInfo.FetchTime) <= FetchInterval+LivestreamFreshnessLeeway {
-			currentLivestreamID = &livestreamInfo.LivestreamID // Assign the livestream ID
-		}
-	}
+	// Null unless the channel is live now, see livestreamForMessage
+	currentLivestreamID := livestreamForMessage(channel.ChannelID, time.Now())
 
 	switch msg.Event {
 	case "pusher_internal:subscription_succeeded":
 		log.Printf("✅ WebSocket subscription succeeded for channel: %s (ID: %d, ChatroomID : %d)", channel.Username, channel.ChannelID, channel.ChatroomID)
+		recordPusherSuccess()
+
+	case "pusher:error", "pusher:subscription_error":
+		handlePusherError(channel.Username, msg)
 
 	case "App\\Events\\ChatMessageEvent":
 		// Unmarshal the Data string (which is JSON) into the ChatMessageEventData struct
@@ -624,46 +837,91 @@ func handleWebSocketMessage(channel *models.MonitoredChannel, rawMessage []byte)
 			return
 		}
 
-		// Parse the message send time using the correct format
-		messageSendTime, err := time.Parse("2006-01-02T15:04:05Z07:00", chatMsgData.CreatedAt) // Correct format string
-		if err != nil {
-			log.Printf("Error parsing chat message created_at timestamp for %s: %v, value: %s", channel.Username, err, chatMsgData.CreatedAt)
-		}
+		ingestChatMessage(channel, msg.Event, chatMsgData, []byte(msg.Data), currentLivestreamID)
 
-		// Parse the message ID string into a UUID
-		messageUUID, err := uuid.Parse(chatMsgData.ID)
-		if err != nil {
-			log.Printf("Error parsing chat message ID string into UUID for %s: %v, value: %s", channel.Username, err, chatMsgData.ID)
+	case "App\\Events\\LivestreamUpdated", "App\\Events\\ViewerCountUpdated":
+		handleViewerCountEvent(channel, msg)
+
+	default:
+		if kind, ok := ReactionEvents[msg.Event]; ok {
+			handleReactionEvent(channel, msg, kind, currentLivestreamID)
 			return
 		}
+		log.Printf("📩 Unhandled WebSocket event for %s ", channel.Username)
+	}
+}
 
-		// Persist the chat message data with extracted fields
-		chatMessage := models.ChatMessage{
-			ID:           messageUUID,
-			ChatroomID:   uint(chatMsgData.ChatroomID),
-			Event:        msg.Event,
-			LivestreamID: currentLivestreamID,
-			CreatedAt:    time.Now(),
-
-			// Populate extracted fields
-			SenderID:        chatMsgData.Sender.ID,
-			SenderUsername:  chatMsgData.Sender.Slug,
-			Message:         chatMsgData.Content,
-			Metadata:        chatMsgData.Metadata,
-			MessageSendTime: messageSendTime,
-		}
+// ingestChatMessage persists a chat message received from the WebSocket or the polling fallback. Messages seen
+// before, from either, are skipped so the two can overlap.
+func ingestChatMessage(channel *models.MonitoredChannel, event string, chatMsgData ChatMessageEventData, raw []byte, currentLivestreamID *uint) {
+	// Zero when unparseable, livestreamForMessage then falls back to the receive time
+	receivedAt := receiveTime()
+	messageSendTime := normalizeKickTimestamp("chat_message.created_at", channel.Username, chatMsgData.CreatedAt, receivedAt)
 
-		if err := db.DB.Create(&chatMessage).Error; err != nil {
-			log.Printf("Error saving chat message for %s (Message ID: %s): %v",
-				channel.Username, chatMessage.ID.String(), err)
-		} else {
-			// temp disabled so we don't clutter
-			// MessagePreview(channel, &chatMessage, currentLivestreamID, chatMsgData)
-		}
+	// Parse the message ID string into a UUID
+	messageUUID, err := uuid.Parse(chatMsgData.ID)
+	if err != nil {
+		log.Printf("Error parsing chat message ID string into UUID for %s: %v, value: %s", channel.Username, err, chatMsgData.ID)
+		return
+	}
+	if !markChatMessageSeen(channel.ChannelID, messageUUID) {
+		return
+	}
 
-	default:
-		log.Printf("📩 Unhandled WebSocket event for %s ", channel.Username)
+	// Persist the chat message data with extracted fields
+	chatMessage := models.ChatMessage{
+		ID:           messageUUID,
+		ChatroomID:   uint(chatMsgData.ChatroomID),
+		Event:        event,
+		LivestreamID: livestreamForMessage(channel.ChannelID, messageSendTime), // Replayed messages keep the stream they were sent in
+		CreatedAt:    receivedAt,
+
+		// Populate extracted fields
+		SenderID:        chatMsgData.Sender.ID,
+		SenderUsername:  chatMsgData.Sender.Slug,
+		Message:         chatMsgData.Content,
+		Metadata:        chatMsgData.Metadata,
+		MessageSendTime: messageSendTime,
+		Flagged:         isFlaggedChatter(channel.ChannelID, chatMsgData.Sender.Slug),
+	}
+	if badges := chatMsgData.Sender.Identity.Badges; badges != nil {
+		chatMessage.Badges, _ = json.Marshal(badges)
+	} else {
+		chatMessage.Badges = []byte("[]")
 	}
+
+	detectClipLinks(channel, &chatMessage)
+	recordLiveMessage(channel.ChannelID, &chatMessage)
+
+	// Sampled out messages only count towards the exact totals
+	if !shouldPersistMessage(channel.ChannelID, &chatMessage) {
+		countChatMessage(&chatMessage, false)
+		if chatMsgData.Type == ReactionChatCelebration {
+			saveReactionEvent(channel, event, ReactionChatCelebration, chatMsgData.Sender.Slug, 1, raw, currentLivestreamID)
+		}
+		return
+	}
+
+	chatMessageWrites.enqueue(chatMessage, func(err error) {
+		if err != nil {
+			if !errors.Is(err, errWriteDropped) {
+				log.Printf("Error saving chat message for %s (Message ID: %s): %v",
+					channel.Username, chatMessage.ID.String(), err)
+			}
+			countChatMessage(&chatMessage, false)
+			return
+		}
+		recordIngestionLag(chatMessage.MessageSendTime, time.Now())
+		countChatMessage(&chatMessage, true)
+		if chatMsgData.Type == ReactionChatCelebration {
+			saveReactionEvent(channel, event, ReactionChatCelebration, chatMsgData.Sender.Slug, 1, raw, currentLivestreamID)
+		}
+		if chatMessage.Flagged {
+			publishEvent(LiveEvent{Type: EventFlaggedMessage, ChannelID: channel.ChannelID, Data: chatMessage})
+		}
+		// temp disabled so we don't clutter
+		// MessagePreview(channel, &chatMessage, currentLivestreamID, chatMsgData)
+	})
 }
 
 func MessagePreview(channel *models.MonitoredChannel, chatMessage *models.ChatMessage, currentLivestreamID *uint, chatMsgData ChatMessageEventData) {
@@ -681,7 +939,88 @@ func MessagePreview(channel *models.MonitoredChannel, chatMessage *models.ChatMe
 	)
 }
 
-func GenerateLivestreamReport(livestreamID uint) error {
+// ErrReportInProgress is returned when a report for the livestream is already being generated
+var ErrReportInProgress = errors.New("report generation already in progress")
+
+var reportsInProgress sync.Map // livestreamID -> struct{}
+
+// IsReportInProgress reports whether a report for the livestream is currently being generated, by any instance
+func IsReportInProgress(livestreamID uint) bool {
+	if _, ok := reportsInProgress.Load(livestreamID); ok {
+		return true
+	}
+	return isJobLeased(JobKindReport, strconv.FormatUint(uint64(livestreamID), 10))
+}
+
+// GenerateLivestreamReport generates the report of a livestream under a job lease, so it is requeued if this
+// instance crashes midway
+func GenerateLivestreamReport(livestreamID uint, window ReportWindow) error {
+	lease, err := acquireReportLease(livestreamID, window)
+	if err != nil {
+		return err
+	}
+	return runLeasedJob(lease, func() error { return runReportJob(lease) })
+}
+
+// StartLivestreamReport starts generating the report of a livestream in the background, like
+// GenerateLivestreamReport, and returns the job ID its progress can be followed with
+func StartLivestreamReport(livestreamID uint, window ReportWindow) (uuid.UUID, error) {
+	lease, err := acquireReportLease(livestreamID, window)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	go func() {
+		if err := runLeasedJob(lease, func() error { return runReportJob(lease) }); err != nil {
+			log.Printf("Error generating livestream report for %d: %v", livestreamID, err)
+		} else {
+			log.Printf("Successfully generated livestream report for %d", livestreamID)
+		}
+	}()
+	return lease.ID, nil
+}
+
+// acquireReportLease takes the lease of a livestream's report, recording the window so a requeued job keeps it
+func acquireReportLease(livestreamID uint, window ReportWindow) (*models.JobLease, error) {
+	var params any
+	if !window.IsZero() {
+		params = window
+	}
+	lease, err := acquireJobLease(JobKindReport, strconv.FormatUint(uint64(livestreamID), 10), params)
+	if errors.Is(err, ErrJobLeased) {
+		return nil, ErrReportInProgress
+	}
+	return lease, err
+}
+
+// runReportJob runs a report job from its lease, whose key is the livestream ID, tracking its progress
+func runReportJob(lease *models.JobLease) error {
+	livestreamID, err := strconv.ParseUint(lease.JobKey, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid livestream ID %q: %w", lease.JobKey, err)
+	}
+	var window ReportWindow
+	if len(lease.Params) > 0 {
+		if err := json.Unmarshal(lease.Params, &window); err != nil {
+			return fmt.Errorf("invalid report window %s: %w", lease.Params, err)
+		}
+	}
+	progress := trackReportProgress(lease.ID, uint(livestreamID))
+	err = generateLivestreamReport(uint(livestreamID), window, progress)
+	progress.finish(err)
+	return err
+}
+
+func generateLivestreamReport(livestreamID uint, window ReportWindow, progress *reportProgress) error {
+	if _, running := reportsInProgress.LoadOrStore(livestreamID, struct{}{}); running {
+		return ErrReportInProgress
+	}
+	defer reportsInProgress.Delete(livestreamID)
+
+	// Reports hold every chat message of the stream in memory, only Capacity.ConcurrentReports run at once
+	release := acquire(reportSlots)
+	defer release()
+	progress.setPhase(ReportPhaseFetching)
+
 	var monitoredChannel models.MonitoredChannel
 	subQuery := db.DB.Model(&models.LivestreamData{}).Select("channel_id").Where("livestream_id = ?", livestreamID)
 	err := db.DB.Where("channel_id IN (?)", subQuery).First(&monitoredChannel).Error
@@ -706,25 +1045,54 @@ func GenerateLivestreamReport(livestreamID uint) error {
 		}
 	}
 
+	// Chat from just before the stream was detected belongs to it
+	if attached, err := attachPreShowMessages(monitoredChannel, livestreamID, streamActualStartTime); err != nil {
+		log.Printf("Error attaching pre-show messages to livestream %d: %v", livestreamID, err)
+	} else if attached > 0 {
+		log.Printf("Attached %d pre-show chat message(s) to livestream %d", attached, livestreamID)
+	}
+
 	// Find the min/max message send times for this livestream to define the report window
 	var minMessageTime time.Time
 	var maxMessageTime time.Time
 
-	row := db.DB.Model(&models.ChatMessage{}).
+	row := window.scope(db.DB.Model(&models.ChatMessage{})).
 		Select("MIN(message_send_time), MAX(message_send_time)").
 		Where("livestream_id = ?", livestreamID).
 		Row()
 
-	if err := row.Scan(&minMessageTime, &maxMessageTime); err != nil {
-		if err == gorm.ErrRecordNotFound || minMessageTime.IsZero() {
-			log.Printf("No chat messages found for livestream ID: %d in the specified time range. Report cannot be generated.", livestreamID)
-			return fmt.Errorf("no chat messages for livestream %d", livestreamID)
-		}
+	var minMessage, maxMessage sql.NullTime
+	if err := row.Scan(&minMessage, &maxMessage); err != nil {
 		return fmt.Errorf("failed to get message time range for livestream %d: %w", livestreamID, err)
 	}
+	// Old messages may have moved to the chat archive, in part or all of them
+	archivedMessages, err := loadArchivedMessages(livestreamID, window)
+	if err != nil {
+		return err
+	}
+	for _, msg := range archivedMessages {
+		if !minMessage.Valid || msg.MessageSendTime.Before(minMessage.Time) {
+			minMessage = sql.NullTime{Time: msg.MessageSendTime, Valid: true}
+		}
+		if !maxMessage.Valid || msg.MessageSendTime.After(maxMessage.Time) {
+			maxMessage = sql.NullTime{Time: msg.MessageSendTime, Valid: true}
+		}
+	}
+	if !minMessage.Valid {
+		log.Printf("No chat messages found for livestream ID: %d in the specified time range. Report cannot be generated.", livestreamID)
+		return fmt.Errorf("no chat messages for livestream %d%s", livestreamID, window)
+	}
+	minMessageTime, maxMessageTime = minMessage.Time, maxMessage.Time
 
 	reportStartTime := minMessageTime.Truncate(MessageTimelineBlock)
 	reportEndTime := maxMessageTime.Add(MessageTimelineBlock).Truncate(MessageTimelineBlock)
+	// A window cuts the report at its bounds, but doesn't stretch it beyond the chat
+	if window.Start != nil && window.Start.After(reportStartTime) {
+		reportStartTime = *window.Start
+	}
+	if window.End != nil && window.End.Before(reportEndTime) {
+		reportEndTime = *window.End
+	}
 
 	// If streamActualStartTime was not found or is later than minMessageTime, use minMessageTime
 	if streamActualStartTime.IsZero() || streamActualStartTime.After(reportStartTime) {
@@ -732,18 +1100,46 @@ func GenerateLivestreamReport(livestreamID uint) error {
 		streamActualStartTime = reportStartTime
 	}
 
-	// Calculate duration in minutes (from reportStartTime to reportEndTime)
-	durationMinutes := int(reportEndTime.Sub(reportStartTime).Minutes())
-
 	// 2. Fetch all relevant chat messages for the livestream
 	var chatMessages []models.ChatMessage
-	if err := db.DB.Where("livestream_id = ?", livestreamID).
+	if err := window.scope(db.DB).Where("livestream_id = ?", livestreamID).
 		Order("message_send_time ASC").
 		Find(&chatMessages).Error; err != nil {
 		return fmt.Errorf("failed to fetch chat messages for livestream %d: %w", livestreamID, err)
 	}
+	if len(archivedMessages) > 0 {
+		chatMessages = append(chatMessages, archivedMessages...)
+		sort.SliceStable(chatMessages, func(i, j int) bool {
+			return chatMessages[i].MessageSendTime.Before(chatMessages[j].MessageSendTime)
+		})
+		log.Printf("Read %d archived chat messages for livestream %d", len(archivedMessages), livestreamID)
+	}
 	log.Printf("Fetched %d chat messages for livestream %d", len(chatMessages), livestreamID)
 
+	lists, err := loadChatterLists(ChannelID)
+	if err != nil {
+		return err
+	}
+	offenders, err := loadConfirmedOffenders()
+	if err != nil {
+		log.Printf("Error loading confirmed offenders for livestream %d: %v", livestreamID, err)
+	}
+	chatMessages, exclusion := excludeChatters(chatMessages, lists.Excluded)
+	if exclusion.Messages > 0 {
+		log.Printf("Excluded %d messages of %d listed chatter(s) from livestream %d", exclusion.Messages, exclusion.Chatters, livestreamID)
+	}
+
+	sampling, err := loadMessageSampling(livestreamID, lists.Excluded)
+	if err != nil {
+		log.Printf("Error loading message sampling for livestream %d, treating its messages as complete: %v", livestreamID, err)
+	}
+	if sampling != nil && window.IsZero() {
+		exclusion.Messages = max(exclusion.Messages, sampling.Excluded)
+	} else if sampling != nil {
+		// The exact counters cover the whole stream, a window only gets estimates scaled by its sample ratio
+		sampling = &messageSampling{Messages: sampling.Messages, Persisted: sampling.Persisted}
+	}
+
 	// 3. Fetch all relevant viewer counts for the channel and time range
 	var viewerCounts []models.LivestreamData
 	if err := db.DB.Where("channel_id = ? AND created_at >= ? AND created_at <= ?",
@@ -754,15 +1150,208 @@ func GenerateLivestreamReport(livestreamID uint) error {
 	}
 	log.Printf("Fetched %d viewer count records for channel %d", len(viewerCounts), ChannelID)
 
+	// Shared by the report and, for long streams, each of its chunks
+	var sessionTitle string
+	err = db.DB.Model(&models.LivestreamData{}).Select("session_title").Where("livestream_id = ?", livestreamID).Order("created_at DESC").First(&sessionTitle).Error
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			fmt.Printf("No entry found for LivestreamID: %d\n", livestreamID)
+		} else {
+			fmt.Printf("Error fetching only SessionTitle: %v\n", err)
+		}
+	} else {
+		fmt.Printf("Session Title (only fetched) for LivestreamID %d (last entry): %s\n", livestreamID, sessionTitle)
+	}
+
+	var reactions []models.ReactionEvent
+	if err := db.DB.Where("livestream_id = ?", livestreamID).Order("created_at ASC").Find(&reactions).Error; err != nil {
+		log.Printf("Error fetching reactions for livestream %d: %v", livestreamID, err)
+	}
+
+	var clips []models.KickClip
+	if err := db.DB.Where("livestream_id = ?", livestreamID).Order("shared_at ASC").Find(&clips).Error; err != nil {
+		log.Printf("Error fetching clips for livestream %d: %v", livestreamID, err)
+	}
+	if !window.IsZero() {
+		reactions = reactionsBetween(reactions, reportStartTime, reportEndTime)
+		clips = clipsBetween(clips, reportStartTime, reportEndTime)
+	}
+
+	// Follows are credited to the stream until the attribution window after it closes, a window ends them at its end
+	followersUntil := reportEndTime
+	if window.End == nil {
+		followersUntil = followerAttributionEnd(ChannelID, livestreamID, reportEndTime)
+	}
+	followers, err := loadFollowerSamples(ChannelID, reportStartTime, followersUntil)
+	if err != nil {
+		log.Printf("Error fetching follower counts for livestream %d: %v", livestreamID, err)
+	}
+
+	// Bot probabilities are kept per chatter for the whole stream only, a window would replace them with partial ones
+	senders := make(map[int]struct{})
+	for _, msg := range chatMessages {
+		senders[msg.SenderID] = struct{}{}
+	}
+	senderIDs := make([]int, 0, len(senders))
+	for senderID := range senders {
+		senderIDs = append(senderIDs, senderID)
+	}
+	channelCounts, err := chatterChannelCounts(senderIDs, ChannelID)
+	if err != nil {
+		log.Printf("Error fetching chatter channels for livestream %d: %v", livestreamID, err)
+	}
+	bots := classifyChatters(chatMessages, channelCounts, lists.Trusted)
+	if window.IsZero() {
+		if err := saveChatterBotScores(ChannelID, livestreamID, bots); err != nil {
+			log.Printf("Error saving chatter bot scores: %v", err)
+		}
+	}
+
+	chatConnected, chatPolled, chatTracked, err := loadChatConnections(ChannelID, reportStartTime, reportEndTime)
+	if err != nil {
+		log.Printf("Error fetching chat connections for livestream %d: %v", livestreamID, err)
+	}
+	simulcast, err := loadSimulcast(livestreamID)
+	if err != nil {
+		log.Printf("Error fetching simulcast indicators for livestream %d: %v", livestreamID, err)
+	}
+	streamEndings, err := loadStreamEndings(ChannelID, reportStartTime.Add(-RaidSpikeWindow-RaidAttributionWindow), reportEndTime)
+	if err != nil {
+		log.Printf("Error fetching stream endings for livestream %d: %v", livestreamID, err)
+	}
+
+	input := reportInput{
+		ChannelID:       ChannelID,
+		ChannelUsername: channelUsername,
+		LivestreamID:    livestreamID,
+		Title:           sessionTitle,
+		Category:        livestreamCategory(ChannelID, reportStartTime, reportEndTime),
+		StartTime:       reportStartTime,
+		EndTime:         reportEndTime,
+		ChatMessages:    chatMessages,
+		ViewerCounts:    viewerCounts,
+		Reactions:       reactions,
+		Clips:           clips,
+		Followers:       followers,
+		FollowersUntil:  followersUntil,
+		Sampling:        sampling,
+		Trusted:         lists.Trusted,
+		Offenders:       offenders,
+		Exclusion:       exclusion,
+		Window:          window,
+		ChatConnected:   chatConnected,
+		ChatPolled:      chatPolled,
+		ChatTracked:     chatTracked,
+		Bots:            bots,
+		Simulcast:       simulcast,
+		StreamEndings:   streamEndings,
+		Progress:        progress,
+	}
+
+	if !window.IsZero() {
+		// Kept next to the full report, which stays the one listed, mailed and served publicly
+		progress.expectMessages(len(chatMessages))
+		report, spamReport := buildLivestreamReport(input)
+		progress.setPhase(ReportPhasePersisting)
+		if err := persistWindowReport(window, report, spamReport); err != nil {
+			return err
+		}
+		log.Printf("Successfully generated window report for livestream ID %d%s (Report ID: %s)", livestreamID, window, report.ID.String())
+		return nil
+	}
+
+	if ReportChunkThreshold > 0 && reportEndTime.Sub(reportStartTime) > ReportChunkThreshold {
+		progress.expectMessages(2 * len(chatMessages)) // The parent and the chunks each analyse every message
+		return generateChunkedReports(input)
+	}
+
+	progress.expectMessages(len(chatMessages))
+	report, spamReport := buildLivestreamReport(input)
+	progress.setPhase(ReportPhasePersisting)
+	if err := persistLivestreamReports(ChannelID, livestreamID, []models.LivestreamReport{report}, []models.SpamReport{spamReport}); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully generated spam report for livestream ID %d (Spam Report ID: %s)", livestreamID, spamReport.ID.String())
+
+	go resolveReportVOD(report.ID, channelUsername, livestreamID)
+	go sendReportEmails(report)
+	log.Printf("Successfully generated main livestream report for livestream ID %d (Report ID: %s)", livestreamID, report.ID.String())
+	return nil
+}
+
+// reportInput is the data a livestream report (or one of its chunks) is computed from.
+// livestreamCategory returns the category of the last channel snapshot taken in [start, end], or "" when unknown
+func livestreamCategory(channelID uint, start, end time.Time) string {
+	var category *string
+	err := db.DB.Model(&models.ChannelData{}).
+		Select("data->'livestream'->'categories'->0->>'name'").
+		Where("channel_id = ? AND created_at BETWEEN ? AND ? AND data->'livestream'->'categories'->0->>'name' IS NOT NULL", channelID, start, end).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(&category).Error
+	if err != nil {
+		log.Printf("Error fetching category for channel %d: %v", channelID, err)
+		return ""
+	}
+	if category == nil {
+		return ""
+	}
+	return *category
+}
+
+type reportInput struct {
+	ChannelID       uint
+	ChannelUsername string
+	LivestreamID    uint
+	Title           string
+	Category        string
+	StartTime       time.Time
+	EndTime         time.Time
+	ChatMessages    []models.ChatMessage // Sorted by MessageSendTime
+	ViewerCounts    []models.LivestreamData
+	Reactions       []models.ReactionEvent
+	Clips           []models.KickClip       // Sorted by SharedAt
+	Followers       []followerSample        // Sorted by Time, starting with the last sample at or before StartTime
+	FollowersUntil  time.Time               // Follows up to this time, EndTime plus the attribution window, are credited
+	Sampling        *messageSampling        // Nil unless some of the livestream's messages weren't persisted
+	Trusted         map[int]struct{}        // Sender IDs never reported as suspicious
+	Offenders       map[string]struct{}     // Lowercased usernames confirmed as spammers in the moderation queue
+	Exclusion       chatterExclusion        // Messages of excluded chatters are already left out of ChatMessages
+	Window          ReportWindow            // Requested bounds when the report covers only part of the stream
+	ChatConnected   []timeRange             // Periods the chat was connected between StartTime and EndTime
+	ChatPolled      []timeRange             // Those of them it was only polled, while the WebSocket was blocked
+	ChatTracked     bool                    // False when the stream predates chat connection tracking
+	Bots            map[int]chatterBotScore // Bot probability of each chatter of the stream, by sender ID
+	Simulcast       *SimulcastInfo          // Nil unless the stream was probably also broadcast elsewhere
+	StreamEndings   []streamEnding          // Streams of other monitored channels that went offline around the report
+	Progress        *reportProgress         // Receives the progress of the generation, may be nil
+}
+
+// buildLivestreamReport computes a livestream report and its spam report over the input window.
+// Nothing is persisted.
+func buildLivestreamReport(in reportInput) (models.LivestreamReport, models.SpamReport) {
+	ChannelID := in.ChannelID
+	channelUsername := in.ChannelUsername
+	livestreamID := in.LivestreamID
+	sessionTitle := in.Title
+	reportStartTime, reportEndTime := in.StartTime, in.EndTime
+	chatMessages, viewerCounts, reactions := in.ChatMessages, in.ViewerCounts, in.Reactions
+
+	// Calculate duration in minutes (from reportStartTime to reportEndTime)
+	durationMinutes := int(reportEndTime.Sub(reportStartTime).Minutes())
+
 	metrics := NewReportMetrics()
 
+	in.Progress.setPhase(ReportPhaseAnalysing)
 	messageProcessingChan := make(chan models.ChatMessage, len(chatMessages))
 	for _, msg := range chatMessages {
 		messageProcessingChan <- msg
 	}
 	close(messageProcessingChan)
 
-	numWorkers := 4
+	numWorkers := Capacity.ReportWorkers
 	var wg sync.WaitGroup
 
 	for range make([]struct{}, numWorkers) {
@@ -771,18 +1360,30 @@ func GenerateLivestreamReport(livestreamID uint) error {
 			defer wg.Done()
 			for msg := range messageProcessingChan {
 				processSingleMessage(msg, metrics)
+				in.Progress.messageProcessed()
 			}
 		}()
 	}
 
 	wg.Wait()
+	in.Progress.setPhase(ReportPhaseSpamAnalysis)
 
 	metrics.TotalMessages = len(chatMessages)
 
 	var viewerTimelineJSON []byte
 	var messageTimelineJSON []byte
 
-	metrics.ViewerCountsTimeline = buildViewerCountTimeline(viewerCounts, reportStartTime, reportEndTime)
+	// Reject bogus viewer spikes before building the timeline and analytics, keeping raw values alongside
+	smoothedViewerCounts := smoothViewerCounts(viewerCounts)
+
+	rawViewerTimeline := buildViewerCountTimeline(viewerCounts, reportStartTime, reportEndTime)
+	rawViewerTimelineJSON, err := json.Marshal(rawViewerTimeline)
+	if err != nil {
+		log.Printf("Error marshalling raw viewer counts timeline for livestream %d: %v", livestreamID, err)
+		rawViewerTimelineJSON = []byte("[]")
+	}
+
+	metrics.ViewerCountsTimeline = buildViewerCountTimeline(smoothedViewerCounts, reportStartTime, reportEndTime)
 	viewerTimelineJSON, err = json.Marshal(metrics.ViewerCountsTimeline) // Assign here
 	if err != nil {
 		log.Printf("Error marshalling viewer counts timeline for livestream %d: %v", livestreamID, err)
@@ -790,25 +1391,95 @@ func GenerateLivestreamReport(livestreamID uint) error {
 	}
 
 	metrics.MessageCountsTimeline = buildMessageCountTimeline(chatMessages, reportStartTime, reportEndTime)
+	if in.Sampling != nil {
+		for i := range metrics.MessageCountsTimeline {
+			metrics.MessageCountsTimeline[i].Count = in.Sampling.scale(metrics.MessageCountsTimeline[i].Count)
+		}
+	}
 	messageTimelineJSON, err = json.Marshal(metrics.MessageCountsTimeline) // Assign here
 	if err != nil {
 		log.Printf("Error marshalling message counts timeline for livestream %d: %v", livestreamID, err)
 		messageTimelineJSON = []byte("[]")
 	}
 
-	averageViewers, peakViewers, lowestViewers := calculateViewerAnalytics(viewerCounts)
-
-	engagement := 0.0
-	if averageViewers > 0 {
-		engagement = (float64(len(metrics.UniqueChatters)) / float64(averageViewers)) * 100.0
+	viewerBots := analyzeViewerBots(metrics.ViewerCountsTimeline, metrics.MessageCountsTimeline)
+	viewerBotsJSON, err := json.Marshal(viewerBots)
+	if err != nil {
+		log.Printf("Error marshalling viewer bot analysis for livestream %d: %v", livestreamID, err)
+		viewerBotsJSON = []byte("{}")
 	}
 
+	averageViewers, peakViewers, lowestViewers := calculateViewerAnalytics(smoothedViewerCounts)
+	rawAverageViewers, rawPeakViewers, _ := calculateViewerAnalytics(viewerCounts)
+
 	// --- Spam Analysis - Post-processing after all messages have been individually processed ---
 	userMessageHistory := make(map[int][]models.ChatMessage)
 	for _, msg := range chatMessages {
 		userMessageHistory[msg.SenderID] = append(userMessageHistory[msg.SenderID], msg)
 	}
 
+	messagesPerChatter := make(map[int]int, len(userMessageHistory))
+	for senderID, msgs := range userMessageHistory {
+		messagesPerChatter[senderID] = len(msgs)
+	}
+
+	// Sampled streams take their totals from the exact counters, chunks scale theirs by the stream's sample ratio
+	totalMessages, uniqueChatters := metrics.TotalMessages, len(metrics.UniqueChatters)
+	if sampling := in.Sampling; sampling != nil {
+		if sampling.PerChatter != nil {
+			messagesPerChatter = sampling.PerChatter
+			uniqueChatters = max(uniqueChatters, len(sampling.PerChatter))
+			totalMessages = sampling.Messages + max(0, totalMessages-sampling.Persisted) // Reassociated messages are persisted but never counted
+		} else {
+			totalMessages = sampling.scale(totalMessages)
+		}
+	}
+	concentration := calculateChatConcentration(messagesPerChatter)
+
+	audienceJSON, err := json.Marshal(calculateAudienceComposition(chatMessages))
+	if err != nil {
+		log.Printf("Error marshalling audience composition for livestream %d: %v", livestreamID, err)
+		audienceJSON = []byte("{}")
+	}
+	languagesJSON, err := json.Marshal(analyzeChatLanguages(chatMessages, viewerCounts))
+	if err != nil {
+		log.Printf("Error marshalling chat languages for livestream %d: %v", livestreamID, err)
+		languagesJSON = []byte("{}")
+	}
+	qualityJSON, err := json.Marshal(assessReportQuality(qualityInput{
+		Start:         reportStartTime,
+		End:           reportEndTime,
+		ViewerCounts:  smoothedViewerCounts,
+		Average:       averageViewers,
+		Peak:          peakViewers,
+		Lowest:        lowestViewers,
+		RawPeak:       rawPeakViewers,
+		ChatConnected: in.ChatConnected,
+		ChatPolled:    in.ChatPolled,
+		ChatTracked:   in.ChatTracked,
+	}))
+	if err != nil {
+		log.Printf("Error marshalling report quality for livestream %d: %v", livestreamID, err)
+		qualityJSON = []byte("{}")
+	}
+	milestonesJSON, err := json.Marshal(detectViewerMilestones(smoothedViewerCounts, reportStartTime, reportEndTime))
+	if err != nil {
+		log.Printf("Error marshalling viewer milestones for livestream %d: %v", livestreamID, err)
+		milestonesJSON = []byte("[]")
+	}
+	raidSpikesJSON, err := json.Marshal(detectRaidSpikes(smoothedViewerCounts, reactions, in.StreamEndings, reportStartTime, reportEndTime))
+	if err != nil {
+		log.Printf("Error marshalling raid spikes for livestream %d: %v", livestreamID, err)
+		raidSpikesJSON = []byte("[]")
+	}
+	var simulcastJSON []byte
+	if in.Simulcast != nil {
+		if simulcastJSON, err = json.Marshal(in.Simulcast); err != nil {
+			log.Printf("Error marshalling simulcast for livestream %d: %v", livestreamID, err)
+			simulcastJSON = nil
+		}
+	}
+
 	for _, messages := range userMessageHistory {
 		sort.Slice(messages, func(i, j int) bool {
 			return messages[i].MessageSendTime.Before(messages[j].MessageSendTime)
@@ -829,6 +1500,7 @@ func GenerateLivestreamReport(livestreamID uint) error {
 
 			if exactBurstCount >= ExactDuplicateBurstMinCount {
 				metrics.Lock()
+				metrics.recordSuspicionSignal(currentMsg.SenderID, IssueExactDuplicateBursts)
 				metrics.ExactDuplicateBursts = append(metrics.ExactDuplicateBursts, ExactDuplicateBurstReport{
 					Username:   currentMsg.SenderUsername,
 					Content:    currentMsg.Message,
@@ -857,6 +1529,7 @@ func GenerateLivestreamReport(livestreamID uint) error {
 
 			if similarBurstCount >= SimilarMessageBurstMinCount {
 				metrics.Lock()
+				metrics.recordSuspicionSignal(currentMsg.SenderID, IssueSimilarMessageBursts)
 				metrics.SimilarMessageBursts = append(metrics.SimilarMessageBursts, SimilarMessageBurstReport{
 					Username:   currentMsg.SenderUsername,
 					Pattern:    strings.Join(util.UniqueStrings(similarMessagesInBurst), " / "),
@@ -882,20 +1555,21 @@ func GenerateLivestreamReport(livestreamID uint) error {
 
 			if rapidBurstCount >= RapidMessageBurstMinCount {
 				metrics.Lock()
+				metrics.recordSuspicionSignal(currentMsg.SenderID, IssueRapidMessageBursts)
 				if _, ok := metrics.SuspiciousChattersMap[currentMsg.SenderID]; !ok {
 					metrics.SuspiciousChattersMap[currentMsg.SenderID] = struct{}{}
 					metrics.SuspiciousChattersList = append(metrics.SuspiciousChattersList, SuspiciousChatterReport{
 						UserID:            currentMsg.SenderID,
 						Username:          currentMsg.SenderUsername,
-						PotentialIssues:   []string{"rapid_message_bursts"},
+						PotentialIssues:   []string{IssueRapidMessageBursts},
 						MessageTimestamps: util.UniqueSortedTimes(burstTimestamps),
 						ExampleMessages:   util.UniqueStrings(exampleMessages),
 					})
 				} else {
 					for k := range metrics.SuspiciousChattersList {
 						if metrics.SuspiciousChattersList[k].UserID == currentMsg.SenderID {
-							if !util.ContainsString(metrics.SuspiciousChattersList[k].PotentialIssues, "rapid_message_bursts") {
-								metrics.SuspiciousChattersList[k].PotentialIssues = append(metrics.SuspiciousChattersList[k].PotentialIssues, "rapid_message_bursts")
+							if !util.ContainsString(metrics.SuspiciousChattersList[k].PotentialIssues, IssueRapidMessageBursts) {
+								metrics.SuspiciousChattersList[k].PotentialIssues = append(metrics.SuspiciousChattersList[k].PotentialIssues, IssueRapidMessageBursts)
 							}
 							metrics.SuspiciousChattersList[k].MessageTimestamps = util.UniqueSortedTimes(append(metrics.SuspiciousChattersList[k].MessageTimestamps, burstTimestamps...))
 							metrics.SuspiciousChattersList[k].ExampleMessages = util.UniqueStrings(append(metrics.SuspiciousChattersList[k].ExampleMessages, exampleMessages...))
@@ -915,20 +1589,21 @@ func GenerateLivestreamReport(livestreamID uint) error {
 			usernameToCheck := msgs[0].SenderUsername
 			if suspiciousUsernameChecker.MatchString(usernameToCheck) {
 				metrics.Lock()
+				metrics.recordSuspicionSignal(userID, IssueSuspiciousUsername)
 				if _, ok := metrics.SuspiciousChattersMap[userID]; !ok {
 					metrics.SuspiciousChattersMap[userID] = struct{}{}
 					metrics.SuspiciousChattersList = append(metrics.SuspiciousChattersList, SuspiciousChatterReport{
 						UserID:            userID,
 						Username:          usernameToCheck,
-						PotentialIssues:   []string{"suspicious_username"},
+						PotentialIssues:   []string{IssueSuspiciousUsername},
 						MessageTimestamps: []time.Time{},
 						ExampleMessages:   []string{},
 					})
 				} else {
 					for k := range metrics.SuspiciousChattersList {
 						if metrics.SuspiciousChattersList[k].UserID == userID {
-							if !util.ContainsString(metrics.SuspiciousChattersList[k].PotentialIssues, "suspicious_username") {
-								metrics.SuspiciousChattersList[k].PotentialIssues = append(metrics.SuspiciousChattersList[k].PotentialIssues, "suspicious_username")
+							if !util.ContainsString(metrics.SuspiciousChattersList[k].PotentialIssues, IssueSuspiciousUsername) {
+								metrics.SuspiciousChattersList[k].PotentialIssues = append(metrics.SuspiciousChattersList[k].PotentialIssues, IssueSuspiciousUsername)
 							}
 							break
 						}
@@ -939,6 +1614,31 @@ func GenerateLivestreamReport(livestreamID uint) error {
 		}
 	}
 
+	// Chatters moderators flagged during the stream
+	for userID, msgs := range userMessageHistory {
+		if slices.ContainsFunc(msgs, func(msg models.ChatMessage) bool { return msg.Flagged }) {
+			metrics.recordSuspicionSignal(userID, IssueFlaggedByModerator)
+		}
+	}
+
+	// Chatters analysts confirmed as spammers before, here or on another channel
+	for userID, msgs := range userMessageHistory {
+		if _, ok := in.Offenders[strings.ToLower(msgs[0].SenderUsername)]; ok {
+			metrics.recordSuspicionSignal(userID, IssueConfirmedOffender)
+		}
+	}
+
+	// Each abusive message is an occurrence, capped like every other signal
+	unicodeAbuse, unicodeOffenders := detectUnicodeAbuse(chatMessages, in.Trusted)
+	for senderID, count := range unicodeOffenders {
+		for range count {
+			metrics.recordSuspicionSignal(senderID, IssueUnicodeAbuse)
+		}
+	}
+
+	dropTrustedChatters(metrics, in.Trusted)
+	metrics.SuspiciousChattersList = scoreSuspiciousChatters(metrics, userMessageHistory)
+
 	// Sort bursts by count (higher count first)
 	sort.Slice(metrics.ExactDuplicateBursts, func(i, j int) bool {
 		return metrics.ExactDuplicateBursts[i].Count > metrics.ExactDuplicateBursts[j].Count
@@ -947,10 +1647,13 @@ func GenerateLivestreamReport(livestreamID uint) error {
 		return metrics.SimilarMessageBursts[i].Count > metrics.SimilarMessageBursts[j].Count
 	})
 
-	// Create Spam Report							ID: string(report.ID),
+	// IDs are assigned up front so both rows can reference each other in a single transaction
+	reportID := uuid.New()
+
+	// Create Spam Report
 	spamReport := models.SpamReport{
 		ID:                 uuid.New(),
-		LivestreamReportID: uuid.Nil, // Will be set after livestream report is created
+		LivestreamReportID: reportID,
 		ChannelID:          ChannelID,
 		LivestreamID:       livestreamID,
 		CreatedAt:          time.Now(),
@@ -988,37 +1691,92 @@ func GenerateLivestreamReport(livestreamID uint) error {
 	}
 	spamReport.SuspiciousChatters = suspiciousChattersJSON
 
+	crossUserCopypastaJSON, err := json.Marshal(detectCrossUserCopypasta(chatMessages))
+	if err != nil {
+		log.Printf("Error marshalling cross-user copypasta for spam report: %v", err)
+		crossUserCopypastaJSON = []byte("[]")
+	}
+	spamReport.CrossUserCopypasta = crossUserCopypastaJSON
+
+	altClustersJSON, err := json.Marshal(detectAltClusters(chatMessages, in.Trusted))
+	if err != nil {
+		log.Printf("Error marshalling alt clusters for spam report: %v", err)
+		altClustersJSON = []byte("[]")
+	}
+	spamReport.AltClusters = altClustersJSON
+
+	unicodeAbuseJSON, err := json.Marshal(unicodeAbuse)
+	if err != nil {
+		log.Printf("Error marshalling unicode abuse for spam report: %v", err)
+		unicodeAbuseJSON = []byte("{}")
+	}
+	spamReport.UnicodeAbuse = unicodeAbuseJSON
+
+	emoteBurstsJSON, err := json.Marshal(classifyEmoteBursts(chatMessages, smoothedViewerCounts, reactions, in.Clips))
+	if err != nil {
+		log.Printf("Error marshalling emote bursts for spam report: %v", err)
+		emoteBurstsJSON = []byte("{}")
+	}
+	spamReport.EmoteBursts = emoteBurstsJSON
+
 	spamReport.RepetitivePhrasesCount = 0 // Placeholder
 
 	// Moved emote counts to spam report
 	spamReport.MessagesWithEmotes = metrics.MessagesWithEmotes
 	spamReport.MessagesMultipleEmotesOnly = metrics.MessagesMultipleEmotesOnly
 
-	if err := db.DB.Create(&spamReport).Error; err != nil {
-		return fmt.Errorf("failed to save spam report for %d: %w", livestreamID, err)
+	// The raw sample series (HTTP fetches merged with Pusher updates) has the highest resolution. Hours watched is
+	// the ACV over the whole report, so the time between samples counts at the average rather than not at all.
+	acv, viewersTracked := calculateACV(smoothedViewerCounts, reportEndTime)
+	hoursWatched := acv * reportEndTime.Sub(reportStartTime).Hours()
+	if hoursWatched == 0 {
+		hoursWatched = CalculateWatchHours(metrics.ViewerCountsTimeline)
 	}
-	log.Printf("Successfully generated spam report for livestream ID %d (Spam Report ID: %s)", livestreamID, spamReport.ID.String())
 
-	var sessionTitle string
-	err = db.DB.Model(&models.LivestreamData{}).Select("session_title").Where("livestream_id = ?", livestreamID).Order("created_at DESC").First(&sessionTitle).Error
+	reactionsJSON, err := json.Marshal(buildReactionsReport(reactions, chatMessages, smoothedViewerCounts))
+	if err != nil {
+		log.Printf("Error marshalling reactions for livestream %d: %v", livestreamID, err)
+		reactionsJSON = []byte("{}")
+	}
 
+	clipsJSON, err := json.Marshal(buildClipsCreated(in.Clips, channelUsername, chatMessages, smoothedViewerCounts, reportStartTime, reportEndTime))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			fmt.Printf("No entry found for LivestreamID: %d\n", livestreamID)
-		} else {
-			fmt.Printf("Error fetching only SessionTitle: %v\n", err)
-		}
-	} else {
-		fmt.Printf("Session Title (only fetched) for LivestreamID %d (last entry): %s\n", livestreamID, sessionTitle)
+		log.Printf("Error marshalling clips for livestream %d: %v", livestreamID, err)
+		clipsJSON = []byte("[]")
+	}
+
+	questionsJSON, err := json.Marshal(buildQuestionStats(chatMessages, channelUsername, reportEndTime.Sub(reportStartTime)))
+	if err != nil {
+		log.Printf("Error marshalling question stats for livestream %d: %v", livestreamID, err)
+		questionsJSON = []byte("{}")
+	}
+
+	customMetrics := buildCustomMetrics(reportID, in)
+
+	ingestionLag := measureIngestionLag(chatMessages)
+	if ingestionLag.Flagged {
+		log.Printf("Livestream %d was ingested with lag: p50 %.2fs, p95 %.2fs", livestreamID, ingestionLag.P50, ingestionLag.P95)
+	}
+
+	gained, _ := followersGained(in.Followers, reportStartTime, in.FollowersUntil)
+	var followersPerHourWatched float64
+	if hoursWatched > 0 {
+		followersPerHourWatched = float64(gained) / hoursWatched
 	}
 
-	hoursWatched := CalculateWatchHours(metrics.ViewerCountsTimeline)
+	engagementScores := calculateEngagement(chatMessages, uniqueChatters, acv, peakViewers, hoursWatched)
+	if in.Sampling != nil && hoursWatched > 0 {
+		engagementScores.MessagesPerViewerHr = float64(totalMessages) / hoursWatched
+	}
+	humanShare, likelyBots := botAdjustedShare(chatMessages, in.Bots)
+	engagementScores.BotAdjusted = engagementScores.ChattersPerAverage * humanShare
 
 	// Create Main Livestream Report
 	report := models.LivestreamReport{
-		ID:              uuid.New(),
+		ID:              reportID,
 		LivestreamID:    livestreamID,
 		Title:           sessionTitle,
+		Category:        in.Category,
 		ChannelID:       ChannelID,
 		Username:        channelUsername,
 		ReportStartTime: reportStartTime,
@@ -1026,39 +1784,101 @@ func GenerateLivestreamReport(livestreamID uint) error {
 		DurationMinutes: durationMinutes,
 
 		// Viewer Analytics
-		AverageViewers:   averageViewers,
-		PeakViewers:      peakViewers,
-		LowestViewers:    lowestViewers,
-		Engagement:       engagement,
-		HoursWatched:     hoursWatched,
-		TotalMessages:    metrics.TotalMessages,
-		UniqueChatters:   len(metrics.UniqueChatters),
-		MessagesFromApps: metrics.MessagesFromApps,
+		AverageViewers:    averageViewers,
+		PeakViewers:       peakViewers,
+		LowestViewers:     lowestViewers,
+		Engagement:        engagementScores.Selected(),
+		HoursWatched:      hoursWatched,
+		RawAverageViewers: rawAverageViewers,
+
+		AverageConcurrentViewers: roundTo(acv, 2),
+		ViewerTrackedSeconds:     int(viewersTracked.Seconds()),
+		RawPeakViewers:           rawPeakViewers,
+		TotalMessages:            totalMessages,
+		UniqueChatters:           uniqueChatters,
+		MessagesFromApps:         metrics.MessagesFromApps,
+
+		FollowersGained:         gained,
+		FollowersPerHourWatched: followersPerHourWatched,
+
+		// Engagement variants
+		EngagementFormula:             EngagementFormula,
+		EngagementChattersPerAverage:  engagementScores.ChattersPerAverage,
+		EngagementMessagesPerViewerHr: engagementScores.MessagesPerViewerHr,
+		EngagementChattersPerPeak:     engagementScores.ChattersPerPeak,
+		EngagementQualityWeighted:     engagementScores.QualityWeighted,
+		EngagementBotAdjusted:         engagementScores.BotAdjusted,
+		LikelyBotChatters:             likelyBots,
+
+		// Chat Concentration
+		TopOnePercentShare: concentration.TopOnePercentShare,
+		TopTenPercentShare: concentration.TopTenPercentShare,
+		ChatGini:           concentration.Gini,
 
 		SpamReportID: &spamReport.ID,
 
-		ViewerCountsTimeline:  viewerTimelineJSON,
-		MessageCountsTimeline: messageTimelineJSON,
+		ViewerCountsTimeline:    viewerTimelineJSON,
+		RawViewerCountsTimeline: rawViewerTimelineJSON,
+		MessageCountsTimeline:   messageTimelineJSON,
+
+		Reactions:           reactionsJSON,
+		AudienceComposition: audienceJSON,
+		ClipsCreated:        clipsJSON,
+		QuestionStats:       questionsJSON,
+		CustomMetrics:       customMetrics,
+		ViewerBotAnalysis:   viewerBotsJSON,
+		ViewerBotSuspected:  viewerBots.Suspected,
+		ChatLanguages:       languagesJSON,
+		Quality:             qualityJSON,
+		Simulcast:           in.Simulcast != nil,
+		SimulcastInfo:       simulcastJSON,
+		ViewerMilestones:    milestonesJSON,
+		RaidSpikes:          raidSpikesJSON,
+
+		Sampled:           in.Sampling != nil,
+		SampleRate:        in.Sampling.Ratio(),
+		PersistedMessages: len(chatMessages),
+
+		ExcludedChatters: in.Exclusion.Chatters,
+		ExcludedMessages: in.Exclusion.Messages,
+
+		IngestionLagP50: ingestionLag.P50,
+		IngestionLagP95: ingestionLag.P95,
+		IngestionLagged: ingestionLag.Flagged,
+
+		WindowStart: in.Window.Start,
+		WindowEnd:   in.Window.End,
 
 		CreatedAt: time.Now(),
 	}
 
-	if err := db.DB.Create(&report).Error; err != nil {
-		return fmt.Errorf("failed to save livestream report for %d: %w", livestreamID, err)
-	}
-
-	err = UpdateStreamerProfileLivestreams(ChannelID, report.ID)
-	if err != nil {
-		log.Printf("ERROR: Failed to update streamer profile with new report UUID %s for channel %d: %v", report.ID.String(), ChannelID, err)
-	}
-
-	spamReport.LivestreamReportID = report.ID
-	if err := db.DB.Save(&spamReport).Error; err != nil {
-		log.Printf("Warning: Failed to update spam_report %s with livestream_report_id %s: %v", spamReport.ID.String(), report.ID.String(), err)
-	}
+	return report, spamReport
+}
 
-	log.Printf("Successfully generated main livestream report for livestream ID %d (Report ID: %s)", livestreamID, report.ID.String())
-	return nil
+// persistLivestreamReports saves reports and their spam reports atomically. Regenerating a report replaces the
+// previous ones for the livestream, so retries after a failure never leave duplicates or orphan rows behind.
+// Only the first report is listed on the streamer profile; the others are chunks of it.
+func persistLivestreamReports(ChannelID uint, livestreamID uint, reports []models.LivestreamReport, spamReports []models.SpamReport) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteLivestreamReports(tx, ChannelID, livestreamID); err != nil {
+			return err
+		}
+		for i := range reports {
+			if err := tx.Create(&spamReports[i]).Error; err != nil {
+				return fmt.Errorf("failed to save spam report for %d: %w", livestreamID, err)
+			}
+			if err := tx.Create(&reports[i]).Error; err != nil {
+				return fmt.Errorf("failed to save livestream report for %d: %w", livestreamID, err)
+			}
+			if err := saveSpamIncidents(tx, reports[i], spamReports[i]); err != nil {
+				return err
+			}
+		}
+		if err := UpdateStreamerProfileLivestreams(tx, ChannelID, reports[0].ID); err != nil {
+			return fmt.Errorf("failed to update streamer profile with report %s: %w", reports[0].ID.String(), err)
+		}
+		return nil
+	})
 }
 
 func processSingleMessage(msg models.ChatMessage, metrics *ReportMetrics) {
@@ -1138,7 +1958,7 @@ func buildLivestreamsList(channel *models.MonitoredChannel) []uuid.UUID {
 	var livestreamReports []uuid.UUID
 	if err := db.DB.Model(&models.LivestreamReport{}).
 		Select("id").
-		Where("channel_id = ?", channel.ChannelID).
+		Where("channel_id = ? AND parent_report_id IS NULL", channel.ChannelID).
 		Pluck("id", &livestreamReports).Error; err != nil {
 		log.Printf("Failed to fetch livestream IDs for channel %d: %v", channel.ChannelID, err)
 	}
@@ -1209,12 +2029,14 @@ func CalculateWatchHours(points []ViewerCountPoint) float64 {
 	return totalSeconds / 3600.0
 }
 
-func streamerProfileBuilder(channel *models.MonitoredChannel, kickData KickChannelResponse) error {
+// streamerProfileBuilder updates the profile of a channel from the snapshot fetched at snapshotTime. The followers
+// timeline is only appended to, its work per fetch stays constant however long the channel has been monitored.
+func streamerProfileBuilder(channel *models.MonitoredChannel, kickData KickChannelResponse, snapshotTime time.Time) error {
 	log.Println("Streamer profile builder Ran for:", channel.Username)
 	var profile models.StreamerProfile
 	var existingProfile models.StreamerProfile
 
-	result := db.DB.Where("channel_id = ?", channel.ChannelID).First(&existingProfile)
+	result := db.DB.Omit("followers_count").Where("channel_id = ?", channel.ChannelID).First(&existingProfile)
 
 	if result.Error == nil {
 		profile = existingProfile // If exists, load existing data
@@ -1224,8 +2046,49 @@ func streamerProfileBuilder(channel *models.MonitoredChannel, kickData KickChann
 		profile.ChannelID = channel.ChannelID
 	}
 
-	// Populate common fields
 	profile.Username = channel.Username
+	populateProfileAttributes(&profile, kickData)
+
+	followersPoint := models.FollowersCountPoint{Time: snapshotTime.UTC(), Count: kickData.FollowersCount}
+
+	livestreamList := buildLivestreamsList(channel)
+	livestreamListJSON, err := json.Marshal(livestreamList)
+	if err != nil {
+		log.Fatalf("Error: failed to marshal livestreamList %v", err)
+	}
+
+	// asign livestream list to profile
+	profile.Livestreams = livestreamListJSON
+
+	// Save/Update the StreamerProfile
+	if result.Error == nil {
+		if err := db.DB.Omit("followers_count", "followers_backfilled_at").Save(&profile).Error; err != nil {
+			return fmt.Errorf("failed to update streamer profile for channel %d: %w", channel.ChannelID, err)
+		}
+		if err := appendFollowersPoint(channel.ChannelID, followersPoint); err != nil {
+			return err
+		}
+		log.Printf("Updated streamer profile for channel %s (ID: %d)", channel.Username, channel.ChannelID)
+	} else {
+		followersTimelineJSON, err := json.Marshal([]models.FollowersCountPoint{followersPoint})
+		if err != nil {
+			return fmt.Errorf("failed to marshal followers timeline for channel %d: %w", channel.ChannelID, err)
+		}
+		profile.FollowersCount = followersTimelineJSON
+		backfilledAt := time.Now().UTC() // A new profile starts its timeline with this snapshot
+		profile.FollowersBackfilledAt = &backfilledAt
+		if err := db.DB.Create(&profile).Error; err != nil {
+			return fmt.Errorf("failed to create streamer profile for channel %d: %w", channel.ChannelID, err)
+		}
+		log.Printf("Created streamer profile for channel %s (ID: %d)", channel.Username, channel.ChannelID)
+	}
+
+	return nil
+}
+
+// populateProfileAttributes sets the profile attributes (badges, bio, location, socials) from a channel snapshot
+func populateProfileAttributes(profile *models.StreamerProfile, kickData KickChannelResponse) {
+	// Populate common fields
 	profile.Verified = kickData.Verified
 	profile.IsBanned = kickData.IsBanned
 	profile.VodEnabled = kickData.VodEnabled
@@ -1261,7 +2124,7 @@ func streamerProfileBuilder(channel *models.MonitoredChannel, kickData KickChann
 		profile.Instagram = kickData.User.Instagram
 		profile.ProfilePic = kickData.User.ProfilePic
 	} else {
-		log.Printf("Warning: KickChannelResponse.User is nil for channel %d. Some profile fields will be empty.", channel.ChannelID)
+		log.Printf("Warning: KickChannelResponse.User is nil for channel %d. Some profile fields will be empty.", profile.ChannelID)
 		// Clear fields if User is nil and this is an update
 		profile.Bio = ""
 		profile.Country = ""
@@ -1275,66 +2138,82 @@ func streamerProfileBuilder(channel *models.MonitoredChannel, kickData KickChann
 		profile.Instagram = ""
 		profile.ProfilePic = ""
 	}
+}
 
-	// Build followers_count timeline from all historical channel_data
-	var allChannelData []models.ChannelData
-	if err := db.DB.Where("channel_id = ?", channel.ChannelID).Order("created_at ASC").Find(&allChannelData).Error; err != nil {
-		log.Printf("Warning: Failed to fetch historical channel_data for followers timeline for channel %d: %v", channel.ChannelID, err)
-		emptyJsonArray, err := json.Marshal([]models.FollowersCountPoint{})
-		if err != nil {
-			log.Fatalf("failed to create an empty array:%v", err)
-		}
-		profile.FollowersCount = emptyJsonArray
-	} else {
-		followersTimeline := make([]models.FollowersCountPoint, 0, len(allChannelData))
-		for _, cd := range allChannelData {
-			var historicalKickResponse KickChannelResponse
-			if err := json.Unmarshal(cd.Data, &historicalKickResponse); err != nil {
-				log.Printf("Warning: Error unmarshalling historical channel_data for followers timeline for channel %d (ID: %s): %v", channel.ChannelID, cd.ID.String(), err)
-				continue
-			}
-			followersTimeline = append(followersTimeline, models.FollowersCountPoint{
-				Time:  cd.CreatedAt,
-				Count: historicalKickResponse.FollowersCount,
-			})
-		}
-
-		followersTimelineJSON, err := json.Marshal(followersTimeline)
-		if err != nil {
-			log.Fatalf("Error: failed to marshal followersTimeline %v", err)
-		}
+// copyProfileAttributes copies the attributes set by populateProfileAttributes to the API profile
+func copyProfileAttributes(apiProfile *StreamerProfileAPI, dbProfile models.StreamerProfile) {
+	apiProfile.Verified = dbProfile.Verified
+	apiProfile.IsBanned = dbProfile.IsBanned
+	apiProfile.VodEnabled = dbProfile.VodEnabled
+	apiProfile.IsAffiliate = dbProfile.IsAffiliate
+	apiProfile.SubscriptionEnabled = dbProfile.SubscriptionEnabled
+	apiProfile.Bio = dbProfile.Bio
+	apiProfile.City = dbProfile.City
+	apiProfile.State = dbProfile.State
+	apiProfile.TikTok = dbProfile.TikTok
+	apiProfile.Country = dbProfile.Country
+	apiProfile.Discord = dbProfile.Discord
+	apiProfile.Twitter = dbProfile.Twitter
+	apiProfile.YouTube = dbProfile.YouTube
+	apiProfile.Facebook = dbProfile.Facebook
+	apiProfile.Instagram = dbProfile.Instagram
+	apiProfile.ProfilePic = dbProfile.ProfilePic
+}
 
-		profile.FollowersCount = followersTimelineJSON // Assign directly, GORM handles JSONB
+// deleteLivestreamReports removes previously generated reports (and their spam reports) for a livestream
+// and drops them from the streamer profile. Must run inside the report persistence transaction.
+func deleteLivestreamReports(tx *gorm.DB, ChannelID uint, livestreamID uint) error {
+	var existingIDs []uuid.UUID
+	if err := tx.Model(&models.LivestreamReport{}).Where("livestream_id = ?", livestreamID).Pluck("id", &existingIDs).Error; err != nil {
+		return fmt.Errorf("failed to look up existing reports for livestream %d: %w", livestreamID, err)
 	}
-
-	livestreamList := buildLivestreamsList(channel)
-	livestreamListJSON, err := json.Marshal(livestreamList)
-	if err != nil {
-		log.Fatalf("Error: failed to marshal livestreamList %v", err)
+	if len(existingIDs) == 0 {
+		return nil
 	}
 
-	// asign livestream list to profile
-	profile.Livestreams = livestreamListJSON
+	if err := tx.Where("livestream_id = ?", livestreamID).Delete(&models.SpamIncident{}).Error; err != nil {
+		return fmt.Errorf("failed to delete existing spam incidents for livestream %d: %w", livestreamID, err)
+	}
+	if err := tx.Where("livestream_id = ?", livestreamID).Delete(&models.SpamReport{}).Error; err != nil {
+		return fmt.Errorf("failed to delete existing spam reports for livestream %d: %w", livestreamID, err)
+	}
+	if err := tx.Where("livestream_id = ?", livestreamID).Delete(&models.LivestreamReport{}).Error; err != nil {
+		return fmt.Errorf("failed to delete existing reports for livestream %d: %w", livestreamID, err)
+	}
 
-	// Save/Update the StreamerProfile
-	if result.Error == nil {
-		if err := db.DB.Save(&profile).Error; err != nil {
-			return fmt.Errorf("failed to update streamer profile for channel %d: %w", channel.ChannelID, err)
+	var profile models.StreamerProfile
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("channel_id = ?", ChannelID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
 		}
-		log.Printf("Updated streamer profile for channel %s (ID: %d)", channel.Username, channel.ChannelID)
-	} else {
-		if err := db.DB.Create(&profile).Error; err != nil {
-			return fmt.Errorf("failed to create streamer profile for channel %d: %w", channel.ChannelID, err)
+		return fmt.Errorf("failed to fetch streamer profile for channel %d: %w", ChannelID, err)
+	}
+
+	var nativeLivestreams []uuid.UUID
+	if len(profile.Livestreams) > 0 {
+		if err := json.Unmarshal(profile.Livestreams, &nativeLivestreams); err != nil {
+			return fmt.Errorf("failed to unmarshal existing Livestreams for channel %d: %w", ChannelID, err)
 		}
-		log.Printf("Created streamer profile for channel %s (ID: %d)", channel.Username, channel.ChannelID)
+	}
+	nativeLivestreams = slices.DeleteFunc(nativeLivestreams, func(id uuid.UUID) bool {
+		return slices.Contains(existingIDs, id)
+	})
+
+	nativeLivestreamsJSON, err := json.Marshal(nativeLivestreams)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated Livestreams for channel %d: %w", ChannelID, err)
+	}
+	if err := tx.Model(&profile).Update("livestreams", nativeLivestreamsJSON).Error; err != nil {
+		return fmt.Errorf("failed to update streamer profile livestreams for channel %d: %w", ChannelID, err)
 	}
 
+	log.Printf("Replaced %d previous report(s) for livestream %d", len(existingIDs), livestreamID)
 	return nil
 }
 
-func UpdateStreamerProfileLivestreams(ChannelID uint, newReportUUID uuid.UUID) error {
+func UpdateStreamerProfileLivestreams(tx *gorm.DB, ChannelID uint, newReportUUID uuid.UUID) error {
 	var profile models.StreamerProfile
-	if err := db.DB.Where("channel_id = ?", ChannelID).First(&profile).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("channel_id = ?", ChannelID).First(&profile).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("Warning: Streamer profile not found for channel %d. Cannot update livestreams list. Creating empty profile.", ChannelID)
 			return nil
@@ -1344,8 +2223,10 @@ func UpdateStreamerProfileLivestreams(ChannelID uint, newReportUUID uuid.UUID) e
 
 	// Profile found, unmarshal current Livestreams from []byte to Go-native slice
 	var nativeLivestreams []uuid.UUID
-	if err := json.Unmarshal(profile.Livestreams, &nativeLivestreams); err != nil {
-		return fmt.Errorf("failed to unmarshal existing Livestreams for channel %d: %w", ChannelID, err)
+	if len(profile.Livestreams) > 0 {
+		if err := json.Unmarshal(profile.Livestreams, &nativeLivestreams); err != nil {
+			return fmt.Errorf("failed to unmarshal existing Livestreams for channel %d: %w", ChannelID, err)
+		}
 	}
 
 	// Check if the UUID is already in the list to prevent duplicates
@@ -1365,7 +2246,7 @@ func UpdateStreamerProfileLivestreams(ChannelID uint, newReportUUID uuid.UUID) e
 	// assign livestream list to profile
 	profile.Livestreams = nativeLivestreamsJSON
 
-	if err := db.DB.Save(&profile).Error; err != nil {
+	if err := tx.Save(&profile).Error; err != nil {
 		return fmt.Errorf("failed to update streamer profile livestreams for channel %d: %w", ChannelID, err)
 	}
 	log.Printf("Added livestream report UUID %s to profile for channel %d", newReportUUID.String(), ChannelID)
@@ -1376,29 +2257,20 @@ func GetStreamerProfile(username string) (StreamerProfileAPI, error) {
 	var apiProfile StreamerProfileAPI
 
 	var dbProfile models.StreamerProfile
-	if err := db.DB.Where("username = ?", username).First(&dbProfile).Error; err != nil {
+	if err := db.Reader().Where("username = ?", ResolveUsername(username)).First(&dbProfile).Error; err != nil {
 		return StreamerProfileAPI{}, fmt.Errorf("failed to fetch StreamerProfile from DB for channel %v: %w", username, err)
 	}
 
 	// Copy direct fields (these are already non-JSONB)
 	apiProfile.ChannelID = dbProfile.ChannelID
 	apiProfile.Username = dbProfile.Username
-	apiProfile.Verified = dbProfile.Verified
-	apiProfile.IsBanned = dbProfile.IsBanned
-	apiProfile.VodEnabled = dbProfile.VodEnabled
-	apiProfile.IsAffiliate = dbProfile.IsAffiliate
-	apiProfile.SubscriptionEnabled = dbProfile.SubscriptionEnabled
-	apiProfile.Bio = dbProfile.Bio
-	apiProfile.City = dbProfile.City
-	apiProfile.State = dbProfile.State
-	apiProfile.TikTok = dbProfile.TikTok
-	apiProfile.Country = dbProfile.Country
-	apiProfile.Discord = dbProfile.Discord
-	apiProfile.Twitter = dbProfile.Twitter
-	apiProfile.YouTube = dbProfile.YouTube
-	apiProfile.Facebook = dbProfile.Facebook
-	apiProfile.Instagram = dbProfile.Instagram
-	apiProfile.ProfilePic = dbProfile.ProfilePic
+	copyProfileAttributes(&apiProfile, dbProfile)
+
+	previous, err := previousUsernames(dbProfile.ChannelID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	apiProfile.PreviousUsernames = previous
 
 	var followersTimeline []models.FollowersCountPoint
 	if len(dbProfile.FollowersCount) > 0 {
@@ -1425,54 +2297,116 @@ func GetStreamerProfile(username string) (StreamerProfileAPI, error) {
 	var fetchedReports []FullLivestreamReportForProfile
 	if len(livestreamUUIDs) > 0 {
 		var reports []models.LivestreamReport
-		if err := db.DB.Where("id IN (?)", livestreamUUIDs).Order("report_start_time DESC").Find(&reports).Error; err != nil {
+		if err := db.Reader().Where("id IN (?)", livestreamUUIDs).Order("report_start_time DESC").Find(&reports).Error; err != nil {
 			log.Printf("Warning: Failed to fetch LivestreamReports for channel %d: %v", dbProfile.ChannelID, err)
 		} else {
 			fetchedReports = make([]FullLivestreamReportForProfile, 0, len(reports))
 			for _, report := range reports {
-				fullReport := FullLivestreamReportForProfile{
-					LivestreamReportRestructured: LivestreamReportRestructured{
-						LivestreamID:          int(report.LivestreamID),
-						Title:                 report.Title,
-						ReportStartTime:       report.ReportStartTime,
-						DurationMinutes:       report.DurationMinutes,
-						AverageViewers:        report.AverageViewers,
-						PeakViewers:           report.PeakViewers,
-						LowestViewers:         report.LowestViewers,
-						Engagement:            report.Engagement,
-						TotalMessages:         report.TotalMessages,
-						HoursWatched:          report.HoursWatched,
-						UniqueChatters:        report.UniqueChatters,
-						MessagesFromApps:      report.MessagesFromApps,
-						ViewerCountsTimeline:  report.ViewerCountsTimeline,
-						MessageCountsTimeline: report.MessageCountsTimeline,
-						CreatedAt:             report.CreatedAt,
-					},
-				}
+				var spamReport *models.SpamReport
 				if report.SpamReportID != nil {
-					var spamReport models.SpamReport
-					if err := db.DB.Where("id = ?", report.SpamReportID).First(&spamReport).Error; err != nil {
+					var found models.SpamReport
+					if err := db.Reader().Where("id = ?", report.SpamReportID).First(&found).Error; err != nil {
 						log.Printf("Warning: Failed to fetch spam report %s for report %s: %v", report.SpamReportID.String(), report.ID.String(), err)
-
 					} else {
-
-						fullReport.SpamReport = SpamReportRestructured{
-							MessagesWithEmotes:         spamReport.MessagesWithEmotes,
-							MessagesMultipleEmotesOnly: spamReport.MessagesWithEmotes,
-							DuplicateMessagesCount:     spamReport.DuplicateMessagesCount,
-							RepetitivePhrasesCount:     spamReport.RepetitivePhrasesCount,
-							ExactDuplicateBursts:       spamReport.ExactDuplicateBursts,
-							SimilarMessageBursts:       spamReport.SimilarMessageBursts,
-							SuspiciousChatters:         spamReport.SuspiciousChatters,
-						}
+						spamReport = &found
 					}
 				}
-				fetchedReports = append(fetchedReports, fullReport)
+				fetchedReports = append(fetchedReports, restructureReport(report, spamReport))
 			}
 		}
 	}
 	apiProfile.Livestreams = fetchedReports
+	apiProfile.AudienceLanguages = rollupAudienceLanguages(fetchedReports)
 
 	return apiProfile, nil
 
 }
+
+// restructureReport returns a report and its spam report, nil when missing, in the shape the API serves them
+func restructureReport(report models.LivestreamReport, spamReport *models.SpamReport) FullLivestreamReportForProfile {
+	fullReport := FullLivestreamReportForProfile{
+		LivestreamReportRestructured: LivestreamReportRestructured{
+			LivestreamID:                  int(report.LivestreamID),
+			Title:                         report.Title,
+			Category:                      report.Category,
+			ReportStartTime:               report.ReportStartTime,
+			ReportEndTime:                 report.ReportEndTime,
+			DurationMinutes:               report.DurationMinutes,
+			AverageViewers:                report.AverageViewers,
+			PeakViewers:                   report.PeakViewers,
+			LowestViewers:                 report.LowestViewers,
+			Engagement:                    report.Engagement,
+			TotalMessages:                 report.TotalMessages,
+			HoursWatched:                  report.HoursWatched,
+			AverageConcurrentViewers:      report.AverageConcurrentViewers,
+			ViewerTrackedSeconds:          report.ViewerTrackedSeconds,
+			FollowersGained:               report.FollowersGained,
+			FollowersPerHourWatched:       report.FollowersPerHourWatched,
+			EngagementFormula:             report.EngagementFormula,
+			EngagementChattersPerAverage:  report.EngagementChattersPerAverage,
+			EngagementMessagesPerViewerHr: report.EngagementMessagesPerViewerHr,
+			EngagementChattersPerPeak:     report.EngagementChattersPerPeak,
+			EngagementQualityWeighted:     report.EngagementQualityWeighted,
+			EngagementBotAdjusted:         report.EngagementBotAdjusted,
+			LikelyBotChatters:             report.LikelyBotChatters,
+			UniqueChatters:                report.UniqueChatters,
+			MessagesFromApps:              report.MessagesFromApps,
+			TopOnePercentShare:            report.TopOnePercentShare,
+			TopTenPercentShare:            report.TopTenPercentShare,
+			ChatGini:                      report.ChatGini,
+			RawAverageViewers:             report.RawAverageViewers,
+			RawPeakViewers:                report.RawPeakViewers,
+			ViewerCountsTimeline:          report.ViewerCountsTimeline,
+			RawViewerCountsTimeline:       report.RawViewerCountsTimeline,
+			MessageCountsTimeline:         report.MessageCountsTimeline,
+			Reactions:                     report.Reactions,
+			ClipsCreated:                  report.ClipsCreated,
+			QuestionStats:                 report.QuestionStats,
+			CustomMetrics:                 report.CustomMetrics,
+			ViewerBotAnalysis:             report.ViewerBotAnalysis,
+			ViewerBotSuspected:            report.ViewerBotSuspected,
+			ChatLanguages:                 report.ChatLanguages,
+			Quality:                       report.Quality,
+			Simulcast:                     report.Simulcast,
+			SimulcastInfo:                 report.SimulcastInfo,
+			ViewerMilestones:              report.ViewerMilestones,
+			RaidSpikes:                    report.RaidSpikes,
+			AudienceComposition:           report.AudienceComposition,
+			ParentReportID:                report.ParentReportID,
+			ChunkIndex:                    report.ChunkIndex,
+			ChunkCount:                    report.ChunkCount,
+			ChunkReportIDs:                report.ChunkReportIDs,
+			Stale:                         report.Stale,
+			StaleMessages:                 report.StaleMessages,
+			Sampled:                       report.Sampled,
+			SampleRate:                    report.SampleRate,
+			PersistedMessages:             report.PersistedMessages,
+			ExcludedChatters:              report.ExcludedChatters,
+			ExcludedMessages:              report.ExcludedMessages,
+			IngestionLagP50:               report.IngestionLagP50,
+			IngestionLagP95:               report.IngestionLagP95,
+			IngestionLagged:               report.IngestionLagged,
+			WindowStart:                   report.WindowStart,
+			WindowEnd:                     report.WindowEnd,
+			VodURL:                        report.VodURL,
+			VodSourceURL:                  report.VodSourceURL,
+			CreatedAt:                     report.CreatedAt,
+		},
+	}
+	if spamReport != nil {
+		fullReport.SpamReport = SpamReportRestructured{
+			MessagesWithEmotes:         spamReport.MessagesWithEmotes,
+			MessagesMultipleEmotesOnly: spamReport.MessagesWithEmotes,
+			DuplicateMessagesCount:     spamReport.DuplicateMessagesCount,
+			RepetitivePhrasesCount:     spamReport.RepetitivePhrasesCount,
+			ExactDuplicateBursts:       spamReport.ExactDuplicateBursts,
+			SimilarMessageBursts:       spamReport.SimilarMessageBursts,
+			SuspiciousChatters:         spamReport.SuspiciousChatters,
+			CrossUserCopypasta:         spamReport.CrossUserCopypasta,
+			AltClusters:                spamReport.AltClusters,
+			UnicodeAbuse:               spamReport.UnicodeAbuse,
+			EmoteBursts:                spamReport.EmoteBursts,
+		}
+	}
+	return fullReport
+}
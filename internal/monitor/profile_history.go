@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoProfileHistory is returned when a channel has no snapshot old enough to reconstruct its profile at a date
+var ErrNoProfileHistory = errors.New("no channel history at the requested date")
+
+// GetStreamerProfileAsOf reconstructs a streamer profile as it was at asOf: attributes come from the last
+// channel_data snapshot taken by then, and the follower timeline and livestreams are cut off at asOf.
+func GetStreamerProfileAsOf(username string, asOf time.Time) (StreamerProfileAPI, error) {
+	apiProfile, err := GetStreamerProfile(username)
+	if err != nil {
+		return apiProfile, err
+	}
+
+	var snapshot models.ChannelData
+	if err := db.DB.Where("channel_id = ? AND created_at <= ?", apiProfile.ChannelID, asOf).
+		Order("created_at DESC").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return StreamerProfileAPI{}, ErrNoProfileHistory
+		}
+		return StreamerProfileAPI{}, fmt.Errorf("failed to fetch channel snapshot of %s as of %s: %w", username, asOf.Format(time.RFC3339), err)
+	}
+
+	var kickData KickChannelResponse
+	if err := json.Unmarshal(snapshot.Data, &kickData); err != nil {
+		return StreamerProfileAPI{}, fmt.Errorf("failed to decode channel snapshot %s: %w", snapshot.ID.String(), err)
+	}
+	historical := models.StreamerProfile{ChannelID: apiProfile.ChannelID}
+	populateProfileAttributes(&historical, kickData)
+	copyProfileAttributes(&apiProfile, historical)
+
+	followers := make([]models.FollowersCountPoint, 0, len(apiProfile.FollowersCount))
+	for _, point := range apiProfile.FollowersCount {
+		if !point.Time.After(asOf) {
+			followers = append(followers, point)
+		}
+	}
+	apiProfile.FollowersCount = followers
+
+	livestreams := make([]FullLivestreamReportForProfile, 0, len(apiProfile.Livestreams))
+	for _, report := range apiProfile.Livestreams {
+		if report.ReportStartTime.Before(asOf) {
+			livestreams = append(livestreams, report)
+		}
+	}
+	apiProfile.Livestreams = livestreams
+	apiProfile.AudienceLanguages = rollupAudienceLanguages(livestreams)
+
+	apiProfile.AsOf = &asOf
+	return apiProfile, nil
+}
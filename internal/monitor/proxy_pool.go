@@ -0,0 +1,200 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Kick requests rotate round-robin over the healthy proxies of the pool. A proxy that errors, times out or answers
+// with a non-ok status rests for PROXY_FAILURE_COOLDOWN, doubling with each consecutive failure up to
+// PROXY_MAX_COOLDOWN, and the request fails over to the next healthy proxy. When every proxy left is resting only the
+// one that failed longest ago is tried, so the pool never stops fetching outright without hammering proxies that are
+// down.
+var (
+	ProxyTimeout         = util.GetEnvDuration("PROXY_TIMEOUT", 90*time.Second)          // Per request to a proxy, above the 60s it gets to solve the challenge
+	ProxyFailureCooldown = util.GetEnvDuration("PROXY_FAILURE_COOLDOWN", 30*time.Second) // Rest after a failure, doubling while they repeat
+	ProxyMaxCooldown     = util.GetEnvDuration("PROXY_MAX_COOLDOWN", 10*time.Minute)
+)
+
+// ProxyPool is a Fetcher spreading requests over several challenge-solving proxies
+type ProxyPool struct {
+	mu      sync.Mutex
+	proxies []*pooledProxy
+	next    int // Round-robin position
+}
+
+type pooledProxy struct {
+	fetcher             *ProxyFetcher
+	name                string // URL without credentials, for logs and status
+	requests            int64
+	failures            int64
+	consecutiveFailures int
+	lastError           string
+	lastFailure         time.Time
+	lastSuccess         time.Time
+	restingUntil        time.Time
+}
+
+// NewProxyPool returns a pool of the proxies at urls
+func NewProxyPool(urls []string) (*ProxyPool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("proxy pool needs at least one proxy URL")
+	}
+	pool := &ProxyPool{}
+	for _, raw := range urls {
+		fetcher, err := NewProxyFetcher(raw)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.Client = &http.Client{Timeout: ProxyTimeout}
+		name := raw
+		if u, err := url.Parse(raw); err == nil {
+			name = u.Redacted()
+		}
+		pool.proxies = append(pool.proxies, &pooledProxy{fetcher: fetcher, name: name})
+	}
+	return pool, nil
+}
+
+func (p *ProxyPool) FetchPage(apiURL string) (string, error) {
+	return p.try(apiURL, func(fetcher *ProxyFetcher) (string, error) { return fetcher.FetchPage(apiURL) })
+}
+
+func (p *ProxyPool) PostForm(apiURL string, form url.Values, cookies []*http.Cookie) (string, error) {
+	return p.try(apiURL, func(fetcher *ProxyFetcher) (string, error) { return fetcher.PostForm(apiURL, form, cookies) })
+}
+
+// try runs the request through one healthy proxy after the other until one succeeds, each proxy at most once. Once
+// only resting proxies are left it makes a last attempt through the one pick falls back to.
+func (p *ProxyPool) try(apiURL string, request func(*ProxyFetcher) (string, error)) (string, error) {
+	tried := make(map[*pooledProxy]bool, len(p.proxies))
+	var lastErr error
+	for range p.proxies {
+		proxy, resting := p.pick(tried)
+		tried[proxy] = true
+		body, err := request(proxy.fetcher)
+		p.record(proxy, err)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if resting {
+			break
+		}
+		if len(tried) < len(p.proxies) {
+			log.Printf("Proxy %s failed for %s, failing over: %v", proxy.name, apiURL, err)
+		}
+	}
+	if len(tried) > 1 {
+		return "", fmt.Errorf("%d proxies failed, last error: %w", len(tried), lastErr)
+	}
+	return "", lastErr
+}
+
+// pick returns the next healthy proxy not tried yet, or the one that failed longest ago and true when all those left
+// are resting
+func (p *ProxyPool) pick(tried map[*pooledProxy]bool) (*pooledProxy, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var fallback *pooledProxy
+	for i := range p.proxies {
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+		if tried[proxy] {
+			continue
+		}
+		if !proxy.restingUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			return proxy, false
+		}
+		if fallback == nil || proxy.lastFailure.Before(fallback.lastFailure) {
+			fallback = proxy
+		}
+	}
+	return fallback, true
+}
+
+// record updates the health of a proxy with the outcome of a request through it
+func (p *ProxyPool) record(proxy *pooledProxy, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	proxy.requests++
+	if err == nil {
+		if proxy.consecutiveFailures > 0 {
+			log.Printf("Proxy %s recovered after %d failure(s)", proxy.name, proxy.consecutiveFailures)
+		}
+		proxy.consecutiveFailures = 0
+		proxy.restingUntil = time.Time{}
+		proxy.lastSuccess = now
+		return
+	}
+	proxy.failures++
+	proxy.consecutiveFailures++
+	proxy.lastError = err.Error()
+	proxy.lastFailure = now
+	cooldown := ProxyFailureCooldown
+	for i := 1; i < proxy.consecutiveFailures && cooldown < ProxyMaxCooldown; i++ {
+		cooldown *= 2
+	}
+	proxy.restingUntil = now.Add(min(cooldown, ProxyMaxCooldown))
+}
+
+// ProxyStatus is the health of a proxy of the pool
+type ProxyStatus struct {
+	URL                 string     `json:"url"` // Credentials redacted
+	Healthy             bool       `json:"healthy"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	RestingUntil        *time.Time `json:"resting_until,omitempty"` // Skipped by the rotation until then
+}
+
+// Status returns the health of every proxy, in pool order
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	statuses := make([]ProxyStatus, 0, len(p.proxies))
+	for _, proxy := range p.proxies {
+		status := ProxyStatus{
+			URL:                 proxy.name,
+			Healthy:             !proxy.restingUntil.After(now),
+			Requests:            proxy.requests,
+			Failures:            proxy.failures,
+			ConsecutiveFailures: proxy.consecutiveFailures,
+			LastError:           proxy.lastError,
+		}
+		if !proxy.lastFailure.IsZero() {
+			lastFailure := proxy.lastFailure
+			status.LastFailure = &lastFailure
+		}
+		if !proxy.lastSuccess.IsZero() {
+			lastSuccess := proxy.lastSuccess
+			status.LastSuccess = &lastSuccess
+		}
+		if !status.Healthy {
+			restingUntil := proxy.restingUntil
+			status.RestingUntil = &restingUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// GetProxyPoolStatus returns the health of the proxies fetcher rotates over, nil when it isn't a pool (fake mode)
+func GetProxyPoolStatus(fetcher Fetcher) []ProxyStatus {
+	if pool, ok := fetcher.(*ProxyPool); ok {
+		return pool.Status()
+	}
+	return nil
+}
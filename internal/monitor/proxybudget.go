@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Daily proxy request budgets (per UTC day, per instance) keep FlareSolverr/captcha costs predictable. A channel
+// over its budget, or any channel once the global budget is spent, is polled ProxyBudgetBackoff times less often.
+// 0 disables a budget.
+var (
+	ProxyDailyBudget        = util.GetEnvInt("PROXY_DAILY_BUDGET", 0)
+	ProxyChannelDailyBudget = util.GetEnvInt("PROXY_CHANNEL_DAILY_BUDGET", 0)
+	ProxyBudgetBackoff      = util.GetEnvInt("PROXY_BUDGET_BACKOFF", 4)
+)
+
+// proxyBudgetSharedKey accounts proxy requests not made for a particular channel, e.g. Pusher key detection
+const proxyBudgetSharedKey = ""
+
+var proxyBudget = struct {
+	sync.Mutex
+	day        string
+	total      int
+	perChannel map[string]int // username -> requests today
+	warned     map[string]bool
+}{perChannel: make(map[string]int), warned: make(map[string]bool)}
+
+// ProxyBudgetStatus is the proxy request consumption of the current day
+type ProxyBudgetStatus struct {
+	Day       string `json:"day"`
+	Requests  int    `json:"requests"`
+	Budget    int    `json:"budget"` // 0 when unlimited
+	Exhausted bool   `json:"exhausted"`
+}
+
+// resetProxyBudgetIfNewDay starts a new budget day. Caller must hold the lock.
+func resetProxyBudgetIfNewDay(now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if proxyBudget.day == day {
+		return
+	}
+	proxyBudget.day = day
+	proxyBudget.total = 0
+	proxyBudget.perChannel = make(map[string]int)
+	proxyBudget.warned = make(map[string]bool)
+}
+
+// recordProxyRequest counts a proxy request made on behalf of username
+func recordProxyRequest(username string) {
+	proxyBudget.Lock()
+	defer proxyBudget.Unlock()
+
+	resetProxyBudgetIfNewDay(time.Now())
+	proxyBudget.total++
+	proxyBudget.perChannel[username]++
+
+	if ProxyDailyBudget > 0 && proxyBudget.total == ProxyDailyBudget {
+		log.Printf("Global proxy budget of %d requests exhausted for %s, slowing down polling of every channel", ProxyDailyBudget, proxyBudget.day)
+	}
+	if username != proxyBudgetSharedKey && ProxyChannelDailyBudget > 0 && proxyBudget.perChannel[username] >= ProxyChannelDailyBudget && !proxyBudget.warned[username] {
+		proxyBudget.warned[username] = true
+		log.Printf("Proxy budget of %d requests exhausted for %s on %s, polling it %dx less often", ProxyChannelDailyBudget, username, proxyBudget.day, max(ProxyBudgetBackoff, 1))
+	}
+}
+
+// pollInterval returns how often the channel should be fetched, backing off once a budget is exhausted
+func pollInterval(username string) time.Duration {
+	channel := ChannelProxyBudget(username)
+	if channel.Exhausted || GlobalProxyBudget().Exhausted {
+		return FetchInterval * time.Duration(max(ProxyBudgetBackoff, 1))
+	}
+	return FetchInterval
+}
+
+// GlobalProxyBudget returns today's proxy consumption of this instance
+func GlobalProxyBudget() ProxyBudgetStatus {
+	proxyBudget.Lock()
+	defer proxyBudget.Unlock()
+
+	resetProxyBudgetIfNewDay(time.Now())
+	return ProxyBudgetStatus{
+		Day:       proxyBudget.day,
+		Requests:  proxyBudget.total,
+		Budget:    ProxyDailyBudget,
+		Exhausted: ProxyDailyBudget > 0 && proxyBudget.total >= ProxyDailyBudget,
+	}
+}
+
+// ChannelProxyBudget returns today's proxy consumption of a channel
+func ChannelProxyBudget(username string) ProxyBudgetStatus {
+	proxyBudget.Lock()
+	defer proxyBudget.Unlock()
+
+	resetProxyBudgetIfNewDay(time.Now())
+	requests := proxyBudget.perChannel[username]
+	return ProxyBudgetStatus{
+		Day:       proxyBudget.day,
+		Requests:  requests,
+		Budget:    ProxyChannelDailyBudget,
+		Exhausted: ProxyChannelDailyBudget > 0 && requests >= ProxyChannelDailyBudget,
+	}
+}
@@ -0,0 +1,265 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/notify"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Pusher app key detection. Kick occasionally rotates the key embedded in WebSocketURL; once subscriptions keep
+// failing the key is scraped from the Kick web app through the proxy and the WebSocket URL is swapped in place.
+var (
+	PusherKeyFailureThreshold = util.GetEnvInt("PUSHER_KEY_FAILURE_THRESHOLD", 5)                // Consecutive Pusher errors before re-detecting the key
+	PusherKeyCheckInterval    = util.GetEnvDuration("PUSHER_KEY_CHECK_INTERVAL", 10*time.Minute) // Minimum time between detection attempts
+	PusherKeyPageURL          = util.GetEnvString("PUSHER_KEY_PAGE_URL", "https://kick.com")     // Page the key is scraped from
+)
+
+const pusherKeyMaxScripts = 15 // Script bundles searched when the key isn't inlined in the page
+
+// Where the current Pusher key came from
+const (
+	PusherKeySourceDefault  = "default"
+	PusherKeySourceEnv      = "env"
+	PusherKeySourceDetected = "detected"
+)
+
+var (
+	pusherURLRegex     = regexp.MustCompile(`wss?://ws-([a-z0-9-]+)\.pusher\.com/app/([A-Za-z0-9]+)`)
+	pusherKeyRegex     = regexp.MustCompile(`(?i)pusher[_-]?(?:app[_-]?)?key["']?\s*[:=]\s*["']([a-f0-9]{20})["']`)
+	pusherClusterRegex = regexp.MustCompile(`(?i)(?:pusher[_-]?)?cluster["']?\s*[:=]\s*["']([a-z]{2}[0-9])["']`)
+	scriptSrcRegex     = regexp.MustCompile(`<script[^>]+src=["']([^"']+\.js[^"']*)["']`)
+)
+
+// PusherHealth describes the Pusher app key in use and how subscriptions with it are doing
+type PusherHealth struct {
+	AppKey              string     `json:"app_key"`
+	Cluster             string     `json:"cluster"`
+	Source              string     `json:"source"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastFailureReason   string     `json:"last_failure_reason,omitempty"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastCheckError      string     `json:"last_check_error,omitempty"`
+	LastRotation        *time.Time `json:"last_rotation,omitempty"`
+
+	PrivateChannels        int        `json:"private_channels"` // Templates in PUSHER_PRIVATE_CHANNELS
+	PrivateAuthFailures    int        `json:"private_auth_failures"`
+	LastPrivateAuthError   string     `json:"last_private_auth_error,omitempty"`
+	LastPrivateAuthFailure *time.Time `json:"last_private_auth_failure,omitempty"`
+}
+
+type pusherState struct {
+	sync.Mutex
+	health   PusherHealth
+	checking bool
+}
+
+var pusher = newPusherState()
+
+func newPusherState() *pusherState {
+	s := &pusherState{health: PusherHealth{Source: PusherKeySourceDefault}}
+	if m := pusherURLRegex.FindStringSubmatch(WebSocketURL); m != nil {
+		s.health.Cluster, s.health.AppKey = m[1], m[2]
+	}
+	if key := os.Getenv("PUSHER_APP_KEY"); key != "" {
+		s.health.AppKey = key
+		s.health.Source = PusherKeySourceEnv
+	}
+	if cluster := os.Getenv("PUSHER_CLUSTER"); cluster != "" {
+		s.health.Cluster = cluster
+		s.health.Source = PusherKeySourceEnv
+	}
+	return s
+}
+
+// currentWebSocketURL is the Pusher URL built from the app key and cluster currently in use
+func currentWebSocketURL() string {
+	pusher.Lock()
+	defer pusher.Unlock()
+	return fmt.Sprintf("wss://ws-%s.pusher.com/app/%s", pusher.health.Cluster, pusher.health.AppKey)
+}
+
+// GetPusherHealth returns a snapshot of the Pusher app key health
+func GetPusherHealth() PusherHealth {
+	pusher.Lock()
+	defer pusher.Unlock()
+	health := pusher.health
+	health.PrivateChannels = len(privateChannelNames(&models.MonitoredChannel{}))
+	return health
+}
+
+// recordPrivateAuthFailure counts a private channel that couldn't be authorized or whose subscription was refused.
+// These don't count towards key detection: the key is fine, the account or its session isn't.
+func recordPrivateAuthFailure(channelName, reason string) {
+	pusher.Lock()
+	defer pusher.Unlock()
+
+	now := time.Now()
+	pusher.health.PrivateAuthFailures++
+	pusher.health.LastPrivateAuthError = channelName + ": " + reason
+	pusher.health.LastPrivateAuthFailure = &now
+}
+
+// recordPusherSuccess resets the failure counter after a successful subscription
+func recordPusherSuccess() {
+	pusher.Lock()
+	defer pusher.Unlock()
+
+	now := time.Now()
+	pusher.health.ConsecutiveFailures = 0
+	pusher.health.LastSuccess = &now
+}
+
+// recordPusherFailure counts a failed subscription or Pusher error and starts key detection once
+// PusherKeyFailureThreshold is reached.
+func recordPusherFailure(reason string) {
+	pusher.Lock()
+	defer pusher.Unlock()
+
+	now := time.Now()
+	pusher.health.ConsecutiveFailures++
+	pusher.health.LastFailure = &now
+	pusher.health.LastFailureReason = reason
+
+	if FakeMode || pusher.checking || pusher.health.ConsecutiveFailures < PusherKeyFailureThreshold {
+		return
+	}
+	if last := pusher.health.LastCheck; last != nil && now.Sub(*last) < PusherKeyCheckInterval {
+		return
+	}
+	pusher.checking = true
+	pusher.health.LastCheck = &now
+	go refreshPusherKey()
+}
+
+// pusherErrorData is the payload of pusher:error and pusher:subscription_error events
+type pusherErrorData struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+	Status  int    `json:"status"`
+}
+
+// handlePusherError records Pusher errors that point at an invalid app key or failing subscriptions.
+// Codes 4000-4099 mean the connection must not be retried as-is (e.g. 4001 app does not exist).
+func handlePusherError(channelUsername string, msg IncomingMessage) {
+	var data pusherErrorData
+	if msg.Data != "" {
+		if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+			log.Printf("Error unmarshalling %s for %s: %v, data: %s", msg.Event, channelUsername, err, msg.Data)
+		}
+	}
+	reason := fmt.Sprintf("%s code=%d status=%d %s%s", msg.Event, data.Code, data.Status, data.Message, data.Error)
+	log.Printf("Pusher error for channel %s: %s", channelUsername, reason)
+
+	if msg.Event == "pusher:subscription_error" && isAuthenticatedChannel(msg.Channel) {
+		recordPrivateAuthFailure(msg.Channel, reason)
+		return
+	}
+	if msg.Event == "pusher:subscription_error" || (data.Code >= 4000 && data.Code < 4100) {
+		recordPusherFailure(reason)
+	}
+}
+
+// refreshPusherKey scrapes the Pusher app key and cluster from the Kick web app and swaps them in if they changed
+func refreshPusherKey() {
+	key, cluster, err := detectPusherKey()
+
+	pusher.Lock()
+	defer pusher.Unlock()
+	pusher.checking = false
+
+	if err != nil {
+		pusher.health.LastCheckError = err.Error()
+		log.Printf("Pusher app key detection failed: %v", err)
+		return
+	}
+	pusher.health.LastCheckError = ""
+
+	if cluster == "" {
+		cluster = pusher.health.Cluster
+	}
+	if key == pusher.health.AppKey && cluster == pusher.health.Cluster {
+		log.Printf("Pusher app key detection found the key in use (%s, cluster %s); failures are not caused by a key rotation", key, cluster)
+		return
+	}
+
+	oldKey, oldCluster := pusher.health.AppKey, pusher.health.Cluster
+	now := time.Now()
+	pusher.health.AppKey = key
+	pusher.health.Cluster = cluster
+	pusher.health.Source = PusherKeySourceDetected
+	pusher.health.LastRotation = &now
+	pusher.health.ConsecutiveFailures = 0
+	log.Printf("Pusher app key rotated: %s (%s) -> %s (%s). WebSocket monitors will reconnect with the new key.", oldKey, oldCluster, key, cluster)
+
+	notify.SendAsync(notify.Alert{
+		Kind:      "pusher_key_rotated",
+		Severity:  notify.SeverityWarning,
+		Subject:   "pusher",
+		Message:   fmt.Sprintf("Kick rotated the Pusher app key from %s (%s) to %s (%s); switched automatically", oldKey, oldCluster, key, cluster),
+		Timestamp: now,
+	})
+}
+
+// detectPusherKey looks for the Pusher key in the Kick page and, failing that, in the script bundles it loads
+func detectPusherKey() (key, cluster string, err error) {
+	page, err := fetchPageViaProxy(PusherKeyPageURL, proxyBudgetSharedKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch %s: %w", PusherKeyPageURL, err)
+	}
+	if key, cluster := findPusherKey(page); key != "" {
+		return key, cluster, nil
+	}
+
+	scripts := scriptSrcRegex.FindAllStringSubmatch(page, -1)
+	for i, m := range scripts {
+		if i >= pusherKeyMaxScripts {
+			break
+		}
+		script, err := fetchPageViaProxy(resolveScriptURL(m[1]), proxyBudgetSharedKey)
+		if err != nil {
+			log.Printf("Pusher app key detection: failed to fetch script %s: %v", m[1], err)
+			continue
+		}
+		if key, cluster := findPusherKey(script); key != "" {
+			return key, cluster, nil
+		}
+	}
+	return "", "", fmt.Errorf("no Pusher app key found in %s or %d script(s)", PusherKeyPageURL, min(len(scripts), pusherKeyMaxScripts))
+}
+
+// findPusherKey extracts a Pusher key and cluster from page or script source, preferring a full WebSocket URL
+func findPusherKey(source string) (key, cluster string) {
+	if m := pusherURLRegex.FindStringSubmatch(source); m != nil {
+		return m[2], m[1]
+	}
+	if m := pusherKeyRegex.FindStringSubmatch(source); m != nil {
+		key = m[1]
+		if c := pusherClusterRegex.FindStringSubmatch(source); c != nil {
+			cluster = c[1]
+		}
+	}
+	return key, cluster
+}
+
+func resolveScriptURL(src string) string {
+	switch {
+	case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		return src
+	case strings.HasPrefix(src, "//"):
+		return "https:" + src
+	default:
+		return strings.TrimSuffix(PusherKeyPageURL, "/") + "/" + strings.TrimPrefix(src, "/")
+	}
+}
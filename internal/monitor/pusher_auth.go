@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/gorilla/websocket"
+)
+
+// Private (private-*) and presence (presence-*) Pusher channels need a signature from Kick's broadcasting auth
+// endpoint, which only signs for a signed-in account. The channels to subscribe are listed in
+// PUSHER_PRIVATE_CHANNELS as templates, {channel_id} and {chatroom_id} being replaced per monitored channel.
+var (
+	PusherPrivateChannels = util.GetEnvString("PUSHER_PRIVATE_CHANNELS", "")
+	PusherAuthURL         = util.GetEnvString("PUSHER_AUTH_URL", "https://kick.com/broadcasting/auth")
+	PusherAuthCookies     = util.GetEnvString("PUSHER_AUTH_COOKIES", "") // Cookie header of a signed-in Kick session
+	PusherAuthToken       = util.GetEnvString("PUSHER_AUTH_TOKEN", "")   // Bearer token, only sent on direct requests
+	PusherAuthViaProxy    = util.GetEnvBool("PUSHER_AUTH_VIA_PROXY", true)
+)
+
+// pusherHandshakeTimeout bounds the wait for the socket ID Pusher sends right after connecting
+const pusherHandshakeTimeout = 10 * time.Second
+
+// privateChannelNames returns the private and presence channels to subscribe for a monitored channel
+func privateChannelNames(channel *models.MonitoredChannel) []string {
+	var names []string
+	replacer := strings.NewReplacer(
+		"{channel_id}", strconv.FormatUint(uint64(channel.ChannelID), 10),
+		"{chatroom_id}", strconv.FormatUint(uint64(channel.ChatroomID), 10),
+	)
+	for _, template := range strings.Split(PusherPrivateChannels, ",") {
+		if template = strings.TrimSpace(template); template != "" {
+			names = append(names, replacer.Replace(template))
+		}
+	}
+	return names
+}
+
+// isAuthenticatedChannel reports whether subscribing to a Pusher channel needs an auth signature
+func isAuthenticatedChannel(name string) bool {
+	return strings.HasPrefix(name, "private-") || strings.HasPrefix(name, "presence-")
+}
+
+// readSocketID waits for pusher:connection_established and returns the socket ID it carries
+func readSocketID(conn *websocket.Conn, channelUsername string) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(pusherHandshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the Pusher handshake: %w", err)
+	}
+	var msg IncomingMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return "", fmt.Errorf("failed to unmarshal the Pusher handshake: %w", err)
+	}
+	if msg.Event == "pusher:error" {
+		handlePusherError(channelUsername, msg)
+		return "", fmt.Errorf("pusher refused the connection: %s", msg.Data)
+	}
+	if msg.Event != "pusher:connection_established" {
+		return "", fmt.Errorf("expected pusher:connection_established, got %s", msg.Event)
+	}
+	var data struct {
+		SocketID string `json:"socket_id"`
+	}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil || data.SocketID == "" {
+		return "", fmt.Errorf("no socket ID in the Pusher handshake: %s", msg.Data)
+	}
+	return data.SocketID, nil
+}
+
+// pusherAuthResponse is the signature of a subscription, channel_data being set for presence channels
+type pusherAuthResponse struct {
+	Auth        string `json:"auth"`
+	ChannelData string `json:"channel_data,omitempty"`
+}
+
+// authorizePusherChannel asks Kick to sign the subscription of socketID to a private or presence channel. The
+// request goes through the proxy, which clears the bot challenge, unless PUSHER_AUTH_VIA_PROXY is false.
+func authorizePusherChannel(socketID, channelName string) (pusherAuthResponse, error) {
+	var auth pusherAuthResponse
+	if PusherAuthCookies == "" && PusherAuthToken == "" {
+		return auth, errors.New("PUSHER_AUTH_COOKIES or PUSHER_AUTH_TOKEN must be set to subscribe to private channels")
+	}
+	form := url.Values{"socket_id": {socketID}, "channel_name": {channelName}}
+	cookies, err := http.ParseCookie(PusherAuthCookies)
+	if err != nil && PusherAuthCookies != "" {
+		return auth, fmt.Errorf("invalid PUSHER_AUTH_COOKIES: %w", err)
+	}
+
+	var body string
+	if poster, ok := pageFetcher.(FormPoster); ok && PusherAuthViaProxy {
+		recordProxyRequest(proxyBudgetSharedKey)
+		release := acquireFetchSlot(proxyBudgetSharedKey, PusherAuthURL)
+		page, err := poster.PostForm(PusherAuthURL, form, cookies)
+		release()
+		if err != nil {
+			return auth, err
+		}
+		if body, err = util.ExtractJSONFromHTML(page); err != nil {
+			return auth, fmt.Errorf("error extracting JSON from the auth response: %w", err)
+		}
+	} else {
+		if body, err = postPusherAuth(form, cookies); err != nil {
+			return auth, err
+		}
+	}
+
+	if err := json.Unmarshal([]byte(body), &auth); err != nil {
+		return auth, fmt.Errorf("error unmarshalling the auth response: %w", err)
+	}
+	if auth.Auth == "" {
+		return auth, fmt.Errorf("auth response carries no signature: %s", truncateRunes(body, 200))
+	}
+	return auth, nil
+}
+
+// postPusherAuth posts the auth form to Kick directly
+func postPusherAuth(form url.Values, cookies []*http.Cookie) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, PusherAuthURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if PusherAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+PusherAuthToken)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending the auth request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading the auth response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth endpoint returned %d: %s", resp.StatusCode, truncateRunes(string(body), 200))
+	}
+	return string(body), nil
+}
+
+// subscribePrivateChannels subscribes the connection to the channel's private and presence channels. A channel
+// that can't be authorized is skipped and recorded in the Pusher health, the public ones keep working.
+func subscribePrivateChannels(conn *websocket.Conn, channel *models.MonitoredChannel, socketID string) error {
+	for _, name := range privateChannelNames(channel) {
+		if !isAuthenticatedChannel(name) {
+			log.Printf("Warning: %s in PUSHER_PRIVATE_CHANNELS is neither private- nor presence-, subscribing without auth", name)
+		}
+		data := map[string]string{"auth": "", "channel": name}
+		if isAuthenticatedChannel(name) {
+			auth, err := authorizePusherChannel(socketID, name)
+			if err != nil {
+				recordPrivateAuthFailure(name, err.Error())
+				log.Printf("Pusher auth for %s failed for channel %s: %v", name, channel.Username, err)
+				continue
+			}
+			data["auth"] = auth.Auth
+			if auth.ChannelData != "" {
+				data["channel_data"] = auth.ChannelData
+			}
+		}
+		if err := conn.WriteJSON(map[string]any{"event": "pusher:subscribe", "data": data}); err != nil {
+			return fmt.Errorf("failed to subscribe to channel %s: %w", name, err)
+		}
+	}
+	return nil
+}
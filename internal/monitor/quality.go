@@ -0,0 +1,233 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Report quality grades, from the score
+const (
+	QualityHigh   = "high"   // Score of at least 0.9
+	QualityMedium = "medium" // Score of at least 0.7
+	QualityLow    = "low"
+)
+
+// Weights of the quality score components. When the chat uptime is unknown its weight goes to the fetch coverage.
+const (
+	qualityWeightCoverage = 0.5
+	qualityWeightChat     = 0.3
+	qualityWeightSamples  = 0.2
+)
+
+// qualityIssueThreshold is the share of a component below which it is listed as an issue
+const qualityIssueThreshold = 0.95
+
+// qualityPolledChatCredit is how much a polled minute of chat counts towards the chat uptime score, polling only
+// sees the messages still in the API's recent list
+const qualityPolledChatCredit = 0.5
+
+// ReportQuality tells how complete the data behind a report is, and how far its viewer metrics may be off
+type ReportQuality struct {
+	Score             float64         `json:"score"`                 // 0 to 1
+	Grade             string          `json:"grade"`                 // high, medium or low
+	FetchCoverage     float64         `json:"fetch_coverage"`        // Share of the stream within MaxSampleGap of a viewer sample
+	LongestGapSeconds int             `json:"longest_gap_seconds"`   // Longest stretch without a viewer sample
+	ChatUptime        *float64        `json:"chat_uptime"`           // Share of the stream the chat was connected, null for streams before it was tracked
+	ChatPolled        *float64        `json:"chat_polled,omitempty"` // Share of the stream the chat was only polled, while the WebSocket was blocked
+	Samples           int             `json:"samples"`               // Viewer samples from HTTP fetches
+	ExpectedSamples   int             `json:"expected_samples"`      // One per FetchInterval
+	AverageViewers    ConfidenceRange `json:"average_viewers"`
+	PeakViewers       ConfidenceRange `json:"peak_viewers"`
+	Issues            []string        `json:"issues,omitempty"`
+}
+
+// ConfidenceRange is a metric with the range its true value lies in with about 95% confidence
+type ConfidenceRange struct {
+	Value int `json:"value"`
+	Low   int `json:"low"`
+	High  int `json:"high"`
+}
+
+// qualityInput holds what assessReportQuality needs from a report
+type qualityInput struct {
+	Start, End    time.Time
+	ViewerCounts  []models.LivestreamData // Smoothed samples, sorted by CreatedAt
+	Average, Peak int                     // Viewer metrics of the report, from the smoothed samples
+	Lowest        int
+	RawPeak       int         // Peak before spike rejection
+	ChatConnected []timeRange // Merged periods the chat was connected within [Start, End)
+	ChatPolled    []timeRange // Those of them it was only polled
+	ChatTracked   bool        // False when the stream predates chat connection tracking
+}
+
+// assessReportQuality scores the data behind a report from the gaps between viewer samples, the chat uptime and
+// the number of samples, and puts confidence ranges around the average and peak viewers
+func assessReportQuality(in qualityInput) ReportQuality {
+	quality := ReportQuality{
+		AverageViewers: ConfidenceRange{Value: in.Average, Low: in.Average, High: in.Average},
+		PeakViewers:    ConfidenceRange{Value: in.Peak, Low: in.Peak, High: in.Peak},
+	}
+	duration := in.End.Sub(in.Start)
+	if duration <= 0 {
+		quality.Grade = QualityLow
+		quality.Issues = []string{"empty report window"}
+		return quality
+	}
+
+	// Time further than MaxSampleGap from any sample is uncovered, the same cap watch time integration uses
+	var samples []models.LivestreamData
+	var uncovered, longestGap time.Duration
+	previous := in.Start
+	for _, sample := range in.ViewerCounts {
+		if sample.CreatedAt.Before(in.Start) || !sample.CreatedAt.Before(in.End) {
+			continue
+		}
+		samples = append(samples, sample)
+		if sample.Source != LivestreamSourcePusher {
+			quality.Samples++
+		}
+		gap := sample.CreatedAt.Sub(previous)
+		longestGap = max(longestGap, gap)
+		uncovered += max(0, gap-MaxSampleGap)
+		previous = sample.CreatedAt
+	}
+	gap := in.End.Sub(previous)
+	longestGap = max(longestGap, gap)
+	uncovered += max(0, gap-MaxSampleGap)
+
+	coverage := math.Max(0, 1-uncovered.Seconds()/duration.Seconds())
+	quality.FetchCoverage = roundTo(coverage, 3)
+	quality.LongestGapSeconds = int(longestGap.Seconds())
+	quality.ExpectedSamples = max(1, int(math.Ceil(duration.Seconds()/FetchInterval.Seconds())))
+	sampleShare := math.Min(1, float64(quality.Samples)/float64(quality.ExpectedSamples))
+
+	coverageWeight := qualityWeightCoverage + qualityWeightChat
+	score := 0.0
+	if in.ChatTracked {
+		var connected time.Duration
+		for _, r := range in.ChatConnected {
+			connected += r.End.Sub(r.Start)
+		}
+		uptime := roundTo(math.Min(1, connected.Seconds()/duration.Seconds()), 3)
+		quality.ChatUptime = &uptime
+		coverageWeight = qualityWeightCoverage
+		chatScore := uptime
+		if uptime < qualityIssueThreshold {
+			quality.Issues = append(quality.Issues, fmt.Sprintf("chat disconnected for %s of the stream",
+				(duration-connected).Round(time.Second)))
+		}
+
+		var polled time.Duration
+		for _, r := range in.ChatPolled {
+			polled += r.End.Sub(r.Start)
+		}
+		if polled > 0 {
+			polledShare := roundTo(math.Min(1, polled.Seconds()/duration.Seconds()), 3)
+			quality.ChatPolled = &polledShare
+			chatScore = math.Max(0, chatScore-(1-qualityPolledChatCredit)*polledShare)
+			quality.Issues = append(quality.Issues, fmt.Sprintf("chat polled instead of streamed for %s of the stream, busy moments may miss messages",
+				polled.Round(time.Second)))
+		}
+		score += qualityWeightChat * chatScore
+	}
+	score += coverageWeight*coverage + qualityWeightSamples*sampleShare
+	quality.Score = roundTo(score, 3)
+	switch {
+	case quality.Score >= 0.9:
+		quality.Grade = QualityHigh
+	case quality.Score >= 0.7:
+		quality.Grade = QualityMedium
+	default:
+		quality.Grade = QualityLow
+	}
+
+	if coverage < qualityIssueThreshold {
+		quality.Issues = append(quality.Issues, fmt.Sprintf("viewer samples missing for %s of the stream (longest gap %s)",
+			uncovered.Round(time.Second), longestGap.Round(time.Second)))
+	}
+	if sampleShare < qualityIssueThreshold {
+		quality.Issues = append(quality.Issues, fmt.Sprintf("%d of %d expected viewer samples", quality.Samples, quality.ExpectedSamples))
+	}
+	if len(samples) == 0 {
+		quality.Issues = append(quality.Issues, "no viewer samples, viewer metrics are unknown")
+		return quality
+	}
+
+	quality.AverageViewers = averageViewersRange(samples, in.Average, in.Lowest, in.Peak, coverage)
+	quality.PeakViewers = peakViewersRange(samples, in.Peak, in.RawPeak, longestGap)
+	return quality
+}
+
+// averageViewersRange is the 95% interval of the mean of the samples, whose effective count is shrunk for their
+// autocorrelation, blended for the uncovered share of the stream with what it could have been: anywhere between
+// the lowest and the peak viewers
+func averageViewersRange(samples []models.LivestreamData, average, lowest, peak int, coverage float64) ConfidenceRange {
+	n := float64(len(samples))
+	mean := 0.0
+	for _, s := range samples {
+		mean += float64(s.ViewerCount)
+	}
+	mean /= n
+
+	var variance, lagCovariance float64
+	for i, s := range samples {
+		d := float64(s.ViewerCount) - mean
+		variance += d * d
+		if i > 0 {
+			lagCovariance += d * (float64(samples[i-1].ViewerCount) - mean)
+		}
+	}
+	margin := 0.0
+	if n > 1 && variance > 0 {
+		rho := math.Max(0, math.Min(0.99, lagCovariance/variance))
+		effective := math.Max(1, n*(1-rho)/(1+rho))
+		margin = 1.96 * math.Sqrt(variance/(n-1)) / math.Sqrt(effective)
+	}
+
+	missing := 1 - coverage
+	low := (1-missing)*(float64(average)-margin) + missing*float64(lowest)
+	high := (1-missing)*(float64(average)+margin) + missing*float64(peak)
+	return ConfidenceRange{
+		Value: average,
+		Low:   max(0, min(average, int(math.Floor(low)))),
+		High:  max(average, int(math.Ceil(high))),
+	}
+}
+
+// peakViewersRange starts at the observed peak: the real one may have fallen between samples. Viewer counts are
+// treated as a random walk pinned by the samples around the longest gap, whose maximum exceeds them by less than
+// 1.22 step deviations times the square root of the fetch intervals in the gap 95% of the time. A spike rejected
+// by smoothing raises the upper bound to the raw peak.
+func peakViewersRange(samples []models.LivestreamData, peak, rawPeak int, longestGap time.Duration) ConfidenceRange {
+	// Steps between HTTP fetches, one FetchInterval apart; Pusher updates in between would shrink them
+	var steps []float64
+	previous := -1
+	for _, sample := range samples {
+		if sample.Source == LivestreamSourcePusher {
+			continue
+		}
+		if previous >= 0 {
+			steps = append(steps, float64(sample.ViewerCount-previous))
+		}
+		previous = sample.ViewerCount
+	}
+	stepDeviation := 0.0
+	if len(steps) > 1 {
+		var mean, variance float64
+		for _, s := range steps {
+			mean += s
+		}
+		mean /= float64(len(steps))
+		for _, s := range steps {
+			variance += (s - mean) * (s - mean)
+		}
+		stepDeviation = math.Sqrt(variance / float64(len(steps)-1))
+	}
+
+	intervals := math.Max(1, longestGap.Seconds()/FetchInterval.Seconds())
+	high := peak + int(math.Ceil(1.22*stepDeviation*math.Sqrt(intervals)))
+	return ConfidenceRange{Value: peak, Low: peak, High: max(high, rawPeak)}
+}
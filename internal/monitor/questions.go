@@ -0,0 +1,180 @@
+package monitor
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+const (
+	QuestionAnswerWindow  = 5 * time.Minute // How long after a question a streamer/moderator reply counts as its answer
+	questionMinWords      = 3               // Interrogative openers need a few words, "what" alone is a reaction
+	questionUnansweredTop = 10              // Most recent unanswered questions listed in the report
+)
+
+var (
+	urlPattern     = regexp.MustCompile(`(?i)https?://\S+|\S+\.(?:com|tv|gg|net|org)/\S*`)
+	mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_-]+)`)
+)
+
+// interrogatives are the words a question without a question mark can open with
+var interrogatives = map[string]struct{}{
+	"who": {}, "what": {}, "when": {}, "where": {}, "why": {}, "how": {}, "which": {}, "whose": {},
+	"is": {}, "are": {}, "am": {}, "was": {}, "were": {}, "can": {}, "could": {}, "do": {}, "does": {}, "did": {},
+	"will": {}, "would": {}, "should": {}, "shall": {}, "have": {}, "has": {}, "may": {}, "might": {},
+}
+
+// QuestionStats is the questions section of a livestream report. Answers are streamer or moderator chat replies
+// (Kick replies or @mentions); questions answered on stream by voice can't be seen.
+type QuestionStats struct {
+	Questions             int               `json:"questions"`
+	UniqueAskers          int               `json:"unique_askers"`
+	Answered              int               `json:"answered"`
+	Unanswered            int               `json:"unanswered"`
+	AnswerRate            float64           `json:"answer_rate"`                       // Answered / Questions
+	MedianResponseSeconds float64           `json:"median_response_seconds,omitempty"` // Of the answered questions
+	QuestionsPerHour      float64           `json:"questions_per_hour"`
+	RecentUnanswered      []QuestionExcerpt `json:"recent_unanswered"`
+}
+
+// QuestionExcerpt is a question listed in the report
+type QuestionExcerpt struct {
+	Username string    `json:"username"`
+	Content  string    `json:"content"`
+	Time     time.Time `json:"time"`
+}
+
+// chatReplyMetadata is the metadata Kick attaches to chat replies
+type chatReplyMetadata struct {
+	OriginalSender *struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"original_sender"`
+	OriginalMessage *struct {
+		ID string `json:"id"`
+	} `json:"original_message"`
+}
+
+type pendingQuestion struct {
+	msg      models.ChatMessage
+	answered bool
+}
+
+// isQuestion reports whether a chat message reads as a question: a question mark outside of links, or an
+// interrogative opener followed by a few words
+func isQuestion(message string) bool {
+	text := strings.TrimSpace(urlPattern.ReplaceAllString(message, ""))
+	if text == "" {
+		return false
+	}
+	if strings.Contains(text, "?") {
+		return strings.Trim(text, "? ") != ""
+	}
+
+	words := strings.Fields(strings.ToLower(mentionPattern.ReplaceAllString(text, "")))
+	if len(words) < questionMinWords {
+		return false
+	}
+	_, ok := interrogatives[strings.Trim(words[0], ",.!")]
+	return ok
+}
+
+// normalizeChatName makes usernames comparable with slugs, which use dashes for underscores
+func normalizeChatName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, "@")), "_", "-")
+}
+
+// isStaffMessage reports whether the message comes from the streamer or a moderator
+func isStaffMessage(msg models.ChatMessage, channelUsername string) bool {
+	if normalizeChatName(msg.SenderUsername) == normalizeChatName(channelUsername) {
+		return true
+	}
+	group, _ := audienceGroup(msg.Badges)
+	return group == audienceModerator
+}
+
+// buildQuestionStats detects the questions of a livestream and which of them the streamer or a moderator
+// answered in chat. messages must be sorted by send time.
+func buildQuestionStats(messages []models.ChatMessage, channelUsername string, duration time.Duration) QuestionStats {
+	stats := QuestionStats{RecentUnanswered: []QuestionExcerpt{}}
+
+	var questions []*pendingQuestion
+	byMessageID := make(map[string]*pendingQuestion)
+	byAsker := make(map[string][]*pendingQuestion)
+	askers := make(map[int]struct{})
+	var responseTimes []float64
+
+	answer := func(q *pendingQuestion, at time.Time) {
+		if q.answered || at.Sub(q.msg.MessageSendTime) > QuestionAnswerWindow {
+			return
+		}
+		q.answered = true
+		responseTimes = append(responseTimes, at.Sub(q.msg.MessageSendTime).Seconds())
+	}
+
+	for _, msg := range messages {
+		if isStaffMessage(msg, channelUsername) {
+			var reply chatReplyMetadata
+			if len(msg.Metadata) > 0 && json.Unmarshal(msg.Metadata, &reply) == nil {
+				if reply.OriginalMessage != nil {
+					if q, ok := byMessageID[reply.OriginalMessage.ID]; ok {
+						answer(q, msg.MessageSendTime)
+					}
+				} else if reply.OriginalSender != nil {
+					for _, q := range byAsker[normalizeChatName(reply.OriginalSender.Username)] {
+						answer(q, msg.MessageSendTime)
+					}
+				}
+			}
+			for _, mention := range mentionPattern.FindAllStringSubmatch(msg.Message, -1) {
+				for _, q := range byAsker[normalizeChatName(mention[1])] {
+					answer(q, msg.MessageSendTime)
+				}
+			}
+			continue
+		}
+
+		if _, isApp := AppSenders[msg.SenderUsername]; isApp || !isQuestion(msg.Message) {
+			continue
+		}
+		q := &pendingQuestion{msg: msg}
+		questions = append(questions, q)
+		byMessageID[msg.ID.String()] = q
+		asker := normalizeChatName(msg.SenderUsername)
+		byAsker[asker] = append(byAsker[asker], q)
+		askers[msg.SenderID] = struct{}{}
+	}
+
+	stats.Questions = len(questions)
+	stats.UniqueAskers = len(askers)
+	for i := len(questions) - 1; i >= 0; i-- {
+		q := questions[i]
+		if q.answered {
+			stats.Answered++
+			continue
+		}
+		stats.Unanswered++
+		if len(stats.RecentUnanswered) < questionUnansweredTop {
+			stats.RecentUnanswered = append(stats.RecentUnanswered, QuestionExcerpt{
+				Username: q.msg.SenderUsername,
+				Content:  q.msg.Message,
+				Time:     q.msg.MessageSendTime,
+			})
+		}
+	}
+	if stats.Questions > 0 {
+		stats.AnswerRate = float64(stats.Answered) / float64(stats.Questions)
+	}
+	if hours := duration.Hours(); hours > 0 {
+		stats.QuestionsPerHour = float64(stats.Questions) / hours
+	}
+	if len(responseTimes) > 0 {
+		sort.Float64s(responseTimes)
+		stats.MedianResponseSeconds = responseTimes[len(responseTimes)/2]
+	}
+	return stats
+}
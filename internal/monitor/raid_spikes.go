@@ -0,0 +1,217 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// A raid or watch party shows up as a sudden viewer rise right as another stream ends or hosts the channel. Rises
+// are matched against the host events of the channel's chat and the streams of other monitored channels that went
+// offline just before.
+var (
+	RaidSpikeWindow       = util.GetEnvDuration("RAID_SPIKE_WINDOW", 5*time.Minute)       // How quickly the viewers must rise
+	RaidSpikeMinRise      = util.GetEnvFloat("RAID_SPIKE_MIN_RISE", 0.25)                 // Rise over the lowest viewers of the window before
+	RaidSpikeMinViewers   = util.GetEnvInt("RAID_SPIKE_MIN_VIEWERS", 50)                  // Viewers gained, so small channels' noise isn't a raid
+	RaidAttributionWindow = util.GetEnvDuration("RAID_ATTRIBUTION_WINDOW", 5*time.Minute) // How long before a rise a source may have ended or hosted
+)
+
+const (
+	raidSourcesPerSpike    = 3
+	raidMatchingViewerHint = 0.5 // Share of the rise the source's last viewers must reach to make its ending likely
+)
+
+// Evidence that a channel sent its viewers
+const (
+	RaidEvidenceHost  = "host"  // It hosted the channel, from the host event in chat
+	RaidEvidenceEnded = "ended" // A monitored channel's stream went offline just before the rise
+)
+
+// Confidence of a raid source
+const (
+	RaidConfidenceHigh   = "high"   // Host event
+	RaidConfidenceMedium = "medium" // Ended with about as many viewers as the rise
+	RaidConfidenceLow    = "low"    // Ended around the rise with fewer viewers than it
+)
+
+// RaidSpike is a viewer rise annotated with the channels that probably sent the viewers, most likely first
+type RaidSpike struct {
+	Time          time.Time    `json:"time"` // Sample the rise peaked at
+	RiseStartedAt time.Time    `json:"rise_started_at"`
+	ViewersBefore int          `json:"viewers_before"`
+	ViewersAfter  int          `json:"viewers_after"`
+	Sources       []RaidSource `json:"sources"`
+	Label         string       `json:"label"`
+}
+
+// RaidSource is a channel that probably sent viewers to a spike
+type RaidSource struct {
+	Username      string     `json:"username"`
+	ChannelID     *uint      `json:"channel_id,omitempty"`    // When the source is monitored
+	LivestreamID  *uint      `json:"livestream_id,omitempty"` // Its stream that ended
+	Evidence      []string   `json:"evidence"`
+	HostedViewers int        `json:"hosted_viewers,omitempty"` // Viewers the host event announced
+	EndedAt       *time.Time `json:"ended_at,omitempty"`       // Last snapshot of its stream
+	LastViewers   int        `json:"last_viewers,omitempty"`   // Its viewers at that snapshot
+	Confidence    string     `json:"confidence"`
+}
+
+// streamEnding is a stream of another monitored channel that went offline
+type streamEnding struct {
+	ChannelID    uint
+	Username     string
+	LivestreamID uint
+	EndedAt      time.Time
+	LastViewers  int
+}
+
+// loadStreamEndings returns the streams of the other monitored channels that went offline between start and end
+func loadStreamEndings(channelID uint, start, end time.Time) ([]streamEnding, error) {
+	// A stream went offline at its last snapshot once no newer one is expected
+	endedBefore := end
+	if offlineBefore := time.Now().Add(-(FetchInterval + LivestreamFreshnessLeeway)); offlineBefore.Before(endedBefore) {
+		endedBefore = offlineBefore
+	}
+	var endings []streamEnding
+	if err := db.Reader().Raw(`
+		SELECT ld.channel_id, mc.username, ld.livestream_id, MAX(ld.created_at) AS ended_at,
+			(ARRAY_AGG(ld.viewer_count ORDER BY ld.created_at DESC))[1] AS last_viewers
+		FROM livestream_data ld
+		JOIN monitored_channels mc ON mc.channel_id = ld.channel_id
+		WHERE ld.channel_id <> ? AND ld.livestream_id IN (
+			SELECT DISTINCT livestream_id FROM livestream_data WHERE channel_id <> ? AND created_at >= ? AND created_at < ?
+		)
+		GROUP BY ld.channel_id, mc.username, ld.livestream_id
+		HAVING MAX(ld.created_at) >= ? AND MAX(ld.created_at) < ?`,
+		channelID, channelID, start, end, start, endedBefore).
+		Scan(&endings).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stream endings around channel %d: %w", channelID, err)
+	}
+	return endings, nil
+}
+
+// detectRaidSpikes finds the viewer rises within [start, end) that a host or another channel's stream ending
+// explains. Rises nothing explains are left out, the milestones already mark the chart's peaks.
+func detectRaidSpikes(samples []models.LivestreamData, reactions []models.ReactionEvent, endings []streamEnding, start, end time.Time) []RaidSpike {
+	spikes := []RaidSpike{}
+	for _, rise := range detectViewerRises(samples, start, end) {
+		sources := raidSources(rise, reactions, endings)
+		if len(sources) == 0 {
+			continue
+		}
+		rise.Sources = sources
+		rise.Label = fmt.Sprintf("+%d viewers, probably from %s", rise.ViewersAfter-rise.ViewersBefore, sources[0].Username)
+		spikes = append(spikes, rise)
+	}
+	return spikes
+}
+
+// detectViewerRises finds the samples whose viewers rose by RaidSpikeMinRise and RaidSpikeMinViewers over the
+// lowest of the RaidSpikeWindow before. Consecutive rising samples are one rise, peaking at the highest.
+func detectViewerRises(samples []models.LivestreamData, start, end time.Time) []RaidSpike {
+	var rises []RaidSpike
+	for i, sample := range samples {
+		if sample.CreatedAt.Before(start) || !sample.CreatedAt.Before(end) {
+			continue
+		}
+		low := -1
+		for j := i - 1; j >= 0 && sample.CreatedAt.Sub(samples[j].CreatedAt) <= RaidSpikeWindow; j-- {
+			if low < 0 || samples[j].ViewerCount < samples[low].ViewerCount {
+				low = j
+			}
+		}
+		if low < 0 {
+			continue
+		}
+		before := samples[low].ViewerCount
+		gained := sample.ViewerCount - before
+		if gained < RaidSpikeMinViewers || float64(gained) < RaidSpikeMinRise*float64(max(before, 1)) {
+			continue
+		}
+
+		if n := len(rises); n > 0 && !samples[low].CreatedAt.After(rises[n-1].Time) {
+			// Still the same rise
+			if sample.ViewerCount > rises[n-1].ViewersAfter {
+				rises[n-1].Time, rises[n-1].ViewersAfter = sample.CreatedAt, sample.ViewerCount
+			}
+			continue
+		}
+		rises = append(rises, RaidSpike{
+			Time:          sample.CreatedAt,
+			RiseStartedAt: samples[low].CreatedAt,
+			ViewersBefore: before,
+			ViewersAfter:  sample.ViewerCount,
+		})
+	}
+	return rises
+}
+
+// raidSources lists the hosts and stream endings around a rise, most likely first
+func raidSources(rise RaidSpike, reactions []models.ReactionEvent, endings []streamEnding) []RaidSource {
+	from, to := rise.RiseStartedAt.Add(-RaidAttributionWindow), rise.Time
+	byUsername := make(map[string]*RaidSource)
+	source := func(username string) *RaidSource {
+		key := strings.ToLower(username)
+		if byUsername[key] == nil {
+			byUsername[key] = &RaidSource{Username: username, Evidence: []string{}}
+		}
+		return byUsername[key]
+	}
+
+	for _, reaction := range reactions {
+		if reaction.Kind != ReactionHost || reaction.Username == "" || reaction.CreatedAt.Before(from) || reaction.CreatedAt.After(to) {
+			continue
+		}
+		s := source(reaction.Username)
+		if !util.ContainsString(s.Evidence, RaidEvidenceHost) {
+			s.Evidence = append(s.Evidence, RaidEvidenceHost)
+		}
+		s.HostedViewers = max(s.HostedViewers, reaction.Amount)
+	}
+	for _, ending := range endings {
+		if ending.EndedAt.Before(from) || ending.EndedAt.After(to) {
+			continue
+		}
+		s := source(ending.Username)
+		channelID, livestreamID, endedAt := ending.ChannelID, ending.LivestreamID, ending.EndedAt
+		s.ChannelID, s.LivestreamID, s.EndedAt, s.LastViewers = &channelID, &livestreamID, &endedAt, ending.LastViewers
+		s.Evidence = append(s.Evidence, RaidEvidenceEnded)
+	}
+
+	gained := rise.ViewersAfter - rise.ViewersBefore
+	sources := make([]RaidSource, 0, len(byUsername))
+	for _, s := range byUsername {
+		switch {
+		case util.ContainsString(s.Evidence, RaidEvidenceHost):
+			s.Confidence = RaidConfidenceHigh
+		case float64(s.LastViewers) >= raidMatchingViewerHint*float64(gained):
+			s.Confidence = RaidConfidenceMedium
+		default:
+			s.Confidence = RaidConfidenceLow
+		}
+		sources = append(sources, *s)
+	}
+	rank := map[string]int{RaidConfidenceHigh: 0, RaidConfidenceMedium: 1, RaidConfidenceLow: 2}
+	sort.Slice(sources, func(i, j int) bool {
+		a, b := sources[i], sources[j]
+		if rank[a.Confidence] != rank[b.Confidence] {
+			return rank[a.Confidence] < rank[b.Confidence]
+		}
+		if a.HostedViewers != b.HostedViewers {
+			return a.HostedViewers > b.HostedViewers
+		}
+		if a.LastViewers != b.LastViewers {
+			return a.LastViewers > b.LastViewers
+		}
+		return a.Username < b.Username
+	})
+	if len(sources) > raidSourcesPerSpike {
+		sources = sources[:raidSourcesPerSpike]
+	}
+	return sources
+}
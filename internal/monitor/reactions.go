@@ -0,0 +1,225 @@
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Reaction kinds
+const (
+	ReactionSubscription    = "subscription"
+	ReactionGiftedSubs      = "gifted_subscriptions"
+	ReactionHost            = "host"
+	ReactionRewardRedeemed  = "reward_redeemed"
+	ReactionKicksGifted     = "kicks_gifted"
+	ReactionChatCelebration = "celebration" // Chat messages of type "celebration" (e.g. sub anniversaries)
+)
+
+const (
+	ReactionBurstWindow    = 60 * time.Second // Max gap between reactions of the same burst
+	ReactionBurstMinEvents = 3                // Min reactions for a burst
+	ReactionCompareWindow  = 5 * time.Minute  // Chat and viewer baseline before a burst, and impact window after it
+)
+
+// ReactionEvents maps the Pusher events treated as reactions/celebrations to their kind
+var ReactionEvents = map[string]string{
+	"App\\Events\\SubscriptionEvent":                      ReactionSubscription,
+	"App\\Events\\GiftedSubscriptionsEvent":               ReactionGiftedSubs,
+	"App\\Events\\LuckyUsersWhoGotGiftSubscriptionsEvent": ReactionGiftedSubs,
+	"App\\Events\\StreamHostEvent":                        ReactionHost,
+	"App\\Events\\RewardRedeemedEvent":                    ReactionRewardRedeemed,
+	"KicksGifted":                                         ReactionKicksGifted,
+}
+
+// ReactionEventData covers the payload fields of the reaction events we care about
+type ReactionEventData struct {
+	Username        string   `json:"username"`
+	GifterUsername  string   `json:"gifter_username"`
+	HostUsername    string   `json:"host_username"`
+	GiftedUsernames []string `json:"gifted_usernames"`
+	NumberViewers   int      `json:"number_viewers"`
+	Sender          *struct {
+		Username string `json:"username"`
+	} `json:"sender"`
+	User *struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+// actor returns whoever triggered the reaction
+func (d ReactionEventData) actor() string {
+	switch {
+	case d.GifterUsername != "":
+		return d.GifterUsername
+	case d.HostUsername != "":
+		return d.HostUsername
+	case d.Username != "":
+		return d.Username
+	case d.Sender != nil:
+		return d.Sender.Username
+	case d.User != nil:
+		return d.User.Username
+	}
+	return ""
+}
+
+// amount is the size of the reaction: subs gifted, viewers brought by a host, otherwise 1
+func (d ReactionEventData) amount() int {
+	if len(d.GiftedUsernames) > 0 {
+		return len(d.GiftedUsernames)
+	}
+	if d.NumberViewers > 0 {
+		return d.NumberViewers
+	}
+	return 1
+}
+
+// handleReactionEvent persists a reaction/celebration event received over Pusher.
+func handleReactionEvent(channel *models.MonitoredChannel, msg IncomingMessage, kind string, livestreamID *uint) {
+	var data ReactionEventData
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		log.Printf("Error unmarshalling %s event for %s: %v", kind, channel.Username, err)
+		return
+	}
+	saveReactionEvent(channel, msg.Event, kind, data.actor(), data.amount(), []byte(msg.Data), livestreamID)
+}
+
+func saveReactionEvent(channel *models.MonitoredChannel, event, kind, username string, amount int, raw []byte, livestreamID *uint) {
+	reaction := models.ReactionEvent{
+		ID:           uuid.New(),
+		ChannelID:    channel.ChannelID,
+		LivestreamID: livestreamID,
+		Event:        event,
+		Kind:         kind,
+		Username:     username,
+		Amount:       amount,
+		Data:         raw,
+	}
+	reactionEventWrites.enqueue(reaction, func(err error) {
+		if err != nil {
+			log.Printf("Error saving %s reaction for %s: %v", kind, channel.Username, err)
+			return
+		}
+		log.Printf("🎉 %s reaction on %s by %s (amount: %d)", kind, channel.Username, username, amount)
+	})
+}
+
+// ReactionsReport is the Reactions section of a livestream report
+type ReactionsReport struct {
+	TotalEvents  int             `json:"total_events"`
+	TotalAmount  int             `json:"total_amount"`
+	EventsByKind map[string]int  `json:"events_by_kind"`
+	Bursts       []ReactionBurst `json:"bursts"`
+}
+
+// ReactionBurst is a cluster of reactions, with chat rate and viewers compared before and after it
+type ReactionBurst struct {
+	Start           time.Time      `json:"start"`
+	End             time.Time      `json:"end"`
+	EventCount      int            `json:"event_count"`
+	Amount          int            `json:"amount"`
+	EventsByKind    map[string]int `json:"events_by_kind"`
+	ChatRateBefore  float64        `json:"chat_rate_before"` // Messages per minute in ReactionCompareWindow before the burst
+	ChatRateAfter   float64        `json:"chat_rate_after"`  // Messages per minute from the burst start until ReactionCompareWindow after it ends
+	ChatRateChange  float64        `json:"chat_rate_change"` // Relative change, 0.5 = +50%
+	ViewersBefore   int            `json:"viewers_before"`
+	ViewersAfter    int            `json:"viewers_after"` // Peak viewers within ReactionCompareWindow after the burst
+	ViewerChange    int            `json:"viewer_change"`
+	ViewerChangePct float64        `json:"viewer_change_pct"`
+}
+
+// buildReactionsReport summarizes the reactions of a livestream and correlates bursts with chat and viewers.
+// reactions, messages and viewerCounts must be sorted by time ascending.
+func buildReactionsReport(reactions []models.ReactionEvent, messages []models.ChatMessage, viewerCounts []models.LivestreamData) ReactionsReport {
+	report := ReactionsReport{
+		EventsByKind: make(map[string]int),
+		Bursts:       []ReactionBurst{},
+	}
+
+	var cluster []models.ReactionEvent
+	flush := func() {
+		if len(cluster) >= ReactionBurstMinEvents {
+			report.Bursts = append(report.Bursts, newReactionBurst(cluster, messages, viewerCounts))
+		}
+		cluster = nil
+	}
+
+	for _, reaction := range reactions {
+		report.TotalEvents++
+		report.TotalAmount += reaction.Amount
+		report.EventsByKind[reaction.Kind]++
+
+		if len(cluster) > 0 && reaction.CreatedAt.Sub(cluster[len(cluster)-1].CreatedAt) > ReactionBurstWindow {
+			flush()
+		}
+		cluster = append(cluster, reaction)
+	}
+	flush()
+
+	return report
+}
+
+func newReactionBurst(cluster []models.ReactionEvent, messages []models.ChatMessage, viewerCounts []models.LivestreamData) ReactionBurst {
+	burst := ReactionBurst{
+		Start:        cluster[0].CreatedAt,
+		End:          cluster[len(cluster)-1].CreatedAt,
+		EventCount:   len(cluster),
+		EventsByKind: make(map[string]int),
+	}
+	for _, reaction := range cluster {
+		burst.Amount += reaction.Amount
+		burst.EventsByKind[reaction.Kind]++
+	}
+
+	beforeStart := burst.Start.Add(-ReactionCompareWindow)
+	afterEnd := burst.End.Add(ReactionCompareWindow)
+
+	burst.ChatRateBefore = messagesPerMinute(messages, beforeStart, burst.Start)
+	burst.ChatRateAfter = messagesPerMinute(messages, burst.Start, afterEnd)
+	if burst.ChatRateBefore > 0 {
+		burst.ChatRateChange = (burst.ChatRateAfter - burst.ChatRateBefore) / burst.ChatRateBefore
+	}
+
+	for _, sample := range viewerCounts {
+		if !sample.CreatedAt.After(burst.Start) {
+			burst.ViewersBefore = sample.ViewerCount
+			continue
+		}
+		if sample.CreatedAt.After(afterEnd) {
+			break
+		}
+		burst.ViewersAfter = max(burst.ViewersAfter, sample.ViewerCount)
+	}
+	if burst.ViewersAfter == 0 {
+		burst.ViewersAfter = burst.ViewersBefore
+	}
+	burst.ViewerChange = burst.ViewersAfter - burst.ViewersBefore
+	if burst.ViewersBefore > 0 {
+		burst.ViewerChangePct = float64(burst.ViewerChange) / float64(burst.ViewersBefore) * 100.0
+	}
+
+	return burst
+}
+
+// messagesPerMinute counts messages sent in [from, to) and returns the per-minute rate
+func messagesPerMinute(messages []models.ChatMessage, from, to time.Time) float64 {
+	minutes := to.Sub(from).Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+
+	first := sort.Search(len(messages), func(i int) bool { return !messages[i].MessageSendTime.Before(from) })
+	count := 0
+	for _, msg := range messages[first:] {
+		if !msg.MessageSendTime.Before(to) {
+			break
+		}
+		count++
+	}
+	return float64(count) / minutes
+}
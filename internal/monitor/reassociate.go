@@ -0,0 +1,172 @@
+package monitor
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm"
+)
+
+// Orphaned chat messages (saved with a NULL livestream_id during fetch gaps) are periodically reassigned to the
+// livestream whose window contains them. A zero interval disables the job.
+var (
+	ReassociateInterval = util.GetEnvDuration("REASSOCIATE_INTERVAL", 15*time.Minute)
+	ReassociateLookback = util.GetEnvDuration("REASSOCIATE_LOOKBACK", 72*time.Hour) // Only messages and streams this recent are considered
+)
+
+// reassociateSQL assigns orphaned messages of a chatroom to the stream window containing their send time.
+// A window ends LivestreamEndGrace after the last live sample, but never past the next stream's start.
+const reassociateSQL = `
+WITH streams AS (
+	SELECT livestream_id,
+		MIN(CASE WHEN start_time > '2000-01-01' THEN start_time ELSE created_at END) AS started_at,
+		MAX(created_at) AS last_seen
+	FROM livestream_data
+	WHERE channel_id = @channel_id AND is_live AND created_at >= @since
+	GROUP BY livestream_id
+), windows AS (
+	SELECT livestream_id,
+		started_at - make_interval(secs => @leeway) AS window_start,
+		LEAST(last_seen + make_interval(secs => @grace),
+			COALESCE(LEAD(started_at) OVER (ORDER BY started_at), 'infinity')) AS window_end
+	FROM streams
+)
+UPDATE chat_messages m SET livestream_id = w.livestream_id
+FROM windows w
+WHERE m.livestream_id IS NULL AND m.chatroom_id = @chatroom_id AND m.created_at >= @since
+	AND m.message_send_time >= w.window_start AND m.message_send_time < w.window_end
+RETURNING w.livestream_id`
+
+// RunMessageReassociation reassigns orphaned chat messages every ReassociateInterval until stop is closed.
+func RunMessageReassociation(stop <-chan struct{}) {
+	if ReassociateInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ReassociateInterval)
+	defer ticker.Stop()
+
+	for {
+		ReassociateOrphanedMessages()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReassociateOrphanedMessages reassigns orphaned chat messages of every owned channel and marks the reports of
+// the livestreams that gained messages as stale. It returns the number of messages reassigned per livestream.
+func ReassociateOrphanedMessages() map[uint]int {
+	var channels []models.MonitoredChannel
+	if err := db.DB.Find(&channels).Error; err != nil {
+		log.Printf("Error loading channels for message reassociation: %v", err)
+		return nil
+	}
+
+	since := time.Now().Add(-ReassociateLookback)
+	reassigned := make(map[uint]int)
+	for _, channel := range channels {
+		// In cluster mode each instance reconciles the channels it owns
+		if OwnershipFilter != nil && !OwnershipFilter(channel.ChannelID) {
+			continue
+		}
+
+		var rows []struct{ LivestreamID uint }
+		if err := db.DB.Raw(reassociateSQL, map[string]any{
+			"channel_id":  channel.ChannelID,
+			"chatroom_id": channel.ChatroomID,
+			"since":       since,
+			"leeway":      LivestreamFreshnessLeeway.Seconds(),
+			"grace":       LivestreamEndGrace.Seconds(),
+		}).Scan(&rows).Error; err != nil {
+			log.Printf("Error reassociating orphaned messages for %s: %v", channel.Username, err)
+			continue
+		}
+
+		counts := make(map[uint]int)
+		for _, row := range rows {
+			counts[row.LivestreamID]++
+		}
+		for livestreamID, count := range counts {
+			reassigned[livestreamID] += count
+			log.Printf("Reassociated %d orphaned chat message(s) of %s with livestream %d", count, channel.Username, livestreamID)
+			markReportsStale(livestreamID, count)
+		}
+	}
+	return reassigned
+}
+
+// markReportsStale flags the existing reports of a livestream as missing messages; regenerating them clears the flag
+func markReportsStale(livestreamID uint, messages int) {
+	result := db.DB.Model(&models.LivestreamReport{}).
+		Where("livestream_id = ?", livestreamID).
+		Updates(map[string]any{
+			"stale":          true,
+			"stale_messages": gorm.Expr("stale_messages + ?", messages),
+		})
+	if result.Error != nil {
+		log.Printf("Error marking reports of livestream %d as stale: %v", livestreamID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d report(s) of livestream %d as stale (%d message(s) missing)", result.RowsAffected, livestreamID, messages)
+	}
+}
+
+// preShowSQL attaches the orphaned messages of a chatroom sent in the pre-show window to the livestream
+const preShowSQL = `
+UPDATE chat_messages SET livestream_id = @livestream_id
+WHERE livestream_id IS NULL AND chatroom_id = @chatroom_id
+	AND message_send_time >= @from AND message_send_time < @start
+RETURNING sender_id, sender_username`
+
+// attachPreShowMessages attaches the orphaned messages sent in the LivestreamPreShowWindow before a stream started,
+// chat waiting for the stream before the fetcher saw it live. The window never reaches back into the previous
+// stream's end grace. It returns the number of messages attached.
+func attachPreShowMessages(channel models.MonitoredChannel, livestreamID uint, start time.Time) (int, error) {
+	if LivestreamPreShowWindow <= 0 || start.IsZero() {
+		return 0, nil
+	}
+
+	from := start.Add(-LivestreamPreShowWindow)
+	var previousLastSeen sql.NullTime
+	if err := db.DB.Model(&models.LivestreamData{}).Select("MAX(created_at)").
+		Where("channel_id = ? AND livestream_id <> ? AND is_live AND created_at < ?", channel.ChannelID, livestreamID, start).
+		Scan(&previousLastSeen).Error; err != nil {
+		return 0, fmt.Errorf("failed to find the stream before livestream %d: %w", livestreamID, err)
+	}
+	if previousLastSeen.Valid && previousLastSeen.Time.Add(LivestreamEndGrace).After(from) {
+		from = previousLastSeen.Time.Add(LivestreamEndGrace)
+	}
+	if !from.Before(start) {
+		return 0, nil
+	}
+
+	var rows []struct {
+		SenderID       int
+		SenderUsername string
+	}
+	if err := db.DB.Raw(preShowSQL, map[string]any{
+		"livestream_id": livestreamID,
+		"chatroom_id":   channel.ChatroomID,
+		"from":          from,
+		"start":         start,
+	}).Scan(&rows).Error; err != nil {
+		return 0, fmt.Errorf("failed to attach pre-show messages to livestream %d: %w", livestreamID, err)
+	}
+
+	// Orphaned messages weren't counted, count them now so sampling ratios stay exact
+	for _, row := range rows {
+		countChatMessage(&models.ChatMessage{LivestreamID: &livestreamID, SenderID: row.SenderID, SenderUsername: row.SenderUsername}, true)
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// A report archive is a complete livestream report as one JSON document: the report, its chunk reports, their spam
+// reports and spam incidents. Rows are the to_json of the table row, like in backups, so every section, timelines
+// included, round-trips and archives taken before a column was added import with its default.
+const (
+	ReportArchiveFormat  = "kick-monitor/report"
+	reportArchiveVersion = 1
+)
+
+var (
+	ErrReportArchiveChunk   = errors.New("report is a chunk, export its parent report")
+	ErrReportArchiveExists  = errors.New("the livestream already has a report, import with replace to overwrite it")
+	ErrInvalidReportArchive = errors.New("invalid report archive")
+)
+
+// ReportArchive is a livestream report in the portable archive format
+type ReportArchive struct {
+	Format        string            `json:"format"`  // Always ReportArchiveFormat
+	Version       int               `json:"version"` // Of the archive layout, bumped on incompatible changes
+	ExportedAt    time.Time         `json:"exported_at"`
+	SchemaVersion int64             `json:"schema_version"` // Migration the exporting database was at
+	ChannelID     uint              `json:"channel_id"`
+	Username      string            `json:"username"`
+	LivestreamID  uint              `json:"livestream_id"`
+	ReportID      uuid.UUID         `json:"report_id"`      // The report, the others are its chunks
+	Reports       []json.RawMessage `json:"reports"`        // livestream_reports rows, the report first
+	SpamReports   []json.RawMessage `json:"spam_reports"`   // spam_reports rows
+	SpamIncidents []json.RawMessage `json:"spam_incidents"` // spam_incidents rows
+}
+
+// ReportImportResult is what an import wrote
+type ReportImportResult struct {
+	ReportID      uuid.UUID `json:"report_id"`
+	ChannelID     uint      `json:"channel_id"`
+	LivestreamID  uint      `json:"livestream_id"`
+	Reports       int       `json:"reports"`
+	SpamReports   int       `json:"spam_reports"`
+	SpamIncidents int       `json:"spam_incidents"`
+	Replaced      bool      `json:"replaced"` // A report of the livestream was overwritten
+}
+
+// ExportReportArchive returns the archive of a report and its chunks
+func ExportReportArchive(ctx context.Context, reportID uuid.UUID) (ReportArchive, error) {
+	var report models.LivestreamReport
+	if err := db.Reader().WithContext(ctx).First(&report, "id = ?", reportID).Error; err != nil {
+		return ReportArchive{}, err
+	}
+	if report.ParentReportID != nil {
+		return ReportArchive{}, ErrReportArchiveChunk
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return ReportArchive{}, err
+	}
+
+	archive := ReportArchive{
+		Format:        ReportArchiveFormat,
+		Version:       reportArchiveVersion,
+		ExportedAt:    time.Now().UTC(),
+		SchemaVersion: version,
+		ChannelID:     report.ChannelID,
+		Username:      report.Username,
+		LivestreamID:  report.LivestreamID,
+		ReportID:      report.ID,
+	}
+	sections := []struct {
+		target *[]json.RawMessage
+		query  string
+	}{
+		{&archive.Reports, `SELECT to_json(t)::text FROM livestream_reports t WHERE id = ? OR parent_report_id = ?
+			ORDER BY parent_report_id IS NOT NULL, chunk_index`},
+		{&archive.SpamReports, `SELECT to_json(t)::text FROM spam_reports t WHERE livestream_report_id IN
+			(SELECT id FROM livestream_reports WHERE id = ? OR parent_report_id = ?) ORDER BY created_at, id`},
+		{&archive.SpamIncidents, `SELECT to_json(t)::text FROM spam_incidents t WHERE livestream_report_id IN
+			(SELECT id FROM livestream_reports WHERE id = ? OR parent_report_id = ?) ORDER BY first_seen, id`},
+	}
+	for _, section := range sections {
+		var rows []string
+		if err := db.Reader().WithContext(ctx).Raw(section.query, report.ID, report.ID).Scan(&rows).Error; err != nil {
+			return ReportArchive{}, fmt.Errorf("failed to export report %s: %w", report.ID, err)
+		}
+		*section.target = make([]json.RawMessage, len(rows))
+		for i, row := range rows {
+			(*section.target)[i] = json.RawMessage(row)
+		}
+	}
+	return archive, nil
+}
+
+// archivedRow holds the columns of an archived row the import checks
+type archivedRow struct {
+	ID                 uuid.UUID  `json:"id"`
+	ParentReportID     *uuid.UUID `json:"parent_report_id"`
+	LivestreamReportID uuid.UUID  `json:"livestream_report_id"`
+	ChannelID          uint       `json:"channel_id"`
+	LivestreamID       uint       `json:"livestream_id"`
+}
+
+// ImportReportArchive stores an archived report like a generated one: it is listed on the channel's profile and, with
+// replace, takes the place of the livestream's current report. The channel needn't be monitored by this instance.
+func ImportReportArchive(ctx context.Context, archive ReportArchive, replace bool) (ReportImportResult, error) {
+	result := ReportImportResult{ReportID: archive.ReportID, ChannelID: archive.ChannelID, LivestreamID: archive.LivestreamID}
+	if err := validateReportArchive(archive); err != nil {
+		return result, err
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return result, err
+	}
+	if archive.SchemaVersion > version {
+		return result, fmt.Errorf("%w: exported at schema version %d, newer than this database (%d)", ErrInvalidReportArchive, archive.SchemaVersion, version)
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.LivestreamReport{}).Where("livestream_id = ?", archive.LivestreamID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to look up existing reports for livestream %d: %w", archive.LivestreamID, err)
+		}
+		if existing > 0 && !replace {
+			return ErrReportArchiveExists
+		}
+		result.Replaced = existing > 0
+		if err := deleteLivestreamReports(tx, archive.ChannelID, archive.LivestreamID); err != nil {
+			return err
+		}
+
+		// Spam reports first, the order reports are saved in
+		var err error
+		if len(archive.SpamReports) > 0 {
+			if result.SpamReports, err = restoreRows(tx, "spam_reports", archive.SpamReports); err != nil {
+				return err
+			}
+		}
+		if result.Reports, err = restoreRows(tx, "livestream_reports", archive.Reports); err != nil {
+			return err
+		}
+		if len(archive.SpamIncidents) > 0 {
+			if result.SpamIncidents, err = restoreRows(tx, "spam_incidents", archive.SpamIncidents); err != nil {
+				return err
+			}
+		}
+		if result.Reports != len(archive.Reports) {
+			return fmt.Errorf("%w: %d of its reports already exist under another livestream", ErrInvalidReportArchive, len(archive.Reports)-result.Reports)
+		}
+		return UpdateStreamerProfileLivestreams(tx, archive.ChannelID, archive.ReportID)
+	})
+	return result, err
+}
+
+// validateReportArchive checks the archive is complete and every row belongs to its report
+func validateReportArchive(archive ReportArchive) error {
+	if archive.Format != ReportArchiveFormat {
+		return fmt.Errorf("%w: format is %q, expected %q", ErrInvalidReportArchive, archive.Format, ReportArchiveFormat)
+	}
+	if archive.Version != reportArchiveVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidReportArchive, archive.Version)
+	}
+	if len(archive.Reports) == 0 {
+		return fmt.Errorf("%w: no reports", ErrInvalidReportArchive)
+	}
+
+	reportIDs := make(map[uuid.UUID]struct{}, len(archive.Reports))
+	for i, raw := range archive.Reports {
+		var row archivedRow
+		if err := json.Unmarshal(raw, &row); err != nil {
+			return fmt.Errorf("%w: report %d: %v", ErrInvalidReportArchive, i, err)
+		}
+		if row.ChannelID != archive.ChannelID || row.LivestreamID != archive.LivestreamID {
+			return fmt.Errorf("%w: report %s belongs to another channel or livestream", ErrInvalidReportArchive, row.ID)
+		}
+		switch {
+		case i == 0 && (row.ID != archive.ReportID || row.ParentReportID != nil):
+			return fmt.Errorf("%w: the first report must be report %s", ErrInvalidReportArchive, archive.ReportID)
+		case i > 0 && (row.ParentReportID == nil || *row.ParentReportID != archive.ReportID):
+			return fmt.Errorf("%w: report %s is not a chunk of report %s", ErrInvalidReportArchive, row.ID, archive.ReportID)
+		}
+		reportIDs[row.ID] = struct{}{}
+	}
+	for name, rows := range map[string][]json.RawMessage{"spam report": archive.SpamReports, "spam incident": archive.SpamIncidents} {
+		for i, raw := range rows {
+			var row archivedRow
+			if err := json.Unmarshal(raw, &row); err != nil {
+				return fmt.Errorf("%w: %s %d: %v", ErrInvalidReportArchive, name, i, err)
+			}
+			if _, ok := reportIDs[row.LivestreamReportID]; !ok || row.LivestreamID != archive.LivestreamID {
+				return fmt.Errorf("%w: %s %s belongs to another report", ErrInvalidReportArchive, name, row.ID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package monitor
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Report generation phases
+const (
+	ReportPhaseQueued       = "queued" // Waiting for a report slot
+	ReportPhaseFetching     = "fetching"
+	ReportPhaseAnalysing    = "analysing" // Processing chat messages
+	ReportPhaseSpamAnalysis = "spam_analysis"
+	ReportPhasePersisting   = "persisting"
+	ReportPhaseRunning      = "running" // The job runs on another instance, only its lease is known
+	ReportPhaseDone         = "done"
+	ReportPhaseFailed       = "failed"
+)
+
+const (
+	reportProgressInterval  = 250 * time.Millisecond // Min time between message count updates
+	reportProgressRetention = 10 * time.Minute       // How long finished jobs stay subscribable
+)
+
+// ErrReportJobNotFound is returned for job IDs that aren't report jobs
+var ErrReportJobNotFound = errors.New("report job not found")
+
+// ReportProgress is a progress update of a report generation job
+type ReportProgress struct {
+	JobID             uuid.UUID `json:"job_id"`
+	LivestreamID      uint      `json:"livestream_id"`
+	Phase             string    `json:"phase"`
+	MessagesProcessed int64     `json:"messages_processed"`
+	MessagesTotal     int64     `json:"messages_total"`
+	Percent           float64   `json:"percent"`
+	Error             string    `json:"error,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the job is over
+func (p ReportProgress) Finished() bool {
+	return p.Phase == ReportPhaseDone || p.Phase == ReportPhaseFailed
+}
+
+// reportProgress tracks a report job running on this instance. Its methods are no-ops on nil, so report code
+// runs the same without a tracker.
+type reportProgress struct {
+	processed   atomic.Int64
+	lastPublish atomic.Int64 // Unix nanoseconds
+
+	mu          sync.Mutex
+	state       ReportProgress
+	subscribers map[chan ReportProgress]struct{}
+}
+
+var reportJobs = struct {
+	sync.Mutex
+	jobs map[uuid.UUID]*reportProgress
+}{jobs: make(map[uuid.UUID]*reportProgress)}
+
+// trackReportProgress starts tracking the progress of a report job
+func trackReportProgress(jobID uuid.UUID, livestreamID uint) *reportProgress {
+	p := &reportProgress{
+		state:       ReportProgress{JobID: jobID, LivestreamID: livestreamID, Phase: ReportPhaseQueued, UpdatedAt: time.Now()},
+		subscribers: make(map[chan ReportProgress]struct{}),
+	}
+	reportJobs.Lock()
+	reportJobs.jobs[jobID] = p
+	reportJobs.Unlock()
+	return p
+}
+
+func (p *reportProgress) setPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Phase = phase
+	p.publishLocked()
+	p.mu.Unlock()
+}
+
+// expectMessages sets how many message analyses the job runs, chunked reports analyse every message twice
+func (p *reportProgress) expectMessages(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.MessagesTotal = int64(total)
+	p.mu.Unlock()
+}
+
+// messageProcessed counts an analysed message, publishing at most every reportProgressInterval
+func (p *reportProgress) messageProcessed() {
+	if p == nil {
+		return
+	}
+	p.processed.Add(1)
+	now := time.Now().UnixNano()
+	last := p.lastPublish.Load()
+	if now-last < int64(reportProgressInterval) || !p.lastPublish.CompareAndSwap(last, now) {
+		return
+	}
+	p.mu.Lock()
+	p.publishLocked()
+	p.mu.Unlock()
+}
+
+// finish publishes the final state, closes the subscriptions and forgets the job after reportProgressRetention
+func (p *reportProgress) finish(err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Phase = ReportPhaseDone
+	if err != nil {
+		p.state.Phase, p.state.Error = ReportPhaseFailed, err.Error()
+	}
+	p.publishLocked()
+	for ch := range p.subscribers {
+		close(ch)
+	}
+	p.subscribers = nil
+	p.mu.Unlock()
+
+	time.AfterFunc(reportProgressRetention, func() {
+		reportJobs.Lock()
+		delete(reportJobs.jobs, p.state.JobID)
+		reportJobs.Unlock()
+	})
+}
+
+// publishLocked refreshes the state and sends it to the subscribers. Slow subscribers miss intermediate updates.
+func (p *reportProgress) publishLocked() {
+	p.state.MessagesProcessed = p.processed.Load()
+	p.state.Percent = reportPercent(p.state)
+	p.state.UpdatedAt = time.Now()
+	for ch := range p.subscribers {
+		select {
+		case ch <- p.state:
+		default:
+		}
+	}
+}
+
+// reportPercent weights the phases by their usual share of the generation time: fetching up to 10%, message
+// analysis up to 85%, spam analysis and persisting the rest
+func reportPercent(state ReportProgress) float64 {
+	switch state.Phase {
+	case ReportPhaseQueued:
+		return 0
+	case ReportPhaseFetching:
+		return 5
+	case ReportPhaseAnalysing, ReportPhaseSpamAnalysis:
+		if state.MessagesTotal == 0 {
+			return 10
+		}
+		done := min(float64(state.MessagesProcessed)/float64(state.MessagesTotal), 1)
+		return float64(int((10+75*done)*10)) / 10
+	case ReportPhasePersisting:
+		return 95
+	case ReportPhaseDone, ReportPhaseFailed:
+		return 100
+	}
+	return 0
+}
+
+// SubscribeReportProgress subscribes to the progress of a report job running on this instance. It returns the
+// current state, and a channel closed when the job finishes (nil when it already did). ok is false when the
+// job isn't tracked here. The returned function must be called to unsubscribe.
+func SubscribeReportProgress(jobID uuid.UUID) (current ReportProgress, updates <-chan ReportProgress, unsubscribe func(), ok bool) {
+	reportJobs.Lock()
+	p, ok := reportJobs.jobs[jobID]
+	reportJobs.Unlock()
+	if !ok {
+		return ReportProgress{}, nil, func() {}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current = p.state
+	current.MessagesProcessed = p.processed.Load()
+	current.Percent = reportPercent(current)
+	if current.Finished() {
+		return current, nil, func() {}, true
+	}
+
+	ch := make(chan ReportProgress, 16)
+	p.subscribers[ch] = struct{}{}
+	unsubscribe = func() {
+		p.mu.Lock()
+		if p.subscribers != nil {
+			delete(p.subscribers, ch)
+		}
+		p.mu.Unlock()
+	}
+	return current, ch, unsubscribe, true
+}
+
+// GetReportJobProgress returns the progress of a report job from its lease, for jobs running on other instances
+func GetReportJobProgress(jobID uuid.UUID) (ReportProgress, error) {
+	var lease models.JobLease
+	if err := db.DB.Where("id = ? AND kind = ?", jobID, JobKindReport).First(&lease).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ReportProgress{}, ErrReportJobNotFound
+		}
+		return ReportProgress{}, err
+	}
+
+	livestreamID, _ := strconv.ParseUint(lease.JobKey, 10, 64)
+	progress := ReportProgress{JobID: lease.ID, LivestreamID: uint(livestreamID), Phase: ReportPhaseRunning, UpdatedAt: lease.HeartbeatAt}
+	switch lease.Status {
+	case JobStatusDone:
+		progress.Phase = ReportPhaseDone
+	case JobStatusFailed, JobStatusAbandoned:
+		progress.Phase, progress.Error = ReportPhaseFailed, lease.Error
+	}
+	if lease.FinishedAt != nil {
+		progress.UpdatedAt = *lease.FinishedAt
+	}
+	progress.Percent = reportPercent(progress)
+	return progress, nil
+}
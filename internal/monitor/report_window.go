@@ -0,0 +1,131 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidReportWindow is returned for report windows that end before they start
+var ErrInvalidReportWindow = errors.New("report window must end after it starts")
+
+// ReportWindow restricts a livestream report to part of the stream, e.g. a sponsored segment. Every metric is
+// computed from the messages, viewer samples, reactions and clips within it. Windowed reports are kept per livestream
+// and window next to the full report, which they never replace, and regenerating a window overwrites only its own
+// report. Nil bounds default to the first and last chat message.
+type ReportWindow struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// IsZero reports whether the window covers the whole stream
+func (w ReportWindow) IsZero() bool {
+	return w.Start == nil && w.End == nil
+}
+
+// Validate checks the window bounds
+func (w ReportWindow) Validate() error {
+	if w.Start != nil && w.End != nil && !w.End.After(*w.Start) {
+		return ErrInvalidReportWindow
+	}
+	return nil
+}
+
+// scope restricts a chat message query to the window
+func (w ReportWindow) scope(query *gorm.DB) *gorm.DB {
+	if w.Start != nil {
+		query = query.Where("message_send_time >= ?", *w.Start)
+	}
+	if w.End != nil {
+		query = query.Where("message_send_time < ?", *w.End)
+	}
+	return query
+}
+
+func (w ReportWindow) String() string {
+	switch {
+	case w.Start != nil && w.End != nil:
+		return fmt.Sprintf(" between %s and %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+	case w.Start != nil:
+		return fmt.Sprintf(" after %s", w.Start.Format(time.RFC3339))
+	case w.End != nil:
+		return fmt.Sprintf(" before %s", w.End.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// where matches the window reports of exactly this window, open bounds included
+func (w ReportWindow) where(query *gorm.DB) *gorm.DB {
+	if w.Start != nil {
+		query = query.Where("window_start = ?", *w.Start)
+	} else {
+		query = query.Where("window_start IS NULL")
+	}
+	if w.End != nil {
+		return query.Where("window_end = ?", *w.End)
+	}
+	return query.Where("window_end IS NULL")
+}
+
+// persistWindowReport saves a windowed report, replacing the previous report of the same livestream and window
+func persistWindowReport(window ReportWindow, report models.LivestreamReport, spamReport models.SpamReport) error {
+	body, err := json.Marshal(restructureReport(report, &spamReport))
+	if err != nil {
+		return fmt.Errorf("failed to marshal window report for livestream %d: %w", report.LivestreamID, err)
+	}
+	windowReport := models.LivestreamWindowReport{
+		ID:           report.ID,
+		ChannelID:    report.ChannelID,
+		LivestreamID: report.LivestreamID,
+		WindowStart:  window.Start,
+		WindowEnd:    window.End,
+		Report:       body,
+	}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := window.where(tx.Where("livestream_id = ?", report.LivestreamID)).Delete(&models.LivestreamWindowReport{}).Error; err != nil {
+			return fmt.Errorf("failed to delete previous window report for livestream %d%s: %w", report.LivestreamID, window, err)
+		}
+		if err := tx.Create(&windowReport).Error; err != nil {
+			return fmt.Errorf("failed to save window report for livestream %d%s: %w", report.LivestreamID, window, err)
+		}
+		return nil
+	})
+}
+
+// WindowReport is a report restricted to part of a livestream
+type WindowReport struct {
+	ID           uuid.UUID       `json:"id"`
+	LivestreamID uint            `json:"livestream_id"`
+	WindowStart  *time.Time      `json:"window_start,omitempty"`
+	WindowEnd    *time.Time      `json:"window_end,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Report       json.RawMessage `json:"report"` // Same shape as the full report
+}
+
+// GetWindowReports returns the windowed reports of a livestream, by window start
+func GetWindowReports(livestreamID uint) ([]WindowReport, error) {
+	var rows []models.LivestreamWindowReport
+	if err := db.Reader().Where("livestream_id = ?", livestreamID).
+		Order("window_start ASC NULLS FIRST, window_end ASC NULLS LAST").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch window reports for livestream %d: %w", livestreamID, err)
+	}
+	reports := make([]WindowReport, len(rows))
+	for i, row := range rows {
+		reports[i] = WindowReport{
+			ID:           row.ID,
+			LivestreamID: row.LivestreamID,
+			WindowStart:  row.WindowStart,
+			WindowEnd:    row.WindowEnd,
+			CreatedAt:    row.CreatedAt,
+			Report:       row.Report,
+		}
+	}
+	return reports, nil
+}
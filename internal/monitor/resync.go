@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoChannelSnapshot is returned when a channel has no channel_data snapshot to rebuild its profile from
+var ErrNoChannelSnapshot = errors.New("no channel snapshot to rebuild from")
+
+// ChannelResync tells what ResyncChannel rebuilt
+type ChannelResync struct {
+	ChannelID      uint      `json:"channel_id"`
+	Username       string    `json:"username"`
+	SnapshotTime   time.Time `json:"snapshot_time"`   // Of the channel_data snapshot the profile attributes came from
+	FollowerPoints int       `json:"follower_points"` // Points of the rebuilt followers timeline, one per snapshot
+	Livestreams    int       `json:"livestreams"`
+	Created        bool      `json:"created"` // The channel had no profile yet
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// ResyncChannel rebuilds the derived data of one channel from its raw data: the profile attributes from the latest
+// channel_data snapshot, the followers timeline from every snapshot and the livestream list from the reports.
+// The profile row is locked while it is rebuilt, a fetch of the channel meanwhile appends its point afterwards.
+func ResyncChannel(channelID uint) (*ChannelResync, error) {
+	started := time.Now()
+	var channel models.MonitoredChannel
+	if err := db.DB.Where("channel_id = ?", channelID).First(&channel).Error; err != nil {
+		return nil, err
+	}
+
+	var snapshot models.ChannelData
+	if err := db.DB.Where("channel_id = ?", channelID).Order("created_at DESC").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoChannelSnapshot
+		}
+		return nil, fmt.Errorf("failed to fetch latest snapshot of channel %d: %w", channelID, err)
+	}
+	var kickData KickChannelResponse
+	if err := json.Unmarshal(snapshot.Data, &kickData); err != nil {
+		return nil, fmt.Errorf("failed to decode channel snapshot %s: %w", snapshot.ID.String(), err)
+	}
+
+	// The same list as buildLivestreamsList, which only logs a failure a fetch can live with
+	livestreams := []uuid.UUID{}
+	if err := db.DB.Model(&models.LivestreamReport{}).Where("channel_id = ? AND parent_report_id IS NULL", channelID).
+		Pluck("id", &livestreams).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch livestreams of channel %d: %w", channelID, err)
+	}
+	livestreamsJSON, err := json.Marshal(livestreams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal livestream list: %w", err)
+	}
+	result := ChannelResync{ChannelID: channelID, Username: channel.Username, SnapshotTime: snapshot.CreatedAt, Livestreams: len(livestreams)}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var profile models.StreamerProfile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("followers_count").
+			Where("channel_id = ?", channelID).Take(&profile).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			profile = models.StreamerProfile{ChannelID: channelID}
+			result.Created = true
+		case err != nil:
+			return fmt.Errorf("failed to lock profile of channel %d: %w", channelID, err)
+		}
+
+		snapshots, err := loadFollowersSnapshots(tx, channelID)
+		if err != nil {
+			return err
+		}
+		followersJSON, err := json.Marshal(snapshots)
+		if err != nil {
+			return fmt.Errorf("failed to marshal followers timeline: %w", err)
+		}
+		result.FollowerPoints = len(snapshots)
+
+		now := time.Now().UTC()
+		profile.Username = channel.Username
+		populateProfileAttributes(&profile, kickData)
+		profile.FollowersCount = followersJSON
+		profile.FollowersBackfilledAt = &now
+		profile.Livestreams = livestreamsJSON
+		if result.Created {
+			return tx.Create(&profile).Error
+		}
+		return tx.Save(&profile).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resync channel %d: %w", channelID, err)
+	}
+
+	result.DurationMs = time.Since(started).Milliseconds()
+	log.Printf("Resynced channel %s (ID: %d): %d followers points, %d livestreams", channel.Username, channelID,
+		result.FollowerPoints, result.Livestreams)
+	return &result, nil
+}
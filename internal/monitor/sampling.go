@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Giant streams can persist only 1 in N chat messages. A channel samples when its sample_rate is above 1 or,
+// with SAMPLING_VIEWER_THRESHOLD set, automatically while it has at least that many viewers. Every message is
+// still counted per chatter in chat_message_counts, so report totals stay exact.
+var (
+	SamplingViewerThreshold  = util.GetEnvInt("SAMPLING_VIEWER_THRESHOLD", 0) // 0 disables automatic sampling
+	SamplingAutoRate         = util.GetEnvInt("SAMPLING_AUTO_RATE", 10)
+	ChatCounterFlushInterval = util.GetEnvDuration("CHAT_COUNTER_FLUSH_INTERVAL", 30*time.Second)
+)
+
+// MaxSampleRate is the largest accepted per-channel sample rate
+const MaxSampleRate = 1000
+
+// sampleRateRefresh is how long a channel's configured sample rate is cached before being reloaded
+const sampleRateRefresh = time.Minute
+
+type cachedSampleRate struct {
+	Rate     int
+	LoadedAt time.Time
+}
+
+// SetChannelSampleRate persists a channel's sample rate (1 disables sampling) and applies it immediately
+func SetChannelSampleRate(channelID uint, rate int) error {
+	if rate < 1 || rate > MaxSampleRate {
+		return fmt.Errorf("sample rate must be between 1 and %d", MaxSampleRate)
+	}
+	if err := db.DB.Model(&models.MonitoredChannel{}).Where("channel_id = ?", channelID).Update("sample_rate", rate).Error; err != nil {
+		return err
+	}
+	cacheChannelSampleRate(channelID, rate)
+	return nil
+}
+
+// EffectiveSampleRate returns N when 1 in N messages of the channel are persisted right now
+func EffectiveSampleRate(channelID uint) int {
+	rate := configuredSampleRate(channelID)
+	if rate <= 1 && SamplingViewerThreshold > 0 && SamplingAutoRate > 1 {
+		if snapshot, ok := livestreamSnapshot(channelID); ok && snapshot.ViewerCount >= SamplingViewerThreshold {
+			rate = SamplingAutoRate
+		}
+	}
+	return max(rate, 1)
+}
+
+// configuredSampleRate returns the channel's sample_rate, cached for sampleRateRefresh so changes made through
+// another instance are picked up
+func configuredSampleRate(channelID uint) int {
+	if rate, ok := cachedChannelSampleRate(channelID, sampleRateRefresh); ok {
+		return rate
+	}
+
+	rate := 1
+	if err := db.DB.Model(&models.MonitoredChannel{}).Select("sample_rate").Where("channel_id = ?", channelID).Scan(&rate).Error; err != nil {
+		log.Printf("Error loading sample rate for channel %d: %v", channelID, err)
+	}
+	cacheChannelSampleRate(channelID, rate)
+	return rate
+}
+
+// shouldPersistMessage decides whether a chat message is persisted. Messages outside a livestream and messages
+// of flagged chatters are always kept.
+func shouldPersistMessage(channelID uint, msg *models.ChatMessage) bool {
+	if msg.LivestreamID == nil || msg.Flagged {
+		return true
+	}
+	rate := EffectiveSampleRate(channelID)
+	if rate <= 1 {
+		return true
+	}
+	return nextSampledMessage(channelID)%uint64(rate) == 0
+}
+
+type chatCounterKey struct {
+	LivestreamID uint
+	SenderID     int
+}
+
+// chatCounters accumulates message counts in memory until the next flush
+var chatCounters = struct {
+	sync.Mutex
+	counts map[chatCounterKey]*models.ChatMessageCount
+}{counts: make(map[chatCounterKey]*models.ChatMessageCount)}
+
+// countChatMessage records a message received during a livestream, persisted or not
+func countChatMessage(msg *models.ChatMessage, persisted bool) {
+	if msg.LivestreamID == nil {
+		return
+	}
+
+	key := chatCounterKey{LivestreamID: *msg.LivestreamID, SenderID: msg.SenderID}
+	chatCounters.Lock()
+	defer chatCounters.Unlock()
+	count, ok := chatCounters.counts[key]
+	if !ok {
+		count = &models.ChatMessageCount{LivestreamID: key.LivestreamID, SenderID: key.SenderID, SenderUsername: msg.SenderUsername}
+		chatCounters.counts[key] = count
+	}
+	count.Messages++
+	if persisted {
+		count.PersistedMessages++
+	}
+}
+
+// RunChatCounterFlusher writes the in-memory message counts to chat_message_counts every ChatCounterFlushInterval
+// and once more when stop is closed.
+func RunChatCounterFlusher(stop <-chan struct{}) {
+	interval := ChatCounterFlushInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			flushChatCounters()
+			return
+		case <-ticker.C:
+			flushChatCounters()
+		}
+	}
+}
+
+// flushChatCounters adds the accumulated counts to chat_message_counts. On failure they are kept for the next flush.
+func flushChatCounters() {
+	chatCounters.Lock()
+	pending := chatCounters.counts
+	chatCounters.counts = make(map[chatCounterKey]*models.ChatMessageCount)
+	chatCounters.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	rows := make([]models.ChatMessageCount, 0, len(pending))
+	for _, count := range pending {
+		rows = append(rows, *count)
+	}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "livestream_id"}, {Name: "sender_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"sender_username":    gorm.Expr("excluded.sender_username"),
+			"messages":           gorm.Expr("chat_message_counts.messages + excluded.messages"),
+			"persisted_messages": gorm.Expr("chat_message_counts.persisted_messages + excluded.persisted_messages"),
+			"updated_at":         gorm.Expr("excluded.updated_at"),
+		}),
+	}).CreateInBatches(&rows, Capacity.DBBatchSize).Error
+	if err == nil {
+		return
+	}
+
+	log.Printf("Error flushing %d chat message counter(s): %v", len(rows), err)
+	recordDBWriteError("chat_message_counts", err)
+	chatCounters.Lock()
+	defer chatCounters.Unlock()
+	for key, count := range pending {
+		if current, ok := chatCounters.counts[key]; ok {
+			current.Messages += count.Messages
+			current.PersistedMessages += count.PersistedMessages
+		} else {
+			chatCounters.counts[key] = count
+		}
+	}
+}
+
+// messageSampling holds the exact message counts of a livestream some of whose messages weren't persisted
+type messageSampling struct {
+	Messages   int         // Every message received
+	Persisted  int         // Messages saved to chat_messages
+	PerChatter map[int]int // Messages per sender ID; nil for chunk reports, which only get scaled estimates
+	Excluded   int         // Messages of excluded chatters, left out of the counts above
+}
+
+// Ratio is the number of messages received per message persisted
+func (s *messageSampling) Ratio() float64 {
+	if s == nil || s.Persisted == 0 || s.Messages <= s.Persisted {
+		return 1
+	}
+	return float64(s.Messages) / float64(s.Persisted)
+}
+
+// scale estimates the number of messages received from the number persisted
+func (s *messageSampling) scale(persisted int) int {
+	return int(math.Round(float64(persisted) * s.Ratio()))
+}
+
+// loadMessageSampling flushes pending counters and returns the livestream's exact counts without the excluded
+// chatters, or nil when every message was persisted (or no counts were recorded, e.g. for messages received
+// before counting existed)
+func loadMessageSampling(livestreamID uint, excluded map[int]struct{}) (*messageSampling, error) {
+	flushChatCounters()
+
+	var counts []models.ChatMessageCount
+	if err := db.DB.Where("livestream_id = ?", livestreamID).Find(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chat message counts for livestream %d: %w", livestreamID, err)
+	}
+
+	sampling := &messageSampling{PerChatter: make(map[int]int, len(counts))}
+	for _, count := range counts {
+		if _, ok := excluded[count.SenderID]; ok {
+			sampling.Excluded += count.Messages
+			continue
+		}
+		sampling.Messages += count.Messages
+		sampling.Persisted += count.PersistedMessages
+		sampling.PerChatter[count.SenderID] += count.Messages
+	}
+	if sampling.Messages <= sampling.Persisted {
+		return nil, nil
+	}
+	return sampling, nil
+}
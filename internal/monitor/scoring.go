@@ -0,0 +1,176 @@
+package monitor
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Suspicious chatter issue types
+const (
+	IssueRapidMessageBursts   = "rapid_message_bursts"
+	IssueSuspiciousUsername   = "suspicious_username"
+	IssueExactDuplicateBursts = "exact_duplicate_bursts"
+	IssueSimilarMessageBursts = "similar_message_bursts"
+	IssueFlaggedByModerator   = "flagged_by_moderator"
+	IssueUnicodeAbuse         = "unicode_abuse"      // Zalgo, combining mark walls, bidi overrides or emoji floods
+	IssueConfirmedOffender    = "confirmed_offender" // Confirmed as a spammer in the moderation queue, on any channel
+)
+
+// SuspicionSignalCap limits how many occurrences of one signal count towards the score,
+// so a single very noisy signal can't dominate the ranking
+const SuspicionSignalCap = 5
+
+// DefaultSuspicionWeights are the points each occurrence of an issue contributes to a chatter's score
+var DefaultSuspicionWeights = map[string]float64{
+	IssueRapidMessageBursts:   3.0,
+	IssueSuspiciousUsername:   2.0,
+	IssueExactDuplicateBursts: 2.5,
+	IssueSimilarMessageBursts: 1.5,
+	IssueFlaggedByModerator:   4.0,
+	IssueUnicodeAbuse:         2.0,
+	IssueConfirmedOffender:    3.0,
+}
+
+// SuspicionWeights is DefaultSuspicionWeights overridden by SUSPICION_WEIGHTS ("rapid_message_bursts=3,suspicious_username=1")
+var SuspicionWeights = suspicionWeightsFromEnv()
+
+func suspicionWeightsFromEnv() map[string]float64 {
+	weights := make(map[string]float64, len(DefaultSuspicionWeights))
+	for issue, weight := range DefaultSuspicionWeights {
+		weights[issue] = weight
+	}
+
+	raw := strings.TrimSpace(os.Getenv("SUSPICION_WEIGHTS"))
+	if raw == "" {
+		return weights
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		issue, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		issue = strings.TrimSpace(issue)
+		if !ok {
+			util.InvalidSetting("invalid SUSPICION_WEIGHTS entry %q, expected issue=weight", pair)
+			continue
+		}
+		if _, known := DefaultSuspicionWeights[issue]; !known {
+			util.InvalidSetting("unknown issue %q in SUSPICION_WEIGHTS", issue)
+			continue
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			util.InvalidSetting("invalid weight for %s in SUSPICION_WEIGHTS (%q), using default %v", issue, value, weights[issue])
+			continue
+		}
+		weights[issue] = weight
+	}
+	return weights
+}
+
+// SuspicionSignal explains how much one issue contributed to a chatter's score
+type SuspicionSignal struct {
+	Issue        string  `json:"issue"`
+	Occurrences  int     `json:"occurrences"` // Times the issue was detected
+	Counted      int     `json:"counted"`     // Occurrences counted towards the score (capped)
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"` // Counted * Weight
+	Share        float64 `json:"share"`        // Fraction of the total score
+}
+
+// recordSuspicionSignal notes an occurrence of an issue for a sender. Callers must hold the metrics lock.
+func (m *ReportMetrics) recordSuspicionSignal(senderID int, issue string) {
+	if m.SuspicionSignals[senderID] == nil {
+		m.SuspicionSignals[senderID] = make(map[string]int)
+	}
+	m.SuspicionSignals[senderID][issue]++
+}
+
+// scoreSuspiciousChatters adds every sender with a recorded signal to the suspicious chatters list, scores them
+// with SuspicionWeights and returns the list ranked by score (rank 1 is the most suspicious).
+func scoreSuspiciousChatters(metrics *ReportMetrics, userMessageHistory map[int][]models.ChatMessage) []SuspiciousChatterReport {
+	byID := make(map[int]int, len(metrics.SuspiciousChattersList))
+	for i, chatter := range metrics.SuspiciousChattersList {
+		byID[chatter.UserID] = i
+	}
+
+	for senderID, signals := range metrics.SuspicionSignals {
+		idx, ok := byID[senderID]
+		if !ok {
+			msgs := userMessageHistory[senderID]
+			if len(msgs) == 0 {
+				continue
+			}
+			metrics.SuspiciousChattersList = append(metrics.SuspiciousChattersList, SuspiciousChatterReport{
+				UserID:            senderID,
+				Username:          msgs[0].SenderUsername,
+				PotentialIssues:   []string{},
+				MessageTimestamps: []time.Time{},
+				ExampleMessages:   []string{},
+			})
+			idx = len(metrics.SuspiciousChattersList) - 1
+			byID[senderID] = idx
+		}
+
+		chatter := &metrics.SuspiciousChattersList[idx]
+		for issue := range signals {
+			if !util.ContainsString(chatter.PotentialIssues, issue) {
+				chatter.PotentialIssues = append(chatter.PotentialIssues, issue)
+			}
+		}
+		sort.Strings(chatter.PotentialIssues)
+	}
+
+	for i := range metrics.SuspiciousChattersList {
+		chatter := &metrics.SuspiciousChattersList[i]
+		chatter.Score, chatter.Explanation = suspicionScore(metrics.SuspicionSignals[chatter.UserID])
+	}
+
+	sort.SliceStable(metrics.SuspiciousChattersList, func(i, j int) bool {
+		a, b := metrics.SuspiciousChattersList[i], metrics.SuspiciousChattersList[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Username < b.Username
+	})
+	for i := range metrics.SuspiciousChattersList {
+		metrics.SuspiciousChattersList[i].Rank = i + 1
+	}
+	return metrics.SuspiciousChattersList
+}
+
+// suspicionScore weighs the signals of one chatter and explains each contribution, largest first
+func suspicionScore(signals map[string]int) (float64, []SuspicionSignal) {
+	explanation := make([]SuspicionSignal, 0, len(signals))
+	total := 0.0
+	for issue, occurrences := range signals {
+		counted := min(occurrences, SuspicionSignalCap)
+		weight := SuspicionWeights[issue]
+		signal := SuspicionSignal{
+			Issue:        issue,
+			Occurrences:  occurrences,
+			Counted:      counted,
+			Weight:       weight,
+			Contribution: float64(counted) * weight,
+		}
+		total += signal.Contribution
+		explanation = append(explanation, signal)
+	}
+
+	for i := range explanation {
+		if total > 0 {
+			explanation[i].Share = math.Round(explanation[i].Contribution/total*1000) / 1000
+		}
+	}
+	sort.Slice(explanation, func(i, j int) bool {
+		if explanation[i].Contribution != explanation[j].Contribution {
+			return explanation[i].Contribution > explanation[j].Contribution
+		}
+		return explanation[i].Issue < explanation[j].Issue
+	})
+	return total, explanation
+}
@@ -0,0 +1,210 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/notify"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Self-monitoring thresholds, overridable through the environment
+var (
+	AlertFetchFailureIntervals = util.GetEnvInt("ALERT_FETCH_FAILURE_INTERVALS", 3)    // Consecutive failed fetches before alerting
+	AlertReconnectsPerHour     = util.GetEnvInt("ALERT_WS_RECONNECTS_PER_HOUR", 10)    // WebSocket reconnects per hour before alerting
+	AlertDBErrorsPerMinute     = util.GetEnvInt("ALERT_DB_ERRORS_PER_MINUTE", 20)      // DB write errors per minute before alerting
+	AlertCooldown              = util.GetEnvDuration("ALERT_COOLDOWN", 30*time.Minute) // Minimum time between identical alerts
+)
+
+// channelHealth tracks failure signals for a single monitored channel
+type channelHealth struct {
+	ConsecutiveFetchFailures int
+	LastSuccessfulFetch      time.Time
+	Reconnects               []time.Time
+}
+
+// selfMonitor aggregates failure signals and raises alerts through the notify package
+type selfMonitor struct {
+	sync.Mutex
+
+	channels      map[string]*channelHealth // username -> health
+	dbWriteErrors []time.Time
+	lastAlerted   map[string]time.Time // alert key -> last sent
+}
+
+var health = &selfMonitor{
+	channels:    make(map[string]*channelHealth),
+	lastAlerted: make(map[string]time.Time),
+}
+
+func (s *selfMonitor) channel(username string) *channelHealth {
+	ch, ok := s.channels[username]
+	if !ok {
+		ch = &channelHealth{}
+		s.channels[username] = ch
+	}
+	return ch
+}
+
+// shouldAlert reports whether an alert with the given key is outside its cooldown. Caller must hold the lock.
+func (s *selfMonitor) shouldAlert(key string, now time.Time) bool {
+	if last, ok := s.lastAlerted[key]; ok && now.Sub(last) < AlertCooldown {
+		return false
+	}
+	s.lastAlerted[key] = now
+	return true
+}
+
+// recordFetchSuccess resets the failure counter for a channel
+func recordFetchSuccess(username string) {
+	health.Lock()
+	defer health.Unlock()
+
+	ch := health.channel(username)
+	ch.ConsecutiveFetchFailures = 0
+	ch.LastSuccessfulFetch = time.Now()
+}
+
+// recordFetchFailure counts a failed fetch and alerts once the configured number of intervals is reached
+func recordFetchFailure(username string) {
+	health.Lock()
+	defer health.Unlock()
+
+	now := time.Now()
+	ch := health.channel(username)
+	ch.ConsecutiveFetchFailures++
+
+	if ch.ConsecutiveFetchFailures < AlertFetchFailureIntervals {
+		return
+	}
+	if !health.shouldAlert("fetch_failures:"+username, now) {
+		return
+	}
+
+	lastSuccess := "never"
+	if !ch.LastSuccessfulFetch.IsZero() {
+		lastSuccess = ch.LastSuccessfulFetch.Format(time.RFC3339)
+	}
+	notify.SendAsync(notify.Alert{
+		Kind:      "fetch_failures",
+		Severity:  notify.SeverityCritical,
+		Subject:   username,
+		Message:   fmt.Sprintf("No successful fetch for %d consecutive intervals (last success: %s)", ch.ConsecutiveFetchFailures, lastSuccess),
+		Timestamp: now,
+	})
+}
+
+// recordReconnect counts a WebSocket reconnect and alerts when the hourly threshold is exceeded
+func recordReconnect(username string) {
+	health.Lock()
+	defer health.Unlock()
+
+	now := time.Now()
+	ch := health.channel(username)
+	ch.Reconnects = pruneBefore(append(ch.Reconnects, now), now.Add(-time.Hour))
+
+	if len(ch.Reconnects) <= AlertReconnectsPerHour {
+		return
+	}
+	if !health.shouldAlert("ws_reconnects:"+username, now) {
+		return
+	}
+
+	notify.SendAsync(notify.Alert{
+		Kind:      "ws_reconnects",
+		Severity:  notify.SeverityWarning,
+		Subject:   username,
+		Message:   fmt.Sprintf("WebSocket reconnected %d times in the last hour", len(ch.Reconnects)),
+		Timestamp: now,
+	})
+}
+
+// recordDBWriteError counts a failed DB write and alerts when the per-minute error rate spikes
+func recordDBWriteError(table string, err error) {
+	health.Lock()
+	defer health.Unlock()
+
+	now := time.Now()
+	health.dbWriteErrors = pruneBefore(append(health.dbWriteErrors, now), now.Add(-time.Minute))
+
+	if len(health.dbWriteErrors) <= AlertDBErrorsPerMinute {
+		return
+	}
+	if !health.shouldAlert("db_write_errors", now) {
+		return
+	}
+
+	notify.SendAsync(notify.Alert{
+		Kind:      "db_write_errors",
+		Severity:  notify.SeverityCritical,
+		Subject:   "database",
+		Message:   fmt.Sprintf("%d DB write errors in the last minute (latest on %s: %v)", len(health.dbWriteErrors), table, err),
+		Timestamp: now,
+	})
+}
+
+// pruneBefore drops timestamps older than cutoff, assuming ts is sorted ascending
+func pruneBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
+
+// MonitorStatus describes a channel monitored by this instance, for the admin monitors endpoint
+type MonitorStatus struct {
+	ChannelID                uint              `json:"channel_id"`
+	Username                 string            `json:"username"`
+	PollInterval             string            `json:"poll_interval"`
+	ConsecutiveFetchFailures int               `json:"consecutive_fetch_failures"`
+	LastSuccessfulFetch      *time.Time        `json:"last_successful_fetch,omitempty"`
+	ReconnectsLastHour       int               `json:"reconnects_last_hour"`
+	ChatSource               string            `json:"chat_source"` // websocket, or polling while the WebSocket is blocked
+	ProxyBudget              ProxyBudgetStatus `json:"proxy_budget"`
+}
+
+// GetMonitorStatuses returns the status of every channel monitored by this instance
+func GetMonitorStatuses() ([]MonitorStatus, error) {
+	ids := MonitoredChannelIDs()
+	statuses := make([]MonitorStatus, 0, len(ids))
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+
+	var channels []models.MonitoredChannel
+	if err := db.DB.Where("channel_id IN ?", ids).Order("username ASC").Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load monitored channels: %w", err)
+	}
+
+	now := time.Now()
+	for _, channel := range channels {
+		status := MonitorStatus{
+			ChannelID:    channel.ChannelID,
+			Username:     channel.Username,
+			PollInterval: pollInterval(channel.Username).String(),
+			ChatSource:   ChatSourceWebSocket,
+			ProxyBudget:  ChannelProxyBudget(channel.Username),
+		}
+		if IsChatPolling(channel.ChannelID) {
+			status.ChatSource = ChatSourcePolling
+		}
+
+		health.Lock()
+		if ch, ok := health.channels[channel.Username]; ok {
+			status.ConsecutiveFetchFailures = ch.ConsecutiveFetchFailures
+			if !ch.LastSuccessfulFetch.IsZero() {
+				lastSuccess := ch.LastSuccessfulFetch
+				status.LastSuccessfulFetch = &lastSuccess
+			}
+			status.ReconnectsLastHour = len(pruneBefore(ch.Reconnects, now.Add(-time.Hour)))
+		}
+		health.Unlock()
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
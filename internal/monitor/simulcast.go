@@ -0,0 +1,202 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sources of a simulcast indicator
+const (
+	SimulcastSourceTwitchChannel = "twitch_channel" // Kick's livestream payload links a Twitch channel
+	SimulcastSourceTitle         = "title"          // The stream title announces the simulcast
+)
+
+// simulcastTitleRegex matches titles announcing a multistream, e.g. "SIMULCAST" or "multistreaming"
+var simulcastTitleRegex = regexp.MustCompile(`(?i)\b(simulcast(ing)?|multi-?stream(ing)?|restream(ing)?)\b`)
+
+// simulcastPlatformRegex matches titles naming the other platforms, e.g. "also live on Twitch" or "| YT + Kick"
+var simulcastPlatformRegex = regexp.MustCompile(`(?i)\b(?:also|live|streaming|now)\s+on\s+(twitch|youtube|yt|tiktok)\b|\b(twitch|youtube|yt|tiktok)\s*(?:\+|&|and)\s*kick\b|\bkick\s*(?:\+|&|and)\s*(twitch|youtube|yt|tiktok)\b`)
+
+// SimulcastIndicator is one hint that a stream was also broadcast on another platform
+type SimulcastIndicator struct {
+	Source   string `json:"source"`             // twitch_channel or title
+	Platform string `json:"platform,omitempty"` // twitch, youtube or tiktok, empty when the title does not say
+	Value    string `json:"value"`              // The linked channel, or the title that matched
+}
+
+// SimulcastInfo is the simulcast section of a report. Chat is split between the platforms of a simulcast, so its
+// chat based metrics, engagement first, undercount the audience compared to Kick-only streams.
+type SimulcastInfo struct {
+	Platforms     []string             `json:"platforms"`
+	TwitchChannel string               `json:"twitch_channel,omitempty"`
+	Indicators    []SimulcastIndicator `json:"indicators"`
+	FirstSeen     time.Time            `json:"first_seen"`
+	LastSeen      time.Time            `json:"last_seen"`
+}
+
+// detectSimulcast returns the simulcast indicators of a livestream payload
+func detectSimulcast(livestream *KickLivestream) []SimulcastIndicator {
+	var indicators []SimulcastIndicator
+	if channel := twitchChannelName(livestream.TwitchChannel); channel != "" {
+		indicators = append(indicators, SimulcastIndicator{Source: SimulcastSourceTwitchChannel, Platform: "twitch", Value: channel})
+	}
+
+	title := strings.TrimSpace(livestream.SessionTitle)
+	platforms := make(map[string]struct{})
+	for _, match := range simulcastPlatformRegex.FindAllStringSubmatch(title, -1) {
+		for _, name := range match[1:] {
+			if name != "" {
+				platforms[normalizeSimulcastPlatform(name)] = struct{}{}
+			}
+		}
+	}
+	for platform := range platforms {
+		indicators = append(indicators, SimulcastIndicator{Source: SimulcastSourceTitle, Platform: platform, Value: title})
+	}
+	if len(platforms) == 0 && simulcastTitleRegex.MatchString(title) {
+		indicators = append(indicators, SimulcastIndicator{Source: SimulcastSourceTitle, Value: title})
+	}
+	sortSimulcastIndicators(indicators)
+	return indicators
+}
+
+// twitchChannelName extracts the channel from the twitch_channel field, which Kick sends as null, a string or an
+// object depending on the API version
+func twitchChannelName(raw any) string {
+	switch v := raw.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		for _, key := range []string{"slug", "username", "name", "login", "channel"} {
+			if name, ok := v[key].(string); ok && strings.TrimSpace(name) != "" {
+				return strings.TrimSpace(name)
+			}
+		}
+	}
+	return ""
+}
+
+func normalizeSimulcastPlatform(name string) string {
+	name = strings.ToLower(name)
+	if name == "yt" {
+		return "youtube"
+	}
+	return name
+}
+
+func sortSimulcastIndicators(indicators []SimulcastIndicator) {
+	sort.Slice(indicators, func(i, j int) bool {
+		if indicators[i].Source != indicators[j].Source {
+			return indicators[i].Source > indicators[j].Source // twitch_channel first
+		}
+		if indicators[i].Platform != indicators[j].Platform {
+			return indicators[i].Platform < indicators[j].Platform
+		}
+		return indicators[i].Value < indicators[j].Value
+	})
+}
+
+// recordSimulcast stores the simulcast indicators of a fetched livestream, merged with those of earlier fetches as
+// titles change during a stream
+func recordSimulcast(channelID, livestreamID uint, livestream *KickLivestream) error {
+	indicators := detectSimulcast(livestream)
+	if len(indicators) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var row models.LivestreamSimulcast
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("livestream_id = ?", livestreamID).Take(&row).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			row = models.LivestreamSimulcast{LivestreamID: livestreamID, ChannelID: channelID, FirstSeenAt: now}
+		case err != nil:
+			return fmt.Errorf("failed to load simulcast of livestream %d: %w", livestreamID, err)
+		default:
+			var known []SimulcastIndicator
+			if len(row.Indicators) > 0 {
+				if err := json.Unmarshal(row.Indicators, &known); err != nil {
+					return fmt.Errorf("failed to parse simulcast indicators of livestream %d: %w", livestreamID, err)
+				}
+			}
+			indicators = mergeSimulcastIndicators(known, indicators)
+		}
+
+		encoded, err := json.Marshal(indicators)
+		if err != nil {
+			return fmt.Errorf("failed to marshal simulcast indicators: %w", err)
+		}
+		row.Indicators = encoded
+		row.Platforms = strings.Join(simulcastPlatforms(indicators), ",")
+		for _, indicator := range indicators {
+			if indicator.Source == SimulcastSourceTwitchChannel {
+				row.TwitchChannel = indicator.Value
+			}
+		}
+		row.LastSeenAt = now
+		if err := tx.Save(&row).Error; err != nil {
+			return fmt.Errorf("failed to save simulcast of livestream %d: %w", livestreamID, err)
+		}
+		return nil
+	})
+}
+
+// mergeSimulcastIndicators returns the union of two indicator lists
+func mergeSimulcastIndicators(known, found []SimulcastIndicator) []SimulcastIndicator {
+	seen := make(map[SimulcastIndicator]struct{}, len(known)+len(found))
+	merged := make([]SimulcastIndicator, 0, len(known)+len(found))
+	for _, indicator := range append(known, found...) {
+		if _, ok := seen[indicator]; ok {
+			continue
+		}
+		seen[indicator] = struct{}{}
+		merged = append(merged, indicator)
+	}
+	sortSimulcastIndicators(merged)
+	return merged
+}
+
+// simulcastPlatforms lists the platforms named by the indicators, sorted
+func simulcastPlatforms(indicators []SimulcastIndicator) []string {
+	platforms := []string{}
+	seen := make(map[string]struct{})
+	for _, indicator := range indicators {
+		if _, ok := seen[indicator.Platform]; indicator.Platform == "" || ok {
+			continue
+		}
+		seen[indicator.Platform] = struct{}{}
+		platforms = append(platforms, indicator.Platform)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// loadSimulcast returns the simulcast section of a livestream, nil when no indicator was seen
+func loadSimulcast(livestreamID uint) (*SimulcastInfo, error) {
+	var row models.LivestreamSimulcast
+	if err := db.DB.Where("livestream_id = ?", livestreamID).Limit(1).Find(&row).Error; err != nil {
+		return nil, fmt.Errorf("failed to load simulcast of livestream %d: %w", livestreamID, err)
+	}
+	if row.LivestreamID == 0 {
+		return nil, nil
+	}
+	info := SimulcastInfo{TwitchChannel: row.TwitchChannel, FirstSeen: row.FirstSeenAt, LastSeen: row.LastSeenAt}
+	if len(row.Indicators) > 0 {
+		if err := json.Unmarshal(row.Indicators, &info.Indicators); err != nil {
+			return nil, fmt.Errorf("failed to parse simulcast indicators of livestream %d: %w", livestreamID, err)
+		}
+	}
+	info.Platforms = simulcastPlatforms(info.Indicators)
+	return &info, nil
+}
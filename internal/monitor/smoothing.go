@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Viewer count outlier rejection methods
+const (
+	OutlierMethodNone   = "none"   // Use raw viewer counts
+	OutlierMethodMedian = "median" // Sliding window median filter
+	OutlierMethodZScore = "zscore" // Clamp values outside mean ± threshold * stddev
+)
+
+var (
+	ViewerOutlierMethod   = viewerOutlierMethodFromEnv()
+	ViewerMedianWindow    = util.GetEnvInt("VIEWER_MEDIAN_WINDOW", 5)        // Number of samples in the median window (odd)
+	ViewerZScoreThreshold = util.GetEnvFloat("VIEWER_ZSCORE_THRESHOLD", 3.0) // Z-score above which a sample is clamped
+)
+
+func viewerOutlierMethodFromEnv() string {
+	method := strings.ToLower(os.Getenv("VIEWER_OUTLIER_METHOD"))
+	switch method {
+	case "":
+		return OutlierMethodNone // Opt-in, filtering changes peak and average viewers of existing deployments
+	case OutlierMethodNone, OutlierMethodMedian, OutlierMethodZScore:
+		return method
+	default:
+		util.InvalidSetting("unknown VIEWER_OUTLIER_METHOD %q, falling back to %s", method, OutlierMethodNone)
+		return OutlierMethodNone
+	}
+}
+
+// smoothViewerCounts returns a copy of viewerCounts with outliers rejected using the configured method.
+// The input slice is left untouched so raw values remain available.
+func smoothViewerCounts(viewerCounts []models.LivestreamData) []models.LivestreamData {
+	smoothed := make([]models.LivestreamData, len(viewerCounts))
+	copy(smoothed, viewerCounts)
+
+	if len(viewerCounts) < 3 {
+		return smoothed
+	}
+
+	values := make([]int, len(viewerCounts))
+	for i, vc := range viewerCounts {
+		values[i] = vc.ViewerCount
+	}
+
+	switch ViewerOutlierMethod {
+	case OutlierMethodMedian:
+		values = medianFilter(values, ViewerMedianWindow)
+	case OutlierMethodZScore:
+		values = zScoreClamp(values, ViewerZScoreThreshold)
+	}
+
+	for i := range smoothed {
+		smoothed[i].ViewerCount = values[i]
+	}
+	return smoothed
+}
+
+// medianFilter replaces each value with the median of the window centred on it.
+func medianFilter(values []int, window int) []int {
+	if window < 3 {
+		return values
+	}
+	if window%2 == 0 {
+		window++
+	}
+	half := window / 2
+
+	result := make([]int, len(values))
+	buf := make([]int, 0, window)
+	for i := range values {
+		start := max(0, i-half)
+		end := min(len(values), i+half+1)
+
+		buf = append(buf[:0], values[start:end]...)
+		sort.Ints(buf)
+		result[i] = buf[len(buf)/2]
+	}
+	return result
+}
+
+// zScoreClamp clamps values whose z-score exceeds threshold back to the threshold boundary.
+func zScoreClamp(values []int, threshold float64) []int {
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(values)))
+
+	result := make([]int, len(values))
+	copy(result, values)
+	if stddev == 0 {
+		return result
+	}
+
+	upper := int(mean + threshold*stddev)
+	lower := max(0, int(mean-threshold*stddev))
+	for i, v := range result {
+		if v > upper {
+			result[i] = upper
+		} else if v < lower {
+			result[i] = lower
+		}
+	}
+	return result
+}
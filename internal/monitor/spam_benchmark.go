@@ -0,0 +1,564 @@
+package monitor
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// The spam detectors are benchmarked against golden datasets: chat logs whose spammers are labeled. The built-in
+// datasets cover the usual shapes of spam and the chat that looks like it; operators add their own, exported from
+// streams they reviewed in the moderation queue, to check a threshold or detector change on their channels before
+// deploying it. A dataset runs through the same report build as a stream, so the environment's settings apply.
+
+//go:embed spam_datasets/*.json
+var builtinSpamDatasets embed.FS
+
+// SpamDatasetFormat identifies golden dataset files
+const SpamDatasetFormat = "kick-monitor/spam-dataset"
+
+// SpamBenchmarkAny is the detector name of the findings of every detector together
+const SpamBenchmarkAny = "any"
+
+// SpamDataset is a chat log with its spammers labeled
+type SpamDataset struct {
+	Format      string               `json:"format"` // Always SpamDatasetFormat
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Channel     string               `json:"channel,omitempty"`
+	Messages    []SpamDatasetMessage `json:"messages"`
+	Spammers    []string             `json:"spammers"`             // Usernames labeled as spammers
+	Legitimate  []string             `json:"legitimate,omitempty"` // Usernames labeled as legitimate, unless Exhaustive
+	Exhaustive  bool                 `json:"exhaustive"`           // Every chatter not in Spammers is legitimate
+	Source      string               `json:"-"`                    // File the dataset was read from, "builtin" for the included ones
+}
+
+// SpamDatasetMessage is a chat message of a dataset
+type SpamDatasetMessage struct {
+	SenderID int       `json:"sender_id,omitempty"` // Assigned per username when missing
+	Username string    `json:"username"`
+	Content  string    `json:"content"`
+	SentAt   time.Time `json:"sent_at"`
+	Flagged  bool      `json:"flagged,omitempty"` // A moderator flagged the sender at ingestion time
+}
+
+// SpamBenchmarkOptions tunes a benchmark run
+type SpamBenchmarkOptions struct {
+	Runs     int     // Report builds per dataset, the runtime is their median
+	MinScore float64 // Suspicious chatters scoring below this aren't counted as flagged
+}
+
+// SpamBenchmarkReport is the outcome of a benchmark run
+type SpamBenchmarkReport struct {
+	StartedAt time.Time            `json:"started_at"`
+	Runs      int                  `json:"runs"`
+	MinScore  float64              `json:"min_score"`
+	Weights   map[string]float64   `json:"weights"`   // SuspicionWeights the run scored with
+	Detectors []SpamDetectorScore  `json:"detectors"` // Over every dataset, SpamBenchmarkAny first
+	Issues    []SpamDetectorScore  `json:"issues"`    // Of the suspicious chatters, per issue
+	Datasets  []SpamDatasetResult  `json:"datasets"`
+	Runtime   SpamBenchmarkRuntime `json:"runtime"` // Of all datasets together
+}
+
+// SpamDatasetResult is the benchmark of one dataset
+type SpamDatasetResult struct {
+	Name           string               `json:"name"`
+	Source         string               `json:"source"`
+	Messages       int                  `json:"messages"`
+	Chatters       int                  `json:"chatters"`
+	Spammers       int                  `json:"spammers"`   // Labeled spammers who chatted
+	Legitimate     int                  `json:"legitimate"` // Labeled legitimate chatters
+	Detectors      []SpamDetectorScore  `json:"detectors"`
+	Issues         []SpamDetectorScore  `json:"issues"`
+	Missed         []string             `json:"missed"`          // Spammers no detector flagged
+	FalsePositives []string             `json:"false_positives"` // Legitimate chatters a detector flagged
+	Runtime        SpamBenchmarkRuntime `json:"runtime"`
+}
+
+// SpamDetectorScore is how well a detector, or a suspicion issue, separates the labeled chatters. Precision,
+// recall and F1 are nil when undefined, e.g. precision of a detector that flagged no labeled chatter.
+type SpamDetectorScore struct {
+	Name           string   `json:"name"`
+	Flagged        int      `json:"flagged"`         // Chatters flagged
+	TruePositives  int      `json:"true_positives"`  // Flagged spammers
+	FalsePositives int      `json:"false_positives"` // Flagged legitimate chatters
+	FalseNegatives int      `json:"false_negatives"` // Spammers not flagged
+	Unlabeled      int      `json:"unlabeled"`       // Flagged chatters without a label, left out of the scores
+	Precision      *float64 `json:"precision"`
+	Recall         *float64 `json:"recall"`
+	F1             *float64 `json:"f1"`
+}
+
+// SpamBenchmarkRuntime is how long the report build took
+type SpamBenchmarkRuntime struct {
+	MedianMs          float64 `json:"median_ms"`
+	MinMs             float64 `json:"min_ms"`
+	MaxMs             float64 `json:"max_ms"`
+	MessagesPerSecond float64 `json:"messages_per_second"` // At the median
+}
+
+// LoadSpamDatasets reads the dataset files and the .json files of the directories in paths, after the built-in
+// datasets unless builtin is false
+func LoadSpamDatasets(paths []string, builtin bool) ([]SpamDataset, error) {
+	var datasets []SpamDataset
+	if builtin {
+		entries, err := builtinSpamDatasets.ReadDir("spam_datasets")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			data, err := builtinSpamDatasets.ReadFile("spam_datasets/" + entry.Name())
+			if err != nil {
+				return nil, err
+			}
+			dataset, err := parseSpamDataset(data, "builtin")
+			if err != nil {
+				return nil, fmt.Errorf("built-in dataset %s: %w", entry.Name(), err)
+			}
+			datasets = append(datasets, dataset)
+		}
+	}
+
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			dataset, err := parseSpamDataset(data, file)
+			if err != nil {
+				return nil, fmt.Errorf("dataset %s: %w", file, err)
+			}
+			datasets = append(datasets, dataset)
+		}
+	}
+	if len(datasets) == 0 {
+		return nil, errors.New("no datasets to benchmark")
+	}
+	return datasets, nil
+}
+
+func parseSpamDataset(data []byte, source string) (SpamDataset, error) {
+	var dataset SpamDataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return dataset, err
+	}
+	if dataset.Format != SpamDatasetFormat {
+		return dataset, fmt.Errorf("format is %q, expected %q", dataset.Format, SpamDatasetFormat)
+	}
+	if len(dataset.Messages) == 0 {
+		return dataset, errors.New("no messages")
+	}
+	for i, msg := range dataset.Messages {
+		if msg.Username == "" || msg.SentAt.IsZero() {
+			return dataset, fmt.Errorf("message %d has no username or sent_at", i)
+		}
+	}
+	if dataset.Name == "" {
+		dataset.Name = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	}
+	dataset.Source = source
+	return dataset, nil
+}
+
+// RunSpamBenchmark builds a report of every dataset and scores the findings against the labels
+func RunSpamBenchmark(datasets []SpamDataset, opts SpamBenchmarkOptions) SpamBenchmarkReport {
+	opts.Runs = max(opts.Runs, 1)
+	report := SpamBenchmarkReport{
+		StartedAt: time.Now().UTC(),
+		Runs:      opts.Runs,
+		MinScore:  opts.MinScore,
+		Weights:   SuspicionWeights,
+		Datasets:  []SpamDatasetResult{},
+	}
+
+	detectors, issues := make(map[string]*SpamDetectorScore), make(map[string]*SpamDetectorScore)
+	var totalMessages int
+	var totalRuns [][]time.Duration
+	for _, dataset := range datasets {
+		result, durations := benchmarkSpamDataset(dataset, opts)
+		report.Datasets = append(report.Datasets, result)
+		totalMessages += result.Messages
+		totalRuns = append(totalRuns, durations)
+		for _, score := range result.Detectors {
+			detectors[score.Name] = addDetectorScore(detectors[score.Name], score)
+		}
+		for _, score := range result.Issues {
+			issues[score.Name] = addDetectorScore(issues[score.Name], score)
+		}
+	}
+
+	// The runs of every dataset summed, run by run
+	combined := make([]time.Duration, opts.Runs)
+	for _, durations := range totalRuns {
+		for i, d := range durations {
+			combined[i] += d
+		}
+	}
+	report.Runtime = benchmarkRuntime(combined, totalMessages)
+	report.Detectors, report.Issues = sortedDetectorScores(detectors), sortedDetectorScores(issues)
+	return report
+}
+
+// benchmarkSpamDataset builds the report of a dataset opts.Runs times and scores the findings of the last build
+func benchmarkSpamDataset(dataset SpamDataset, opts SpamBenchmarkOptions) (SpamDatasetResult, []time.Duration) {
+	in := spamDatasetInput(dataset)
+	var spamReport models.SpamReport
+	durations := make([]time.Duration, opts.Runs)
+	for i := range durations {
+		start := time.Now()
+		_, spamReport = buildLivestreamReport(in)
+		durations[i] = time.Since(start)
+	}
+
+	chatters := make(map[string]struct{})
+	for _, msg := range in.ChatMessages {
+		chatters[strings.ToLower(msg.SenderUsername)] = struct{}{}
+	}
+	labels := make(map[string]bool) // Lowercased username -> is a spammer
+	for _, username := range dataset.Legitimate {
+		labels[strings.ToLower(username)] = false
+	}
+	if dataset.Exhaustive {
+		for username := range chatters {
+			labels[username] = false
+		}
+	}
+	for _, username := range dataset.Spammers {
+		labels[strings.ToLower(username)] = true
+	}
+
+	result := SpamDatasetResult{
+		Name:           dataset.Name,
+		Source:         dataset.Source,
+		Messages:       len(in.ChatMessages),
+		Chatters:       len(chatters),
+		Missed:         []string{},
+		FalsePositives: []string{},
+		Runtime:        benchmarkRuntime(durations, len(in.ChatMessages)),
+	}
+	for username, spammer := range labels {
+		if _, chatted := chatters[username]; !chatted {
+			continue // Spammers of other streams in the labels don't count as missed
+		}
+		if spammer {
+			result.Spammers++
+		} else {
+			result.Legitimate++
+		}
+	}
+
+	flagged, flaggedIssues := spamBenchmarkFindings(spamReport, in.ChatMessages, opts.MinScore)
+	detectors := make(map[string]*SpamDetectorScore)
+	for name, usernames := range flagged {
+		detectors[name] = scoreDetector(name, usernames, labels, chatters)
+	}
+	for _, name := range SpamIncidentTypes {
+		if detectors[name] == nil {
+			detectors[name] = scoreDetector(name, nil, labels, chatters)
+		}
+	}
+	flaggedByAny := make(map[string]struct{})
+	for _, usernames := range flagged {
+		for username := range usernames {
+			flaggedByAny[username] = struct{}{}
+		}
+	}
+	detectors[SpamBenchmarkAny] = scoreDetector(SpamBenchmarkAny, flaggedByAny, labels, chatters)
+	issues := make(map[string]*SpamDetectorScore)
+	for issue, usernames := range flaggedIssues {
+		issues[issue] = scoreDetector(issue, usernames, labels, chatters)
+	}
+	result.Detectors, result.Issues = sortedDetectorScores(detectors), sortedDetectorScores(issues)
+
+	for username, spammer := range labels {
+		_, chatted := chatters[username]
+		_, found := flaggedByAny[username]
+		switch {
+		case spammer && chatted && !found:
+			result.Missed = append(result.Missed, username)
+		case !spammer && found:
+			result.FalsePositives = append(result.FalsePositives, username)
+		}
+	}
+	sort.Strings(result.Missed)
+	sort.Strings(result.FalsePositives)
+	return result, durations
+}
+
+// spamDatasetInput turns a dataset into the input of a report build. Nothing about the chatters is known
+// beforehand: no trusted chatters, and no confirmed offenders, which would hand the labels to the scoring.
+func spamDatasetInput(dataset SpamDataset) reportInput {
+	senderIDs := make(map[string]int)
+	nextID := -1 // Negative, so they can't collide with the datasets' real IDs
+	messages := make([]models.ChatMessage, len(dataset.Messages))
+	for i, msg := range dataset.Messages {
+		senderID := msg.SenderID
+		if senderID == 0 {
+			if senderIDs[msg.Username] == 0 {
+				senderIDs[msg.Username] = nextID
+				nextID--
+			}
+			senderID = senderIDs[msg.Username]
+		}
+		messages[i] = models.ChatMessage{
+			ID:              uuid.New(),
+			SenderID:        senderID,
+			SenderUsername:  msg.Username,
+			Event:           "App\\Events\\ChatMessageEvent",
+			Message:         msg.Content,
+			Flagged:         msg.Flagged,
+			MessageSendTime: msg.SentAt,
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].MessageSendTime.Before(messages[j].MessageSendTime) })
+
+	start := messages[0].MessageSendTime.Truncate(MessageTimelineBlock)
+	end := messages[len(messages)-1].MessageSendTime.Add(MessageTimelineBlock).Truncate(MessageTimelineBlock)
+	return reportInput{
+		ChannelUsername: dataset.Channel,
+		Title:           dataset.Name,
+		StartTime:       start,
+		EndTime:         end,
+		ChatMessages:    messages,
+		FollowersUntil:  end,
+		Trusted:         map[int]struct{}{},
+		Offenders:       map[string]struct{}{},
+	}
+}
+
+// spamBenchmarkFindings returns the lowercased usernames each detector flagged, and each suspicion issue of the
+// suspicious chatters scoring at least minScore
+func spamBenchmarkFindings(spamReport models.SpamReport, messages []models.ChatMessage, minScore float64) (map[string]map[string]struct{}, map[string]map[string]struct{}) {
+	flagged, issues := make(map[string]map[string]struct{}), make(map[string]map[string]struct{})
+	flag := func(into map[string]map[string]struct{}, name, username string) {
+		if into[name] == nil {
+			into[name] = make(map[string]struct{})
+		}
+		into[name][strings.ToLower(username)] = struct{}{}
+	}
+
+	var exact []ExactDuplicateBurstReport
+	if json.Unmarshal(spamReport.ExactDuplicateBursts, &exact) == nil {
+		for _, burst := range exact {
+			flag(flagged, SpamIncidentExactBurst, burst.Username)
+		}
+	}
+	var similar []SimilarMessageBurstReport
+	if json.Unmarshal(spamReport.SimilarMessageBursts, &similar) == nil {
+		for _, burst := range similar {
+			flag(flagged, SpamIncidentSimilarBurst, burst.Username)
+		}
+	}
+	var chatters []SuspiciousChatterReport
+	if json.Unmarshal(spamReport.SuspiciousChatters, &chatters) == nil {
+		for _, chatter := range chatters {
+			if chatter.Score < minScore {
+				continue
+			}
+			flag(flagged, SpamIncidentSuspicious, chatter.Username)
+			for _, issue := range chatter.PotentialIssues {
+				flag(issues, issue, chatter.Username)
+			}
+		}
+	}
+	// The report lists a few usernames per copypasta, every poster of it counts
+	var copypastas []CrossUserCopypastaReport
+	if json.Unmarshal(spamReport.CrossUserCopypasta, &copypastas) == nil {
+		for _, copypasta := range copypastas {
+			for _, msg := range messages {
+				if msg.MessageSendTime.Before(copypasta.FirstSeen) || msg.MessageSendTime.After(copypasta.LastSeen) {
+					continue
+				}
+				if hash, _ := copypastaKey(msg.Message); hash == copypasta.ContentHash {
+					flag(flagged, SpamIncidentCopypasta, msg.SenderUsername)
+				}
+			}
+		}
+	}
+	return flagged, issues
+}
+
+// scoreDetector compares the chatters a detector flagged with the labels of the chatters of the dataset
+func scoreDetector(name string, flagged map[string]struct{}, labels map[string]bool, chatters map[string]struct{}) *SpamDetectorScore {
+	score := &SpamDetectorScore{Name: name, Flagged: len(flagged)}
+	for username := range flagged {
+		switch spammer, labeled := labels[username]; {
+		case !labeled:
+			score.Unlabeled++
+		case spammer:
+			score.TruePositives++
+		default:
+			score.FalsePositives++
+		}
+	}
+	for username, spammer := range labels {
+		if _, chatted := chatters[username]; !spammer || !chatted {
+			continue
+		}
+		if _, found := flagged[username]; !found {
+			score.FalseNegatives++
+		}
+	}
+	score.computeRates()
+	return score
+}
+
+func (s *SpamDetectorScore) computeRates() {
+	s.Precision, s.Recall, s.F1 = nil, nil, nil
+	if tp := s.TruePositives; tp+s.FalsePositives > 0 {
+		s.Precision = spamBenchmarkRatio(tp, tp+s.FalsePositives)
+	}
+	if tp := s.TruePositives; tp+s.FalseNegatives > 0 {
+		s.Recall = spamBenchmarkRatio(tp, tp+s.FalseNegatives)
+	}
+	if s.Precision != nil && s.Recall != nil && *s.Precision+*s.Recall > 0 {
+		f1 := roundTo(2**s.Precision**s.Recall/(*s.Precision+*s.Recall), 3)
+		s.F1 = &f1
+	}
+}
+
+func spamBenchmarkRatio(part, total int) *float64 {
+	ratio := roundTo(float64(part)/float64(total), 3)
+	return &ratio
+}
+
+// addDetectorScore adds a dataset's counts to the totals of a detector
+func addDetectorScore(total *SpamDetectorScore, score SpamDetectorScore) *SpamDetectorScore {
+	if total == nil {
+		total = &SpamDetectorScore{Name: score.Name}
+	}
+	total.Flagged += score.Flagged
+	total.TruePositives += score.TruePositives
+	total.FalsePositives += score.FalsePositives
+	total.FalseNegatives += score.FalseNegatives
+	total.Unlabeled += score.Unlabeled
+	total.computeRates()
+	return total
+}
+
+// sortedDetectorScores lists the scores by name, SpamBenchmarkAny first
+func sortedDetectorScores(scores map[string]*SpamDetectorScore) []SpamDetectorScore {
+	sorted := make([]SpamDetectorScore, 0, len(scores))
+	for _, score := range scores {
+		sorted = append(sorted, *score)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i].Name == SpamBenchmarkAny) != (sorted[j].Name == SpamBenchmarkAny) {
+			return sorted[i].Name == SpamBenchmarkAny
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func benchmarkRuntime(durations []time.Duration, messages int) SpamBenchmarkRuntime {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	runtime := SpamBenchmarkRuntime{
+		MedianMs: roundTo(float64(median.Microseconds())/1000, 3),
+		MinMs:    roundTo(float64(sorted[0].Microseconds())/1000, 3),
+		MaxMs:    roundTo(float64(sorted[len(sorted)-1].Microseconds())/1000, 3),
+	}
+	if median > 0 {
+		runtime.MessagesPerSecond = roundTo(float64(messages)/median.Seconds(), 1)
+	}
+	return runtime
+}
+
+// ExportSpamDataset returns the chat of a livestream as a dataset labeled by the moderation queue: chatters with a
+// confirmed finding are spammers, those whose findings were all dismissed legitimate, the others unlabeled
+func ExportSpamDataset(ctx context.Context, livestreamID uint) (SpamDataset, error) {
+	var messages []models.ChatMessage
+	if err := db.Reader().WithContext(ctx).Where("livestream_id = ?", livestreamID).
+		Order("message_send_time").Find(&messages).Error; err != nil {
+		return SpamDataset{}, fmt.Errorf("failed to fetch chat messages for livestream %d: %w", livestreamID, err)
+	}
+	archived, err := loadArchivedMessages(livestreamID, ReportWindow{})
+	if err != nil {
+		return SpamDataset{}, err
+	}
+	messages = append(messages, archived...)
+	if len(messages) == 0 {
+		return SpamDataset{}, fmt.Errorf("no chat messages for livestream %d", livestreamID)
+	}
+
+	var items []models.ModerationItem
+	if err := db.Reader().WithContext(ctx).Where("livestream_id = ? AND status <> ?", livestreamID, ModerationPending).
+		Find(&items).Error; err != nil {
+		return SpamDataset{}, fmt.Errorf("failed to fetch moderation items for livestream %d: %w", livestreamID, err)
+	}
+	labels := make(map[string]bool)
+	for _, item := range items {
+		usernames := []string{item.Username}
+		if item.Type == SpamIncidentCopypasta {
+			var details struct {
+				Usernames []string `json:"usernames"`
+			}
+			_ = json.Unmarshal(item.Details, &details)
+			usernames = details.Usernames
+		}
+		for _, username := range usernames {
+			if username != "" {
+				labels[strings.ToLower(username)] = labels[strings.ToLower(username)] || item.Status == ModerationConfirmed
+			}
+		}
+	}
+
+	var channel models.MonitoredChannel
+	db.Reader().WithContext(ctx).Where("chatroom_id = ?", messages[0].ChatroomID).Limit(1).Find(&channel)
+	dataset := SpamDataset{
+		Format:      SpamDatasetFormat,
+		Name:        fmt.Sprintf("%s-%d", channel.Username, livestreamID),
+		Description: fmt.Sprintf("Chat of livestream %d labeled by %d moderation review(s)", livestreamID, len(items)),
+		Channel:     channel.Username,
+		Messages:    make([]SpamDatasetMessage, len(messages)),
+		Spammers:    []string{},
+		Legitimate:  []string{},
+	}
+	for i, msg := range messages {
+		dataset.Messages[i] = SpamDatasetMessage{
+			SenderID: msg.SenderID,
+			Username: msg.SenderUsername,
+			Content:  msg.Message,
+			SentAt:   msg.MessageSendTime.UTC(),
+			Flagged:  msg.Flagged,
+		}
+	}
+	sort.SliceStable(dataset.Messages, func(i, j int) bool { return dataset.Messages[i].SentAt.Before(dataset.Messages[j].SentAt) })
+	for username, spammer := range labels {
+		if spammer {
+			dataset.Spammers = append(dataset.Spammers, username)
+		} else {
+			dataset.Legitimate = append(dataset.Legitimate, username)
+		}
+	}
+	sort.Strings(dataset.Spammers)
+	sort.Strings(dataset.Legitimate)
+	return dataset, nil
+}
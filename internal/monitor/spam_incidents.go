@@ -0,0 +1,287 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Spam incident types
+const (
+	SpamIncidentExactBurst   = "exact_duplicate_burst"
+	SpamIncidentSimilarBurst = "similar_message_burst"
+	SpamIncidentSuspicious   = "suspicious_chatter"
+	SpamIncidentCopypasta    = "copypasta"
+)
+
+// SpamIncidentTypes lists the incident types accepted by the search endpoints
+var SpamIncidentTypes = []string{SpamIncidentExactBurst, SpamIncidentSimilarBurst, SpamIncidentSuspicious, SpamIncidentCopypasta}
+
+const spamIncidentContentRunes = 500 // Incident content is cut to this many characters
+
+// spamIncidents splits a spam report into one incident per burst, suspicious chatter and copypasta
+func spamIncidents(report models.LivestreamReport, spamReport models.SpamReport) []models.SpamIncident {
+	var incidents []models.SpamIncident
+	add := func(incidentType, username string, senderID *int, content string, count int, score float64, details any, timestamps []time.Time) {
+		first, last := report.ReportStartTime, report.ReportStartTime
+		if len(timestamps) > 0 {
+			first, last = timestamps[0], timestamps[0]
+			for _, t := range timestamps[1:] {
+				if t.Before(first) {
+					first = t
+				}
+				if t.After(last) {
+					last = t
+				}
+			}
+		}
+		var detailsJSON []byte
+		if details != nil {
+			detailsJSON, _ = json.Marshal(details)
+		}
+		incidents = append(incidents, models.SpamIncident{
+			ID:                 uuid.New(),
+			SpamReportID:       spamReport.ID,
+			LivestreamReportID: report.ID,
+			ChannelID:          report.ChannelID,
+			LivestreamID:       report.LivestreamID,
+			Type:               incidentType,
+			SenderID:           senderID,
+			Username:           strings.ToLower(username),
+			Content:            truncateRunes(content, spamIncidentContentRunes),
+			MessageCount:       count,
+			Score:              score,
+			Details:            detailsJSON,
+			FirstSeen:          first,
+			LastSeen:           last,
+		})
+	}
+
+	var exact []ExactDuplicateBurstReport
+	if json.Unmarshal(spamReport.ExactDuplicateBursts, &exact) == nil {
+		for _, burst := range exact {
+			add(SpamIncidentExactBurst, burst.Username, nil, burst.Content, burst.Count, 0, nil, burst.Timestamps)
+		}
+	}
+	var similar []SimilarMessageBurstReport
+	if json.Unmarshal(spamReport.SimilarMessageBursts, &similar) == nil {
+		for _, burst := range similar {
+			add(SpamIncidentSimilarBurst, burst.Username, nil, burst.Pattern, burst.Count, 0, nil, burst.Timestamps)
+		}
+	}
+	var chatters []SuspiciousChatterReport
+	if json.Unmarshal(spamReport.SuspiciousChatters, &chatters) == nil {
+		for _, chatter := range chatters {
+			example := ""
+			if len(chatter.ExampleMessages) > 0 {
+				example = chatter.ExampleMessages[0]
+			}
+			senderID := chatter.UserID
+			add(SpamIncidentSuspicious, chatter.Username, &senderID, example, len(chatter.MessageTimestamps), chatter.Score,
+				map[string]any{"rank": chatter.Rank, "issues": chatter.PotentialIssues}, chatter.MessageTimestamps)
+		}
+	}
+	var copypastas []CrossUserCopypastaReport
+	if json.Unmarshal(spamReport.CrossUserCopypasta, &copypastas) == nil {
+		for _, copypasta := range copypastas {
+			usernames := make([]string, len(copypasta.Usernames))
+			for i, username := range copypasta.Usernames {
+				usernames[i] = strings.ToLower(username)
+			}
+			add(SpamIncidentCopypasta, "", nil, copypasta.Content, copypasta.MessageCount, 0,
+				map[string]any{"content_hash": copypasta.ContentHash, "user_count": copypasta.UserCount, "usernames": usernames},
+				[]time.Time{copypasta.FirstSeen, copypasta.LastSeen})
+		}
+	}
+	return incidents
+}
+
+// saveSpamIncidents stores the incidents of a report's spam report. Chunk reports are skipped, their findings are
+// already part of the parent's.
+func saveSpamIncidents(tx *gorm.DB, report models.LivestreamReport, spamReport models.SpamReport) error {
+	if report.ParentReportID != nil {
+		return nil
+	}
+	incidents := spamIncidents(report, spamReport)
+	if len(incidents) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(&incidents, max(Capacity.DBBatchSize, 1)).Error; err != nil {
+		return fmt.Errorf("failed to save spam incidents for %d: %w", report.LivestreamID, err)
+	}
+	return queueModerationItems(tx, incidents)
+}
+
+// SpamIncidentQuery filters the spam incident archive. Zero values don't filter.
+type SpamIncidentQuery struct {
+	Username  string // Also matches copypasta participants
+	SenderID  *int
+	ChannelID uint
+	Types     []string
+	From, To  time.Time // On FirstSeen, To exclusive
+	Before    time.Time // Cursor, only incidents first seen before it
+	Limit     int
+}
+
+// SpamIncidentPage is a page of incidents, newest first
+type SpamIncidentPage struct {
+	Incidents  []SpamIncident `json:"incidents"`
+	NextBefore *time.Time     `json:"next_before,omitempty"` // Pass as before to get the next page
+}
+
+type SpamIncident struct {
+	ID           uuid.UUID       `json:"id"`
+	Type         string          `json:"type"`
+	ChannelID    uint            `json:"channel_id"`
+	LivestreamID uint            `json:"livestream_id"`
+	ReportID     uuid.UUID       `json:"report_id"`
+	SenderID     *int            `json:"sender_id,omitempty"`
+	Username     string          `json:"username,omitempty"`
+	Content      string          `json:"content"`
+	MessageCount int             `json:"message_count"`
+	Score        float64         `json:"score,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	FirstSeen    time.Time       `json:"first_seen"`
+	LastSeen     time.Time       `json:"last_seen"`
+}
+
+func (q SpamIncidentQuery) scope(query *gorm.DB) *gorm.DB {
+	if q.Username != "" {
+		username := strings.ToLower(q.Username)
+		query = query.Where("(username = ? OR details -> 'usernames' @> jsonb_build_array(?::text))", username, username)
+	}
+	if q.SenderID != nil {
+		query = query.Where("sender_id = ?", *q.SenderID)
+	}
+	if q.ChannelID != 0 {
+		query = query.Where("channel_id = ?", q.ChannelID)
+	}
+	if len(q.Types) > 0 {
+		query = query.Where("type IN ?", q.Types)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("first_seen >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("first_seen < ?", q.To)
+	}
+	return query
+}
+
+// SearchSpamIncidents returns a page of the incidents matching the query, newest first
+func SearchSpamIncidents(q SpamIncidentQuery) (SpamIncidentPage, error) {
+	query := q.scope(db.Reader().Model(&models.SpamIncident{}))
+	if !q.Before.IsZero() {
+		query = query.Where("first_seen < ?", q.Before)
+	}
+
+	var rows []models.SpamIncident
+	if err := query.Order("first_seen DESC, id").Limit(q.Limit + 1).Find(&rows).Error; err != nil {
+		return SpamIncidentPage{}, fmt.Errorf("failed to search spam incidents: %w", err)
+	}
+
+	page := SpamIncidentPage{Incidents: make([]SpamIncident, 0, len(rows))}
+	if len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		next := rows[len(rows)-1].FirstSeen
+		page.NextBefore = &next
+	}
+	for _, row := range rows {
+		page.Incidents = append(page.Incidents, SpamIncident{
+			ID:           row.ID,
+			Type:         row.Type,
+			ChannelID:    row.ChannelID,
+			LivestreamID: row.LivestreamID,
+			ReportID:     row.LivestreamReportID,
+			SenderID:     row.SenderID,
+			Username:     row.Username,
+			Content:      row.Content,
+			MessageCount: row.MessageCount,
+			Score:        row.Score,
+			Details:      row.Details,
+			FirstSeen:    row.FirstSeen,
+			LastSeen:     row.LastSeen,
+		})
+	}
+	return page, nil
+}
+
+// RepeatOffender is a chatter found in the incidents of several streams
+type RepeatOffender struct {
+	Username    string    `json:"username"`
+	SenderID    *int      `json:"sender_id,omitempty"`
+	Incidents   int       `json:"incidents"`
+	Streams     int       `json:"streams"`
+	Channels    int       `json:"channels"`
+	Types       []string  `json:"types"`
+	MaxScore    float64   `json:"max_score"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	ChannelIDs  []uint    `json:"channel_ids"`
+	LastContent string    `json:"last_content"`
+}
+
+// FindRepeatOffenders returns the chatters whose incidents span at least minStreams streams, most streams first.
+// Copypasta participants aren't counted, taking part in a copypasta once is normal chat behaviour.
+func FindRepeatOffenders(q SpamIncidentQuery, minStreams int) ([]RepeatOffender, error) {
+	q.Username = ""
+	query := q.scope(db.Reader().Model(&models.SpamIncident{})).Where("username <> ''")
+
+	var rows []struct {
+		Username    string
+		SenderID    *int
+		Incidents   int
+		Streams     int
+		Channels    int
+		Types       string
+		MaxScore    float64
+		FirstSeen   time.Time
+		LastSeen    time.Time
+		ChannelIDs  string
+		LastContent string
+	}
+	if err := query.Select(`username, MAX(sender_id) AS sender_id, COUNT(*) AS incidents,
+			COUNT(DISTINCT livestream_id) AS streams, COUNT(DISTINCT channel_id) AS channels,
+			STRING_AGG(DISTINCT type, ',') AS types, MAX(score) AS max_score,
+			MIN(first_seen) AS first_seen, MAX(last_seen) AS last_seen,
+			STRING_AGG(DISTINCT channel_id::text, ',') AS channel_ids,
+			(ARRAY_AGG(content ORDER BY first_seen DESC))[1] AS last_content`).
+		Group("username").
+		Having("COUNT(DISTINCT livestream_id) >= ?", max(minStreams, 1)).
+		Order("streams DESC, incidents DESC, username").
+		Limit(q.Limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to find repeat offenders: %w", err)
+	}
+
+	offenders := make([]RepeatOffender, 0, len(rows))
+	for _, row := range rows {
+		offender := RepeatOffender{
+			Username:    row.Username,
+			SenderID:    row.SenderID,
+			Incidents:   row.Incidents,
+			Streams:     row.Streams,
+			Channels:    row.Channels,
+			Types:       strings.Split(row.Types, ","),
+			MaxScore:    row.MaxScore,
+			FirstSeen:   row.FirstSeen,
+			LastSeen:    row.LastSeen,
+			ChannelIDs:  []uint{},
+			LastContent: row.LastContent,
+		}
+		for _, raw := range strings.Split(row.ChannelIDs, ",") {
+			var channelID uint
+			if _, err := fmt.Sscan(raw, &channelID); err == nil {
+				offender.ChannelIDs = append(offender.ChannelIDs, channelID)
+			}
+		}
+		offenders = append(offenders, offender)
+	}
+	return offenders, nil
+}
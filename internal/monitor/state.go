@@ -0,0 +1,201 @@
+package monitor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// channelStateShards splits the per-channel state so fetchers, WebSocket readers and API handlers of different
+// channels rarely contend on the same lock
+const channelStateShards = 64
+
+// channelState is the in-memory state of a channel monitored by this instance, shared by its fetcher, its
+// WebSocket reader and the API. Fields are only read and written through the accessors below, under mu.
+type channelState struct {
+	mu         sync.RWMutex
+	latest     LatestLivestreamInfo
+	snapshot   *models.LivestreamData // Last livestream row persisted from an HTTP fetch, nil while offline
+	window     *livestreamWindow      // Latest livestream seen, to associate chat messages
+	viewers    *liveViewerCount       // Nil while offline
+	sampleRate *cachedSampleRate
+	sampled    atomic.Uint64 // Chat messages seen, 1 in N is persisted while sampling
+	seenChat   *recentIDs    // Last chat message IDs ingested, so WebSocket and polled messages aren't stored twice
+	live       *liveReport   // Running aggregate of the latest stream, see live_report.go
+}
+
+type channelStateShard struct {
+	sync.RWMutex
+	channels map[uint]*channelState
+}
+
+var channelStates [channelStateShards]channelStateShard
+
+func stateShard(channelID uint) *channelStateShard {
+	return &channelStates[channelID%channelStateShards]
+}
+
+// loadChannelState returns the state of a channel, nil when nothing was recorded for it
+func loadChannelState(channelID uint) *channelState {
+	shard := stateShard(channelID)
+	shard.RLock()
+	defer shard.RUnlock()
+	return shard.channels[channelID]
+}
+
+// channelStateFor returns the state of a channel, creating it on first use
+func channelStateFor(channelID uint) *channelState {
+	if state := loadChannelState(channelID); state != nil {
+		return state
+	}
+	shard := stateShard(channelID)
+	shard.Lock()
+	defer shard.Unlock()
+	if state, ok := shard.channels[channelID]; ok {
+		return state
+	}
+	if shard.channels == nil {
+		shard.channels = make(map[uint]*channelState)
+	}
+	state := &channelState{}
+	shard.channels[channelID] = state
+	return state
+}
+
+// dropChannelState forgets everything recorded for a channel, once it is no longer monitored here
+func dropChannelState(channelID uint) {
+	shard := stateShard(channelID)
+	shard.Lock()
+	delete(shard.channels, channelID)
+	shard.Unlock()
+}
+
+// rangeChannelStates calls fn for every channel with state until it returns false. fn must not create or drop
+// channel states.
+func rangeChannelStates(fn func(channelID uint, state *channelState) bool) {
+	for i := range channelStates {
+		shard := &channelStates[i]
+		shard.RLock()
+		for channelID, state := range shard.channels {
+			if !fn(channelID, state) {
+				shard.RUnlock()
+				return
+			}
+		}
+		shard.RUnlock()
+	}
+}
+
+// latestLivestreamInfo returns what the last HTTP fetch of the channel saw
+func latestLivestreamInfo(channelID uint) (LatestLivestreamInfo, bool) {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return LatestLivestreamInfo{}, false
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	return state.latest, true
+}
+
+// setLatestLivestream records the outcome of an HTTP fetch. snapshot is the livestream row persisted, nil when
+// the channel is offline.
+func setLatestLivestream(channelID uint, info LatestLivestreamInfo, snapshot *models.LivestreamData) {
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.latest = info
+	state.snapshot = snapshot
+	if snapshot == nil {
+		state.viewers = nil
+	}
+}
+
+// livestreamSnapshot returns the last livestream row persisted from an HTTP fetch of a live channel
+func livestreamSnapshot(channelID uint) (models.LivestreamData, bool) {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return models.LivestreamData{}, false
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if state.snapshot == nil {
+		return models.LivestreamData{}, false
+	}
+	return *state.snapshot, true
+}
+
+// cachedLivestreamWindow returns the channel's latest livestream window, if one was loaded or recorded
+func cachedLivestreamWindow(channelID uint) (livestreamWindow, bool) {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return livestreamWindow{}, false
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if state.window == nil {
+		return livestreamWindow{}, false
+	}
+	return *state.window, true
+}
+
+// updateLivestreamWindow replaces the channel's livestream window with update's result. update gets the current
+// window, nil when there is none, and runs under the channel's lock.
+func updateLivestreamWindow(channelID uint, update func(current *livestreamWindow) livestreamWindow) {
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	window := update(state.window)
+	state.window = &window
+}
+
+// cachedChannelSampleRate returns the channel's sample rate if it was loaded within maxAge
+func cachedChannelSampleRate(channelID uint, maxAge time.Duration) (int, bool) {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return 0, false
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if state.sampleRate == nil || time.Since(state.sampleRate.LoadedAt) >= maxAge {
+		return 0, false
+	}
+	return state.sampleRate.Rate, true
+}
+
+func cacheChannelSampleRate(channelID uint, rate int) {
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.sampleRate = &cachedSampleRate{Rate: rate, LoadedAt: time.Now()}
+}
+
+// nextSampledMessage counts a chat message of the channel and returns how many were counted before it
+func nextSampledMessage(channelID uint) uint64 {
+	return channelStateFor(channelID).sampled.Add(1) - 1
+}
+
+// markChatMessageSeen records a chat message of the channel as ingested and reports whether it is new
+func markChatMessageSeen(channelID uint, id uuid.UUID) bool {
+	state := channelStateFor(channelID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.seenChat == nil {
+		state.seenChat = newRecentIDs(chatSeenIDs)
+	}
+	return state.seenChat.add(id)
+}
+
+// chatMessageSeen reports whether a chat message of the channel was ingested recently
+func chatMessageSeen(channelID uint, id uuid.UUID) bool {
+	state := loadChannelState(channelID)
+	if state == nil {
+		return false
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	return state.seenChat != nil && state.seenChat.contains(id)
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Component and overall states of the public status page
+const (
+	StatusOperational = "operational"
+	StatusDegraded    = "degraded"
+	StatusDown        = "down"
+)
+
+const (
+	publicStatusTTL     = 30 * time.Second // Snapshots are shared between requests for this long
+	ingestionLagWindow  = 5 * time.Minute  // Messages stored this recently make up the ingestion lag
+	degradedLagDuration = time.Minute      // Average ingestion lag above which chat ingestion is degraded
+)
+
+// PublicStatus is an anonymized snapshot of the instance for public status pages: no channel names or IDs
+type PublicStatus struct {
+	Status              string            `json:"status"`
+	ChannelsMonitored   int64             `json:"channels_monitored"`
+	ChannelsLive        int64             `json:"channels_live"`
+	MessagesToday       int64             `json:"messages_today"` // Stored since 00:00 UTC
+	IngestionLagSeconds *float64          `json:"ingestion_lag_seconds,omitempty"`
+	LastMessageAt       *time.Time        `json:"last_message_at,omitempty"`
+	Components          []ComponentStatus `json:"components"`
+	GeneratedAt         time.Time         `json:"generated_at"`
+}
+
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+var publicStatusCache struct {
+	sync.Mutex
+	status PublicStatus
+}
+
+// GetPublicStatus returns the public status snapshot, rebuilding it at most every publicStatusTTL
+func GetPublicStatus(ctx context.Context) PublicStatus {
+	publicStatusCache.Lock()
+	defer publicStatusCache.Unlock()
+
+	if time.Since(publicStatusCache.status.GeneratedAt) < publicStatusTTL {
+		return publicStatusCache.status
+	}
+	publicStatusCache.status = buildPublicStatus(ctx)
+	return publicStatusCache.status
+}
+
+func buildPublicStatus(ctx context.Context) PublicStatus {
+	now := time.Now().UTC()
+	status := PublicStatus{Status: StatusOperational, GeneratedAt: now}
+
+	database := StatusOperational
+	if sqlDB, err := db.DB.DB(); err != nil || sqlDB.PingContext(ctx) != nil {
+		database = StatusDown
+	}
+
+	ingestion := StatusOperational
+	if GetPusherHealth().ConsecutiveFailures >= PusherKeyFailureThreshold || chatPollingChannels() > 0 {
+		ingestion = StatusDegraded
+	}
+
+	if database != StatusDown {
+		tx := db.DB.WithContext(ctx)
+		tx.Model(&models.MonitoredChannel{}).Where("is_active = ?", true).Count(&status.ChannelsMonitored)
+		tx.Model(&models.LivestreamData{}).
+			Where("is_live = ? AND created_at >= ?", true, now.Add(-(FetchInterval + LivestreamFreshnessLeeway))).
+			Distinct("channel_id").Count(&status.ChannelsLive)
+		tx.Model(&models.ChatMessage{}).Where("created_at >= ?", now.Truncate(24*time.Hour)).Count(&status.MessagesToday)
+
+		var recent struct {
+			AverageLag    *float64
+			LastMessageAt *time.Time
+		}
+		tx.Model(&models.ChatMessage{}).
+			Select("AVG(EXTRACT(EPOCH FROM created_at - message_send_time)) AS average_lag, MAX(created_at) AS last_message_at").
+			Where("created_at >= ? AND message_send_time > ?", now.Add(-ingestionLagWindow), time.Time{}).
+			Scan(&recent)
+		status.IngestionLagSeconds = recent.AverageLag
+		status.LastMessageAt = recent.LastMessageAt
+		if recent.AverageLag != nil && *recent.AverageLag > degradedLagDuration.Seconds() {
+			ingestion = StatusDegraded
+		}
+	}
+
+	fetching := StatusOperational
+	health.Lock()
+	for _, ch := range health.channels {
+		if ch.ConsecutiveFetchFailures >= AlertFetchFailureIntervals {
+			fetching = StatusDegraded
+			break
+		}
+	}
+	health.Unlock()
+
+	status.Components = []ComponentStatus{
+		{Name: "database", Status: database},
+		{Name: "chat_ingestion", Status: ingestion},
+		{Name: "channel_fetching", Status: fetching},
+	}
+	for _, component := range status.Components {
+		if component.Status == StatusDown {
+			status.Status = StatusDown
+			break
+		}
+		if component.Status == StatusDegraded {
+			status.Status = StatusDegraded
+		}
+	}
+	return status
+}
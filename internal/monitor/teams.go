@@ -0,0 +1,164 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Team member roles, each including the permissions of the ones below it
+const (
+	TeamRoleOwner  = "owner"  // Renames and deletes the team, manages owners
+	TeamRoleAdmin  = "admin"  // Invites and removes members, shares channels
+	TeamRoleMember = "member" // Sees the team's channels, reports and dashboard
+)
+
+// TeamRoleRank orders the roles, higher ranks may do everything lower ones can
+var TeamRoleRank = map[string]int{TeamRoleMember: 1, TeamRoleAdmin: 2, TeamRoleOwner: 3}
+
+// TeamDashboard sums up a team's shared channels over a period
+type TeamDashboard struct {
+	TeamID      uuid.UUID              `json:"team_id"`
+	Name        string                 `json:"name"`
+	PeriodStart time.Time              `json:"period_start"`
+	PeriodEnd   time.Time              `json:"period_end"`
+	Totals      TeamDashboardTotals    `json:"totals"`
+	Channels    []TeamDashboardChannel `json:"channels"` // Live channels first, then by hours watched
+}
+
+// TeamDashboardTotals adds up every shared channel
+type TeamDashboardTotals struct {
+	Channels        int     `json:"channels"`
+	LiveNow         int     `json:"live_now"`
+	Streams         int     `json:"streams"`
+	StreamedMinutes int     `json:"streamed_minutes"`
+	HoursWatched    float64 `json:"hours_watched"`
+	TotalMessages   int     `json:"total_messages"`
+	FollowersGained int     `json:"followers_gained"`
+}
+
+// TeamDashboardChannel is one shared channel over the dashboard period
+type TeamDashboardChannel struct {
+	ChannelID       uint                 `json:"channel_id"`
+	Username        string               `json:"username"`
+	IsLive          bool                 `json:"is_live"`
+	Streams         int                  `json:"streams"`
+	StreamedMinutes int                  `json:"streamed_minutes"`
+	HoursWatched    float64              `json:"hours_watched"`
+	AverageViewers  int                  `json:"average_viewers"` // Weighted by stream length
+	PeakViewers     int                  `json:"peak_viewers"`
+	TotalMessages   int                  `json:"total_messages"`
+	FollowersGained int                  `json:"followers_gained"`
+	LastStream      *TeamDashboardStream `json:"last_stream,omitempty"`
+}
+
+// TeamDashboardStream is the latest report of a shared channel
+type TeamDashboardStream struct {
+	ReportID        uuid.UUID `json:"report_id"`
+	LivestreamID    uint      `json:"livestream_id"`
+	Title           string    `json:"title"`
+	StartTime       time.Time `json:"start_time"`
+	DurationMinutes int       `json:"duration_minutes"`
+	AverageViewers  int       `json:"average_viewers"`
+	PeakViewers     int       `json:"peak_viewers"`
+}
+
+// BuildTeamDashboard computes the dashboard of a team's shared channels over [start, end)
+func BuildTeamDashboard(team models.Team, channelIDs []uint, start, end time.Time) (TeamDashboard, error) {
+	dashboard := TeamDashboard{TeamID: team.ID, Name: team.Name, PeriodStart: start, PeriodEnd: end, Channels: []TeamDashboardChannel{}}
+	if len(channelIDs) == 0 {
+		return dashboard, nil
+	}
+
+	var channels []models.MonitoredChannel
+	if err := db.DB.Where("channel_id IN ?", channelIDs).Order("username ASC").Find(&channels).Error; err != nil {
+		return dashboard, fmt.Errorf("failed to load team channels: %w", err)
+	}
+
+	var live []uint
+	if err := db.DB.Model(&models.LivestreamData{}).
+		Where("channel_id IN ? AND is_live = ? AND created_at >= ?", channelIDs, true, time.Now().Add(-(FetchInterval+LivestreamFreshnessLeeway))).
+		Distinct().Pluck("channel_id", &live).Error; err != nil {
+		return dashboard, fmt.Errorf("failed to load live team channels: %w", err)
+	}
+	isLive := make(map[uint]bool, len(live))
+	for _, channelID := range live {
+		isLive[channelID] = true
+	}
+
+	var reports []models.LivestreamReport
+	if err := db.DB.Select("channel_id", "duration_minutes", "hours_watched", "average_viewers", "peak_viewers", "total_messages", "followers_gained").
+		Where("channel_id IN ? AND parent_report_id IS NULL AND report_start_time >= ? AND report_start_time < ?", channelIDs, start, end).
+		Find(&reports).Error; err != nil {
+		return dashboard, fmt.Errorf("failed to load team reports: %w", err)
+	}
+
+	var latest []struct {
+		ChannelID uint
+		TeamDashboardStream
+	}
+	if err := db.DB.Raw(`
+		SELECT DISTINCT ON (channel_id)
+			channel_id, id AS report_id, livestream_id, title, report_start_time AS start_time, duration_minutes,
+			average_viewers, peak_viewers
+		FROM livestream_reports
+		WHERE channel_id IN ? AND parent_report_id IS NULL
+		ORDER BY channel_id, report_start_time DESC, created_at DESC`, channelIDs).Scan(&latest).Error; err != nil {
+		return dashboard, fmt.Errorf("failed to load latest team reports: %w", err)
+	}
+
+	byID := make(map[uint]*TeamDashboardChannel, len(channels))
+	stats := make([]TeamDashboardChannel, len(channels))
+	for i, channel := range channels {
+		stats[i] = TeamDashboardChannel{ChannelID: channel.ChannelID, Username: channel.Username, IsLive: isLive[channel.ChannelID]}
+		byID[channel.ChannelID] = &stats[i]
+	}
+	viewerMinutes := make(map[uint]int, len(channels))
+	for _, report := range reports {
+		channel, ok := byID[report.ChannelID]
+		if !ok {
+			continue
+		}
+		channel.Streams++
+		channel.StreamedMinutes += report.DurationMinutes
+		channel.HoursWatched += report.HoursWatched
+		channel.PeakViewers = max(channel.PeakViewers, report.PeakViewers)
+		channel.TotalMessages += report.TotalMessages
+		channel.FollowersGained += report.FollowersGained
+		viewerMinutes[report.ChannelID] += report.AverageViewers * report.DurationMinutes
+	}
+	for _, row := range latest {
+		if channel, ok := byID[row.ChannelID]; ok {
+			stream := row.TeamDashboardStream
+			channel.LastStream = &stream
+		}
+	}
+
+	for i := range stats {
+		channel := &stats[i]
+		if channel.StreamedMinutes > 0 {
+			channel.AverageViewers = viewerMinutes[channel.ChannelID] / channel.StreamedMinutes
+		}
+		dashboard.Totals.Channels++
+		if channel.IsLive {
+			dashboard.Totals.LiveNow++
+		}
+		dashboard.Totals.Streams += channel.Streams
+		dashboard.Totals.StreamedMinutes += channel.StreamedMinutes
+		dashboard.Totals.HoursWatched += channel.HoursWatched
+		dashboard.Totals.TotalMessages += channel.TotalMessages
+		dashboard.Totals.FollowersGained += channel.FollowersGained
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].IsLive != stats[j].IsLive {
+			return stats[i].IsLive
+		}
+		return stats[i].HoursWatched > stats[j].HoursWatched
+	})
+	dashboard.Channels = stats
+	return dashboard, nil
+}
@@ -0,0 +1,386 @@
+package monitor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Channel timeline event types
+const (
+	TimelineWentLive        = "went_live"
+	TimelineWentOffline     = "went_offline"
+	TimelineTitleChanged    = "title_changed"
+	TimelineBanned          = "banned"
+	TimelineUnbanned        = "unbanned"
+	TimelineMilestone       = "milestone"
+	TimelineReportGenerated = "report_generated"
+	TimelineModeChanged     = "mode_changed"
+	TimelineRenamed         = "renamed"
+)
+
+// TimelineEventTypes lists the event types of the channel timeline, in the order the API documents them
+var TimelineEventTypes = []string{
+	TimelineWentLive, TimelineWentOffline, TimelineTitleChanged, TimelineBanned, TimelineUnbanned,
+	TimelineMilestone, TimelineReportGenerated, TimelineModeChanged, TimelineRenamed,
+}
+
+// followerMilestones are the follower counts announced on the timeline when a channel crosses them while monitored
+var followerMilestones = []int{
+	100, 500, 1_000, 5_000, 10_000, 25_000, 50_000, 100_000, 250_000, 500_000,
+	1_000_000, 2_500_000, 5_000_000, 10_000_000,
+}
+
+// ChannelEvent is an entry of a channel's timeline, derived from the stored snapshots, streams and reports
+type ChannelEvent struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	LivestreamID *uint     `json:"livestream_id,omitempty"`
+	Data         any       `json:"data,omitempty"`
+}
+
+// TimelineQuery selects a page of a channel's timeline, newest first
+type TimelineQuery struct {
+	Before time.Time           // Only events strictly before, zero for the newest
+	Since  *time.Time          // Only events strictly after
+	Types  map[string]struct{} // Empty for all types
+	Limit  int
+}
+
+// ChannelTimeline is a page of a channel's timeline. NextBefore is the cursor of the next (older) page, nil on
+// the last page.
+type ChannelTimeline struct {
+	ChannelID  uint           `json:"channel_id"`
+	Events     []ChannelEvent `json:"events"`
+	NextBefore *time.Time     `json:"next_before,omitempty"`
+}
+
+// timelineSource loads up to limit events of a type (or a pair of types) before the cursor, newest first
+type timelineSource struct {
+	types []string
+	load  func(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error)
+}
+
+var timelineSources = []timelineSource{
+	{[]string{TimelineWentLive, TimelineWentOffline}, loadStreamEvents},
+	{[]string{TimelineTitleChanged}, loadTitleChanges},
+	{[]string{TimelineBanned, TimelineUnbanned}, loadBanChanges},
+	{[]string{TimelineModeChanged}, loadModeChanges},
+	{[]string{TimelineMilestone}, loadFollowerMilestones},
+	{[]string{TimelineReportGenerated}, loadReportEvents},
+	{[]string{TimelineRenamed}, loadRenames},
+}
+
+// GetChannelTimeline assembles a page of the channel's timeline from every event source
+func GetChannelTimeline(channel models.MonitoredChannel, q TimelineQuery) (ChannelTimeline, error) {
+	if q.Before.IsZero() {
+		q.Before = time.Now().Add(time.Second)
+	}
+
+	timeline := ChannelTimeline{ChannelID: channel.ChannelID, Events: []ChannelEvent{}}
+	for _, source := range timelineSources {
+		if !q.wants(source.types...) {
+			continue
+		}
+		events, err := source.load(channel, q)
+		if err != nil {
+			return timeline, err
+		}
+		for _, event := range events {
+			if q.wants(event.Type) {
+				timeline.Events = append(timeline.Events, event)
+			}
+		}
+	}
+
+	// Every source returned its newest events, so the newest Limit of the union are the page
+	sort.SliceStable(timeline.Events, func(i, j int) bool { return timeline.Events[i].Time.After(timeline.Events[j].Time) })
+	if len(timeline.Events) > q.Limit {
+		timeline.Events = timeline.Events[:q.Limit]
+		next := timeline.Events[len(timeline.Events)-1].Time
+		timeline.NextBefore = &next
+	}
+	return timeline, nil
+}
+
+func (q TimelineQuery) wants(types ...string) bool {
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if _, ok := q.Types[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inWindow reports whether an event time falls within the page window
+func (q TimelineQuery) inWindow(t time.Time) bool {
+	return t.Before(q.Before) && (q.Since == nil || t.After(*q.Since))
+}
+
+// sinceOrZero is the lower bound of the page window for SQL, the zero time when unbounded
+func (q TimelineQuery) sinceOrZero() time.Time {
+	if q.Since == nil {
+		return time.Time{}
+	}
+	return *q.Since
+}
+
+// loadStreamEvents derives went_live/went_offline from the livestream snapshots. A stream went offline at its
+// last snapshot once no newer one is expected.
+func loadStreamEvents(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var streams []struct {
+		LivestreamID uint
+		StartTime    time.Time
+		LastSeen     time.Time
+		Title        string
+		PeakViewers  int
+	}
+	if err := db.DB.Raw(`
+		SELECT livestream_id, MIN(start_time) AS start_time, MAX(created_at) AS last_seen,
+			(ARRAY_AGG(session_title ORDER BY created_at) FILTER (WHERE session_title <> ''))[1] AS title,
+			MAX(viewer_count) AS peak_viewers
+		FROM livestream_data
+		WHERE channel_id = ?
+		GROUP BY livestream_id
+		HAVING MIN(start_time) < ? AND MAX(created_at) > ?
+		ORDER BY MIN(start_time) DESC
+		LIMIT ?`, channel.ChannelID, q.Before, q.sinceOrZero(), q.Limit+1).Scan(&streams).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch streams of channel %d: %w", channel.ChannelID, err)
+	}
+
+	offlineBefore := time.Now().Add(-(FetchInterval + LivestreamFreshnessLeeway))
+	var events []ChannelEvent
+	for _, stream := range streams {
+		livestreamID := stream.LivestreamID
+		if q.inWindow(stream.StartTime) {
+			events = append(events, ChannelEvent{
+				Type:         TimelineWentLive,
+				Time:         stream.StartTime,
+				LivestreamID: &livestreamID,
+				Data:         map[string]any{"title": stream.Title},
+			})
+		}
+		if stream.LastSeen.Before(offlineBefore) && q.inWindow(stream.LastSeen) {
+			events = append(events, ChannelEvent{
+				Type:         TimelineWentOffline,
+				Time:         stream.LastSeen,
+				LivestreamID: &livestreamID,
+				Data: map[string]any{
+					"duration_minutes": int(stream.LastSeen.Sub(stream.StartTime).Minutes()),
+					"peak_viewers":     stream.PeakViewers,
+				},
+			})
+		}
+	}
+	return events, nil
+}
+
+// loadTitleChanges finds the snapshots whose title differs from the previous snapshot of the same stream
+func loadTitleChanges(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var changes []struct {
+		LivestreamID  uint
+		CreatedAt     time.Time
+		SessionTitle  string
+		PreviousTitle string
+	}
+	if err := db.DB.Raw(`
+		SELECT livestream_id, created_at, session_title, previous_title FROM (
+			SELECT livestream_id, created_at, session_title,
+				LAG(session_title) OVER (PARTITION BY livestream_id ORDER BY created_at) AS previous_title
+			FROM livestream_data
+			WHERE channel_id = ? AND session_title <> ''
+		) titles
+		WHERE previous_title IS NOT NULL AND previous_title <> session_title AND created_at < ? AND created_at > ?
+		ORDER BY created_at DESC
+		LIMIT ?`, channel.ChannelID, q.Before, q.sinceOrZero(), q.Limit).Scan(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch title changes of channel %d: %w", channel.ChannelID, err)
+	}
+
+	events := make([]ChannelEvent, 0, len(changes))
+	for _, change := range changes {
+		livestreamID := change.LivestreamID
+		events = append(events, ChannelEvent{
+			Type:         TimelineTitleChanged,
+			Time:         change.CreatedAt,
+			LivestreamID: &livestreamID,
+			Data:         map[string]any{"title": change.SessionTitle, "previous_title": change.PreviousTitle},
+		})
+	}
+	return events, nil
+}
+
+// loadBanChanges finds the channel snapshots where is_banned flipped. A channel first seen banned counts as banned
+// at that snapshot.
+func loadBanChanges(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var changes []struct {
+		CreatedAt time.Time
+		IsBanned  bool
+	}
+	if err := db.DB.Raw(`
+		SELECT created_at, is_banned FROM (
+			SELECT created_at, COALESCE((data->>'is_banned')::boolean, false) AS is_banned,
+				LAG(COALESCE((data->>'is_banned')::boolean, false)) OVER (ORDER BY created_at) AS was_banned
+			FROM channel_data
+			WHERE channel_id = ?
+		) bans
+		WHERE is_banned IS DISTINCT FROM COALESCE(was_banned, false) AND created_at < ? AND created_at > ?
+		ORDER BY created_at DESC
+		LIMIT ?`, channel.ChannelID, q.Before, q.sinceOrZero(), q.Limit).Scan(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch ban changes of channel %d: %w", channel.ChannelID, err)
+	}
+
+	events := make([]ChannelEvent, 0, len(changes))
+	for _, change := range changes {
+		eventType := TimelineUnbanned
+		if change.IsBanned {
+			eventType = TimelineBanned
+		}
+		events = append(events, ChannelEvent{Type: eventType, Time: change.CreatedAt})
+	}
+	return events, nil
+}
+
+// loadModeChanges finds the channel snapshots where a chatroom mode (slow, followers, subscribers or emotes only)
+// or its setting changed, with the changed fields
+func loadModeChanges(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var changes []struct {
+		CreatedAt     time.Time
+		Modes         []byte
+		PreviousModes []byte
+	}
+	if err := db.DB.Raw(`
+		SELECT created_at, modes, previous_modes FROM (
+			SELECT created_at, modes, LAG(modes) OVER (ORDER BY created_at) AS previous_modes
+			FROM (
+				SELECT created_at, jsonb_build_object(
+					'slow_mode', data->'chatroom'->'slow_mode',
+					'message_interval', data->'chatroom'->'message_interval',
+					'followers_mode', data->'chatroom'->'followers_mode',
+					'following_min_duration', data->'chatroom'->'following_min_duration',
+					'subscribers_mode', data->'chatroom'->'subscribers_mode',
+					'emotes_mode', data->'chatroom'->'emotes_mode'
+				) AS modes
+				FROM channel_data
+				WHERE channel_id = ? AND jsonb_typeof(data->'chatroom') = 'object'
+			) snapshots
+		) modes
+		WHERE previous_modes IS NOT NULL AND modes <> previous_modes AND created_at < ? AND created_at > ?
+		ORDER BY created_at DESC
+		LIMIT ?`, channel.ChannelID, q.Before, q.sinceOrZero(), q.Limit).Scan(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat mode changes of channel %d: %w", channel.ChannelID, err)
+	}
+
+	events := make([]ChannelEvent, 0, len(changes))
+	for _, change := range changes {
+		var modes, previous map[string]any
+		if json.Unmarshal(change.Modes, &modes) != nil || json.Unmarshal(change.PreviousModes, &previous) != nil {
+			continue
+		}
+		changed := make(map[string]any)
+		for field, value := range modes {
+			if fmt.Sprint(value) != fmt.Sprint(previous[field]) {
+				changed[field] = map[string]any{"from": previous[field], "to": value}
+			}
+		}
+		events = append(events, ChannelEvent{Type: TimelineModeChanged, Time: change.CreatedAt, Data: changed})
+	}
+	return events, nil
+}
+
+// loadFollowerMilestones finds when the channel first reached each follower milestone it crossed while monitored.
+// Milestones already reached at the first snapshot predate monitoring and aren't reported.
+func loadFollowerMilestones(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var bounds struct {
+		First *int
+		Max   *int
+	}
+	if err := db.DB.Raw(`
+		SELECT
+			(SELECT (data->>'followers_count')::int FROM channel_data
+				WHERE channel_id = ? AND data->>'followers_count' IS NOT NULL ORDER BY created_at LIMIT 1) AS first,
+			(SELECT MAX((data->>'followers_count')::int) FROM channel_data
+				WHERE channel_id = ? AND data->>'followers_count' IS NOT NULL) AS max`,
+		channel.ChannelID, channel.ChannelID).Scan(&bounds).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch follower counts of channel %d: %w", channel.ChannelID, err)
+	}
+	if bounds.First == nil || bounds.Max == nil {
+		return nil, nil
+	}
+
+	var events []ChannelEvent
+	for _, milestone := range followerMilestones {
+		if milestone <= *bounds.First || milestone > *bounds.Max {
+			continue
+		}
+		var reachedAt sql.NullTime
+		if err := db.DB.Raw(`
+			SELECT MIN(created_at) FROM channel_data
+			WHERE channel_id = ? AND (data->>'followers_count')::int >= ?`, channel.ChannelID, milestone).
+			Scan(&reachedAt).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch follower milestone %d of channel %d: %w", milestone, channel.ChannelID, err)
+		}
+		if reachedAt.Valid && q.inWindow(reachedAt.Time) {
+			events = append(events, ChannelEvent{
+				Type: TimelineMilestone,
+				Time: reachedAt.Time,
+				Data: map[string]any{"metric": "followers", "value": milestone},
+			})
+		}
+	}
+	return events, nil
+}
+
+// loadReportEvents lists the generated stream reports, chunk reports are part of their parent's
+func loadReportEvents(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var reports []models.LivestreamReport
+	query := db.DB.Select("id", "livestream_id", "title", "created_at").
+		Where("channel_id = ? AND parent_report_id IS NULL AND created_at < ?", channel.ChannelID, q.Before)
+	if q.Since != nil {
+		query = query.Where("created_at > ?", *q.Since)
+	}
+	if err := query.Order("created_at DESC").Limit(q.Limit).Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reports of channel %d: %w", channel.ChannelID, err)
+	}
+
+	events := make([]ChannelEvent, 0, len(reports))
+	for _, report := range reports {
+		livestreamID := report.LivestreamID
+		events = append(events, ChannelEvent{
+			Type:         TimelineReportGenerated,
+			Time:         report.CreatedAt,
+			LivestreamID: &livestreamID,
+			Data:         map[string]any{"report_id": report.ID, "title": report.Title},
+		})
+	}
+	return events, nil
+}
+
+// loadRenames lists the username changes recorded in channel_aliases
+func loadRenames(channel models.MonitoredChannel, q TimelineQuery) ([]ChannelEvent, error) {
+	var aliases []models.ChannelAlias
+	query := db.DB.Where("channel_id = ? AND renamed_at < ?", channel.ChannelID, q.Before)
+	if q.Since != nil {
+		query = query.Where("renamed_at > ?", *q.Since)
+	}
+	if err := query.Order("renamed_at DESC").Limit(q.Limit).Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch renames of channel %d: %w", channel.ChannelID, err)
+	}
+
+	events := make([]ChannelEvent, 0, len(aliases))
+	for _, alias := range aliases {
+		events = append(events, ChannelEvent{
+			Type: TimelineRenamed,
+			Time: alias.RenamedAt,
+			Data: map[string]any{"username": alias.RenamedTo, "previous_username": alias.Username},
+		})
+	}
+	return events, nil
+}
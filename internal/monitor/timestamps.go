@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// ClockSkewTolerance is how far a Kick timestamp may be ahead of our receive time and still be taken as Kick's
+// clock running slightly ahead. Either way a timestamp is never kept later than its receive time; the ones beyond
+// the tolerance are counted apart as a sign one of the clocks is off. Receive times also hold steady through
+// backward steps of our wall clock up to the tolerance, e.g. NTP corrections.
+var ClockSkewTolerance = util.GetEnvDuration("CLOCK_SKEW_TOLERANCE", 5*time.Second)
+
+// TimestampParseStats counts Kick timestamps that couldn't be parsed, per field, and those ahead of our clock
+// since startup
+type TimestampParseStats struct {
+	Failures    map[string]int `json:"failures"`
+	LastField   string         `json:"last_field,omitempty"`
+	LastValue   string         `json:"last_value,omitempty"`
+	LastFailure *time.Time     `json:"last_failure,omitempty"`
+
+	SkewAdjusted        int     `json:"skew_adjusted"`         // Timestamps ahead of their receive time, moved back to it
+	SkewBeyondTolerance int     `json:"skew_beyond_tolerance"` // Of which more than CLOCK_SKEW_TOLERANCE ahead
+	MaxSkewSeconds      float64 `json:"max_skew_seconds"`
+}
+
+var timestampParsing = struct {
+	sync.Mutex
+	stats TimestampParseStats
+}{stats: TimestampParseStats{Failures: make(map[string]int)}}
+
+// parseKickTimestamp parses a timestamp field of a Kick payload, returning the zero time (and recording the
+// failure) when the value can't be parsed. Empty values are expected for optional fields and aren't failures.
+func parseKickTimestamp(field, username, value string) time.Time {
+	t, err := util.ParseKickTime(value)
+	if err == nil || errors.Is(err, util.ErrEmptyTimestamp) {
+		return t
+	}
+
+	log.Printf("Error parsing %s timestamp for %s: %v", field, username, err)
+	now := time.Now()
+	timestampParsing.Lock()
+	defer timestampParsing.Unlock()
+	timestampParsing.stats.Failures[field]++
+	timestampParsing.stats.LastField = field
+	timestampParsing.stats.LastValue = value
+	timestampParsing.stats.LastFailure = &now
+	return time.Time{}
+}
+
+// normalizeKickTimestamp parses a timestamp field of a Kick payload received at receivedAt (from receiveTime) and
+// moves it back to receivedAt when it is ahead of it: nothing is sent after we receive it, and a timestamp from
+// the future would land in the next burst window or timeline block. The zero time is returned like
+// parseKickTimestamp does.
+func normalizeKickTimestamp(field, username, value string, receivedAt time.Time) time.Time {
+	t := parseKickTimestamp(field, username, value)
+	if t.IsZero() || !t.After(receivedAt) {
+		return t
+	}
+
+	ahead := t.Sub(receivedAt)
+	timestampParsing.Lock()
+	stats := &timestampParsing.stats
+	stats.SkewAdjusted++
+	stats.MaxSkewSeconds = max(stats.MaxSkewSeconds, ahead.Seconds())
+	beyond := ahead > ClockSkewTolerance
+	if beyond {
+		stats.SkewBeyondTolerance++
+	}
+	logIt := beyond && (stats.SkewBeyondTolerance == 1 || stats.SkewBeyondTolerance%1000 == 0)
+	count := stats.SkewBeyondTolerance
+	timestampParsing.Unlock()
+
+	if logIt {
+		log.Printf("Warning: %s timestamp for %s is %s ahead of our clock (%d beyond CLOCK_SKEW_TOLERANCE so far), using the receive time",
+			field, username, ahead.Round(time.Millisecond), count)
+	}
+	return receivedAt
+}
+
+// receiveClock stamps incoming payloads. Its times are UTC without a monotonic reading, so they compare and
+// persist the same way as parsed Kick timestamps, and they never go backwards by up to ClockSkewTolerance.
+var receiveClock struct {
+	sync.Mutex
+	last time.Time
+}
+
+// receiveTime returns the time an incoming payload is received at
+func receiveTime() time.Time {
+	now := time.Now().UTC().Round(0)
+	receiveClock.Lock()
+	defer receiveClock.Unlock()
+	if now.Before(receiveClock.last) && receiveClock.last.Sub(now) <= ClockSkewTolerance {
+		now = receiveClock.last // The wall clock stepped back a little, hold until it catches up
+	}
+	receiveClock.last = now
+	return now
+}
+
+// GetTimestampParseStats returns a snapshot of the timestamp parse failures
+func GetTimestampParseStats() TimestampParseStats {
+	timestampParsing.Lock()
+	defer timestampParsing.Unlock()
+
+	stats := timestampParsing.stats
+	stats.Failures = make(map[string]int, len(timestampParsing.stats.Failures))
+	for field, count := range timestampParsing.stats.Failures {
+		stats.Failures[field] = count
+	}
+	return stats
+}
@@ -0,0 +1,331 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/retconned/kick-monitor/internal/db"
+)
+
+const (
+	TitleRampWindow        = 30 * time.Minute // A stream's ramp is the viewers it gained per minute over its first 30 minutes
+	TitleMinStreams        = 2                // Streams a keyword, style or template needs to be listed
+	titleMinKeywordLength  = 3
+	titleGroupsListed      = 50 // Keywords and templates listed, the most streamed kept
+	titleExamples          = 3
+	titleCapsShare         = 0.6 // Share of uppercase letters from which a title is shouting
+	titleShortLength       = 30  // Titles up to this many characters are short, up to titleLongLength medium
+	titleLongLength        = 60
+	titleMinLettersForCaps = 4
+)
+
+// Title styles, what a title looks like whatever its words
+const (
+	TitleStyleCaps        = "all_caps"
+	TitleStyleEmoji       = "emoji"
+	TitleStyleQuestion    = "question"
+	TitleStyleExclamation = "exclamation"
+	TitleStyleNumber      = "number"
+	TitleStyleTag         = "bracket_tag"  // [18+], (DROPS ON) and the like
+	TitleStyleSeparator   = "separated"    // Parts split by | or //
+	TitleStyleCommand     = "chat_command" // !discord, !sub
+	TitleStyleShort       = "short"
+	TitleStyleMedium      = "medium"
+	TitleStyleLong        = "long"
+)
+
+var (
+	titleTagRegex      = regexp.MustCompile(`\[[^\]]+\]|\([^)]+\)`)
+	titleCommandRegex  = regexp.MustCompile(`(^|\s)![A-Za-z]+`)
+	titleNumberRegex   = regexp.MustCompile(`\d+`)
+	titleTemplateRegex = regexp.MustCompile(`[^\p{L}\p{N}#]+`)
+)
+
+// titleStopwords are left out of the keywords, from the stopwords of every detected language
+var titleStopwords = func() map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, list := range latinStopwords {
+		for _, word := range list {
+			words[word] = struct{}{}
+		}
+	}
+	return words
+}()
+
+// TitleAnalytics groups a channel's streams by the keywords, styles and templates of their titles and compares
+// each group with the channel's average, telling which titles draw viewers in faster
+type TitleAnalytics struct {
+	ChannelID uint         `json:"channel_id"`
+	Username  string       `json:"username"`
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Baseline  TitleMetrics `json:"baseline"`  // Every stream of the period
+	Keywords  []TitleGroup `json:"keywords"`  // Best ramp lift first
+	Styles    []TitleGroup `json:"styles"`    // Best ramp lift first
+	Templates []TitleGroup `json:"templates"` // Recurring titles with the numbers masked, "day # of ..."
+}
+
+// TitleMetrics are the averages of a group of streams
+type TitleMetrics struct {
+	Streams         int     `json:"streams"`
+	ViewerRamp      float64 `json:"viewer_ramp"`      // Viewers gained per minute over the first TitleRampWindow
+	EarlyPeak       float64 `json:"early_peak"`       // Peak viewers within the first TitleRampWindow
+	AverageViewers  float64 `json:"average_viewers"`  // Over the whole stream
+	FollowersGained float64 `json:"followers_gained"` // Per stream
+}
+
+// TitleGroup is the streams sharing a keyword, style or template, with their lift over the baseline in percent.
+// Lifts are nil when the baseline is zero.
+type TitleGroup struct {
+	Key string `json:"key"`
+	TitleMetrics
+	RampLift      *float64 `json:"ramp_lift"`
+	EarlyPeakLift *float64 `json:"early_peak_lift"`
+	ViewersLift   *float64 `json:"viewers_lift"`
+	Examples      []string `json:"examples"` // Most recent titles first
+}
+
+// titleStream is a stream's title and the metrics it is compared on
+type titleStream struct {
+	Title           string
+	ViewerRamp      float64
+	EarlyPeak       int
+	AverageViewers  int
+	FollowersGained int
+}
+
+// BuildTitleAnalytics analyses the titles of the channel's streams that started since the given time. Windowed
+// and chunk reports are left out, they cover part of a stream.
+func BuildTitleAnalytics(channelID uint, username string, since, now time.Time) (TitleAnalytics, error) {
+	analytics := TitleAnalytics{ChannelID: channelID, Username: username, From: since.UTC(), To: now.UTC()}
+
+	var rows []struct {
+		Title                string
+		ReportStartTime      time.Time
+		AverageViewers       int
+		FollowersGained      int
+		ViewerCountsTimeline []byte
+	}
+	if err := db.Reader().Raw(`
+		SELECT title, report_start_time, average_viewers, followers_gained, viewer_counts_timeline
+		FROM livestream_reports
+		WHERE channel_id = ? AND parent_report_id IS NULL AND window_start IS NULL AND window_end IS NULL
+			AND report_start_time >= ? AND report_start_time < ?
+		ORDER BY report_start_time DESC`, channelID, since, now).Scan(&rows).Error; err != nil {
+		return analytics, fmt.Errorf("failed to fetch reports of channel %d: %w", channelID, err)
+	}
+
+	streams := make([]titleStream, 0, len(rows))
+	for _, row := range rows {
+		if strings.TrimSpace(row.Title) == "" {
+			continue
+		}
+		var timeline []ViewerCountPoint
+		if len(row.ViewerCountsTimeline) > 0 {
+			if err := json.Unmarshal(row.ViewerCountsTimeline, &timeline); err != nil {
+				continue
+			}
+		}
+		ramp, earlyPeak := viewerRamp(timeline, row.ReportStartTime)
+		streams = append(streams, titleStream{
+			Title:           row.Title,
+			ViewerRamp:      ramp,
+			EarlyPeak:       earlyPeak,
+			AverageViewers:  row.AverageViewers,
+			FollowersGained: row.FollowersGained,
+		})
+	}
+
+	analytics.Baseline = summarizeTitleStreams(streams)
+	keywords := make(map[string][]titleStream)
+	styles := make(map[string][]titleStream)
+	templates := make(map[string][]titleStream)
+	for _, stream := range streams {
+		for _, keyword := range titleKeywords(stream.Title) {
+			keywords[keyword] = append(keywords[keyword], stream)
+		}
+		for _, style := range titleStyles(stream.Title) {
+			styles[style] = append(styles[style], stream)
+		}
+		if template := titleTemplate(stream.Title); template != "" {
+			templates[template] = append(templates[template], stream)
+		}
+	}
+	analytics.Keywords = titleGroups(keywords, analytics.Baseline, titleGroupsListed)
+	analytics.Styles = titleGroups(styles, analytics.Baseline, 0)
+	analytics.Templates = titleGroups(templates, analytics.Baseline, titleGroupsListed)
+	return analytics, nil
+}
+
+// viewerRamp returns the viewers a stream gained per minute from its first sample to its peak within
+// TitleRampWindow of the start, spread over the samples of the window, and that peak
+func viewerRamp(timeline []ViewerCountPoint, start time.Time) (float64, int) {
+	if len(timeline) == 0 {
+		return 0, 0
+	}
+	until := start.Add(TitleRampWindow)
+	first, last, peak := timeline[0], timeline[0], 0
+	for _, point := range timeline {
+		if point.Time.After(until) {
+			break
+		}
+		last = point
+		peak = max(peak, point.Count)
+	}
+	minutes := last.Time.Sub(first.Time).Minutes()
+	if minutes <= 0 {
+		return 0, peak
+	}
+	return roundTo(float64(peak-first.Count)/minutes, 2), peak
+}
+
+func summarizeTitleStreams(streams []titleStream) TitleMetrics {
+	metrics := TitleMetrics{Streams: len(streams)}
+	if len(streams) == 0 {
+		return metrics
+	}
+	for _, stream := range streams {
+		metrics.ViewerRamp += stream.ViewerRamp
+		metrics.EarlyPeak += float64(stream.EarlyPeak)
+		metrics.AverageViewers += float64(stream.AverageViewers)
+		metrics.FollowersGained += float64(stream.FollowersGained)
+	}
+	n := float64(len(streams))
+	metrics.ViewerRamp = roundTo(metrics.ViewerRamp/n, 2)
+	metrics.EarlyPeak = roundTo(metrics.EarlyPeak/n, 1)
+	metrics.AverageViewers = roundTo(metrics.AverageViewers/n, 1)
+	metrics.FollowersGained = roundTo(metrics.FollowersGained/n, 1)
+	return metrics
+}
+
+// titleGroups summarizes the groups of at least TitleMinStreams streams, keeping the limit most streamed when
+// limit is positive, and sorts them by ramp lift
+func titleGroups(groups map[string][]titleStream, baseline TitleMetrics, limit int) []TitleGroup {
+	result := []TitleGroup{}
+	for key, streams := range groups {
+		if len(streams) < TitleMinStreams {
+			continue
+		}
+		group := TitleGroup{Key: key, TitleMetrics: summarizeTitleStreams(streams), Examples: []string{}}
+		group.RampLift = percentChange(baseline.ViewerRamp, group.ViewerRamp)
+		group.EarlyPeakLift = percentChange(baseline.EarlyPeak, group.EarlyPeak)
+		group.ViewersLift = percentChange(baseline.AverageViewers, group.AverageViewers)
+		seen := make(map[string]struct{})
+		for _, stream := range streams {
+			if _, ok := seen[stream.Title]; ok {
+				continue
+			}
+			seen[stream.Title] = struct{}{}
+			group.Examples = append(group.Examples, stream.Title)
+			if len(group.Examples) == titleExamples {
+				break
+			}
+		}
+		result = append(result, group)
+	}
+
+	if limit > 0 && len(result) > limit {
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Streams != result[j].Streams {
+				return result[i].Streams > result[j].Streams
+			}
+			return result[i].Key < result[j].Key
+		})
+		result = result[:limit]
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].RampLift, result[j].RampLift
+		switch {
+		case a != nil && b != nil && *a != *b:
+			return *a > *b
+		case (a == nil) != (b == nil):
+			return a != nil
+		case result[i].Streams != result[j].Streams:
+			return result[i].Streams > result[j].Streams
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// titleKeywords returns the distinct lowercase words of a title, without stopwords, short words and numbers
+func titleKeywords(title string) []string {
+	var keywords []string
+	seen := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len([]rune(word)) < titleMinKeywordLength || titleNumberRegex.FindString(word) == word {
+			continue
+		}
+		if _, stop := titleStopwords[word]; stop {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// titleStyles returns the styles of a title
+func titleStyles(title string) []string {
+	var styles []string
+	letters, upper, emoji := 0, 0, false
+	for _, r := range title {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		case unicode.Is(unicode.So, r) || r >= 0x1F000:
+			emoji = true
+		}
+	}
+	if letters >= titleMinLettersForCaps && float64(upper) >= titleCapsShare*float64(letters) {
+		styles = append(styles, TitleStyleCaps)
+	}
+	if emoji {
+		styles = append(styles, TitleStyleEmoji)
+	}
+	if strings.Contains(title, "?") {
+		styles = append(styles, TitleStyleQuestion)
+	}
+	if strings.Contains(titleCommandRegex.ReplaceAllString(title, " "), "!") {
+		styles = append(styles, TitleStyleExclamation)
+	}
+	if titleNumberRegex.MatchString(title) {
+		styles = append(styles, TitleStyleNumber)
+	}
+	if titleTagRegex.MatchString(title) {
+		styles = append(styles, TitleStyleTag)
+	}
+	if strings.Contains(title, "|") || strings.Contains(title, "//") {
+		styles = append(styles, TitleStyleSeparator)
+	}
+	if titleCommandRegex.MatchString(title) {
+		styles = append(styles, TitleStyleCommand)
+	}
+	switch length := len([]rune(strings.TrimSpace(title))); {
+	case length <= titleShortLength:
+		styles = append(styles, TitleStyleShort)
+	case length <= titleLongLength:
+		styles = append(styles, TitleStyleMedium)
+	default:
+		styles = append(styles, TitleStyleLong)
+	}
+	return styles
+}
+
+// titleTemplate normalizes a title so the episodes of a series match: lowercase, numbers masked as #, punctuation
+// and emoji collapsed to single spaces
+func titleTemplate(title string) string {
+	template := titleNumberRegex.ReplaceAllString(strings.ToLower(title), "#")
+	return strings.TrimSpace(titleTemplateRegex.ReplaceAllString(template, " "))
+}
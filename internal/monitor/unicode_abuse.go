@@ -0,0 +1,198 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+const (
+	ZalgoMinStack            = 4    // Combining marks on one character that make it zalgo, scripts like Thai stack up to 3
+	CombiningMinMarks        = 12   // Combining marks a message needs before their share is checked
+	CombiningMinShare        = 0.6  // Share of combining marks among the message's characters
+	EmojiFloodMinEmoji       = 20   // Emoji in one message that make it a flood
+	EmojiFloodMinShare       = 0.8  // Share of emoji among the message's non-space characters
+	unicodeAbuseMaxExamples  = 5    // Example messages kept per category
+	unicodeAbuseExampleRunes = 200  // Examples are cut to this many characters
+	unicodeAbuseMaxOffenders = 1000 // Offenders listed per category
+)
+
+// Unicode abuse categories
+const (
+	UnicodeAbuseZalgo       = "zalgo"               // Stacks of combining marks rendering over neighbouring lines
+	UnicodeAbuseCombining   = "excessive_combining" // Messages mostly made of combining marks
+	UnicodeAbuseRTLOverride = "rtl_override"        // Bidi override and isolate controls flipping the surrounding text
+	UnicodeAbuseEmojiFlood  = "emoji_flood"         // Walls of emoji
+)
+
+// UnicodeAbuseCategories lists the categories in report order
+var UnicodeAbuseCategories = []string{UnicodeAbuseZalgo, UnicodeAbuseCombining, UnicodeAbuseRTLOverride, UnicodeAbuseEmojiFlood}
+
+// UnicodeAbuseReport is the unicode abuse section of a spam report
+type UnicodeAbuseReport struct {
+	Messages   int                    `json:"messages"` // Messages in at least one category
+	Chatters   int                    `json:"chatters"`
+	Categories []UnicodeAbuseCategory `json:"categories"`
+}
+
+// UnicodeAbuseCategory counts the messages of one abuse category
+type UnicodeAbuseCategory struct {
+	Category  string                 `json:"category"`
+	Messages  int                    `json:"messages"`
+	Chatters  int                    `json:"chatters"`
+	Offenders []UnicodeAbuseOffender `json:"offenders"` // Most messages first
+	Examples  []UnicodeAbuseExample  `json:"examples"`
+}
+
+// UnicodeAbuseOffender is a chatter who sent messages of a category
+type UnicodeAbuseOffender struct {
+	SenderID int    `json:"sender_id"`
+	Username string `json:"username"`
+	Messages int    `json:"messages"`
+}
+
+// UnicodeAbuseExample is an abusive message, with its bidi controls spelled out so it displays safely
+type UnicodeAbuseExample struct {
+	Username string    `json:"username"`
+	Message  string    `json:"message"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// detectUnicodeAbuse classifies the messages of a report window into the unicode abuse categories. It also
+// returns how many abusive messages each sender sent, which count towards their suspicion score. Trusted chatters
+// and app accounts are never reported.
+func detectUnicodeAbuse(messages []models.ChatMessage, trusted map[int]struct{}) (UnicodeAbuseReport, map[int]int) {
+	categories := make(map[string]*UnicodeAbuseCategory, len(UnicodeAbuseCategories))
+	offenders := make(map[string]map[int]*UnicodeAbuseOffender, len(UnicodeAbuseCategories))
+	for _, category := range UnicodeAbuseCategories {
+		categories[category] = &UnicodeAbuseCategory{Category: category, Offenders: []UnicodeAbuseOffender{}, Examples: []UnicodeAbuseExample{}}
+		offenders[category] = make(map[int]*UnicodeAbuseOffender)
+	}
+
+	report := UnicodeAbuseReport{Categories: make([]UnicodeAbuseCategory, 0, len(UnicodeAbuseCategories))}
+	perSender := make(map[int]int)
+	for _, msg := range messages {
+		if _, ok := trusted[msg.SenderID]; ok {
+			continue
+		}
+		if _, isApp := AppSenders[msg.SenderUsername]; isApp {
+			continue
+		}
+		found := classifyUnicodeAbuse(msg.Message)
+		if len(found) == 0 {
+			continue
+		}
+
+		report.Messages++
+		perSender[msg.SenderID]++
+		for _, name := range found {
+			category := categories[name]
+			category.Messages++
+			offender, ok := offenders[name][msg.SenderID]
+			if !ok {
+				offender = &UnicodeAbuseOffender{SenderID: msg.SenderID, Username: msg.SenderUsername}
+				offenders[name][msg.SenderID] = offender
+			}
+			offender.Messages++
+			if len(category.Examples) < unicodeAbuseMaxExamples {
+				category.Examples = append(category.Examples, UnicodeAbuseExample{
+					Username: msg.SenderUsername,
+					Message:  truncateRunes(revealBidiControls(msg.Message), unicodeAbuseExampleRunes),
+					SentAt:   msg.MessageSendTime,
+				})
+			}
+		}
+	}
+
+	for _, name := range UnicodeAbuseCategories {
+		category := categories[name]
+		category.Chatters = len(offenders[name])
+		for _, offender := range offenders[name] {
+			category.Offenders = append(category.Offenders, *offender)
+		}
+		sort.Slice(category.Offenders, func(i, j int) bool {
+			if category.Offenders[i].Messages != category.Offenders[j].Messages {
+				return category.Offenders[i].Messages > category.Offenders[j].Messages
+			}
+			return category.Offenders[i].SenderID < category.Offenders[j].SenderID
+		})
+		if len(category.Offenders) > unicodeAbuseMaxOffenders {
+			category.Offenders = category.Offenders[:unicodeAbuseMaxOffenders]
+		}
+		report.Categories = append(report.Categories, *category)
+	}
+	report.Chatters = len(perSender)
+	return report, perSender
+}
+
+// classifyUnicodeAbuse returns the abuse categories of a message, in UnicodeAbuseCategories order
+func classifyUnicodeAbuse(message string) []string {
+	var marks, stack, maxStack, emoji, visible int
+	bidi := false
+	for _, r := range message {
+		switch {
+		case isEmojiModifier(r):
+			continue // Skin tones, joiners and variation selectors belong to the previous emoji
+		case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r):
+			marks++
+			stack++
+			maxStack = max(maxStack, stack)
+			continue
+		case isBidiControl(r):
+			bidi = true
+		case isEmoji(r):
+			emoji++
+		}
+		stack = 0
+		if !unicode.IsSpace(r) {
+			visible++
+		}
+	}
+
+	var found []string
+	if maxStack >= ZalgoMinStack {
+		found = append(found, UnicodeAbuseZalgo)
+	}
+	if marks >= CombiningMinMarks && float64(marks)/float64(marks+visible) >= CombiningMinShare {
+		found = append(found, UnicodeAbuseCombining)
+	}
+	if bidi {
+		found = append(found, UnicodeAbuseRTLOverride)
+	}
+	if emoji >= EmojiFloodMinEmoji && float64(emoji)/float64(visible) >= EmojiFloodMinShare {
+		found = append(found, UnicodeAbuseEmojiFlood)
+	}
+	return found
+}
+
+// isBidiControl reports whether r is an explicit bidi embedding, override or isolate control. Plain direction
+// marks (LRM, RLM) are left alone, right-to-left chatters use them legitimately.
+func isBidiControl(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+func isEmojiModifier(r rune) bool {
+	return r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F) || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+// isEmoji reports whether r is in one of the pictographic emoji blocks
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) || (r >= 0x2600 && r <= 0x27BF) || (r >= 0x2B00 && r <= 0x2BFF)
+}
+
+// revealBidiControls spells out bidi controls as <U+XXXX> so an example can't flip the text around it
+func revealBidiControls(message string) string {
+	return bidiControlReplacer.Replace(message)
+}
+
+var bidiControlReplacer = func() *strings.Replacer {
+	var pairs []string
+	for _, r := range []rune{0x202A, 0x202B, 0x202C, 0x202D, 0x202E, 0x2066, 0x2067, 0x2068, 0x2069} {
+		pairs = append(pairs, string(r), fmt.Sprintf("<U+%04X>", r))
+	}
+	return strings.NewReplacer(pairs...)
+}()
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	ViewerChatMinCorrelation = 0.2 // Viewer/chat correlation below which a stream is flagged for view-botting
+	ViewerChatMinPoints      = 6   // Timeline blocks a stream needs before the correlation is meaningful
+)
+
+// ViewerBotAnalysis is the viewer-bot section of a livestream report. Real audiences chat more as they grow, so
+// the viewer count and the chat rate move together; bought viewers inflate the first without touching the second.
+type ViewerBotAnalysis struct {
+	ViewerChatCorrelation *float64 `json:"viewer_chat_correlation"` // Pearson coefficient, nil when either series is flat or too short
+	Points                int      `json:"points"`                  // MessageTimelineBlock blocks correlated
+	Threshold             float64  `json:"threshold"`
+	Suspected             bool     `json:"suspected"`
+}
+
+// analyzeViewerBots correlates the average viewer count of each message timeline block with its chat rate
+func analyzeViewerBots(viewers []ViewerCountPoint, messages []MessageCountPoint) ViewerBotAnalysis {
+	analysis := ViewerBotAnalysis{Threshold: ViewerChatMinCorrelation}
+
+	viewerSums := make(map[time.Time]int, len(messages))
+	viewerSamples := make(map[time.Time]int, len(messages))
+	for _, point := range viewers {
+		block := point.Time.Truncate(MessageTimelineBlock)
+		viewerSums[block] += point.Count
+		viewerSamples[block]++
+	}
+
+	var xs, ys []float64
+	for _, point := range messages {
+		samples := viewerSamples[point.Time]
+		if samples == 0 {
+			continue
+		}
+		xs = append(xs, float64(viewerSums[point.Time])/float64(samples))
+		ys = append(ys, float64(point.Count))
+	}
+	analysis.Points = len(xs)
+	if analysis.Points < ViewerChatMinPoints {
+		return analysis
+	}
+
+	if r, ok := pearson(xs, ys); ok {
+		r = math.Round(r*1000) / 1000
+		analysis.ViewerChatCorrelation = &r
+		analysis.Suspected = r < ViewerChatMinCorrelation
+	}
+	return analysis
+}
+
+// pearson returns the correlation coefficient of two equally long series; ok is false when either has no variance
+func pearson(xs, ys []float64) (r float64, ok bool) {
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Viewer milestone kinds, the moments of a stream marked on its viewer chart
+const (
+	MilestonePeak      = "peak"      // The stream's peak viewers
+	MilestoneMultiple  = "multiple"  // Viewers first reached a multiple of those at the start
+	MilestoneThreshold = "threshold" // Viewers first reached a round number
+)
+
+// milestoneMultiples are the multiples of the starting viewers that are marked
+var milestoneMultiples = []int{2, 3, 5, 10}
+
+// milestoneThresholds are the round viewer counts that are marked
+var milestoneThresholds = []int{100, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000}
+
+const (
+	milestoneBaselineWindow = 5 * time.Minute // Samples from the start of the stream its starting viewers are the peak of
+	milestoneMinBaseline    = 10              // Lowest starting viewers multiples count from, so 1 to 2 viewers isn't a doubling
+)
+
+// ViewerMilestone is a moment a stream's viewers crossed a notable threshold, to annotate its viewer chart with
+type ViewerMilestone struct {
+	Kind      string    `json:"kind"`
+	Time      time.Time `json:"time"`
+	Viewers   int       `json:"viewers"`
+	Factor    int       `json:"factor,omitempty"`    // Multiple of the starting viewers, for multiple
+	Threshold int       `json:"threshold,omitempty"` // Round number reached, for threshold
+	Title     string    `json:"title,omitempty"`     // Stream title at the time, usually what was happening
+	Label     string    `json:"label"`
+}
+
+// detectViewerMilestones finds the milestones of the smoothed viewer samples within [start, end), sorted by time.
+// A sample's title is the session title it was fetched with; Pusher updates carry none and take the last one seen.
+func detectViewerMilestones(samples []models.LivestreamData, start, end time.Time) []ViewerMilestone {
+	milestones := []ViewerMilestone{}
+	var inWindow []models.LivestreamData
+	for _, sample := range samples {
+		if !sample.CreatedAt.Before(start) && sample.CreatedAt.Before(end) {
+			inWindow = append(inWindow, sample)
+		}
+	}
+	if len(inWindow) == 0 {
+		return milestones
+	}
+
+	baseline, peak := 0, 0
+	for i, sample := range inWindow {
+		if sample.CreatedAt.Sub(inWindow[0].CreatedAt) <= milestoneBaselineWindow {
+			baseline = max(baseline, sample.ViewerCount)
+		}
+		if sample.ViewerCount > inWindow[peak].ViewerCount {
+			peak = i
+		}
+	}
+	baseline = max(baseline, milestoneMinBaseline)
+
+	title := ""
+	nextMultiple, nextThreshold := 0, 0
+	for nextThreshold < len(milestoneThresholds) && milestoneThresholds[nextThreshold] <= inWindow[0].ViewerCount {
+		nextThreshold++ // Already there when the report starts
+	}
+	for i, sample := range inWindow {
+		if sample.SessionTitle != "" {
+			title = sample.SessionTitle
+		}
+		for nextMultiple < len(milestoneMultiples) && sample.ViewerCount >= baseline*milestoneMultiples[nextMultiple] {
+			factor := milestoneMultiples[nextMultiple]
+			milestones = append(milestones, ViewerMilestone{
+				Kind: MilestoneMultiple, Time: sample.CreatedAt, Viewers: sample.ViewerCount, Factor: factor, Title: title,
+				Label: fmt.Sprintf("%dx the starting viewers (%d)", factor, baseline),
+			})
+			nextMultiple++
+		}
+		for nextThreshold < len(milestoneThresholds) && sample.ViewerCount >= milestoneThresholds[nextThreshold] {
+			threshold := milestoneThresholds[nextThreshold]
+			milestones = append(milestones, ViewerMilestone{
+				Kind: MilestoneThreshold, Time: sample.CreatedAt, Viewers: sample.ViewerCount, Threshold: threshold, Title: title,
+				Label: fmt.Sprintf("Reached %d viewers", threshold),
+			})
+			nextThreshold++
+		}
+		if i == peak && sample.ViewerCount > 0 {
+			milestones = append(milestones, ViewerMilestone{
+				Kind: MilestonePeak, Time: sample.CreatedAt, Viewers: sample.ViewerCount, Title: title,
+				Label: fmt.Sprintf("Peak of %d viewers", sample.ViewerCount),
+			})
+		}
+	}
+	return milestones
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/models"
+)
+
+// Sources of livestream_data rows
+const (
+	LivestreamSourceHTTP   = "http"
+	LivestreamSourcePusher = "pusher"
+)
+
+// MaxSampleGap caps how long a single viewer sample is assumed to last when averaging the viewers over time,
+// so a sample before a gap in data collection doesn't stand for the whole gap.
+var MaxSampleGap = FetchInterval + LivestreamFreshnessLeeway
+
+// ViewerCountEventData covers the payload shapes Kick uses for viewer count updates on channel.{id}
+type ViewerCountEventData struct {
+	ID          int  `json:"id"`
+	ViewerCount *int `json:"viewer_count"`
+	Viewers     *int `json:"viewers"`
+	Livestream  *struct {
+		ID          int  `json:"id"`
+		ViewerCount *int `json:"viewer_count"`
+	} `json:"livestream"`
+}
+
+// viewerCount extracts the viewer count and livestream ID from whichever fields are present
+func (d ViewerCountEventData) viewerCount() (count int, livestreamID uint, ok bool) {
+	if d.Livestream != nil && d.Livestream.ViewerCount != nil {
+		return *d.Livestream.ViewerCount, uint(d.Livestream.ID), true
+	}
+	if d.ViewerCount != nil {
+		return *d.ViewerCount, uint(d.ID), true
+	}
+	if d.Viewers != nil {
+		return *d.Viewers, uint(d.ID), true
+	}
+	return 0, 0, false
+}
+
+// handleViewerCountEvent persists a viewer count received over Pusher between HTTP fetches.
+// The row copies the livestream metadata from the last HTTP snapshot so both sources form one series.
+func handleViewerCountEvent(channel *models.MonitoredChannel, msg IncomingMessage) {
+	var data ViewerCountEventData
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		log.Printf("Error unmarshalling viewer count event for %s: %v", channel.Username, err)
+		return
+	}
+
+	count, livestreamID, ok := data.viewerCount()
+	if !ok {
+		return
+	}
+
+	snapshot, ok := livestreamSnapshot(channel.ChannelID)
+	if !ok {
+		// No live stream known from the HTTP fetcher yet, nothing to attach the update to
+		return
+	}
+	if livestreamID != 0 && livestreamID != snapshot.LivestreamID {
+		return
+	}
+
+	update := snapshot
+	update.ViewerCount = count
+	update.Source = LivestreamSourcePusher
+	update.CreatedAt = receiveTime() // Stamped on receipt, the row may sit in the write queue for a while
+
+	recordLiveViewers(channel.ChannelID, update.LivestreamID, count, update.CreatedAt)
+	livestreamDataWrites.enqueue(update, func(err error) {
+		if err != nil {
+			log.Printf("Error saving Pusher viewer update for %s (Livestream ID: %d): %v", channel.Username, update.LivestreamID, err)
+			return
+		}
+		recordLivestreamSample(channel.ChannelID, update.LivestreamID, update.StartTime, update.CreatedAt)
+	})
+}
+
+// calculateACV returns the average concurrent viewers of a sample series, the industry's time-weighted average:
+// each sample holds until the next one, or until end for the last, capped at MaxSampleGap. Samples are taken in
+// time order and one sharing the time of the next holds for nothing, so a burst of Pusher updates counts no more
+// than the HTTP fetch it lands next to. tracked is the time the samples held, what the average is over.
+func calculateACV(samples []models.LivestreamData, end time.Time) (acv float64, tracked time.Duration) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	ordered := slices.Clone(samples)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].CreatedAt.Before(ordered[j].CreatedAt) })
+
+	var viewerSeconds float64
+	for i, sample := range ordered {
+		until := end
+		if i+1 < len(ordered) {
+			until = ordered[i+1].CreatedAt
+		}
+		held := min(max(until.Sub(sample.CreatedAt), 0), MaxSampleGap)
+		viewerSeconds += float64(sample.ViewerCount) * held.Seconds()
+		tracked += held
+	}
+	if tracked == 0 {
+		// All samples at one instant, which is all there is to go by
+		total := 0
+		for _, sample := range ordered {
+			total += sample.ViewerCount
+		}
+		return float64(total) / float64(len(ordered)), 0
+	}
+	return viewerSeconds / tracked.Seconds(), tracked
+}
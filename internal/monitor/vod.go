@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+)
+
+const (
+	VODResolveAttempts = 4                // Kick can take a while to publish the VOD after a stream ends
+	VODResolveDelay    = 10 * time.Minute // Delay between resolution attempts
+)
+
+// KickVideo is an entry of https://kick.com/api/v2/channels/{slug}/videos
+type KickVideo struct {
+	ID           int    `json:"id"` // Livestream ID
+	Slug         string `json:"slug"`
+	SessionTitle string `json:"session_title"`
+	StartTime    string `json:"start_time"`
+	Source       string `json:"source"` // HLS playlist URL
+	Video        struct {
+		ID   int    `json:"id"`
+		UUID string `json:"uuid"`
+	} `json:"video"`
+}
+
+// fetchViaProxy fetches a Kick API URL through the configured proxy and returns the extracted JSON body.
+// The request counts towards the proxy budget of username.
+func fetchViaProxy(apiURL, username string) (string, error) {
+	return fetchJSONVia(pageFetcher, apiURL, username)
+}
+
+// fetchJSONVia is fetchViaProxy going through fetcher instead of the configured Fetcher
+func fetchJSONVia(fetcher Fetcher, apiURL, username string) (string, error) {
+	page, err := fetchPageVia(fetcher, apiURL, username)
+	if err != nil {
+		return "", err
+	}
+
+	jsonString, err := util.ExtractJSONFromHTML(page)
+	if err != nil {
+		return "", fmt.Errorf("error extracting JSON from HTML for %s: %w", apiURL, err)
+	}
+	return jsonString, nil
+}
+
+// fetchPageViaProxy fetches a URL through the configured Fetcher and returns the raw response it rendered. The
+// request counts towards the proxy budget of budgetKey.
+func fetchPageViaProxy(apiURL, budgetKey string) (string, error) {
+	return fetchPageVia(pageFetcher, apiURL, budgetKey)
+}
+
+// fetchPageVia is fetchPageViaProxy going through fetcher instead of the configured Fetcher
+func fetchPageVia(fetcher Fetcher, apiURL, budgetKey string) (string, error) {
+	if fetcher == nil {
+		return "", fmt.Errorf("proxy not configured")
+	}
+	recordProxyRequest(budgetKey)
+
+	release := acquireFetchSlot(budgetKey, apiURL)
+	defer release()
+
+	return fetcher.FetchPage(apiURL)
+}
+
+// fetchChannelJSON returns the channel payload from Kick through fetcher, or from the synthetic generator in
+// FAKE_MODE.
+func fetchChannelJSON(fetcher Fetcher, apiURL, username string) (string, error) {
+	if FakeMode {
+		return fakeChannelJSON(username)
+	}
+	return fetchJSONVia(fetcher, apiURL, username)
+}
+
+// FetchChannelVideos returns the VODs Kick lists for a channel.
+func FetchChannelVideos(username string) ([]KickVideo, error) {
+	if FakeMode {
+		return fakeChannelVideos(username), nil
+	}
+
+	jsonString, err := fetchViaProxy(fmt.Sprintf("https://kick.com/api/v2/channels/%s/videos", username), username)
+	if err != nil {
+		return nil, err
+	}
+
+	var videos []KickVideo
+	if err := json.Unmarshal([]byte(jsonString), &videos); err != nil {
+		return nil, fmt.Errorf("error unmarshalling videos for %s: %w", username, err)
+	}
+	return videos, nil
+}
+
+// ResolveVOD finds the VOD page URL and playlist for a livestream. ok is false when Kick hasn't published it yet.
+func ResolveVOD(username string, livestreamID uint) (vodURL, sourceURL string, ok bool, err error) {
+	videos, err := FetchChannelVideos(username)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	for _, v := range videos {
+		if uint(v.ID) != livestreamID {
+			continue
+		}
+		if v.Video.UUID != "" {
+			vodURL = fmt.Sprintf("https://kick.com/%s/videos/%s", username, v.Video.UUID)
+		}
+		return vodURL, v.Source, vodURL != "" || v.Source != "", nil
+	}
+	return "", "", false, nil
+}
+
+// resolveReportVOD attaches the VOD to a report in the background, retrying while Kick processes it.
+func resolveReportVOD(reportID uuid.UUID, username string, livestreamID uint) {
+	for attempt := 1; attempt <= VODResolveAttempts; attempt++ {
+		vodURL, sourceURL, ok, err := ResolveVOD(username, livestreamID)
+		if err != nil {
+			log.Printf("Error resolving VOD for livestream %d (attempt %d/%d): %v", livestreamID, attempt, VODResolveAttempts, err)
+		} else if ok {
+			if err := db.DB.Model(&models.LivestreamReport{}).Where("id = ?", reportID).
+				Updates(map[string]any{"vod_url": vodURL, "vod_source_url": sourceURL}).Error; err != nil {
+				log.Printf("Error saving VOD for report %s: %v", reportID.String(), err)
+				return
+			}
+			log.Printf("Attached VOD %s to report %s (livestream %d)", vodURL, reportID.String(), livestreamID)
+			return
+		}
+
+		if attempt < VODResolveAttempts {
+			time.Sleep(VODResolveDelay)
+		}
+	}
+	log.Printf("No VOD found for livestream %d after %d attempts", livestreamID, VODResolveAttempts)
+}
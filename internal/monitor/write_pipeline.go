@@ -0,0 +1,493 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Write pipeline policies, applied when a table's queue is full
+const (
+	WritePolicyBlock      = "block"       // Wait for room, slowing ingestion down to the database's pace
+	WritePolicyDropOldest = "drop_oldest" // Drop the oldest queued row to make room
+	WritePolicySpill      = "spill"       // Append the row to a file under DB_WRITE_SPILL_DIR, replayed once the queue drains
+)
+
+var (
+	DBWriteQueueSize = util.GetEnvInt("DB_WRITE_QUEUE_SIZE", 10000)     // Default queue size of every table
+	DBWriteSpillDir  = util.GetEnvString("DB_WRITE_SPILL_DIR", "spill") // Where spill policies write their rows
+
+	// dbWriteConfigs is the policy and queue size of each pipelined table, overridden by DB_WRITE_QUEUES
+	// ("chat_messages=drop_oldest:20000,reaction_events=spill")
+	dbWriteConfigs = writeConfigsFromEnv()
+)
+
+const writeSpillRetryInterval = 10 * time.Second // Spill files are retried this often while the queue is idle
+
+// errWriteDropped is passed to the callback of rows dropped by a drop_oldest queue
+var errWriteDropped = errors.New("dropped from a full write queue")
+
+// writeTables are the tables written through a pipeline, the high volume writes of the ingestion path
+var writeTables = []string{"chat_messages", "reaction_events", "livestream_data"}
+
+var (
+	chatMessageWrites    = newWritePipeline[models.ChatMessage]("chat_messages")
+	reactionEventWrites  = newWritePipeline[models.ReactionEvent]("reaction_events")
+	livestreamDataWrites = newWritePipeline[models.LivestreamData]("livestream_data")
+
+	writePipelines = []writePipelineRunner{chatMessageWrites, reactionEventWrites, livestreamDataWrites}
+)
+
+// writeLatencyBuckets are the upper bounds of the batch insert latency histogram, in seconds
+var writeLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type writeConfig struct {
+	Policy    string
+	QueueSize int
+}
+
+func writeConfigsFromEnv() map[string]writeConfig {
+	configs := make(map[string]writeConfig, len(writeTables))
+	for _, table := range writeTables {
+		configs[table] = writeConfig{Policy: WritePolicyBlock, QueueSize: max(DBWriteQueueSize, 1)}
+	}
+
+	raw := strings.TrimSpace(os.Getenv("DB_WRITE_QUEUES"))
+	if raw == "" {
+		return configs
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		table, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		table = strings.TrimSpace(table)
+		if !ok {
+			util.InvalidSetting("invalid DB_WRITE_QUEUES entry %q, expected table=policy[:size]", pair)
+			continue
+		}
+		config, known := configs[table]
+		if !known {
+			log.Printf("Warning: table %q in DB_WRITE_QUEUES isn't written through a pipeline", table)
+			continue
+		}
+		policy, size, hasSize := strings.Cut(strings.TrimSpace(value), ":")
+		switch policy {
+		case WritePolicyBlock, WritePolicyDropOldest, WritePolicySpill:
+			config.Policy = policy
+		default:
+			util.InvalidSetting("unknown policy %q for %s in DB_WRITE_QUEUES, using %s", policy, table, config.Policy)
+		}
+		if hasSize {
+			if n, err := strconv.Atoi(size); err == nil && n > 0 {
+				config.QueueSize = n
+			} else {
+				util.InvalidSetting("invalid queue size for %s in DB_WRITE_QUEUES (%q), using %d", table, size, config.QueueSize)
+			}
+		}
+		configs[table] = config
+	}
+	return configs
+}
+
+// WritePipelineStats are the counters of a table's write pipeline since startup
+type WritePipelineStats struct {
+	Table          string                `json:"table"`
+	Policy         string                `json:"policy"`
+	QueueCapacity  int                   `json:"queue_capacity"`
+	QueueDepth     int                   `json:"queue_depth"`
+	Enqueued       int64                 `json:"enqueued"`
+	Blocked        int64                 `json:"blocked"` // Enqueues that waited for room
+	Written        int64                 `json:"written"`
+	Failed         int64                 `json:"failed"`
+	Dropped        int64                 `json:"dropped"`
+	Spilled        int64                 `json:"spilled"`
+	Replayed       int64                 `json:"replayed"` // Spilled rows written back
+	SpillFileBytes int64                 `json:"spill_file_bytes"`
+	WriteLatency   WriteLatencyHistogram `json:"write_latency"` // Per batch insert
+}
+
+// Pending is the number of rows queued or being inserted, whose fate isn't known yet
+func (s WritePipelineStats) Pending() int64 {
+	return max(s.Enqueued-s.Written-s.Failed-s.Dropped-s.Spilled, 0)
+}
+
+type WriteLatencyHistogram struct {
+	Count      int                  `json:"count"`
+	SumSeconds float64              `json:"sum_seconds"`
+	MaxSeconds float64              `json:"max_seconds"`
+	Buckets    []WriteLatencyBucket `json:"buckets"` // Cumulative
+}
+
+type WriteLatencyBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+type writePipelineRunner interface {
+	run(stop <-chan struct{})
+	stats() WritePipelineStats
+}
+
+type writeRequest[T any] struct {
+	row  T
+	done func(error) // Called once the row is written, spilled, dropped or failed; may be nil
+}
+
+func (r writeRequest[T]) finish(err error) {
+	if r.done != nil {
+		r.done(err)
+	}
+}
+
+// writePipeline batches the inserts of one table through a bounded queue, applying the table's policy when the
+// database falls behind
+type writePipeline[T any] struct {
+	table  string
+	config writeConfig
+	queue  chan writeRequest[T]
+
+	enqueued, blocked, written, failed, dropped, spilled, replayed atomic.Int64
+
+	spillMu sync.Mutex // Serializes appends to and the rotation of the spill file
+
+	latency struct {
+		sync.Mutex
+		counts []int // Per bucket, plus +Inf
+		count  int
+		sum    float64
+		max    float64
+	}
+}
+
+func newWritePipeline[T any](table string) *writePipeline[T] {
+	config := dbWriteConfigs[table]
+	p := &writePipeline[T]{table: table, config: config, queue: make(chan writeRequest[T], config.QueueSize)}
+	p.latency.counts = make([]int, len(writeLatencyBuckets)+1)
+	return p
+}
+
+// enqueue queues a row for insertion. done runs once the row's fate is known, on the pipeline's goroutine unless
+// the queue was full: nil when it was written or spilled to disk, errWriteDropped when a drop_oldest queue dropped
+// it, the insert error otherwise.
+func (p *writePipeline[T]) enqueue(row T, done func(error)) {
+	req := writeRequest[T]{row: row, done: done}
+	p.enqueued.Add(1)
+	select {
+	case p.queue <- req:
+		return
+	default:
+	}
+
+	switch p.config.Policy {
+	case WritePolicyDropOldest:
+		for {
+			select {
+			case p.queue <- req:
+				return
+			default:
+			}
+			select {
+			case oldest := <-p.queue:
+				p.dropped.Add(1)
+				oldest.finish(errWriteDropped)
+			default:
+			}
+		}
+	case WritePolicySpill:
+		if err := p.spill([]T{row}); err != nil {
+			log.Printf("Error spilling %s row to disk: %v", p.table, err)
+			p.failed.Add(1)
+			req.finish(err)
+			return
+		}
+		p.spilled.Add(1)
+		req.finish(nil)
+	default:
+		p.blocked.Add(1)
+		p.queue <- req
+	}
+}
+
+// run writes the queued rows until stop is closed, then drains the queue
+func (p *writePipeline[T]) run(stop <-chan struct{}) {
+	retry := time.NewTicker(writeSpillRetryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-stop:
+			for {
+				select {
+				case req := <-p.queue:
+					p.write(p.collect(req))
+				default:
+					return
+				}
+			}
+		case req := <-p.queue:
+			p.write(p.collect(req))
+			if len(p.queue) == 0 {
+				p.replaySpill()
+			}
+		case <-retry.C:
+			p.replaySpill()
+		}
+	}
+}
+
+// collect adds whatever else is queued to a batch, up to the batch size
+func (p *writePipeline[T]) collect(first writeRequest[T]) []writeRequest[T] {
+	batch := []writeRequest[T]{first}
+	for len(batch) < max(Capacity.DBBatchSize, 1) {
+		select {
+		case req := <-p.queue:
+			batch = append(batch, req)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (p *writePipeline[T]) write(batch []writeRequest[T]) {
+	rows := make([]T, len(batch))
+	for i, req := range batch {
+		rows[i] = req.row
+	}
+	if err := p.insert(rows); err == nil {
+		p.written.Add(int64(len(batch)))
+		for _, req := range batch {
+			req.finish(nil)
+		}
+		return
+	}
+
+	// With the database down, a spill policy keeps the rows on disk rather than losing them
+	if p.config.Policy == WritePolicySpill && !databaseReachable() {
+		if err := p.spill(rows); err == nil {
+			p.spilled.Add(int64(len(rows)))
+			for _, req := range batch {
+				req.finish(nil)
+			}
+			return
+		}
+	}
+
+	// One bad row, e.g. a replayed message ID, fails the whole insert; retry row by row so only it is lost
+	for _, req := range batch {
+		if err := p.insert([]T{req.row}); err != nil {
+			p.failed.Add(1)
+			recordDBWriteError(p.table, err)
+			req.finish(err)
+			continue
+		}
+		p.written.Add(1)
+		req.finish(nil)
+	}
+}
+
+func (p *writePipeline[T]) insert(rows []T) error {
+	start := time.Now()
+	err := db.DB.Create(&rows).Error
+	p.observeLatency(time.Since(start))
+	return err
+}
+
+func (p *writePipeline[T]) observeLatency(d time.Duration) {
+	seconds := d.Seconds()
+	bucket := sort.SearchFloat64s(writeLatencyBuckets, seconds)
+
+	p.latency.Lock()
+	defer p.latency.Unlock()
+	p.latency.counts[bucket]++
+	p.latency.count++
+	p.latency.sum += seconds
+	p.latency.max = math.Max(p.latency.max, seconds)
+}
+
+func (p *writePipeline[T]) spillPath() string {
+	return filepath.Join(DBWriteSpillDir, p.table+".ndjson")
+}
+
+// spill appends rows to the table's spill file, one JSON document per line
+func (p *writePipeline[T]) spill(rows []T) error {
+	p.spillMu.Lock()
+	defer p.spillMu.Unlock()
+
+	if err := os.MkdirAll(DBWriteSpillDir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.spillPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range rows {
+		if err := encoder.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// replaySpill writes the spill file back to the table. The file is moved aside first so new spills start a fresh
+// one; a replay interrupted by a crash is picked up again on the next start.
+func (p *writePipeline[T]) replaySpill() {
+	replayPath := p.spillPath() + ".replay"
+	p.spillMu.Lock()
+	if _, err := os.Stat(replayPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(p.spillPath(), replayPath); err != nil {
+			p.spillMu.Unlock()
+			return // Nothing spilled
+		}
+	}
+	p.spillMu.Unlock()
+
+	rows, skipped, err := readSpillFile[T](replayPath)
+	if err != nil {
+		log.Printf("Error reading %s spill file %s: %v", p.table, replayPath, err)
+		return
+	}
+	if skipped > 0 {
+		log.Printf("Warning: skipped %d undecodable line(s) of %s spill file %s", skipped, p.table, replayPath)
+		p.failed.Add(int64(skipped))
+	}
+
+	batchSize := max(Capacity.DBBatchSize, 1)
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+		if err := p.insert(batch); err == nil {
+			p.replayed.Add(int64(len(batch)))
+			continue
+		}
+		if !databaseReachable() {
+			// Still down, put the rest back for the next attempt
+			if err := p.spill(rows[start:]); err != nil {
+				log.Printf("Error re-spilling %s rows, keeping %s: %v", p.table, replayPath, err)
+				return
+			}
+			break
+		}
+		for i := range batch {
+			if err := p.insert(batch[i : i+1]); err != nil {
+				p.failed.Add(1)
+				recordDBWriteError(p.table, err)
+				continue
+			}
+			p.replayed.Add(1)
+		}
+	}
+	if err := os.Remove(replayPath); err != nil {
+		log.Printf("Error removing %s spill file %s: %v", p.table, replayPath, err)
+	}
+}
+
+// readSpillFile decodes a spill file, skipping lines cut short by a crash mid-append
+func readSpillFile[T any](path string) (rows []T, skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var row T
+		if json.Unmarshal(scanner.Bytes(), &row) != nil {
+			skipped++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, skipped, scanner.Err()
+}
+
+func (p *writePipeline[T]) stats() WritePipelineStats {
+	stats := WritePipelineStats{
+		Table:         p.table,
+		Policy:        p.config.Policy,
+		QueueCapacity: cap(p.queue),
+		QueueDepth:    len(p.queue),
+		Enqueued:      p.enqueued.Load(),
+		Blocked:       p.blocked.Load(),
+		Written:       p.written.Load(),
+		Failed:        p.failed.Load(),
+		Dropped:       p.dropped.Load(),
+		Spilled:       p.spilled.Load(),
+		Replayed:      p.replayed.Load(),
+	}
+	for _, path := range []string{p.spillPath(), p.spillPath() + ".replay"} {
+		if info, err := os.Stat(path); err == nil {
+			stats.SpillFileBytes += info.Size()
+		}
+	}
+
+	p.latency.Lock()
+	defer p.latency.Unlock()
+	stats.WriteLatency = WriteLatencyHistogram{
+		Count:      p.latency.count,
+		SumSeconds: p.latency.sum,
+		MaxSeconds: p.latency.max,
+		Buckets:    make([]WriteLatencyBucket, 0, len(p.latency.counts)),
+	}
+	cumulative := 0
+	for i, count := range p.latency.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(writeLatencyBuckets) {
+			le = strconv.FormatFloat(writeLatencyBuckets[i], 'f', -1, 64)
+		}
+		stats.WriteLatency.Buckets = append(stats.WriteLatency.Buckets, WriteLatencyBucket{LE: le, Count: cumulative})
+	}
+	return stats
+}
+
+// databaseReachable pings the database, telling a database outage apart from rows it rejects
+func databaseReachable() bool {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx) == nil
+}
+
+// RunWritePipelines writes the queued ingestion rows until stop is closed, then drains the queues
+func RunWritePipelines(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, pipeline := range writePipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pipeline.run(stop)
+		}()
+	}
+	wg.Wait()
+}
+
+// GetWritePipelineStats returns the counters of every write pipeline
+func GetWritePipelineStats() []WritePipelineStats {
+	stats := make([]WritePipelineStats, 0, len(writePipelines))
+	for _, pipeline := range writePipelines {
+		stats = append(stats, pipeline.stats())
+	}
+	return stats
+}
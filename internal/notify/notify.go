@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// Severity levels for alerts
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is a single operator notification.
+type Alert struct {
+	ID        string    `json:"id"`       // Idempotency key, the same on every delivery of the alert; generated when empty
+	Kind      string    `json:"kind"`     // Machine readable alert type (e.g. "fetch_failures")
+	Severity  string    `json:"severity"` // info, warning or critical
+	Subject   string    `json:"subject"`  // Channel username or subsystem the alert is about
+	Message   string    `json:"message"`
+	Data      any       `json:"data,omitempty"` // Structured payload of informational notifications, e.g. a digest
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Deliveries follow the Standard Webhooks scheme: every request carries the alert's ID in webhook-id, the Unix time
+// it was sent at in webhook-timestamp, and in webhook-signature "v1,<base64 HMAC-SHA256 of id.timestamp.body>" per
+// secret of the endpoint. Consumers reject stale timestamps to stop replays and drop IDs they've already handled.
+var (
+	WebhookAttempts      = util.GetEnvInt("NOTIFY_WEBHOOK_ATTEMPTS", 3)                       // Tries per endpoint, retried on network errors, 429 and 5xx
+	WebhookRetryDelay    = util.GetEnvDuration("NOTIFY_WEBHOOK_RETRY_DELAY", 2*time.Second)   // Before the first retry, doubling after
+	SecretRotationGrace  = util.GetEnvDuration("WEBHOOK_SECRET_ROTATION_GRACE", 24*time.Hour) // How long a rotated-out secret keeps signing
+	errRetryableDelivery = errors.New("retryable delivery failure")
+)
+
+// secretPrefix marks a generated secret, whose signing key is the base64 after it
+const secretPrefix = "whsec_"
+
+// Endpoint is a webhook alerts are delivered to
+type Endpoint struct {
+	URL            string
+	Secret         string // Signing secret, deliveries are unsigned when empty
+	PreviousSecret string // Signs deliveries alongside Secret while a rotation is phased in
+	Untrusted      bool   // Registered by a user rather than the operator, delivered to public addresses only
+}
+
+// ChannelEndpoints returns the webhooks registered for the channel an alert's subject names. It's set at startup, so
+// the package doesn't depend on the database; alerts go to the global webhook only while it's nil.
+var ChannelEndpoints func(subject string) ([]Endpoint, error)
+
+var webhook Endpoint
+
+var httpClient = &http.Client{Timeout: 10 * time.Second, CheckRedirect: noRedirects}
+
+// Init loads the notification configuration from the environment.
+// When NOTIFY_WEBHOOK_URL is unset alerts are only written to the log, and to the channels' own webhooks.
+func Init() {
+	webhook = Endpoint{URL: os.Getenv("NOTIFY_WEBHOOK_URL"), Secret: os.Getenv("NOTIFY_WEBHOOK_SECRET")}
+	if webhook.URL == "" {
+		log.Println("NOTIFY_WEBHOOK_URL not set. Alerts will only be logged.")
+	} else if webhook.Secret == "" {
+		log.Println("NOTIFY_WEBHOOK_SECRET not set. Alerts to NOTIFY_WEBHOOK_URL will be unsigned.")
+	}
+	if WebhookAttempts < 1 {
+		util.InvalidSetting("NOTIFY_WEBHOOK_ATTEMPTS must be at least 1, got %d; using 1", WebhookAttempts)
+		WebhookAttempts = 1
+	}
+}
+
+// NewSecret returns a random signing secret in the whsec_ format
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Send logs the alert and delivers it to the configured webhook and the webhooks of the alert's channel, if any.
+func Send(alert Alert) error {
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+	if alert.ID == "" {
+		alert.ID = uuid.NewString()
+	}
+
+	log.Printf("🚨 ALERT [%s] %s (%s): %s", alert.Severity, alert.Kind, alert.Subject, alert.Message)
+
+	var endpoints []Endpoint
+	if webhook.URL != "" {
+		endpoints = append(endpoints, webhook)
+	}
+	if ChannelEndpoints != nil {
+		channelEndpoints, err := ChannelEndpoints(alert.Subject)
+		if err != nil {
+			log.Printf("Error looking up webhooks of %s: %v", alert.Subject, err)
+		}
+		endpoints = append(endpoints, channelEndpoints...)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		if err := deliver(endpoint, alert.ID, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver posts the alert to an endpoint, retrying failures that may pass. Every attempt is signed with a fresh
+// timestamp under the same ID, so a consumer that got an attempt whose response was lost sees a duplicate.
+func deliver(endpoint Endpoint, id string, body []byte) error {
+	delay := WebhookRetryDelay
+	var err error
+	for attempt := 1; attempt <= WebhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = post(endpoint, id, body); err == nil || !errors.Is(err, errRetryableDelivery) {
+			return err
+		}
+	}
+	return err
+}
+
+func post(endpoint Endpoint, id string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("webhook-id", id)
+	req.Header.Set("webhook-timestamp", strconv.FormatInt(timestamp, 10))
+	if signature := Sign(id, timestamp, body, endpoint.Secret, endpoint.PreviousSecret); signature != "" {
+		req.Header.Set("webhook-signature", signature)
+	}
+
+	client := httpClient
+	if endpoint.Untrusted {
+		client = channelClient
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, ErrPrivateTarget) {
+		return fmt.Errorf("failed to deliver alert to webhook: %w", err) // Retrying resolves to the same address
+	}
+	if err != nil {
+		return fmt.Errorf("failed to deliver alert to webhook: %w (%w)", err, errRetryableDelivery)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned status %d (%w)", resp.StatusCode, errRetryableDelivery)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("webhook returned non-success status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the webhook-signature header of a delivery, one space separated "v1,<signature>" per non-empty secret
+func Sign(id string, timestamp int64, body []byte, secrets ...string) string {
+	var signatures []string
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		mac := hmac.New(sha256.New, signingKey(secret))
+		fmt.Fprintf(mac, "%s.%d.", id, timestamp)
+		mac.Write(body)
+		signatures = append(signatures, "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}
+	return strings.Join(signatures, " ")
+}
+
+// signingKey is the key bytes of a whsec_ secret, or a secret set by hand as is
+func signingKey(secret string) []byte {
+	if encoded, ok := strings.CutPrefix(secret, secretPrefix); ok {
+		if key, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			return key
+		}
+	}
+	return []byte(secret)
+}
+
+// SendAsync delivers the alert in the background, logging any delivery error.
+func SendAsync(alert Alert) {
+	go func() {
+		if err := Send(alert); err != nil {
+			log.Printf("Error sending alert %s for %s: %v", alert.Kind, alert.Subject, err)
+		}
+	}()
+}
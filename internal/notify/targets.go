@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/util"
+)
+
+// Channel webhooks are URLs any user registers, so unlike NOTIFY_WEBHOOK_URL they only reach public addresses: the
+// check runs when the connection is dialed, after DNS resolution, so a name resolving to an internal address later
+// is refused too. Deliveries never follow redirects.
+var WebhookAllowPrivateTargets = util.GetEnvBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false) // For self-hosted setups posting to internal services
+
+// ErrPrivateTarget is returned for webhook URLs on loopback, private, link-local and other non-public addresses
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// nonPublicPrefixes are ranges netip's predicates don't cover
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, embeds IPv4 addresses
+}
+
+// publicAddress reports whether deliveries may connect to the address
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookTarget resolves the host of a channel webhook URL and returns ErrPrivateTarget when any of its
+// addresses isn't public, so registering one fails early instead of every delivery
+func CheckWebhookTarget(ctx context.Context, target *url.URL) error {
+	if WebhookAllowPrivateTargets {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", target.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateTarget, target.Hostname(), addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// refusePrivateDial is the dialer Control of channel webhook deliveries, called with the resolved address
+func refusePrivateDial(network, address string, _ syscall.RawConn) error {
+	if WebhookAllowPrivateTargets {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, address)
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, addrPort.Addr().Unmap())
+	}
+	return nil
+}
+
+// noRedirects makes a client return redirects as responses, which deliveries count as failures
+func noRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// channelClient delivers to channel webhooks. It ignores HTTP_PROXY, as a proxy would dial the target in its place.
+var channelClient = &http.Client{
+	Timeout:       10 * time.Second,
+	CheckRedirect: noRedirects,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: refusePrivateDial}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
@@ -0,0 +1,293 @@
+package quota
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/retconned/kick-monitor/internal/auth"
+	"github.com/retconned/kick-monitor/internal/db"
+	"github.com/retconned/kick-monitor/internal/models"
+	"github.com/retconned/kick-monitor/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metered quota metrics
+const (
+	MetricChannels   = "channels_monitored"
+	MetricReports    = "report_generations"
+	MetricExportRows = "export_rows"
+)
+
+// Quota periods
+const (
+	PeriodLifetime = "lifetime"
+	PeriodDay      = "day"
+	PeriodMonth    = "month"
+	PeriodCurrent  = "current" // Counted from what the tenant has now rather than metered, so it goes down again
+)
+
+// Limit describes the allowance for a single metric. A Max of 0 means unlimited.
+type Limit struct {
+	Metric string
+	Period string
+	Max    int
+}
+
+// Limits holds the plan limits applied to every tenant, configured through the environment
+var Limits = map[string]Limit{
+	MetricChannels:   {Metric: MetricChannels, Period: PeriodCurrent, Max: util.GetEnvInt("QUOTA_MAX_CHANNELS", 0)},
+	MetricReports:    {Metric: MetricReports, Period: PeriodDay, Max: util.GetEnvInt("QUOTA_REPORTS_PER_DAY", 0)},
+	MetricExportRows: {Metric: MetricExportRows, Period: PeriodMonth, Max: util.GetEnvInt("QUOTA_EXPORT_ROWS_PER_MONTH", 0)},
+}
+
+// MetricUsage is the usage summary for a single metric returned by the usage endpoint
+type MetricUsage struct {
+	Metric   string     `json:"metric"`
+	Period   string     `json:"period"`
+	Used     int        `json:"used"`
+	Limit    int        `json:"limit"` // 0 means unlimited
+	ResetsAt *time.Time `json:"resets_at,omitempty"`
+}
+
+// periodBounds returns the start of the current period and when it resets (nil for lifetime)
+func periodBounds(period string, now time.Time) (time.Time, *time.Time) {
+	now = now.UTC()
+	switch period {
+	case PeriodDay:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		reset := start.AddDate(0, 0, 1)
+		return start, &reset
+	case PeriodMonth:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		reset := start.AddDate(0, 1, 0)
+		return start, &reset
+	default:
+		return time.Time{}, nil
+	}
+}
+
+// Usage returns the current usage of a metric for a tenant
+func Usage(tx *gorm.DB, tenantID uuid.UUID, metric string) (MetricUsage, error) {
+	limit, ok := Limits[metric]
+	if !ok {
+		return MetricUsage{}, fmt.Errorf("unknown quota metric %q", metric)
+	}
+	if limit.Period == PeriodCurrent {
+		used, err := countCurrent(tx, tenantID, metric)
+		if err != nil {
+			return MetricUsage{}, err
+		}
+		return MetricUsage{Metric: metric, Period: limit.Period, Used: used, Limit: limit.Max}, nil
+	}
+	start, reset := periodBounds(limit.Period, time.Now())
+
+	var usage models.QuotaUsage
+	err := tx.Where("tenant_id = ? AND metric = ? AND period_start = ?", tenantID, metric, start).First(&usage).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return MetricUsage{}, fmt.Errorf("failed to fetch usage for %s: %w", metric, err)
+	}
+
+	return MetricUsage{
+		Metric:   metric,
+		Period:   limit.Period,
+		Used:     usage.Count,
+		Limit:    limit.Max,
+		ResetsAt: reset,
+	}, nil
+}
+
+// countCurrent counts what a tenant has now of a PeriodCurrent metric
+func countCurrent(tx *gorm.DB, tenantID uuid.UUID, metric string) (int, error) {
+	var count int64
+	switch metric {
+	case MetricChannels:
+		// Deactivated channels count too, reactivating one isn't metered
+		if err := tx.Model(&models.MonitoredChannel{}).Where("added_by = ?", tenantID).Count(&count).Error; err != nil {
+			return 0, fmt.Errorf("failed to count channels of tenant %s: %w", tenantID, err)
+		}
+	default:
+		return 0, fmt.Errorf("quota metric %q is not counted", metric)
+	}
+	return int(count), nil
+}
+
+// Consume records n units of usage for a tenant. PeriodCurrent metrics are counted, not consumed.
+func Consume(tx *gorm.DB, tenantID uuid.UUID, metric string, n int) error {
+	limit, ok := Limits[metric]
+	if !ok {
+		return fmt.Errorf("unknown quota metric %q", metric)
+	}
+	if limit.Period == PeriodCurrent || n <= 0 {
+		return nil
+	}
+	start, _ := periodBounds(limit.Period, time.Now())
+
+	usage := models.QuotaUsage{
+		TenantID:    tenantID,
+		Metric:      metric,
+		PeriodStart: start,
+		Count:       n,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "metric"}, {Name: "period_start"}},
+		DoUpdates: clause.Assignments(map[string]any{"count": gorm.Expr("quota_usages.count + ?", n), "updated_at": time.Now()}),
+	}).Create(&usage).Error
+}
+
+// Reserve atomically takes n units of a metered metric for a tenant, so concurrent requests can't all pass a check
+// of the same usage. When they would exceed the limit nothing is taken and ok is false, with the usage to report.
+func Reserve(tx *gorm.DB, tenantID uuid.UUID, metric string, n int) (usage MetricUsage, ok bool, err error) {
+	limit, known := Limits[metric]
+	if !known {
+		return MetricUsage{}, false, fmt.Errorf("unknown quota metric %q", metric)
+	}
+	if limit.Period == PeriodCurrent {
+		return MetricUsage{}, false, fmt.Errorf("quota metric %q is counted and can't be reserved", metric)
+	}
+	if limit.Max == 0 || n <= 0 {
+		return MetricUsage{}, true, Consume(tx, tenantID, metric, n)
+	}
+	if n <= limit.Max {
+		start, _ := periodBounds(limit.Period, time.Now())
+		var counts []int
+		if err := tx.Raw(`INSERT INTO quota_usages (tenant_id, metric, period_start, count, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (tenant_id, metric, period_start) DO UPDATE
+				SET count = quota_usages.count + EXCLUDED.count, updated_at = EXCLUDED.updated_at
+				WHERE quota_usages.count + EXCLUDED.count <= ?
+			RETURNING count`, tenantID, metric, start, n, time.Now(), limit.Max).Scan(&counts).Error; err != nil {
+			return MetricUsage{}, false, fmt.Errorf("failed to reserve %d of %s: %w", n, metric, err)
+		}
+		if len(counts) > 0 {
+			return MetricUsage{}, true, nil
+		}
+	}
+	usage, err = Usage(tx, tenantID, metric)
+	return usage, false, err
+}
+
+// Release gives back n reserved units a request didn't use
+func Release(tx *gorm.DB, tenantID uuid.UUID, metric string, n int) error {
+	limit, ok := Limits[metric]
+	if !ok {
+		return fmt.Errorf("unknown quota metric %q", metric)
+	}
+	if limit.Period == PeriodCurrent || n <= 0 {
+		return nil
+	}
+	start, _ := periodBounds(limit.Period, time.Now())
+	return tx.Model(&models.QuotaUsage{}).Where("tenant_id = ? AND metric = ? AND period_start = ?", tenantID, metric, start).
+		Update("count", gorm.Expr("GREATEST(count - ?, 0)", n)).Error
+}
+
+// Remaining returns how many units are left for a tenant, or -1 when the metric is unlimited
+func Remaining(tx *gorm.DB, tenantID uuid.UUID, metric string) (int, error) {
+	usage, err := Usage(tx, tenantID, metric)
+	if err != nil {
+		return 0, err
+	}
+	if usage.Limit == 0 {
+		return -1, nil
+	}
+	return max(0, usage.Limit-usage.Used), nil
+}
+
+// Enforce returns middleware rejecting requests with 429 once the tenant has exhausted the metric.
+// One unit is reserved before the handler runs and given back unless it responds with 201 Created or 202 Accepted.
+// Counted metrics can't be reserved, so a tenant's requests for them run one at a time instead.
+func Enforce(metric string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID, err := auth.TenantID(c)
+			if err != nil {
+				return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+			}
+			if limit := Limits[metric]; limit.Period == PeriodCurrent {
+				if limit.Max == 0 {
+					return next(c)
+				}
+				return enforceCounted(c, next, tenantID, metric)
+			}
+
+			usage, ok, err := Reserve(db.FromContext(c), tenantID, metric, 1)
+			if err != nil {
+				log.Printf("Error checking quota %s for tenant %s: %v", metric, tenantID, err)
+				return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+			}
+			if !ok {
+				return Exceeded(c, usage)
+			}
+
+			err = next(c)
+			if status := c.Response().Status; err != nil || (status != http.StatusCreated && status != http.StatusAccepted) {
+				if err := Release(db.FromContext(c), tenantID, metric, 1); err != nil {
+					log.Printf("Error releasing quota usage %s for tenant %s: %v", metric, tenantID, err)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// enforceCounted runs the request while holding a per tenant and metric advisory lock on a dedicated connection, so
+// what the handler adds is counted before the tenant's next request for the metric checks its usage
+func enforceCounted(c echo.Context, next echo.HandlerFunc, tenantID uuid.UUID, metric string) error {
+	lockKey := fmt.Sprintf("quota:%s:%s", metric, tenantID)
+	return db.FromContext(c).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(hashtext(?))", lockKey).Error; err != nil {
+			log.Printf("Error locking quota %s for tenant %s: %v", metric, tenantID, err)
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(hashtext(?))", lockKey).Error; err != nil {
+				log.Printf("Error unlocking quota %s for tenant %s: %v", metric, tenantID, err)
+			}
+		}()
+
+		usage, err := Usage(conn, tenantID, metric)
+		if err != nil {
+			log.Printf("Error checking quota %s for tenant %s: %v", metric, tenantID, err)
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, "Failed to check usage quota")
+		}
+		if usage.Used >= usage.Limit {
+			return Exceeded(c, usage)
+		}
+		return next(c)
+	})
+}
+
+// Exceeded writes the 429 response of a request over the tenant's quota
+func Exceeded(c echo.Context, usage MetricUsage) error {
+	if usage.ResetsAt != nil {
+		c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(*usage.ResetsAt).Seconds())))
+	}
+	detail := fmt.Sprintf("Quota exceeded for %s: %d of %d used this %s", usage.Metric, usage.Used, usage.Limit, usage.Period)
+	if usage.Period == PeriodCurrent {
+		detail = fmt.Sprintf("Quota exceeded for %s: %d of %d in use", usage.Metric, usage.Used, usage.Limit)
+	}
+	return util.WriteProblem(c, util.NewProblem(http.StatusTooManyRequests, util.ErrQuotaExceeded, detail).With("quota", usage))
+}
+
+// UsageHandler handles GET /protected/usage and returns the caller's usage for every metric
+func UsageHandler(c echo.Context) error {
+	tenantID, err := auth.TenantID(c)
+	if err != nil {
+		return util.NewProblem(http.StatusUnauthorized, util.ErrUnauthorized, "Authentication required")
+	}
+
+	usages := make([]MetricUsage, 0, len(Limits))
+	for _, metric := range []string{MetricChannels, MetricReports, MetricExportRows} {
+		usage, err := Usage(db.FromContext(c), tenantID, metric)
+		if err != nil {
+			return util.Problem(c, http.StatusInternalServerError, util.ErrInternal, fmt.Sprintf("Failed to fetch usage: %v", err))
+		}
+		usages = append(usages, usage)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"tenant_id": tenantID, "usage": usages})
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/bytes"
+)
+
+// APIBodyLimit caps the request body of write endpoints, e.g. "512K" or "2M"
+var APIBodyLimit = GetEnvString("API_BODY_LIMIT", "1M")
+
+// ReportImportBodyLimit caps the body of report archive imports, which hold a whole stream's report
+var ReportImportBodyLimit = GetEnvString("REPORT_IMPORT_BODY_LIMIT", "64M")
+
+// BodyLimit rejects POST, PUT, PATCH and DELETE requests whose body exceeds limit with a 413 problem. Bodies
+// without a Content-Length are cut off once they reach the limit. Routes in exempt, by their path pattern, set their
+// own limit.
+func BodyLimit(limit string, exempt ...string) (echo.MiddlewareFunc, error) {
+	size, err := bytes.Parse(limit)
+	if err != nil || size <= 0 {
+		return nil, fmt.Errorf("invalid body limit %q, expected a size such as 512K or 2M", limit)
+	}
+	return middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Skipper: func(c echo.Context) bool {
+			if slices.Contains(exempt, c.Path()) {
+				return true
+			}
+			switch c.Request().Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				return false
+			}
+			return true
+		},
+		Limit: limit,
+	}), nil
+}
@@ -0,0 +1,195 @@
+package util
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Response compression settings. Only gzip is offered: it is the encoding every client and proxy understands and
+// needs no dependency; a reverse proxy in front can still negotiate brotli itself.
+var (
+	CompressMinBytes = GetEnvInt("COMPRESS_MIN_BYTES", 1024)                // Smaller bodies are sent as is
+	CompressLevel    = GetEnvInt("COMPRESS_LEVEL", gzip.DefaultCompression) // 1 (fastest) to 9 (smallest)
+)
+
+// compressibleTypes are the response content types worth compressing. Server-Sent Events are left out, proxies
+// and browsers handle compressed event streams poorly.
+var compressibleTypes = []string{
+	"application/json",
+	MIMEProblemJSON,
+	"application/x-ndjson",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/html",
+	"text/plain",
+	"text/csv",
+	"text/calendar",
+	"text/xml",
+}
+
+var gzipWriters sync.Pool
+
+// Compress gzips the responses of clients accepting it, for the compressible content types and bodies of at least
+// COMPRESS_MIN_BYTES. Streamed responses are compressed as they are flushed.
+func Compress() (echo.MiddlewareFunc, error) {
+	if CompressLevel < gzip.HuffmanOnly || CompressLevel > gzip.BestCompression {
+		return nil, fmt.Errorf("COMPRESS_LEVEL must be between %d and %d, got %d", gzip.HuffmanOnly, gzip.BestCompression, CompressLevel)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			if !acceptsGzip(c.Request().Header.Get(echo.HeaderAcceptEncoding)) || c.Request().Method == http.MethodHead {
+				return next(c)
+			}
+
+			writer := &compressWriter{ResponseWriter: res.Writer}
+			res.Writer = writer
+			defer func() {
+				writer.close()
+				res.Writer = writer.ResponseWriter
+			}()
+			return next(c)
+		}
+	}, nil
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.TrimSpace(coding) != "*" {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, candidate := range compressibleTypes {
+		if mediaType == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter holds back the status and the first COMPRESS_MIN_BYTES of the body until it knows whether the
+// response is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if !compressible(w.Header().Get(echo.HeaderContentType)) || w.Header().Get(echo.HeaderContentEncoding) != "" ||
+		status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.WriteHeader(http.StatusOK)
+		}
+		if !w.decided {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) >= CompressMinBytes {
+				if err := w.decide(true); err != nil {
+					return 0, err
+				}
+			}
+			return len(b), nil
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the status, with the compression headers when compressing, and what was held back
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		w.Header().Set(echo.HeaderContentEncoding, "gzip")
+		w.Header().Del(echo.HeaderContentLength)
+		if pooled, ok := gzipWriters.Get().(*gzip.Writer); ok {
+			pooled.Reset(w.ResponseWriter)
+			w.gz = pooled
+		} else {
+			w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, CompressLevel) // Level validated by Compress
+		}
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush sends what was written so far. Streamed responses are compressed from their first flush on, whatever the
+// size of that first chunk, as the rest of the stream usually dwarfs it.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.status != 0 && len(w.buf) > 0)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) close() {
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		w.decide(false) // Below COMPRESS_MIN_BYTES
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	invalidSettingsMu sync.Mutex
+	invalidSettings   []string
+)
+
+// InvalidSetting logs a setting that couldn't be used as given and remembers it for the startup config report
+func InvalidSetting(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", msg)
+	invalidSettingsMu.Lock()
+	invalidSettings = append(invalidSettings, msg)
+	invalidSettingsMu.Unlock()
+}
+
+// InvalidSettings returns the settings reported through InvalidSetting so far, most are read when packages load
+func InvalidSettings() []string {
+	invalidSettingsMu.Lock()
+	defer invalidSettingsMu.Unlock()
+	return append([]string(nil), invalidSettings...)
+}
+
+// GetEnvInt reads an integer environment variable, falling back to def when unset or invalid.
+func GetEnvInt(key string, def int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(val)
+	if err != nil {
+		InvalidSetting("invalid integer for %s (%q), using default %d", key, val, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvFloat reads a float environment variable, falling back to def when unset or invalid.
+func GetEnvFloat(key string, def float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		InvalidSetting("invalid float for %s (%q), using default %v", key, val, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvBool reads a boolean environment variable, falling back to def when unset or invalid.
+func GetEnvBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(val)
+	if err != nil {
+		InvalidSetting("invalid boolean for %s (%q), using default %t", key, val, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvDuration reads a duration environment variable (e.g. "90s", "5m"), falling back to def when unset or invalid.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(val)
+	if err != nil {
+		InvalidSetting("invalid duration for %s (%q), using default %s", key, val, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvString reads a string environment variable, falling back to def when unset.
+func GetEnvString(key string, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
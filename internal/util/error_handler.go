@@ -1,37 +1,39 @@
 package util
 
 import (
-	"github.com/labstack/echo/v4"
+	"errors"
 	"net/http"
+
+	"github.com/labstack/echo/v4"
 )
 
+// CustomHTTPErrorHandler renders every error returned by handlers and middleware as RFC 7807 problem+json
 func CustomHTTPErrorHandler(err error, c echo.Context) {
-	report, ok := err.(*echo.HTTPError)
-	if !ok {
-		report = echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	if c.Response().Committed {
+		return
 	}
 
-	// Send JSON response
-	if !c.Response().Committed {
+	var problem *ProblemDetails
+	if !errors.As(err, &problem) {
+		report, ok := err.(*echo.HTTPError)
+		if !ok {
+			report = echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 		if report.Internal != nil {
 			c.Logger().Error("Internal error:", report.Internal) // Log internal errors
 		}
 
-		// Map common errors to client-friendly messages or structure
-		message := report.Message
-		if msgStr, isString := message.(string); !isString {
-			message = "An unexpected error occurred" // Generic message if original is not string
-		} else if msgStr == http.StatusText(http.StatusInternalServerError) {
-			message = "An internal server error occurred" // More friendly for 500
-		}
-
-		errorResponse := map[string]any{
-			"message": message,
-			"code":    report.Code,
+		// Map common errors to client-friendly messages
+		detail, isString := report.Message.(string)
+		if !isString {
+			detail = "An unexpected error occurred" // Generic message if original is not string
+		} else if detail == http.StatusText(http.StatusInternalServerError) {
+			detail = "An internal server error occurred" // More friendly for 500
 		}
+		problem = NewProblem(report.Code, "", detail)
+	}
 
-		if err := c.JSON(report.Code, errorResponse); err != nil {
-			c.Logger().Error("Failed to send error response:", err)
-		}
+	if err := WriteProblem(c, problem); err != nil {
+		c.Logger().Error("Failed to send error response:", err)
 	}
 }
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEProblemJSON is the content type of RFC 7807 error responses
+const MIMEProblemJSON = "application/problem+json"
+
+// problemTypePrefix prefixes the error code to form the problem "type" URI
+const problemTypePrefix = "urn:kick-monitor:problem:"
+
+// Machine-readable error codes, returned as "code" (and in "type") so clients can branch on them
+const (
+	ErrInvalidRequestBody  = "invalid_request_body"
+	ErrValidationFailed    = "validation_failed"
+	ErrInvalidChannelID    = "invalid_channel_id"
+	ErrInvalidLivestreamID = "invalid_livestream_id"
+	ErrInvalidReportID     = "invalid_report_id"
+	ErrChannelNotFound     = "channel_not_found"
+	ErrReportNotFound      = "report_not_found"
+	ErrProfileNotFound     = "profile_not_found"
+	ErrNotFound            = "not_found"
+	ErrReportInProgress    = "report_in_progress"
+	ErrConflict            = "conflict"
+	ErrUserExists          = "user_exists"
+	ErrUnauthorized        = "unauthorized"
+	ErrInvalidCredentials  = "invalid_credentials"
+	ErrForbidden           = "forbidden"
+	ErrRateLimited         = "rate_limited"
+	ErrQuotaExceeded       = "quota_exceeded"
+	ErrPayloadTooLarge     = "payload_too_large"
+	ErrDraining            = "draining"
+	ErrInternal            = "internal_error"
+)
+
+// ProblemDetails is an RFC 7807 problem+json error body. Extensions are merged into the top-level object.
+type ProblemDetails struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Code       string         `json:"code"`
+	Extensions map[string]any `json:"-"`
+}
+
+// NewProblem builds a problem for the given status and error code. It can be returned as an error from
+// handlers and middleware; CustomHTTPErrorHandler renders it.
+func NewProblem(status int, code, detail string) *ProblemDetails {
+	if code == "" {
+		code = DefaultProblemCode(status)
+	}
+	return &ProblemDetails{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// With adds an extension member to the problem
+func (p *ProblemDetails) With(key string, value any) *ProblemDetails {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *ProblemDetails) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		body[k] = v
+	}
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	return json.Marshal(body)
+}
+
+// Problem writes an RFC 7807 problem+json response
+func Problem(c echo.Context, status int, code, detail string) error {
+	return WriteProblem(c, NewProblem(status, code, detail))
+}
+
+// WriteProblem writes p as the response, using the request path as its instance
+func WriteProblem(c echo.Context, p *ProblemDetails) error {
+	if p.Instance == "" {
+		p.Instance = c.Request().URL.Path
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return c.Blob(p.Status, MIMEProblemJSON, body)
+}
+
+// DefaultProblemCode is the error code used for a status when no more specific one applies
+func DefaultProblemCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrValidationFailed
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusRequestEntityTooLarge:
+		return ErrPayloadTooLarge
+	}
+	return ErrInternal
+}
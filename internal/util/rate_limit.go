@@ -0,0 +1,148 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// Requests are limited per client IP. Routes matching a rule of RATE_LIMIT_ROUTES get the rule's own budget, the
+// others share the default one of RATE_LIMIT and RATE_LIMIT_BURST.
+var (
+	RateLimit       = GetEnvFloat("RATE_LIMIT", 10)     // Requests per second per client, 0 disables the default limit
+	RateLimitBurst  = GetEnvInt("RATE_LIMIT_BURST", 30) // Requests a client may make at once
+	RateLimitRoutes = GetEnvString("RATE_LIMIT_ROUTES", DefaultRateLimitRoutes)
+)
+
+// DefaultRateLimitRoutes is strict on report generation, exports and sign-in, lenient on what dashboards poll
+const DefaultRateLimitRoutes = "POST /api/login=1:5," +
+	"POST /api/register=0.2:3," +
+	"POST /api/account/verify_email=0.2:5," +
+	"POST /api/protected/account/verify_email=0.05:3," +
+	"POST /api/protected/process_livestream_report=0.2:5," +
+	"GET /api/protected/reports/:reportID/archive=0.5:5," +
+	"POST /api/protected/reports/import=0.2:2," +
+	"GET /api/protected/export/*=1:5," +
+	"GET /api/health=50:100," +
+	"GET /api/status=50:100," +
+	"GET /api/live/aggregate=50:100"
+
+// rateLimitStoreTTL is how long the budget of a client that stopped sending requests is kept
+const rateLimitStoreTTL = 3 * time.Minute
+
+// RateLimitRule is the request budget of the routes it matches
+type RateLimitRule struct {
+	Method string  // Empty matches every method
+	Path   string  // Route pattern as registered, a trailing * matches every route under it
+	Rate   float64 // Requests per second per client, 0 for unlimited
+	Burst  int
+}
+
+// ParseRateLimitRules parses comma separated "[METHOD ]PATH=RATE:BURST" rules, e.g.
+// "GET /api/protected/export/*=1:5,POST /api/login=1:5"
+func ParseRateLimitRules(spec string) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, budget, ok := strings.Cut(entry, "=")
+		rateValue, burstValue, hasBurst := strings.Cut(budget, ":")
+		if !ok || !hasBurst {
+			return nil, fmt.Errorf("invalid rate limit rule %q, expected [METHOD ]PATH=RATE:BURST", entry)
+		}
+
+		rule := RateLimitRule{Path: strings.TrimSpace(route)}
+		if method, path, hasMethod := strings.Cut(rule.Path, " "); hasMethod {
+			rule.Method, rule.Path = strings.ToUpper(method), strings.TrimSpace(path)
+		}
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("invalid rate limit rule %q, the path must start with /", entry)
+		}
+		var err error
+		if rule.Rate, err = strconv.ParseFloat(strings.TrimSpace(rateValue), 64); err != nil || rule.Rate < 0 {
+			return nil, fmt.Errorf("invalid rate in rate limit rule %q", entry)
+		}
+		if rule.Burst, err = strconv.Atoi(strings.TrimSpace(burstValue)); err != nil || (rule.Burst < 1 && rule.Rate > 0) {
+			return nil, fmt.Errorf("invalid burst in rate limit rule %q", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matches reports whether the rule covers a route, and how specifically: exact paths beat prefixes, longer prefixes
+// beat shorter ones and a method beats none
+func (r RateLimitRule) matches(method, path string) (int, bool) {
+	if r.Method != "" && r.Method != method {
+		return 0, false
+	}
+	specificity := 0
+	if r.Method != "" {
+		specificity = 1
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		if !strings.HasPrefix(path, prefix) {
+			return 0, false
+		}
+		return specificity + 2*len(prefix), true
+	}
+	if r.Path != path {
+		return 0, false
+	}
+	return specificity + 2*len(path) + 2, true
+}
+
+// rateLimitBucket is the budget of a rule, or the default one
+type rateLimitBucket struct {
+	store *middleware.RateLimiterMemoryStore // nil when unlimited
+}
+
+func newRateLimitBucket(limit float64, burst int) rateLimitBucket {
+	if limit <= 0 {
+		return rateLimitBucket{}
+	}
+	return rateLimitBucket{store: middleware.NewRateLimiterMemoryStoreWithConfig(
+		middleware.RateLimiterMemoryStoreConfig{Rate: rate.Limit(limit), Burst: burst, ExpiresIn: rateLimitStoreTTL},
+	)}
+}
+
+// RateLimiter limits the requests of each client IP with the budget of the most specific rule matching the route,
+// or the default budget. Clients over it get a 429 problem.
+func RateLimiter(rules []RateLimitRule) (echo.MiddlewareFunc, error) {
+	if RateLimit < 0 || (RateLimit > 0 && RateLimitBurst < 1) {
+		return nil, fmt.Errorf("RATE_LIMIT must be at least 0 and RATE_LIMIT_BURST at least 1, got %v and %d", RateLimit, RateLimitBurst)
+	}
+	fallback := newRateLimitBucket(RateLimit, RateLimitBurst)
+	buckets := make([]rateLimitBucket, len(rules))
+	for i, rule := range rules {
+		buckets[i] = newRateLimitBucket(rule.Rate, rule.Burst)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			bucket, best := fallback, -1
+			for i, rule := range rules {
+				if specificity, ok := rule.matches(c.Request().Method, c.Path()); ok && specificity > best {
+					bucket, best = buckets[i], specificity
+				}
+			}
+			if bucket.store == nil {
+				return next(c)
+			}
+			if allowed, err := bucket.store.Allow(c.RealIP()); err != nil {
+				return Problem(c, http.StatusForbidden, ErrForbidden, "Unable to identify client")
+			} else if !allowed {
+				return Problem(c, http.StatusTooManyRequests, ErrRateLimited, "Too many requests, slow down")
+			}
+			return next(c)
+		}
+	}, nil
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers the client IP can be read from when the request comes through a trusted proxy
+const (
+	RealIPHeaderXFF        = "x-forwarded-for"
+	RealIPHeaderXRealIP    = "x-real-ip"
+	RealIPHeaderCloudflare = "cf-connecting-ip"
+)
+
+// NewIPExtractor builds the echo.IPExtractor used for c.RealIP() (rate limiting, request and audit logs).
+//
+// TRUSTED_PROXIES is a comma separated list of IPs/CIDRs of the reverse proxies in front of the app
+// (e.g. "10.0.0.0/8,172.16.0.0/12" or Cloudflare's ranges). Forwarding headers are only honoured when the
+// connecting peer is one of them, so clients can't spoof their IP. When unset the peer address is used as is.
+// REAL_IP_HEADER selects the header: x-forwarded-for (default), x-real-ip or cf-connecting-ip.
+func NewIPExtractor() (echo.IPExtractor, error) {
+	rawProxies := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES"))
+	if rawProxies == "" {
+		log.Println("TRUSTED_PROXIES not set. Client IPs are taken from the connection, forwarding headers are ignored.")
+		return echo.ExtractIPDirect(), nil
+	}
+
+	var ranges []*net.IPNet
+	for _, entry := range strings.Split(rawProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", entry, err)
+		}
+		ranges = append(ranges, ipNet)
+	}
+
+	header := strings.ToLower(strings.TrimSpace(os.Getenv("REAL_IP_HEADER")))
+	if header == "" {
+		header = RealIPHeaderXFF
+	}
+
+	trustOptions := []echo.TrustOption{
+		// Only the configured proxies are trusted, not every private or loopback address
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipNet := range ranges {
+		trustOptions = append(trustOptions, echo.TrustIPRange(ipNet))
+	}
+
+	log.Printf("Trusting %d proxy range(s), client IP read from %s", len(ranges), header)
+
+	switch header {
+	case RealIPHeaderXFF:
+		return echo.ExtractIPFromXFFHeader(trustOptions...), nil
+	case RealIPHeaderXRealIP:
+		return echo.ExtractIPFromRealIPHeader(trustOptions...), nil
+	case RealIPHeaderCloudflare:
+		return extractIPFromSingleHeader("CF-Connecting-IP", ranges), nil
+	default:
+		return nil, fmt.Errorf("unsupported REAL_IP_HEADER %q", header)
+	}
+}
+
+// extractIPFromSingleHeader reads the client IP from a single-value header set by a trusted proxy.
+func extractIPFromSingleHeader(header string, trusted []*net.IPNet) echo.IPExtractor {
+	direct := echo.ExtractIPDirect()
+	return func(req *http.Request) string {
+		peer := direct(req)
+		peerIP := net.ParseIP(peer)
+		if peerIP == nil || !ipInRanges(peerIP, trusted) {
+			return peer
+		}
+		if ip := net.ParseIP(strings.TrimSpace(req.Header.Get(header))); ip != nil {
+			return ip.String()
+		}
+		return peer
+	}
+}
+
+func ipInRanges(ip net.IP, ranges []*net.IPNet) bool {
+	for _, ipNet := range ranges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// CPULimit returns how many CPUs the process may use: GOMAXPROCS, lowered to the cgroup (container) CPU quota
+// when there is one
+func CPULimit() int {
+	cpus := runtime.GOMAXPROCS(0)
+	if quota, ok := cgroupCPUQuota(); ok {
+		cpus = min(cpus, max(int(math.Ceil(quota)), 1))
+	}
+	return cpus
+}
+
+// MemoryLimit returns the memory available to the process in bytes and where the limit came from: GOMEMLIMIT,
+// the cgroup memory limit or the host memory. 0 means unknown.
+func MemoryLimit() (int64, string) {
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit < math.MaxInt64 {
+		return limit, "GOMEMLIMIT"
+	}
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		if limit, ok := readCgroupLimit(path); ok {
+			return limit, "cgroup"
+		}
+	}
+	if total, ok := hostMemory(); ok {
+		return total, "host"
+	}
+	return 0, "unknown"
+}
+
+// cgroupCPUQuota reads the CPU quota of cgroup v2 (cpu.max) or v1 (cfs_quota_us / cfs_period_us)
+func cgroupCPUQuota() (float64, bool) {
+	if raw, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(raw))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, errQuota := strconv.ParseFloat(fields[0], 64)
+			period, errPeriod := strconv.ParseFloat(fields[1], 64)
+			if errQuota == nil && errPeriod == nil && period > 0 {
+				return quota / period, true
+			}
+		}
+		return 0, false
+	}
+
+	quota, okQuota := readCgroupInt("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period, okPeriod := readCgroupInt("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if okQuota && okPeriod && quota > 0 && period > 0 {
+		return float64(quota) / float64(period), true
+	}
+	return 0, false
+}
+
+// readCgroupLimit reads a memory limit file, ignoring "max" and the huge values v1 uses for no limit
+func readCgroupLimit(path string) (int64, bool) {
+	limit, ok := readCgroupInt(path)
+	if !ok || limit <= 0 || limit >= 1<<60 {
+		return 0, false
+	}
+	return limit, true
+}
+
+func readCgroupInt(path string) (int64, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+func hostMemory() (int64, bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return kb * 1024, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KickLocation is the timezone of Kick timestamps that carry no offset (KICK_TIMEZONE, UTC by default)
+var KickLocation = loadKickLocation(GetEnvString("KICK_TIMEZONE", "UTC"))
+
+// ErrEmptyTimestamp is returned by ParseKickTime for empty values
+var ErrEmptyTimestamp = errors.New("empty timestamp")
+
+// kickTimeLayouts are tried in order; the first ones are what the Kick API returns today
+var kickTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	time.RFC1123Z,
+	time.RFC1123,
+	"02/01/2006 15:04:05",
+	time.DateOnly,
+}
+
+func loadKickLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		InvalidSetting("invalid KICK_TIMEZONE %q, using UTC: %v", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseKickTime parses a timestamp from the Kick API in any of the formats it has been seen in, including unix
+// seconds or milliseconds. Values without an offset are read in KickLocation. The result is in UTC.
+func ParseKickTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, ErrEmptyTimestamp
+	}
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if unix > 1e12 { // Milliseconds
+			return time.UnixMilli(unix).UTC(), nil
+		}
+		return time.Unix(unix, 0).UTC(), nil
+	}
+
+	for _, layout := range kickTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, KickLocation); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp format %q", value)
+}
@@ -47,7 +47,7 @@ export async function apiFetch<T>(
             message: response.statusText,
         }));
         throw new ApiError(
-            errorData.message || "An unknown API error occurred.",
+            errorData.detail || errorData.message || "An unknown API error occurred.",
             response.status,
         );
     }
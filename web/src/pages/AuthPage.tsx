@@ -64,7 +64,7 @@ const registerUser = async (data: TSignupSchema) => {
 
     if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || "Failed to register.");
+        throw new Error(errorData.detail || "Failed to register.");
     }
     return response.json();
 };